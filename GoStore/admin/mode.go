@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/opmode"
+	"github.com/ramG-reddy/sms-store/problem"
+)
+
+// ModeStatus is the JSON body served by the /admin/mode endpoints
+type ModeStatus struct {
+	Mode   opmode.Mode `json:"mode"`
+	Since  time.Time   `json:"since"`
+	Reads  bool        `json:"reads"`
+	Writes bool        `json:"writes"`
+}
+
+// modeRequest is the JSON body of PUT /admin/mode
+type modeRequest struct {
+	Mode string `json:"mode"`
+}
+
+// modeStatus reports the service mode and what it serves
+func (s *Server) modeStatus(w http.ResponseWriter, r *http.Request) {
+	mode := s.modes.Mode()
+	status := ModeStatus{Mode: mode, Since: s.modes.Since(), Reads: mode.Reads(), Writes: mode.Writes()}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding service mode", "error", err)
+	}
+}
+
+// servingWrites answers 503 Service Unavailable to the wrapped endpoint while
+// the service mode refuses writes
+func (s *Server) servingWrites(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.modes != nil {
+			if mode := s.modes.Mode(); !mode.Writes() {
+				problem.Write(w, http.StatusServiceUnavailable, "Writes are not accepted while the service is "+string(mode))
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// setMode switches the service mode, pausing ingestion for read_only and
+// refusing reads for write_only, until it is switched back to read_write
+func (s *Server) setMode(w http.ResponseWriter, r *http.Request) {
+	var req modeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	mode, err := opmode.Parse(req.Mode)
+	if err != nil {
+		problem.Write(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if s.modes.Set(mode) {
+		slog.InfoContext(r.Context(), "Service mode changed by operator", "mode", mode)
+	}
+	s.modeStatus(w, r)
+}
@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ramG-reddy/sms-store/kafka"
+	"github.com/ramG-reddy/sms-store/opmode"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+func TestWritesRefusedWhileReadOnly(t *testing.T) {
+	// Refused before the quarantine or the requeue service is used, so neither needs a backend
+	requeue := services.NewRequeueService(nil, nil, nil, 1)
+	s := NewServer(nil, nil, &kafka.Quarantine{}, nil, requeue, opmode.NewSwitch(opmode.ReadOnly), nil)
+
+	routes := []struct{ method, path string }{
+		{http.MethodPost, "/admin/messages/507f1f77bcf86cd799439011/requeue"},
+		{http.MethodPost, "/admin/quarantine/507f1f77bcf86cd799439011/reprocess"},
+		{http.MethodDelete, "/admin/quarantine/507f1f77bcf86cd799439011"},
+		{http.MethodDelete, "/admin/quarantine?before=2026-01-01T00:00:00Z"},
+	}
+	for _, route := range routes {
+		rec := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s = %d, want %d", route.method, route.path, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
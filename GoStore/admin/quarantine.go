@@ -0,0 +1,152 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/kafka"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/problem"
+)
+
+// defaultQuarantineLimit and maxQuarantineLimit bound a page of quarantined messages
+const (
+	defaultQuarantineLimit = 50
+	maxQuarantineLimit     = 500
+)
+
+// QuarantineList is the JSON body served at GET /admin/quarantine
+type QuarantineList struct {
+	Messages []*models.QuarantinedMessage `json:"messages"`
+	Skip     int64                        `json:"skip"`
+	Limit    int64                        `json:"limit"`
+}
+
+// quarantineFilter reads the topic and reason query parameters
+func quarantineFilter(r *http.Request) kafka.QuarantineFilter {
+	return kafka.QuarantineFilter{
+		Topic:  r.URL.Query().Get("topic"),
+		Reason: r.URL.Query().Get("reason"),
+	}
+}
+
+// listQuarantine lists quarantined messages, newest first, optionally of one
+// topic or reason, paged by skip and limit
+func (s *Server) listQuarantine(w http.ResponseWriter, r *http.Request) {
+	skip, limit := int64(0), int64(defaultQuarantineLimit)
+	if v := r.URL.Query().Get("skip"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			problem.Write(w, http.StatusBadRequest, "skip must be a non-negative integer")
+			return
+		}
+		skip = n
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxQuarantineLimit {
+			problem.Write(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxQuarantineLimit))
+			return
+		}
+		limit = n
+	}
+
+	messages, err := s.quarantine.List(r.Context(), quarantineFilter(r), skip, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing quarantined messages", "error", err)
+		problem.Write(w, http.StatusInternalServerError, "Failed to list quarantined messages")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(QuarantineList{Messages: messages, Skip: skip, Limit: limit}); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding quarantined messages", "error", err)
+	}
+}
+
+// getQuarantined returns one quarantined message, with its raw payload
+func (s *Server) getQuarantined(w http.ResponseWriter, r *http.Request) {
+	message, err := s.quarantine.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, kafka.ErrNotQuarantined) {
+		problem.Write(w, http.StatusNotFound, "Quarantined message not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading quarantined message", "error", err)
+		problem.Write(w, http.StatusInternalServerError, "Failed to read quarantined message")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(message); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding quarantined message", "error", err)
+	}
+}
+
+// reprocessQuarantined republishes a quarantined message to its original topic
+// and removes it from quarantine
+func (s *Server) reprocessQuarantined(w http.ResponseWriter, r *http.Request) {
+	message, err := s.quarantine.Reprocess(r.Context(), r.PathValue("id"))
+	if errors.Is(err, kafka.ErrNotQuarantined) {
+		problem.Write(w, http.StatusNotFound, "Quarantined message not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reprocessing quarantined message", "error", err)
+		problem.Write(w, http.StatusBadGateway, "Failed to reprocess quarantined message")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(message); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding quarantined message", "error", err)
+	}
+}
+
+// deleteQuarantined discards one quarantined message
+func (s *Server) deleteQuarantined(w http.ResponseWriter, r *http.Request) {
+	err := s.quarantine.Delete(r.Context(), r.PathValue("id"))
+	if errors.Is(err, kafka.ErrNotQuarantined) {
+		problem.Write(w, http.StatusNotFound, "Quarantined message not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting quarantined message", "error", err)
+		problem.Write(w, http.StatusInternalServerError, "Failed to delete quarantined message")
+		return
+	}
+	slog.InfoContext(r.Context(), "Quarantined message deleted by operator", "id", r.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeQuarantine discards the quarantined messages of the optional topic and
+// reason quarantined before the optional RFC 3339 before parameter
+func (s *Server) purgeQuarantine(w http.ResponseWriter, r *http.Request) {
+	var before time.Time
+	if v := r.URL.Query().Get("before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			problem.Write(w, http.StatusBadRequest, "before must be an RFC 3339 timestamp")
+			return
+		}
+		before = t
+	}
+
+	deleted, err := s.quarantine.Purge(r.Context(), quarantineFilter(r), before)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error purging quarantine", "error", err)
+		problem.Write(w, http.StatusInternalServerError, "Failed to purge quarantine")
+		return
+	}
+	slog.InfoContext(r.Context(), "Quarantine purged by operator", "count", deleted)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int64{"deleted": deleted}); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding purge result", "error", err)
+	}
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/problem"
+)
+
+// defaultQuotaLimit and maxQuotaLimit bound a list of users' storage usage
+const (
+	defaultQuotaLimit = 50
+	maxQuotaLimit     = 1000
+)
+
+// QuotaList is the JSON body served at GET /admin/quota
+type QuotaList struct {
+	Quota          models.UserQuota `json:"quota"`
+	WarningPercent int              `json:"warning_percent"`
+	Users          []UserQuotaUsage `json:"users"`
+}
+
+// UserQuotaUsage is a user's storage usage and the share of the quota it takes
+type UserQuotaUsage struct {
+	*models.UserUsage
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// listQuota lists users' storage usage, largest first, optionally of one
+// tenant or of users using at least min_percent of their quota, up to limit
+func (s *Server) listQuota(w http.ResponseWriter, r *http.Request) {
+	limit := int64(defaultQuotaLimit)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxQuotaLimit {
+			problem.Write(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxQuotaLimit))
+			return
+		}
+		limit = n
+	}
+	minPercent := 0
+	if v := r.URL.Query().Get("min_percent"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			problem.Write(w, http.StatusBadRequest, "min_percent must be a non-negative integer")
+			return
+		}
+		minPercent = n
+	}
+
+	usage, err := s.quotas.List(r.Context(), r.URL.Query().Get("tenant_id"), float64(minPercent)/100, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing user storage usage", "error", err)
+		problem.Write(w, http.StatusInternalServerError, "Failed to list user storage usage")
+		return
+	}
+
+	quota := s.quotas.Quota()
+	list := QuotaList{
+		Quota:          quota,
+		WarningPercent: int(math.Round(s.quotas.WarningLevel() * 100)),
+		Users:          make([]UserQuotaUsage, len(usage)),
+	}
+	for i, u := range usage {
+		list.Users[i] = UserQuotaUsage{UserUsage: u, UsedPercent: math.Round(u.Used(quota)*1000) / 10}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding user storage usage", "error", err)
+	}
+}
@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/problem"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+// requeueMessage republishes a stored message of the tenant in the X-Tenant-ID
+// header to the requeue topic. The admin server does not authenticate, so the
+// audit record names no actor; query parameters, such as the operator and a
+// reason, are kept in it instead
+func (s *Server) requeueMessage(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.Header.Get(tenant.Header)
+	if !tenant.IsValidID(tenantID) {
+		problem.Write(w, http.StatusBadRequest, "A valid "+tenant.Header+" header is required")
+		return
+	}
+	ctx := tenant.WithID(r.Context(), tenantID)
+
+	audit := &models.AuditRecord{
+		AuthMethod: "admin_server",
+		Endpoint:   r.Pattern,
+		RemoteAddr: r.RemoteAddr,
+	}
+	for key, values := range r.URL.Query() {
+		if audit.Filters == nil {
+			audit.Filters = make(map[string]string)
+		}
+		audit.Filters[key] = strings.Join(values, ",")
+	}
+
+	result, err := s.requeue.Requeue(ctx, r.PathValue("id"), audit)
+	switch {
+	case errors.Is(err, services.ErrMessageNotFound):
+		problem.Write(w, http.StatusNotFound, "Message not found")
+		return
+	case errors.Is(err, services.ErrRequeueLimit):
+		problem.Write(w, http.StatusConflict, "Message has already been requeued the maximum number of times")
+		return
+	case errors.Is(err, services.ErrNotRequeueable):
+		problem.Write(w, http.StatusConflict, "Only outbound messages can be requeued")
+		return
+	case err != nil:
+		slog.ErrorContext(ctx, "Error requeuing message", "id", r.PathValue("id"), "error", err)
+		problem.Write(w, http.StatusBadGateway, "Failed to requeue message")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.ErrorContext(ctx, "Error encoding requeue result", "error", err)
+	}
+}
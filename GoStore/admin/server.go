@@ -0,0 +1,304 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/config"
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/kafka"
+	"github.com/ramG-reddy/sms-store/opmode"
+	"github.com/ramG-reddy/sms-store/problem"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// Server exposes profiling, runtime diagnostics and consumer controls on a loopback-only listener
+// It must never be reachable from outside the host, so it binds to 127.0.0.1 only
+type Server struct {
+	httpServer *http.Server
+	consumers  map[string]Consumer
+	stats      StatsFunc
+	quarantine *kafka.Quarantine
+	quotas     *services.QuotaService
+	requeue    *services.RequeueService
+	modes      *opmode.Switch
+	reload     func() error
+}
+
+// StatsFunc gathers the service-wide storage totals served at /admin/stats
+type StatsFunc func(ctx context.Context) (*db.ServiceStats, error)
+
+// Consumer is an ingestion consumer operators can pause, resume and inspect
+type Consumer interface {
+	Pause() bool
+	Resume() bool
+	Paused() bool
+	MaxLag() int64
+}
+
+// groupConsumer is a Consumer in a Kafka consumer group, reporting the group's
+// lag on each partition
+type groupConsumer interface {
+	GroupLag() []kafka.PartitionLag
+}
+
+// ConsumerStatus is the JSON body served by the /admin/consumer endpoints
+type ConsumerStatus struct {
+	Consumers map[string]string `json:"consumers"` // name to "running" or "paused"
+}
+
+// ConsumerLagStatus is the JSON body served at /admin/consumer/status
+type ConsumerLagStatus struct {
+	Consumers map[string]ConsumerLag `json:"consumers"`
+}
+
+// ConsumerLag is the state of one consumer and the group lag of each of its
+// partitions. Consumers of other brokers report their backlog as TotalLag
+type ConsumerLag struct {
+	State      string               `json:"state"` // "running" or "paused"
+	TotalLag   int64                `json:"total_lag"`
+	Partitions []kafka.PartitionLag `json:"partitions"`
+}
+
+// GCStats is the JSON body served at /debug/gc
+type GCStats struct {
+	NumGC         int64           `json:"num_gc"`
+	LastGC        time.Time       `json:"last_gc"`
+	PauseTotal    time.Duration   `json:"pause_total_ns"`
+	RecentPauses  []time.Duration `json:"recent_pauses_ns"`
+	HeapAlloc     uint64          `json:"heap_alloc_bytes"`
+	HeapSys       uint64          `json:"heap_sys_bytes"`
+	HeapObjects   uint64          `json:"heap_objects"`
+	NextGC        uint64          `json:"next_gc_bytes"`
+	NumGoroutines int             `json:"num_goroutines"`
+}
+
+// NewServer creates a new admin server instance controlling the given consumers, by name
+// A nil stats leaves /admin/stats unregistered, a nil quarantine /admin/quarantine,
+// a nil quotas /admin/quota, a nil requeue /admin/messages/{id}/requeue, and
+// a nil modes /admin/mode.
+// reload re-applies the reloadable settings of the configuration for /admin/reload
+func NewServer(consumers map[string]Consumer, stats StatsFunc, quarantine *kafka.Quarantine, quotas *services.QuotaService, requeue *services.RequeueService, modes *opmode.Switch, reload func() error) *Server {
+	s := &Server{consumers: consumers, stats: stats, quarantine: quarantine, quotas: quotas, requeue: requeue, modes: modes, reload: reload}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("GET /debug/gc", gcStats)
+	mux.HandleFunc("GET /debug/goroutines", goroutineDump)
+	mux.HandleFunc("GET /admin/consumer", s.consumerStatus)
+	mux.HandleFunc("GET /admin/consumer/status", s.consumerLagStatus)
+	mux.HandleFunc("POST /admin/consumer/pause", s.pauseConsumers)
+	mux.HandleFunc("POST /admin/consumer/resume", s.resumeConsumers)
+	if stats != nil {
+		mux.HandleFunc("GET /admin/stats", s.serviceStats)
+	}
+	if quarantine != nil {
+		mux.HandleFunc("GET /admin/quarantine", s.listQuarantine)
+		mux.HandleFunc("GET /admin/quarantine/{id}", s.getQuarantined)
+		// Purging deletes quarantined messages, and reprocessing republishes them for ingestion
+		mux.HandleFunc("DELETE /admin/quarantine", s.servingWrites(s.purgeQuarantine))
+		mux.HandleFunc("DELETE /admin/quarantine/{id}", s.servingWrites(s.deleteQuarantined))
+		mux.HandleFunc("POST /admin/quarantine/{id}/reprocess", s.servingWrites(s.reprocessQuarantined))
+	}
+	if quotas != nil {
+		mux.HandleFunc("GET /admin/quota", s.listQuota)
+	}
+	if requeue != nil {
+		// Requeuing counts the attempt on the stored message
+		mux.HandleFunc("POST /admin/messages/{id}/requeue", s.servingWrites(s.requeueMessage))
+	}
+	if modes != nil {
+		mux.HandleFunc("GET /admin/mode", s.modeStatus)
+		mux.HandleFunc("PUT /admin/mode", s.setMode)
+	}
+	mux.HandleFunc("POST /admin/reload", s.reloadConfig)
+
+	s.httpServer = &http.Server{
+		Handler:     mux,
+		ReadTimeout: 15 * time.Second,
+		// No write timeout: CPU profiles and traces stream for as long as requested
+		IdleTimeout: 60 * time.Second,
+	}
+	return s
+}
+
+// Address returns the loopback address the admin server listens on, so it is
+// only reachable from the host
+func Address(port string) string {
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// Serve begins serving admin requests on listener, which should be on
+// Address, in a background goroutine
+func (s *Server) Serve(listener net.Listener) {
+	go func() {
+		slog.Info("Admin server listening", "address", listener.Addr().String())
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Admin server stopped with error", "error", err)
+		}
+	}()
+}
+
+// Stop shuts down the admin server, abandoning in-flight profiles when ctx expires
+func (s *Server) Stop(ctx context.Context) {
+	slog.Info("Stopping admin server")
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		s.httpServer.Close()
+		slog.Warn("Admin server forced to stop", "error", err)
+	}
+}
+
+// gcStats reports garbage collector and heap statistics
+func gcStats(w http.ResponseWriter, r *http.Request) {
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := GCStats{
+		NumGC:         gc.NumGC,
+		LastGC:        gc.LastGC,
+		PauseTotal:    gc.PauseTotal,
+		RecentPauses:  gc.Pause,
+		HeapAlloc:     mem.HeapAlloc,
+		HeapSys:       mem.HeapSys,
+		HeapObjects:   mem.HeapObjects,
+		NextGC:        mem.NextGC,
+		NumGoroutines: runtime.NumGoroutine(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding GC stats", "error", err)
+	}
+}
+
+// pauseConsumers pauses every consumer, e.g. for MongoDB maintenance
+func (s *Server) pauseConsumers(w http.ResponseWriter, r *http.Request) {
+	for name, consumer := range s.consumers {
+		if consumer.Pause() {
+			slog.InfoContext(r.Context(), "Consumer paused by operator", "consumer", name)
+		}
+	}
+	s.consumerStatus(w, r)
+}
+
+// resumeConsumers resumes every paused consumer, unless the service mode pauses
+// ingestion
+func (s *Server) resumeConsumers(w http.ResponseWriter, r *http.Request) {
+	if s.modes != nil {
+		if mode := s.modes.Mode(); !mode.Writes() {
+			problem.Write(w, http.StatusConflict, "Ingestion is paused while the service is "+string(mode)+"; switch the mode to resume it")
+			return
+		}
+	}
+	for name, consumer := range s.consumers {
+		if consumer.Resume() {
+			slog.InfoContext(r.Context(), "Consumer resumed by operator", "consumer", name)
+		}
+	}
+	s.consumerStatus(w, r)
+}
+
+// consumerStatus reports whether each consumer is running or paused
+func (s *Server) consumerStatus(w http.ResponseWriter, r *http.Request) {
+	status := ConsumerStatus{Consumers: make(map[string]string, len(s.consumers))}
+	for name, consumer := range s.consumers {
+		status.Consumers[name] = "running"
+		if consumer.Paused() {
+			status.Consumers[name] = "paused"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding consumer status", "error", err)
+	}
+}
+
+// consumerLagStatus reports each consumer's state and its group lag per partition
+// from the last periodic check, or its backlog outside Kafka
+func (s *Server) consumerLagStatus(w http.ResponseWriter, r *http.Request) {
+	status := ConsumerLagStatus{Consumers: make(map[string]ConsumerLag, len(s.consumers))}
+	for name, consumer := range s.consumers {
+		lag := ConsumerLag{State: "running", Partitions: []kafka.PartitionLag{}}
+		if consumer.Paused() {
+			lag.State = "paused"
+		}
+		if group, ok := consumer.(groupConsumer); ok {
+			if partitions := group.GroupLag(); partitions != nil {
+				lag.Partitions = partitions
+			}
+			for _, p := range lag.Partitions {
+				lag.TotalLag += p.Lag
+			}
+		} else {
+			lag.TotalLag = consumer.MaxLag()
+		}
+		status.Consumers[name] = lag
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding consumer lag status", "error", err)
+	}
+}
+
+// serviceStats reports storage totals, recent ingest rates and top senders
+func (s *Server) serviceStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	stats, err := s.stats(ctx)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error gathering service stats", "error", err)
+		problem.Write(w, http.StatusInternalServerError, "Failed to gather service stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding service stats", "error", err)
+	}
+}
+
+// reloadConfig reloads the configuration, answering 400 with every problem if it is invalid
+func (s *Server) reloadConfig(w http.ResponseWriter, r *http.Request) {
+	slog.InfoContext(r.Context(), "Reloading configuration on operator request")
+	if err := s.reload(); err != nil {
+		slog.ErrorContext(r.Context(), "Configuration reload failed", "error", err)
+		status := http.StatusInternalServerError
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			status = http.StatusBadRequest
+		}
+		problem.Write(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "reloaded"}); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding reload status", "error", err)
+	}
+}
+
+// goroutineDump writes the stack of every goroutine in panic-trace format
+func goroutineDump(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := rpprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		slog.ErrorContext(r.Context(), "Error writing goroutine dump", "error", err)
+	}
+}
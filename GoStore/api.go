@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/cache"
+	"github.com/ramG-reddy/sms-store/gql"
+	"github.com/ramG-reddy/sms-store/handlers"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/openapi"
+	"github.com/ramG-reddy/sms-store/opmode"
+	"github.com/ramG-reddy/sms-store/problem"
+	"github.com/ramG-reddy/sms-store/redact"
+	"github.com/ramG-reddy/sms-store/router"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// apiServices are the services behind the HTTP API besides the application's
+// SMS service. Optional ones are nil when disabled, which leaves out their routes
+type apiServices struct {
+	audit     *services.AuditService
+	webhooks  *services.WebhookService
+	apiKeys   *services.APIKeyService
+	usage     *services.UsageService           // optional
+	retention *services.RetentionPolicyService // optional
+	senders   *services.SenderService          // optional
+	blocklist *services.BlocklistService       // optional
+	responses *cache.Responses                 // optional
+	archiving bool                             // whether retention policies can archive
+	search    bool                             // whether messages can be searched
+}
+
+// api holds the handlers of the HTTP API; optional ones are nil when disabled
+type api struct {
+	sms       *handlers.SMSHandler
+	webhooks  *handlers.WebhookHandler
+	apiKeys   *handlers.APIKeyHandler
+	audit     *handlers.AuditHandler
+	retention *handlers.RetentionHandler // optional
+	senders   *handlers.SenderHandler    // optional
+	blocklist *handlers.BlocklistHandler // optional
+	ingest    *handlers.IngestHandler
+	twilio    *handlers.TwilioHandler // optional
+	graphql   *gql.Handler
+
+	usage, search, swaggerUI bool
+}
+
+// newAPI creates the handlers of the HTTP API over the application's SMS
+// service and svc, with their settings from the configuration
+func (a *application) newAPI(svc apiServices) (*api, error) {
+	h := &api{
+		sms:       handlers.NewSMSHandler(a.smsService, svc.audit),
+		webhooks:  handlers.NewWebhookHandler(svc.webhooks, svc.audit),
+		apiKeys:   handlers.NewAPIKeyHandler(svc.apiKeys, svc.usage, svc.audit),
+		audit:     handlers.NewAuditHandler(svc.audit),
+		usage:     svc.usage != nil,
+		search:    svc.search,
+		swaggerUI: a.cfg.SwaggerUIEnabled,
+	}
+	h.sms.SetMaxSearchLimit(a.cfg.SearchMaxLimit)
+	if svc.responses != nil {
+		h.sms.EnableResponseCache(svc.responses)
+	}
+	if svc.retention != nil {
+		h.retention = handlers.NewRetentionHandler(svc.retention, svc.audit, svc.archiving)
+	}
+	if svc.senders != nil {
+		h.senders = handlers.NewSenderHandler(svc.senders, svc.audit)
+	}
+	if svc.blocklist != nil {
+		h.blocklist = handlers.NewBlocklistHandler(svc.blocklist, svc.audit)
+	}
+
+	var err error
+	if h.ingest, err = handlers.NewIngestHandler(a.smsService); err != nil {
+		return nil, fmt.Errorf("failed to initialize message ingestion handler: %w", err)
+	}
+	if a.cfg.TwilioAuthToken != "" {
+		h.twilio, err = handlers.NewTwilioHandler(handlers.TwilioConfig{
+			AuthToken:  a.cfg.TwilioAuthToken,
+			WebhookURL: a.cfg.TwilioWebhookURL,
+			TenantID:   a.cfg.TwilioTenantID,
+		}, a.smsService)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Twilio webhook handler: %w", err)
+		}
+	}
+	if h.graphql, err = gql.NewHandler(a.smsService, svc.audit); err != nil {
+		return nil, fmt.Errorf("failed to initialize GraphQL handler: %w", err)
+	}
+	h.graphql.SetMaxPageSize(a.cfg.GraphQLMaxPageSize)
+	return h, nil
+}
+
+// routes returns the handler of every public route, authenticated by
+// authMiddleware, with reads and writes refused as modes says and problem
+// details masked by redactor. Routes live on their own mux so nothing
+// registered on http.DefaultServeMux (such as net/http/pprof) is exposed
+func (h *api) routes(authMiddleware *handlers.Auth, modes *opmode.Switch, health *handlers.HealthHandler, redactor *redact.Redactor) http.Handler {
+	routes := router.New()
+
+	// Read and management endpoints require an API key with the given scope,
+	// and are scoped to the key's tenant. Both API versions serve them: /v0 with
+	// bare bodies, as it always has, and /v1 with response envelopes. Reads are
+	// refused in the write_only mode, and every route that writes in read_only
+	readScope, servingReads := authMiddleware.Scope(models.ScopeRead), handlers.ServingReads(modes)
+	read := func(h http.Handler) http.Handler { return readScope(servingReads(h)) }
+	writing := handlers.ServingWrites(modes)
+	registerAPI := func(api *router.Router) {
+		api.HandleFunc("DELETE /user/{user_id}/messages", h.sms.DeleteUserMessages, authMiddleware.Scope(models.ScopeDelete), writing)
+		api.HandleFunc("GET /user/{user_id}/messages/stream", h.sms.StreamUserMessages, read)
+		api.HandleFunc("GET /user/{user_id}/messages/export", h.sms.ExportUserMessages, read)
+		api.HandleFunc("GET /user/{user_id}/messages/count", h.sms.CountUserMessages, read)
+		api.HandleFunc("GET /user/{user_id}/stats", h.sms.GetUserStats, read)
+		api.HandleFunc("GET /user/{user_id}/conversations", h.sms.GetUserConversations, read)
+		api.HandleFunc("GET /user/{user_id}/conversations/{peer}/messages", h.sms.GetConversationMessages, read)
+		api.HandleFunc("GET /phone/{phone_number}/messages", h.sms.GetPhoneMessages, read)
+		api.HandleFunc("POST /messages/query", h.sms.QueryMessages, read)
+		api.HandleFunc("GET /analytics/delivery", h.sms.GetDeliveryStats, read)
+		api.HandleFunc("POST /messages", h.ingest.CreateMessage, authMiddleware.Scope(models.ScopeWrite), writing)
+		api.HandleFunc("POST /messages/batch", h.ingest.CreateMessages, authMiddleware.Scope(models.ScopeWrite), writing)
+		api.HandleFunc("GET /messages/{id}/media/{n}", h.sms.GetMessageMedia, read)
+		if h.search {
+			api.HandleFunc("GET /user/{user_id}/messages/search", h.sms.SearchUserMessages, read)
+		}
+		api.HandleFunc("POST /receipts", h.sms.ReceiveDeliveryReceipt, authMiddleware.Scope(models.ScopeWrite), writing)
+		if h.senders != nil {
+			api.HandleFunc("GET /senders", h.senders.ListSenders, read)
+			api.HandleFunc("GET /senders/{id}", h.senders.GetSender, read)
+		}
+
+		adminAPI := api.Group("", authMiddleware.Scope(models.ScopeAdmin))
+		adminAPI.HandleFunc("POST /webhooks", h.webhooks.RegisterWebhook, writing)
+		adminAPI.HandleFunc("GET /webhooks", h.webhooks.ListWebhooks)
+		adminAPI.HandleFunc("GET /webhooks/{id}", h.webhooks.GetWebhook)
+		adminAPI.HandleFunc("PUT /webhooks/{id}", h.webhooks.UpdateWebhook, writing)
+		adminAPI.HandleFunc("POST /webhooks/{id}/secret", h.webhooks.RotateWebhookSecret, writing)
+		adminAPI.HandleFunc("GET /webhooks/{id}/deliveries", h.webhooks.GetWebhookDeliveries)
+		adminAPI.HandleFunc("DELETE /webhooks/{id}", h.webhooks.DeleteWebhook, writing)
+		adminAPI.HandleFunc("POST /api-keys", h.apiKeys.CreateAPIKey, writing)
+		// Exempt from read_only: a leaked key must be revocable during any incident
+		adminAPI.HandleFunc("DELETE /api-keys/{id}", h.apiKeys.RevokeAPIKey)
+		if h.usage {
+			adminAPI.HandleFunc("GET /api-keys/{id}/usage", h.apiKeys.GetAPIKeyUsage)
+			adminAPI.HandleFunc("DELETE /api-keys/{id}/usage", h.apiKeys.ResetAPIKeyUsage, writing)
+		}
+		adminAPI.HandleFunc("GET /duplicates", h.sms.GetDuplicateReport)
+		adminAPI.HandleFunc("GET /audit", h.audit.GetAuditLog)
+		if h.retention != nil {
+			adminAPI.HandleFunc("GET /retention-policy", h.retention.GetRetentionPolicy)
+			adminAPI.HandleFunc("PUT /retention-policy", h.retention.SetRetentionPolicy, writing)
+			adminAPI.HandleFunc("DELETE /retention-policy", h.retention.DeleteRetentionPolicy, writing)
+		}
+		if h.senders != nil {
+			adminAPI.HandleFunc("POST /senders", h.senders.RegisterSender, writing)
+			adminAPI.HandleFunc("PUT /senders/{id}", h.senders.UpdateSender, writing)
+			adminAPI.HandleFunc("DELETE /senders/{id}", h.senders.DeleteSender, writing)
+		}
+		if h.blocklist != nil {
+			adminAPI.HandleFunc("POST /blocklist", h.blocklist.AddBlockEntry, writing)
+			adminAPI.HandleFunc("GET /blocklist", h.blocklist.ListBlockEntries)
+			adminAPI.HandleFunc("GET /blocklist/{id}", h.blocklist.GetBlockEntry)
+			adminAPI.HandleFunc("PUT /blocklist/{id}", h.blocklist.UpdateBlockEntry, writing)
+			adminAPI.HandleFunc("DELETE /blocklist/{id}", h.blocklist.DeleteBlockEntry, writing)
+		}
+	}
+	v0 := routes.Group("/v0")
+	// /v0 has always served the message list for any method under /v0/user/
+	v0.HandleFunc("/user/", h.sms.GetUserMessages, read)
+	registerAPI(v0)
+	// Twilio signs its webhook requests instead of sending an API key
+	if h.twilio != nil {
+		v0.HandleFunc("POST /twilio/messages", h.twilio.ReceiveMessage, handlers.Unauthenticated, writing)
+	}
+	v1 := routes.Group("/v1", handlers.Envelope)
+	v1.HandleFunc("GET /user/{user_id}/messages", h.sms.GetUserMessages, read)
+	registerAPI(v1)
+
+	routes.HandleFunc("GET /healthz", health.Liveness)
+	routes.HandleFunc("GET /readyz", health.Readiness)
+	routes.Handle("GET /metrics", metrics.Handler())
+
+	// OpenAPI description of the routes above, for generating client SDKs
+	apiDoc := openapi.New("SMS Store API", "v0", problem.Problem{}, problem.MediaType)
+	handlers.DescribeAPI(apiDoc, h.search, h.usage, h.retention != nil, h.senders != nil, h.blocklist != nil)
+	routes.Handle("GET /openapi.json", apiDoc, handlers.Unauthenticated)
+	if h.swaggerUI {
+		routes.HandleFunc("GET /docs", openapi.SwaggerUI, handlers.Unauthenticated)
+	}
+
+	routes.HandleFunc("POST /graphql", h.graphql.ServeHTTP, read)
+	routes.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "SMS Store Service - Use /v0/user/{user_id}/messages to retrieve messages")
+	}, handlers.Unauthenticated)
+	return problem.Redact(redactor, routes)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ramG-reddy/sms-store/config"
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/lease"
+	"github.com/ramG-reddy/sms-store/phonenumber"
+	"github.com/ramG-reddy/sms-store/pseudonym"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// application is what every command handling messages builds the same way: the
+// message storage, the SMS service writing records to it as configured, and the
+// leases keeping scheduled jobs to one instance. It starts the consumers of SMS
+// events and builds the HTTP API over them too. Commands wire the rest of what
+// they run from these, handing each component the ones it uses
+type application struct {
+	cfg        *config.Config
+	mongo      *db.Mongo
+	leases     *lease.Leases
+	storage    *storage
+	broker     *events.Broker
+	smsService *services.SMSService
+	pseudonyms *pseudonym.Hasher       // nil when pseudonymization is disabled
+	numbers    *phonenumber.Normalizer // nil when phone number normalization is disabled
+}
+
+// newApplication opens the storage backend selected by cfg and builds the SMS
+// service on it. m is the service's own database, which also holds the
+// messages with the mongo backend
+func newApplication(cfg *config.Config, m *db.Mongo) (*application, error) {
+	messageStorage, err := openStorage(cfg, m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize message storage: %w", err)
+	}
+
+	broker := events.NewBroker()
+	smsService := services.NewSMSService(messageStorage.store, broker)
+	pseudonyms, numbers, err := configureRecords(cfg, m, smsService)
+	if err != nil {
+		messageStorage.Close()
+		return nil, fmt.Errorf("failed to configure message storage: %w", err)
+	}
+
+	return &application{
+		cfg:        cfg,
+		mongo:      m,
+		leases:     lease.New(m),
+		storage:    messageStorage,
+		broker:     broker,
+		smsService: smsService,
+		pseudonyms: pseudonyms,
+		numbers:    numbers,
+	}, nil
+}
+
+// Close disconnects from the storage backend. MongoDB is left connected, for
+// whoever connected it to close
+func (a *application) Close() {
+	a.storage.Close()
+}
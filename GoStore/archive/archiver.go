@@ -0,0 +1,215 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ramG-reddy/sms-store/lease"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Config controls what is archived, where, and how often
+type Config struct {
+	Interval  time.Duration
+	BatchSize int64
+	MaxAge    time.Duration
+	Bucket    string
+	Prefix    string
+	Endpoint  string // Optional S3-compatible endpoint (e.g. MinIO)
+}
+
+// objectPutter is the subset of the S3 client used by the archiver
+type objectPutter interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// Archiver periodically moves old messages from MongoDB to S3 as gzipped NDJSON
+type Archiver struct {
+	cfg        Config
+	maxAge     atomic.Int64 // time.Duration; cfg.MaxAge until changed by SetMaxAge
+	leases     *lease.Leases
+	smsService *services.SMSService
+	s3         objectPutter
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewArchiver creates an archiver using the default AWS credential chain
+func NewArchiver(ctx context.Context, cfg Config, leases *lease.Leases, smsService *services.SMSService) (*Archiver, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	a := &Archiver{
+		cfg:        cfg,
+		leases:     leases,
+		smsService: smsService,
+		s3:         client,
+		stopChan:   make(chan struct{}),
+	}
+	a.maxAge.Store(int64(cfg.MaxAge))
+	return a, nil
+}
+
+// SetMaxAge changes the age past which messages are archived, from the next run on
+func (a *Archiver) SetMaxAge(maxAge time.Duration) {
+	a.maxAge.Store(int64(maxAge))
+}
+
+// Start runs the archival job on the configured interval in a background goroutine
+func (a *Archiver) Start() {
+	slog.Info("Starting archiver",
+		"interval", a.cfg.Interval, "max_age", a.cfg.MaxAge, "bucket", a.cfg.Bucket, "prefix", a.cfg.Prefix)
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(a.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.stopChan:
+				return
+			case <-ticker.C:
+				err := a.RunOnce(context.Background())
+				if errors.Is(err, lease.ErrHeld) {
+					slog.Debug("Archival run skipped; another instance ran it this period")
+				} else if err != nil {
+					slog.Error("Archival run failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop waits for an in-progress run to finish and stops the schedule
+func (a *Archiver) Stop() {
+	slog.Info("Stopping archiver")
+	close(a.stopChan)
+	a.wg.Wait()
+	slog.Info("Archiver stopped")
+}
+
+// RunOnce archives batches of old messages until none remain
+// Messages are only deleted after their batch has been uploaded successfully.
+// Only one instance archives each interval; the others get lease.ErrHeld
+func (a *Archiver) RunOnce(ctx context.Context) error {
+	return a.leases.Run(ctx, "archive", a.cfg.Interval, a.run)
+}
+
+// run archives batches of old messages until none remain
+func (a *Archiver) run(ctx context.Context) error {
+	cutoff := time.Now().UTC().Add(-time.Duration(a.maxAge.Load()))
+	total := int64(0)
+
+	for {
+		select {
+		case <-a.stopChan:
+			slog.Info("Archival interrupted by shutdown", "archived", total)
+			return nil
+		default:
+		}
+
+		records, err := a.smsService.FindMessagesOlderThan(ctx, cutoff, models.TenantFilter{}, a.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			break
+		}
+
+		deleted, err := a.Archive(ctx, records)
+		if err != nil {
+			return err
+		}
+		total += deleted
+
+		if int64(len(records)) < a.cfg.BatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		slog.Info("Archival run complete", "archived", total)
+	}
+	return nil
+}
+
+// Archive uploads records as one object and then deletes them, returning the
+// number deleted. Records are kept if the upload fails
+func (a *Archiver) Archive(ctx context.Context, records []*models.SMSRecord) (int64, error) {
+	key, err := a.upload(ctx, records)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]primitive.ObjectID, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+	deleted, err := a.smsService.DeleteMessagesByIDs(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("uploaded %s but failed to delete archived messages: %w", key, err)
+	}
+
+	slog.Info("Archived messages", "count", deleted, "bucket", a.cfg.Bucket, "key", key)
+	return deleted, nil
+}
+
+// upload writes a batch as a gzipped NDJSON object and returns its key
+func (a *Archiver) upload(ctx context.Context, records []*models.SMSRecord) (string, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return "", fmt.Errorf("failed to encode archive batch: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress archive batch: %w", err)
+	}
+
+	first, last := records[0], records[len(records)-1]
+	key := path.Join(a.cfg.Prefix, first.CreatedAt.Format("2006/01/02"),
+		fmt.Sprintf("%s-%s.ndjson.gz", first.ID.Hex(), last.ID.Hex()))
+
+	uploadCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	_, err := a.s3.PutObject(uploadCtx, &s3.PutObjectInput{
+		Bucket:          aws.String(a.cfg.Bucket),
+		Key:             aws.String(key),
+		Body:            bytes.NewReader(buf.Bytes()),
+		ContentType:     aws.String("application/x-ndjson"),
+		ContentEncoding: aws.String("gzip"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload archive batch to S3: %w", err)
+	}
+	return key, nil
+}
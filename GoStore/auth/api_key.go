@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// APIKeyHeader is the header carrying the caller's API key
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator authenticates requests by the X-API-Key header
+type APIKeyAuthenticator struct {
+	apiKeyService *services.APIKeyService
+}
+
+// NewAPIKeyAuthenticator creates an authenticator backed by stored API keys
+func NewAPIKeyAuthenticator(apiKeyService *services.APIKeyService) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{apiKeyService: apiKeyService}
+}
+
+// Authenticate implements Authenticator
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	rawKey := r.Header.Get(APIKeyHeader)
+	if rawKey == "" {
+		return nil, ErrNoCredentials
+	}
+
+	key, err := a.apiKeyService.Authenticate(r.Context(), rawKey)
+	if errors.Is(err, services.ErrInvalidAPIKey) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		Subject:  key.Prefix,
+		Method:   "api_key",
+		TenantID: key.TenantID,
+		Scopes:   key.Scopes,
+
+		KeyID:      key.ID.Hex(),
+		DailyQuota: key.DailyQuota,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request carries none of
+	// the credentials it understands, so the next authenticator can be tried
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when credentials are present but rejected
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	Subject  string
+	Method   string // Authenticator that produced the principal, e.g. "api_key" or "jwt"
+	TenantID string
+	Scopes   []string
+
+	// KeyID and DailyQuota are set for API keys only, whose usage is metered
+	KeyID      string
+	DailyQuota int64
+}
+
+// HasScope reports whether the principal was granted scope; admin implies every scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == models.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator verifies one kind of credential on an HTTP request
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated principal, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok
+}
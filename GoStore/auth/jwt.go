@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Roles granted by identity provider tokens and the scopes they map to
+const (
+	RoleRead  = "sms:read"
+	RoleWrite = "sms:write"
+	RoleAdmin = "sms:admin"
+)
+
+var roleScopes = map[string]string{
+	RoleRead:  models.ScopeRead,
+	RoleWrite: models.ScopeWrite,
+	RoleAdmin: models.ScopeAdmin,
+}
+
+// JWTConfig configures bearer token validation
+type JWTConfig struct {
+	Issuer      string
+	Audience    string
+	JWKSURL     string // Optional; discovered from the issuer when empty
+	RolesClaim  string // Dotted path to the roles claim, e.g. "realm_access.roles"
+	TenantClaim string
+}
+
+// JWTAuthenticator authenticates requests by an OIDC-issued JWT bearer token
+type JWTAuthenticator struct {
+	cfg      JWTConfig
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewJWTAuthenticator creates a JWT authenticator. Signing keys are fetched from the
+// issuer's JWKS endpoint and refreshed automatically when an unknown key ID is seen
+func NewJWTAuthenticator(ctx context.Context, cfg JWTConfig) (*JWTAuthenticator, error) {
+	oidcConfig := &oidc.Config{ClientID: cfg.Audience}
+
+	var verifier *oidc.IDTokenVerifier
+	if cfg.JWKSURL != "" {
+		verifier = oidc.NewVerifier(cfg.Issuer, oidc.NewRemoteKeySet(ctx, cfg.JWKSURL), oidcConfig)
+	} else {
+		provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover OIDC provider %s: %w", cfg.Issuer, err)
+		}
+		verifier = provider.Verifier(oidcConfig)
+	}
+
+	return &JWTAuthenticator{cfg: cfg, verifier: verifier}, nil
+}
+
+// Authenticate implements Authenticator
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	rawToken, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || rawToken == "" {
+		return nil, ErrNoCredentials
+	}
+
+	token, err := a.verifier.Verify(r.Context(), rawToken)
+	if err != nil {
+		slog.InfoContext(r.Context(), "Rejected bearer token", "error", err)
+		return nil, ErrInvalidCredentials
+	}
+
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	tenantID, _ := lookupClaim(claims, a.cfg.TenantClaim).(string)
+	if tenantID == "" {
+		slog.InfoContext(r.Context(), "Rejected bearer token without tenant claim", "subject", token.Subject, "claim", a.cfg.TenantClaim)
+		return nil, ErrInvalidCredentials
+	}
+
+	var scopes []string
+	for _, role := range claimStrings(lookupClaim(claims, a.cfg.RolesClaim)) {
+		if scope, ok := roleScopes[role]; ok {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &Principal{
+		Subject:  token.Subject,
+		Method:   "jwt",
+		TenantID: tenantID,
+		Scopes:   scopes,
+	}, nil
+}
+
+// lookupClaim resolves a dotted claim path such as "realm_access.roles"
+func lookupClaim(claims map[string]interface{}, path string) interface{} {
+	var current interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+// claimStrings accepts either a JSON array of strings or a space-separated string
+// (as in the standard "scope" claim)
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/backfill"
+	"github.com/ramG-reddy/sms-store/logging"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/search"
+	"github.com/spf13/cobra"
+)
+
+// newBackfillCommand returns the backfill command, which imports a CSV or
+// NDJSON dump of historical messages into the store. It can run while the
+// service is up, and resumes from its checkpoint when run again after an
+// interruption
+func newBackfillCommand(app *cli) *cobra.Command {
+	var cfg backfill.Config
+	cmd := &cobra.Command{
+		Use:   "backfill --input file",
+		Short: "Import a CSV or NDJSON dump of historical messages",
+		Args:  cobra.NoArgs,
+		Run: func(*cobra.Command, []string) {
+			if cfg.Format == "" {
+				format, err := backfill.FormatOf(cfg.Input)
+				if err != nil {
+					logging.Fatal("Unknown dump format", "error", err)
+				}
+				cfg.Format = format
+			}
+			if cfg.Format != backfill.FormatCSV && cfg.Format != backfill.FormatNDJSON {
+				logging.Fatal("Invalid --format; use csv or ndjson", "format", cfg.Format)
+			}
+			if cfg.BatchSize <= 0 {
+				logging.Fatal("Invalid --batch-size; it must be positive", "batch_size", cfg.BatchSize)
+			}
+			if cfg.Direction != models.DirectionInbound && cfg.Direction != models.DirectionOutbound {
+				logging.Fatal("Invalid --direction; use inbound or outbound", "direction", cfg.Direction)
+			}
+			if cfg.CheckpointPath == "" {
+				cfg.CheckpointPath = cfg.Input + ".checkpoint"
+			}
+			if cfg.DefaultTenantID == "" {
+				cfg.DefaultTenantID = app.cfg.DefaultTenantID
+			}
+			app.backfill(cfg)
+		},
+	}
+	cmd.Flags().StringVar(&cfg.Input, "input", "", "CSV or NDJSON dump to import, optionally gzip-compressed (.gz)")
+	cmd.Flags().StringVar(&cfg.Format, "format", "", "csv or ndjson; inferred from the file extension when empty")
+	cmd.Flags().IntVar(&cfg.BatchSize, "batch-size", 500, "messages stored per write")
+	cmd.Flags().StringVar(&cfg.CheckpointPath, "checkpoint", "", "file recording progress, to resume from; defaults to the input path with .checkpoint appended")
+	cmd.Flags().StringVar(&cfg.RejectsPath, "rejects", "", "NDJSON file rejected messages are appended to; when empty they are only logged")
+	cmd.Flags().StringVar(&cfg.DefaultTenantID, "tenant", "", "tenant of messages without a tenantId; defaults to DEFAULT_TENANT_ID")
+	cmd.Flags().StringVar(&cfg.Direction, "direction", models.DirectionOutbound, "direction of messages that don't name one: inbound or outbound")
+	cmd.Flags().DurationVar(&cfg.ProgressInterval, "progress", 10*time.Second, "interval between progress reports")
+	cmd.MarkFlagRequired("input")
+	return cmd
+}
+
+// backfill runs the import configured by importCfg
+func (app *cli) backfill(importCfg backfill.Config) {
+	cfg := app.cfg
+	database := app.connectMongo()
+	defer database.Close()
+
+	// Records are written as the service writes them; nothing subscribes to the
+	// broker, so no webhooks or stored events are sent for imported messages
+	core, err := newApplication(cfg, database)
+	if err != nil {
+		logging.Fatal("Failed to initialize message storage", "error", err)
+	}
+	defer core.Close()
+	smsService := core.smsService
+	if cfg.SearchURL != "" {
+		index := search.NewIndex(search.Config{
+			URL:      cfg.SearchURL,
+			Index:    cfg.SearchIndex,
+			Username: cfg.SearchUsername,
+			Password: cfg.SearchPassword,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := index.EnsureIndex(ctx)
+		cancel()
+		if err != nil {
+			logging.Fatal("Failed to reach the search index; imported messages would be missing from search", "error", err)
+		}
+		smsService.EnableSearch(index)
+	}
+
+	// An interrupt stops the import after the batch being stored
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	importer := backfill.NewImporter(importCfg, smsService)
+	if _, err := importer.Run(ctx); err != nil {
+		if ctx.Err() != nil {
+			slog.Info("Backfill interrupted; run the command again to resume", "checkpoint", importCfg.CheckpointPath)
+			return
+		}
+		logging.Fatal("Backfill failed; run the command again to resume", "checkpoint", importCfg.CheckpointPath, "error", err)
+	}
+}
@@ -0,0 +1,67 @@
+package backfill
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint records how far an import got, so an interrupted one can resume.
+// It is written after every batch is stored
+type Checkpoint struct {
+	Input      string    `json:"input"`            // absolute path of the dump
+	Offset     int64     `json:"offset"`           // uncompressed bytes of the dump imported
+	Header     []string  `json:"header,omitempty"` // of a CSV dump, which is read only once
+	Records    int64     `json:"records"`          // messages read
+	Imported   int64     `json:"imported"`
+	Duplicates int64     `json:"duplicates"` // skipped, being already stored
+	Rejected   int64     `json:"rejected"`
+	Done       bool      `json:"done"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// loadCheckpoint reads the checkpoint at path, or returns nil if there is none
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %s: %w", path, err)
+	}
+	return checkpoint, nil
+}
+
+// save writes the checkpoint to path, replacing the previous one only once it
+// is complete so a crash never leaves a truncated checkpoint
+func (c *Checkpoint) save(path string) error {
+	c.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
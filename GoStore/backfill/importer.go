@@ -0,0 +1,251 @@
+// Package backfill imports historical messages from a CSV or NDJSON dump, e.g.
+// one exported from a legacy system, through the same pipeline of validation,
+// deduplication and storage as the message broker consumers
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+const (
+	// source names the dump as where events came from
+	source = "backfill"
+
+	// maxAttempts is how many times a batch is stored before the import stops
+	maxAttempts = 5
+
+	// retryBackoff is the wait before the first retry of a batch, doubled for each further one
+	retryBackoff = time.Second
+)
+
+// Config configures an import
+type Config struct {
+	Input            string
+	Format           string // FormatCSV or FormatNDJSON
+	BatchSize        int
+	CheckpointPath   string
+	RejectsPath      string // NDJSON file rejected messages are appended to; empty only logs them
+	ProgressInterval time.Duration
+	DefaultTenantID  string // assigned to messages without a tenantId
+	Direction        string // of messages that don't name one
+}
+
+// Importer imports a dump into the message store
+type Importer struct {
+	cfg        Config
+	smsService *services.SMSService
+}
+
+// NewImporter creates an importer of the dump configured by cfg
+func NewImporter(cfg Config, smsService *services.SMSService) *Importer {
+	return &Importer{cfg: cfg, smsService: smsService}
+}
+
+// rejection is a message that was not imported, as written to the rejects file
+type rejection struct {
+	Record  int64  `json:"record"` // position of the message in the dump, from 1
+	Reason  string `json:"reason"`
+	Error   string `json:"error"`
+	Payload string `json:"payload,omitempty"`
+}
+
+// Run imports the dump from where its checkpoint left off and returns the
+// final checkpoint. Messages already stored, by an earlier import or by the
+// consumers, are skipped by message_id. Once ctx is cancelled it stops after
+// the batch being stored, returning the error of ctx
+func (im *Importer) Run(ctx context.Context) (*Checkpoint, error) {
+	input, err := filepath.Abs(im.cfg.Input)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, err := loadCheckpoint(im.cfg.CheckpointPath)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case checkpoint == nil:
+		checkpoint = &Checkpoint{Input: input}
+	case checkpoint.Input != input:
+		return nil, fmt.Errorf("checkpoint %s is for %s; remove it or name another to import %s", im.cfg.CheckpointPath, checkpoint.Input, input)
+	case checkpoint.Done:
+		slog.Info("Backfill already complete; remove the checkpoint to import again", "input", input, "checkpoint", im.cfg.CheckpointPath)
+		return checkpoint, nil
+	default:
+		slog.Info("Resuming backfill from checkpoint", "input", input, "offset", checkpoint.Offset, "records", checkpoint.Records)
+	}
+
+	reader, err := openReader(input, im.cfg.Format, checkpoint.Offset, checkpoint.Header)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	checkpoint.Header = reader.header
+
+	// The size of a compressed dump isn't known, so its progress has no percentage
+	var size int64
+	if info, err := reader.file.Stat(); err == nil && reader.gzip == nil {
+		size = info.Size()
+	}
+
+	var rejects *os.File
+	if im.cfg.RejectsPath != "" {
+		rejects, err = os.OpenFile(im.cfg.RejectsPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open rejects file: %w", err)
+		}
+		defer rejects.Close()
+	}
+
+	var stored int
+	pipeline, err := ingest.NewPipeline(ingest.PipelineConfig{
+		DefaultTenantID: im.cfg.DefaultTenantID,
+		Direction:       im.cfg.Direction,
+		Persist: ingest.PersistWith(func(ctx context.Context, records []*models.SMSRecord) error {
+			duplicates, err := im.smsService.SaveMessagesCounting(ctx, records)
+			stored = len(records) - duplicates
+			var bulkErr *services.BulkSaveError
+			if errors.As(err, &bulkErr) {
+				stored -= len(bulkErr.Failed)
+			}
+			return err
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	started, lastProgress := time.Now(), time.Now()
+	startRecords := checkpoint.Records
+	for !checkpoint.Done {
+		if err := ctx.Err(); err != nil {
+			return checkpoint, err
+		}
+
+		// Advanced on a copy, which replaces the checkpoint once the batch is stored
+		next := *checkpoint
+		var payloads [][]byte
+		var numbers []int64
+		var rejected []rejection
+		for len(payloads) < im.cfg.BatchSize {
+			payload, err := reader.next()
+			if errors.Is(err, io.EOF) {
+				next.Done = true
+				break
+			}
+			var malformed *malformedError
+			if errors.As(err, &malformed) {
+				next.Records++
+				rejected = append(rejected, rejection{Record: next.Records, Reason: ingest.ReasonMalformed, Error: err.Error()})
+				continue
+			}
+			if err != nil {
+				return checkpoint, fmt.Errorf("failed to read %s at offset %d: %w", input, reader.offset, err)
+			}
+			next.Records++
+			payloads = append(payloads, payload)
+			numbers = append(numbers, next.Records)
+		}
+		next.Offset = reader.offset
+
+		malformed := len(rejected)
+		stored = 0
+		for attempt := 1; len(payloads) > 0; attempt++ {
+			// Events are made afresh for each attempt, as the pipeline marks them
+			events := make([]*ingest.Event, len(payloads))
+			positions := make(map[*ingest.Event]int64, len(payloads))
+			for i, payload := range payloads {
+				events[i] = ingest.NewEvent(ctx, source, payload)
+				positions[events[i]] = numbers[i]
+			}
+			rejected, stored = rejected[:malformed], 0
+			err := pipeline.Run(context.WithoutCancel(ctx), events, func(e *ingest.Event) error {
+				reason := e.Rejection()
+				rejected = append(rejected, rejection{Record: positions[e], Reason: reason.Reason, Error: reason.Err.Error(), Payload: string(e.Payload)})
+				return nil
+			})
+			if err == nil {
+				break
+			}
+			if attempt == maxAttempts {
+				return checkpoint, fmt.Errorf("failed to store records %d to %d: %w", numbers[0], numbers[len(numbers)-1], err)
+			}
+			backoff := retryBackoff << (attempt - 1)
+			slog.Warn("Failed to store backfill batch, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+			select {
+			case <-ctx.Done():
+				return checkpoint, ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+
+		if err := im.reject(rejects, rejected); err != nil {
+			return checkpoint, err
+		}
+		next.Imported += int64(stored)
+		next.Rejected += int64(len(rejected))
+		// The rest were repeated earlier in the batch or already stored
+		next.Duplicates += int64(len(payloads) - stored - (len(rejected) - malformed))
+		if err := next.save(im.cfg.CheckpointPath); err != nil {
+			return checkpoint, fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+		checkpoint = &next
+
+		if time.Since(lastProgress) >= im.cfg.ProgressInterval && !checkpoint.Done {
+			lastProgress = time.Now()
+			attrs := []any{
+				"records", checkpoint.Records,
+				"imported", checkpoint.Imported,
+				"duplicates", checkpoint.Duplicates,
+				"rejected", checkpoint.Rejected,
+				"records_per_second", int(float64(checkpoint.Records-startRecords) / time.Since(started).Seconds()),
+			}
+			if size > 0 {
+				attrs = append(attrs, "percent", fmt.Sprintf("%.1f", float64(checkpoint.Offset)*100/float64(size)))
+			}
+			slog.Info("Backfill progress", attrs...)
+		}
+	}
+
+	slog.Info("Backfill complete", "input", input, "records", checkpoint.Records, "imported", checkpoint.Imported,
+		"duplicates", checkpoint.Duplicates, "rejected", checkpoint.Rejected, "duration", time.Since(started).Round(time.Second))
+	return checkpoint, nil
+}
+
+// reject records the messages of a batch that were not imported, in the
+// rejects file if there is one. It is written before the checkpoint, so the
+// rejections of a batch interrupted in between are recorded again on resume
+func (im *Importer) reject(rejects *os.File, rejected []rejection) error {
+	if rejects == nil {
+		for _, r := range rejected {
+			slog.Warn("Rejected backfill record", "record", r.Record, "reason", r.Reason, "error", r.Error)
+		}
+		return nil
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+	var lines strings.Builder
+	enc := json.NewEncoder(&lines)
+	for _, r := range rejected {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	if _, err := rejects.WriteString(lines.String()); err != nil {
+		return fmt.Errorf("failed to write rejects file: %w", err)
+	}
+	return rejects.Sync()
+}
@@ -0,0 +1,206 @@
+package backfill
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Formats of the dumps the importer reads, optionally gzip-compressed
+const (
+	FormatCSV    = "csv"    // a header row of SMS event field names, then a row per message
+	FormatNDJSON = "ndjson" // an SMS event JSON object per line
+)
+
+// FormatOf infers the format of a dump from its file extension, ignoring a
+// trailing .gz
+func FormatOf(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(strings.TrimSuffix(path, ".gz"))) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ndjson", ".jsonl", ".json":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %s from its extension; name it", path)
+}
+
+// csvColumns are the column names a CSV dump may use, the JSON names of the
+// SMS event fields
+var csvColumns = eventFieldNames()
+
+func eventFieldNames() []string {
+	var names []string
+	event := reflect.TypeFor[models.KafkaEvent]()
+	for i := range event.NumField() {
+		name, _, _ := strings.Cut(event.Field(i).Tag.Get("json"), ",")
+		names = append(names, name)
+	}
+	return names
+}
+
+// reader reads the messages of a dump one at a time as SMS event JSON
+// payloads, keeping track of its position in the uncompressed content
+type reader struct {
+	file   *os.File
+	gzip   *gzip.Reader
+	buf    *bufio.Reader
+	format string
+
+	offset int64 // of the next message, in uncompressed bytes
+
+	csv     *csv.Reader
+	csvBase int64 // offset the CSV reader started at
+	header  []string
+}
+
+// openReader opens the dump at path and moves to offset, which must be the
+// start of a message. A CSV dump read from its start has its header read;
+// otherwise header must be that of the dump
+func openReader(path, format string, offset int64, header []string) (*reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &reader{file: file, format: format, offset: offset, header: header}
+
+	var content io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		if r.gzip, err = gzip.NewReader(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read gzip header of %s: %w", path, err)
+		}
+		content = r.gzip
+		// A compressed stream can't be seeked, so the content before offset is skipped
+		if _, err := io.CopyN(io.Discard, r.gzip, offset); err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to skip to offset %d of %s: %w", offset, path, err)
+		}
+	} else if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.buf = bufio.NewReaderSize(content, 1<<20)
+
+	if format == FormatCSV {
+		r.csv = csv.NewReader(r.buf)
+		r.csv.ReuseRecord = true
+		r.csvBase = offset
+		if offset == 0 {
+			if err := r.readHeader(); err != nil {
+				r.Close()
+				return nil, err
+			}
+		}
+		r.csv.FieldsPerRecord = len(r.header)
+	}
+	return r, nil
+}
+
+// readHeader reads the header row of a CSV dump, checking that it names SMS
+// event fields
+func (r *reader) readHeader() error {
+	header, err := r.csv.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	header = slices.Clone(header)
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	for _, name := range header {
+		if !slices.Contains(csvColumns, name) {
+			return fmt.Errorf("unknown CSV column %q; columns are named after SMS event fields: %s", name, strings.Join(csvColumns, ", "))
+		}
+	}
+	r.header = header
+	r.offset = r.csvBase + r.csv.InputOffset()
+	return nil
+}
+
+// next returns the payload of the next message, and io.EOF at the end of the
+// dump. An error of *malformedError only spoils that message, and the next
+// can still be read
+func (r *reader) next() ([]byte, error) {
+	if r.format == FormatCSV {
+		return r.nextCSV()
+	}
+	for {
+		line, err := r.buf.ReadBytes('\n')
+		r.offset += int64(len(line))
+		if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+	}
+}
+
+func (r *reader) nextCSV() ([]byte, error) {
+	row, err := r.csv.Read()
+	r.offset = r.csvBase + r.csv.InputOffset()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, &malformedError{err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]any, len(row))
+	for i, value := range row {
+		if value == "" {
+			continue
+		}
+		switch name := r.header[i]; name {
+		case "segmentCount":
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, &malformedError{fmt.Errorf("segmentCount %q is not a number", value)}
+			}
+			fields[name] = count
+		case "media":
+			// A JSON array of attachments, as in the event
+			if !json.Valid([]byte(value)) {
+				return nil, &malformedError{fmt.Errorf("media is not valid JSON")}
+			}
+			fields[name] = json.RawMessage(value)
+		default:
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Close closes the dump
+func (r *reader) Close() error {
+	if r.gzip != nil {
+		r.gzip.Close()
+	}
+	return r.file.Close()
+}
+
+// malformedError is a message that could not be read from the dump
+type malformedError struct {
+	err error
+}
+
+func (e *malformedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *malformedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,156 @@
+// Package breaker implements a circuit breaker that stops calling a failing
+// dependency for a while, so callers fail fast instead of each waiting out a timeout
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// State is the state of a Breaker
+type State int
+
+const (
+	// Closed lets every call through
+	Closed State = iota
+	// Open rejects every call until the open period ends
+	Open
+	// HalfOpen lets a single probe call through to test whether the dependency recovered
+	HalfOpen
+)
+
+// String returns the state's name as used in logs and metrics
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// OpenError is returned by Allow while the breaker rejects calls
+type OpenError struct {
+	Name       string
+	RetryAfter time.Duration // until the next probe is let through
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%s circuit breaker is open, retry in %s", e.Name, e.RetryAfter.Round(time.Second))
+}
+
+// Breaker opens after Threshold consecutive failures, rejects calls for OpenFor,
+// then lets one probe through; the probe's success closes it again, its failure
+// reopens it
+type Breaker struct {
+	name      string
+	threshold int
+	openFor   time.Duration
+	isFailure func(error) bool
+	onChange  func(State)
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// New creates a closed breaker for the named dependency. isFailure tells apart
+// errors that mean the dependency is unhealthy from errors about the call itself,
+// such as a duplicate key; a nil isFailure counts every error
+func New(name string, threshold int, openFor time.Duration, isFailure func(error) bool) *Breaker {
+	if isFailure == nil {
+		isFailure = func(error) bool { return true }
+	}
+	return &Breaker{name: name, threshold: threshold, openFor: openFor, isFailure: isFailure}
+}
+
+// OnStateChange sets fn to be called, with the breaker locked, whenever the state changes
+func (b *Breaker) OnStateChange(fn func(State)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = fn
+}
+
+// State returns the current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether a call may go ahead, returning an *OpenError if not
+// Every allowed call must be followed by Done with its outcome
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		now := time.Now()
+		if now.Before(b.openUntil) {
+			return &OpenError{Name: b.name, RetryAfter: b.openUntil.Sub(now)}
+		}
+		b.setState(HalfOpen)
+		b.probing = true
+		return nil
+	case HalfOpen:
+		if b.probing {
+			return &OpenError{Name: b.name, RetryAfter: b.openFor}
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Done records the outcome of a call let through by Allow
+// A canceled call says nothing about the dependency and only frees the probe
+func (b *Breaker) Done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen {
+		b.probing = false
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return
+	case err != nil && b.isFailure(err):
+		b.failures++
+		if b.state == HalfOpen || (b.state == Closed && b.failures >= b.threshold) {
+			b.openUntil = time.Now().Add(b.openFor)
+			b.setState(Open)
+		}
+	default:
+		b.failures = 0
+		if b.state == HalfOpen {
+			b.setState(Closed)
+		}
+	}
+}
+
+// setState moves to state and reports the change; b.mu must be held
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	switch state {
+	case Open:
+		slog.Warn("Circuit breaker opened", "dependency", b.name, "failures", b.failures, "open_for", b.openFor)
+	case Closed:
+		slog.Info("Circuit breaker closed", "dependency", b.name)
+	}
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
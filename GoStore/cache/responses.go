@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+)
+
+// Background refreshes of stale responses are given this long to load
+const responseRefreshTimeout = 30 * time.Second
+
+// responseSweepInterval is how often expired responses and invalidations are swept
+const responseSweepInterval = time.Minute
+
+// ResponsePolicy sets how long the responses of a route are cached
+type ResponsePolicy struct {
+	// TTL is how long a response is served as fresh; zero disables caching the route
+	TTL time.Duration
+	// StaleWhileRevalidate is how long after the TTL an expired response is
+	// still served while it is reloaded in the background
+	StaleWhileRevalidate time.Duration
+}
+
+// userKey identifies a user of a tenant; responses not about one user have no user ID
+type userKey struct {
+	tenantID string
+	userID   string
+}
+
+// responseKey identifies a response of a route: the user it is about and its query
+type responseKey struct {
+	route string
+	user  userKey
+	query string
+}
+
+type responseEntry struct {
+	value      any
+	loadedAt   time.Time
+	refreshing bool
+}
+
+// Responses caches the results of read-heavy routes in process memory, each
+// route for the TTL of its policy. Responses about a user are dropped whenever
+// Invalidate is called for them, as it is when one of their messages is stored,
+// changed or erased; the others, like tenant-wide analytics, only expire.
+// Invalidation only reaches the instance it is called on, so other instances
+// serve their entries until the TTL
+type Responses struct {
+	policies map[string]ResponsePolicy
+
+	mu      sync.Mutex
+	entries map[responseKey]*responseEntry
+	// clock counts invalidations; invalidated holds the clock value and time of
+	// each user's latest, so loads that started before it are not cached
+	clock       uint64
+	invalidated map[userKey]invalidation
+	nextSweep   time.Time
+}
+
+type invalidation struct {
+	clock uint64
+	at    time.Time
+}
+
+// NewResponses creates an empty response cache with a policy per route name
+func NewResponses(policies map[string]ResponsePolicy) *Responses {
+	return &Responses{
+		policies:    policies,
+		entries:     make(map[responseKey]*responseEntry),
+		invalidated: make(map[userKey]invalidation),
+	}
+}
+
+// Policy returns the policy of route, and whether its responses are cached
+func (c *Responses) Policy(route string) (ResponsePolicy, bool) {
+	policy := c.policies[route]
+	return policy, policy.TTL > 0
+}
+
+// Get returns the response of route about the tenant's user to query, and how
+// long ago it was loaded. Fresh responses are served from the cache; stale ones
+// are too, within the route's stale-while-revalidate window, while one
+// background load replaces them. Otherwise the response is loaded with load
+// and cached, unless loading failed
+func (c *Responses) Get(ctx context.Context, route, tenantID, userID, query string, load func(context.Context) (any, error)) (any, time.Duration, error) {
+	policy, ok := c.Policy(route)
+	if !ok {
+		value, err := load(ctx)
+		return value, 0, err
+	}
+	key := responseKey{route: route, user: userKey{tenantID, userID}, query: query}
+
+	c.mu.Lock()
+	now := time.Now()
+	if entry, ok := c.entries[key]; ok {
+		age := now.Sub(entry.loadedAt)
+		if age < policy.TTL {
+			c.mu.Unlock()
+			metrics.ResponseCacheLookup(route, "hit")
+			return entry.value, age, nil
+		}
+		if age < policy.TTL+policy.StaleWhileRevalidate {
+			if !entry.refreshing {
+				entry.refreshing = true
+				go c.refresh(context.WithoutCancel(ctx), key, entry, load)
+			}
+			c.mu.Unlock()
+			metrics.ResponseCacheLookup(route, "stale")
+			return entry.value, age, nil
+		}
+	}
+	started := c.clock
+	c.mu.Unlock()
+
+	metrics.ResponseCacheLookup(route, "miss")
+	value, err := load(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.store(key, value, started)
+	return value, 0, nil
+}
+
+// refresh reloads the stale entry of key in the background
+func (c *Responses) refresh(ctx context.Context, key responseKey, entry *responseEntry, load func(context.Context) (any, error)) {
+	ctx, cancel := context.WithTimeout(ctx, responseRefreshTimeout)
+	defer cancel()
+
+	c.mu.Lock()
+	started := c.clock
+	c.mu.Unlock()
+
+	value, err := load(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to refresh cached response", "route", key.route, "error", err)
+		c.mu.Lock()
+		entry.refreshing = false
+		c.mu.Unlock()
+		return
+	}
+	c.store(key, value, started)
+}
+
+// store caches value as the response of key, loaded from the clock value
+// started on, unless the user was invalidated since
+// Expired entries are swept at most once a minute, so the cache only holds
+// responses requested recently
+func (c *Responses) store(key responseKey, value any, started uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.After(c.nextSweep) {
+		for k, entry := range c.entries {
+			policy := c.policies[k.route]
+			if now.Sub(entry.loadedAt) >= policy.TTL+policy.StaleWhileRevalidate && !entry.refreshing {
+				delete(c.entries, k)
+			}
+		}
+		// Loads running longer than the sweep interval may cache a response
+		// that an invalidation swept here made stale, until its TTL
+		for user, inv := range c.invalidated {
+			if now.Sub(inv.at) >= responseSweepInterval {
+				delete(c.invalidated, user)
+			}
+		}
+		c.nextSweep = now.Add(responseSweepInterval)
+	}
+
+	if inv, ok := c.invalidated[key.user]; ok && inv.clock > started {
+		// Loaded concurrently with a write; the next request loads it again
+		delete(c.entries, key)
+		return
+	}
+	c.entries[key] = &responseEntry{value: value, loadedAt: now}
+}
+
+// Invalidate drops every cached response about the tenant's users
+func (c *Responses) Invalidate(tenantID string, userIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clock++
+	now := time.Now()
+	for _, userID := range userIDs {
+		c.invalidated[userKey{tenantID, userID}] = invalidation{clock: c.clock, at: now}
+	}
+	for key := range c.entries {
+		if key.user.tenantID != tenantID || key.user.userID == "" {
+			continue
+		}
+		for _, userID := range userIDs {
+			if key.user.userID == userID {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
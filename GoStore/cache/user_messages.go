@@ -0,0 +1,111 @@
+// Package cache keeps the results of hot read queries in Redis or in process memory
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPrefix namespaces the cache keys in a Redis shared with other services
+const keyPrefix = "sms-store:user-messages:"
+
+// UserMessages caches each user's message list, as returned by GetMessagesByUserID.
+// Entries are removed whenever a message of the user is stored or changed, and
+// expire after the TTL, which bounds how long a list read concurrently with a
+// write can stay stale. Redis errors are logged and treated as misses, so an
+// unavailable cache only slows reads down
+type UserMessages struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewUserMessages connects to the Redis server at url (redis://[user:password@]host:port/db)
+func NewUserMessages(url string, ttl time.Duration) (*UserMessages, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &UserMessages{client: client, ttl: ttl}, nil
+}
+
+// key returns the cache key of a user's messages; user IDs are only unique within a tenant
+func key(tenantID, userID string) string {
+	return keyPrefix + tenantID + ":" + userID
+}
+
+// Get returns the cached messages of the user, if any
+func (c *UserMessages) Get(ctx context.Context, tenantID, userID string) ([]*models.SMSRecord, bool) {
+	data, err := c.client.Get(ctx, key(tenantID, userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		metrics.CacheLookup("miss")
+		return nil, false
+	}
+	if err != nil {
+		metrics.CacheLookup("error")
+		slog.WarnContext(ctx, "Failed to read user messages cache", "tenant_id", tenantID, "user_id", userID, "error", err)
+		return nil, false
+	}
+
+	var records []*models.SMSRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		metrics.CacheLookup("error")
+		slog.WarnContext(ctx, "Discarding unreadable user messages cache entry", "tenant_id", tenantID, "user_id", userID, "error", err)
+		return nil, false
+	}
+	metrics.CacheLookup("hit")
+	return records, true
+}
+
+// Set caches the messages of the user
+func (c *UserMessages) Set(ctx context.Context, tenantID, userID string, records []*models.SMSRecord) {
+	data, err := json.Marshal(records)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to encode user messages for the cache", "tenant_id", tenantID, "user_id", userID, "error", err)
+		return
+	}
+	if err := c.client.Set(ctx, key(tenantID, userID), data, c.ttl).Err(); err != nil {
+		slog.WarnContext(ctx, "Failed to write user messages cache", "tenant_id", tenantID, "user_id", userID, "error", err)
+	}
+}
+
+// Invalidate removes the cached messages of each given user of the tenant
+func (c *UserMessages) Invalidate(ctx context.Context, tenantID string, userIDs ...string) {
+	if len(userIDs) == 0 {
+		return
+	}
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = key(tenantID, userID)
+	}
+	if err := c.client.Del(ctx, keys...).Err(); err != nil {
+		// The entries expire with their TTL instead
+		slog.WarnContext(ctx, "Failed to invalidate user messages cache", "tenant_id", tenantID, "users", len(userIDs), "error", err)
+	}
+}
+
+// Ping checks that Redis answers
+func (c *UserMessages) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Close closes the Redis connections
+func (c *UserMessages) Close() error {
+	return c.client.Close()
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// userStatsKey identifies the stats of a user over a number of days
+type userStatsKey struct {
+	tenantID string
+	userID   string
+	days     int
+}
+
+type userStatsEntry struct {
+	stats   *models.MessageStats
+	expires time.Time
+}
+
+// UserStats caches each user's message stats in process memory for a short TTL,
+// so dashboards polling the stats endpoint do not rerun the aggregation. Entries
+// are not invalidated by new messages; the TTL bounds how stale they get
+type UserStats struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[userStatsKey]userStatsEntry
+	nextSweep time.Time
+}
+
+// NewUserStats creates an empty stats cache
+func NewUserStats(ttl time.Duration) *UserStats {
+	return &UserStats{
+		ttl:     ttl,
+		entries: make(map[userStatsKey]userStatsEntry),
+	}
+}
+
+// Get returns the cached stats of the user over days, if present and unexpired
+func (c *UserStats) Get(tenantID, userID string, days int) (*models.MessageStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[userStatsKey{tenantID, userID, days}]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.stats, true
+}
+
+// Set caches the stats of the user over days
+// Expired entries are swept at most once per TTL, so the cache only holds users
+// looked up recently
+func (c *UserStats) Set(tenantID, userID string, days int, stats *models.MessageStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.After(c.nextSweep) {
+		for key, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+		c.nextSweep = now.Add(c.ttl)
+	}
+	c.entries[userStatsKey{tenantID, userID, days}] = userStatsEntry{stats: stats, expires: now.Add(c.ttl)}
+}
+
+// Invalidate drops every cached stats of the user
+func (c *UserStats) Invalidate(tenantID, userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.tenantID == tenantID && key.userID == userID {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"time"
+
+	"github.com/ramG-reddy/sms-store/config"
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/logging"
+	"github.com/ramG-reddy/sms-store/redact"
+	"github.com/spf13/cobra"
+)
+
+// cli is what the commands share: the configuration, loaded before any of them
+// runs, and the redactor of the logger, enabled as it says
+type cli struct {
+	configPath string
+	cfg        *config.Config
+	redactor   *redact.Redactor
+}
+
+// newRootCommand returns the sms-store command, which runs the service unless
+// given one of the operational subcommands
+func newRootCommand(redactor *redact.Redactor) *cobra.Command {
+	app := &cli{redactor: redactor}
+	root := &cobra.Command{
+		Use:     "sms-store",
+		Short:   "Stores SMS events from message brokers and serves them over HTTP, gRPC and GraphQL",
+		Version: version,
+		Args:    cobra.NoArgs,
+		// Invoked without a subcommand, as the container image does, it serves
+		Run: func(*cobra.Command, []string) { serve(app) },
+		PersistentPreRun: func(*cobra.Command, []string) {
+			app.loadConfig()
+		},
+		SilenceUsage:      true,
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+	}
+	root.PersistentFlags().StringVar(&app.configPath, "config", "", "YAML or JSON config file; environment variables override its settings")
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Run the service (the default)",
+			Args:  cobra.NoArgs,
+			Run:   func(*cobra.Command, []string) { serve(app) },
+		},
+		newMigrateCommand(app),
+		newReplayCommand(app),
+		newBackfillCommand(app),
+		newExportCommand(app),
+		newLoadgenCommand(app),
+	)
+	return root
+}
+
+// loadConfig loads the configuration and applies its logging settings
+func (app *cli) loadConfig() {
+	cfg, err := config.Load(app.configPath)
+	if err != nil {
+		logging.Fatal("Failed to load configuration", "error", err)
+	}
+	if err := logging.SetLevel(cfg.LogLevel); err != nil {
+		logging.Fatal("Failed to set log level", "error", err)
+	}
+	app.redactor.SetEnabled(cfg.LogRedactPII)
+	app.cfg = cfg
+}
+
+// connectMongo connects to MongoDB with the configured consistency settings.
+// The caller closes the connection
+func (app *cli) connectMongo() *db.Mongo {
+	m, err := db.Connect(app.cfg.MongoURI, app.cfg.MongoDatabase)
+	if err != nil {
+		logging.Fatal("Failed to connect to MongoDB", "error", err)
+	}
+	if err := m.SetConsistency(app.cfg.MongoQueryReadPreference, app.cfg.MongoIngestWriteConcern); err != nil {
+		logging.Fatal("Invalid MongoDB consistency settings", "error", err)
+	}
+	m.SetQueryTimeout(time.Duration(app.cfg.MongoQueryTimeoutMs) * time.Millisecond)
+	m.SetSlowQueryThreshold(time.Duration(app.cfg.MongoSlowQueryMs) * time.Millisecond)
+	return m
+}
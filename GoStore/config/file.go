@@ -0,0 +1,331 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// fileKeys maps each setting of a config file, as section.key, to the
+// environment variable that overrides it
+var fileKeys = map[string]string{
+	"server.port":                            "GO_SERVICE_PORT",
+	"server.http_read_timeout_seconds":       "HTTP_READ_TIMEOUT_SECONDS",
+	"server.http_write_timeout_seconds":      "HTTP_WRITE_TIMEOUT_SECONDS",
+	"server.http_idle_timeout_seconds":       "HTTP_IDLE_TIMEOUT_SECONDS",
+	"server.http_max_header_bytes":           "HTTP_MAX_HEADER_BYTES",
+	"server.shutdown_grace_seconds":          "SHUTDOWN_GRACE_SECONDS",
+	"server.restart_handoff_enabled":         "RESTART_HANDOFF_ENABLED",
+	"server.restart_handoff_timeout_seconds": "RESTART_HANDOFF_TIMEOUT_SECONDS",
+	"server.pid_file":                        "PID_FILE",
+	"server.grpc_port":                       "GRPC_PORT",
+	"server.grpc_reflection":                 "GRPC_REFLECTION",
+	"server.admin_port":                      "ADMIN_PORT",
+	"server.log_level":                       "LOG_LEVEL",
+	"server.log_redact_pii":                  "LOG_REDACT_PII",
+	"server.readiness_max_kafka_lag":         "READINESS_MAX_KAFKA_LAG",
+	"server.service_mode":                    "SERVICE_MODE",
+	"server.rate_limit_rps":                  "RATE_LIMIT_RPS",
+	"server.rate_limit_burst":                "RATE_LIMIT_BURST",
+	"server.rate_limit_trust_forwarded_for":  "RATE_LIMIT_TRUST_FORWARDED_FOR",
+	"server.graphql_max_page_size":           "GRAPHQL_MAX_PAGE_SIZE",
+	"server.tls_cert_file":                   "TLS_CERT_FILE",
+	"server.tls_key_file":                    "TLS_KEY_FILE",
+	"server.tls_min_version":                 "TLS_MIN_VERSION",
+	"server.tls_client_ca_file":              "TLS_CLIENT_CA_FILE",
+	"server.tls_client_auth":                 "TLS_CLIENT_AUTH",
+	"server.cors_allowed_origins":            "CORS_ALLOWED_ORIGINS",
+	"server.cors_allowed_methods":            "CORS_ALLOWED_METHODS",
+	"server.cors_allowed_headers":            "CORS_ALLOWED_HEADERS",
+	"server.cors_max_age_seconds":            "CORS_MAX_AGE_SECONDS",
+	"server.swagger_ui_enabled":              "SWAGGER_UI_ENABLED",
+	"server.compression_enabled":             "COMPRESSION_ENABLED",
+	"server.compression_min_bytes":           "COMPRESSION_MIN_BYTES",
+
+	"mongo.host":                    "MONGO_HOST",
+	"mongo.port":                    "MONGO_PORT",
+	"mongo.database":                "MONGO_DATABASE",
+	"mongo.user":                    "MONGO_APP_USER",
+	"mongo.password":                "MONGO_APP_PASSWORD",
+	"mongo.query_read_preference":   "MONGO_QUERY_READ_PREFERENCE",
+	"mongo.ingest_write_concern":    "MONGO_INGEST_WRITE_CONCERN",
+	"mongo.migrate_on_start":        "MONGO_MIGRATE_ON_START",
+	"mongo.query_timeout_ms":        "MONGO_QUERY_TIMEOUT_MS",
+	"mongo.slow_query_ms":           "MONGO_SLOW_QUERY_MS",
+	"mongo.reconnect_after_seconds": "MONGO_RECONNECT_AFTER_SECONDS",
+	"mongo.change_streams_enabled":  "CHANGE_STREAMS_ENABLED",
+	"mongo.change_stream_name":      "CHANGE_STREAM_NAME",
+
+	"storage.backend":                    "STORAGE_BACKEND",
+	"storage.retention_days":             "RETENTION_DAYS",
+	"storage.retention_policies_enabled": "RETENTION_POLICIES_ENABLED",
+	"storage.retention_action":           "RETENTION_ACTION",
+	"storage.retention_sweep_minutes":    "RETENTION_SWEEP_MINUTES",
+	"storage.retention_sweep_batch_size": "RETENTION_SWEEP_BATCH_SIZE",
+	"storage.tiering_enabled":            "TIERING_ENABLED",
+	"storage.tiering_cold_after_days":    "TIERING_COLD_AFTER_DAYS",
+	"storage.tiering_interval_minutes":   "TIERING_INTERVAL_MINUTES",
+	"storage.tiering_batch_size":         "TIERING_BATCH_SIZE",
+	"storage.postgres_url":               "POSTGRES_URL",
+	"storage.postgres_max_conns":         "POSTGRES_MAX_CONNS",
+	"storage.cassandra_hosts":            "CASSANDRA_HOSTS",
+	"storage.cassandra_keyspace":         "CASSANDRA_KEYSPACE",
+	"storage.cassandra_consistency":      "CASSANDRA_CONSISTENCY",
+	"storage.cassandra_username":         "CASSANDRA_USERNAME",
+	"storage.cassandra_password":         "CASSANDRA_PASSWORD",
+	"storage.breaker_threshold":          "STORAGE_BREAKER_THRESHOLD",
+	"storage.breaker_open_seconds":       "STORAGE_BREAKER_OPEN_SECONDS",
+
+	"media.backend":       "MEDIA_BACKEND",
+	"media.gridfs_bucket": "MEDIA_GRIDFS_BUCKET",
+	"media.s3_bucket":     "MEDIA_S3_BUCKET",
+	"media.s3_prefix":     "MEDIA_S3_PREFIX",
+	"media.s3_endpoint":   "MEDIA_S3_ENDPOINT",
+
+	"cache.redis_url":              "REDIS_URL",
+	"cache.user_ttl_seconds":       "USER_CACHE_TTL_SECONDS",
+	"cache.user_stats_ttl_seconds": "USER_STATS_CACHE_TTL_SECONDS",
+
+	"cache.response_messages_ttl_seconds": "RESPONSE_CACHE_MESSAGES_TTL_SECONDS",
+	"cache.response_count_ttl_seconds":    "RESPONSE_CACHE_COUNT_TTL_SECONDS",
+	"cache.response_stats_ttl_seconds":    "RESPONSE_CACHE_STATS_TTL_SECONDS",
+	"cache.response_delivery_ttl_seconds": "RESPONSE_CACHE_DELIVERY_TTL_SECONDS",
+	"cache.response_stale_seconds":        "RESPONSE_CACHE_STALE_SECONDS",
+
+	"search.url":       "SEARCH_URL",
+	"search.index":     "SEARCH_INDEX",
+	"search.username":  "SEARCH_USERNAME",
+	"search.password":  "SEARCH_PASSWORD",
+	"search.max_limit": "SEARCH_MAX_LIMIT",
+
+	"flagging.rules_file": "FLAG_RULES_FILE",
+
+	"senders.registry_enabled": "SENDER_REGISTRY_ENABLED",
+	"senders.cache_seconds":    "SENDER_CACHE_SECONDS",
+
+	"blocklist.enabled":       "BLOCKLIST_ENABLED",
+	"blocklist.action":        "BLOCKLIST_ACTION",
+	"blocklist.cache_seconds": "BLOCKLIST_CACHE_SECONDS",
+
+	"ingest.backend":                "INGEST_BACKEND",
+	"ingest.strict_validation":      "INGEST_STRICT_VALIDATION",
+	"ingest.max_message_length":     "INGEST_MAX_MESSAGE_LENGTH",
+	"ingest.max_clock_skew_seconds": "INGEST_MAX_CLOCK_SKEW_SECONDS",
+
+	"nats.url":              "NATS_URL",
+	"nats.creds_file":       "NATS_CREDS_FILE",
+	"nats.stream":           "NATS_STREAM",
+	"nats.subjects":         "NATS_SUBJECTS",
+	"nats.durable":          "NATS_DURABLE",
+	"nats.batch_size":       "NATS_BATCH_SIZE",
+	"nats.fetch_wait_ms":    "NATS_FETCH_WAIT_MS",
+	"nats.ack_wait_seconds": "NATS_ACK_WAIT_SECONDS",
+	"nats.max_deliver":      "NATS_MAX_DELIVER",
+
+	"rabbitmq.url":           "RABBITMQ_URL",
+	"rabbitmq.queues":        "RABBITMQ_QUEUES",
+	"rabbitmq.prefetch":      "RABBITMQ_PREFETCH",
+	"rabbitmq.batch_size":    "RABBITMQ_BATCH_SIZE",
+	"rabbitmq.batch_wait_ms": "RABBITMQ_BATCH_WAIT_MS",
+	"rabbitmq.max_attempts":  "RABBITMQ_MAX_ATTEMPTS",
+
+	"sqs.queues":                     "SQS_QUEUES",
+	"sqs.endpoint":                   "SQS_ENDPOINT",
+	"sqs.batch_size":                 "SQS_BATCH_SIZE",
+	"sqs.wait_time_seconds":          "SQS_WAIT_TIME_SECONDS",
+	"sqs.visibility_timeout_seconds": "SQS_VISIBILITY_TIMEOUT_SECONDS",
+
+	"pubsub.project_id":               "PUBSUB_PROJECT_ID",
+	"pubsub.subscriptions":            "PUBSUB_SUBSCRIPTIONS",
+	"pubsub.streams":                  "PUBSUB_STREAMS",
+	"pubsub.max_outstanding_messages": "PUBSUB_MAX_OUTSTANDING_MESSAGES",
+	"pubsub.max_outstanding_bytes":    "PUBSUB_MAX_OUTSTANDING_BYTES",
+	"pubsub.batch_size":               "PUBSUB_BATCH_SIZE",
+	"pubsub.batch_wait_ms":            "PUBSUB_BATCH_WAIT_MS",
+
+	"kafka.brokers":                 "KAFKA_BROKERS",
+	"kafka.topics":                  "KAFKA_TOPIC",
+	"kafka.group_id":                "KAFKA_GROUP_ID",
+	"kafka.status_topic":            "KAFKA_STATUS_TOPIC",
+	"kafka.status_group_id":         "KAFKA_STATUS_GROUP_ID",
+	"kafka.group_balancer":          "KAFKA_GROUP_BALANCER",
+	"kafka.session_timeout_ms":      "KAFKA_SESSION_TIMEOUT_MS",
+	"kafka.heartbeat_interval_ms":   "KAFKA_HEARTBEAT_INTERVAL_MS",
+	"kafka.rebalance_timeout_ms":    "KAFKA_REBALANCE_TIMEOUT_MS",
+	"kafka.join_group_backoff_ms":   "KAFKA_JOIN_GROUP_BACKOFF_MS",
+	"kafka.dlq_topic":               "KAFKA_DLQ_TOPIC",
+	"kafka.quarantine_enabled":      "KAFKA_QUARANTINE_ENABLED",
+	"kafka.write_max_attempts":      "KAFKA_WRITE_MAX_ATTEMPTS",
+	"kafka.write_retry_base_ms":     "KAFKA_WRITE_RETRY_BASE_MS",
+	"kafka.write_retry_max_ms":      "KAFKA_WRITE_RETRY_MAX_MS",
+	"kafka.batch_size":              "KAFKA_BATCH_SIZE",
+	"kafka.batch_timeout_ms":        "KAFKA_BATCH_TIMEOUT_MS",
+	"kafka.workers":                 "KAFKA_WORKERS",
+	"kafka.worker_queue_size":       "KAFKA_WORKER_QUEUE_SIZE",
+	"kafka.max_in_flight":           "KAFKA_MAX_IN_FLIGHT",
+	"kafka.fetch_queue_capacity":    "KAFKA_FETCH_QUEUE_CAPACITY",
+	"kafka.message_format":          "KAFKA_MESSAGE_FORMAT",
+	"kafka.schema_registry_url":     "SCHEMA_REGISTRY_URL",
+	"kafka.schema_subject_strategy": "SCHEMA_SUBJECT_STRATEGY",
+	"kafka.stored_events_topic":     "KAFKA_STORED_EVENTS_TOPIC",
+	"kafka.outbox_poll_ms":          "KAFKA_OUTBOX_POLL_MS",
+	"kafka.requeue_topic":           "KAFKA_REQUEUE_TOPIC",
+	"kafka.requeue_max_attempts":    "KAFKA_REQUEUE_MAX_ATTEMPTS",
+	"kafka.lag_check_seconds":       "KAFKA_LAG_CHECK_SECONDS",
+	"kafka.lag_alert_threshold":     "KAFKA_LAG_ALERT_THRESHOLD",
+	"kafka.tls_enabled":             "KAFKA_TLS_ENABLED",
+	"kafka.tls_ca_file":             "KAFKA_TLS_CA_FILE",
+	"kafka.tls_cert_file":           "KAFKA_TLS_CERT_FILE",
+	"kafka.tls_key_file":            "KAFKA_TLS_KEY_FILE",
+	"kafka.sasl_mechanism":          "KAFKA_SASL_MECHANISM",
+	"kafka.sasl_username":           "KAFKA_SASL_USERNAME",
+	"kafka.sasl_password":           "KAFKA_SASL_PASSWORD",
+
+	"auth.api_key_enabled":        "API_KEY_AUTH_ENABLED",
+	"auth.bootstrap_api_key":      "BOOTSTRAP_API_KEY",
+	"auth.api_key_usage_tracking": "API_KEY_USAGE_TRACKING",
+	"auth.api_key_daily_quota":    "API_KEY_DAILY_QUOTA",
+	"auth.default_tenant_id":      "DEFAULT_TENANT_ID",
+	"auth.jwt_issuer":             "JWT_ISSUER",
+	"auth.jwt_audience":           "JWT_AUDIENCE",
+	"auth.jwt_jwks_url":           "JWT_JWKS_URL",
+	"auth.jwt_roles_claim":        "JWT_ROLES_CLAIM",
+	"auth.jwt_tenant_claim":       "JWT_TENANT_CLAIM",
+
+	"webhooks.workers":         "WEBHOOK_WORKERS",
+	"webhooks.max_attempts":    "WEBHOOK_MAX_ATTEMPTS",
+	"webhooks.timeout_seconds": "WEBHOOK_TIMEOUT_SECONDS",
+	"webhooks.leader_election": "WEBHOOK_LEADER_ELECTION",
+
+	"twilio.auth_token":  "TWILIO_AUTH_TOKEN",
+	"twilio.webhook_url": "TWILIO_WEBHOOK_URL",
+	"twilio.tenant_id":   "TWILIO_TENANT_ID",
+
+	"smpp.host":                 "SMPP_HOST",
+	"smpp.port":                 "SMPP_PORT",
+	"smpp.system_id":            "SMPP_SYSTEM_ID",
+	"smpp.password":             "SMPP_PASSWORD",
+	"smpp.system_type":          "SMPP_SYSTEM_TYPE",
+	"smpp.bind_type":            "SMPP_BIND_TYPE",
+	"smpp.tls_enabled":          "SMPP_TLS_ENABLED",
+	"smpp.tls_ca_file":          "SMPP_TLS_CA_FILE",
+	"smpp.default_alphabet":     "SMPP_DEFAULT_ALPHABET",
+	"smpp.enquire_link_seconds": "SMPP_ENQUIRE_LINK_SECONDS",
+	"smpp.tenant_id":            "SMPP_TENANT_ID",
+	"smpp.batch_size":           "SMPP_BATCH_SIZE",
+	"smpp.batch_wait_ms":        "SMPP_BATCH_WAIT_MS",
+
+	"tracing.enabled":      "TRACING_ENABLED",
+	"tracing.service_name": "OTEL_SERVICE_NAME",
+	"tracing.sample_ratio": "TRACING_SAMPLE_RATIO",
+
+	"archive.enabled":          "ARCHIVE_ENABLED",
+	"archive.interval_minutes": "ARCHIVE_INTERVAL_MINUTES",
+	"archive.batch_size":       "ARCHIVE_BATCH_SIZE",
+	"archive.max_age_days":     "ARCHIVE_MAX_AGE_DAYS",
+	"archive.s3_bucket":        "ARCHIVE_S3_BUCKET",
+	"archive.s3_prefix":        "ARCHIVE_S3_PREFIX",
+	"archive.s3_endpoint":      "ARCHIVE_S3_ENDPOINT",
+
+	"quota.enabled":               "QUOTA_ENABLED",
+	"quota.max_messages_per_user": "QUOTA_MAX_MESSAGES_PER_USER",
+	"quota.max_bytes_per_user":    "QUOTA_MAX_BYTES_PER_USER",
+	"quota.action":                "QUOTA_ACTION",
+	"quota.warning_percent":       "QUOTA_WARNING_PERCENT",
+	"quota.enforce_minutes":       "QUOTA_ENFORCE_MINUTES",
+	"quota.reconcile_hours":       "QUOTA_RECONCILE_HOURS",
+	"quota.batch_size":            "QUOTA_BATCH_SIZE",
+
+	"export.enabled":       "EXPORT_ENABLED",
+	"export.job":           "EXPORT_JOB",
+	"export.schedule":      "EXPORT_SCHEDULE",
+	"export.format":        "EXPORT_FORMAT",
+	"export.destination":   "EXPORT_DESTINATION",
+	"export.s3_endpoint":   "EXPORT_S3_ENDPOINT",
+	"export.rows_per_file": "EXPORT_ROWS_PER_FILE",
+
+	"secrets.vault_addr":      "VAULT_ADDR",
+	"secrets.vault_namespace": "VAULT_NAMESPACE",
+	"secrets.vault_token":     "VAULT_TOKEN",
+	"secrets.refresh_minutes": "SECRETS_REFRESH_MINUTES",
+
+	"encryption.keys":          "ENCRYPTION_KEYS",
+	"encryption.active_key_id": "ENCRYPTION_ACTIVE_KEY_ID",
+	"encryption.phone_numbers": "ENCRYPT_PHONE_NUMBERS",
+
+	"pseudonyms.enabled": "PSEUDONYMIZE_PHONE_NUMBERS",
+	"pseudonyms.pepper":  "PSEUDONYM_PEPPER",
+
+	"phone_numbers.normalize":      "NORMALIZE_PHONE_NUMBERS",
+	"phone_numbers.default_region": "PHONE_DEFAULT_REGION",
+}
+
+// readFile reads a YAML or JSON config file of sections of settings, returning
+// each setting's value by the environment variable it stands in for. Lists are
+// joined with commas, as in the environment. Unknown sections and keys, and
+// values that are not scalars or lists of scalars, are reported as problems
+func readFile(path string) (map[string]string, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// JSON is valid YAML, so one parser reads both
+	var sections map[string]map[string]any
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	var problems []string
+	for _, section := range slices.Sorted(maps.Keys(sections)) {
+		for _, key := range slices.Sorted(maps.Keys(sections[section])) {
+			name := section + "." + key
+			envKey, ok := fileKeys[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: unknown setting %s", path, name))
+				continue
+			}
+			value, ok := scalarString(sections[section][key])
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: %s must be a string, number, boolean or list of those", path, name))
+				continue
+			}
+			values[envKey] = value
+		}
+	}
+	return values, problems, nil
+}
+
+// scalarString formats a YAML scalar, or a list of them joined with commas
+func scalarString(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int, int64, uint64, float64:
+		return fmt.Sprint(v), true
+	case []any:
+		items := make([]string, len(v))
+		for n, item := range v {
+			if _, nested := item.([]any); nested {
+				return "", false
+			}
+			s, ok := scalarString(item)
+			if !ok || strings.Contains(s, ",") {
+				return "", false
+			}
+			items[n] = s
+		}
+		return strings.Join(items, ","), true
+	}
+	return "", false
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeStreamTokensCollection stores the resume token of each record watcher
+const ChangeStreamTokensCollection = "change_stream_tokens"
+
+// Operation types of a ChangeEvent
+const (
+	OperationInsert = "insert"
+	OperationUpdate = "update"
+)
+
+// changeStreamRetryDelay is how long a watcher waits before reopening a failed stream
+const changeStreamRetryDelay = 5 * time.Second
+
+// codeChangeStreamHistoryLost means the resume token is no longer in the oplog
+const codeChangeStreamHistoryLost = 286
+
+// ChangeEvent is a stored record, or a status change of one, seen on the change stream
+type ChangeEvent struct {
+	OperationType string
+	Record        *models.SMSRecord // the full document after the change
+}
+
+// RecordWatcher follows inserts into sms_records and status changes of stored
+// records, in either tier, with a change stream on the database. The resume token is saved after each batch of
+// changes is handled, so a restarted watcher carries on from where it stopped
+// and changes are handled at least once. Change streams need a replica set or
+// sharded cluster
+type RecordWatcher struct {
+	db     *Mongo
+	name   string
+	handle func(ctx context.Context, event ChangeEvent)
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRecordWatcher creates a watcher of m calling handle for every change,
+// whose resume token is saved under name
+func (m *Mongo) NewRecordWatcher(name string, handle func(ctx context.Context, event ChangeEvent)) *RecordWatcher {
+	return &RecordWatcher{
+		db:     m,
+		name:   name,
+		handle: handle,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start follows the change stream in a background goroutine, reopening it after failures
+func (w *RecordWatcher) Start() {
+	slog.Info("Starting change stream watcher", "watcher", w.name)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	go func() {
+		defer close(w.done)
+		for {
+			err := w.watch(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Change stream failed, reopening", "watcher", w.name, "delay", changeStreamRetryDelay, "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(changeStreamRetryDelay):
+			}
+		}
+	}()
+}
+
+// Stop closes the change stream and waits for the change being handled
+func (w *RecordWatcher) Stop() {
+	slog.Info("Stopping change stream watcher", "watcher", w.name)
+	w.cancel()
+	<-w.done
+}
+
+// watch opens the change stream after the saved resume token and handles changes until it fails
+func (w *RecordWatcher) watch(ctx context.Context) error {
+	token, err := w.loadToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Updates that don't change the status (e.g. clearing the outbox flag) are not
+	// of interest, nor inserts into the cold tier, which are messages being moved
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"$or": bson.A{
+		bson.M{"operationType": OperationInsert, "ns.coll": SMSRecordsCollection},
+		bson.M{
+			"operationType":                          OperationUpdate,
+			"ns.coll":                                bson.M{"$in": bson.A{SMSRecordsCollection, ColdRecordsCollection}},
+			"updateDescription.updatedFields.status": bson.M{"$exists": true},
+		},
+	}}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if token != nil {
+		opts.SetStartAfter(token)
+	}
+
+	stream, err := w.db.Database().Watch(ctx, pipeline, opts)
+	var cmdErr mongo.CommandError
+	if token != nil && errors.As(err, &cmdErr) && cmdErr.Code == codeChangeStreamHistoryLost {
+		// Changes since the token were lost; carry on from now rather than never recovering
+		slog.Warn("Change stream resume token expired, changes since it were missed", "watcher", w.name)
+		stream, err = w.db.Database().Watch(ctx, pipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open change stream: %w", err)
+	}
+	defer stream.Close(context.Background())
+
+	slog.Info("Change stream opened", "watcher", w.name, "resumed", token != nil)
+	for stream.Next(ctx) {
+		var change struct {
+			OperationType string            `bson:"operationType"`
+			FullDocument  *models.SMSRecord `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			return fmt.Errorf("failed to decode change: %w", err)
+		}
+		// An update's document is looked up afterwards, and is gone if the record was deleted since
+		if change.FullDocument != nil {
+			w.handle(ctx, ChangeEvent{OperationType: change.OperationType, Record: change.FullDocument})
+		}
+
+		if stream.RemainingBatchLength() == 0 {
+			if err := w.saveToken(ctx, stream.ResumeToken()); err != nil {
+				return err
+			}
+		}
+	}
+	return stream.Err()
+}
+
+// loadToken returns the watcher's saved resume token, or nil to start from now
+func (w *RecordWatcher) loadToken(ctx context.Context) (bson.Raw, error) {
+	var saved struct {
+		Token bson.Raw `bson:"resume_token"`
+	}
+	err := w.db.Database().Collection(ChangeStreamTokensCollection).FindOne(ctx, bson.M{"_id": w.name}).Decode(&saved)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load change stream resume token: %w", err)
+	}
+	return saved.Token, nil
+}
+
+// saveToken records the resume token of the last handled change
+func (w *RecordWatcher) saveToken(ctx context.Context, token bson.Raw) error {
+	update := bson.M{"$set": bson.M{"resume_token": token, "updated_at": time.Now().UTC()}}
+	_, err := w.db.Database().Collection(ChangeStreamTokensCollection).UpdateByID(ctx, w.name, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save change stream resume token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// SetConsistency chooses the read preference of API queries (a mode such as
+// "secondaryPreferred") and the write concern of ingestion writes ("majority"
+// or a number of acknowledging members). Every other operation keeps the
+// client's defaults
+func (m *Mongo) SetConsistency(readPreference, writeConcern string) error {
+	mode, err := readpref.ModeFromString(readPreference)
+	if err != nil {
+		return fmt.Errorf("invalid read preference %q: %w", readPreference, err)
+	}
+	pref, err := readpref.New(mode)
+	if err != nil {
+		return fmt.Errorf("invalid read preference %q: %w", readPreference, err)
+	}
+
+	wc := writeconcern.Majority()
+	if writeConcern != "majority" {
+		w, err := strconv.Atoi(writeConcern)
+		if err != nil || w < 0 {
+			return fmt.Errorf("invalid write concern %q: must be majority or a number of members", writeConcern)
+		}
+		wc = &writeconcern.WriteConcern{W: w}
+	}
+
+	m.queryReadPreference, m.ingestWriteConcern = pref, wc
+	return nil
+}
+
+// GetQueryCollection returns the sms_records collection with the read preference of API queries
+// Reads from secondaries may briefly miss the latest writes
+func (m *Mongo) GetQueryCollection() *mongo.Collection {
+	return m.Database().Collection(SMSRecordsCollection, options.Collection().SetReadPreference(m.queryReadPreference))
+}
+
+// GetIngestCollection returns the sms_records collection with the write concern of ingestion
+func (m *Mongo) GetIngestCollection() *mongo.Collection {
+	return m.Database().Collection(SMSRecordsCollection, options.Collection().SetWriteConcern(m.ingestWriteConcern))
+}
+
+// GetColdQueryCollection returns the sms_records_cold collection with the read preference of API queries
+func (m *Mongo) GetColdQueryCollection() *mongo.Collection {
+	return m.Database().Collection(ColdRecordsCollection, options.Collection().SetReadPreference(m.queryReadPreference))
+}
+
+// GetColdIngestCollection returns the sms_records_cold collection with the write
+// concern of ingestion, for status updates of cold messages
+func (m *Mongo) GetColdIngestCollection() *mongo.Collection {
+	return m.Database().Collection(ColdRecordsCollection, options.Collection().SetWriteConcern(m.ingestWriteConcern))
+}
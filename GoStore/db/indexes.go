@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StoredEventIndexName is the index the stored-events relay polls for unpublished records
+const StoredEventIndexName = "idx_stored_event_pending"
+
+// UserMessagesIndexName is the index of each user's messages by time
+const UserMessagesIndexName = "idx_tenant_id_user_id_created_at"
+
+// requiredIndexes are the indexes each collection's queries rely on, created by EnsureIndexes
+// The unique message_id index and the TTL index are reconciled separately, by
+// EnsureUniqueMessageIDIndex and EnsureRetentionPolicy, and indexes added since
+// migrations were introduced are created by migrations
+var requiredIndexes = map[string][]mongo.IndexModel{
+	SMSRecordsCollection: {
+		// Recent messages across users, and archival and retention sweeps by age
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_created_at"),
+		},
+		// Tenant-scoped user queries sorted by time; its prefix serves tenant-only lookups
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName(UserMessagesIndexName),
+		},
+		// User queries sorted by status or by sender
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_tenant_id_user_id_status_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "phone_number", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_tenant_id_user_id_phone_number_created_at"),
+		},
+		// User queries filtered by message metadata. Carrier, country and sender are
+		// only set by some producers, so only records having them are indexed
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "direction", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_tenant_id_user_id_direction_created_at"),
+		},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "carrier", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().
+				SetName("idx_tenant_id_user_id_carrier_created_at").
+				SetPartialFilterExpression(bson.M{"carrier": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "country_code", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().
+				SetName("idx_tenant_id_user_id_country_code_created_at").
+				SetPartialFilterExpression(bson.M{"country_code": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "sender_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().
+				SetName("idx_tenant_id_user_id_sender_id_created_at").
+				SetPartialFilterExpression(bson.M{"sender_id": bson.M{"$exists": true}}),
+		},
+		// User queries filtered by the detected language of the message
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "language", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().
+				SetName("idx_tenant_id_user_id_language_created_at").
+				SetPartialFilterExpression(bson.M{"language": bson.M{"$exists": true}}),
+		},
+		// Flagged messages of a user; few messages are flagged, so only those are indexed
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "flags", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().
+				SetName("idx_tenant_id_user_id_flags_created_at").
+				SetPartialFilterExpression(bson.M{"flags": bson.M{"$exists": true}}),
+		},
+		// Support lookups by the phone number messages were sent to or received from
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "phone_number", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_tenant_id_phone_number_created_at"),
+		},
+		// Matching carrier delivery receipts
+		{
+			Keys:    bson.D{{Key: "provider_message_id", Value: 1}},
+			Options: options.Index().SetName("idx_provider_message_id").SetSparse(true),
+		},
+		// Records whose stored event is not published yet; only those are indexed, so it stays small
+		{
+			Keys: bson.D{{Key: "stored_event_pending", Value: 1}, {Key: "_id", Value: 1}},
+			Options: options.Index().
+				SetName(StoredEventIndexName).
+				SetPartialFilterExpression(bson.M{"stored_event_pending": true}),
+		},
+	},
+	WebhooksCollection: {
+		// Subscriptions are looked up per tenant and user on every event
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("idx_tenant_id_user_id"),
+		},
+	},
+	AuditLogCollection: {
+		// The audit trail of a tenant, newest first; filters by actor, user and action are applied to it
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_tenant_id_created_at"),
+		},
+	},
+	APIKeysCollection: {
+		// Keys are looked up by hash on every authenticated request
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetName("idx_key_hash").SetUnique(true),
+		},
+	},
+	APIKeyUsageCollection: {
+		// A key's usage by day, for the admin usage endpoint
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "key_id", Value: 1}, {Key: "day", Value: -1}},
+			Options: options.Index().SetName("idx_tenant_id_key_id_day"),
+		},
+	},
+	RetentionPoliciesCollection: {
+		// One policy per tenant
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}},
+			Options: options.Index().SetName("idx_tenant_id").SetUnique(true),
+		},
+	},
+	ExportRunsCollection: {
+		// A job's runs, newest first, and its last successful run
+		{
+			Keys:    bson.D{{Key: "job", Value: 1}, {Key: "status", Value: 1}, {Key: "started_at", Value: -1}},
+			Options: options.Index().SetName("idx_job_status_started_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "job", Value: 1}, {Key: "started_at", Value: -1}},
+			Options: options.Index().SetName("idx_job_started_at"),
+		},
+	},
+}
+
+// EnsureIndexes creates every index the service relies on, so a fresh database
+// needs no initialization script. Existing identical indexes are left as they
+// are. An index whose name is taken by one with other keys or options is
+// reported and skipped, so the rest are still created
+func (m *Mongo) EnsureIndexes() error {
+	slog.Info("Ensuring MongoDB indexes")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(requiredIndexes)) {
+		indexes := m.Database().Collection(name).Indexes()
+		for _, model := range requiredIndexes[name] {
+			if _, err := indexes.CreateOne(ctx, model); err != nil {
+				errs = append(errs, fmt.Errorf("failed to create index %s.%s: %w", name, *model.Options.Name, err))
+			}
+		}
+	}
+
+	// Make ingestion idempotent; without the unique index redelivered messages are stored twice
+	if err := m.EnsureUniqueMessageIDIndex(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	slog.Info("MongoDB indexes ensured")
+	return nil
+}
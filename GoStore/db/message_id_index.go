@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MessageIDIndexName is the unique index that makes ingestion idempotent per tenant and message_id
+const MessageIDIndexName = "idx_message_id"
+
+// messageIDIndex is the unique index on each tier. message_id leads, so status
+// updates, which only carry a message_id, still use it; tenant_id follows, so two
+// tenants' messages with the same message_id are both stored
+var messageIDIndex = mongo.IndexModel{
+	Keys: bson.D{{Key: "message_id", Value: 1}, {Key: "tenant_id", Value: 1}},
+	Options: options.Index().
+		SetName(MessageIDIndexName).
+		SetUnique(true).
+		SetPartialFilterExpression(bson.M{"message_id": bson.M{"$exists": true}}),
+}
+
+// EnsureUniqueMessageIDIndex upgrades the message_id index of both tiers to a unique
+// one on tenant_id and message_id, so a redelivered Kafka message fails with a duplicate
+// key error instead of being stored twice. Records without a message_id are excluded
+// from the index. The cold tier is left to its migration until that has created it
+func (m *Mongo) EnsureUniqueMessageIDIndex() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if err := ensureUniqueMessageIDIndex(ctx, m.GetCollection()); err != nil {
+		return err
+	}
+	cold, err := m.ColdTierExists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up the cold tier: %w", err)
+	}
+	if cold {
+		return ensureUniqueMessageIDIndex(ctx, m.GetColdCollection())
+	}
+	return nil
+}
+
+// ensureUniqueMessageIDIndex replaces the message_id index of collection unless it
+// is already the unique one on tenant_id and message_id
+func ensureUniqueMessageIDIndex(ctx context.Context, collection *mongo.Collection) error {
+	current, exists, err := messageIDIndexIsCurrent(ctx, collection)
+	if err != nil {
+		return err
+	}
+	if current {
+		slog.Info("Unique message_id index verified", "collection", collection.Name())
+		return nil
+	}
+
+	// Deployments initialized before deduplication have a non-unique index of the same
+	// name, and those initialized before tenants were keyed a unique one on message_id alone
+	if exists {
+		if _, err := collection.Indexes().DropOne(ctx, MessageIDIndexName); err != nil {
+			return fmt.Errorf("failed to drop %s message_id index: %w", collection.Name(), err)
+		}
+	}
+
+	if _, err := collection.Indexes().CreateOne(ctx, messageIDIndex); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			// Put back a plain index so status updates stay fast until duplicates are removed
+			plain := mongo.IndexModel{Keys: messageIDIndex.Keys, Options: options.Index().SetName(MessageIDIndexName)}
+			if _, restoreErr := collection.Indexes().CreateOne(ctx, plain); restoreErr != nil {
+				slog.Error("Failed to restore message_id index", "collection", collection.Name(), "error", restoreErr)
+			}
+			return fmt.Errorf("existing %s records have duplicate message_id values, remove them and restart: %w", collection.Name(), err)
+		}
+		return fmt.Errorf("failed to create unique %s message_id index: %w", collection.Name(), err)
+	}
+
+	slog.Info("Unique message_id index applied", "collection", collection.Name())
+	return nil
+}
+
+// messageIDIndexIsCurrent reports whether the message_id index exists, and whether
+// it is unique on tenant_id and message_id
+func messageIDIndexIsCurrent(ctx context.Context, collection *mongo.Collection) (current, exists bool, err error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to list %s indexes: %w", collection.Name(), err)
+	}
+
+	var indexes []struct {
+		Name   string `bson:"name"`
+		Unique bool   `bson:"unique"`
+		Key    bson.D `bson:"key"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return false, false, fmt.Errorf("failed to decode %s indexes: %w", collection.Name(), err)
+	}
+
+	for _, idx := range indexes {
+		if idx.Name == MessageIDIndexName {
+			return idx.Unique && sameKeys(idx.Key, messageIDIndex.Keys.(bson.D)), true, nil
+		}
+	}
+	return false, false, nil
+}
+
+// sameKeys reports whether an index lists the same fields as want, in the same order
+func sameKeys(keys, want bson.D) bool {
+	if len(keys) != len(want) {
+		return false
+	}
+	for i := range keys {
+		if keys[i].Key != want[i].Key {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,421 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationsCollection records the migrations applied to the database, one
+// document per version, plus the lock held while migrations run
+const MigrationsCollection = "migrations"
+
+const (
+	// migrationLockID is the _id of the lock document, which never collides with a version
+	migrationLockID = "lock"
+	// migrationLockTTL is how old a lock must be before it is taken as abandoned by
+	// a process that died while migrating
+	migrationLockTTL = 30 * time.Minute
+)
+
+// ErrIrreversible is returned when reverting a migration that has no Down step
+var ErrIrreversible = errors.New("migration cannot be reverted")
+
+// MigrationEnv is what migrations may depend on besides the database
+type MigrationEnv struct {
+	DefaultTenantID string
+}
+
+// Migration is a versioned change to the collections, indexes or data of the
+// database. Up and Down must leave the database as it was if they fail, or be
+// safe to run again
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, m *Mongo, env MigrationEnv) error
+	Down        func(ctx context.Context, m *Mongo, env MigrationEnv) error // nil when irreversible
+}
+
+// MigrationState is a known migration and when it was applied; AppliedAt is
+// zero while it is pending
+type MigrationState struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	AppliedAt   time.Time `json:"applied_at,omitzero"`
+}
+
+// appliedMigration is the document recording an applied migration
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// migrations are every migration, in version order. Append new ones with the
+// next version; never renumber or remove one that has been released
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Assign the default tenant to records stored before multi-tenancy",
+		Up: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			backfilled, err := m.backfillTenantID(ctx, env.DefaultTenantID)
+			if backfilled > 0 {
+				slog.Info("Assigned default tenant to existing records", "tenant_id", env.DefaultTenantID, "count", backfilled)
+			}
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "Index sms_records by tenant and age for retention sweeps",
+		Up: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			_, err := m.GetCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("idx_tenant_id_created_at"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			_, err := m.GetCollection().Indexes().DropOne(ctx, "idx_tenant_id_created_at")
+			return ignoreNotFound(err)
+		},
+	},
+	{
+		Version:     3,
+		Description: "Index quarantined messages by source offset and age",
+		Up: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			_, err := m.GetQuarantineCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+				// A message is quarantined once, however often its batch is retried
+				{
+					Keys:    bson.D{{Key: "group_id", Value: 1}, {Key: "topic", Value: 1}, {Key: "partition", Value: 1}, {Key: "offset", Value: 1}},
+					Options: options.Index().SetName("idx_group_id_topic_partition_offset").SetUnique(true),
+				},
+				{
+					Keys:    bson.D{{Key: "quarantined_at", Value: -1}},
+					Options: options.Index().SetName("idx_quarantined_at"),
+				},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			for _, name := range []string{"idx_group_id_topic_partition_offset", "idx_quarantined_at"} {
+				if _, err := m.GetQuarantineCollection().Indexes().DropOne(ctx, name); ignoreNotFound(err) != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     4,
+		Description: "Create the zstd-compressed sms_records_cold collection for the cold tier",
+		Up: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			return m.createColdCollection(ctx)
+		},
+		Down: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			count, err := m.GetColdCollection().EstimatedDocumentCount(ctx)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%s holds %d messages; move them back to %s first", ColdRecordsCollection, count, SMSRecordsCollection)
+			}
+			if err := m.GetColdCollection().Drop(ctx); err != nil {
+				return err
+			}
+			return m.Database().Collection(TieringCollection).Drop(ctx)
+		},
+	},
+	{
+		Version:     5,
+		Description: "Index user storage usage by size",
+		Up: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			_, err := m.GetUserUsageCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+				// The largest users, of a tenant or of every tenant
+				{
+					Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "bytes", Value: -1}},
+					Options: options.Index().SetName("idx_tenant_id_bytes"),
+				},
+				{
+					Keys:    bson.D{{Key: "bytes", Value: -1}},
+					Options: options.Index().SetName("idx_bytes"),
+				},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			for _, name := range []string{"idx_tenant_id_bytes", "idx_bytes"} {
+				if _, err := m.GetUserUsageCollection().Indexes().DropOne(ctx, name); ignoreNotFound(err) != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     6,
+		Description: "Index registered senders and the messages stored from them",
+		Up: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			// A sender ID is registered once per tenant, and resolved by it on ingest
+			_, err := m.GetSendersCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "sender_id", Value: 1}},
+				Options: options.Index().SetName("idx_tenant_id_sender_id").SetUnique(true),
+			})
+			if err != nil {
+				return err
+			}
+			// Messages of a user from registered senders; only those are indexed
+			_, err = m.GetCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "sender_type", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().
+					SetName("idx_tenant_id_user_id_sender_type_created_at").
+					SetPartialFilterExpression(bson.M{"sender_type": bson.M{"$exists": true}}),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			if _, err := m.GetCollection().Indexes().DropOne(ctx, "idx_tenant_id_user_id_sender_type_created_at"); ignoreNotFound(err) != nil {
+				return err
+			}
+			_, err := m.GetSendersCollection().Indexes().DropOne(ctx, "idx_tenant_id_sender_id")
+			return ignoreNotFound(err)
+		},
+	},
+	{
+		Version:     7,
+		Description: "Index blocklist entries by tenant, kind and value",
+		Up: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			// A value is blocked once per tenant, and a tenant's blocklist is read whole
+			_, err := m.GetBlocklistCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "kind", Value: 1}, {Key: "value", Value: 1}},
+				Options: options.Index().SetName("idx_tenant_id_kind_value").SetUnique(true),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			_, err := m.GetBlocklistCollection().Indexes().DropOne(ctx, "idx_tenant_id_kind_value")
+			return ignoreNotFound(err)
+		},
+	},
+	{
+		Version:     8,
+		Description: "Index the webhook delivery log by subscription",
+		Up: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			// The recent attempts of a subscription, newest first, and its whole log on delete
+			_, err := m.GetWebhookDeliveriesCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("idx_subscription_id_created_at"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			_, err := m.GetWebhookDeliveriesCollection().Indexes().DropOne(ctx, "idx_subscription_id_created_at")
+			return ignoreNotFound(err)
+		},
+	},
+	{
+		Version:     9,
+		Description: "Index sms_records by tenant, country and dial code for regional reporting",
+		Up: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			// Tenant-wide analytics of a country or dial code; only records having them are indexed
+			_, err := m.GetCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "country_code", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().
+						SetName("idx_tenant_id_country_code_created_at").
+						SetPartialFilterExpression(bson.M{"country_code": bson.M{"$exists": true}}),
+				},
+				{
+					Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "dial_code", Value: 1}, {Key: "created_at", Value: -1}},
+					Options: options.Index().
+						SetName("idx_tenant_id_dial_code_created_at").
+						SetPartialFilterExpression(bson.M{"dial_code": bson.M{"$exists": true}}),
+				},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, m *Mongo, env MigrationEnv) error {
+			for _, name := range []string{"idx_tenant_id_country_code_created_at", "idx_tenant_id_dial_code_created_at"} {
+				if _, err := m.GetCollection().Indexes().DropOne(ctx, name); ignoreNotFound(err) != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// MigrationStatus returns every known migration and when each was applied,
+// in version order
+func (m *Mongo) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, len(migrations))
+	for i, migration := range migrations {
+		states[i] = MigrationState{Version: migration.Version, Description: migration.Description, AppliedAt: applied[migration.Version]}
+	}
+	return states, nil
+}
+
+// PendingMigrations returns the number of known migrations not yet applied
+func (m *Mongo) PendingMigrations(ctx context.Context) (int, error) {
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// MigrateUp applies the pending migrations up to version target, or all of them
+// when target is zero, in version order. It returns the versions applied; the
+// first failure stops it, leaving the later ones pending
+func (m *Mongo) MigrateUp(ctx context.Context, env MigrationEnv, target int) ([]int, error) {
+	unlock, err := m.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []int
+	for _, migration := range migrations {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := migration.Up(ctx, m, env); err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		record := appliedMigration{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now().UTC()}
+		if _, err := m.Database().Collection(MigrationsCollection).InsertOne(ctx, record); err != nil {
+			return done, fmt.Errorf("migration %d was applied but could not be recorded: %w", migration.Version, err)
+		}
+		slog.Info("Applied MongoDB migration", "version", migration.Version, "description", migration.Description)
+		done = append(done, migration.Version)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and
+// returns the versions reverted. A migration without a Down step stops it with
+// ErrIrreversible
+func (m *Mongo) MigrateDown(ctx context.Context, env MigrationEnv, steps int) ([]int, error) {
+	unlock, err := m.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []int
+	for _, migration := range slices.Backward(migrations) {
+		if len(done) == steps {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == nil {
+			return done, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, ErrIrreversible)
+		}
+		if err := migration.Down(ctx, m, env); err != nil {
+			return done, fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		if _, err := m.Database().Collection(MigrationsCollection).DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return done, fmt.Errorf("migration %d was reverted but is still recorded as applied: %w", migration.Version, err)
+		}
+		slog.Info("Reverted MongoDB migration", "version", migration.Version, "description", migration.Description)
+		done = append(done, migration.Version)
+	}
+	return done, nil
+}
+
+// appliedMigrations returns when each applied migration was applied, by version
+func (m *Mongo) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	cursor, err := m.Database().Collection(MigrationsCollection).Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var records []appliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode applied migrations: %w", err)
+	}
+	applied := make(map[int]time.Time, len(records))
+	for _, record := range records {
+		applied[record.Version] = record.AppliedAt
+	}
+	return applied, nil
+}
+
+// lockMigrations waits until no other process is migrating the database and
+// takes the lock, returning the function that releases it. A lock older than
+// migrationLockTTL is taken over
+func (m *Mongo) lockMigrations(ctx context.Context) (func(), error) {
+	collection := m.Database().Collection(MigrationsCollection)
+	owner, _ := os.Hostname()
+	for {
+		_, err := collection.InsertOne(ctx, bson.M{"_id": migrationLockID, "owner": owner, "locked_at": time.Now().UTC()})
+		if err == nil {
+			return func() {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if _, err := collection.DeleteOne(releaseCtx, bson.M{"_id": migrationLockID}); err != nil {
+					slog.Error("Failed to release the migration lock", "error", err)
+				}
+			}, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("failed to lock migrations: %w", err)
+		}
+
+		stale := bson.M{"_id": migrationLockID, "locked_at": bson.M{"$lt": time.Now().UTC().Add(-migrationLockTTL)}}
+		if result, err := collection.DeleteOne(ctx, stale); err == nil && result.DeletedCount > 0 {
+			slog.Warn("Took over an abandoned migration lock")
+			continue
+		}
+
+		slog.Info("Waiting for another process to finish migrating")
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for the migration lock: %w", ctx.Err())
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// ignoreNotFound treats a missing index or collection as already removed
+func ignoreNotFound(err error) error {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && (cmdErr.Code == codeNamespaceNotFound || cmdErr.Code == codeIndexNotFound) {
+		return nil
+	}
+	return err
+}
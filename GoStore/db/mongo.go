@@ -3,44 +3,149 @@ package db
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/tracing"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 const (
 	// Collection name in MongoDB
 	SMSRecordsCollection = "sms_records"
+	// AuditLogCollection stores audit records for compliance-relevant operations
+	AuditLogCollection = "audit_log"
+	// WebhooksCollection stores registered webhook subscriptions
+	WebhooksCollection = "webhooks"
+	// WebhookDeliveriesCollection stores the log of webhook delivery attempts
+	WebhookDeliveriesCollection = "webhook_deliveries"
+	// APIKeysCollection stores hashed API keys and their scopes
+	APIKeysCollection = "api_keys"
+	// APIKeyUsageCollection stores daily request and byte counts per API key
+	APIKeyUsageCollection = "api_key_usage"
+	// ExportRunsCollection stores the history of scheduled export runs
+	ExportRunsCollection = "export_runs"
+	// RetentionPoliciesCollection stores the retention policies of tenants overriding the default
+	RetentionPoliciesCollection = "retention_policies"
+	// LeasesCollection stores the leases keeping background jobs to one instance at a time
+	LeasesCollection = "leases"
+	// QuarantineCollection stores consumed messages that could not be decoded or failed validation
+	QuarantineCollection = "quarantine"
+	// ColdRecordsCollection stores the messages moved out of sms_records by the tiering job
+	ColdRecordsCollection = "sms_records_cold"
+	// TieringCollection stores the cold tier boundary of sms_records
+	TieringCollection = "tiering"
+	// UserUsageCollection stores how many messages each user has stored, for storage quotas
+	UserUsageCollection = "user_usage"
+	// SendersCollection stores the sender IDs registered by tenants
+	SendersCollection = "senders"
+	// BlocklistCollection stores the phone numbers, prefixes and countries tenants block messages from
+	BlocklistCollection = "blocklist"
 )
 
-var (
-	// Client is the MongoDB client instance
-	Client *mongo.Client
-	// Database is the SMS Store database
-	Database *mongo.Database
-)
+// Mongo is a connection to the SMS Store database and the settings of the
+// operations run on it. Everything reading or writing MongoDB is given the
+// Mongo it uses, so several can be open in one process, e.g. in tests
+type Mongo struct {
+	uri    string
+	dbName string
+
+	// current is the connection in use, replaced as a whole when the Supervisor reconnects
+	current atomic.Pointer[connection]
+
+	// Read preference of API queries and write concern of ingestion writes, set by SetConsistency
+	queryReadPreference *readpref.ReadPref
+	ingestWriteConcern  *writeconcern.WriteConcern
+
+	// queryTimeout limits the server time of the queries serving API requests
+	queryTimeout time.Duration
+
+	// slowQueryThreshold is the duration from which commands are logged with the
+	// shape of their filter; zero disables the log
+	slowQueryThreshold atomic.Int64
+
+	// coldBoundary caches the cold tier boundary
+	coldBoundary struct {
+		sync.Mutex
+		value  time.Time
+		readAt time.Time
+	}
+}
+
+// connection is a MongoDB client with the SMS Store database on it, replaced
+// as a whole when the Supervisor reconnects
+type connection struct {
+	client       *mongo.Client
+	database     *mongo.Database
+	topology     *topologyMonitor
+	transactions bool // the deployment is a replica set or sharded cluster
+}
+
+// Connect establishes a connection to the database dbName at uri. Queries
+// read from the primary, ingestion waits for a majority and API queries are
+// limited to 10 seconds until changed with SetConsistency and SetQueryTimeout
+func Connect(uri, dbName string) (*Mongo, error) {
+	slog.Info("Initializing MongoDB connection")
+
+	m := &Mongo{
+		uri:                 uri,
+		dbName:              dbName,
+		queryReadPreference: readpref.Primary(),
+		ingestWriteConcern:  writeconcern.Majority(),
+		queryTimeout:        10 * time.Second,
+	}
+	conn, err := m.connect()
+	if err != nil {
+		return nil, err
+	}
+	m.current.Store(conn)
+
+	slog.Info("Connected to MongoDB", "database", dbName, "transactions", m.SupportsTransactions())
+	return m, nil
+}
+
+// Client returns the MongoDB client in use
+func (m *Mongo) Client() *mongo.Client {
+	return m.current.Load().client
+}
 
-// InitMongoDB establishes connection to MongoDB with retry logic
-func InitMongoDB(uri, dbName string) error {
-	log.Println("Initializing MongoDB connection...")
+// Database returns the SMS Store database on the client in use. Callers
+// shouldn't keep it, since the client is replaced if MongoDB has to be reconnected
+func (m *Mongo) Database() *mongo.Database {
+	return m.current.Load().database
+}
 
+// connect creates a client for the URI and checks that it reaches the deployment
+func (m *Mongo) connect() (*connection, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	// Set client options
-	clientOptions := options.Client().ApplyURI(uri).
+	// Reads and writes interrupted by a failover are retried once against the
+	// new primary. Set before the URI, so retryReads/retryWrites there still apply
+	topology := &topologyMonitor{}
+	clientOptions := options.Client().
+		SetRetryReads(true).
+		SetRetryWrites(true).
+		ApplyURI(m.uri).
 		SetMaxPoolSize(50).
 		SetMinPoolSize(10).
 		SetMaxConnIdleTime(30 * time.Second).
-		SetServerSelectionTimeout(10 * time.Second)
+		SetServerSelectionTimeout(10 * time.Second).
+		SetMonitor(m.commandMonitor()).
+		SetServerMonitor(topology.serverMonitor())
 
 	// Connect to MongoDB
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
 	}
 
 	// Ping the database to verify connection
@@ -48,107 +153,182 @@ func InitMongoDB(uri, dbName string) error {
 	defer pingCancel()
 
 	if err := client.Ping(pingCtx, nil); err != nil {
-		return fmt.Errorf("failed to ping MongoDB: %w", err)
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+	return &connection{
+		client:       client,
+		database:     client.Database(m.dbName),
+		topology:     topology,
+		transactions: detectTransactions(pingCtx, client),
+	}, nil
+}
+
+// commandMonitor records the duration of every MongoDB command the driver runs
+// by command and collection, logs those slower than the slow query threshold,
+// and traces each as a child span of the calling operation
+func (m *Mongo) commandMonitor() *event.CommandMonitor {
+	tracer := tracing.NewMongoTracer()
+	var started sync.Map // request ID -> startedCommand
+	finished := func(requestID int64) startedCommand {
+		cmd, _ := started.LoadAndDelete(requestID)
+		c, _ := cmd.(startedCommand)
+		return c
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			started.Store(e.RequestID, newStartedCommand(e, m.SlowQueryThreshold()))
+			tracer.Started(ctx, e)
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			cmd := finished(e.RequestID)
+			metrics.ObserveMongoCommand(e.CommandName, cmd.collection, true, e.Duration)
+			logSlowCommand(ctx, e.CommandName, cmd, e.Duration, m.SlowQueryThreshold())
+			tracer.Succeeded(ctx, e)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			cmd := finished(e.RequestID)
+			metrics.ObserveMongoCommand(e.CommandName, cmd.collection, false, e.Duration)
+			tracer.Failed(ctx, e)
+			// ctx is the caller's, so this line carries its request ID
+			slog.WarnContext(ctx, "MongoDB command failed", "command", e.CommandName, "duration", e.Duration, "error", e.Failure)
+		},
 	}
+}
 
-	Client = client
-	Database = client.Database(dbName)
+// backfillTenantID assigns tenantID to records stored before multi-tenancy was introduced
+// so they stay reachable through tenant-scoped queries. Returns the number of records updated.
+func (m *Mongo) backfillTenantID(ctx context.Context, tenantID string) (int64, error) {
+	filter := bson.M{"tenant_id": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"tenant_id": tenantID}}
 
-	log.Printf("Successfully connected to MongoDB database: %s", dbName)
-	return nil
+	var total int64
+	for _, name := range []string{SMSRecordsCollection, AuditLogCollection, WebhooksCollection} {
+		result, err := m.Database().Collection(name).UpdateMany(ctx, filter, update)
+		if err != nil {
+			return total, fmt.Errorf("failed to backfill tenant_id on %s: %w", name, err)
+		}
+		total += result.ModifiedCount
+	}
+	return total, nil
 }
 
-// ValidateIndexes verifies that indexes exist on the sms_records collection
-// Indexes are created by MongoDB initialization script on first startup
-func ValidateIndexes() error {
-	log.Println("Verifying MongoDB indexes...")
+// GetCollection returns the sms_records collection
+func (m *Mongo) GetCollection() *mongo.Collection {
+	return m.Database().Collection(SMSRecordsCollection)
+}
 
-	collection := Database.Collection(SMSRecordsCollection)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// GetAuditCollection returns the audit_log collection
+func (m *Mongo) GetAuditCollection() *mongo.Collection {
+	return m.Database().Collection(AuditLogCollection)
+}
 
-	// List existing indexes to verify setup
-	indexView := collection.Indexes()
-	cursor, err := indexView.List(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to list indexes: %w", err)
-	}
+// GetWebhooksCollection returns the webhooks collection
+func (m *Mongo) GetWebhooksCollection() *mongo.Collection {
+	return m.Database().Collection(WebhooksCollection)
+}
 
-	var existingIndexes []bson.M
-	if err = cursor.All(ctx, &existingIndexes); err != nil {
-		return fmt.Errorf("failed to decode indexes: %w", err)
-	}
+// GetWebhookDeliveriesCollection returns the webhook_deliveries collection
+func (m *Mongo) GetWebhookDeliveriesCollection() *mongo.Collection {
+	return m.Database().Collection(WebhookDeliveriesCollection)
+}
 
-	// Verify expected indexes exist
-	expectedIndexes := map[string]bool{
-		"_id_":                     false,
-		"idx_user_id":              false,
-		"idx_created_at":           false,
-		"idx_user_id_created_at":   false,
-	}
+// GetAPIKeysCollection returns the api_keys collection
+func (m *Mongo) GetAPIKeysCollection() *mongo.Collection {
+	return m.Database().Collection(APIKeysCollection)
+}
 
-	for _, idx := range existingIndexes {
-		indexName := idx["name"].(string)
-		if _, expected := expectedIndexes[indexName]; expected {
-			expectedIndexes[indexName] = true
-			log.Printf("✓ Index verified: %s", indexName)
-		}
-	}
+// GetAPIKeyUsageCollection returns the api_key_usage collection
+func (m *Mongo) GetAPIKeyUsageCollection() *mongo.Collection {
+	return m.Database().Collection(APIKeyUsageCollection)
+}
 
-	// Check if any expected indexes are missing
-	missingIndexes := []string{}
-	for indexName, found := range expectedIndexes {
-		if !found && indexName != "_id_" {
-			missingIndexes = append(missingIndexes, indexName)
-		}
-	}
+// GetRetentionPoliciesCollection returns the retention_policies collection
+func (m *Mongo) GetRetentionPoliciesCollection() *mongo.Collection {
+	return m.Database().Collection(RetentionPoliciesCollection)
+}
 
-	if len(missingIndexes) > 0 {
-		log.Printf("WARNING: Missing indexes: %v", missingIndexes)
-		log.Printf("Indexes should be created by MongoDB initialization script")
-		// Don't fail - service can still work, just slower
-	} else {
-		log.Printf("✓ All indexes verified successfully (%d total)", len(existingIndexes))
-	}
+// GetExportRunsCollection returns the export_runs collection
+func (m *Mongo) GetExportRunsCollection() *mongo.Collection {
+	return m.Database().Collection(ExportRunsCollection)
+}
 
-	return nil
+// GetLeasesCollection returns the leases collection
+func (m *Mongo) GetLeasesCollection() *mongo.Collection {
+	return m.Database().Collection(LeasesCollection)
 }
 
-// GetCollection returns the sms_records collection
-func GetCollection() *mongo.Collection {
-	return Database.Collection(SMSRecordsCollection)
+// GetColdCollection returns the sms_records_cold collection
+func (m *Mongo) GetColdCollection() *mongo.Collection {
+	return m.Database().Collection(ColdRecordsCollection)
+}
+
+// GetUserUsageCollection returns the user_usage collection
+func (m *Mongo) GetUserUsageCollection() *mongo.Collection {
+	return m.Database().Collection(UserUsageCollection)
+}
+
+// GetSendersCollection returns the senders collection
+func (m *Mongo) GetSendersCollection() *mongo.Collection {
+	return m.Database().Collection(SendersCollection)
+}
+
+// GetBlocklistCollection returns the blocklist collection
+func (m *Mongo) GetBlocklistCollection() *mongo.Collection {
+	return m.Database().Collection(BlocklistCollection)
+}
+
+// GetQuarantineCollection returns the quarantine collection
+func (m *Mongo) GetQuarantineCollection() *mongo.Collection {
+	return m.Database().Collection(QuarantineCollection)
 }
 
 // Close closes the MongoDB connection gracefully
-func Close() error {
-	if Client == nil {
+func (m *Mongo) Close() error {
+	conn := m.current.Load()
+	if conn == nil {
 		return nil
 	}
 
-	log.Println("Closing MongoDB connection...")
+	slog.Info("Closing MongoDB connection")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if err := Client.Disconnect(ctx); err != nil {
+	if err := conn.client.Disconnect(ctx); err != nil {
 		return fmt.Errorf("failed to disconnect from MongoDB: %w", err)
 	}
 
-	log.Println("MongoDB connection closed successfully")
+	slog.Info("MongoDB connection closed successfully")
 	return nil
 }
 
 // HealthCheck verifies MongoDB connection is alive
-func HealthCheck() error {
-	if Client == nil {
+// A replica set without a primary fails at once rather than waiting for one to be elected
+func (m *Mongo) HealthCheck(ctx context.Context) error {
+	conn := m.current.Load()
+	if conn == nil {
 		return fmt.Errorf("MongoDB client is not initialized")
 	}
+	if conn.topology.noPrimary() {
+		return fmt.Errorf("MongoDB health check failed: replica set has no primary")
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	if err := Client.Ping(ctx, nil); err != nil {
+	if err := conn.client.Ping(ctx, nil); err != nil {
 		return fmt.Errorf("MongoDB health check failed: %w", err)
 	}
 
 	return nil
 }
+
+// HealthDetails reports the deployment's topology as the driver last saw it:
+// its kind, each member's role, the primary and how often it has changed
+func (m *Mongo) HealthDetails() map[string]any {
+	conn := m.current.Load()
+	if conn == nil {
+		return nil
+	}
+	return conn.topology.details()
+}
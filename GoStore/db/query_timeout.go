@@ -0,0 +1,27 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// queryTimeoutGrace is how much longer the client waits than the server, so a
+// query running out of time is stopped by the server, which reports it as such
+const queryTimeoutGrace = time.Second
+
+// SetQueryTimeout sets the time limit of the queries serving API requests
+func (m *Mongo) SetQueryTimeout(timeout time.Duration) {
+	m.queryTimeout = timeout
+}
+
+// QueryTimeout returns the time limit of the queries serving API requests, to
+// be sent with each as its maxTimeMS
+func (m *Mongo) QueryTimeout() time.Duration {
+	return m.queryTimeout
+}
+
+// QueryContext bounds ctx by the query timeout. ctx should be the request's,
+// so that a client going away cancels its queries
+func (m *Mongo) QueryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, m.queryTimeout+queryTimeoutGrace)
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RetentionIndexName is the TTL index that expires sms_records by created_at
+const RetentionIndexName = "idx_created_at_ttl"
+
+// EnsureRetentionPolicy reconciles the TTL index on sms_records, and on the cold
+// tier once created, with the configured retention
+// A retention of zero or less removes the TTL index so messages are kept indefinitely
+func (m *Mongo) EnsureRetentionPolicy(retentionDays int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := ensureRetentionIndex(ctx, m.GetCollection(), retentionDays); err != nil {
+		return err
+	}
+	// Creating the index would create the cold collection without its compression
+	cold, err := m.ColdTierExists(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up cold tier: %w", err)
+	}
+	if cold {
+		return ensureRetentionIndex(ctx, m.GetColdCollection(), retentionDays)
+	}
+	return nil
+}
+
+// ensureRetentionIndex reconciles the TTL index on one collection
+func ensureRetentionIndex(ctx context.Context, collection *mongo.Collection, retentionDays int) error {
+	current, exists, err := currentRetentionSeconds(ctx, collection)
+	if err != nil {
+		return err
+	}
+
+	if retentionDays <= 0 {
+		if exists {
+			if _, err := collection.Indexes().DropOne(ctx, RetentionIndexName); err != nil {
+				return fmt.Errorf("failed to drop retention index: %w", err)
+			}
+			slog.Info("Retention policy disabled, TTL index removed", "collection", collection.Name())
+		}
+		return nil
+	}
+
+	desired := int64(retentionDays) * 24 * 60 * 60
+	if exists && current == desired {
+		slog.Info("Retention policy verified", "collection", collection.Name(), "retention_days", retentionDays)
+		return nil
+	}
+
+	// The application user only has the readWrite role, which does not grant collMod,
+	// so a changed retention period is applied by recreating the index
+	if exists {
+		if _, err := collection.Indexes().DropOne(ctx, RetentionIndexName); err != nil {
+			return fmt.Errorf("failed to drop retention index: %w", err)
+		}
+	}
+
+	model := mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().
+			SetName(RetentionIndexName).
+			SetExpireAfterSeconds(int32(desired)),
+	}
+	if _, err := collection.Indexes().CreateOne(ctx, model); err != nil {
+		return fmt.Errorf("failed to create retention index: %w", err)
+	}
+
+	slog.Info("Retention policy applied", "collection", collection.Name(), "retention_days", retentionDays)
+	return nil
+}
+
+// currentRetentionSeconds returns the expireAfterSeconds of the retention index, if present
+func currentRetentionSeconds(ctx context.Context, collection *mongo.Collection) (int64, bool, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to list indexes: %w", err)
+	}
+
+	var indexes []bson.M
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return 0, false, fmt.Errorf("failed to decode indexes: %w", err)
+	}
+
+	for _, idx := range indexes {
+		if idx["name"] != RetentionIndexName {
+			continue
+		}
+		switch v := idx["expireAfterSeconds"].(type) {
+		case int32:
+			return int64(v), true, nil
+		case int64:
+			return v, true, nil
+		case float64:
+			return int64(v), true, nil
+		default:
+			return 0, true, nil
+		}
+	}
+	return 0, false, nil
+}
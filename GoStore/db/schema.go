@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Server error codes met while applying or enforcing the schema
+const (
+	codeUnauthorized              = 13
+	codeNamespaceNotFound         = 26
+	codeIndexNotFound             = 27
+	codeDocumentValidationFailure = 121
+)
+
+// recordSchema is the $jsonSchema every sms_records document written must match
+var recordSchema = bson.M{
+	"bsonType": "object",
+	"required": bson.A{"tenant_id", "user_id", "status", "created_at"},
+	"properties": bson.M{
+		"tenant_id":            bson.M{"bsonType": "string", "minLength": 1},
+		"user_id":              bson.M{"bsonType": "string", "minLength": 1},
+		"phone_number":         bson.M{"bsonType": "string"},
+		"raw_user_id":          bson.M{"bsonType": "string", "minLength": 1},
+		"raw_phone_number":     bson.M{"bsonType": "string", "minLength": 1},
+		"message":              bson.M{"bsonType": "string"},
+		"status":               bson.M{"bsonType": "string", "minLength": 1},
+		"direction":            bson.M{"enum": bson.A{"outbound", "inbound"}},
+		"message_id":           bson.M{"bsonType": "string", "minLength": 1},
+		"provider_message_id":  bson.M{"bsonType": "string", "minLength": 1},
+		"carrier":              bson.M{"bsonType": "string", "minLength": 1},
+		"country_code":         bson.M{"bsonType": "string", "pattern": "^[A-Z]{2}$"},
+		"dial_code":            bson.M{"bsonType": "string", "pattern": "^[1-9][0-9]{0,2}$"},
+		"sender_id":            bson.M{"bsonType": "string", "minLength": 1},
+		"sender_type":          bson.M{"enum": bson.A{"short_code", "alphanumeric", "long_code"}},
+		"segment_count":        bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 1},
+		"encoding":             bson.M{"enum": bson.A{"GSM-7", "UCS-2"}},
+		"script":               bson.M{"bsonType": "string", "minLength": 1},
+		"language":             bson.M{"bsonType": "string", "minLength": 1},
+		"flags":                bson.M{"bsonType": "array", "items": bson.M{"bsonType": "string", "minLength": 1}},
+		"created_at":           bson.M{"bsonType": "date"},
+		"updated_at":           bson.M{"bsonType": "date"},
+		"stored_event_pending": bson.M{"bsonType": "bool"},
+		"media": bson.M{
+			"bsonType": "array",
+			"items": bson.M{
+				"bsonType": "object",
+				"required": bson.A{"content_type", "size"},
+				"properties": bson.M{
+					"content_type": bson.M{"bsonType": "string", "minLength": 1},
+					"file_name":    bson.M{"bsonType": "string"},
+					"size":         bson.M{"bsonType": bson.A{"int", "long"}},
+				},
+			},
+		},
+		"status_history": bson.M{
+			"bsonType": "array",
+			"items": bson.M{
+				"bsonType": "object",
+				"required": bson.A{"status", "changed_at"},
+				"properties": bson.M{
+					"status":     bson.M{"bsonType": "string", "minLength": 1},
+					"reason":     bson.M{"bsonType": "string"},
+					"changed_at": bson.M{"bsonType": "date"},
+				},
+			},
+		},
+	},
+}
+
+// EnsureRecordSchema applies the record schema to sms_records, so malformed
+// documents from buggy producers are rejected by MongoDB itself. The moderate
+// validation level leaves existing documents that don't match alone until they
+// are updated. Changing the validator of an existing collection needs the
+// collMod action, which the readWrite role does not grant
+func (m *Mongo) EnsureRecordSchema() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	validator := bson.M{"$jsonSchema": recordSchema}
+	err := m.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: SMSRecordsCollection},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: "moderate"},
+		{Key: "validationAction", Value: "error"},
+	}).Err()
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == codeNamespaceNotFound {
+		opts := options.CreateCollection().
+			SetValidator(validator).
+			SetValidationLevel("moderate").
+			SetValidationAction("error")
+		err = m.Database().CreateCollection(ctx, SMSRecordsCollection, opts)
+	}
+	if errors.As(err, &cmdErr) && cmdErr.Code == codeUnauthorized {
+		return fmt.Errorf("the MongoDB user may not change the validator of %s, grant it the dbAdmin role: %w", SMSRecordsCollection, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply record schema: %w", err)
+	}
+
+	slog.Info("Record schema validation applied")
+	return nil
+}
+
+// ValidationFailure reports whether MongoDB rejected a write for not matching
+// the collection's schema, returning the server's explanation of which rules failed
+func ValidationFailure(err error) (string, bool) {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) || !serverErr.HasErrorCode(codeDocumentValidationFailure) {
+		return "", false
+	}
+
+	var details bson.Raw
+	var writeErr mongo.WriteException
+	var cmdErr mongo.CommandError
+	switch {
+	case errors.As(err, &writeErr) && len(writeErr.WriteErrors) > 0:
+		details = writeErr.WriteErrors[0].Details
+	case errors.As(err, &cmdErr):
+		if info, err := cmdErr.Raw.LookupErr("errInfo"); err == nil {
+			details, _ = info.DocumentOK()
+		}
+	}
+	if len(details) == 0 {
+		return serverErr.Error(), true
+	}
+	return details.String(), true
+}
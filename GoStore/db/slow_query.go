@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// SetSlowQueryThreshold changes the duration from which commands are logged as
+// slow; zero disables the log
+func (m *Mongo) SetSlowQueryThreshold(d time.Duration) {
+	m.slowQueryThreshold.Store(int64(d))
+}
+
+// SlowQueryThreshold returns the duration from which commands are logged as slow
+func (m *Mongo) SlowQueryThreshold() time.Duration {
+	return time.Duration(m.slowQueryThreshold.Load())
+}
+
+// filterFields names the field holding the filter of each command that reads
+// documents by one; updates and deletes hold theirs in each statement's q
+var filterFields = map[string]string{
+	"find":          "filter",
+	"count":         "query",
+	"distinct":      "query",
+	"findAndModify": "query",
+	"aggregate":     "pipeline",
+	"update":        "updates",
+	"delete":        "deletes",
+}
+
+// startedCommand is what the monitor keeps of a command until it finishes
+type startedCommand struct {
+	collection string
+	filter     bson.RawValue // unset unless slow commands are logged
+	awaiting   bool          // a getMore of a tailable cursor, waiting for new documents
+}
+
+// newStartedCommand reads the collection of a command, and its filter while
+// slow commands are logged. The driver hands the monitor its own copy of the
+// command, so the filter can be kept until the command finishes
+func newStartedCommand(e *event.CommandStartedEvent, threshold time.Duration) startedCommand {
+	var cmd startedCommand
+	if e.CommandName == "getMore" {
+		cmd.collection, _ = e.Command.Lookup("collection").StringValueOK()
+		_, cmd.awaiting = e.Command.Lookup("maxTimeMS").AsInt64OK()
+	} else if first, err := e.Command.IndexErr(0); err == nil {
+		cmd.collection, _ = first.Value().StringValueOK()
+	}
+	if threshold <= 0 {
+		return cmd
+	}
+	if field, ok := filterFields[e.CommandName]; ok {
+		cmd.filter, _ = e.Command.LookupErr(field)
+		// Statements share the shape of the first; only its filter is logged
+		if statements, ok := cmd.filter.ArrayOK(); ok && field != "pipeline" {
+			cmd.filter = statements.Lookup("0", "q")
+		}
+	}
+	return cmd
+}
+
+// logSlowCommand logs a command that took at least the slow query threshold,
+// with its filter reduced to field names and operators so that it carries no
+// personal data. getMores waiting on change streams are slow by design and skipped
+func logSlowCommand(ctx context.Context, name string, cmd startedCommand, duration, threshold time.Duration) {
+	if threshold <= 0 || duration < threshold || cmd.awaiting {
+		return
+	}
+	attrs := []any{"command", name, "collection", cmd.collection, "duration", duration}
+	if cmd.filter.Type != 0 {
+		attrs = append(attrs, "filter", filterShape(cmd.filter))
+	}
+	// ctx is the caller's, so this line carries its request ID
+	slog.WarnContext(ctx, "Slow MongoDB command", attrs...)
+}
+
+// filterShape renders a filter or pipeline as JSON with every value replaced
+// by "?", e.g. {"tenant_id":"?","created_at":{"$lt":"?"}}
+func filterShape(v bson.RawValue) string {
+	var b strings.Builder
+	writeShape(&b, v)
+	return b.String()
+}
+
+// writeShape writes the shape of v to b: documents keep their keys, arrays of
+// documents their elements, and anything else becomes "?"
+func writeShape(b *strings.Builder, v bson.RawValue) {
+	switch v.Type {
+	case bsontype.EmbeddedDocument:
+		elements, _ := v.Document().Elements()
+		b.WriteByte('{')
+		for i, element := range elements {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(`"` + element.Key() + `":`)
+			writeShape(b, element.Value())
+		}
+		b.WriteByte('}')
+	case bsontype.Array:
+		values, _ := v.Array().Values()
+		if len(values) == 0 || values[0].Type != bsontype.EmbeddedDocument {
+			b.WriteString(`"?"`)
+			return
+		}
+		b.WriteByte('[')
+		for i, value := range values {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeShape(b, value)
+		}
+		b.WriteByte(']')
+	default:
+		b.WriteString(`"?"`)
+	}
+}
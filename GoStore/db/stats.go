@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Top senders are the users with the most messages stored in this window
+const (
+	topSendersWindow = time.Hour
+	topSendersLimit  = 10
+)
+
+// ServiceStats summarises the sms_records collection across all tenants
+type ServiceStats struct {
+	Documents  int64         `json:"documents"`
+	IngestRate IngestRate    `json:"ingest_rate"`
+	TopSenders []SenderCount `json:"top_senders"` // most messages stored in the last hour first
+	Storage    StorageStats  `json:"storage"`
+}
+
+// IngestRate is the average number of messages stored per second over recent windows
+type IngestRate struct {
+	OneMinute      float64 `json:"1m"`
+	FiveMinutes    float64 `json:"5m"`
+	FifteenMinutes float64 `json:"15m"`
+}
+
+// SenderCount is the number of messages stored for one user, and the billing
+// units (SMS segments) they took
+type SenderCount struct {
+	TenantID     string `json:"tenant_id"`
+	UserID       string `json:"user_id"`
+	Count        int64  `json:"count"`
+	BillingUnits int64  `json:"billing_units"`
+}
+
+// StorageStats are the sizes reported by collStats, in bytes
+type StorageStats struct {
+	DataSize       int64 `json:"data_size_bytes"`
+	StorageSize    int64 `json:"storage_size_bytes"`
+	IndexSize      int64 `json:"index_size_bytes"`
+	AverageDocSize int64 `json:"avg_doc_size_bytes"`
+}
+
+// GetServiceStats gathers ServiceStats. Recent messages are found by the creation
+// time of their ObjectIDs, which are assigned when a message is stored, so the
+// windows use the _id index and reflect ingestion rather than event timestamps
+func (m *Mongo) GetServiceStats(ctx context.Context) (*ServiceStats, error) {
+	collection := m.Database().Collection(SMSRecordsCollection)
+	var stats ServiceStats
+	var err error
+
+	// The estimate reads collection metadata instead of scanning
+	if stats.Documents, err = collection.EstimatedDocumentCount(ctx); err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	now := time.Now()
+	for _, window := range []struct {
+		rate   *float64
+		period time.Duration
+	}{
+		{&stats.IngestRate.OneMinute, time.Minute},
+		{&stats.IngestRate.FiveMinutes, 5 * time.Minute},
+		{&stats.IngestRate.FifteenMinutes, 15 * time.Minute},
+	} {
+		count, err := collection.CountDocuments(ctx, storedSince(now.Add(-window.period)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to count recent documents: %w", err)
+		}
+		*window.rate = float64(count) / window.period.Seconds()
+	}
+
+	if stats.TopSenders, err = topSenders(ctx, collection, now.Add(-topSendersWindow)); err != nil {
+		return nil, err
+	}
+
+	var collStats struct {
+		Size           int64   `bson:"size"`
+		StorageSize    int64   `bson:"storageSize"`
+		TotalIndexSize int64   `bson:"totalIndexSize"`
+		AvgObjSize     float64 `bson:"avgObjSize"`
+	}
+	err = m.Database().RunCommand(ctx, bson.D{{Key: "collStats", Value: SMSRecordsCollection}}).Decode(&collStats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection stats: %w", err)
+	}
+	stats.Storage = StorageStats{
+		DataSize:       collStats.Size,
+		StorageSize:    collStats.StorageSize,
+		IndexSize:      collStats.TotalIndexSize,
+		AverageDocSize: int64(collStats.AvgObjSize),
+	}
+
+	return &stats, nil
+}
+
+// storedSince matches the records stored at or after t
+func storedSince(t time.Time) bson.M {
+	return bson.M{"_id": bson.M{"$gte": primitive.NewObjectIDFromTimestamp(t)}}
+}
+
+// topSenders returns the users with the most messages stored since t
+func topSenders(ctx context.Context, collection *mongo.Collection, t time.Time) ([]SenderCount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: storedSince(t)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"tenant_id": "$tenant_id", "user_id": "$user_id"},
+			"count": bson.M{"$sum": 1},
+			"units": bson.M{"$sum": bson.M{"$max": bson.A{"$segment_count", 1}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}}}},
+		{{Key: "$limit", Value: topSendersLimit}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate top senders: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		ID struct {
+			TenantID string `bson:"tenant_id"`
+			UserID   string `bson:"user_id"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+		Units int64 `bson:"units"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, fmt.Errorf("failed to decode top senders: %w", err)
+	}
+
+	senders := make([]SenderCount, len(groups))
+	for n, group := range groups {
+		senders[n] = SenderCount{TenantID: group.ID.TenantID, UserID: group.ID.UserID, Count: group.Count, BillingUnits: group.Units}
+	}
+	return senders, nil
+}
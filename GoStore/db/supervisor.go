@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+)
+
+const (
+	// supervisePingInterval is how often the Supervisor checks that MongoDB is reachable
+	supervisePingInterval = 10 * time.Second
+
+	// disconnectGrace is how long a replaced client is given for the operations
+	// still running on it before it is disconnected
+	disconnectGrace = 30 * time.Second
+)
+
+// Supervisor keeps the service connected to MongoDB for as long as it runs.
+// The driver rediscovers the deployment and follows failovers on its own, so
+// an election only fails the operations running during it. But a client
+// whose seed list no longer leads to any member, e.g. after every member was
+// replaced or a mongodb+srv record changed, never recovers. Once MongoDB has
+// been unreachable for reconnectAfter, the Supervisor connects a new client
+// from the URI and swaps it in, retrying every reconnectAfter until it succeeds
+type Supervisor struct {
+	db             *Mongo
+	reconnectAfter time.Duration
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSupervisor creates a supervisor reconnecting m to its database
+func NewSupervisor(m *Mongo, reconnectAfter time.Duration) *Supervisor {
+	return &Supervisor{
+		db:             m,
+		reconnectAfter: reconnectAfter,
+		stopChan:       make(chan struct{}),
+	}
+}
+
+// Start pings MongoDB in a background goroutine, reconnecting after long outages
+func (s *Supervisor) Start() {
+	slog.Info("Starting MongoDB supervisor", "reconnect_after", s.reconnectAfter)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(supervisePingInterval)
+		defer ticker.Stop()
+
+		var unreachableSince, lastAttempt time.Time
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err := s.db.Client().Ping(ctx, nil)
+			cancel()
+			if err == nil {
+				if !unreachableSince.IsZero() {
+					slog.Info("MongoDB reachable again", "outage", time.Since(unreachableSince).Round(time.Second))
+					unreachableSince, lastAttempt = time.Time{}, time.Time{}
+				}
+				continue
+			}
+
+			if unreachableSince.IsZero() {
+				unreachableSince, lastAttempt = time.Now(), time.Now()
+				slog.Warn("MongoDB unreachable", "error", err)
+				continue
+			}
+			if time.Since(lastAttempt) < s.reconnectAfter {
+				continue
+			}
+			lastAttempt = time.Now()
+			s.reconnect(time.Since(unreachableSince))
+		}
+	}()
+}
+
+// reconnect replaces the client with a new one connected from the URI, if it
+// reaches MongoDB, leaving the old one to finish its operations
+func (s *Supervisor) reconnect(outage time.Duration) {
+	slog.Warn("MongoDB unreachable for too long, reconnecting", "outage", outage.Round(time.Second))
+
+	conn, err := s.db.connect()
+	if err != nil {
+		metrics.MongoReconnected(false)
+		slog.Error("Failed to reconnect to MongoDB, retrying later", "retry_in", s.reconnectAfter, "error", err)
+		return
+	}
+
+	old := s.db.current.Swap(conn)
+	metrics.MongoReconnected(true)
+	slog.Info("Reconnected to MongoDB", "database", s.db.dbName, "transactions", conn.transactions)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		select {
+		case <-s.stopChan:
+		case <-time.After(disconnectGrace):
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := old.client.Disconnect(ctx); err != nil {
+			slog.Warn("Failed to disconnect replaced MongoDB client", "error", err)
+		}
+	}()
+}
+
+// Stop stops supervising, waiting for a reconnection in progress
+func (s *Supervisor) Stop() {
+	slog.Info("Stopping MongoDB supervisor")
+	close(s.stopChan)
+	s.wg.Wait()
+}
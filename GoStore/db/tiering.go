@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// codeNamespaceExists is returned when creating a collection that already exists
+const codeNamespaceExists = 48
+
+// coldBoundaryTTL is how long each process caches the cold tier boundary
+const coldBoundaryTTL = 10 * time.Second
+
+// ColdBoundaryPropagation is how long after raising the cold tier boundary every
+// process has seen it, so messages below it may be moved without queries missing them
+const ColdBoundaryPropagation = 2 * coldBoundaryTTL
+
+// coldIndexes are the indexes of the cold tier: user queries by time, phone
+// number lookups and status updates. Queries on other fields scan a user's messages
+var coldIndexes = []mongo.IndexModel{
+	{
+		Keys:    bson.D{{Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("idx_created_at"),
+	},
+	{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName(UserMessagesIndexName),
+	},
+	{
+		Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "phone_number", Value: 1}, {Key: "created_at", Value: -1}},
+		Options: options.Index().SetName("idx_tenant_id_phone_number_created_at"),
+	},
+	messageIDIndex,
+	{
+		Keys:    bson.D{{Key: "provider_message_id", Value: 1}},
+		Options: options.Index().SetName("idx_provider_message_id").SetSparse(true),
+	},
+}
+
+// createColdCollection creates sms_records_cold compressed with zstd, and its indexes
+func (m *Mongo) createColdCollection(ctx context.Context) error {
+	opts := options.CreateCollection().SetStorageEngine(bson.M{
+		"wiredTiger": bson.M{"configString": "block_compressor=zstd"},
+	})
+	err := m.Database().CreateCollection(ctx, ColdRecordsCollection, opts)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == codeNamespaceExists {
+		slog.Warn("Cold tier collection already exists, its compression is left unchanged", "collection", ColdRecordsCollection)
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", ColdRecordsCollection, err)
+	}
+	_, err = m.GetColdCollection().Indexes().CreateMany(ctx, coldIndexes)
+	return err
+}
+
+// ColdTierExists reports whether the cold tier collection has been created by its migration
+func (m *Mongo) ColdTierExists(ctx context.Context) (bool, error) {
+	names, err := m.Database().ListCollectionNames(ctx, bson.M{"name": ColdRecordsCollection})
+	if err != nil {
+		return false, err
+	}
+	return len(names) > 0, nil
+}
+
+// ColdTierBoundary returns the creation time below which messages may have been
+// moved to the cold tier, or zero if none ever were. Messages created since are
+// only in sms_records
+func (m *Mongo) ColdTierBoundary(ctx context.Context) (time.Time, error) {
+	m.coldBoundary.Lock()
+	defer m.coldBoundary.Unlock()
+
+	if time.Since(m.coldBoundary.readAt) < coldBoundaryTTL {
+		return m.coldBoundary.value, nil
+	}
+
+	// Timed from before the read, so a boundary raised during it expires in time
+	started := time.Now()
+	var state struct {
+		Boundary time.Time `bson:"boundary"`
+	}
+	err := m.Database().Collection(TieringCollection).FindOne(ctx, bson.M{"_id": SMSRecordsCollection}).Decode(&state)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, fmt.Errorf("failed to read cold tier boundary: %w", err)
+	}
+	m.coldBoundary.value = state.Boundary
+	m.coldBoundary.readAt = started
+	return state.Boundary, nil
+}
+
+// RaiseColdTierBoundary moves the cold tier boundary up to boundary; it never moves down
+func (m *Mongo) RaiseColdTierBoundary(ctx context.Context, boundary time.Time) error {
+	_, err := m.Database().Collection(TieringCollection).UpdateOne(ctx,
+		bson.M{"_id": SMSRecordsCollection},
+		bson.M{"$max": bson.M{"boundary": boundary}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to raise cold tier boundary: %w", err)
+	}
+	return nil
+}
+
+// MoveToColdTier moves up to limit of the oldest messages created before cutoff
+// from sms_records to sms_records_cold, returning how many it moved. Messages
+// whose stored event is not published yet stay until it is. Each batch is moved
+// in a transaction; on a standalone server a batch interrupted between copying
+// and deleting is completed by the next call
+func (m *Mongo) MoveToColdTier(ctx context.Context, cutoff time.Time, limit int64) (int64, error) {
+	filter := bson.M{"created_at": bson.M{"$lt": cutoff}, "stored_event_pending": bson.M{"$ne": true}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(limit)
+	cursor, err := m.GetCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find messages to move: %w", err)
+	}
+	var docs []bson.Raw
+	if err := cursor.All(ctx, &docs); err != nil {
+		return 0, fmt.Errorf("failed to decode messages to move: %w", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(docs))
+	messageIDs := []string{}
+	for i, doc := range docs {
+		ids[i], _ = doc.Lookup("_id").ObjectIDOK()
+		if messageID, ok := doc.Lookup("message_id").StringValueOK(); ok {
+			messageIDs = append(messageIDs, messageID)
+		}
+	}
+
+	var moved int64
+	err = m.WithTransaction(ctx, func(ctx context.Context) error {
+		// Skip copies left by an interrupted move, and redeliveries of messages already cold
+		coldIDs, coldMessageIDs, err := m.coldCopies(ctx, ids, messageIDs)
+		if err != nil {
+			return err
+		}
+		var inserts []any
+		for i, doc := range docs {
+			if coldIDs[ids[i]] || coldMessageIDs[tenantMessageID(doc)] {
+				continue
+			}
+			inserts = append(inserts, doc)
+		}
+		if len(inserts) > 0 {
+			if _, err := m.GetColdCollection().InsertMany(ctx, inserts); err != nil {
+				return fmt.Errorf("failed to copy messages to the cold tier: %w", err)
+			}
+		}
+		result, err := m.GetCollection().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return fmt.Errorf("failed to delete moved messages: %w", err)
+		}
+		moved = result.DeletedCount
+		return nil
+	})
+	return moved, err
+}
+
+// tenantMessageID keys a record by its tenant and message_id, the fields of the
+// unique message_id index, or returns "" if it has no message_id
+func tenantMessageID(doc bson.Raw) string {
+	messageID, ok := doc.Lookup("message_id").StringValueOK()
+	if !ok || messageID == "" {
+		return ""
+	}
+	tenantID, _ := doc.Lookup("tenant_id").StringValueOK()
+	return tenantID + "\x00" + messageID
+}
+
+// coldCopies returns which of ids are already in the cold tier, and which tenants'
+// messages with one of messageIDs, keyed by tenantMessageID
+func (m *Mongo) coldCopies(ctx context.Context, ids []primitive.ObjectID, messageIDs []string) (map[primitive.ObjectID]bool, map[string]bool, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"message_id": bson.M{"$in": messageIDs}},
+	}}
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "tenant_id": 1, "message_id": 1})
+	cursor, err := m.GetColdCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find cold copies: %w", err)
+	}
+	var copies []bson.Raw
+	if err := cursor.All(ctx, &copies); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode cold copies: %w", err)
+	}
+
+	coldIDs := make(map[primitive.ObjectID]bool, len(copies))
+	coldMessageIDs := make(map[string]bool, len(copies))
+	for _, c := range copies {
+		id, _ := c.Lookup("_id").ObjectIDOK()
+		coldIDs[id] = true
+		if key := tenantMessageID(c); key != "" {
+			coldMessageIDs[key] = true
+		}
+	}
+	return coldIDs, coldMessageIDs, nil
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+// topologyMonitor follows the deployment as the driver discovers it: its
+// kind, its members and which one is primary. The driver fails over to a new
+// primary on its own; this only makes the changes visible
+type topologyMonitor struct {
+	mu             sync.Mutex
+	kind           description.TopologyKind
+	primary        string // address; empty while there is none
+	lastPrimary    string // the last primary seen, kept through elections
+	members        map[string]string
+	primaryChanges int64
+	changedAt      time.Time // when the primary last changed or was lost
+}
+
+// serverMonitor reports topology changes seen by the driver to m
+func (m *topologyMonitor) serverMonitor() *event.ServerMonitor {
+	return &event.ServerMonitor{
+		TopologyDescriptionChanged: func(e *event.TopologyDescriptionChangedEvent) {
+			m.update(e.NewDescription)
+		},
+	}
+}
+
+// update records the new description, logging when the primary is lost or moves
+func (m *topologyMonitor) update(topology description.Topology) {
+	primary := ""
+	members := make(map[string]string, len(topology.Servers))
+	for _, server := range topology.Servers {
+		members[server.Addr.String()] = server.Kind.String()
+		if server.Kind == description.RSPrimary {
+			primary = server.Addr.String()
+		}
+	}
+
+	m.mu.Lock()
+	previous, last := m.primary, m.lastPrimary
+	moved := primary != "" && last != "" && primary != last
+	m.kind = topology.Kind
+	m.primary = primary
+	m.members = members
+	if primary != previous {
+		m.changedAt = time.Now()
+	}
+	if primary != "" {
+		m.lastPrimary = primary
+	}
+	if moved {
+		m.primaryChanges++
+	}
+	m.mu.Unlock()
+
+	switch {
+	case primary == previous:
+	case primary == "":
+		slog.Warn("MongoDB replica set has no primary, writes wait for an election", "previous_primary", previous)
+	case moved:
+		metrics.MongoPrimaryChanged()
+		slog.Warn("MongoDB primary changed", "primary", primary, "previous_primary", last)
+	default:
+		slog.Info("MongoDB primary available", "primary", primary)
+	}
+}
+
+// noPrimary reports whether the deployment is a replica set currently without a primary
+func (m *topologyMonitor) noPrimary() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.kind == description.ReplicaSetNoPrimary
+}
+
+// details summarises the topology for the readiness probe
+func (m *topologyMonitor) details() map[string]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	details := map[string]any{
+		"topology":        m.kind.String(),
+		"members":         m.members,
+		"primary_changes": m.primaryChanges,
+	}
+	if m.primary != "" {
+		details["primary"] = m.primary
+	}
+	if !m.changedAt.IsZero() {
+		details["primary_changed_at"] = m.changedAt.UTC().Format(time.RFC3339)
+	}
+	return details
+}
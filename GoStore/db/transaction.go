@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// SupportsTransactions reports whether WithTransaction runs its callback in a
+// transaction. Standalone servers don't support transactions
+func (m *Mongo) SupportsTransactions() bool {
+	return m.current.Load().transactions
+}
+
+// WithTransaction runs fn so that the writes it makes are applied together or
+// not at all. fn must do its work with the context it is given, and may be run
+// again after a transient transaction error, so it must be safe to retry
+// On a standalone server fn runs without a transaction, and writes it made
+// before failing stay applied
+func (m *Mongo) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.SupportsTransactions() {
+		return fn(ctx)
+	}
+
+	session, err := m.Client().StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start MongoDB session: %w", err)
+	}
+	defer session.EndSession(ctx)
+
+	opts := options.Transaction().SetWriteConcern(writeconcern.Majority())
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	}, opts)
+	return err
+}
+
+// detectTransactions reports whether the deployment client is connected to supports transactions
+func detectTransactions(ctx context.Context, client *mongo.Client) bool {
+	var hello bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		slog.Warn("Failed to detect MongoDB deployment type, transactions disabled", "error", err)
+		return false
+	}
+	_, replicaSet := hello["setName"]
+	return replicaSet || hello["msg"] == "isdbgrid"
+}
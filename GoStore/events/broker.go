@@ -0,0 +1,123 @@
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Event types published by the SMS service
+const (
+	MessageStored        = "message.stored"
+	MessageStatusChanged = "message.status_changed"
+	// A user's stored messages crossed the warning level of their quota, or reached it
+	QuotaWarning  = "quota.warning"
+	QuotaExceeded = "quota.exceeded"
+)
+
+// Types lists every event type, for webhook subscriptions to filter by
+var Types = []string{MessageStored, MessageStatusChanged, QuotaWarning, QuotaExceeded}
+
+// subscriberBuffer is the number of events buffered per subscriber before drops occur
+const subscriberBuffer = 16
+
+// Event describes a change to a stored SMS record
+type Event struct {
+	Type       string            `json:"event"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Record     *models.SMSRecord `json:"data"`
+	Usage      *models.UserUsage `json:"usage,omitempty"` // of the record's user, for quota events
+}
+
+// Broker fans out SMS record events to in-process per-user subscribers and
+// to listeners that receive every event. Subscribers are keyed by tenant and user
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+	listeners   []func(Event)
+}
+
+// NewBroker creates a new event broker instance
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[chan Event]struct{}),
+	}
+}
+
+// Subscribe registers interest in events for a user of a tenant. The returned
+// function must be called to release the subscription; it closes the channel.
+func (b *Broker) Subscribe(tenantID, userID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	key := subscriberKey(tenantID, userID)
+
+	b.mu.Lock()
+	if b.subscribers[key] == nil {
+		b.subscribers[key] = make(map[chan Event]struct{})
+	}
+	b.subscribers[key][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[key], ch)
+			if len(b.subscribers[key]) == 0 {
+				delete(b.subscribers, key)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// subscriberKey combines tenant and user so subscribers never see another tenant's events
+func subscriberKey(tenantID, userID string) string {
+	return tenantID + "/" + userID
+}
+
+// AddListener registers fn to be called synchronously for every published event
+// Listeners must not block; hand work off to another goroutine instead
+func (b *Broker) AddListener(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+// Publish delivers an event to listeners and to every subscriber of the record's
+// user without blocking. Slow subscribers whose buffer is full miss the event.
+func (b *Broker) Publish(eventType string, record *models.SMSRecord) {
+	b.publish(Event{Type: eventType, OccurredAt: time.Now().UTC(), Record: record})
+}
+
+// PublishUsage is Publish for a quota event, carrying the usage of the user of
+// record, the message that took it past the level
+func (b *Broker) PublishUsage(eventType string, record *models.SMSRecord, usage *models.UserUsage) {
+	b.publish(Event{Type: eventType, OccurredAt: time.Now().UTC(), Record: record, Usage: usage})
+}
+
+// publish delivers event to listeners and subscribers
+func (b *Broker) publish(event Event) {
+	if b == nil {
+		return
+	}
+	record, eventType := event.Record, event.Type
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, fn := range b.listeners {
+		fn(event)
+	}
+
+	for ch := range b.subscribers[subscriberKey(record.TenantID, record.UserID)] {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("Dropping event for slow subscriber", "event", eventType, "tenant_id", record.TenantID, "user_id", record.UserID, "message_id", record.MessageID)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ramG-reddy/sms-store/export"
+	"github.com/ramG-reddy/sms-store/logging"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/spf13/cobra"
+)
+
+// newExportCommand returns the export command, which runs the export job once
+// instead of waiting for its schedule, e.g. to catch up after a failed run or
+// when the schedule is left to an external scheduler with EXPORT_ENABLED=false
+func newExportCommand(app *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "export",
+		Short: "Export the messages stored since the last export run",
+		Args:  cobra.NoArgs,
+		Run:   func(*cobra.Command, []string) { app.export() },
+	}
+}
+
+// export runs the configured export job once
+func (app *cli) export() {
+	cfg := app.cfg
+	if cfg.ExportDestination == "" {
+		logging.Fatal("No export destination is configured; set EXPORT_DESTINATION")
+	}
+	database := app.connectMongo()
+	defer database.Close()
+
+	core, err := newApplication(cfg, database)
+	if err != nil {
+		logging.Fatal("Failed to initialize message storage", "error", err)
+	}
+	defer core.Close()
+
+	exporter, err := export.NewExporter(context.Background(), export.Config{
+		Job:         cfg.ExportJob,
+		Schedule:    cfg.ExportSchedule,
+		Format:      cfg.ExportFormat,
+		Destination: cfg.ExportDestination,
+		S3Endpoint:  cfg.ExportS3Endpoint,
+		RowsPerFile: cfg.ExportRowsPerFile,
+	}, core.leases, core.smsService, services.NewExportRunService(database))
+	if err != nil {
+		logging.Fatal("Failed to initialize exporter", "error", err)
+	}
+	defer exporter.Stop()
+
+	// An interrupt fails the run, which the next run then exports again
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := exporter.RunOnce(ctx); err != nil {
+		logging.Fatal("Export run failed", "job", cfg.ExportJob, "error", err)
+	}
+}
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Schemes of export destinations
+const (
+	SchemeS3  = "s3"
+	SchemeGCS = "gs"
+)
+
+// ParseDestination splits a destination URL, s3://bucket/prefix or
+// gs://bucket/prefix, into its scheme, bucket and key prefix
+func ParseDestination(raw string) (scheme, bucket, prefix string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid export destination: %w", err)
+	}
+	if (u.Scheme != SchemeS3 && u.Scheme != SchemeGCS) || u.Host == "" {
+		return "", "", "", fmt.Errorf("export destination must be s3://bucket/prefix or gs://bucket/prefix, got %q", raw)
+	}
+	return u.Scheme, u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// destination writes export files to a bucket
+type destination interface {
+	// put writes an object and returns its URL
+	put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+	close() error
+}
+
+// newDestination connects to the bucket of the destination URL, using the
+// default credential chain of its cloud. endpoint overrides the S3 endpoint,
+// e.g. for MinIO
+func newDestination(ctx context.Context, raw, endpoint string) (destination, string, error) {
+	scheme, bucket, prefix, err := ParseDestination(raw)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if scheme == SchemeGCS {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create Cloud Storage client: %w", err)
+		}
+		return &gcsDestination{client: client, bucket: bucket}, prefix, nil
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &s3Destination{client: client, bucket: bucket}, prefix, nil
+}
+
+// s3Destination writes to an S3 bucket
+type s3Destination struct {
+	client *s3.Client
+	bucket string
+}
+
+func (d *s3Destination) put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	_, err := d.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(d.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload export file to S3: %w", err)
+	}
+	return "s3://" + d.bucket + "/" + key, nil
+}
+
+func (d *s3Destination) close() error {
+	return nil
+}
+
+// gcsDestination writes to a Cloud Storage bucket
+type gcsDestination struct {
+	client *storage.Client
+	bucket string
+}
+
+func (d *gcsDestination) put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	w := d.client.Bucket(d.bucket).Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return "", fmt.Errorf("failed to upload export file to Cloud Storage: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to upload export file to Cloud Storage: %w", err)
+	}
+	return "gs://" + d.bucket + "/" + key, nil
+}
+
+func (d *gcsDestination) close() error {
+	return d.client.Close()
+}
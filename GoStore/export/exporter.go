@@ -0,0 +1,272 @@
+// Package export periodically writes the messages stored since its last run to
+// S3 or Google Cloud Storage, as Parquet or NDJSON files partitioned by the day
+// the messages were created, for loading into a data warehouse
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/lease"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/robfig/cron/v3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// pageSize is how many messages are read from storage at a time
+	pageSize = 1000
+
+	// settleDelay keeps a run from exporting the messages of its last minute.
+	// Each instance assigns IDs by its own clock, so a message stored at the same
+	// time on another instance could get an ID below the watermark after the run
+	settleDelay = time.Minute
+
+	// uploadTimeout bounds the upload of one file
+	uploadTimeout = 5 * time.Minute
+)
+
+// errStopped fails a run interrupted by Stop
+var errStopped = errors.New("interrupted by shutdown")
+
+// Config controls what is exported, where, and when
+type Config struct {
+	// Job names the export in the run history; each run continues from the
+	// watermark of the job's last successful run
+	Job string
+
+	// Schedule is a cron expression in UTC, five fields or a descriptor such as @hourly
+	Schedule string
+
+	Format      string // FormatParquet or FormatNDJSON
+	Destination string // s3://bucket/prefix or gs://bucket/prefix
+	S3Endpoint  string // Optional S3-compatible endpoint (e.g. MinIO)
+
+	// RowsPerFile caps the messages in one file
+	RowsPerFile int
+}
+
+// ParseSchedule parses a cron expression of Config.Schedule
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export schedule %q: %w", spec, err)
+	}
+	return schedule, nil
+}
+
+// Exporter runs an export job on its schedule
+type Exporter struct {
+	cfg        Config
+	schedule   cron.Schedule
+	encoder    encoder
+	dest       destination
+	prefix     string
+	leases     *lease.Leases
+	smsService *services.SMSService
+	runs       *services.ExportRunService
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+
+	// runMu keeps scheduled and manual runs from overlapping
+	runMu sync.Mutex
+}
+
+// NewExporter creates an exporter using the default credentials of the
+// destination's cloud
+func NewExporter(ctx context.Context, cfg Config, leases *lease.Leases, smsService *services.SMSService, runs *services.ExportRunService) (*Exporter, error) {
+	schedule, err := ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := newEncoder(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+	dest, prefix, err := newDestination(ctx, cfg.Destination, cfg.S3Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Exporter{
+		cfg:        cfg,
+		schedule:   schedule,
+		encoder:    enc,
+		dest:       dest,
+		prefix:     prefix,
+		leases:     leases,
+		smsService: smsService,
+		runs:       runs,
+		stopChan:   make(chan struct{}),
+	}, nil
+}
+
+// Start runs the export job on its schedule in a background goroutine
+func (e *Exporter) Start() {
+	slog.Info("Starting exporter", "job", e.cfg.Job, "schedule", e.cfg.Schedule,
+		"format", e.cfg.Format, "destination", e.cfg.Destination)
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+
+		for {
+			next := e.schedule.Next(time.Now().UTC())
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-e.stopChan:
+				timer.Stop()
+				return
+			case <-timer.C:
+				// The lease is kept until the next scheduled run, so the other
+				// instances skip this one
+				hold := time.Until(e.schedule.Next(time.Now().UTC()))
+				err := e.runOnce(context.Background(), hold)
+				if errors.Is(err, lease.ErrHeld) {
+					slog.Debug("Export run skipped; another instance ran it", "job", e.cfg.Job)
+				} else if err != nil {
+					slog.Error("Export run failed", "job", e.cfg.Job, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop interrupts an in-progress run, which is recorded as failed so the next
+// run exports its messages again, and stops the schedule
+func (e *Exporter) Stop() {
+	slog.Info("Stopping exporter")
+	close(e.stopChan)
+	e.wg.Wait()
+	if err := e.dest.close(); err != nil {
+		slog.Warn("Failed to close export destination", "error", err)
+	}
+	slog.Info("Exporter stopped")
+}
+
+// RunOnce exports the messages stored since the job's last successful run,
+// recording the run in the run history. A failed run leaves the watermark
+// where it was, so the next run exports the same messages; files it already
+// wrote are named by their first and last message ID, and are overwritten
+// unless the next run splits them differently. It fails with lease.ErrHeld
+// while another instance runs the job
+func (e *Exporter) RunOnce(ctx context.Context) error {
+	return e.runOnce(ctx, 0)
+}
+
+// runOnce runs the job holding its lease, which is kept until hold has passed
+func (e *Exporter) runOnce(ctx context.Context, hold time.Duration) error {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+
+	return e.leases.Run(ctx, "export:"+e.cfg.Job, hold, e.run)
+}
+
+// run exports the messages stored since the last successful run
+func (e *Exporter) run(ctx context.Context) error {
+	last, err := e.runs.LastSucceeded(ctx, e.cfg.Job)
+	if err != nil {
+		return err
+	}
+	run := &models.ExportRun{
+		Job:    e.cfg.Job,
+		Before: primitive.NewObjectIDFromTimestamp(time.Now().Add(-settleDelay)),
+	}
+	if last != nil {
+		run.After = last.Before
+	}
+	if err := e.runs.Start(ctx, run); err != nil {
+		return err
+	}
+	slog.Info("Export run started", "job", e.cfg.Job, "run", run.ID.Hex(), "after", run.After.Hex(), "before", run.Before.Hex())
+
+	exportErr := e.export(ctx, run)
+	run.Status = models.ExportSucceeded
+	if exportErr != nil {
+		run.Status = models.ExportFailed
+		run.Error = exportErr.Error()
+	}
+	// Recorded even if ctx is done, or the run would look like it still runs
+	finishErr := e.runs.Finish(context.WithoutCancel(ctx), run)
+	metrics.ExportRunFinished(e.cfg.Job, exportErr == nil && finishErr == nil, run.Records, run.FinishedAt)
+	if err := errors.Join(exportErr, finishErr); err != nil {
+		return fmt.Errorf("export run %s: %w", run.ID.Hex(), err)
+	}
+
+	slog.Info("Export run complete", "job", e.cfg.Job, "run", run.ID.Hex(), "records", run.Records, "files", len(run.Files), "bytes", run.Bytes)
+	return nil
+}
+
+// export writes the messages of the run's ID range, grouping them by the UTC
+// day they were created. A day's file is written once it holds RowsPerFile
+// messages, and the rest at the end
+func (e *Exporter) export(ctx context.Context, run *models.ExportRun) error {
+	days := make(map[string][]*models.SMSRecord)
+	after := run.After
+	for {
+		select {
+		case <-e.stopChan:
+			return errStopped
+		default:
+		}
+
+		records, err := e.smsService.FindMessagesInIDRange(ctx, after, run.Before, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			day := record.CreatedAt.UTC().Format(time.DateOnly)
+			days[day] = append(days[day], record)
+			if len(days[day]) >= e.cfg.RowsPerFile {
+				if err := e.write(ctx, run, day, days[day]); err != nil {
+					return err
+				}
+				delete(days, day)
+			}
+		}
+		if len(records) < pageSize {
+			break
+		}
+		after = records[len(records)-1].ID
+	}
+
+	for _, day := range slices.Sorted(maps.Keys(days)) {
+		if err := e.write(ctx, run, day, days[day]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write uploads one file of a day's messages to the day's partition
+func (e *Exporter) write(ctx context.Context, run *models.ExportRun, day string, records []*models.SMSRecord) error {
+	data, err := e.encoder.encode(records)
+	if err != nil {
+		return err
+	}
+
+	first, last := records[0], records[len(records)-1]
+	key := path.Join(e.prefix, "dt="+day, fmt.Sprintf("%s-%s.%s", first.ID.Hex(), last.ID.Hex(), e.encoder.extension()))
+
+	uploadCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	defer cancel()
+	url, err := e.dest.put(uploadCtx, key, data, e.encoder.contentType())
+	if err != nil {
+		return err
+	}
+
+	run.Files = append(run.Files, url)
+	run.Records += int64(len(records))
+	run.Bytes += int64(len(data))
+	slog.Info("Exported messages", "job", e.cfg.Job, "count", len(records), "file", url)
+	return nil
+}
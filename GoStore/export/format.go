@@ -0,0 +1,145 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Formats of exported files
+const (
+	FormatParquet = "parquet"
+	FormatNDJSON  = "ndjson"
+)
+
+// IsValidFormat reports whether format is a supported file format
+func IsValidFormat(format string) bool {
+	return format == FormatParquet || format == FormatNDJSON
+}
+
+// encoder serializes a file of records
+type encoder interface {
+	encode(records []*models.SMSRecord) ([]byte, error)
+	extension() string
+	contentType() string
+}
+
+// newEncoder returns the encoder of format
+func newEncoder(format string) (encoder, error) {
+	switch format {
+	case FormatParquet:
+		return parquetEncoder{}, nil
+	case FormatNDJSON:
+		return ndjsonEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// ndjsonEncoder writes gzipped NDJSON, one record per line as the API returns
+// it, status history and media included
+type ndjsonEncoder struct{}
+
+func (ndjsonEncoder) encode(records []*models.SMSRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return nil, fmt.Errorf("failed to encode export file: %w", err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress export file: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (ndjsonEncoder) extension() string   { return "ndjson.gz" }
+func (ndjsonEncoder) contentType() string { return "application/gzip" }
+
+// parquetRow is the flat schema of Parquet exports. Nested status history and
+// media are left out; Status is the status at the time of the export
+type parquetRow struct {
+	ID                string    `parquet:"id"`
+	TenantID          string    `parquet:"tenant_id,dict"`
+	UserID            string    `parquet:"user_id"`
+	MessageID         string    `parquet:"message_id,optional"`
+	ProviderMessageID string    `parquet:"provider_message_id,optional"`
+	PhoneNumber       string    `parquet:"phone_number"`
+	RawUserID         string    `parquet:"raw_user_id,optional"`
+	RawPhoneNumber    string    `parquet:"raw_phone_number,optional"`
+	Message           string    `parquet:"message"`
+	Status            string    `parquet:"status,dict"`
+	Direction         string    `parquet:"direction,dict"`
+	Carrier           string    `parquet:"carrier,optional,dict"`
+	CountryCode       string    `parquet:"country_code,optional,dict"`
+	DialCode          string    `parquet:"dial_code,optional,dict"`
+	SenderID          string    `parquet:"sender_id,optional,dict"`
+	SenderType        string    `parquet:"sender_type,optional,dict"`
+	SegmentCount      int32     `parquet:"segment_count,optional"`
+	Encoding          string    `parquet:"encoding,optional,dict"`
+	Script            string    `parquet:"script,optional,dict"`
+	Language          string    `parquet:"language,optional,dict"`
+	Flags             []string  `parquet:"flags,list"`
+	MediaCount        int32     `parquet:"media_count"`
+	CreatedAt         time.Time `parquet:"created_at,timestamp(millisecond)"`
+	UpdatedAt         time.Time `parquet:"updated_at,optional,timestamp(millisecond)"`
+}
+
+// parquetEncoder writes Snappy-compressed Parquet, which every warehouse loads
+type parquetEncoder struct{}
+
+func (parquetEncoder) encode(records []*models.SMSRecord) ([]byte, error) {
+	rows := make([]parquetRow, len(records))
+	for i, record := range records {
+		direction := record.Direction
+		if direction == "" {
+			direction = models.DirectionOutbound
+		}
+		rows[i] = parquetRow{
+			ID:                record.ID.Hex(),
+			TenantID:          record.TenantID,
+			UserID:            record.UserID,
+			MessageID:         record.MessageID,
+			ProviderMessageID: record.ProviderMessageID,
+			PhoneNumber:       record.PhoneNumber,
+			RawUserID:         record.RawUserID,
+			RawPhoneNumber:    record.RawPhoneNumber,
+			Message:           record.Message,
+			Status:            record.Status,
+			Direction:         direction,
+			Carrier:           record.Carrier,
+			CountryCode:       record.CountryCode,
+			DialCode:          record.DialCode,
+			SenderID:          record.SenderID,
+			SenderType:        record.SenderType,
+			SegmentCount:      int32(record.SegmentCount),
+			Encoding:          record.Encoding,
+			Script:            record.Script,
+			Language:          record.Language,
+			Flags:             record.Flags,
+			MediaCount:        int32(len(record.Media)),
+			CreatedAt:         record.CreatedAt.UTC(),
+			UpdatedAt:         record.UpdatedAt.UTC(),
+		}
+	}
+
+	var buf bytes.Buffer
+	w := parquet.NewGenericWriter[parquetRow](&buf, parquet.Compression(&parquet.Snappy))
+	if _, err := w.Write(rows); err != nil {
+		return nil, fmt.Errorf("failed to encode export file: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode export file: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (parquetEncoder) extension() string   { return "parquet" }
+func (parquetEncoder) contentType() string { return "application/vnd.apache.parquet" }
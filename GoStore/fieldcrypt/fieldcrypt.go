@@ -0,0 +1,158 @@
+// Package fieldcrypt encrypts individual record fields with AES-GCM. Each
+// ciphertext is tagged with the ID of its key, so keys can be rotated by adding
+// a new active key while older ones stay available for reading
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// prefix marks encrypted values, which read enc:v1:<key ID>:<base64 nonce and ciphertext>
+const prefix = "enc:v1:"
+
+// key is one AES key, with a subkey deriving the nonces of deterministic encryption
+type key struct {
+	aead     cipher.AEAD
+	nonceKey []byte
+}
+
+// Keyring encrypts with its active key and decrypts with any of its keys
+type Keyring struct {
+	active string
+	keys   map[string]*key
+}
+
+// ParseKeys parses comma-separated id:key pairs, each key a base64-encoded
+// 16, 24 or 32 byte AES key, returning the key IDs in the order given
+func ParseKeys(value string) ([]string, map[string][]byte, error) {
+	var ids []string
+	keys := make(map[string][]byte)
+	for n, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(pair, ":")
+		if !ok || id == "" {
+			// The pair itself is not quoted, since it may hold the key
+			return nil, nil, fmt.Errorf("invalid encryption key %d, expected id:base64key", n+1)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, nil, fmt.Errorf("duplicate encryption key ID %q", id)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encryption key %q is not valid base64", id)
+		}
+		if n := len(secret); n != 16 && n != 24 && n != 32 {
+			return nil, nil, fmt.Errorf("encryption key %q is %d bytes, expected 16, 24 or 32", id, n)
+		}
+		ids = append(ids, id)
+		keys[id] = secret
+	}
+	if len(ids) == 0 {
+		return nil, nil, fmt.Errorf("no encryption keys given")
+	}
+	return ids, keys, nil
+}
+
+// NewKeyring creates a keyring that encrypts with the key activeID
+func NewKeyring(keys map[string][]byte, activeID string) (*Keyring, error) {
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("active encryption key %q is not configured", activeID)
+	}
+	k := &Keyring{active: activeID, keys: make(map[string]*key, len(keys))}
+	for id, secret := range keys {
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte("fieldcrypt nonce key"))
+		k.keys[id] = &key{aead: aead, nonceKey: mac.Sum(nil)}
+	}
+	return k, nil
+}
+
+// ActiveKeyID returns the ID of the key new values are encrypted with
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Encrypt encrypts a field's value with a random nonce under the active key
+// The field name is authenticated, so a value cannot be moved to another field.
+// Empty values are left empty
+func (k *Keyring) Encrypt(field, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	active := k.keys[k.active]
+	nonce := make([]byte, active.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return k.seal(active, nonce, field, plaintext), nil
+}
+
+// EncryptDeterministic encrypts a field's value under the active key with a
+// nonce derived from the value, so equal values encrypt equally and can still
+// be matched by equality. It reveals which records share a value
+func (k *Keyring) EncryptDeterministic(field, plaintext string) string {
+	if plaintext == "" {
+		return ""
+	}
+	active := k.keys[k.active]
+	mac := hmac.New(sha256.New, active.nonceKey)
+	mac.Write([]byte(field))
+	mac.Write([]byte{0})
+	mac.Write([]byte(plaintext))
+	return k.seal(active, mac.Sum(nil)[:active.aead.NonceSize()], field, plaintext)
+}
+
+func (k *Keyring) seal(active *key, nonce []byte, field, plaintext string) string {
+	sealed := active.aead.Seal(nonce, nonce, []byte(plaintext), []byte(field))
+	return prefix + k.active + ":" + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// Decrypt decrypts a value encrypted for field with any key of the keyring
+// Values that are not encrypted, such as those stored before encryption was
+// enabled, are returned as they are
+func (k *Keyring) Decrypt(field, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", fmt.Errorf("malformed encrypted %s", field)
+	}
+	key, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%s is encrypted with unknown key %q", field, id)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < key.aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted %s", field)
+	}
+	nonce, ciphertext := sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():]
+	plaintext, err := key.aead.Open(nil, nonce, ciphertext, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s with key %q: %w", field, id, err)
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether value was produced by a Keyring
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
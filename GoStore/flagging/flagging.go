@@ -0,0 +1,146 @@
+// Package flagging flags messages whose text matches configured rules, such as
+// opt-out keywords like STOP or patterns typical of spam, so they can be found
+// and handled later. Rules are read from a YAML or JSON file and can be replaced
+// while the service runs
+package flagging
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// flagPattern restricts flag names to what query strings and metric labels carry safely
+var flagPattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// IsValidFlag reports whether name can name a flag
+func IsValidFlag(name string) bool {
+	return flagPattern.MatchString(name)
+}
+
+// Rule flags messages containing any of its keywords, as whole words in any
+// case, or matching any of its regular expressions
+type Rule struct {
+	Flag     string   `yaml:"flag"`
+	Keywords []string `yaml:"keywords"`
+	Patterns []string `yaml:"patterns"` // RE2 syntax; prefix (?i) to ignore case
+}
+
+// compiledRule is a Rule ready for matching
+type compiledRule struct {
+	flag     string
+	keywords []string // as normalized by words, each padded with spaces
+	patterns []*regexp.Regexp
+}
+
+// Flagger flags messages by the rules it was last given
+type Flagger struct {
+	rules atomic.Pointer[[]compiledRule]
+}
+
+// New creates a Flagger applying rules
+func New(rules []Rule) (*Flagger, error) {
+	f := &Flagger{}
+	if err := f.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// LoadFile reads the rules of a YAML or JSON file holding a list of rules, e.g.
+//
+//   - flag: opt_out
+//     keywords: [STOP, UNSUBSCRIBE, opt out]
+//   - flag: spam
+//     patterns: ["(?i)you have won"]
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flag rules: %w", err)
+	}
+	var rules []Rule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse flag rules %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// SetRules replaces the rules messages are flagged by. The current rules are
+// kept if any of the new ones is invalid
+func (f *Flagger) SetRules(rules []Rule) error {
+	compiled := make([]compiledRule, 0, len(rules))
+	for n, rule := range rules {
+		if !IsValidFlag(rule.Flag) {
+			return fmt.Errorf("flag rule %d: invalid flag %q; expected 1-32 lowercase letters, digits or underscores", n, rule.Flag)
+		}
+		if len(rule.Keywords) == 0 && len(rule.Patterns) == 0 {
+			return fmt.Errorf("flag rule %s: keywords or patterns are required", rule.Flag)
+		}
+		c := compiledRule{flag: rule.Flag}
+		for _, keyword := range rule.Keywords {
+			normalized := words(keyword)
+			if strings.TrimSpace(normalized) == "" {
+				return fmt.Errorf("flag rule %s: keyword %q has no letters or digits", rule.Flag, keyword)
+			}
+			c.keywords = append(c.keywords, normalized)
+		}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("flag rule %s: invalid pattern %q: %w", rule.Flag, pattern, err)
+			}
+			c.patterns = append(c.patterns, re)
+		}
+		compiled = append(compiled, c)
+	}
+	f.rules.Store(&compiled)
+	return nil
+}
+
+// Flags returns the flags of the rules text matches, in rule order, each once
+func (f *Flagger) Flags(text string) []string {
+	rules := *f.rules.Load()
+	if len(rules) == 0 || text == "" {
+		return nil
+	}
+
+	normalized := words(text)
+	var flags []string
+	for _, rule := range rules {
+		if !slices.Contains(flags, rule.flag) && rule.matches(text, normalized) {
+			flags = append(flags, rule.flag)
+		}
+	}
+	return flags
+}
+
+// matches reports whether text, or its normalized words, match the rule
+func (r *compiledRule) matches(text, normalized string) bool {
+	for _, keyword := range r.keywords {
+		if strings.Contains(normalized, keyword) {
+			return true
+		}
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// words lowercases text and reduces it to its words, runs of letters and
+// digits, separated and surrounded by single spaces, so keywords only match
+// whole words in scripts of any kind
+func words(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(fields, " ") + " "
+}
@@ -0,0 +1,329 @@
+package gql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// Handler is the /graphql HTTP handler
+type Handler struct {
+	relay.Handler
+	resolver *resolver
+}
+
+// NewHandler builds the /graphql HTTP handler backed by the SMS service, recording
+// every read of messages with auditService
+func NewHandler(smsService *services.SMSService, auditService *services.AuditService) (*Handler, error) {
+	root := &resolver{smsService: smsService, auditService: auditService}
+	schema, err := graphql.ParseSchema(schemaSDL, root, graphql.UseFieldResolvers())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GraphQL schema: %w", err)
+	}
+	return &Handler{Handler: relay.Handler{Schema: schema}, resolver: root}, nil
+}
+
+// requestKey is the context key of the HTTP request a query is resolved for
+type requestKey struct{}
+
+// ServeHTTP resolves the query of r, with r in the context for audit records
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestKey{}, r)))
+}
+
+// SetMaxPageSize sets the largest first argument accepted by messages queries
+func (h *Handler) SetMaxPageSize(size int) {
+	h.resolver.maxPageSize.Store(int64(size))
+}
+
+// resolver is the root query resolver
+type resolver struct {
+	smsService   *services.SMSService
+	auditService *services.AuditService
+
+	// maxPageSize caps the number of messages returned by a single messages query
+	// It is set from the configuration at startup and on reload
+	maxPageSize atomic.Int64
+}
+
+type messageFilterInput struct {
+	Status *[]string
+	Since  *graphql.Time
+	Until  *graphql.Time
+}
+
+type messagesArgs struct {
+	UserId string
+	Filter *messageFilterInput
+	First  int32 // Defaults to 50 in the schema
+	After  *string
+}
+
+// Messages resolves Query.messages
+func (r *resolver) Messages(ctx context.Context, args messagesArgs) (*connectionResolver, error) {
+	if !r.smsService.IsValidPhoneNumber(args.UserId) {
+		return nil, errors.New("invalid userId format, expected phone number")
+	}
+
+	first := int64(args.First)
+	if limit := r.maxPageSize.Load(); first < 1 || first > limit {
+		return nil, fmt.Errorf("first must be between 1 and %d", limit)
+	}
+
+	offset := int64(0)
+	if args.After != nil {
+		var err error
+		if offset, err = decodeCursor(*args.After); err != nil {
+			return nil, err
+		}
+	}
+
+	query := &models.MessageQuery{UserID: args.UserId, Skip: offset, Limit: first + 1}
+	if f := args.Filter; f != nil {
+		if f.Status != nil {
+			query.Statuses = *f.Status
+		}
+		if f.Since != nil {
+			query.Since = f.Since.Time
+		}
+		if f.Until != nil {
+			query.Until = f.Until.Time
+		}
+	}
+
+	records, err := r.smsService.FindMessages(ctx, query)
+	if err != nil {
+		return nil, errors.New("failed to retrieve messages")
+	}
+
+	// One extra record was fetched to detect whether another page exists
+	hasNext := int64(len(records)) > first
+	if hasNext {
+		records = records[:first]
+	}
+
+	audit := newAuditRecord(ctx, models.AuditActionQueryMessages, "messages")
+	audit.UserID = args.UserId
+	audit.ResultCount = int64(len(records))
+	audit.Filters["first"] = strconv.Itoa(int(args.First))
+	if args.After != nil {
+		audit.Filters["after"] = *args.After
+	}
+	if f := args.Filter; f != nil {
+		if f.Status != nil {
+			audit.Filters["status"] = strings.Join(*f.Status, ",")
+		}
+		if f.Since != nil {
+			audit.Filters["since"] = f.Since.Format(time.RFC3339)
+		}
+		if f.Until != nil {
+			audit.Filters["until"] = f.Until.Format(time.RFC3339)
+		}
+	}
+	if err := r.recordAudit(ctx, audit); err != nil {
+		return nil, err
+	}
+
+	return &connectionResolver{
+		smsService: r.smsService,
+		query:      query,
+		records:    records,
+		offset:     offset,
+		hasNext:    hasNext,
+	}, nil
+}
+
+// Message resolves Query.message
+func (r *resolver) Message(ctx context.Context, args struct{ ID graphql.ID }) (*messageResolver, error) {
+	record, err := r.smsService.GetMessageByID(ctx, string(args.ID))
+	if errors.Is(err, services.ErrMessageNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to retrieve message")
+	}
+
+	audit := newAuditRecord(ctx, models.AuditActionReadMessages, "message")
+	audit.UserID = record.UserID
+	audit.TargetID = record.ID.Hex()
+	audit.ResultCount = 1
+	if err := r.recordAudit(ctx, audit); err != nil {
+		return nil, err
+	}
+	return &messageResolver{record: record}, nil
+}
+
+// newAuditRecord starts the audit record of a read by the query field: who made
+// it, from where, and with which arguments, as the REST API records them
+func newAuditRecord(ctx context.Context, action, field string) *models.AuditRecord {
+	audit := &models.AuditRecord{
+		Action:  action,
+		Filters: map[string]string{"field": field},
+	}
+	if r, ok := ctx.Value(requestKey{}).(*http.Request); ok {
+		audit.Endpoint = r.Pattern
+		audit.RemoteAddr = r.RemoteAddr
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		audit.Actor = principal.Subject
+		audit.AuthMethod = principal.Method
+	}
+	return audit
+}
+
+// recordAudit writes the audit record of a read before its data is returned,
+// failing the field when that fails, so nothing is disclosed without a trace
+func (r *resolver) recordAudit(ctx context.Context, audit *models.AuditRecord) error {
+	if err := r.auditService.Record(ctx, audit); err != nil {
+		slog.ErrorContext(ctx, "Error writing audit record", "audit_action", audit.Action, "error", err)
+		return errors.New("failed to write audit record")
+	}
+	return nil
+}
+
+// connectionResolver resolves MessageConnection
+type connectionResolver struct {
+	smsService *services.SMSService
+	query      *models.MessageQuery
+	records    []*models.SMSRecord
+	offset     int64
+	hasNext    bool
+}
+
+// TotalCount is only computed when the client selects it
+func (c *connectionResolver) TotalCount(ctx context.Context) (int32, error) {
+	count, err := c.smsService.CountMatchingMessages(ctx, c.query)
+	if err != nil {
+		return 0, errors.New("failed to count messages")
+	}
+	return int32(count), nil
+}
+
+func (c *connectionResolver) Edges() []*edgeResolver {
+	edges := make([]*edgeResolver, len(c.records))
+	for i, record := range c.records {
+		edges[i] = &edgeResolver{
+			cursor: encodeCursor(c.offset + int64(i) + 1),
+			node:   &messageResolver{record: record},
+		}
+	}
+	return edges
+}
+
+func (c *connectionResolver) PageInfo() *pageInfoResolver {
+	info := &pageInfoResolver{hasNext: c.hasNext}
+	if len(c.records) > 0 {
+		cursor := encodeCursor(c.offset + int64(len(c.records)))
+		info.endCursor = &cursor
+	}
+	return info
+}
+
+// edgeResolver resolves MessageEdge
+type edgeResolver struct {
+	cursor string
+	node   *messageResolver
+}
+
+func (e *edgeResolver) Cursor() string         { return e.cursor }
+func (e *edgeResolver) Node() *messageResolver { return e.node }
+
+// pageInfoResolver resolves PageInfo
+type pageInfoResolver struct {
+	hasNext   bool
+	endCursor *string
+}
+
+func (p *pageInfoResolver) HasNextPage() bool  { return p.hasNext }
+func (p *pageInfoResolver) EndCursor() *string { return p.endCursor }
+
+// messageResolver resolves Message
+type messageResolver struct {
+	record *models.SMSRecord
+}
+
+func (m *messageResolver) ID() graphql.ID     { return graphql.ID(m.record.ID.Hex()) }
+func (m *messageResolver) MessageId() *string { return optionalString(m.record.MessageID) }
+func (m *messageResolver) ProviderMessageId() *string {
+	return optionalString(m.record.ProviderMessageID)
+}
+func (m *messageResolver) UserId() string          { return m.record.UserID }
+func (m *messageResolver) PhoneNumber() string     { return m.record.PhoneNumber }
+func (m *messageResolver) Message() string         { return m.record.Message }
+func (m *messageResolver) Status() string          { return m.record.Status }
+func (m *messageResolver) CreatedAt() graphql.Time { return graphql.Time{Time: m.record.CreatedAt} }
+
+func (m *messageResolver) UpdatedAt() *graphql.Time {
+	if m.record.UpdatedAt.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: m.record.UpdatedAt}
+}
+
+// StatusHistory supports nested filtering by status
+func (m *messageResolver) StatusHistory(args struct{ Status *[]string }) []*statusChangeResolver {
+	var history []*statusChangeResolver
+	for i := range m.record.StatusHistory {
+		change := &m.record.StatusHistory[i]
+		if args.Status != nil && !slices.Contains(*args.Status, change.Status) {
+			continue
+		}
+		history = append(history, &statusChangeResolver{change: change})
+	}
+	return history
+}
+
+// statusChangeResolver resolves StatusChange
+type statusChangeResolver struct {
+	change *models.StatusChange
+}
+
+func (s *statusChangeResolver) Status() string  { return s.change.Status }
+func (s *statusChangeResolver) Reason() *string { return optionalString(s.change.Reason) }
+func (s *statusChangeResolver) ChangedAt() graphql.Time {
+	return graphql.Time{Time: s.change.ChangedAt}
+}
+
+// optionalString maps an empty string to a GraphQL null
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// encodeCursor produces an opaque cursor for the given result offset
+func encodeCursor(offset int64) string {
+	return base64.StdEncoding.EncodeToString([]byte("offset:" + strconv.FormatInt(offset, 10)))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	offset, err := strconv.ParseInt(strings.TrimPrefix(string(raw), "offset:"), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return offset, nil
+}
@@ -0,0 +1,47 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// newAuditRecord starts the audit record of a call: who made it, from where, and
+// to which method, as the REST API records requests
+func newAuditRecord(ctx context.Context, action string) *models.AuditRecord {
+	audit := &models.AuditRecord{Action: action}
+	audit.Endpoint, _ = grpc.Method(ctx)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		audit.RemoteAddr = p.Addr.String()
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		audit.Actor = principal.Subject
+		audit.AuthMethod = principal.Method
+	}
+	return audit
+}
+
+// recordAudit writes the audit record of a call before its data is returned.
+// When that fails the call fails with Internal, so nothing is disclosed without
+// a trace
+func (s *Server) recordAudit(ctx context.Context, audit *models.AuditRecord) error {
+	if err := s.auditService.Record(ctx, audit); err != nil {
+		slog.ErrorContext(ctx, "gRPC: error writing audit record", "audit_action", audit.Action, "error", err)
+		return status.Error(codes.Internal, "failed to write audit record")
+	}
+	return nil
+}
+
+// recordCompleted writes the audit record of a call whose data is already sent,
+// so a failure can only be logged
+func (s *Server) recordCompleted(ctx context.Context, audit *models.AuditRecord) {
+	if err := s.auditService.Record(ctx, audit); err != nil {
+		slog.ErrorContext(ctx, "gRPC: error writing audit record", "audit_action", audit.Action, "error", err)
+	}
+}
@@ -0,0 +1,55 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/models"
+	smsstorev1 "github.com/ramG-reddy/sms-store/proto/smsstore/v1"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+func TestReadsAreAudited(t *testing.T) {
+	st := store.NewMemoryStore()
+	smsService := services.NewSMSService(st, events.NewBroker())
+	auditService := services.NewAuditService(st)
+	s := &Server{smsService: smsService, auditService: auditService}
+
+	principal := &auth.Principal{Subject: "reporting", Method: "api_key", TenantID: "t1", Scopes: []string{models.ScopeRead}}
+	ctx := tenant.WithID(auth.WithPrincipal(context.Background(), principal), "t1")
+	record := &models.SMSRecord{MessageID: "m1", TenantID: "t1", UserID: "+14155550100", PhoneNumber: "+14155550100", Message: "hello", CreatedAt: time.Now().UTC()}
+	if err := smsService.SaveMessage(ctx, record); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	if _, err := s.GetUserMessages(ctx, &smsstorev1.GetUserMessagesRequest{UserId: "+14155550100", Limit: 10}); err != nil {
+		t.Fatalf("GetUserMessages: %v", err)
+	}
+	if _, err := s.GetMessage(ctx, &smsstorev1.GetMessageRequest{Id: record.ID.Hex()}); err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+
+	audit, err := auditService.Find(ctx, &models.AuditQuery{Action: models.AuditActionReadMessages})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(audit) != 2 {
+		t.Fatalf("found %d audit records, want 2", len(audit))
+	}
+	for _, a := range audit {
+		if a.Actor != "reporting" || a.AuthMethod != "api_key" || a.UserID != "+14155550100" || a.ResultCount != 1 {
+			t.Errorf("audit record = %+v", a)
+		}
+	}
+	if byID := audit[0]; byID.TargetID != record.ID.Hex() {
+		t.Errorf("newest audit record targets %q, want %q", byID.TargetID, record.ID.Hex())
+	}
+	if byUser := audit[1]; byUser.Filters["limit"] != "10" {
+		t.Errorf("oldest audit record filters = %v, want limit 10", byUser.Filters)
+	}
+}
@@ -0,0 +1,97 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// credentialKeys are the metadata keys carrying credentials, which the REST
+// API's authenticators read as the HTTP headers of the same names
+var credentialKeys = []string{auth.APIKeyHeader, "Authorization"}
+
+// authenticate verifies the credentials in the call metadata with the REST API's
+// authenticators, tried in order, and scopes ctx to the principal's tenant. Every
+// call is a read, so the principal needs the read scope. Without authenticators
+// the tenant is taken from the x-tenant-id metadata, as the REST API takes it
+// from X-Tenant-ID
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	if len(s.authenticators) == 0 {
+		return tenantFromMetadata(ctx)
+	}
+
+	// The authenticators read an HTTP request, so the credentials are handed
+	// to them as one
+	md, _ := metadata.FromIncomingContext(ctx)
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to authenticate call")
+	}
+	for _, key := range credentialKeys {
+		if values := md.Get(key); len(values) > 0 {
+			r.Header.Set(key, values[0])
+		}
+	}
+
+	principal, err := s.principal(r)
+	if errors.Is(err, auth.ErrNoCredentials) {
+		return nil, status.Error(codes.Unauthenticated, "missing credentials: provide x-api-key or a bearer token in authorization metadata")
+	}
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "gRPC: error authenticating call", "error", err)
+		return nil, status.Error(codes.Internal, "failed to authenticate call")
+	}
+
+	if !principal.HasScope(models.ScopeRead) {
+		slog.InfoContext(ctx, "Principal lacks required scope", "auth_method", principal.Method, "subject", principal.Subject, "scope", models.ScopeRead)
+		return nil, status.Error(codes.PermissionDenied, "caller lacks the "+models.ScopeRead+" scope")
+	}
+	// A principal only ever grants access to its own tenant
+	if requested := md.Get(tenant.Header); len(requested) > 0 && requested[0] != principal.TenantID {
+		return nil, status.Error(codes.PermissionDenied, "credentials are not valid for the requested tenant")
+	}
+	return tenant.WithID(auth.WithPrincipal(ctx, principal), principal.TenantID), nil
+}
+
+// principal returns the principal from the first authenticator that recognises
+// credentials on r
+func (s *Server) principal(r *http.Request) (*auth.Principal, error) {
+	for _, authenticator := range s.authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, auth.ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, auth.ErrNoCredentials
+}
+
+// unaryAuthInterceptor authenticates every unary call
+func (s *Server) unaryAuthInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamAuthInterceptor authenticates every streaming call
+func (s *Server) streamAuthInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
@@ -0,0 +1,62 @@
+package grpcserver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// keyAuthenticator accepts the API keys it maps to principals
+type keyAuthenticator map[string]*auth.Principal
+
+func (a keyAuthenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	key := r.Header.Get(auth.APIKeyHeader)
+	if key == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	principal, ok := a[key]
+	if !ok {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return principal, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := &Server{authenticators: []auth.Authenticator{keyAuthenticator{
+		"reader": {Subject: "reader", TenantID: "t1", Scopes: []string{models.ScopeRead}},
+		"writer": {Subject: "writer", TenantID: "t1", Scopes: []string{models.ScopeWrite}},
+	}}}
+
+	tests := []struct {
+		name   string
+		md     metadata.MD
+		code   codes.Code
+		tenant string
+	}{
+		{"no credentials", metadata.Pairs(tenant.Header, "t1"), codes.Unauthenticated, ""},
+		{"unknown key", metadata.Pairs("x-api-key", "guess"), codes.Unauthenticated, ""},
+		{"missing scope", metadata.Pairs("x-api-key", "writer"), codes.PermissionDenied, ""},
+		{"other tenant", metadata.Pairs("x-api-key", "reader", tenant.Header, "t2"), codes.PermissionDenied, ""},
+		{"key's tenant", metadata.Pairs("x-api-key", "reader"), codes.OK, "t1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := s.authenticate(metadata.NewIncomingContext(context.Background(), tt.md))
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("authenticate = %v, want %v", err, tt.code)
+			}
+			if err == nil {
+				if id, _ := tenant.FromContext(ctx); id != tt.tenant {
+					t.Errorf("tenant = %q, want %q", id, tt.tenant)
+				}
+			}
+		})
+	}
+}
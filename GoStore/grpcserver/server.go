@@ -0,0 +1,283 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/opmode"
+	smsstorev1 "github.com/ramG-reddy/sms-store/proto/smsstore/v1"
+	"github.com/ramG-reddy/sms-store/requestid"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server exposes the SMS store over gRPC
+type Server struct {
+	smsstorev1.UnimplementedSMSStoreServiceServer
+
+	smsService     *services.SMSService
+	auditService   *services.AuditService
+	modes          *opmode.Switch
+	authenticators []auth.Authenticator
+	grpcServer     *grpc.Server
+}
+
+// NewServer creates a new gRPC server instance. Callers are authenticated by
+// authenticators as on the REST API, and their reads recorded with auditService.
+// Every call is a read, so all are refused while the service mode of modes
+// refuses reads
+func NewServer(smsService *services.SMSService, auditService *services.AuditService, modes *opmode.Switch, authenticators []auth.Authenticator, enableReflection bool) *Server {
+	s := &Server{smsService: smsService, auditService: auditService, modes: modes, authenticators: authenticators}
+	s.grpcServer = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryRequestIDInterceptor, s.unaryModeInterceptor, s.unaryAuthInterceptor),
+		grpc.ChainStreamInterceptor(streamRequestIDInterceptor, s.streamModeInterceptor, s.streamAuthInterceptor),
+	)
+
+	smsstorev1.RegisterSMSStoreServiceServer(s.grpcServer, s)
+	if enableReflection {
+		reflection.Register(s.grpcServer)
+	}
+	return s
+}
+
+// requestIDFromMetadata honors a valid x-request-id metadata value or generates
+// a new one, and returns it to the caller as response header metadata
+func requestIDFromMetadata(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(requestid.Header)
+	id := ""
+	if len(values) > 0 {
+		id = values[0]
+	}
+	if !requestid.IsValid(id) {
+		id = requestid.New()
+	}
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestid.Header, id)); err != nil {
+		slog.WarnContext(ctx, "Error setting gRPC request ID header", "error", err)
+	}
+	return requestid.WithID(ctx, id)
+}
+
+// unaryRequestIDInterceptor attaches a request ID to every unary call
+func unaryRequestIDInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(requestIDFromMetadata(ctx), req)
+}
+
+// streamRequestIDInterceptor attaches a request ID to every streaming call
+func streamRequestIDInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: requestIDFromMetadata(ss.Context())})
+}
+
+// tenantFromMetadata scopes ctx to the tenant in the x-tenant-id metadata key,
+// for servers without authentication
+func tenantFromMetadata(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(tenant.Header)
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing x-tenant-id metadata")
+	}
+	if !tenant.IsValidID(values[0]) {
+		return nil, status.Error(codes.InvalidArgument, "invalid x-tenant-id metadata")
+	}
+	return tenant.WithID(ctx, values[0]), nil
+}
+
+// servingReads fails with Unavailable while the service mode refuses reads
+func (s *Server) servingReads() error {
+	if mode := s.modes.Mode(); !mode.Reads() {
+		return status.Errorf(codes.Unavailable, "reads are not served while the service is %s", mode)
+	}
+	return nil
+}
+
+// unaryModeInterceptor refuses unary calls while the service mode refuses reads
+func (s *Server) unaryModeInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.servingReads(); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamModeInterceptor refuses streaming calls while the service mode refuses reads
+func (s *Server) streamModeInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.servingReads(); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// contextStream overrides the stream context with one scoped by an interceptor
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// Serve begins serving gRPC requests on listener in a background goroutine
+func (s *Server) Serve(listener net.Listener) {
+	go func() {
+		slog.Info("gRPC server listening", "address", listener.Addr().String())
+		if err := s.grpcServer.Serve(listener); err != nil {
+			slog.Error("gRPC server stopped with error", "error", err)
+		}
+	}()
+}
+
+// Stop drains in-flight RPCs, forcing the server closed once ctx expires
+func (s *Server) Stop(ctx context.Context) {
+	slog.Info("Stopping gRPC server")
+
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("gRPC server stopped gracefully")
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		slog.Warn("gRPC server forced to stop")
+	}
+}
+
+// GetUserMessages returns a user's messages, newest first
+func (s *Server) GetUserMessages(ctx context.Context, req *smsstorev1.GetUserMessagesRequest) (*smsstorev1.GetUserMessagesResponse, error) {
+	if !s.smsService.IsValidPhoneNumber(req.GetUserId()) {
+		return nil, status.Error(codes.InvalidArgument, "invalid user_id format, expected phone number")
+	}
+
+	var records []*models.SMSRecord
+	var err error
+	if req.GetLimit() > 0 {
+		records, err = s.smsService.GetRecentMessages(ctx, req.GetUserId(), req.GetLimit())
+	} else {
+		records, err = s.smsService.GetMessagesByUserID(ctx, req.GetUserId(), models.MessageFilter{}, nil, models.MessageSort{})
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "gRPC: error retrieving messages", "user_id", req.GetUserId(), "error", err)
+		return nil, status.Error(codes.Internal, "failed to retrieve messages")
+	}
+
+	audit := newAuditRecord(ctx, models.AuditActionReadMessages)
+	audit.UserID = req.GetUserId()
+	audit.ResultCount = int64(len(records))
+	if req.GetLimit() > 0 {
+		audit.Filters = map[string]string{"limit": strconv.FormatInt(req.GetLimit(), 10)}
+	}
+	if err := s.recordAudit(ctx, audit); err != nil {
+		return nil, err
+	}
+
+	resp := &smsstorev1.GetUserMessagesResponse{
+		Messages: make([]*smsstorev1.SMSRecord, 0, len(records)),
+	}
+	for _, record := range records {
+		resp.Messages = append(resp.Messages, toProto(record))
+	}
+	return resp, nil
+}
+
+// GetMessage returns a single message by its document ID
+func (s *Server) GetMessage(ctx context.Context, req *smsstorev1.GetMessageRequest) (*smsstorev1.SMSRecord, error) {
+	record, err := s.smsService.GetMessageByID(ctx, req.GetId())
+	if errors.Is(err, services.ErrMessageNotFound) {
+		return nil, status.Error(codes.NotFound, "message not found")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "gRPC: error retrieving message", "id", req.GetId(), "error", err)
+		return nil, status.Error(codes.Internal, "failed to retrieve message")
+	}
+
+	audit := newAuditRecord(ctx, models.AuditActionReadMessages)
+	audit.UserID = record.UserID
+	audit.TargetID = record.ID.Hex()
+	audit.ResultCount = 1
+	if err := s.recordAudit(ctx, audit); err != nil {
+		return nil, err
+	}
+	return toProto(record), nil
+}
+
+// StreamUserMessages streams a user's messages, newest first, straight from the database cursor
+func (s *Server) StreamUserMessages(req *smsstorev1.GetUserMessagesRequest, stream grpc.ServerStreamingServer[smsstorev1.SMSRecord]) error {
+	if !s.smsService.IsValidPhoneNumber(req.GetUserId()) {
+		return status.Error(codes.InvalidArgument, "invalid user_id format, expected phone number")
+	}
+
+	sent := 0
+	err := s.smsService.StreamMessagesByUserID(stream.Context(), req.GetUserId(), models.MessageFilter{}, nil, models.MessageSort{}, req.GetLimit(), func(record *models.SMSRecord) error {
+		if err := stream.Send(toProto(record)); err != nil {
+			return err
+		}
+		sent++
+		return nil
+	})
+
+	// Recorded once the messages are sent, as REST exports are, aborted streams
+	// included, so the count is what left the service
+	audit := newAuditRecord(stream.Context(), models.AuditActionExportMessages)
+	audit.UserID = req.GetUserId()
+	audit.ResultCount = int64(sent)
+	if req.GetLimit() > 0 {
+		audit.Filters = map[string]string{"limit": strconv.FormatInt(req.GetLimit(), 10)}
+	}
+	s.recordCompleted(stream.Context(), audit)
+
+	if err != nil {
+		if stream.Context().Err() != nil {
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+		slog.ErrorContext(stream.Context(), "gRPC: error streaming messages", "user_id", req.GetUserId(), "error", err)
+		return status.Error(codes.Internal, "failed to stream messages")
+	}
+	return nil
+}
+
+// toProto converts a stored record into its protobuf representation
+func toProto(record *models.SMSRecord) *smsstorev1.SMSRecord {
+	pb := &smsstorev1.SMSRecord{
+		Id:                record.ID.Hex(),
+		MessageId:         record.MessageID,
+		ProviderMessageId: record.ProviderMessageID,
+		UserId:            record.UserID,
+		PhoneNumber:       record.PhoneNumber,
+		Message:           record.Message,
+		Status:            record.Status,
+		CreatedAt:         toTimestamp(record.CreatedAt),
+		UpdatedAt:         toTimestamp(record.UpdatedAt),
+	}
+	for _, change := range record.StatusHistory {
+		pb.StatusHistory = append(pb.StatusHistory, &smsstorev1.StatusChange{
+			Status:    change.Status,
+			Reason:    change.Reason,
+			ChangedAt: toTimestamp(change.ChangedAt),
+		})
+	}
+	return pb
+}
+
+// toTimestamp converts a time to a protobuf timestamp, mapping the zero time to nil
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Windows of delivery stats
+const (
+	defaultDeliveryWindow   = 24 * time.Hour
+	maxDeliveryWindow       = 31 * 24 * time.Hour
+	maxHourlyDeliveryWindow = 7 * 24 * time.Hour
+)
+
+// deliveryStatsResponse is the body of delivery stats
+type deliveryStatsResponse struct {
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Interval string    `json:"interval"`
+	*models.DeliveryStats
+}
+
+// GetDeliveryStats handles GET /v0/analytics/delivery?since=&until=&interval=&sender_id=&carrier=&country=&dial_code=
+// Counts how many of the tenant's outbound messages created in the window
+// (default the last 24 hours, at most 31 days) were delivered, failed or are
+// pending, by sender ID, carrier, country and hourly or daily bucket, so a
+// failing carrier or sender shows up as a drop in its delivery rate
+func (h *SMSHandler) GetDeliveryStats(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	until := time.Now().UTC()
+	if value := params.Get("until"); value != "" {
+		var err error
+		if until, err = time.Parse(time.RFC3339, value); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid until. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+	since := until.Add(-defaultDeliveryWindow)
+	if value := params.Get("since"); value != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, value); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid since. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+	if !since.Before(until) || until.Sub(since) > maxDeliveryWindow {
+		respondWithError(w, http.StatusBadRequest, "Invalid window. Expected since before until, at most 31 days apart.")
+		return
+	}
+
+	interval := params.Get("interval")
+	if interval == "" {
+		interval = models.DeliveryIntervalHour
+	}
+	if !models.IsValidDeliveryInterval(interval) {
+		respondWithError(w, http.StatusBadRequest, "Invalid interval. Expected hour or day.")
+		return
+	}
+	if interval == models.DeliveryIntervalHour && until.Sub(since) > maxHourlyDeliveryWindow {
+		respondWithError(w, http.StatusBadRequest, "Invalid window. Hourly buckets cover at most 7 days; use interval=day.")
+		return
+	}
+
+	countryCode, dialCode, err := parseRegion(params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := &models.DeliveryQuery{
+		Since:       since,
+		Until:       until,
+		Interval:    interval,
+		SenderID:    params.Get("sender_id"),
+		Carrier:     params.Get("carrier"),
+		CountryCode: countryCode,
+		DialCode:    dialCode,
+	}
+	stats, err := cachedResponse(h, w, r, CacheRouteDelivery, "", func(ctx context.Context) (*models.DeliveryStats, error) {
+		return h.smsService.GetDeliveryStats(ctx, query)
+	})
+	if errors.Is(err, errors.ErrUnsupported) {
+		respondWithError(w, http.StatusNotImplemented, "Delivery analytics are not supported by the storage backend")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error counting delivery outcomes", "error", err)
+		respondWithStoreError(w, err, "Failed to count delivery outcomes")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionReadAnalytics)
+	audit.ResultCount = stats.Total
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, deliveryStatsResponse{Since: since.UTC(), Until: until.UTC(), Interval: interval, DeliveryStats: stats})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// APIKeyHandler handles HTTP requests for API key management
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+	usageService  *services.UsageService // nil while usage isn't metered
+	auditService  *services.AuditService
+}
+
+// NewAPIKeyHandler creates a new API key handler instance, reporting usage from
+// usageService and recording every key created or revoked with auditService
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService, usageService *services.UsageService, auditService *services.AuditService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		usageService:  usageService,
+		auditService:  auditService,
+	}
+}
+
+// maxUsageDays caps the days of usage returned at once
+const maxUsageDays = 366
+
+// createAPIKeyRequest is the payload for POST /v0/api-keys
+type createAPIKeyRequest struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	DailyQuota int64    `json:"daily_quota,omitzero"` // requests per UTC day; omitted applies API_KEY_DAILY_QUOTA
+}
+
+// apiKeyResponse is the body of a created API key
+type apiKeyResponse struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	TenantID   string    `json:"tenant_id"`
+	Prefix     string    `json:"prefix"`
+	Scopes     []string  `json:"scopes"`
+	DailyQuota int64     `json:"daily_quota,omitzero"`
+	Key        string    `json:"key"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// apiKeyUsageResponse is the body of GET /v0/api-keys/{id}/usage
+type apiKeyUsageResponse struct {
+	KeyID string                `json:"key_id"`
+	Days  []*models.APIKeyUsage `json:"days"`
+}
+
+// CreateAPIKey handles POST /v0/api-keys
+// The raw key is only returned in this response
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid API key payload")
+		return
+	}
+
+	key := &models.APIKey{Name: req.Name, Scopes: req.Scopes, DailyQuota: req.DailyQuota}
+	if err := key.Validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rawKey, err := h.apiKeyService.Create(r.Context(), key)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating API key", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionCreateAPIKey)
+	audit.TargetID = key.ID.Hex()
+	recordCompleted(r, h.auditService, audit)
+
+	respondWithJSON(w, http.StatusCreated, apiKeyResponse{
+		ID:         key.ID.Hex(),
+		Name:       key.Name,
+		TenantID:   key.TenantID,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		DailyQuota: key.DailyQuota,
+		Key:        rawKey,
+		CreatedAt:  key.CreatedAt,
+	})
+}
+
+// RevokeAPIKey handles DELETE /v0/api-keys/{id}
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := h.apiKeyService.Revoke(r.Context(), id)
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		respondWithError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking API key", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionRevokeAPIKey)
+	audit.TargetID = id
+	recordCompleted(r, h.auditService, audit)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAPIKeyUsage handles GET /v0/api-keys/{id}/usage?days=30
+// Returns the key's requests and bytes served per UTC day, most recent first
+func (h *APIKeyHandler) GetAPIKeyUsage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	days := 30
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxUsageDays {
+			respondWithError(w, http.StatusBadRequest, "days must be an integer from 1 to "+strconv.Itoa(maxUsageDays))
+			return
+		}
+		days = parsed
+	}
+
+	usage, err := h.usageService.Usage(r.Context(), id, days)
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		respondWithError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving API key usage", "api_key_id", id, "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve API key usage")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionReadAPIKeyUsage)
+	audit.TargetID = id
+	audit.ResultCount = int64(len(usage))
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, apiKeyUsageResponse{KeyID: id, Days: usage})
+}
+
+// ResetAPIKeyUsage handles DELETE /v0/api-keys/{id}/usage
+// Clears the key's usage today, so a key over its quota can be used again
+func (h *APIKeyHandler) ResetAPIKeyUsage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := h.usageService.Reset(r.Context(), id)
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		respondWithError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting API key usage", "api_key_id", id, "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to reset API key usage")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionResetAPIKeyUsage)
+	audit.TargetID = id
+	recordCompleted(r, h.auditService, audit)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// Page size of audit trail queries
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditHandler serves the audit trail of data access and admin operations
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+// NewAuditHandler creates a new audit handler instance
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// GetAuditLog handles GET /v0/audit?actor=&user_id=&action=&target_id=&since=&until=&skip=N&limit=N
+// Lists the tenant's audit records, newest first. Reading the trail is audited too
+func (h *AuditHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := &models.AuditQuery{
+		Actor:    params.Get("actor"),
+		UserID:   params.Get("user_id"),
+		Action:   params.Get("action"),
+		TargetID: params.Get("target_id"),
+	}
+	if query.UserID != "" && !isValidPhoneNumber(query.UserID) {
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+
+	var err error
+	if since := params.Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid since. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+	if until := params.Get("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid until. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+
+	var ok bool
+	if query.Skip, query.Limit, ok = parsePage(w, r, defaultAuditLimit, maxAuditLimit); !ok {
+		return
+	}
+
+	records, err := h.auditService.Find(r.Context(), query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving audit records", "error", err)
+		respondWithStoreError(w, err, "Failed to retrieve audit records")
+		return
+	}
+	if records == nil {
+		records = make([]*models.AuditRecord, 0)
+	}
+
+	audit := newAuditRecord(r, models.AuditActionReadAuditLog)
+	audit.ResultCount = int64(len(records))
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, records)
+}
+
+// newAuditRecord starts the audit record of a request: who made it, to which
+// route, and with which query parameters
+func newAuditRecord(r *http.Request, action string) *models.AuditRecord {
+	audit := &models.AuditRecord{
+		Action:     action,
+		Endpoint:   r.Pattern,
+		RemoteAddr: r.RemoteAddr,
+	}
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		audit.Actor = principal.Subject
+		audit.AuthMethod = principal.Method
+	}
+	for key, values := range r.URL.Query() {
+		if audit.Filters == nil {
+			audit.Filters = make(map[string]string)
+		}
+		audit.Filters[key] = strings.Join(values, ",")
+	}
+	return audit
+}
+
+// recordAudit writes the audit record of a request before its data is served.
+// When that fails it responds 500 Internal Server Error and returns false, so
+// nothing is disclosed without a trace
+func recordAudit(w http.ResponseWriter, r *http.Request, auditService *services.AuditService, audit *models.AuditRecord) bool {
+	if err := auditService.Record(r.Context(), audit); err != nil {
+		slog.ErrorContext(r.Context(), "Error writing audit record", "audit_action", audit.Action, "error", err)
+		respondWithStoreError(w, err, "Failed to write audit record")
+		return false
+	}
+	return true
+}
+
+// recordCompleted writes the audit record of an operation that has already
+// taken effect, or whose response is already under way, so a failure can only
+// be logged
+func recordCompleted(r *http.Request, auditService *services.AuditService, audit *models.AuditRecord) {
+	if err := auditService.Record(r.Context(), audit); err != nil {
+		slog.ErrorContext(r.Context(), "Error writing audit record", "audit_action", audit.Action, "error", err)
+	}
+}
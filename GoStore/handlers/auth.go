@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+// Auth enforces authentication and per-principal scopes on HTTP routes
+// Authenticators are tried in order; the first that finds its credentials decides
+type Auth struct {
+	authenticators []auth.Authenticator
+
+	// usage meters API key requests when set, see EnableUsage
+	usage        *services.UsageService
+	defaultQuota int64
+}
+
+// NewAuth creates the auth middleware. With no authenticators, routes only require X-Tenant-ID
+func NewAuth(authenticators ...auth.Authenticator) *Auth {
+	return &Auth{
+		authenticators: authenticators,
+	}
+}
+
+// Require wraps next so it only runs for callers granted scope
+// The request context is scoped to the principal's tenant. Requests the rate
+// limiter left to the route are limited by principal, or by client IP when
+// authentication fails
+func (a *Auth) Require(scope string, next http.HandlerFunc) http.HandlerFunc {
+	if len(a.authenticators) == 0 {
+		requireTenant := RequireTenant(next)
+		return func(w http.ResponseWriter, r *http.Request) {
+			if limitClientIP(w, r) {
+				requireTenant(w, r)
+			}
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.authenticate(r)
+		if err != nil && !limitClientIP(w, r) {
+			return
+		}
+		if errors.Is(err, auth.ErrNoCredentials) {
+			respondWithError(w, http.StatusUnauthorized, "Missing credentials: provide "+auth.APIKeyHeader+" or a bearer token")
+			return
+		}
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error authenticating request", "error", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to authenticate request")
+			return
+		}
+
+		if !limitPrincipal(w, r, principal) {
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			slog.InfoContext(r.Context(), "Principal lacks required scope", "auth_method", principal.Method, "subject", principal.Subject, "scope", scope, "method", r.Method, "path", r.URL.Path)
+			respondWithError(w, http.StatusForbidden, "Caller lacks the "+scope+" scope")
+			return
+		}
+
+		// A principal only ever grants access to its own tenant
+		if requested := r.Header.Get(tenant.Header); requested != "" && requested != principal.TenantID {
+			respondWithError(w, http.StatusForbidden, "Credentials are not valid for the requested tenant")
+			return
+		}
+
+		ctx := auth.WithPrincipal(r.Context(), principal)
+		r = r.WithContext(tenant.WithID(ctx, principal.TenantID))
+		if a.usage != nil && principal.KeyID != "" {
+			a.serveMetered(w, r, principal, next)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Scope returns Require for scope as route middleware
+func (a *Auth) Scope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return a.Require(scope, next.ServeHTTP)
+	}
+}
+
+// authenticate returns the principal from the first authenticator that recognises
+// credentials on the request
+func (a *Auth) authenticate(r *http.Request) (*auth.Principal, error) {
+	for _, authenticator := range a.authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, auth.ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, auth.ErrNoCredentials
+}
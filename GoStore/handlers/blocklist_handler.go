@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// BlocklistHandler handles HTTP requests for the blocklist of the caller's tenant
+type BlocklistHandler struct {
+	blocklistService *services.BlocklistService
+	auditService     *services.AuditService
+}
+
+// NewBlocklistHandler creates a new blocklist handler instance, recording every
+// change to the blocklist with auditService
+func NewBlocklistHandler(blocklistService *services.BlocklistService, auditService *services.AuditService) *BlocklistHandler {
+	return &BlocklistHandler{
+		blocklistService: blocklistService,
+		auditService:     auditService,
+	}
+}
+
+// blockEntryRequest is the payload for POST /v0/blocklist and PUT /v0/blocklist/{id}
+type blockEntryRequest struct {
+	Kind   string `json:"kind"`
+	Value  string `json:"value"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// decodeBlockEntry reads and validates the blocklist entry of a request
+func decodeBlockEntry(w http.ResponseWriter, r *http.Request) (*models.BlockEntry, bool) {
+	var req blockEntryRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid blocklist entry payload")
+		return nil, false
+	}
+	entry := &models.BlockEntry{Kind: req.Kind, Value: req.Value, Reason: req.Reason}
+	if err := entry.Validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return entry, true
+}
+
+// AddBlockEntry handles POST /v0/blocklist
+func (h *BlocklistHandler) AddBlockEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := decodeBlockEntry(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.blocklistService.Add(r.Context(), entry)
+	if errors.Is(err, services.ErrBlockEntryExists) {
+		respondWithError(w, http.StatusConflict, "Value already blocked")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error adding blocklist entry", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to add blocklist entry")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionAddBlockEntry)
+	audit.TargetID = entry.ID.Hex()
+	recordCompleted(r, h.auditService, audit)
+
+	respondWithJSON(w, http.StatusCreated, entry)
+}
+
+// ListBlockEntries handles GET /v0/blocklist
+func (h *BlocklistHandler) ListBlockEntries(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && !models.IsValidBlockKind(kind) {
+		respondWithError(w, http.StatusBadRequest, "Invalid kind. Expected phone_number, prefix or country_code.")
+		return
+	}
+
+	entries, err := h.blocklistService.List(r.Context(), kind)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing blocklist", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list blocklist")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, entries)
+}
+
+// GetBlockEntry handles GET /v0/blocklist/{id}
+func (h *BlocklistHandler) GetBlockEntry(w http.ResponseWriter, r *http.Request) {
+	entry, err := h.blocklistService.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, services.ErrBlockEntryNotFound) {
+		respondWithError(w, http.StatusNotFound, "Blocklist entry not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading blocklist entry", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to read blocklist entry")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, entry)
+}
+
+// UpdateBlockEntry handles PUT /v0/blocklist/{id}
+func (h *BlocklistHandler) UpdateBlockEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := decodeBlockEntry(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	err := h.blocklistService.Update(r.Context(), id, entry)
+	if errors.Is(err, services.ErrBlockEntryNotFound) {
+		respondWithError(w, http.StatusNotFound, "Blocklist entry not found")
+		return
+	}
+	if errors.Is(err, services.ErrBlockEntryExists) {
+		respondWithError(w, http.StatusConflict, "Value already blocked")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating blocklist entry", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update blocklist entry")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionUpdateBlockEntry)
+	audit.TargetID = id
+	recordCompleted(r, h.auditService, audit)
+
+	respondWithJSON(w, http.StatusOK, entry)
+}
+
+// DeleteBlockEntry handles DELETE /v0/blocklist/{id}
+func (h *BlocklistHandler) DeleteBlockEntry(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := h.blocklistService.Delete(r.Context(), id)
+	if errors.Is(err, services.ErrBlockEntryNotFound) {
+		respondWithError(w, http.StatusNotFound, "Blocklist entry not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting blocklist entry", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete blocklist entry")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionDeleteBlockEntry)
+	audit.TargetID = id
+	recordCompleted(r, h.auditService, audit)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Pooled compressors, since each holds hundreds of kilobytes of state
+var (
+	gzipWriters  = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	flateWriters = sync.Pool{New: func() any { w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression); return w }}
+)
+
+// compressor is the part of gzip.Writer and flate.Writer used here
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// Compress gzip or deflate encodes responses of at least minSize bytes for clients
+// that accept it. Smaller responses, responses the handler already encoded, and
+// event streams are sent as they are. Flushing a response starts compressing it
+// regardless of size, so streaming responses are not held back
+func Compress(minSize int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, status: http.StatusOK}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring gzip, or returns empty if neither is acceptable
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, entry := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		accepted[name] = quality > 0
+	}
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if ok, listed := accepted[encoding]; ok || (!listed && accepted["*"]) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressWriter buffers the start of a response until it knows whether to
+// compress it, then either compresses everything or passes everything through.
+// Unwrap lets http.ResponseController reach the underlying writer for deadlines
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status      int
+	wroteHeader bool
+	buf         []byte
+	decided     bool
+	compressor  compressor // nil when passing through
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.status = status
+		c.wroteHeader = true
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	c.wroteHeader = true
+	if c.decided {
+		if c.compressor != nil {
+			return c.compressor.Write(p)
+		}
+		return c.ResponseWriter.Write(p)
+	}
+
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= c.minSize {
+		if err := c.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends what was written so far, compressing the rest of the response if eligible
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		if err := c.decide(true); err != nil {
+			return
+		}
+	}
+	if c.compressor != nil {
+		c.compressor.Flush()
+	}
+	http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// decide writes the header and buffered body, compressing them if large enough
+// and the response is eligible
+func (c *compressWriter) decide(large bool) error {
+	c.decided = true
+	header := c.Header()
+	eligible := large &&
+		header.Get("Content-Encoding") == "" &&
+		!strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") &&
+		c.status != http.StatusNoContent && c.status != http.StatusNotModified
+
+	if eligible {
+		if header.Get("Content-Type") == "" {
+			// Sniff from the uncompressed bytes, as the server would have
+			header.Set("Content-Type", http.DetectContentType(c.buf))
+		}
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", c.encoding)
+		if c.encoding == "gzip" {
+			c.compressor = gzipWriters.Get().(*gzip.Writer)
+		} else {
+			c.compressor = flateWriters.Get().(*flate.Writer)
+		}
+		c.compressor.Reset(c.ResponseWriter)
+	}
+
+	c.ResponseWriter.WriteHeader(c.status)
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if c.compressor != nil {
+		_, err := c.compressor.Write(buf)
+		return err
+	}
+	_, err := c.ResponseWriter.Write(buf)
+	return err
+}
+
+// close sends a response too small to compress as it is, or finishes the
+// compressed stream and returns the compressor to its pool
+func (c *compressWriter) close() {
+	if !c.decided {
+		if !c.wroteHeader {
+			// The handler wrote nothing; let the server send its default response
+			return
+		}
+		c.decide(false)
+		return
+	}
+	if c.compressor == nil {
+		return
+	}
+	c.compressor.Close()
+	switch w := c.compressor.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(w)
+	case *flate.Writer:
+		flateWriters.Put(w)
+	}
+}
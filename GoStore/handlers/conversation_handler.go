@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Page sizes of conversation lists and of the messages of a conversation
+const (
+	defaultConversationLimit = 50
+	defaultThreadLimit       = 100
+	maxConversationLimit     = 1000
+)
+
+// GetUserConversations handles GET /v0/user/{user_id}/conversations?skip=N&limit=N
+// Groups the user's messages by counterpart phone number, most recently active first
+func (h *SMSHandler) GetUserConversations(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	if !h.smsService.IsValidPhoneNumber(userID) {
+		slog.InfoContext(r.Context(), "Invalid user_id format", "user_id", userID)
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+	skip, limit, ok := parsePage(w, r, defaultConversationLimit, maxConversationLimit)
+	if !ok {
+		return
+	}
+
+	conversations, err := h.smsService.GetConversations(r.Context(), userID, skip, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving conversations", "user_id", userID, "error", err)
+		respondWithStoreError(w, err, "Failed to retrieve conversations")
+		return
+	}
+	if conversations == nil {
+		conversations = make([]*models.Conversation, 0)
+	}
+
+	audit := newAuditRecord(r, models.AuditActionReadConversations)
+	audit.UserID = userID
+	audit.ResultCount = int64(len(conversations))
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Retrieved conversations", "user_id", userID, "count", len(conversations))
+	respondWithJSON(w, http.StatusOK, conversations)
+}
+
+// GetConversationMessages handles GET /v0/user/{user_id}/conversations/{peer}/messages?skip=N&limit=N
+// Lists the user's messages to or from the peer phone number, newest first
+func (h *SMSHandler) GetConversationMessages(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	if !h.smsService.IsValidPhoneNumber(userID) {
+		slog.InfoContext(r.Context(), "Invalid user_id format", "user_id", userID)
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+	peer := r.PathValue("peer")
+	if !h.smsService.IsValidPhoneNumber(peer) {
+		slog.InfoContext(r.Context(), "Invalid peer format", "peer", peer)
+		respondWithError(w, http.StatusBadRequest, "Invalid peer format. Expected phone number.")
+		return
+	}
+	skip, limit, ok := parsePage(w, r, defaultThreadLimit, maxConversationLimit)
+	if !ok {
+		return
+	}
+
+	query := &models.MessageQuery{UserID: userID, PhoneNumber: peer, Skip: skip, Limit: limit}
+	messages, err := h.smsService.FindMessages(r.Context(), query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving conversation", "user_id", userID, "peer", peer, "error", err)
+		respondWithStoreError(w, err, "Failed to retrieve messages")
+		return
+	}
+	if messages == nil {
+		messages = make([]*models.SMSRecord, 0)
+	}
+
+	audit := newAuditRecord(r, models.AuditActionReadMessages)
+	audit.UserID = userID
+	audit.PhoneNumber = peer
+	audit.ResultCount = int64(len(messages))
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Retrieved conversation", "user_id", userID, "peer", peer, "count", len(messages))
+	respondWithJSON(w, http.StatusOK, messages)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ramG-reddy/sms-store/requestid"
+)
+
+// CORSConfig configures which cross-origin browser requests are allowed
+type CORSConfig struct {
+	AllowedOrigins []string // "*" allows any origin; empty disables CORS
+	AllowedMethods []string
+	AllowedHeaders []string
+	MaxAge         int // seconds browsers may cache a preflight response; zero omits the header
+}
+
+// CORS answers preflight requests and adds CORS headers to responses for the
+// allowed origins. Requests from other origins are served without them, so
+// browsers refuse to expose the response, and their preflights are rejected
+func CORS(cfg CORSConfig, next http.Handler) http.Handler {
+	if len(cfg.AllowedOrigins) == 0 {
+		return next
+	}
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !anyOrigin {
+			// The response differs by origin, so caches must key on it
+			w.Header().Add("Vary", "Origin")
+		}
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := anyOrigin || slices.Contains(cfg.AllowedOrigins, origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if preflight {
+			if !allowed {
+				respondWithError(w, http.StatusForbidden, "Origin not allowed")
+				return
+			}
+			setAllowOrigin(w, origin, anyOrigin)
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			setAllowOrigin(w, origin, anyOrigin)
+			// Let dashboards read the request ID for support requests, and the ETag to poll with
+			w.Header().Set("Access-Control-Expose-Headers", requestid.Header+", ETag")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setAllowOrigin allows the origin of the request
+func setAllowOrigin(w http.ResponseWriter, origin string, anyOrigin bool) {
+	if anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+}
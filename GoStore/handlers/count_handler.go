@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// messageCountResponse is the body of a message count response
+type messageCountResponse struct {
+	UserID string `json:"user_id"`
+	Count  int64  `json:"count"`
+}
+
+// CountUserMessages handles GET /v0/user/{user_id}/messages/count
+// Query parameters: status (comma-separated), since and until (RFC 3339) and
+// phone_number. Counts the matching messages in the store without reading them,
+// for dashboards that only show how many there are
+func (h *SMSHandler) CountUserMessages(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	if !h.smsService.IsValidPhoneNumber(userID) {
+		slog.InfoContext(r.Context(), "Invalid user_id format", "user_id", userID)
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+
+	params := r.URL.Query()
+	query := &models.MessageQuery{UserID: userID, PhoneNumber: params.Get("phone_number")}
+	if status := params.Get("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			switch s {
+			case models.StatusSent, models.StatusDelivered, models.StatusFailed:
+				query.Statuses = append(query.Statuses, s)
+			default:
+				respondWithError(w, http.StatusBadRequest, "Invalid status. Expected SENT, DELIVERED or FAILED.")
+				return
+			}
+		}
+	}
+	var err error
+	if since := params.Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid since. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+	if until := params.Get("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid until. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+	if query.PhoneNumber != "" && !h.smsService.IsValidPhoneNumber(query.PhoneNumber) {
+		respondWithError(w, http.StatusBadRequest, "Invalid phone_number format. Expected phone number.")
+		return
+	}
+
+	count, err := cachedResponse(h, w, r, CacheRouteCount, userID, func(ctx context.Context) (int64, error) {
+		return h.smsService.CountMatchingMessages(ctx, query)
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error counting messages", "user_id", userID, "error", err)
+		respondWithStoreError(w, err, "Failed to count messages")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionCountMessages)
+	audit.UserID = userID
+	audit.PhoneNumber = query.PhoneNumber
+	audit.ResultCount = count
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, messageCountResponse{UserID: userID, Count: count})
+}
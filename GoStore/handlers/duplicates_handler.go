@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Window and size of duplicate reports
+const (
+	defaultDuplicateWindow = 24 * time.Hour
+	maxDuplicateWindow     = 7 * 24 * time.Hour
+	defaultDuplicateLimit  = 100
+	maxDuplicateLimit      = 1000
+)
+
+// duplicateReportResponse is the body of a duplicate report
+type duplicateReportResponse struct {
+	Since  time.Time                `json:"since"`
+	Until  time.Time                `json:"until"`
+	Groups []*models.DuplicateGroup `json:"groups"`
+}
+
+// GetDuplicateReport handles GET /v0/duplicates?since=&until=&limit=N
+// Finds the tenant's messages created in the window (default the last 24 hours,
+// at most 7 days) that share a message_id, or a user_id, body and created_at, to
+// diagnose producers storing the same message more than once
+func (h *SMSHandler) GetDuplicateReport(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	until := time.Now().UTC()
+	if value := params.Get("until"); value != "" {
+		var err error
+		if until, err = time.Parse(time.RFC3339, value); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid until. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+	since := until.Add(-defaultDuplicateWindow)
+	if value := params.Get("since"); value != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, value); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid since. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+	if !since.Before(until) || until.Sub(since) > maxDuplicateWindow {
+		respondWithError(w, http.StatusBadRequest, "Invalid window. Expected since before until, at most 7 days apart.")
+		return
+	}
+
+	limit := int64(defaultDuplicateLimit)
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.ParseInt(value, 10, 64); err != nil || limit < 1 || limit > maxDuplicateLimit {
+			respondWithError(w, http.StatusBadRequest, "Invalid limit. Expected 1 to 1000.")
+			return
+		}
+	}
+
+	groups, err := h.smsService.FindDuplicates(r.Context(), since, until, limit)
+	if errors.Is(err, errors.ErrUnsupported) {
+		respondWithError(w, http.StatusNotImplemented, "Duplicate reports are not supported by the storage backend")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error finding duplicate messages", "error", err)
+		respondWithStoreError(w, err, "Failed to find duplicate messages")
+		return
+	}
+	if groups == nil {
+		groups = make([]*models.DuplicateGroup, 0)
+	}
+
+	audit := newAuditRecord(r, models.AuditActionReadDuplicates)
+	audit.ResultCount = int64(len(groups))
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, duplicateReportResponse{Since: since.UTC(), Until: until.UTC(), Groups: groups})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/requestid"
+)
+
+// EnvelopeMeta describes a /v1 response
+type EnvelopeMeta struct {
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// SuccessEnvelope is the body of a successful /v1 JSON response
+type SuccessEnvelope struct {
+	Data json.RawMessage `json:"data"`
+	Meta EnvelopeMeta    `json:"meta"`
+}
+
+// Envelope wraps the JSON bodies written by the handlers behind it in the /v1
+// envelope {"data": ..., "meta": {...}}. Other responses, such as errors, which
+// are problem details in both versions, event streams and exports, pass through
+func Envelope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &envelopeWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		ew.finish()
+	})
+}
+
+// envelopeWriter buffers JSON responses so they can be wrapped, and passes any
+// other response straight through
+// Unwrap lets http.ResponseController reach the underlying writer for streaming
+type envelopeWriter struct {
+	http.ResponseWriter
+	status    int
+	buffering bool
+	body      []byte
+}
+
+func (e *envelopeWriter) WriteHeader(status int) {
+	if e.status != 0 {
+		return
+	}
+	e.status = status
+	mediaType, _, _ := mime.ParseMediaType(e.Header().Get("Content-Type"))
+	e.buffering = mediaType == "application/json" && status < http.StatusBadRequest
+	if !e.buffering {
+		e.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (e *envelopeWriter) Write(p []byte) (int, error) {
+	if e.status == 0 {
+		e.WriteHeader(http.StatusOK)
+	}
+	if !e.buffering {
+		return e.ResponseWriter.Write(p)
+	}
+	e.body = append(e.body, p...)
+	return len(p), nil
+}
+
+func (e *envelopeWriter) Flush() {
+	if !e.buffering {
+		http.NewResponseController(e.ResponseWriter).Flush()
+	}
+}
+
+func (e *envelopeWriter) Unwrap() http.ResponseWriter {
+	return e.ResponseWriter
+}
+
+// finish writes the buffered body in its envelope
+func (e *envelopeWriter) finish() {
+	if !e.buffering {
+		return
+	}
+	envelope := SuccessEnvelope{Data: e.body, Meta: EnvelopeMeta{RequestID: e.Header().Get(requestid.Header)}}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		slog.Error("Error encoding response envelope", "error", err)
+		e.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	e.Header().Del("Content-Length")
+	e.ResponseWriter.WriteHeader(e.status)
+	e.ResponseWriter.Write(append(body, '\n'))
+}
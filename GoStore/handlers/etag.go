@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// messagesETag returns a weak ETag for a list of messages built from their count
+// and latest creation and update times, which change whenever a message is
+// stored, erased, or has its status updated, and from the metadata filter, the
+// projected fields and the sort order
+func messagesETag(messages []*models.SMSRecord, filter models.MessageFilter, fields []string, sort models.MessageSort) string {
+	var latestCreated, latestUpdated int64
+	for _, message := range messages {
+		latestCreated = max(latestCreated, message.CreatedAt.UnixNano())
+		if !message.UpdatedAt.IsZero() {
+			latestUpdated = max(latestUpdated, message.UpdatedAt.UnixNano())
+		}
+	}
+	tag := fmt.Sprintf("%x-%x-%x", len(messages), latestCreated, latestUpdated)
+	if len(fields) > 0 || !sort.IsDefault() || !filter.IsZero() {
+		// Each filter, projection and order is a different representation of the list
+		representation := fnv.New32a()
+		representation.Write([]byte(fmt.Sprintf("%+v;%s;%s", filter, strings.Join(fields, ","), sort.String())))
+		tag += fmt.Sprintf("-%x", representation.Sum32())
+	}
+	return `W/"` + tag + `"`
+}
+
+// checkNotModified sets the ETag header and, if the request's If-None-Match
+// header matches it, responds 304 Not Modified and returns true
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	// Clients revalidate on every request, unless the response is cached here and
+	// says for how long; shared caches must not keep tenant data
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		// If-None-Match uses weak comparison, ignoring the W/ prefix
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// exportFlushEvery controls how many rows are written between flushes
+const exportFlushEvery = 500
+
+// exportCSVHeader lists the columns written by CSV exports
+var exportCSVHeader = []string{
+	"id", "message_id", "provider_message_id", "user_id", "phone_number",
+	"message", "status", "created_at", "updated_at", "encoding", "language",
+}
+
+// utf8BOM starts CSV exports asked for with bom=true, so spreadsheet
+// applications read them as UTF-8 rather than a legacy code page
+const utf8BOM = "\uFEFF"
+
+// ExportUserMessages handles GET /v0/user/{user_id}/messages/export?format=csv|ndjson
+// Results are streamed from the database cursor with chunked transfer encoding,
+// so exports of any size use constant memory. Both formats are UTF-8; text that
+// is not valid UTF-8 has its invalid bytes replaced
+func (h *SMSHandler) ExportUserMessages(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	if !h.smsService.IsValidPhoneNumber(userID) {
+		slog.InfoContext(r.Context(), "Invalid user_id format", "user_id", userID)
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+
+	filter, err := parseMessageFilter(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	bom := r.URL.Query().Get("bom") == "true"
+
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid format. Expected csv or ndjson.")
+		return
+	}
+
+	// Large exports outlive the server-wide write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.ErrorContext(r.Context(), "Error disabling write deadline for export", "error", err)
+	}
+
+	slog.InfoContext(r.Context(), "Exporting messages", "user_id", userID, "format", format)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="messages.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	var write func(*models.SMSRecord) error
+	var flush func() error
+	if format == "csv" {
+		if bom {
+			if _, err := w.Write([]byte(utf8BOM)); err != nil {
+				return
+			}
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportCSVHeader); err != nil {
+			return
+		}
+		write = func(record *models.SMSRecord) error { return cw.Write(csvRow(record)) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	} else {
+		enc := json.NewEncoder(w)
+		write = func(record *models.SMSRecord) error { return enc.Encode(record) }
+		flush = func() error { return nil }
+	}
+
+	rows := 0
+	err = h.smsService.StreamMessagesByUserID(r.Context(), userID, filter, nil, models.MessageSort{}, 0, func(record *models.SMSRecord) error {
+		if err := write(record); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+
+	// Recorded once the rows are sent, aborted exports included, so the count is what left the service
+	audit := newAuditRecord(r, models.AuditActionExportMessages)
+	audit.UserID = userID
+	audit.ResultCount = int64(rows)
+	recordCompleted(r, h.auditService, audit)
+
+	if err != nil {
+		// Headers are already sent, so the client sees a truncated body
+		slog.WarnContext(r.Context(), "Export aborted", "user_id", userID, "rows", rows, "error", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Exported messages", "user_id", userID, "rows", rows)
+}
+
+// csvRow flattens a record into the exportCSVHeader column order
+func csvRow(record *models.SMSRecord) []string {
+	updatedAt := ""
+	if !record.UpdatedAt.IsZero() {
+		updatedAt = record.UpdatedAt.Format(time.RFC3339)
+	}
+	return []string{
+		record.ID.Hex(),
+		validUTF8(record.MessageID),
+		validUTF8(record.ProviderMessageID),
+		record.UserID,
+		record.PhoneNumber,
+		validUTF8(record.Message),
+		record.Status,
+		record.CreatedAt.Format(time.RFC3339),
+		updatedAt,
+		record.Encoding,
+		record.Language,
+	}
+}
+
+// validUTF8 replaces the invalid bytes of s, as encoding/json does for NDJSON
+// exports, so CSV exports are valid UTF-8 too
+func validUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return strings.ToValidUTF8(s, "\uFFFD")
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/opmode"
+)
+
+// readinessTimeout bounds each dependency check so a hung dependency can't stall the probe
+const readinessTimeout = 2 * time.Second
+
+// Overall and per-component health states
+const (
+	StatusUp       = "UP"
+	StatusDegraded = "DEGRADED" // only optional components are down
+	StatusDown     = "DOWN"
+)
+
+// ReadinessCheck reports whether a dependency is usable; a non-nil error marks it DOWN
+type ReadinessCheck func(ctx context.Context) error
+
+// ComponentDetails reports figures about a dependency alongside its status, such as consumer lag
+type ComponentDetails func() map[string]any
+
+// ComponentStatus is the readiness of a single dependency, with how long its check took
+type ComponentStatus struct {
+	Status    string         `json:"status"`
+	Optional  bool           `json:"optional,omitempty"`
+	LatencyMs float64        `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// LivenessResponse is the body served by /healthz
+type LivenessResponse struct {
+	Status  string `json:"status"`
+	Service string `json:"service"`
+}
+
+// ReadinessResponse is the body served by /readyz
+type ReadinessResponse struct {
+	Status        string                     `json:"status"`
+	Service       string                     `json:"service"`
+	Version       string                     `json:"version"`
+	Mode          opmode.Mode                `json:"mode,omitempty"`
+	UptimeSeconds int64                      `json:"uptime_seconds"`
+	Components    map[string]ComponentStatus `json:"components"`
+}
+
+// component is a registered dependency
+type component struct {
+	check     ReadinessCheck
+	optional  bool
+	ingestion bool // optional while the service mode pauses ingestion
+	details   ComponentDetails
+}
+
+// HealthHandler serves liveness and readiness probes
+type HealthHandler struct {
+	components map[string]*component
+	version    string
+	startedAt  time.Time
+	modes      *opmode.Switch // nil reports no mode
+}
+
+// NewHealthHandler creates a health handler reporting the given build version,
+// and uptime from now
+func NewHealthHandler(version string) *HealthHandler {
+	return &HealthHandler{
+		components: make(map[string]*component),
+		version:    version,
+		startedAt:  time.Now(),
+	}
+}
+
+// AddCheck registers a dependency that must be healthy for the service to be ready
+func (h *HealthHandler) AddCheck(name string, check ReadinessCheck) {
+	h.component(name).check = check
+}
+
+// AddOptionalCheck registers a dependency the service can run without, such as
+// a cache; while it is down the service reports DEGRADED but stays ready
+func (h *HealthHandler) AddOptionalCheck(name string, check ReadinessCheck) {
+	c := h.component(name)
+	c.check = check
+	c.optional = true
+}
+
+// AddIngestionCheck registers an ingestion consumer that must be healthy for
+// the service to be ready, except while the service mode pauses ingestion, when
+// its lag builds up by design
+func (h *HealthHandler) AddIngestionCheck(name string, check ReadinessCheck) {
+	c := h.component(name)
+	c.check = check
+	c.ingestion = true
+}
+
+// SetModes reports the service mode of modes, which decides whether ingestion
+// checks are required
+func (h *HealthHandler) SetModes(modes *opmode.Switch) {
+	h.modes = modes
+}
+
+// AddDetails reports details alongside the status of the named dependency
+func (h *HealthHandler) AddDetails(name string, details ComponentDetails) {
+	h.component(name).details = details
+}
+
+// component returns the registered dependency with the given name, adding it if new
+func (h *HealthHandler) component(name string) *component {
+	c, ok := h.components[name]
+	if !ok {
+		c = &component{}
+		h.components[name] = c
+	}
+	return c
+}
+
+// Liveness handles GET /healthz
+// It only reports that the process is serving HTTP; dependencies are not checked
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, LivenessResponse{Status: StatusUp, Service: "sms-store"})
+}
+
+// Readiness handles GET /readyz
+// Every registered check runs concurrently and is timed. A failed required check
+// returns 503 so the instance is taken out of load balancing until it recovers;
+// failed optional checks only mark the service DEGRADED. Ingestion checks are
+// optional while the service mode pauses ingestion, so a read-only instance
+// stays ready. A write-only instance is DOWN whatever its checks say, so load
+// balancers stop sending it the reads it refuses
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	response := ReadinessResponse{
+		Status:        StatusUp,
+		Service:       "sms-store",
+		Version:       h.version,
+		UptimeSeconds: int64(time.Since(h.startedAt).Seconds()),
+		Components:    make(map[string]ComponentStatus, len(h.components)),
+	}
+	ingesting := true
+	if h.modes != nil {
+		response.Mode = h.modes.Mode()
+		ingesting = response.Mode.Writes()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, c := range h.components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			optional := c.optional || (c.ingestion && !ingesting)
+			status := ComponentStatus{Status: StatusUp, Optional: optional}
+			if c.check != nil {
+				start := time.Now()
+				err := c.check(ctx)
+				status.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+				if err != nil {
+					status.Status = StatusDown
+					status.Error = err.Error()
+				}
+			}
+			if c.details != nil {
+				status.Details = c.details()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Components[name] = status
+			switch {
+			case status.Status == StatusUp:
+			case !optional:
+				response.Status = StatusDown
+			case response.Status == StatusUp:
+				response.Status = StatusDegraded
+			}
+		}()
+	}
+	wg.Wait()
+	if response.Mode != "" && !response.Mode.Reads() {
+		response.Status = StatusDown
+	}
+
+	statusCode := http.StatusOK
+	if response.Status == StatusDown {
+		statusCode = http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, statusCode, response)
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+// Limits of messages posted over HTTP
+const (
+	maxIngestBodyBytes = 1 << 20
+	maxIngestBatch     = 500
+)
+
+// ingestBackend and ingestSource label the metrics of messages posted over HTTP
+const (
+	ingestBackend = "http"
+	ingestSource  = "api"
+)
+
+// Outcomes of a posted message
+const (
+	ingestStatusStored   = "stored"
+	ingestStatusRejected = "rejected"
+)
+
+// ingestBatchRequest is the payload for POST /v0/messages/batch; each message
+// is an SMS event as published to Kafka
+type ingestBatchRequest struct {
+	Messages []json.RawMessage `json:"messages"`
+}
+
+// ingestResult is the outcome of one posted message. Messages already stored,
+// e.g. posted again after a timeout, are reported stored without being stored twice
+type ingestResult struct {
+	EventID string `json:"event_id,omitempty"`
+	Status  string `json:"status"`           // stored or rejected
+	Reason  string `json:"reason,omitempty"` // why it was rejected, e.g. invalid
+	Error   string `json:"error,omitempty"`
+}
+
+// ingestBatchResponse is the body of a batch ingestion response, with one
+// result per posted message in request order
+type ingestBatchResponse struct {
+	Stored   int            `json:"stored"`
+	Rejected int            `json:"rejected"`
+	Results  []ingestResult `json:"results"`
+}
+
+// IngestHandler stores SMS events posted over HTTP, for integrators without
+// access to the message broker. Events pass through the same ingest pipeline
+// as those consumed from Kafka
+type IngestHandler struct {
+	pipeline *ingest.Pipeline
+}
+
+// NewIngestHandler creates a handler storing posted events with smsService
+func NewIngestHandler(smsService *services.SMSService) (*IngestHandler, error) {
+	pipeline, err := ingest.NewPipeline(ingest.PipelineConfig{
+		Decode:    decodeTenantEvent,
+		Direction: models.DirectionOutbound,
+		Persist:   ingest.Persist(smsService),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &IngestHandler{pipeline: pipeline}, nil
+}
+
+// CreateMessage handles POST /v0/messages
+// Stores a single SMS event, responding 201 with its result, or 400 or 422 when
+// it is rejected
+func (h *IngestHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	var payload json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBodyBytes)).Decode(&payload); err != nil {
+		slog.InfoContext(r.Context(), "Invalid message payload", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid message payload")
+		return
+	}
+
+	results, err := h.store(r.Context(), []json.RawMessage{payload})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error storing posted message", "error", err)
+		respondWithStoreError(w, err, "Failed to store message")
+		return
+	}
+
+	result := results[0]
+	switch result.Reason {
+	case "":
+		respondWithJSON(w, http.StatusCreated, result)
+	case ingest.ReasonMalformed, ingest.ReasonInvalid:
+		respondWithError(w, http.StatusBadRequest, result.Error)
+	default:
+		respondWithError(w, http.StatusUnprocessableEntity, result.Error)
+	}
+}
+
+// CreateMessages handles POST /v0/messages/batch
+// Stores up to maxIngestBatch SMS events with one bulk write, responding with
+// the result of each. Rejected messages don't prevent the others being stored
+func (h *IngestHandler) CreateMessages(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	var req ingestBatchRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIngestBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		slog.InfoContext(r.Context(), "Invalid message batch payload", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid message batch payload")
+		return
+	}
+	if len(req.Messages) == 0 || len(req.Messages) > maxIngestBatch {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid messages. Expected 1 to %d messages.", maxIngestBatch))
+		return
+	}
+
+	results, err := h.store(r.Context(), req.Messages)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error storing posted message batch", "messages", len(req.Messages), "error", err)
+		respondWithStoreError(w, err, "Failed to store messages")
+		return
+	}
+
+	response := ingestBatchResponse{Results: results}
+	for _, result := range results {
+		if result.Status == ingestStatusStored {
+			response.Stored++
+		} else {
+			response.Rejected++
+		}
+	}
+	slog.InfoContext(r.Context(), "Stored posted message batch", "stored", response.Stored, "rejected", response.Rejected)
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// store passes the payloads through the pipeline as one batch and returns the
+// result of each. An error means none are known to be stored, so the whole
+// batch may be posted again
+func (h *IngestHandler) store(ctx context.Context, payloads []json.RawMessage) ([]ingestResult, error) {
+	events := make([]*ingest.Event, len(payloads))
+	for i, payload := range payloads {
+		events[i] = ingest.NewEvent(ctx, ingestSource, payload)
+		metrics.IngestMessageConsumed(ingestBackend, ingestSource)
+	}
+
+	err := h.pipeline.Run(ctx, events, func(e *ingest.Event) error {
+		metrics.IngestRejected(ingestBackend, ingestSource, e.Rejection().Reason)
+		slog.InfoContext(ctx, "Rejected posted message", "reason", e.Rejection().Reason, "error", e.Rejection().Err)
+		return nil
+	})
+	if err != nil {
+		for range events {
+			metrics.IngestProcessingFailed(ingestBackend, ingestSource)
+		}
+		return nil, err
+	}
+
+	results := make([]ingestResult, len(events))
+	for i, e := range events {
+		results[i].EventID = eventID(e)
+		if rejection := e.Rejection(); rejection != nil {
+			results[i].Status = ingestStatusRejected
+			results[i].Reason = rejection.Reason
+			results[i].Error = rejection.Err.Error()
+			continue
+		}
+		results[i].Status = ingestStatusStored
+	}
+	return results, nil
+}
+
+// eventID returns the eventId of a posted event, as far as it could be read
+func eventID(e *ingest.Event) string {
+	if e.Event != nil {
+		return e.Event.EventID
+	}
+	var event struct {
+		EventID string `json:"eventId"`
+	}
+	_ = json.Unmarshal(e.Payload, &event)
+	return event.EventID
+}
+
+// decodeTenantEvent decodes a posted event, assigning it to the caller's tenant
+// unless it names one, which must then be the caller's
+func decodeTenantEvent(ctx context.Context, e *ingest.Event) (*models.KafkaEvent, error) {
+	event, err := ingest.DecodeJSON(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, _ := tenant.FromContext(ctx)
+	if event.TenantID == "" {
+		event.TenantID = tenantID
+	}
+	if event.TenantID != tenantID {
+		return nil, ingest.Rejected(ingest.ReasonInvalid, errors.New("tenantId does not match the caller's tenant"))
+	}
+	return event, nil
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// GetMessageMedia handles GET /v0/messages/{id}/media/{n}
+// Downloads the nth attachment of an MMS message, counted from zero as in its media list
+func (h *SMSHandler) GetMessageMedia(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil || n < 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid media number. Expected a non-negative integer.")
+		return
+	}
+
+	record, data, err := h.smsService.GetMessageMedia(r.Context(), id, n)
+	if errors.Is(err, services.ErrMessageNotFound) || errors.Is(err, services.ErrMediaNotFound) {
+		respondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving media", "id", id, "n", n, "error", err)
+		respondWithStoreError(w, err, "Failed to retrieve media")
+		return
+	}
+	defer data.Close()
+
+	audit := newAuditRecord(r, models.AuditActionReadMedia)
+	audit.UserID = record.UserID
+	audit.TargetID = id
+	audit.ResultCount = 1
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	attachment := record.Media[n]
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	// Never rendered as a page of the API's origin, whatever the sender claimed it was
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	if attachment.FileName != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.FileName}))
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, data); err != nil {
+		slog.WarnContext(r.Context(), "Error sending media", "id", id, "n", n, "error", err)
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/opmode"
+)
+
+// ServingReads answers 503 Service Unavailable to the wrapped read endpoints
+// while the service mode refuses reads
+func ServingReads(modes *opmode.Switch) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if mode := modes.Mode(); !mode.Reads() {
+				respondWithError(w, http.StatusServiceUnavailable, "Reads are not served while the service is "+string(mode))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ServingWrites answers 503 Service Unavailable to the wrapped endpoints that
+// write, ingestion and management alike, while the service mode refuses writes
+func ServingWrites(modes *opmode.Switch) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if mode := modes.Mode(); !mode.Writes() {
+				respondWithError(w, http.StatusServiceUnavailable, "Writes are not accepted while the service is "+string(mode))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
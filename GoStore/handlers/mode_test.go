@@ -0,0 +1,72 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ramG-reddy/sms-store/handlers"
+	"github.com/ramG-reddy/sms-store/opmode"
+)
+
+func TestServingModes(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		mode          opmode.Mode
+		reads, writes int
+	}{
+		{opmode.ReadWrite, http.StatusOK, http.StatusOK},
+		{opmode.ReadOnly, http.StatusOK, http.StatusServiceUnavailable},
+		{opmode.WriteOnly, http.StatusServiceUnavailable, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			modes := opmode.NewSwitch(tt.mode)
+
+			rec := httptest.NewRecorder()
+			handlers.ServingReads(modes)(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/user/u1/messages", nil))
+			if rec.Code != tt.reads {
+				t.Errorf("read status = %d, want %d", rec.Code, tt.reads)
+			}
+			rec = httptest.NewRecorder()
+			handlers.ServingWrites(modes)(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/user/u1/messages", nil))
+			if rec.Code != tt.writes {
+				t.Errorf("write status = %d, want %d", rec.Code, tt.writes)
+			}
+		})
+	}
+}
+
+func TestReadinessFollowsMode(t *testing.T) {
+	tests := []struct {
+		mode   opmode.Mode
+		code   int
+		status string
+	}{
+		{opmode.ReadWrite, http.StatusServiceUnavailable, handlers.StatusDown},
+		// The lagging consumer is paused by design, so it only degrades the instance
+		{opmode.ReadOnly, http.StatusOK, handlers.StatusDegraded},
+		{opmode.WriteOnly, http.StatusServiceUnavailable, handlers.StatusDown},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			health := handlers.NewHealthHandler("test")
+			health.SetModes(opmode.NewSwitch(tt.mode))
+			health.AddCheck("storage", func(ctx context.Context) error { return nil })
+			health.AddIngestionCheck("kafka", func(ctx context.Context) error { return errors.New("lagging") })
+
+			rec := httptest.NewRecorder()
+			health.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			var body handlers.ReadinessResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding readiness: %v", err)
+			}
+			if rec.Code != tt.code || body.Status != tt.status || body.Mode != tt.mode {
+				t.Errorf("readiness = %d %s in %s, want %d %s", rec.Code, body.Status, body.Mode, tt.code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// ndjsonMediaType is the media type of newline-delimited JSON
+const ndjsonMediaType = "application/x-ndjson"
+
+// streamFlushInterval is the longest streamed lines are held before being
+// flushed, so slow queries still deliver what they have found
+const streamFlushInterval = time.Second
+
+// acceptsNDJSON reports whether the Accept header asks for newline-delimited JSON
+func acceptsNDJSON(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept") {
+		for _, accepted := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(accepted)
+			if err == nil && mediaType == ndjsonMediaType && params["q"] != "0" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streamUserMessages writes the user's messages as newline-delimited JSON, one
+// record per line, as they are read from the database cursor, so lists of any
+// size use constant memory. Lines are flushed every exportFlushEvery records
+// and at least every streamFlushInterval. The status is sent with the first
+// record, so a query that fails at once is still answered with an error; one
+// that fails later truncates the body. Streamed lists have no ETag, and their
+// audit record is written once they are sent, with the number of records sent
+func (h *SMSHandler) streamUserMessages(w http.ResponseWriter, r *http.Request, userID string, filter models.MessageFilter, fields []string, sort models.MessageSort) {
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	started := false
+	start := func() {
+		started = true
+		// Large lists outlive the server-wide write timeout
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			slog.ErrorContext(r.Context(), "Error disabling write deadline for message stream", "error", err)
+		}
+		w.Header().Set("Content-Type", ndjsonMediaType)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	rows := 0
+	lastFlush := time.Now()
+	err := h.smsService.StreamMessagesByUserID(r.Context(), userID, filter, fields, sort, 0, func(record *models.SMSRecord) error {
+		if !started {
+			start()
+		}
+		var line any = record
+		if len(fields) > 0 {
+			line = record.Project(fields)
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 || time.Since(lastFlush) >= streamFlushInterval {
+			lastFlush = time.Now()
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		respondWithListError(w, r, userID, err)
+		return
+	}
+	if !started {
+		start()
+	}
+
+	audit := newAuditRecord(r, models.AuditActionReadMessages)
+	audit.UserID = userID
+	audit.ResultCount = int64(rows)
+	recordCompleted(r, h.auditService, audit)
+
+	if err != nil {
+		// Headers are already sent, so the client sees a truncated body
+		slog.WarnContext(r.Context(), "Message stream aborted", "user_id", userID, "rows", rows, "error", err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Streamed messages", "user_id", userID, "count", rows)
+}
@@ -0,0 +1,556 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/openapi"
+	"github.com/ramG-reddy/sms-store/search"
+)
+
+// apiRoute is an endpoint served under every API version, with its pattern
+// relative to the version prefix
+type apiRoute struct {
+	pattern string
+	op      openapi.Operation
+}
+
+// DescribeAPI adds the REST endpoints of both API versions to doc, with the request
+// and response types the handlers decode and encode. /v1 responses are described
+// in their envelopes, and errors as problem details in both versions. The search,
+// API key usage, retention policy, sender registry and blocklist endpoints are
+// only described when enabled
+func DescribeAPI(doc *openapi.Document, searchEnabled, usageEnabled, retentionEnabled, sendersEnabled, blocklistEnabled bool) {
+	routes := apiRoutes(searchEnabled, usageEnabled, retentionEnabled, sendersEnabled, blocklistEnabled)
+	for _, route := range routes {
+		doc.Add(versioned("/v0", route.pattern), route.op)
+	}
+	for _, route := range routes {
+		op := route.op
+		if op.Response != nil {
+			op.Response = enveloped(op.Response)
+		}
+		doc.Add(versioned("/v1", route.pattern), op)
+	}
+
+	doc.Add("GET /healthz", openapi.Operation{
+		Tag:      "health",
+		Summary:  "Liveness probe",
+		Response: LivenessResponse{},
+	})
+	doc.Add("GET /readyz", openapi.Operation{
+		Tag:         "health",
+		Summary:     "Readiness probe",
+		Description: "Checks every dependency; answers 503 with the same body when any is down, or while the service is write_only.",
+		Response:    ReadinessResponse{},
+	})
+}
+
+// versioned prefixes the path of a "METHOD /path" pattern with an API version
+func versioned(version, pattern string) string {
+	method, path, _ := strings.Cut(pattern, " ")
+	return method + " " + version + path
+}
+
+// enveloped returns a value of a SuccessEnvelope-shaped struct whose data is of
+// the type of response, so its schema is described inside the envelope
+func enveloped(response any) any {
+	t := reflect.StructOf([]reflect.StructField{
+		{Name: "Data", Type: reflect.TypeOf(response), Tag: `json:"data"`},
+		{Name: "Meta", Type: reflect.TypeOf(EnvelopeMeta{}), Tag: `json:"meta"`},
+	})
+	return reflect.Zero(t).Interface()
+}
+
+// messageFilterParams are the metadata filters of message lists and exports
+func messageFilterParams() []openapi.Param {
+	return []openapi.Param{
+		{Name: "carrier", Description: "Only messages handled by this carrier"},
+		{Name: "country", Description: "Only messages of this ISO 3166-1 alpha-2 country, e.g. IN, as reported by the producer or derived from the phone number"},
+		{Name: "country_code", Description: "Same as country"},
+		{Name: "dial_code", Description: "Only messages to or from numbers with this country calling code, without the +, e.g. 91"},
+		{Name: "sender_id", Description: "Only messages with this sender ID"},
+		{Name: "sender_type", Description: "Only messages from registered senders of this type", Enum: []string{models.SenderTypeShortCode, models.SenderTypeAlphanumeric, models.SenderTypeLongCode}},
+		{Name: "registered_sender", Description: "true keeps only messages from senders the tenant registered", Enum: []string{"true", "false"}},
+		{Name: "direction", Description: "Only messages in this direction", Enum: []string{models.DirectionOutbound, models.DirectionInbound}},
+		{Name: "segment_count", Description: "Only messages of this many segments"},
+		{Name: "lang", Description: "Only messages detected to be in this ISO 639-1 language, e.g. es"},
+		{Name: "flag", Description: "Only messages flagged by this rule, e.g. opt_out"},
+		{Name: "flagged", Description: "true keeps only messages flagged by any rule", Enum: []string{"true", "false"}},
+	}
+}
+
+// apiRoutes lists the endpoints of the versioned API
+func apiRoutes(searchEnabled, usageEnabled, retentionEnabled, sendersEnabled, blocklistEnabled bool) []apiRoute {
+	routes := []apiRoute{
+		{"GET /user/{user_id}/messages", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "List a user's messages",
+			Description: "Every stored message of the user, most recent first, optionally filtered by message metadata. With Accept: application/x-ndjson the messages are streamed one JSON object per line instead of as an array, without an ETag.",
+			Scope:       models.ScopeRead,
+			Query: append(messageFilterParams(),
+				openapi.Param{Name: "fields", Description: "Comma-separated fields to return, e.g. message_id,created_at,status; omitted fields are left out of each message"},
+				openapi.Param{Name: "sort", Description: "Order as field:asc or field:desc, by created_at, status or sender (phone number); defaults to created_at:desc"},
+				openapi.Param{Name: "body_regex", Description: "Only messages whose body matches this regular expression, at most 64 characters starting with ^, with at most two unbounded repetitions and none of a repetition or alternation, e.g. (?i)^.*refund"},
+			),
+			Response: []*models.SMSRecord{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusNotImplemented, http.StatusServiceUnavailable},
+			Headers:  map[string]string{"ETag": "Weak ETag of the list; send it in If-None-Match to get 304 Not Modified while the list is unchanged"},
+		}},
+		{"DELETE /user/{user_id}/messages", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Erase a user's messages",
+			Description: "Hard-deletes every stored message of the user (right to erasure).",
+			Scope:       models.ScopeDelete,
+			Response:    erasureResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+		{"GET /user/{user_id}/messages/stream", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Stream a user's new messages",
+			Description: "Server-sent events: message.stored and message.status_changed, each with an SMSRecord as data.",
+			Scope:       models.ScopeRead,
+			ContentType: "text/event-stream",
+			Errors:      []int{http.StatusBadRequest},
+		}},
+		{"GET /user/{user_id}/messages/export", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Export a user's messages",
+			Description: "Streams every message of the user, newest first, as UTF-8 NDJSON or, with format=csv, as UTF-8 CSV.",
+			Scope:       models.ScopeRead,
+			Query: append(messageFilterParams(),
+				openapi.Param{Name: "format", Description: "Export format", Enum: []string{"ndjson", "csv"}},
+				openapi.Param{Name: "bom", Description: "true starts a CSV export with a UTF-8 byte order mark, for spreadsheet applications", Enum: []string{"true", "false"}},
+			),
+			ContentType: "application/x-ndjson",
+			Errors:      []int{http.StatusBadRequest},
+		}},
+		{"GET /user/{user_id}/stats", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Count a user's recent messages",
+			Description: "Message counts of the last days, by status, direction, day and week.",
+			Scope:       models.ScopeRead,
+			Query: []openapi.Param{
+				{Name: "days", Type: "integer", Description: "UTC days covered, today included (1 to 366, default 30)"},
+			},
+			Response: userStatsResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+
+		{"GET /user/{user_id}/conversations", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "List a user's conversations",
+			Description: "The user's messages grouped by counterpart phone number, most recently active first, each with its message count and last message.",
+			Scope:       models.ScopeRead,
+			Query: []openapi.Param{
+				{Name: "skip", Type: "integer", Description: "Conversations to skip"},
+				{Name: "limit", Type: "integer", Description: "Conversations to return (1 to 1000, default 50)"},
+			},
+			Response: []*models.Conversation{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+		{"GET /user/{user_id}/conversations/{peer}/messages", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "List the messages of a conversation",
+			Description: "The user's messages to or from the peer phone number, most recent first.",
+			Scope:       models.ScopeRead,
+			Query: []openapi.Param{
+				{Name: "skip", Type: "integer", Description: "Messages to skip"},
+				{Name: "limit", Type: "integer", Description: "Messages to return (1 to 1000, default 100)"},
+			},
+			Response: []*models.SMSRecord{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+		{"GET /user/{user_id}/messages/count", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Count a user's messages",
+			Description: "Counts the matching messages without returning them.",
+			Scope:       models.ScopeRead,
+			Query: []openapi.Param{
+				{Name: "status", Description: "Comma-separated statuses to match"},
+				{Name: "since", Description: "Earliest creation time (RFC 3339, inclusive)"},
+				{Name: "until", Description: "Latest creation time (RFC 3339, exclusive)"},
+				{Name: "phone_number", Description: "Only count messages to or from this number"},
+			},
+			Response: messageCountResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+		{"GET /phone/{phone_number}/messages", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "List a phone number's messages",
+			Description: "Messages sent to or received from the phone number by any of the tenant's users, most recent first.",
+			Scope:       models.ScopeRead,
+			Query: []openapi.Param{
+				{Name: "skip", Type: "integer", Description: "Messages to skip"},
+				{Name: "limit", Type: "integer", Description: "Messages to return (1 to 1000, default 100)"},
+			},
+			Response: []*models.SMSRecord{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+
+		{"GET /messages/{id}/media/{n}", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Download an MMS attachment",
+			Description: "The data of the nth entry of the message's media list, counted from zero, with its content type.",
+			Scope:       models.ScopeRead,
+			ContentType: "application/octet-stream",
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+
+		{"GET /analytics/delivery", openapi.Operation{
+			Tag:         "analytics",
+			Summary:     "Report delivery success rates",
+			Description: "How many of the tenant's outbound messages were delivered, failed or are still pending, and the delivery and failure rates, in total and by sender ID, carrier, country and time bucket. Not supported with Cassandra storage.",
+			Scope:       models.ScopeRead,
+			Query: []openapi.Param{
+				{Name: "since", Description: "Earliest creation time (RFC 3339, inclusive; default 24 hours before until)"},
+				{Name: "until", Description: "Latest creation time (RFC 3339, exclusive; default now); at most 31 days after since, or 7 days with hourly buckets"},
+				{Name: "interval", Description: "Width of the UTC time buckets", Enum: []string{models.DeliveryIntervalHour, models.DeliveryIntervalDay}},
+				{Name: "sender_id", Description: "Count only the messages of this sender ID"},
+				{Name: "carrier", Description: "Count only the messages to this carrier"},
+				{Name: "country", Description: "Count only the messages of this ISO 3166-1 alpha-2 country, e.g. IN"},
+				{Name: "dial_code", Description: "Count only the messages to numbers with this country calling code, without the +, e.g. 91"},
+			},
+			Response: deliveryStatsResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusNotImplemented, http.StatusServiceUnavailable},
+		}},
+
+		{"POST /messages/query", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Query the messages of many users",
+			Description: "Up to 1000 users at once, each with their messages newest first, in the order requested.",
+			Scope:       models.ScopeRead,
+			Request:     messageQueryRequest{},
+			Response:    messageQueryResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+
+		{"POST /messages", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Store a message",
+			Description: "Stores an SMS event, as published to Kafka, for the caller's tenant. Posting a stored eventId again reports it stored without storing it twice.",
+			Scope:       models.ScopeWrite,
+			Request:     models.KafkaEvent{},
+			Response:    ingestResult{},
+			Status:      http.StatusCreated,
+			Errors:      []int{http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+		{"POST /messages/batch", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Store many messages",
+			Description: "Stores up to 500 SMS events with one bulk write, reporting the result of each in request order. Rejected events don't prevent the others being stored.",
+			Scope:       models.ScopeWrite,
+			Request:     ingestBatchRequest{},
+			Response:    ingestBatchResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+
+		{"POST /receipts", openapi.Operation{
+			Tag:         "receipts",
+			Summary:     "Apply a delivery receipt",
+			Description: "Carriers post delivery receipts here; each is matched to a stored message of the key's tenant by provider message ID.",
+			Scope:       models.ScopeWrite,
+			Request:     models.DeliveryReceipt{},
+			Response:    receiptResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnsupportedMediaType, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+
+		{"POST /webhooks", openapi.Operation{
+			Tag:         "webhooks",
+			Summary:     "Register a webhook",
+			Description: "The signing secret is generated unless given, and only returned in this response.",
+			Scope:       models.ScopeAdmin,
+			Request:     webhookRequest{},
+			Response:    webhookResponse{},
+			Status:      http.StatusCreated,
+			Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}},
+		{"GET /webhooks", openapi.Operation{
+			Tag:     "webhooks",
+			Summary: "List webhooks",
+			Scope:   models.ScopeAdmin,
+			Query: []openapi.Param{
+				{Name: "user_id", Description: "Only subscriptions scoped to this user"},
+			},
+			Response: []webhookResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}},
+		{"GET /webhooks/{id}", openapi.Operation{
+			Tag:      "webhooks",
+			Summary:  "Get a webhook",
+			Scope:    models.ScopeAdmin,
+			Response: webhookResponse{},
+			Errors:   []int{http.StatusNotFound, http.StatusInternalServerError},
+		}},
+		{"PUT /webhooks/{id}", openapi.Operation{
+			Tag:         "webhooks",
+			Summary:     "Update a webhook",
+			Description: "Replaces the URL, user, event types and enabled state; the signing secret is kept unless given. Disabled subscriptions are sent nothing.",
+			Scope:       models.ScopeAdmin,
+			Request:     webhookRequest{},
+			Response:    webhookResponse{},
+			Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		}},
+		{"POST /webhooks/{id}/secret", openapi.Operation{
+			Tag:         "webhooks",
+			Summary:     "Rotate a webhook's signing secret",
+			Description: "Generates a new signing secret, only returned in this response.",
+			Scope:       models.ScopeAdmin,
+			Response:    webhookResponse{},
+			Errors:      []int{http.StatusNotFound, http.StatusInternalServerError},
+		}},
+		{"GET /webhooks/{id}/deliveries", openapi.Operation{
+			Tag:         "webhooks",
+			Summary:     "List a webhook's delivery attempts",
+			Description: "Every logged attempt to deliver an event to the subscription, newest first, with its response status code or error.",
+			Scope:       models.ScopeAdmin,
+			Query: []openapi.Param{
+				{Name: "success", Description: "Only successful or only failed attempts", Enum: []string{"true", "false"}},
+				{Name: "skip", Type: "integer", Description: "Attempts to skip"},
+				{Name: "limit", Type: "integer", Description: "Attempts to return (1 to 1000, default 50)"},
+			},
+			Response: []*models.WebhookDelivery{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		}},
+		{"DELETE /webhooks/{id}", openapi.Operation{
+			Tag:         "webhooks",
+			Summary:     "Delete a webhook",
+			Description: "Its delivery log is deleted with it.",
+			Scope:       models.ScopeAdmin,
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusNotFound, http.StatusInternalServerError},
+		}},
+
+		{"POST /api-keys", openapi.Operation{
+			Tag:         "api-keys",
+			Summary:     "Create an API key",
+			Description: "The raw key is only returned in this response.",
+			Scope:       models.ScopeAdmin,
+			Request:     createAPIKeyRequest{},
+			Response:    apiKeyResponse{},
+			Status:      http.StatusCreated,
+			Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}},
+		{"DELETE /api-keys/{id}", openapi.Operation{
+			Tag:     "api-keys",
+			Summary: "Revoke an API key",
+			Scope:   models.ScopeAdmin,
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusNotFound, http.StatusInternalServerError},
+		}},
+
+		{"GET /duplicates", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Report duplicate messages",
+			Description: "Groups of the tenant's messages that share a message_id, or a user_id, body and creation time, largest first. Not supported with Cassandra storage.",
+			Scope:       models.ScopeAdmin,
+			Query: []openapi.Param{
+				{Name: "since", Description: "Earliest creation time (RFC 3339, inclusive; default 24 hours before until)"},
+				{Name: "until", Description: "Latest creation time (RFC 3339, exclusive; default now); at most 7 days after since"},
+				{Name: "limit", Type: "integer", Description: "Groups to return (1 to 1000, default 100)"},
+			},
+			Response: duplicateReportResponse{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusNotImplemented, http.StatusServiceUnavailable},
+		}},
+
+		{"GET /audit", openapi.Operation{
+			Tag:         "audit",
+			Summary:     "List the audit trail",
+			Description: "Who read, erased or managed what, most recent first. Reading the trail is recorded in it too.",
+			Scope:       models.ScopeAdmin,
+			Query: []openapi.Param{
+				{Name: "actor", Description: "Subject of the API key or JWT that made the request"},
+				{Name: "user_id", Description: "User whose messages were accessed"},
+				{Name: "action", Description: "Recorded action", Enum: []string{
+					models.AuditActionReadMessages, models.AuditActionQueryMessages, models.AuditActionSearchMessages, models.AuditActionExportMessages,
+					models.AuditActionStreamMessages, models.AuditActionReadConversations, models.AuditActionReadStats, models.AuditActionCountMessages,
+					models.AuditActionReadMedia, models.AuditActionReadDuplicates, models.AuditActionReadAnalytics,
+					models.AuditActionEraseUserMessages, models.AuditActionRegisterWebhook, models.AuditActionUpdateWebhook,
+					models.AuditActionRotateWebhook, models.AuditActionDeleteWebhook,
+					models.AuditActionCreateAPIKey, models.AuditActionRevokeAPIKey, models.AuditActionReadAPIKeyUsage,
+					models.AuditActionResetAPIKeyUsage, models.AuditActionReadAuditLog,
+					models.AuditActionSetRetention, models.AuditActionDeleteRetention,
+					models.AuditActionRegisterSender, models.AuditActionUpdateSender, models.AuditActionDeleteSender,
+					models.AuditActionAddBlockEntry, models.AuditActionUpdateBlockEntry, models.AuditActionDeleteBlockEntry,
+					models.AuditActionRequeueMessage,
+				}},
+				{Name: "target_id", Description: "ID of the webhook, API key, message or other resource the action applied to"},
+				{Name: "since", Description: "Earliest record time (RFC 3339, inclusive)"},
+				{Name: "until", Description: "Latest record time (RFC 3339, exclusive)"},
+				{Name: "skip", Type: "integer", Description: "Records to skip"},
+				{Name: "limit", Type: "integer", Description: "Records to return (1 to 1000, default 100)"},
+			},
+			Response: []*models.AuditRecord{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable},
+		}},
+	}
+	if searchEnabled {
+		routes = append(routes, apiRoute{"GET /user/{user_id}/messages/search", openapi.Operation{
+			Tag:         "messages",
+			Summary:     "Search a user's messages",
+			Description: "Full-text search of the message bodies, best match first.",
+			Scope:       models.ScopeRead,
+			Query: []openapi.Param{
+				{Name: "q", Required: true, Description: "Text to search for"},
+				{Name: "mode", Description: "How the text is matched (default match)", Enum: []string{search.ModeMatch, search.ModePhrase, search.ModeFuzzy}},
+				{Name: "status", Description: "Comma-separated statuses to match"},
+				{Name: "since", Description: "Earliest creation time (RFC 3339, inclusive)"},
+				{Name: "until", Description: "Latest creation time (RFC 3339, exclusive)"},
+				{Name: "skip", Type: "integer", Description: "Matches to skip"},
+				{Name: "limit", Type: "integer", Description: "Matches to return (default 20)"},
+			},
+			Response: search.Result{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}})
+	}
+	if usageEnabled {
+		routes = append(routes,
+			apiRoute{"GET /api-keys/{id}/usage", openapi.Operation{
+				Tag:         "api-keys",
+				Summary:     "Get an API key's usage",
+				Description: "Requests and response bytes per UTC day, most recent first; days without requests are left out.",
+				Scope:       models.ScopeAdmin,
+				Query: []openapi.Param{
+					{Name: "days", Type: "integer", Description: "UTC days covered, today included (1 to 366, default 30)"},
+				},
+				Response: apiKeyUsageResponse{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+			}},
+			apiRoute{"DELETE /api-keys/{id}/usage", openapi.Operation{
+				Tag:         "api-keys",
+				Summary:     "Reset an API key's usage today",
+				Description: "Clears today's counts, so a key over its daily quota can be used again.",
+				Scope:       models.ScopeAdmin,
+				Status:      http.StatusNoContent,
+				Errors:      []int{http.StatusNotFound, http.StatusInternalServerError},
+			}},
+		)
+	}
+	if retentionEnabled {
+		routes = append(routes,
+			apiRoute{"GET /retention-policy", openapi.Operation{
+				Tag:         "retention",
+				Summary:     "Get the tenant's retention policy",
+				Description: "Answers 404 when the tenant has no policy of its own and the default applies.",
+				Scope:       models.ScopeAdmin,
+				Response:    models.RetentionPolicy{},
+				Errors:      []int{http.StatusNotFound, http.StatusInternalServerError},
+			}},
+			apiRoute{"PUT /retention-policy", openapi.Operation{
+				Tag:         "retention",
+				Summary:     "Set the tenant's retention policy",
+				Description: "Messages older than retention_days are deleted, or archived and then deleted, by the next retention sweep; 0 keeps them indefinitely.",
+				Scope:       models.ScopeAdmin,
+				Request:     setRetentionRequest{},
+				Response:    models.RetentionPolicy{},
+				Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+			}},
+			apiRoute{"DELETE /retention-policy", openapi.Operation{
+				Tag:         "retention",
+				Summary:     "Delete the tenant's retention policy",
+				Description: "The default retention applies to the tenant again.",
+				Scope:       models.ScopeAdmin,
+				Status:      http.StatusNoContent,
+				Errors:      []int{http.StatusNotFound, http.StatusInternalServerError},
+			}},
+		)
+	}
+	if sendersEnabled {
+		routes = append(routes,
+			apiRoute{"POST /senders", openapi.Operation{
+				Tag:         "senders",
+				Summary:     "Register a sender ID",
+				Description: "Messages the tenant stores from the sender ID from now on are marked with its type. The type is taken from the form of the sender ID when omitted.",
+				Scope:       models.ScopeAdmin,
+				Request:     senderRequest{},
+				Response:    models.Sender{},
+				Status:      http.StatusCreated,
+				Errors:      []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+			}},
+			apiRoute{"GET /senders", openapi.Operation{
+				Tag:     "senders",
+				Summary: "List registered sender IDs",
+				Scope:   models.ScopeRead,
+				Query: []openapi.Param{
+					{Name: "sender_id", Description: "Only this sender ID, to look it up"},
+					{Name: "type", Description: "Only senders of this type", Enum: []string{models.SenderTypeShortCode, models.SenderTypeAlphanumeric, models.SenderTypeLongCode}},
+				},
+				Response: []*models.Sender{},
+				Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+			}},
+			apiRoute{"GET /senders/{id}", openapi.Operation{
+				Tag:      "senders",
+				Summary:  "Get a registered sender ID",
+				Scope:    models.ScopeRead,
+				Response: models.Sender{},
+				Errors:   []int{http.StatusNotFound, http.StatusInternalServerError},
+			}},
+			apiRoute{"PUT /senders/{id}", openapi.Operation{
+				Tag:         "senders",
+				Summary:     "Update a registered sender ID",
+				Description: "Messages stored before keep the type they were stored with.",
+				Scope:       models.ScopeAdmin,
+				Request:     senderRequest{},
+				Response:    models.Sender{},
+				Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+			}},
+			apiRoute{"DELETE /senders/{id}", openapi.Operation{
+				Tag:         "senders",
+				Summary:     "Delete a registered sender ID",
+				Description: "Messages stored before keep the type they were stored with.",
+				Scope:       models.ScopeAdmin,
+				Status:      http.StatusNoContent,
+				Errors:      []int{http.StatusNotFound, http.StatusInternalServerError},
+			}},
+		)
+	}
+	if blocklistEnabled {
+		routes = append(routes,
+			apiRoute{"POST /blocklist", openapi.Operation{
+				Tag:         "blocklist",
+				Summary:     "Block a phone number, prefix or country",
+				Description: "Messages the tenant ingests from then on whose user ID or phone number is the number, or starts with the prefix, or whose country_code is the country, are skipped or flagged blocked, as BLOCKLIST_ACTION says.",
+				Scope:       models.ScopeAdmin,
+				Request:     blockEntryRequest{},
+				Response:    models.BlockEntry{},
+				Status:      http.StatusCreated,
+				Errors:      []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+			}},
+			apiRoute{"GET /blocklist", openapi.Operation{
+				Tag:     "blocklist",
+				Summary: "List the blocklist",
+				Scope:   models.ScopeAdmin,
+				Query: []openapi.Param{
+					{Name: "kind", Description: "Only entries of this kind", Enum: []string{models.BlockKindPhoneNumber, models.BlockKindPrefix, models.BlockKindCountryCode}},
+				},
+				Response: []*models.BlockEntry{},
+				Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+			}},
+			apiRoute{"GET /blocklist/{id}", openapi.Operation{
+				Tag:      "blocklist",
+				Summary:  "Get a blocklist entry",
+				Scope:    models.ScopeAdmin,
+				Response: models.BlockEntry{},
+				Errors:   []int{http.StatusNotFound, http.StatusInternalServerError},
+			}},
+			apiRoute{"PUT /blocklist/{id}", openapi.Operation{
+				Tag:      "blocklist",
+				Summary:  "Update a blocklist entry",
+				Scope:    models.ScopeAdmin,
+				Request:  blockEntryRequest{},
+				Response: models.BlockEntry{},
+				Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+			}},
+			apiRoute{"DELETE /blocklist/{id}", openapi.Operation{
+				Tag:     "blocklist",
+				Summary: "Delete a blocklist entry",
+				Scope:   models.ScopeAdmin,
+				Status:  http.StatusNoContent,
+				Errors:  []int{http.StatusNotFound, http.StatusInternalServerError},
+			}},
+		)
+	}
+	return routes
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// parsePage reads the skip and limit query parameters of a paginated list,
+// defaulting limit to defaultLimit. On invalid values it responds 400 Bad
+// Request and returns false
+func parsePage(w http.ResponseWriter, r *http.Request, defaultLimit, maxLimit int64) (skip, limit int64, ok bool) {
+	params := r.URL.Query()
+	limit = defaultLimit
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.ParseInt(value, 10, 64); err != nil || limit < 1 || limit > maxLimit {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit. Expected 1 to %d.", maxLimit))
+			return 0, 0, false
+		}
+	}
+	if value := params.Get("skip"); value != "" {
+		var err error
+		if skip, err = strconv.ParseInt(value, 10, 64); err != nil || skip < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid skip. Expected a non-negative integer.")
+			return 0, 0, false
+		}
+	}
+	return skip, limit, true
+}
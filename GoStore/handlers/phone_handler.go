@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Page size of phone number lookups
+const (
+	defaultPhoneLimit = 100
+	maxPhoneLimit     = 1000
+)
+
+// GetPhoneMessages handles GET /v0/phone/{phone_number}/messages?skip=N&limit=N
+// Lists the messages sent to or received from the phone number by any user,
+// newest first, for support teams that have a number but not a user ID
+func (h *SMSHandler) GetPhoneMessages(w http.ResponseWriter, r *http.Request) {
+	phoneNumber := r.PathValue("phone_number")
+	if !h.smsService.IsValidPhoneNumber(phoneNumber) {
+		slog.InfoContext(r.Context(), "Invalid phone number format", "phone_number", phoneNumber)
+		respondWithError(w, http.StatusBadRequest, "Invalid phone number format. Expected E.164 phone number.")
+		return
+	}
+
+	skip, limit, ok := parsePage(w, r, defaultPhoneLimit, maxPhoneLimit)
+	if !ok {
+		return
+	}
+
+	messages, err := h.smsService.GetMessagesByPhoneNumber(r.Context(), phoneNumber, skip, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving messages by phone number", "phone_number", phoneNumber, "error", err)
+		respondWithStoreError(w, err, "Failed to retrieve messages")
+		return
+	}
+	if messages == nil {
+		messages = make([]*models.SMSRecord, 0)
+	}
+
+	audit := newAuditRecord(r, models.AuditActionReadMessages)
+	audit.PhoneNumber = phoneNumber
+	audit.ResultCount = int64(len(messages))
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Retrieved messages by phone number", "phone_number", phoneNumber, "count", len(messages))
+	respondWithJSON(w, http.StatusOK, messages)
+}
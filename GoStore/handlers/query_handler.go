@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Limits of bulk message queries
+const (
+	maxQueryBodyBytes   = 256 << 10
+	maxQueryUsers       = 1000
+	defaultQueryPerUser = 100
+	maxQueryPerUser     = 1000
+)
+
+// messageQueryRequest is the payload for POST /v0/messages/query
+type messageQueryRequest struct {
+	UserIDs      []string  `json:"user_ids"`
+	Since        time.Time `json:"since,omitzero"`         // inclusive
+	Until        time.Time `json:"until,omitzero"`         // exclusive
+	Statuses     []string  `json:"statuses,omitempty"`     // any of SENT, DELIVERED, FAILED
+	PhoneNumber  string    `json:"phone_number,omitempty"` // counterpart of the messages
+	LimitPerUser int64     `json:"limit_per_user,omitempty"`
+}
+
+// userMessages are the messages of one user in a bulk query response
+type userMessages struct {
+	UserID   string              `json:"user_id"`
+	Messages []*models.SMSRecord `json:"messages"`
+	HasMore  bool                `json:"has_more"` // more messages match than limit_per_user
+}
+
+// messageQueryResponse is the body of a bulk query response, with one entry
+// per requested user in request order
+type messageQueryResponse struct {
+	Users []userMessages `json:"users"`
+}
+
+// QueryMessages handles POST /v0/messages/query
+// Looks up the messages of many users in one request, newest first per user, for
+// batch jobs such as reconciliation that would otherwise query user by user
+func (h *SMSHandler) QueryMessages(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	var req messageQueryRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxQueryBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		slog.InfoContext(r.Context(), "Invalid message query payload", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid message query payload")
+		return
+	}
+
+	if len(req.UserIDs) == 0 || len(req.UserIDs) > maxQueryUsers {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid user_ids. Expected 1 to %d phone numbers.", maxQueryUsers))
+		return
+	}
+	seen := make(map[string]bool, len(req.UserIDs))
+	for _, userID := range req.UserIDs {
+		if !h.smsService.IsValidPhoneNumber(userID) {
+			respondWithError(w, http.StatusBadRequest, "Invalid user_id "+userID+". Expected phone number.")
+			return
+		}
+		if seen[userID] {
+			respondWithError(w, http.StatusBadRequest, "Duplicate user_id "+userID+".")
+			return
+		}
+		seen[userID] = true
+	}
+	for _, status := range req.Statuses {
+		switch status {
+		case models.StatusSent, models.StatusDelivered, models.StatusFailed:
+		default:
+			respondWithError(w, http.StatusBadRequest, "Invalid status. Expected SENT, DELIVERED or FAILED.")
+			return
+		}
+	}
+	if req.PhoneNumber != "" && !h.smsService.IsValidPhoneNumber(req.PhoneNumber) {
+		respondWithError(w, http.StatusBadRequest, "Invalid phone_number format. Expected phone number.")
+		return
+	}
+	if req.LimitPerUser == 0 {
+		req.LimitPerUser = defaultQueryPerUser
+	}
+	if req.LimitPerUser < 1 || req.LimitPerUser > maxQueryPerUser {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit_per_user. Expected 1 to %d.", maxQueryPerUser))
+		return
+	}
+
+	// One more than the limit is read to tell whether a user has more
+	query := models.MessageQuery{
+		Statuses:    req.Statuses,
+		PhoneNumber: req.PhoneNumber,
+		Since:       req.Since,
+		Until:       req.Until,
+		Limit:       req.LimitPerUser + 1,
+	}
+	results, err := h.smsService.FindMessagesForUsers(r.Context(), req.UserIDs, query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying messages", "users", len(req.UserIDs), "error", err)
+		respondWithStoreError(w, err, "Failed to query messages")
+		return
+	}
+
+	resp := messageQueryResponse{Users: make([]userMessages, len(req.UserIDs))}
+	var total int64
+	for i, userID := range req.UserIDs {
+		messages := results[userID]
+		hasMore := int64(len(messages)) > req.LimitPerUser
+		if hasMore {
+			messages = messages[:req.LimitPerUser]
+		}
+		if messages == nil {
+			messages = make([]*models.SMSRecord, 0)
+		}
+		resp.Users[i] = userMessages{UserID: userID, Messages: messages, HasMore: hasMore}
+		total += int64(len(messages))
+	}
+
+	// The filters are in the body rather than the query string, so they are recorded from it
+	audit := newAuditRecord(r, models.AuditActionQueryMessages)
+	audit.Filters = map[string]string{
+		"user_ids":       strings.Join(req.UserIDs, ","),
+		"limit_per_user": strconv.FormatInt(req.LimitPerUser, 10),
+	}
+	if !req.Since.IsZero() {
+		audit.Filters["since"] = req.Since.Format(time.RFC3339)
+	}
+	if !req.Until.IsZero() {
+		audit.Filters["until"] = req.Until.Format(time.RFC3339)
+	}
+	if len(req.Statuses) > 0 {
+		audit.Filters["statuses"] = strings.Join(req.Statuses, ",")
+	}
+	audit.PhoneNumber = req.PhoneNumber
+	audit.ResultCount = total
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Queried messages", "users", len(req.UserIDs), "count", total)
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterIdleTTL is how long an unused client bucket is kept before eviction
+const rateLimiterIdleTTL = 5 * time.Minute
+
+// rateLimiterMaxClients bounds the number of client buckets kept; once reached,
+// the least recently used bucket makes room for a new client
+const rateLimiterMaxClients = 100_000
+
+// clientBucket is a token bucket for one client plus its last use for eviction
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// deferredLimitKey marks the context of a request whose limiting Middleware left
+// to the route, see Auth.Require and Unauthenticated
+type deferredLimitKey struct{}
+
+// RateLimiter applies a token bucket per client: the authenticated principal, or
+// the client IP for requests that don't authenticate
+type RateLimiter struct {
+	trustForwarded bool
+
+	mu       sync.Mutex
+	rps      rate.Limit // zero disables limiting
+	burst    int
+	clients  map[string]*clientBucket
+	stopChan chan struct{}
+}
+
+// NewRateLimiter creates a rate limiter allowing rps requests per second per client
+// with bursts of up to burst requests; zero rps lets every request through until
+// SetLimits enables limiting. When trustForwarded is set the first X-Forwarded-For
+// address is used as the client IP
+func NewRateLimiter(rps float64, burst int, trustForwarded bool) *RateLimiter {
+	rl := &RateLimiter{
+		rps:            rate.Limit(rps),
+		burst:          burst,
+		trustForwarded: trustForwarded,
+		clients:        make(map[string]*clientBucket),
+		stopChan:       make(chan struct{}),
+	}
+	go rl.evictIdle()
+
+	if rps > 0 {
+		slog.Info("Rate limiting enabled", "requests_per_second", rps, "burst", burst)
+	}
+	return rl
+}
+
+// SetLimits changes the rate and burst of every client, including those with a
+// bucket already; zero rps disables limiting
+func (rl *RateLimiter) SetLimits(rps float64, burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.rps, rl.burst = rate.Limit(rps), burst
+	if rps == 0 {
+		clear(rl.clients)
+		return
+	}
+	for _, b := range rl.clients {
+		b.limiter.SetLimit(rl.rps)
+		b.limiter.SetBurst(burst)
+	}
+}
+
+// Middleware rejects requests without credentials over their client IP's limit
+// with 429 and Retry-After. Requests carrying an API key or bearer token are
+// limited by the route: Auth.Require limits them by principal once authenticated,
+// and by client IP when authentication fails, so callers cannot dodge their
+// limit by sending a new key each time. Routes that don't authenticate limit
+// them by client IP with Unauthenticated
+// Health checks and metrics scrapes are never limited
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if hasCredentials(r) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), deferredLimitKey{}, rl)))
+			return
+		}
+		if rl.allow(w, "ip:"+rl.clientIP(r)) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Stop ends background eviction of idle client buckets
+func (rl *RateLimiter) Stop() {
+	close(rl.stopChan)
+}
+
+// Unauthenticated limits requests to a route that doesn't authenticate by client
+// IP, as Middleware does for requests without credentials
+func Unauthenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limitClientIP(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// limitClientIP takes a token from the client IP's bucket of the limiter that
+// left limiting r to the route, returning false once it has answered 429
+func limitClientIP(w http.ResponseWriter, r *http.Request) bool {
+	rl, ok := r.Context().Value(deferredLimitKey{}).(*RateLimiter)
+	return !ok || rl.allow(w, "ip:"+rl.clientIP(r))
+}
+
+// limitPrincipal takes a token from the principal's bucket of the limiter that
+// left limiting r to the route, returning false once it has answered 429
+func limitPrincipal(w http.ResponseWriter, r *http.Request, p *auth.Principal) bool {
+	rl, ok := r.Context().Value(deferredLimitKey{}).(*RateLimiter)
+	return !ok || rl.allow(w, principalKey(p))
+}
+
+// allow takes a token from the bucket of key, or answers 429 and returns false
+// when it has none left
+func (rl *RateLimiter) allow(w http.ResponseWriter, key string) bool {
+	limiter := rl.bucket(key)
+	if limiter == nil {
+		return true
+	}
+	reservation := limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		retryAfter := int(math.Ceil(delay.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded, retry after "+strconv.Itoa(retryAfter)+"s")
+		return false
+	}
+	return true
+}
+
+// bucket returns the client's token bucket, creating it on first use, or nil
+// while limiting is disabled
+func (rl *RateLimiter) bucket(key string) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.rps == 0 {
+		return nil
+	}
+
+	b, ok := rl.clients[key]
+	if !ok {
+		if len(rl.clients) >= rateLimiterMaxClients {
+			rl.evictOldest()
+		}
+		b = &clientBucket{limiter: rate.NewLimiter(rl.rps, rl.burst)}
+		rl.clients[key] = b
+	}
+	b.lastSeen = time.Now()
+	return b.limiter
+}
+
+// evictOldest drops the least recently used bucket. Callers hold rl.mu
+func (rl *RateLimiter) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for key, b := range rl.clients {
+		if oldestKey == "" || b.lastSeen.Before(oldest) {
+			oldestKey, oldest = key, b.lastSeen
+		}
+	}
+	delete(rl.clients, oldestKey)
+}
+
+// principalKey identifies an authenticated caller: its API key, or its subject
+// within its tenant
+func principalKey(p *auth.Principal) string {
+	if p.KeyID != "" {
+		return "key:" + p.TenantID + ":" + p.KeyID
+	}
+	return "principal:" + p.TenantID + ":" + p.Subject
+}
+
+// hasCredentials reports whether r carries an API key or bearer token
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get(auth.APIKeyHeader) != "" || strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// clientIP returns the remote address, or the first X-Forwarded-For hop when trusted
+func (rl *RateLimiter) clientIP(r *http.Request) string {
+	if rl.trustForwarded {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// evictIdle periodically drops buckets that haven't been used recently
+func (rl *RateLimiter) evictIdle() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rl.stopChan:
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-rateLimiterIdleTTL)
+			rl.mu.Lock()
+			for key, b := range rl.clients {
+				if b.lastSeen.Before(cutoff) {
+					delete(rl.clients, key)
+				}
+			}
+			rl.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/handlers"
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// keyAuthenticator accepts the API keys it maps to principals
+type keyAuthenticator map[string]*auth.Principal
+
+func (a keyAuthenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	key := r.Header.Get(auth.APIKeyHeader)
+	if key == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	principal, ok := a[key]
+	if !ok {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return principal, nil
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	authMiddleware := handlers.NewAuth(keyAuthenticator{
+		"key-a": {Subject: "a", TenantID: "t1", KeyID: "a", Scopes: []string{models.ScopeRead}},
+		"key-b": {Subject: "b", TenantID: "t1", KeyID: "b", Scopes: []string{models.ScopeRead}},
+	})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	// serve sends a request from remoteAddr with the API key, returning its status
+	serve := func(rl *handlers.RateLimiter, remoteAddr, key string) int {
+		handler := rl.Middleware(authMiddleware.Require(models.ScopeRead, ok))
+		req := httptest.NewRequest(http.MethodGet, "/v1/user/u1/messages", nil)
+		req.RemoteAddr = remoteAddr
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("invalid keys share the client IP's bucket", func(t *testing.T) {
+		rl := handlers.NewRateLimiter(1, 2, false)
+		defer rl.Stop()
+		var limited int
+		for i := range 10 {
+			if serve(rl, "192.0.2.1:1234", fmt.Sprintf("random-%d", i)) == http.StatusTooManyRequests {
+				limited++
+			}
+		}
+		if limited != 8 {
+			t.Errorf("limited %d of 10 requests with new keys, want 8", limited)
+		}
+	})
+
+	t.Run("principals have their own bucket behind one IP", func(t *testing.T) {
+		rl := handlers.NewRateLimiter(1, 2, false)
+		defer rl.Stop()
+		for _, key := range []string{"key-a", "key-a", "key-b", "key-b"} {
+			if code := serve(rl, "192.0.2.1:1234", key); code != http.StatusOK {
+				t.Fatalf("%s: status = %d, want 200", key, code)
+			}
+		}
+		if code := serve(rl, "192.0.2.2:1234", "key-a"); code != http.StatusTooManyRequests {
+			t.Errorf("key-a from another IP: status = %d, want 429", code)
+		}
+		if code := serve(rl, "192.0.2.1:1234", ""); code != http.StatusUnauthorized {
+			t.Errorf("without credentials: status = %d, want 401", code)
+		}
+	})
+}
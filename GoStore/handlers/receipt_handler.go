@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// maxReceiptBodyBytes caps the size of a delivery receipt payload
+const maxReceiptBodyBytes = 64 << 10
+
+// receiptResponse acknowledges an applied delivery receipt
+type receiptResponse struct {
+	ProviderMessageID string `json:"provider_message_id"`
+	Status            string `json:"status"`
+}
+
+// ReceiveDeliveryReceipt handles POST /v0/receipts
+// Carriers post DLRs here with a write-scoped API key; the receipt is matched to
+// a stored message of the key's tenant by provider message ID
+func (h *SMSHandler) ReceiveDeliveryReceipt(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
+	}
+
+	var receipt models.DeliveryReceipt
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReceiptBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&receipt); err != nil {
+		slog.InfoContext(r.Context(), "Invalid delivery receipt payload", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery receipt payload")
+		return
+	}
+
+	if err := receipt.Validate(); err != nil {
+		slog.InfoContext(r.Context(), "Invalid delivery receipt", "error", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Received delivery receipt", "provider_message_id", receipt.ProviderMessageID, "status", receipt.Status)
+
+	err = h.smsService.UpdateStatusByProviderMessageID(r.Context(), receipt.ProviderMessageID, receipt.ToStatusChange())
+	if errors.Is(err, services.ErrMessageNotFound) {
+		respondWithError(w, http.StatusNotFound, "No message found for provider_message_id")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error applying delivery receipt", "provider_message_id", receipt.ProviderMessageID, "error", err)
+		respondWithStoreError(w, err, "Failed to apply delivery receipt")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, receiptResponse{
+		ProviderMessageID: receipt.ProviderMessageID,
+		Status:            receipt.Status,
+	})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/cache"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+// Routes whose responses can be cached, by the name of their cache policy
+const (
+	CacheRouteMessages = "messages" // GET /user/{user_id}/messages
+	CacheRouteCount    = "count"    // GET /user/{user_id}/messages/count
+	CacheRouteStats    = "stats"    // GET /user/{user_id}/stats
+	CacheRouteDelivery = "delivery" // GET /analytics/delivery
+)
+
+// EnableResponseCache serves the routes with a policy in c from it, the service
+// invalidating the responses about a user as their messages change.
+// It must be called before the handler is used
+func (h *SMSHandler) EnableResponseCache(c *cache.Responses) {
+	h.responses = c
+}
+
+// cachedResponse returns the result of load for the request, about userID if
+// not empty, from the response cache when route is cached, and tells clients
+// how long they may cache it themselves. Requests are still validated and
+// audited by their handler, so only reading the store is saved
+func cachedResponse[T any](h *SMSHandler, w http.ResponseWriter, r *http.Request, route, userID string, load func(context.Context) (T, error)) (T, error) {
+	if h.responses == nil {
+		return load(r.Context())
+	}
+	policy, ok := h.responses.Policy(route)
+	tenantID, hasTenant := tenant.FromContext(r.Context())
+	if !ok || !hasTenant {
+		return load(r.Context())
+	}
+
+	// Keyed by the user ID as stored, which invalidation names, and the encoded
+	// query, whose parameters are sorted so their order doesn't split entries
+	if userID != "" {
+		userID = h.smsService.LookupKey(userID)
+	}
+	value, age, err := h.responses.Get(r.Context(), route, tenantID, userID, r.URL.Query().Encode(), func(ctx context.Context) (any, error) {
+		return load(ctx)
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	// Clients may keep it for as long as it stays fresh here; shared caches must not keep tenant data
+	maxAge := max(policy.TTL-age, 0)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d, stale-while-revalidate=%d", int(maxAge.Seconds()), int(policy.StaleWhileRevalidate.Seconds())))
+	return value.(T), nil
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// RetentionHandler handles HTTP requests for the retention policy of the caller's tenant
+type RetentionHandler struct {
+	retentionService *services.RetentionPolicyService
+	auditService     *services.AuditService
+	archiveAvailable bool
+}
+
+// NewRetentionHandler creates a new retention handler instance, recording every
+// change to policies with auditService. Policies archiving messages are only
+// accepted when archiveAvailable
+func NewRetentionHandler(retentionService *services.RetentionPolicyService, auditService *services.AuditService, archiveAvailable bool) *RetentionHandler {
+	return &RetentionHandler{
+		retentionService: retentionService,
+		auditService:     auditService,
+		archiveAvailable: archiveAvailable,
+	}
+}
+
+// setRetentionRequest is the payload for PUT /v0/retention-policy
+type setRetentionRequest struct {
+	RetentionDays int    `json:"retention_days"` // 0 keeps messages indefinitely
+	Action        string `json:"action"`
+}
+
+// GetRetentionPolicy handles GET /v0/retention-policy
+func (h *RetentionHandler) GetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := h.retentionService.Get(r.Context())
+	if errors.Is(err, services.ErrRetentionPolicyNotFound) {
+		respondWithError(w, http.StatusNotFound, "No retention policy set, the default applies")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading retention policy", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to read retention policy")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, policy)
+}
+
+// SetRetentionPolicy handles PUT /v0/retention-policy
+func (h *RetentionHandler) SetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	var req setRetentionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid retention policy payload")
+		return
+	}
+
+	policy := &models.RetentionPolicy{RetentionDays: req.RetentionDays, Action: req.Action}
+	if err := policy.Validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if policy.Action == models.RetentionActionArchive && !h.archiveAvailable {
+		respondWithError(w, http.StatusBadRequest, "Archival is not configured on this service")
+		return
+	}
+
+	if err := h.retentionService.Set(r.Context(), policy); err != nil {
+		slog.ErrorContext(r.Context(), "Error setting retention policy", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to set retention policy")
+		return
+	}
+
+	recordCompleted(r, h.auditService, newAuditRecord(r, models.AuditActionSetRetention))
+
+	respondWithJSON(w, http.StatusOK, policy)
+}
+
+// DeleteRetentionPolicy handles DELETE /v0/retention-policy
+func (h *RetentionHandler) DeleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	err := h.retentionService.Delete(r.Context())
+	if errors.Is(err, services.ErrRetentionPolicyNotFound) {
+		respondWithError(w, http.StatusNotFound, "No retention policy set")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting retention policy", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete retention policy")
+		return
+	}
+
+	recordCompleted(r, h.auditService, newAuditRecord(r, models.AuditActionDeleteRetention))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/search"
+)
+
+// defaultSearchLimit is the page size of search results unless limit is given
+const defaultSearchLimit = 20
+
+// SetMaxSearchLimit sets the largest limit accepted by search requests
+func (h *SMSHandler) SetMaxSearchLimit(limit int) {
+	h.maxSearchLimit.Store(int64(limit))
+}
+
+// SearchUserMessages handles GET /v0/user/{user_id}/messages/search
+// Query parameters: q (required), mode=match|phrase|fuzzy, status (comma-separated),
+// since and until (RFC 3339), skip and limit
+func (h *SMSHandler) SearchUserMessages(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	if !h.smsService.IsValidPhoneNumber(userID) {
+		slog.InfoContext(r.Context(), "Invalid user_id format", "user_id", userID)
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+
+	params := r.URL.Query()
+	query := &search.Query{
+		UserID: userID,
+		Text:   strings.TrimSpace(params.Get("q")),
+		Mode:   params.Get("mode"),
+		Limit:  defaultSearchLimit,
+	}
+	if query.Text == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing search text. Expected q parameter.")
+		return
+	}
+	if query.Mode == "" {
+		query.Mode = search.ModeMatch
+	}
+	if !search.IsValidMode(query.Mode) {
+		respondWithError(w, http.StatusBadRequest, "Invalid mode. Expected match, phrase or fuzzy.")
+		return
+	}
+
+	if status := params.Get("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			switch s {
+			case models.StatusSent, models.StatusDelivered, models.StatusFailed:
+				query.Statuses = append(query.Statuses, s)
+			default:
+				respondWithError(w, http.StatusBadRequest, "Invalid status. Expected SENT, DELIVERED or FAILED.")
+				return
+			}
+		}
+	}
+
+	var err error
+	if since := params.Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid since. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+	if until := params.Get("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid until. Expected RFC 3339 timestamp.")
+			return
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		maxLimit := int(h.maxSearchLimit.Load())
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 || query.Limit > maxLimit {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit. Expected 1 to %d.", maxLimit))
+			return
+		}
+	}
+	if skip := params.Get("skip"); skip != "" {
+		if query.Skip, err = strconv.Atoi(skip); err != nil || query.Skip < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid skip. Expected a non-negative integer.")
+			return
+		}
+	}
+
+	result, err := h.smsService.SearchMessages(r.Context(), query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error searching messages", "user_id", userID, "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to search messages")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionSearchMessages)
+	audit.UserID = userID
+	audit.ResultCount = int64(len(result.Messages))
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Searched messages", "user_id", userID, "mode", query.Mode, "total", result.Total)
+	respondWithJSON(w, http.StatusOK, result)
+}
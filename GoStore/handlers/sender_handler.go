@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// SenderHandler handles HTTP requests for the sender ID registry of the caller's tenant
+type SenderHandler struct {
+	senderService *services.SenderService
+	auditService  *services.AuditService
+}
+
+// NewSenderHandler creates a new sender handler instance, recording every
+// change to the registry with auditService
+func NewSenderHandler(senderService *services.SenderService, auditService *services.AuditService) *SenderHandler {
+	return &SenderHandler{
+		senderService: senderService,
+		auditService:  auditService,
+	}
+}
+
+// senderRequest is the payload for POST /v0/senders and PUT /v0/senders/{id}
+type senderRequest struct {
+	SenderID    string `json:"sender_id"`
+	Type        string `json:"type,omitempty"` // taken from the form of sender_id when omitted
+	Description string `json:"description,omitempty"`
+}
+
+// decodeSender reads and validates the sender of a request
+func decodeSender(w http.ResponseWriter, r *http.Request) (*models.Sender, bool) {
+	var req senderRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid sender payload")
+		return nil, false
+	}
+	sender := &models.Sender{SenderID: req.SenderID, Type: req.Type, Description: req.Description}
+	if err := sender.Validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return sender, true
+}
+
+// RegisterSender handles POST /v0/senders
+func (h *SenderHandler) RegisterSender(w http.ResponseWriter, r *http.Request) {
+	sender, ok := decodeSender(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.senderService.Register(r.Context(), sender)
+	if errors.Is(err, services.ErrSenderExists) {
+		respondWithError(w, http.StatusConflict, "Sender ID already registered")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error registering sender", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to register sender")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionRegisterSender)
+	audit.TargetID = sender.ID.Hex()
+	recordCompleted(r, h.auditService, audit)
+
+	respondWithJSON(w, http.StatusCreated, sender)
+}
+
+// ListSenders handles GET /v0/senders, optionally looking a sender up by sender_id or type
+func (h *SenderHandler) ListSenders(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	senderType := params.Get("type")
+	if senderType != "" && !models.IsValidSenderType(senderType) {
+		respondWithError(w, http.StatusBadRequest, "Invalid type. Expected short_code, alphanumeric or long_code.")
+		return
+	}
+
+	senders, err := h.senderService.List(r.Context(), params.Get("sender_id"), senderType)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing senders", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list senders")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, senders)
+}
+
+// GetSender handles GET /v0/senders/{id}
+func (h *SenderHandler) GetSender(w http.ResponseWriter, r *http.Request) {
+	sender, err := h.senderService.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, services.ErrSenderNotFound) {
+		respondWithError(w, http.StatusNotFound, "Sender not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading sender", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to read sender")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, sender)
+}
+
+// UpdateSender handles PUT /v0/senders/{id}
+func (h *SenderHandler) UpdateSender(w http.ResponseWriter, r *http.Request) {
+	sender, ok := decodeSender(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	err := h.senderService.Update(r.Context(), id, sender)
+	if errors.Is(err, services.ErrSenderNotFound) {
+		respondWithError(w, http.StatusNotFound, "Sender not found")
+		return
+	}
+	if errors.Is(err, services.ErrSenderExists) {
+		respondWithError(w, http.StatusConflict, "Sender ID already registered")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating sender", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update sender")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionUpdateSender)
+	audit.TargetID = id
+	recordCompleted(r, h.auditService, audit)
+
+	respondWithJSON(w, http.StatusOK, sender)
+}
+
+// DeleteSender handles DELETE /v0/senders/{id}
+func (h *SenderHandler) DeleteSender(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := h.senderService.Delete(r.Context(), id)
+	if errors.Is(err, services.ErrSenderNotFound) {
+		respondWithError(w, http.StatusNotFound, "Sender not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting sender", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete sender")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionDeleteSender)
+	audit.TargetID = id
+	recordCompleted(r, h.auditService, audit)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,62 +1,131 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
+	"github.com/ramG-reddy/sms-store/breaker"
+	"github.com/ramG-reddy/sms-store/cache"
+	"github.com/ramG-reddy/sms-store/flagging"
 	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/problem"
 	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
 )
 
+// userMessagesPath matches /v0/user/{user_id}/messages
+var userMessagesPath = regexp.MustCompile(`^/v0/user/([^/]+)/messages$`)
+
 // SMSHandler handles HTTP requests for SMS operations
 type SMSHandler struct {
-	smsService *services.SMSService
+	smsService   *services.SMSService
+	auditService *services.AuditService
+
+	// maxSearchLimit caps the limit of search requests
+	// It is set from the configuration at startup and on reload
+	maxSearchLimit atomic.Int64
+
+	responses *cache.Responses // nil caches no responses
 }
 
-// NewSMSHandler creates a new SMS handler instance
-func NewSMSHandler(smsService *services.SMSService) *SMSHandler {
+// NewSMSHandler creates a new SMS handler instance, recording every access to
+// messages with auditService
+func NewSMSHandler(smsService *services.SMSService, auditService *services.AuditService) *SMSHandler {
 	return &SMSHandler{
-		smsService: smsService,
+		smsService:   smsService,
+		auditService: auditService,
 	}
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+// erasureResponse reports how many messages were erased for a user
+type erasureResponse struct {
+	UserID       string `json:"user_id"`
+	DeletedCount int64  `json:"deleted_count"`
 }
 
-// GetUserMessages handles GET /v0/user/{user_id}/messages
+// GetUserMessages handles GET /v0/user/{user_id}/messages and /v1/user/{user_id}/messages
+// Responds 304 Not Modified when If-None-Match holds the ETag of the current messages
 func (h *SMSHandler) GetUserMessages(w http.ResponseWriter, r *http.Request) {
-	// Extract user_id from URL path
-	// Expected format: /v0/user/{user_id}/messages
-	re := regexp.MustCompile(`^/v0/user/([^/]+)/messages$`)
-	matches := re.FindStringSubmatch(r.URL.Path)
-
-	if len(matches) != 2 {
-		log.Printf("Invalid URL format: %s", r.URL.Path)
-		respondWithError(w, http.StatusBadRequest, "Invalid URL format")
-		return
+	userID := r.PathValue("user_id")
+	if userID == "" {
+		// /v0 is routed by prefix, so the path is parsed here
+		// Expected format: /v0/user/{user_id}/messages
+		matches := userMessagesPath.FindStringSubmatch(r.URL.Path)
+		if len(matches) != 2 {
+			slog.InfoContext(r.Context(), "Invalid URL format", "path", r.URL.Path)
+			respondWithError(w, http.StatusBadRequest, "Invalid URL format")
+			return
+		}
+		userID = matches[1]
 	}
 
-	userID := matches[1]
-
 	// Validate user_id (phone number format)
-	if !isValidPhoneNumber(userID) {
-		log.Printf("Invalid user_id format: %s", userID)
+	if !h.smsService.IsValidPhoneNumber(userID) {
+		slog.InfoContext(r.Context(), "Invalid user_id format", "user_id", userID)
 		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
 		return
 	}
 
-	log.Printf("Received request to get messages for user: %s", userID)
+	// Optional projection, e.g. fields=message_id,created_at,status
+	var fields []string
+	if value := r.URL.Query().Get("fields"); value != "" {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if !models.IsRecordField(field) {
+				respondWithError(w, http.StatusBadRequest, "Invalid field "+field+". Expected "+strings.Join(models.RecordFields, ", ")+".")
+				return
+			}
+			fields = append(fields, field)
+		}
+	}
+
+	// Optional order, e.g. sort=status:asc; newest first by default
+	var sort models.MessageSort
+	if value := r.URL.Query().Get("sort"); value != "" {
+		parsed, err := models.ParseMessageSort(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid sort: "+err.Error())
+			return
+		}
+		sort = parsed
+	}
+
+	filter, err := parseMessageFilter(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// The database runs the pattern on every message of the user, so only cheap ones are accepted
+	if pattern := r.URL.Query().Get("body_regex"); pattern != "" {
+		if err := models.ValidateBodyRegex(pattern); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid body_regex: "+err.Error()+".")
+			return
+		}
+		filter.BodyRegex = pattern
+	}
+
+	slog.DebugContext(r.Context(), "Received request to get messages", "user_id", userID, "fields", fields, "sort", sort.String())
+
+	if acceptsNDJSON(r) {
+		h.streamUserMessages(w, r, userID, filter, fields, sort)
+		return
+	}
 
 	// Retrieve messages from service
-	messages, err := h.smsService.GetMessagesByUserID(r.Context(), userID)
+	messages, err := cachedResponse(h, w, r, CacheRouteMessages, userID, func(ctx context.Context) ([]*models.SMSRecord, error) {
+		return h.smsService.GetMessagesByUserID(ctx, userID, filter, fields, sort)
+	})
 	if err != nil {
-		log.Printf("Error retrieving messages for user %s: %v", userID, err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve messages")
+		respondWithListError(w, r, userID, err)
 		return
 	}
 
@@ -65,30 +134,129 @@ func (h *SMSHandler) GetUserMessages(w http.ResponseWriter, r *http.Request) {
 		messages = make([]*models.SMSRecord, 0)
 	}
 
-	log.Printf("Successfully retrieved %d messages for user: %s", len(messages), userID)
-	respondWithJSON(w, http.StatusOK, messages)
+	audit := newAuditRecord(r, models.AuditActionReadMessages)
+	audit.UserID = userID
+	audit.ResultCount = int64(len(messages))
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	if checkNotModified(w, r, messagesETag(messages, filter, fields, sort)) {
+		slog.DebugContext(r.Context(), "Messages not modified", "user_id", userID, "count", len(messages))
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Retrieved messages", "user_id", userID, "count", len(messages))
+	if len(fields) == 0 {
+		respondWithJSON(w, http.StatusOK, messages)
+		return
+	}
+	projected := make([]map[string]any, len(messages))
+	for i, message := range messages {
+		projected[i] = message.Project(fields)
+	}
+	respondWithJSON(w, http.StatusOK, projected)
+}
+
+// respondWithListError answers a message list that failed to be read
+func respondWithListError(w http.ResponseWriter, r *http.Request, userID string, err error) {
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		respondWithError(w, http.StatusNotImplemented, "body_regex is not supported by the storage backend or with encrypted message bodies")
+	case errors.Is(err, store.ErrQueryTimeout):
+		respondWithError(w, http.StatusBadRequest, "body_regex took too long to match. Use a more specific pattern.")
+	default:
+		slog.ErrorContext(r.Context(), "Error retrieving messages", "user_id", userID, "error", err)
+		respondWithStoreError(w, err, "Failed to retrieve messages")
+	}
+}
+
+// DeleteUserMessages handles DELETE /v0/user/{user_id}/messages and /v1/user/{user_id}/messages
+// Hard-deletes every stored message for the user (GDPR right to erasure)
+func (h *SMSHandler) DeleteUserMessages(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	if !h.smsService.IsValidPhoneNumber(userID) {
+		slog.InfoContext(r.Context(), "Invalid user_id format", "user_id", userID)
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Received erasure request", "user_id", userID)
+
+	deleted, err := h.smsService.DeleteMessagesByUserID(r.Context(), userID, newAuditRecord(r, models.AuditActionEraseUserMessages))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error erasing messages", "user_id", userID, "error", err)
+		respondWithStoreError(w, err, "Failed to erase messages")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, erasureResponse{UserID: userID, DeletedCount: deleted})
 }
 
-// HealthCheck handles GET /health
-func (h *SMSHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	health := map[string]interface{}{
-		"status":  "UP",
-		"service": "sms-store",
+// parseRegion reads the optional country, given as country or country_code,
+// and dial code filters of a message list or report
+func parseRegion(params url.Values) (countryCode, dialCode string, err error) {
+	countryCode = params.Get("country_code")
+	if country := params.Get("country"); country != "" {
+		if countryCode != "" && countryCode != country {
+			return "", "", errors.New("Conflicting country and country_code. Give one of them.")
+		}
+		countryCode = country
+	}
+	if countryCode != "" && !models.IsValidCountryCode(countryCode) {
+		return "", "", errors.New("Invalid country. Expected ISO 3166-1 alpha-2 code, e.g. IN.")
 	}
-	respondWithJSON(w, http.StatusOK, health)
+	dialCode = params.Get("dial_code")
+	if dialCode != "" && !models.IsValidDialCode(dialCode) {
+		return "", "", errors.New("Invalid dial_code. Expected country calling code without the +, e.g. 91.")
+	}
+	return countryCode, dialCode, nil
+}
+
+// parseMessageFilter reads the optional metadata filters of a message list
+func parseMessageFilter(params url.Values) (models.MessageFilter, error) {
+	countryCode, dialCode, err := parseRegion(params)
+	if err != nil {
+		return models.MessageFilter{}, err
+	}
+	filter := models.MessageFilter{
+		Carrier:     params.Get("carrier"),
+		CountryCode: countryCode,
+		DialCode:    dialCode,
+		SenderID:    params.Get("sender_id"),
+		SenderType:  params.Get("sender_type"),
+		Registered:  params.Get("registered_sender") == "true",
+		Direction:   params.Get("direction"),
+		Language:    params.Get("lang"),
+		Flag:        params.Get("flag"),
+		Flagged:     params.Get("flagged") == "true",
+	}
+	if filter.SenderType != "" && !models.IsValidSenderType(filter.SenderType) {
+		return filter, errors.New("Invalid sender_type. Expected short_code, alphanumeric or long_code.")
+	}
+	if filter.Direction != "" && !models.IsValidDirection(filter.Direction) {
+		return filter, errors.New("Invalid direction. Expected outbound or inbound.")
+	}
+	if filter.Language != "" && !models.IsValidLanguage(filter.Language) {
+		return filter, errors.New("Invalid lang. Expected ISO 639-1 code, e.g. en.")
+	}
+	if filter.Flag != "" && !flagging.IsValidFlag(filter.Flag) {
+		return filter, errors.New("Invalid flag. Expected 1-32 lowercase letters, digits or underscores.")
+	}
+	if value := params.Get("segment_count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 1 {
+			return filter, errors.New("Invalid segment_count. Expected positive integer.")
+		}
+		filter.SegmentCount = count
+	}
+	return filter, nil
 }
 
 // isValidPhoneNumber validates phone number format
 // Accepts: +1234567890 or 1234567890 (10-15 digits)
 func isValidPhoneNumber(phoneNumber string) bool {
-	// Pattern: optional +, digit 1-9, followed by 9-14 more digits
-	pattern := `^\+?[1-9]\d{9,14}$`
-	matched, err := regexp.MatchString(pattern, phoneNumber)
-	if err != nil {
-		log.Printf("Error validating phone number: %v", err)
-		return false
-	}
-	return matched
+	return models.IsValidPhoneNumber(phoneNumber)
 }
 
 // respondWithJSON sends a JSON response
@@ -97,15 +265,27 @@ func respondWithJSON(w http.ResponseWriter, statusCode int, payload interface{})
 	w.WriteHeader(statusCode)
 
 	if err := json.NewEncoder(w).Encode(payload); err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
+		slog.Error("Error encoding JSON response", "error", err)
 	}
 }
 
-// respondWithError sends an error response
+// respondWithError sends an error response as problem details
 func respondWithError(w http.ResponseWriter, statusCode int, message string) {
-	errorResponse := ErrorResponse{
-		Error:   http.StatusText(statusCode),
-		Message: message,
+	problem.Write(w, statusCode, message)
+}
+
+// respondWithStoreError sends 503 with Retry-After while storage is unavailable,
+// so clients back off instead of waiting on a timeout, and 500 with message otherwise
+func respondWithStoreError(w http.ResponseWriter, err error, message string) {
+	if !errors.Is(err, store.ErrUnavailable) {
+		respondWithError(w, http.StatusInternalServerError, message)
+		return
+	}
+	retryAfter := 1
+	var open *breaker.OpenError
+	if errors.As(err, &open) {
+		retryAfter = max(retryAfter, int(math.Ceil(open.RetryAfter.Seconds())))
 	}
-	respondWithJSON(w, statusCode, errorResponse)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	respondWithError(w, http.StatusServiceUnavailable, "Storage temporarily unavailable, retry after "+strconv.Itoa(retryAfter)+"s")
 }
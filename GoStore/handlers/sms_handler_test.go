@@ -0,0 +1,131 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ramG-reddy/sms-store/handlers"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"github.com/ramG-reddy/sms-store/testharness"
+)
+
+// newTestServer routes the message endpoints to handlers over an in-memory
+// SMS service, scoping every request to tenant t1 as authentication would
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	h := testharness.NewMemory()
+	ingestHandler, err := handlers.NewIngestHandler(h.Service)
+	if err != nil {
+		t.Fatalf("NewIngestHandler: %v", err)
+	}
+	smsHandler := handlers.NewSMSHandler(h.Service, services.NewAuditService(h.Store))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/messages", ingestHandler.CreateMessage)
+	mux.HandleFunc("GET /v1/user/{user_id}/messages", smsHandler.GetUserMessages)
+	mux.HandleFunc("DELETE /v1/user/{user_id}/messages", smsHandler.DeleteUserMessages)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r.WithContext(tenant.WithID(r.Context(), "t1")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func do(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestMessageLifecycle(t *testing.T) {
+	server := newTestServer(t)
+	messages := server.URL + "/v1/user/+14155550100/messages"
+
+	event := `{"eventId":"m1","userId":"+14155550100","phoneNumber":"+14155550199","message":"hello","status":"SENT","createdAt":"2026-01-02T10:30:00"}`
+	if resp := do(t, http.MethodPost, server.URL+"/v1/messages", event); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /v1/messages = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	// Posting the same event again stores it once
+	if resp := do(t, http.MethodPost, server.URL+"/v1/messages", event); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /v1/messages again = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	resp := do(t, http.MethodGet, messages, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET messages = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var records []*models.SMSRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		t.Fatalf("decoding messages: %v", err)
+	}
+	if len(records) != 1 || records[0].MessageID != "m1" || records[0].TenantID != "t1" {
+		t.Fatalf("GET messages = %+v, want m1 of tenant t1", records)
+	}
+
+	resp = do(t, http.MethodDelete, messages, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE messages = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var erased struct {
+		DeletedCount int64 `json:"deleted_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&erased); err != nil {
+		t.Fatalf("decoding erasure: %v", err)
+	}
+	if erased.DeletedCount != 1 {
+		t.Errorf("deleted_count = %d, want 1", erased.DeletedCount)
+	}
+
+	resp = do(t, http.MethodGet, messages, "")
+	records = nil
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		t.Fatalf("decoding messages: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("GET messages after erasure returned %d records", len(records))
+	}
+}
+
+func TestCreateMessageRejectsInvalidEvents(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"malformed", `{"eventId":`, http.StatusBadRequest},
+		{"missing user", `{"eventId":"m1","status":"SENT"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := do(t, http.MethodPost, server.URL+"/v1/messages", tt.body); resp.StatusCode != tt.want {
+				t.Errorf("POST /v1/messages = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUserMessagesRejectsInvalidUserID(t *testing.T) {
+	server := newTestServer(t)
+
+	if resp := do(t, http.MethodGet, server.URL+"/v1/user/not-a-number/messages", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET messages = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Number of days covered by user stats
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+)
+
+// userStatsResponse is the body of a user stats response
+type userStatsResponse struct {
+	UserID string `json:"user_id"`
+	Days   int    `json:"days"`
+	*models.MessageStats
+}
+
+// GetUserStats handles GET /v0/user/{user_id}/stats?days=N
+// Counts the user's messages of the last N days (default 30) by day, week, status and direction
+func (h *SMSHandler) GetUserStats(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	if !h.smsService.IsValidPhoneNumber(userID) {
+		slog.InfoContext(r.Context(), "Invalid user_id format", "user_id", userID)
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+
+	days := defaultStatsDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 1 || days > maxStatsDays {
+			respondWithError(w, http.StatusBadRequest, "Invalid days. Expected 1 to 366.")
+			return
+		}
+	}
+
+	stats, err := cachedResponse(h, w, r, CacheRouteStats, userID, func(ctx context.Context) (*models.MessageStats, error) {
+		return h.smsService.GetUserStats(ctx, userID, days)
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error computing message stats", "user_id", userID, "error", err)
+		respondWithStoreError(w, err, "Failed to compute message stats")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionReadStats)
+	audit.UserID = userID
+	audit.ResultCount = stats.Total
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userStatsResponse{UserID: userID, Days: days, MessageStats: stats})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// sseHeartbeatInterval keeps idle SSE connections alive through proxies
+const sseHeartbeatInterval = 15 * time.Second
+
+// StreamUserMessages handles GET /v0/user/{user_id}/messages/stream
+// Holds the connection open and pushes newly stored messages and status changes as
+// Server-Sent Events, using the event type as the SSE event name
+func (h *SMSHandler) StreamUserMessages(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	if !h.smsService.IsValidPhoneNumber(userID) {
+		slog.InfoContext(r.Context(), "Invalid user_id format", "user_id", userID)
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+
+	// The server-wide write timeout would otherwise cut long-lived streams
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.ErrorContext(r.Context(), "Error disabling write deadline for stream", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	messages, unsubscribe, err := h.smsService.SubscribeUserMessages(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Tenant is required")
+		return
+	}
+	defer unsubscribe()
+
+	// Recorded as the stream opens; the messages it will push are not known yet
+	audit := newAuditRecord(r, models.AuditActionStreamMessages)
+	audit.UserID = userID
+	if !recordAudit(w, r, h.auditService, audit) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.ErrorContext(r.Context(), "Error flushing stream", "error", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Opened message stream", "user_id", userID)
+	defer slog.InfoContext(r.Context(), "Closed message stream", "user_id", userID)
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-messages:
+			if !ok {
+				return
+			}
+			// Quota events are only delivered to webhooks; the stream carries messages
+			if event.Usage != nil {
+				continue
+			}
+			data, err := json.Marshal(event.Record)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error encoding stream event", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Record.ID.Hex(), event.Type, data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+// RequireTenant rejects requests without a valid X-Tenant-ID header and scopes
+// the request context to that tenant for the wrapped handler
+func RequireTenant(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.Header.Get(tenant.Header)
+		if tenantID == "" {
+			respondWithError(w, http.StatusUnauthorized, "Missing "+tenant.Header+" header")
+			return
+		}
+		if !tenant.IsValidID(tenantID) {
+			slog.InfoContext(r.Context(), "Invalid tenant ID", "tenant_id", tenantID)
+			respondWithError(w, http.StatusBadRequest, "Invalid "+tenant.Header+" header")
+			return
+		}
+
+		next(w, r.WithContext(tenant.WithID(r.Context(), tenantID)))
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// maxTwilioBodyBytes caps the size of a Twilio webhook request
+const maxTwilioBodyBytes = 64 << 10
+
+// twilioSignatureHeader carries Twilio's signature of a webhook request
+const twilioSignatureHeader = "X-Twilio-Signature"
+
+// twilioSource labels the metrics of messages received from Twilio
+const twilioSource = "twilio"
+
+// emptyTwiML answers a Twilio webhook without replying to the sender
+const emptyTwiML = `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+
+// TwilioConfig configures the Twilio inbound SMS webhook
+type TwilioConfig struct {
+	AuthToken  string // signs every webhook request
+	WebhookURL string // the URL configured in Twilio, which the signature covers
+	TenantID   string // received messages are stored for this tenant
+}
+
+// TwilioHandler stores the SMS messages Twilio receives on the account's
+// numbers, posted to its inbound message webhook
+type TwilioHandler struct {
+	authToken  string
+	webhookURL string
+	pipeline   *ingest.Pipeline
+}
+
+// NewTwilioHandler creates a handler storing received messages with smsService
+// through the ingest pipeline, as inbound messages
+func NewTwilioHandler(cfg TwilioConfig, smsService *services.SMSService) (*TwilioHandler, error) {
+	pipeline, err := ingest.NewPipeline(ingest.PipelineConfig{
+		Decode:          decodeTwilioMessage,
+		DefaultTenantID: cfg.TenantID,
+		Direction:       models.DirectionInbound,
+		Persist:         ingest.Persist(smsService),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TwilioHandler{authToken: cfg.AuthToken, webhookURL: cfg.WebhookURL, pipeline: pipeline}, nil
+}
+
+// ReceiveMessage handles POST /v0/twilio/messages
+// Verifies X-Twilio-Signature, stores the message and answers with empty TwiML,
+// so nothing is replied to the sender. Twilio retries nothing, so a message
+// that can't be stored is only logged
+func (h *TwilioHandler) ReceiveMessage(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxTwilioBodyBytes)
+	if err := r.ParseForm(); err != nil {
+		slog.InfoContext(r.Context(), "Invalid Twilio webhook payload", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid Twilio webhook payload")
+		return
+	}
+	if !h.validSignature(r.Header.Get(twilioSignatureHeader), r.PostForm) {
+		slog.WarnContext(r.Context(), "Rejected Twilio webhook with an invalid signature", "message_sid", r.PostForm.Get("MessageSid"))
+		respondWithError(w, http.StatusForbidden, "Invalid "+twilioSignatureHeader)
+		return
+	}
+
+	metrics.IngestMessageConsumed(ingestBackend, twilioSource)
+	events := []*ingest.Event{ingest.NewEvent(r.Context(), twilioSource, []byte(r.PostForm.Encode()))}
+	err := h.pipeline.Run(r.Context(), events, func(e *ingest.Event) error {
+		metrics.IngestRejected(ingestBackend, twilioSource, e.Rejection().Reason)
+		slog.WarnContext(r.Context(), "Rejected Twilio message", "message_sid", r.PostForm.Get("MessageSid"), "reason", e.Rejection().Reason, "error", e.Rejection().Err)
+		return nil
+	})
+	if err != nil {
+		metrics.IngestProcessingFailed(ingestBackend, twilioSource)
+		slog.ErrorContext(r.Context(), "Error storing Twilio message", "message_sid", r.PostForm.Get("MessageSid"), "error", err)
+		respondWithStoreError(w, err, "Failed to store message")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(emptyTwiML))
+}
+
+// validSignature reports whether signature is Twilio's HMAC-SHA1 of the webhook
+// URL followed by every POST parameter, sorted by name, as name and value
+func (h *TwilioHandler) validSignature(signature string, form url.Values) bool {
+	var data strings.Builder
+	data.WriteString(h.webhookURL)
+	for _, name := range slices.Sorted(maps.Keys(form)) {
+		for _, value := range form[name] {
+			data.WriteString(name)
+			data.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(h.authToken))
+	mac.Write([]byte(data.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// decodeTwilioMessage maps the form parameters of a Twilio webhook to an SMS
+// event. The sender is the user, and the Twilio number it was sent to the
+// sender ID. Attachments of MMS messages are not downloaded
+func decodeTwilioMessage(_ context.Context, e *ingest.Event) (*models.KafkaEvent, error) {
+	form, err := url.ParseQuery(string(e.Payload))
+	if err != nil {
+		return nil, ingest.Rejected(ingest.ReasonMalformed, err)
+	}
+	if form.Get("MessageSid") == "" || form.Get("From") == "" {
+		return nil, ingest.Rejected(ingest.ReasonInvalid, errors.New("MessageSid and From are required"))
+	}
+
+	event := &models.KafkaEvent{
+		EventID:           form.Get("MessageSid"),
+		ProviderMessageID: form.Get("MessageSid"),
+		UserID:            form.Get("From"),
+		PhoneNumber:       form.Get("From"),
+		Message:           form.Get("Body"),
+		Status:            models.StatusDelivered,
+		CreatedAt:         time.Now().UTC().Format("2006-01-02T15:04:05"),
+		CountryCode:       form.Get("FromCountry"),
+		SenderID:          form.Get("To"),
+	}
+	if segments, err := strconv.Atoi(form.Get("NumSegments")); err == nil {
+		event.SegmentCount = segments
+	}
+	return event, nil
+}
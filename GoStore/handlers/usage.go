@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// byteCounter counts the response body bytes written by a handler.
+// Unwrap lets http.ResponseController reach the underlying writer for flushing
+// and deadline control on streaming endpoints
+type byteCounter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+func (b *byteCounter) Write(p []byte) (int, error) {
+	n, err := b.ResponseWriter.Write(p)
+	b.bytes += int64(n)
+	return n, err
+}
+
+func (b *byteCounter) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// EnableUsage meters every request authenticated by an API key with
+// usageService, rejecting keys over their daily quota, or over defaultQuota
+// for keys without their own (zero leaves those unlimited)
+func (a *Auth) EnableUsage(usageService *services.UsageService, defaultQuota int64) {
+	a.usage = usageService
+	a.defaultQuota = defaultQuota
+}
+
+// serveMetered counts the request against the principal's API key and serves it
+// with next unless the key is over its quota, counting the bytes sent. Metering
+// failures are logged and the request served, so an outage of the usage store
+// doesn't take the API down with it
+func (a *Auth) serveMetered(w http.ResponseWriter, r *http.Request, principal *auth.Principal, next http.HandlerFunc) {
+	quota := principal.DailyQuota
+	if quota == 0 {
+		quota = a.defaultQuota
+	}
+
+	requests, err := a.usage.CountRequest(r.Context(), principal.TenantID, principal.KeyID)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error metering API key request", "api_key_id", principal.KeyID, "error", err)
+		next(w, r)
+		return
+	}
+	if quota > 0 {
+		w.Header().Set("X-Quota-Limit", strconv.FormatInt(quota, 10))
+		w.Header().Set("X-Quota-Remaining", strconv.FormatInt(max(quota-requests, 0), 10))
+		if requests > quota {
+			now := time.Now().UTC()
+			midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(midnight.Sub(now).Seconds()))))
+			respondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("Daily quota of %d requests exceeded; it resets at 00:00 UTC", quota))
+			return
+		}
+	}
+
+	counter := &byteCounter{ResponseWriter: w}
+	next(counter, r)
+	if counter.bytes == 0 {
+		return
+	}
+	// The client may already be gone, which shouldn't lose the count
+	if err := a.usage.AddBytes(context.WithoutCancel(r.Context()), principal.TenantID, principal.KeyID, counter.bytes); err != nil {
+		slog.WarnContext(r.Context(), "Error metering API key bytes", "api_key_id", principal.KeyID, "error", err)
+	}
+}
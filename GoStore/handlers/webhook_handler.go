@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// Page size of the delivery log
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 1000
+)
+
+// WebhookHandler handles HTTP requests for webhook subscriptions
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	auditService   *services.AuditService
+}
+
+// NewWebhookHandler creates a new webhook handler instance, recording every
+// change to subscriptions with auditService
+func NewWebhookHandler(webhookService *services.WebhookService, auditService *services.AuditService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		auditService:   auditService,
+	}
+}
+
+// webhookRequest is the payload for POST /v0/webhooks and PUT /v0/webhooks/{id}
+type webhookRequest struct {
+	URL     string   `json:"url"`
+	UserID  string   `json:"user_id,omitempty"`
+	Events  []string `json:"events,omitempty"`  // omitted subscribes to every event type
+	Enabled *bool    `json:"enabled,omitempty"` // omitted enables the subscription
+	Secret  string   `json:"secret,omitempty"`  // omitted generates one on register, and keeps it on update
+}
+
+// webhookResponse is the body of a webhook subscription. The signing secret is
+// only set when the subscription is registered or its secret rotated
+type webhookResponse struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	URL       string    `json:"url"`
+	UserID    string    `json:"user_id"`
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// newWebhookResponse returns the body of sub, without its secret
+func newWebhookResponse(sub *models.WebhookSubscription) webhookResponse {
+	eventTypes := sub.Events
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return webhookResponse{
+		ID:        sub.ID.Hex(),
+		TenantID:  sub.TenantID,
+		URL:       sub.URL,
+		UserID:    sub.UserID,
+		Events:    eventTypes,
+		Enabled:   !sub.Disabled,
+		CreatedAt: sub.CreatedAt,
+		UpdatedAt: sub.UpdatedAt,
+	}
+}
+
+// decodeWebhook reads and validates the subscription of a request
+func decodeWebhook(w http.ResponseWriter, r *http.Request) (*models.WebhookSubscription, bool) {
+	var req webhookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook payload")
+		return nil, false
+	}
+
+	sub := &models.WebhookSubscription{
+		URL:      req.URL,
+		UserID:   req.UserID,
+		Events:   req.Events,
+		Disabled: req.Enabled != nil && !*req.Enabled,
+		Secret:   req.Secret,
+	}
+	if err := sub.Validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	for _, eventType := range sub.Events {
+		if !slices.Contains(events.Types, eventType) {
+			respondWithError(w, http.StatusBadRequest, "Invalid event type "+eventType+". Expected "+strings.Join(events.Types, ", ")+".")
+			return nil, false
+		}
+	}
+	return sub, true
+}
+
+// RegisterWebhook handles POST /v0/webhooks
+// The signing secret is only returned in this response
+func (h *WebhookHandler) RegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	sub, ok := decodeWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.webhookService.Register(r.Context(), sub)
+	if errors.Is(err, services.ErrForbiddenWebhookURL) {
+		respondWithError(w, http.StatusBadRequest, "url host must resolve to publicly routable addresses")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error registering webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to register webhook")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionRegisterWebhook)
+	audit.UserID = sub.UserID
+	audit.TargetID = sub.ID.Hex()
+	recordCompleted(r, h.auditService, audit)
+
+	response := newWebhookResponse(sub)
+	response.Secret = sub.Secret
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+// ListWebhooks handles GET /v0/webhooks?user_id=
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID != "" && !isValidPhoneNumber(userID) {
+		respondWithError(w, http.StatusBadRequest, "Invalid user_id format. Expected phone number.")
+		return
+	}
+
+	subs, err := h.webhookService.List(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing webhooks", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to list webhooks")
+		return
+	}
+	response := make([]webhookResponse, len(subs))
+	for i, sub := range subs {
+		response[i] = newWebhookResponse(sub)
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetWebhook handles GET /v0/webhooks/{id}
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.webhookService.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		respondWithError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to read webhook")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newWebhookResponse(sub))
+}
+
+// UpdateWebhook handles PUT /v0/webhooks/{id}
+// Disabling a subscription stops new deliveries; retries already scheduled go ahead
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	sub, ok := decodeWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	err := h.webhookService.Update(r.Context(), id, sub)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		respondWithError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if errors.Is(err, services.ErrForbiddenWebhookURL) {
+		respondWithError(w, http.StatusBadRequest, "url host must resolve to publicly routable addresses")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to update webhook")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionUpdateWebhook)
+	audit.UserID = sub.UserID
+	audit.TargetID = id
+	recordCompleted(r, h.auditService, audit)
+
+	respondWithJSON(w, http.StatusOK, newWebhookResponse(sub))
+}
+
+// RotateWebhookSecret handles POST /v0/webhooks/{id}/secret
+// The new signing secret is only returned in this response
+func (h *WebhookHandler) RotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	sub, err := h.webhookService.RotateSecret(r.Context(), id)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		respondWithError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error rotating webhook secret", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to rotate webhook secret")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionRotateWebhook)
+	audit.TargetID = id
+	recordCompleted(r, h.auditService, audit)
+
+	response := newWebhookResponse(sub)
+	response.Secret = sub.Secret
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// GetWebhookDeliveries handles GET /v0/webhooks/{id}/deliveries?success=&skip=N&limit=N
+// Lists the logged delivery attempts of the subscription, newest first
+func (h *WebhookHandler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	var success *bool
+	switch r.URL.Query().Get("success") {
+	case "":
+	case "true":
+		success = new(bool)
+		*success = true
+	case "false":
+		success = new(bool)
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid success. Expected true or false.")
+		return
+	}
+	skip, limit, ok := parsePage(w, r, defaultDeliveryLimit, maxDeliveryLimit)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.webhookService.Deliveries(r.Context(), r.PathValue("id"), success, skip, limit)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		respondWithError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading webhook deliveries", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to read webhook deliveries")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// DeleteWebhook handles DELETE /v0/webhooks/{id}
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := h.webhookService.Delete(r.Context(), id)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		respondWithError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting webhook", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	audit := newAuditRecord(r, models.AuditActionDeleteWebhook)
+	audit.TargetID = id
+	recordCompleted(r, h.auditService, audit)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,308 @@
+// Package handoff restarts the service in place without dropping connections
+// or churning consumer groups. On request the running process starts a new one
+// from its executable and passes it the listening sockets, so connections keep
+// being accepted throughout. The new process initializes up to starting its
+// message broker consumers, and only then does the old one drain and stop its
+// own, so each group sees one member leave before its replacement joins
+// rather than both competing for partitions. Once the new process serves, the
+// old one shuts down gracefully, finishing the requests it already accepted
+package handoff
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// envListeners names the listeners a process started by a handoff inherits,
+	// comma-separated in the order of their file descriptors
+	envListeners = "SMS_STORE_HANDOFF_LISTENERS"
+
+	// firstFD is the descriptor of the first inherited listener, after stdin,
+	// stdout and stderr. The status and release pipes follow the listeners
+	firstFD = 3
+)
+
+// Messages the new process sends the old one on the status pipe
+const (
+	msgConsumers = "consumers" // about to start its consumers
+	msgReady     = "ready"     // serving
+)
+
+// Handoff holds the listeners of the process, to pass on in a restart, and its
+// side of the handoff that started it, if one did
+type Handoff struct {
+	timeout time.Duration
+	pidFile string
+
+	mu        sync.Mutex
+	listeners []namedListener
+	inherited map[string]net.Listener // not yet taken over by Listen
+
+	// Pipes to the old process, when started by a handoff
+	status  *os.File
+	release *os.File
+}
+
+// namedListener is a listener with the name it is passed on by
+type namedListener struct {
+	name     string
+	listener net.Listener
+}
+
+// New returns the handoff of this process, taking over the listeners of the
+// old process when a restart started it. Each step of a restart must complete
+// within timeout. A non-empty pidFile is kept naming the process that serves
+func New(timeout time.Duration, pidFile string) (*Handoff, error) {
+	h := &Handoff{timeout: timeout, pidFile: pidFile, inherited: make(map[string]net.Listener)}
+	names := os.Getenv(envListeners)
+	if names == "" {
+		return h, nil
+	}
+	// Not passed on to processes this one starts
+	os.Unsetenv(envListeners)
+
+	fd := firstFD
+	for _, name := range strings.Split(names, ",") {
+		file := os.NewFile(uintptr(fd), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to inherit %s listener: %w", name, err)
+		}
+		h.inherited[name] = listener
+		fd++
+	}
+	h.status = os.NewFile(uintptr(fd), "handoff-status")
+	h.release = os.NewFile(uintptr(fd+1), "handoff-release")
+	slog.Info("Took over listeners from the previous process", "listeners", names, "parent_pid", os.Getppid())
+	return h, nil
+}
+
+// Listen returns the listener of the given name inherited from the old
+// process, or listens on addr. An inherited listener on another port than
+// addr, as after a change of port, is closed instead
+func (h *Handoff) Listen(name, addr string) (net.Listener, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	listener, ok := h.inherited[name]
+	if ok {
+		delete(h.inherited, name)
+		if samePort(listener.Addr().String(), addr) {
+			h.listeners = append(h.listeners, namedListener{name: name, listener: listener})
+			return listener, nil
+		}
+		listener.Close()
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	h.listeners = append(h.listeners, namedListener{name: name, listener: listener})
+	return listener, nil
+}
+
+// samePort reports whether two addresses have the same port
+func samePort(a, b string) bool {
+	_, portA, errA := net.SplitHostPort(a)
+	_, portB, errB := net.SplitHostPort(b)
+	return errA == nil && errB == nil && portA == portB
+}
+
+// AwaitConsumers tells the old process that this one is about to start its
+// consumers and waits for the old one to stop its own. It returns at once
+// unless a restart started the process
+func (h *Handoff) AwaitConsumers() error {
+	if h.status == nil {
+		return nil
+	}
+	if err := h.send(msgConsumers); err != nil {
+		return err
+	}
+	// The old process closes its end once its consumers stopped, or by exiting
+	slog.Info("Waiting for the previous process to stop its consumers")
+	_, err := io.Copy(io.Discard, h.release)
+	h.release.Close()
+	return err
+}
+
+// Ready marks the process as serving: it writes the PID file, closes the
+// inherited listeners nothing took over, and tells the old process to shut down
+func (h *Handoff) Ready() error {
+	h.mu.Lock()
+	for name, listener := range h.inherited {
+		listener.Close()
+		delete(h.inherited, name)
+	}
+	h.mu.Unlock()
+
+	if h.pidFile != "" {
+		if err := os.WriteFile(h.pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+			return fmt.Errorf("failed to write PID file: %w", err)
+		}
+	}
+	if h.status == nil {
+		return nil
+	}
+	err := h.send(msgReady)
+	h.status.Close()
+	h.status = nil
+	return err
+}
+
+// Close removes the PID file unless it names another process, as it does
+// once this one has handed over
+func (h *Handoff) Close() {
+	if h.pidFile == "" {
+		return
+	}
+	data, err := os.ReadFile(h.pidFile)
+	if err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(os.Getpid()) {
+		os.Remove(h.pidFile)
+	}
+}
+
+// send writes a message to the old process
+func (h *Handoff) send(msg string) error {
+	if _, err := h.status.WriteString(msg + "\n"); err != nil {
+		return fmt.Errorf("failed to signal the previous process: %w", err)
+	}
+	return nil
+}
+
+// Restart starts a new process from the executable with the same arguments
+// and environment, passes it the listeners, and calls release to stop the
+// consumers once it is about to start its own. It returns nil once the new
+// process serves, and the caller then shuts down. If it fails before calling
+// release the new process is killed and this one carries on serving; after
+// release it can only shut down, leaving the new process to its supervisor
+func (h *Handoff) Restart(release func()) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the executable: %w", err)
+	}
+
+	h.mu.Lock()
+	names := make([]string, 0, len(h.listeners))
+	files := make([]*os.File, 0, len(h.listeners)+2)
+	for _, l := range h.listeners {
+		filer, ok := l.listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			h.mu.Unlock()
+			closeAll(files)
+			return fmt.Errorf("%s listener cannot be passed on", l.name)
+		}
+		file, err := filer.File()
+		if err != nil {
+			h.mu.Unlock()
+			closeAll(files)
+			return fmt.Errorf("failed to pass on %s listener: %w", l.name, err)
+		}
+		names = append(names, l.name)
+		files = append(files, file)
+	}
+	h.mu.Unlock()
+
+	statusRead, statusWrite, err := os.Pipe()
+	if err != nil {
+		closeAll(files)
+		return err
+	}
+	defer statusRead.Close()
+	releaseRead, releaseWrite, err := os.Pipe()
+	if err != nil {
+		closeAll(append(files, statusWrite))
+		return err
+	}
+	defer releaseWrite.Close()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), envListeners+"="+strings.Join(names, ","))
+	cmd.ExtraFiles = append(files, statusWrite, releaseRead)
+	err = cmd.Start()
+	// The new process has its own copies
+	closeAll(cmd.ExtraFiles)
+	if err != nil {
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+	pid := cmd.Process.Pid
+	slog.Info("Started new process", "pid", pid, "listeners", names)
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	messages := make(chan string, 2)
+	go func() {
+		defer close(messages)
+		scanner := bufio.NewScanner(statusRead)
+		for scanner.Scan() {
+			messages <- scanner.Text()
+		}
+	}()
+
+	if err := h.await(messages, exited, msgConsumers); err != nil {
+		cmd.Process.Kill()
+		return fmt.Errorf("new process %d failed to start: %w", pid, err)
+	}
+	slog.Info("Handing consumers over to the new process", "pid", pid)
+	release()
+	releaseWrite.Close()
+
+	if err := h.await(messages, exited, msgReady); err != nil {
+		return fmt.Errorf("new process %d failed to start serving: %w", pid, err)
+	}
+	return nil
+}
+
+// await waits for the new process to send want
+func (h *Handoff) await(messages <-chan string, exited <-chan error, want string) error {
+	timer := time.NewTimer(h.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				// Closed by its exit, most likely, whose status says more
+				select {
+				case err := <-exited:
+					return exitError(err)
+				case <-time.After(time.Second):
+					return errors.New("it closed the handoff")
+				}
+			}
+			if msg == want {
+				return nil
+			}
+		case err := <-exited:
+			return exitError(err)
+		case <-timer.C:
+			return fmt.Errorf("no %q from it within %s", want, h.timeout)
+		}
+	}
+}
+
+// exitError describes the exit of the new process
+func exitError(err error) error {
+	if err == nil {
+		return errors.New("it exited")
+	}
+	return fmt.Errorf("it exited: %w", err)
+}
+
+// closeAll closes files
+func closeAll(files []*os.File) {
+	for _, file := range files {
+		file.Close()
+	}
+}
@@ -0,0 +1,109 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/breaker"
+)
+
+// Gate holds up a consumer's intake, while an operator has paused it or while
+// storage is unavailable. Consumers embed one, which gives them Pause, Resume
+// and Paused, and wait on it in their receive loop
+type Gate struct {
+	name  string // names the consumer in logs, e.g. "Kafka consumer"
+	attrs []any  // logged with each pause and resume
+	retry time.Duration
+	stop  <-chan struct{}
+
+	// resume is non-nil while the consumer is paused, and closed on Resume
+	mu     sync.Mutex
+	resume chan struct{}
+}
+
+// NewGate creates a gate, open, for the consumer stopped by closing stop. retry
+// is how long WaitAvailable sleeps when storage doesn't say when to try again
+func NewGate(name string, stop <-chan struct{}, retry time.Duration, attrs ...any) *Gate {
+	return &Gate{name: name, attrs: attrs, retry: retry, stop: stop}
+}
+
+// Pause stops intake until Resume is called
+// It reports false if the consumer was already paused
+func (g *Gate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.resume != nil {
+		return false
+	}
+	g.resume = make(chan struct{})
+	slog.Info(g.name+" paused", g.attrs...)
+	return true
+}
+
+// Resume restarts intake after Pause
+// It reports false if the consumer wasn't paused
+func (g *Gate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.resume == nil {
+		return false
+	}
+	close(g.resume)
+	g.resume = nil
+	slog.Info(g.name+" resumed", g.attrs...)
+	return true
+}
+
+// Paused reports whether the consumer is paused
+func (g *Gate) Paused() bool {
+	return g.resumed() != nil
+}
+
+// resumed returns a channel closed on Resume, or nil if the consumer isn't paused
+func (g *Gate) resumed() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resume
+}
+
+// WaitResumed blocks while the consumer is paused
+// It reports false if the consumer is stopped, whether paused or not
+func (g *Gate) WaitResumed() bool {
+	if resumed := g.resumed(); resumed != nil {
+		select {
+		case <-resumed:
+		case <-g.stop:
+			return false
+		}
+	}
+	select {
+	case <-g.stop:
+		return false
+	default:
+		return true
+	}
+}
+
+// WaitAvailable sleeps until the storage circuit breaker lets the next call
+// through, holding up intake rather than failing every message while storage
+// is down. It reports false if the consumer is stopped while waiting
+func (g *Gate) WaitAvailable(ctx context.Context, cause error) bool {
+	delay := g.retry
+	var open *breaker.OpenError
+	if errors.As(cause, &open) {
+		delay = open.RetryAfter
+	}
+	slog.WarnContext(ctx, "Storage unavailable, pausing intake", append([]any{"consumer", g.name, "retry_in", delay}, g.attrs...)...)
+
+	select {
+	case <-time.After(delay):
+		return true
+	case <-g.stop:
+		return false
+	}
+}
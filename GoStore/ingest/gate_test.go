@@ -0,0 +1,67 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/breaker"
+)
+
+func TestGatePauseResume(t *testing.T) {
+	g := NewGate("test consumer", make(chan struct{}), time.Millisecond)
+
+	if g.Paused() {
+		t.Fatal("new gate is paused")
+	}
+	if !g.Pause() || g.Pause() {
+		t.Fatal("Pause should report true once, then false")
+	}
+	if !g.Paused() {
+		t.Fatal("gate not paused after Pause")
+	}
+
+	waited := make(chan bool)
+	go func() { waited <- g.WaitResumed() }()
+	select {
+	case <-waited:
+		t.Fatal("WaitResumed returned while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if !g.Resume() || g.Resume() {
+		t.Fatal("Resume should report true once, then false")
+	}
+	if !<-waited {
+		t.Fatal("WaitResumed reported stopped after Resume")
+	}
+}
+
+func TestGateStop(t *testing.T) {
+	stop := make(chan struct{})
+	g := NewGate("test consumer", stop, time.Hour)
+	g.Pause()
+
+	waited := make(chan bool)
+	go func() { waited <- g.WaitResumed() }()
+	close(stop)
+	if <-waited {
+		t.Fatal("WaitResumed reported resumed after stop")
+	}
+	if g.WaitAvailable(context.Background(), errors.New("down")) {
+		t.Fatal("WaitAvailable reported available after stop")
+	}
+}
+
+func TestGateWaitAvailableHonorsRetryAfter(t *testing.T) {
+	g := NewGate("test consumer", make(chan struct{}), time.Hour)
+
+	start := time.Now()
+	if !g.WaitAvailable(context.Background(), &breaker.OpenError{RetryAfter: time.Millisecond}) {
+		t.Fatal("WaitAvailable reported stopped")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WaitAvailable slept %v, not the breaker's retry delay", elapsed)
+	}
+}
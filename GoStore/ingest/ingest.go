@@ -0,0 +1,139 @@
+// Package ingest holds what the message broker consumers storing SMS events
+// have in common: the Ingestor interface they are started behind, and the
+// pipeline of stages every event passes through on its way to storage
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+// Ingestor consumes SMS events from a message broker and stores them
+type Ingestor interface {
+	// Check reports whether the ingestor is running and can reach its broker,
+	// and its backlog is no more than maxLag messages (zero disables that check)
+	Check(ctx context.Context, maxLag int64) error
+
+	// MaxLag returns the backlog of messages last seen waiting to be consumed
+	MaxLag() int64
+
+	// InFlight returns the number of messages received but not yet stored
+	InFlight() int
+
+	// Pause stops receiving new messages until Resume; messages already received
+	// are still stored. Each reports false if the ingestor already was paused,
+	// or wasn't
+	Pause() bool
+	Resume() bool
+	Paused() bool
+
+	// Stop stops receiving messages, storing those already received first
+	Stop() error
+}
+
+// Reasons an event is rejected, reported when it is dead-lettered
+const (
+	ReasonMalformed        = "malformed"
+	ReasonInvalid          = "invalid"
+	ReasonRetriesExhausted = "retries_exhausted"
+	ReasonRejected         = "rejected"
+	ReasonSchemaViolation  = "schema_violation"
+	ReasonQuotaExceeded    = "quota_exceeded"
+)
+
+// RejectedError marks an event the ingestor has given up on, either because
+// it can never be stored or because storing it kept failing, so that retrying
+// it would only hold up the events behind it
+type RejectedError struct {
+	Reason string
+	Err    error
+}
+
+func (e *RejectedError) Error() string {
+	return e.Reason + ": " + e.Err.Error()
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// Rejected wraps err so the event is dead-lettered instead of retried
+func Rejected(reason string, err error) error {
+	return &RejectedError{Reason: reason, Err: err}
+}
+
+// DecodeJSON deserializes an SMS event from its JSON payload
+func DecodeJSON(payload []byte) (*models.KafkaEvent, error) {
+	event := &models.KafkaEvent{}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, Rejected(ReasonMalformed, fmt.Errorf("failed to unmarshal SMS event: %w", err))
+	}
+	return event, nil
+}
+
+// Validation configures strict validation of events; the zero value leaves it off
+type Validation struct {
+	Strict bool
+	Limits models.StrictLimits
+}
+
+// ValidationError lists the rules of strict validation an event breaks
+type ValidationError struct {
+	Violations []models.Violation
+}
+
+func (e *ValidationError) Error() string {
+	return "strict validation failed: " + e.Summary()
+}
+
+// Summary lists the violations as field:rule pairs, e.g. "phoneNumber:e164,message:max_length"
+func (e *ValidationError) Summary() string {
+	pairs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		pairs[i] = v.String()
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Retryable reports whether every violation may pass when the event is
+// processed again later, so it is retried rather than dead-lettered
+func (e *ValidationError) Retryable() bool {
+	for _, v := range e.Violations {
+		if !v.Retryable {
+			return false
+		}
+	}
+	return true
+}
+
+// validateEvent assigns events without a tenantId to defaultTenantID and checks
+// the event is complete, and with strict validation that it breaks no rule.
+// Events breaking only retryable rules fail with an error that isn't Rejected,
+// so they are processed again instead of dead-lettered
+func validateEvent(event *models.KafkaEvent, defaultTenantID string, validation Validation) error {
+	if event.TenantID == "" {
+		event.TenantID = defaultTenantID
+	}
+	if !tenant.IsValidID(event.TenantID) {
+		return Rejected(ReasonInvalid, fmt.Errorf("invalid tenantId: %q", event.TenantID))
+	}
+	if validation.Strict {
+		if violations := event.StrictViolations(validation.Limits, time.Now()); len(violations) > 0 {
+			err := &ValidationError{Violations: violations}
+			if err.Retryable() {
+				return fmt.Errorf("event %s: %w", event.EventID, err)
+			}
+			return Rejected(ReasonInvalid, err)
+		}
+	}
+	if err := event.Validate(); err != nil {
+		return Rejected(ReasonInvalid, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// writeTimeout bounds the write of a batch
+const writeTimeout = 5 * time.Second
+
+// Persist returns a persist stage storing the records of a batch with a single
+// unordered bulk write, for brokers that redeliver what isn't acknowledged.
+// Records the store rejects are rejected. If any record failed transiently, or
+// the whole write failed, the batch fails so it is redelivered, and the records
+// already stored are then skipped as duplicates
+func Persist(smsService *services.SMSService) ProcessorFunc {
+	return PersistWith(smsService.SaveMessages)
+}
+
+// PersistWith is Persist storing the records with save, which reports partial
+// failures as SMSService.SaveMessages does
+func PersistWith(save func(ctx context.Context, records []*models.SMSRecord) error) ProcessorFunc {
+	return func(ctx context.Context, events []*Event) ([]*Event, error) {
+		records := make([]*models.SMSRecord, len(events))
+		for i, e := range events {
+			records[i] = e.Record
+		}
+
+		writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+		defer cancel()
+		err := save(writeCtx, records)
+		var bulkErr *services.BulkSaveError
+		if !errors.As(err, &bulkErr) {
+			if err != nil {
+				return nil, fmt.Errorf("failed to save batch to database: %w", err)
+			}
+			return events, nil
+		}
+
+		var transient error
+		for i, recordErr := range bulkErr.Failed {
+			switch details, ok := db.ValidationFailure(recordErr); {
+			case db.IsTransient(recordErr):
+				transient = recordErr
+			case ok:
+				events[i].Reject(Rejected(ReasonSchemaViolation, fmt.Errorf("%w: %s", recordErr, details)))
+			case errors.Is(recordErr, services.ErrQuotaExceeded):
+				events[i].Reject(Rejected(ReasonQuotaExceeded, recordErr))
+			default:
+				events[i].Reject(Rejected(ReasonRejected, recordErr))
+			}
+		}
+		if transient != nil {
+			return nil, fmt.Errorf("failed to save %d of %d records: %w", len(bulkErr.Failed), len(records), transient)
+		}
+		return events, nil
+	}
+}
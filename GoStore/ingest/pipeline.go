@@ -0,0 +1,230 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Built-in stages of the pipeline, in the order they run
+const (
+	StageValidate  = "validate"  // decodes each payload and validates the event
+	StageNormalize = "normalize" // converts each event into the record to store
+	StageEnrich    = "enrich"    // no built-in processor; a place for custom enrichment
+	StageDedupe    = "dedupe"    // drops repeats of a message ID earlier in the batch
+	StagePersist   = "persist"   // stores the records
+)
+
+// Event is an SMS message passing through the pipeline
+type Event struct {
+	Source  string // the topic, subject or queue the message was received from
+	Payload []byte
+	Event   *models.KafkaEvent // set by the validate stage
+	Record  *models.SMSRecord  // set by the normalize stage
+
+	ctx      context.Context
+	rejected *RejectedError
+}
+
+// NewEvent creates an event for a message received from source, processed
+// within ctx, e.g. carrying its trace span and request ID
+func NewEvent(ctx context.Context, source string, payload []byte) *Event {
+	return &Event{Source: source, Payload: payload, ctx: ctx}
+}
+
+// Context returns the context the message is processed within
+func (e *Event) Context() context.Context {
+	return e.ctx
+}
+
+// Reject marks the event as one that can never be stored, so it is
+// dead-lettered once the current stage returns instead of being passed on
+// Errors made with Rejected keep their reason; others are ReasonInvalid
+func (e *Event) Reject(err error) {
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		rejected = &RejectedError{Reason: ReasonInvalid, Err: err}
+	}
+	e.rejected = rejected
+}
+
+// Rejection returns why the event was rejected, or nil
+func (e *Event) Rejection() *RejectedError {
+	return e.rejected
+}
+
+// ProcessorFunc is a stage of the pipeline. It is handed the events of a batch
+// that earlier stages passed on and returns those to pass to the next stage;
+// events it leaves out are acknowledged without being stored, and events it
+// rejects are dead-lettered. Any error fails the whole batch, which is retried
+// from the first stage, so processors must be safe to run again
+type ProcessorFunc func(ctx context.Context, events []*Event) ([]*Event, error)
+
+// DecodeFunc deserializes the payload of an event; errors not made with
+// Rejected fail the batch
+type DecodeFunc func(ctx context.Context, e *Event) (*models.KafkaEvent, error)
+
+// Stage is a custom processor run after the named stage, e.g. after StageEnrich
+// to enrich records or after StagePersist to act on stored ones. Stages added
+// after the same stage run in the order they are configured
+type Stage struct {
+	Name    string
+	After   string // a built-in or earlier custom stage
+	Process ProcessorFunc
+}
+
+// PipelineConfig configures the built-in stages of a pipeline and adds custom ones
+type PipelineConfig struct {
+	Decode          DecodeFunc // nil decodes JSON payloads
+	DefaultTenantID string     // assigned to events without a tenantId
+	Validation      Validation // strict validation of events, off by default
+	Direction       string     // of records whose event doesn't name one
+	Persist         ProcessorFunc
+	Stages          []Stage
+}
+
+// Pipeline is the ordered chain of stages the events of a source pass through
+type Pipeline struct {
+	stages []pipelineStage
+}
+
+// pipelineStage is a named stage of a pipeline
+type pipelineStage struct {
+	name    string
+	process ProcessorFunc // nil for a stage without a built-in processor
+	custom  bool
+}
+
+// NewPipeline returns the built-in stages configured by cfg with its custom stages added
+func NewPipeline(cfg PipelineConfig) (*Pipeline, error) {
+	decode := cfg.Decode
+	if decode == nil {
+		decode = func(_ context.Context, e *Event) (*models.KafkaEvent, error) { return DecodeJSON(e.Payload) }
+	}
+	stages := []pipelineStage{
+		{name: StageValidate, process: func(ctx context.Context, events []*Event) ([]*Event, error) {
+			return validateEvents(ctx, events, decode, cfg.DefaultTenantID, cfg.Validation)
+		}},
+		{name: StageNormalize, process: func(_ context.Context, events []*Event) ([]*Event, error) {
+			return normalizeEvents(events, cfg.Direction), nil
+		}},
+		{name: StageEnrich},
+		{name: StageDedupe, process: func(_ context.Context, events []*Event) ([]*Event, error) {
+			return dedupeEvents(events), nil
+		}},
+		{name: StagePersist, process: cfg.Persist},
+	}
+
+	for _, stage := range cfg.Stages {
+		if stage.Name == "" || stage.Process == nil {
+			return nil, fmt.Errorf("pipeline stage %q needs a name and a processor", stage.Name)
+		}
+		if slices.ContainsFunc(stages, func(s pipelineStage) bool { return s.name == stage.Name }) {
+			return nil, fmt.Errorf("duplicate pipeline stage %q", stage.Name)
+		}
+		at := slices.IndexFunc(stages, func(s pipelineStage) bool { return s.name == stage.After })
+		if at < 0 {
+			return nil, fmt.Errorf("pipeline stage %q runs after unknown stage %q", stage.Name, stage.After)
+		}
+		// Behind the custom stages already added there
+		at++
+		for at < len(stages) && stages[at].custom {
+			at++
+		}
+		stages = slices.Insert(stages, at, pipelineStage{name: stage.Name, process: stage.Process, custom: true})
+	}
+	return &Pipeline{stages: stages}, nil
+}
+
+// Run passes events through the stages in turn, handing the events each stage
+// rejects to deadLetter. It stops at the first error of a stage or deadLetter
+func (p *Pipeline) Run(ctx context.Context, events []*Event, deadLetter func(e *Event) error) error {
+	for _, stage := range p.stages {
+		if len(events) == 0 {
+			break
+		}
+		if stage.process == nil {
+			continue
+		}
+		passed, err := stage.process(ctx, events)
+		if err != nil {
+			return fmt.Errorf("%s stage: %w", stage.name, err)
+		}
+
+		for _, e := range events {
+			if e.rejected != nil {
+				if err := deadLetter(e); err != nil {
+					return err
+				}
+			}
+		}
+		events = make([]*Event, 0, len(passed))
+		for _, e := range passed {
+			if e.rejected == nil {
+				events = append(events, e)
+			}
+		}
+	}
+	return nil
+}
+
+// validateEvents decodes and validates the payload of each event
+func validateEvents(ctx context.Context, events []*Event, decode DecodeFunc, defaultTenantID string, validation Validation) ([]*Event, error) {
+	for _, e := range events {
+		event, err := decode(ctx, e)
+		if err == nil {
+			err = validateEvent(event, defaultTenantID, validation)
+		}
+		var rejected *RejectedError
+		if errors.As(err, &rejected) {
+			e.Reject(rejected)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		slog.DebugContext(e.ctx, "Received event", "message_id", event.EventID, "tenant_id", event.TenantID, "user_id", event.UserID, "status", event.Status)
+		e.Event = event
+	}
+	return events, nil
+}
+
+// normalizeEvents converts each event into the record to store, of the given
+// direction unless the event names its own
+func normalizeEvents(events []*Event, direction string) []*Event {
+	for _, e := range events {
+		record, err := e.Event.ToSMSRecord()
+		if err != nil {
+			// Continue processing even if timestamp parsing fails
+			slog.WarnContext(e.ctx, "Failed to parse timestamp, using current time", "message_id", e.Event.EventID, "error", err)
+		}
+		if record.Direction == "" {
+			record.Direction = direction
+		}
+		e.Record = record
+	}
+	return events
+}
+
+// dedupeEvents drops the events repeating the message ID of an event earlier in
+// the batch, so the first is stored. Messages stored by earlier batches are
+// skipped by the store
+func dedupeEvents(events []*Event) []*Event {
+	seen := make(map[string]bool, len(events))
+	unique := make([]*Event, 0, len(events))
+	for _, e := range events {
+		if seen[e.Record.MessageID] {
+			metrics.DuplicateMessageSkipped()
+			slog.InfoContext(e.ctx, "Skipped duplicate SMS event in batch", "message_id", e.Record.MessageID, "tenant_id", e.Record.TenantID, "user_id", e.Record.UserID)
+			continue
+		}
+		seen[e.Record.MessageID] = true
+		unique = append(unique, e)
+	}
+	return unique
+}
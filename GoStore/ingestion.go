@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/kafka"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/nats"
+	"github.com/ramG-reddy/sms-store/pubsub"
+	"github.com/ramG-reddy/sms-store/rabbitmq"
+	"github.com/ramG-reddy/sms-store/schemaregistry"
+	"github.com/ramG-reddy/sms-store/smpp"
+	"github.com/ramG-reddy/sms-store/sqs"
+)
+
+// ingestor is a running consumer of SMS events, under the name it has in
+// /readyz and on the admin server
+type ingestor struct {
+	ingest.Ingestor
+	name   string
+	maxLag int64 // backlog beyond which it is not ready; zero doesn't check it
+}
+
+// startIngestion starts the consumer of the configured ingest backend, for every
+// configured Kafka topic, NATS subject, RabbitMQ or SQS queue, or Pub/Sub
+// subscription, then the Kafka delivery status consumer and the SMPP receiver
+// if they are configured. Unprocessable Kafka messages go to dlq and quarantine
+// unless nil. If one fails to start, those already started are stopped
+func (a *application) startIngestion(security kafka.Security, dlq *kafka.DeadLetterQueue, quarantine *kafka.Quarantine) ([]ingestor, error) {
+	cfg := a.cfg
+	maxLag := int64(cfg.ReadinessMaxKafkaLag)
+	var started []ingestor
+	fail := func(err error) ([]ingestor, error) {
+		for _, running := range slices.Backward(started) {
+			running.Stop()
+		}
+		return nil, err
+	}
+
+	validation := ingest.Validation{
+		Strict: cfg.IngestStrictValidation,
+		Limits: models.StrictLimits{
+			MaxMessageLength: cfg.IngestMaxMessageLength,
+			MaxClockSkew:     time.Duration(cfg.IngestMaxClockSkewSeconds) * time.Second,
+		},
+	}
+	group := kafka.GroupSettings{
+		Balancer:          cfg.KafkaGroupBalancer,
+		SessionTimeout:    time.Duration(cfg.KafkaSessionTimeoutMs) * time.Millisecond,
+		HeartbeatInterval: time.Duration(cfg.KafkaHeartbeatIntervalMs) * time.Millisecond,
+		RebalanceTimeout:  time.Duration(cfg.KafkaRebalanceTimeoutMs) * time.Millisecond,
+		JoinGroupBackoff:  time.Duration(cfg.KafkaJoinGroupBackoffMs) * time.Millisecond,
+	}
+	consumerConfig := kafka.Config{
+		Brokers:            cfg.KafkaBrokers,
+		Topics:             cfg.KafkaTopics,
+		GroupID:            cfg.KafkaGroupID,
+		Group:              group,
+		Security:           security,
+		DefaultTenantID:    cfg.DefaultTenantID,
+		Validation:         validation,
+		Quarantine:         quarantine,
+		WriteMaxAttempts:   cfg.KafkaWriteMaxAttempts,
+		RetryBase:          time.Duration(cfg.KafkaWriteRetryBaseMs) * time.Millisecond,
+		RetryMax:           time.Duration(cfg.KafkaWriteRetryMaxMs) * time.Millisecond,
+		BatchSize:          cfg.KafkaBatchSize,
+		BatchTimeout:       time.Duration(cfg.KafkaBatchTimeoutMs) * time.Millisecond,
+		Workers:            cfg.KafkaWorkers,
+		QueueSize:          cfg.KafkaWorkerQueueSize,
+		MaxInFlight:        cfg.KafkaMaxInFlight,
+		FetchQueueCapacity: cfg.KafkaFetchQueueCapacity,
+		Format:             cfg.KafkaMessageFormat,
+		LagInterval:        time.Duration(cfg.KafkaLagCheckSeconds) * time.Second,
+		LagAlertThreshold:  int64(cfg.KafkaLagAlertThreshold),
+	}
+	if cfg.SchemaRegistryURL != "" {
+		consumerConfig.SchemaRegistry = schemaregistry.NewClient(cfg.SchemaRegistryURL, cfg.SchemaSubjectStrategy)
+	}
+
+	var consumer ingest.Ingestor
+	var err error
+	switch cfg.IngestBackend {
+	case "nats":
+		consumer, err = nats.StartConsumer(nats.Config{
+			URL:             cfg.NATSURL,
+			CredsFile:       cfg.NATSCredsFile,
+			Stream:          cfg.NATSStream,
+			Subjects:        cfg.NATSSubjects,
+			Durable:         cfg.NATSDurable,
+			DefaultTenantID: cfg.DefaultTenantID,
+			Validation:      validation,
+			BatchSize:       cfg.NATSBatchSize,
+			FetchWait:       time.Duration(cfg.NATSFetchWaitMs) * time.Millisecond,
+			AckWait:         time.Duration(cfg.NATSAckWaitSeconds) * time.Second,
+			MaxDeliver:      cfg.NATSMaxDeliver,
+		}, a.smsService)
+	case "rabbitmq":
+		consumer, err = rabbitmq.StartConsumer(rabbitmq.Config{
+			URL:             cfg.RabbitMQURL,
+			Queues:          cfg.RabbitMQQueues,
+			DefaultTenantID: cfg.DefaultTenantID,
+			Validation:      validation,
+			Prefetch:        cfg.RabbitMQPrefetch,
+			BatchSize:       cfg.RabbitMQBatchSize,
+			BatchWait:       time.Duration(cfg.RabbitMQBatchWaitMs) * time.Millisecond,
+			MaxAttempts:     cfg.RabbitMQMaxAttempts,
+		}, a.smsService)
+	case "sqs":
+		consumer, err = sqs.StartConsumer(sqs.Config{
+			Queues:            cfg.SQSQueues,
+			Endpoint:          cfg.SQSEndpoint,
+			DefaultTenantID:   cfg.DefaultTenantID,
+			Validation:        validation,
+			BatchSize:         cfg.SQSBatchSize,
+			WaitTime:          time.Duration(cfg.SQSWaitTimeSeconds) * time.Second,
+			VisibilityTimeout: time.Duration(cfg.SQSVisibilityTimeoutSeconds) * time.Second,
+		}, a.smsService)
+	case "pubsub":
+		consumer, err = pubsub.StartConsumer(pubsub.Config{
+			ProjectID:              cfg.PubSubProjectID,
+			Subscriptions:          cfg.PubSubSubscriptions,
+			DefaultTenantID:        cfg.DefaultTenantID,
+			Validation:             validation,
+			Streams:                cfg.PubSubStreams,
+			MaxOutstandingMessages: cfg.PubSubMaxOutstandingMessages,
+			MaxOutstandingBytes:    cfg.PubSubMaxOutstandingBytes,
+			BatchSize:              cfg.PubSubBatchSize,
+			BatchWait:              time.Duration(cfg.PubSubBatchWaitMs) * time.Millisecond,
+		}, a.smsService)
+	default:
+		consumer, err = kafka.StartConsumer(consumerConfig, a.smsService, dlq)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s consumer: %w", cfg.IngestBackend, err)
+	}
+	started = append(started, ingestor{Ingestor: consumer, name: cfg.IngestBackend, maxLag: maxLag})
+
+	// A separate delivery status consumer, in its own group, if a status topic is configured
+	if cfg.KafkaStatusTopic != "" {
+		statusConfig := consumerConfig
+		statusConfig.Topics = map[string]string{cfg.KafkaStatusTopic: kafka.HandlerStatus}
+		statusConfig.GroupID = cfg.KafkaStatusGroupID
+		statusConfig.BatchSize = 1
+		statusConsumer, err := kafka.StartConsumer(statusConfig, a.smsService, dlq)
+		if err != nil {
+			return fail(fmt.Errorf("failed to start Kafka status consumer: %w", err))
+		}
+		started = append(started, ingestor{Ingestor: statusConsumer, name: "kafka_status", maxLag: maxLag})
+	}
+
+	// Messages straight from a carrier's SMSC if an SMPP host is configured
+	if cfg.SMPPHost != "" {
+		smppConfig := smpp.Config{
+			Addr:            net.JoinHostPort(cfg.SMPPHost, strconv.Itoa(cfg.SMPPPort)),
+			SystemID:        cfg.SMPPSystemID,
+			Password:        cfg.SMPPPassword,
+			SystemType:      cfg.SMPPSystemType,
+			BindType:        cfg.SMPPBindType,
+			DefaultAlphabet: cfg.SMPPDefaultAlphabet,
+			EnquireLink:     time.Duration(cfg.SMPPEnquireLinkSeconds) * time.Second,
+			TenantID:        cfg.SMPPTenantID,
+			BatchSize:       cfg.SMPPBatchSize,
+			BatchWait:       time.Duration(cfg.SMPPBatchWaitMs) * time.Millisecond,
+		}
+		if cfg.SMPPTLSEnabled {
+			if smppConfig.TLS, err = smpp.NewTLSConfig(cfg.SMPPTLSCAFile); err != nil {
+				return fail(fmt.Errorf("failed to configure SMPP TLS: %w", err))
+			}
+		}
+		smppReceiver, err := smpp.StartReceiver(smppConfig, a.smsService)
+		if err != nil {
+			return fail(fmt.Errorf("failed to start SMPP receiver: %w", err))
+		}
+		// The SMSC holds undelivered messages, so there is no backlog to check
+		started = append(started, ingestor{Ingestor: smppReceiver, name: "smpp"})
+	}
+	return started, nil
+}
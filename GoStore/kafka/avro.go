@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/schemaregistry"
+)
+
+// smsEventSchema is the reader schema for Avro SMS events. Writer schemas are
+// resolved against it, so producers can add fields, or drop the ones with defaults,
+// without breaking the consumer
+const smsEventSchema = `{
+	"type": "record",
+	"name": "SmsEvent",
+	"namespace": "com.sms.events",
+	"fields": [
+		{"name": "eventId", "type": "string"},
+		{"name": "providerMessageId", "type": ["null", "string"], "default": null},
+		{"name": "tenantId", "type": ["null", "string"], "default": null},
+		{"name": "userId", "type": "string"},
+		{"name": "phoneNumber", "type": "string", "default": ""},
+		{"name": "message", "type": "string", "default": ""},
+		{"name": "status", "type": "string"},
+		{"name": "createdAt", "type": "string", "default": ""},
+		{"name": "media", "type": {"type": "array", "items": {
+			"type": "record",
+			"name": "SmsMedia",
+			"fields": [
+				{"name": "contentType", "type": "string"},
+				{"name": "fileName", "type": ["null", "string"], "default": null},
+				{"name": "data", "type": "bytes"}
+			]
+		}}, "default": []},
+		{"name": "carrier", "type": ["null", "string"], "default": null},
+		{"name": "countryCode", "type": ["null", "string"], "default": null},
+		{"name": "senderId", "type": ["null", "string"], "default": null},
+		{"name": "segmentCount", "type": ["null", "int"], "default": null},
+		{"name": "direction", "type": ["null", "string"], "default": null}
+	]
+}`
+
+// avroSMSEvent mirrors smsEventSchema for decoding
+type avroSMSEvent struct {
+	EventID           string         `avro:"eventId"`
+	ProviderMessageID *string        `avro:"providerMessageId"`
+	TenantID          *string        `avro:"tenantId"`
+	UserID            string         `avro:"userId"`
+	PhoneNumber       string         `avro:"phoneNumber"`
+	Message           string         `avro:"message"`
+	Status            string         `avro:"status"`
+	CreatedAt         string         `avro:"createdAt"`
+	Media             []avroSMSMedia `avro:"media"`
+	Carrier           *string        `avro:"carrier"`
+	CountryCode       *string        `avro:"countryCode"`
+	SenderID          *string        `avro:"senderId"`
+	SegmentCount      *int           `avro:"segmentCount"`
+	Direction         *string        `avro:"direction"`
+}
+
+// avroSMSMedia mirrors the SmsMedia record of smsEventSchema
+type avroSMSMedia struct {
+	ContentType string  `avro:"contentType"`
+	FileName    *string `avro:"fileName"`
+	Data        []byte  `avro:"data"`
+}
+
+// avroDecoder decodes schema registry framed SMS events
+type avroDecoder struct {
+	registry *schemaregistry.Client
+	reader   avro.Schema
+
+	mu       sync.Mutex
+	resolved map[avro.Schema]avro.Schema // writer schema to its resolution against reader
+}
+
+// newAvroDecoder creates a decoder that looks writer schemas up in registry
+func newAvroDecoder(registry *schemaregistry.Client) *avroDecoder {
+	return &avroDecoder{
+		registry: registry,
+		reader:   avro.MustParse(smsEventSchema),
+		resolved: make(map[avro.Schema]avro.Schema),
+	}
+}
+
+// resolve returns the schema for decoding data written with writer into the reader schema
+func (d *avroDecoder) resolve(writer avro.Schema) (avro.Schema, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if schema, ok := d.resolved[writer]; ok {
+		return schema, nil
+	}
+	schema, err := avro.NewSchemaCompatibility().Resolve(d.reader, writer)
+	if err != nil {
+		return nil, err
+	}
+	d.resolved[writer] = schema
+	return schema, nil
+}
+
+// decodeAvroEvent decodes an Avro SMS event, retrying registry lookups that fail
+// transiently. Unknown or incompatible schemas make the message unprocessable
+func (c *Consumer) decodeAvroEvent(ctx context.Context, topic string, value []byte) (*models.KafkaEvent, error) {
+	if c.avro == nil {
+		return nil, unprocessable(ReasonMalformed, fmt.Errorf("Avro payload received but no schema registry is configured"))
+	}
+
+	id, payload, err := schemaregistry.Split(value)
+	if err != nil {
+		return nil, unprocessable(ReasonMalformed, err)
+	}
+
+	var writer avro.Schema
+	for attempt := 1; ; attempt++ {
+		lookupCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+		writer, err = c.avro.registry.Schema(lookupCtx, topic, id)
+		cancel()
+		if err == nil {
+			break
+		}
+		if errors.Is(err, schemaregistry.ErrUnknownSchema) || errors.Is(err, schemaregistry.ErrSubjectMismatch) {
+			return nil, unprocessable(ReasonInvalid, err)
+		}
+		if attempt >= c.cfg.WriteMaxAttempts {
+			return nil, unprocessable(ReasonRetriesExhausted, fmt.Errorf("giving up on schema lookup after %d attempts: %w", attempt, err))
+		}
+		if err := c.waitRetry(ctx, attempt, err); err != nil {
+			return nil, err
+		}
+	}
+
+	schema, err := c.avro.resolve(writer)
+	if err != nil {
+		return nil, unprocessable(ReasonInvalid, fmt.Errorf("schema %d is incompatible with the SMS event schema: %w", id, err))
+	}
+
+	var decoded avroSMSEvent
+	if err := avro.Unmarshal(schema, payload, &decoded); err != nil {
+		return nil, unprocessable(ReasonMalformed, fmt.Errorf("failed to decode Avro event: %w", err))
+	}
+
+	event := &models.KafkaEvent{
+		EventID:     decoded.EventID,
+		UserID:      decoded.UserID,
+		PhoneNumber: decoded.PhoneNumber,
+		Message:     decoded.Message,
+		Status:      decoded.Status,
+		CreatedAt:   decoded.CreatedAt,
+	}
+	if decoded.ProviderMessageID != nil {
+		event.ProviderMessageID = *decoded.ProviderMessageID
+	}
+	if decoded.TenantID != nil {
+		event.TenantID = *decoded.TenantID
+	}
+	if decoded.Carrier != nil {
+		event.Carrier = *decoded.Carrier
+	}
+	if decoded.CountryCode != nil {
+		event.CountryCode = *decoded.CountryCode
+	}
+	if decoded.SenderID != nil {
+		event.SenderID = *decoded.SenderID
+	}
+	if decoded.SegmentCount != nil {
+		event.SegmentCount = *decoded.SegmentCount
+	}
+	if decoded.Direction != nil {
+		event.Direction = *decoded.Direction
+	}
+	for _, media := range decoded.Media {
+		attachment := models.KafkaMedia{ContentType: media.ContentType, Data: media.Data}
+		if media.FileName != nil {
+			attachment.FileName = *media.FileName
+		}
+		event.Media = append(event.Media, attachment)
+	}
+	return event, nil
+}
@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+)
+
+// pauseLogInterval spaces out the warnings logged when intake pauses
+const pauseLogInterval = time.Minute
+
+// acquire takes an in-flight slot for the next message to fetch, waiting for a
+// batch to be stored if all MaxInFlight are taken. While it waits the reader's
+// prefetch queue fills up and it stops fetching from the brokers, so memory stays
+// bounded however far storage falls behind
+// It returns false if the consumer is stopped while waiting
+func (c *Consumer) acquire() bool {
+	select {
+	case c.inFlight <- struct{}{}:
+		metrics.SetKafkaInFlight(c.cfg.GroupID, len(c.inFlight))
+		return true
+	default:
+	}
+
+	// While storage lags, intake pauses at every flush, so warn at most once per interval
+	metrics.KafkaIntakePaused(c.cfg.GroupID)
+	if time.Since(c.pauseLoggedAt) >= pauseLogInterval {
+		c.pauseLoggedAt = time.Now()
+		slog.Warn("Kafka intake paused, too many messages awaiting storage", "group_id", c.cfg.GroupID, "max_in_flight", c.cfg.MaxInFlight)
+	}
+	select {
+	case c.inFlight <- struct{}{}:
+		metrics.SetKafkaInFlight(c.cfg.GroupID, len(c.inFlight))
+		return true
+	case <-c.stopChan:
+		return false
+	}
+}
+
+// InFlight returns the number of messages fetched and not yet stored
+func (c *Consumer) InFlight() int {
+	return len(c.inFlight)
+}
+
+// release frees the in-flight slots of n messages that were stored, dead-lettered
+// or abandoned
+func (c *Consumer) release(n int) {
+	for range n {
+		<-c.inFlight
+	}
+	metrics.SetKafkaInFlight(c.cfg.GroupID, len(c.inFlight))
+}
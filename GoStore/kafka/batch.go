@@ -0,0 +1,209 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+	"github.com/ramG-reddy/sms-store/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pendingMessage is a fetched message waiting in a batch, with its consume span
+type pendingMessage struct {
+	ctx     context.Context
+	span    trace.Span
+	message kafka.Message
+}
+
+// flush hands each topic's messages in batch to the topic's handler and marks
+// their offsets done once they are stored, so they are committed once every
+// earlier offset of their partition is done too. Offsets of a group that could
+// not be stored before the consumer stops are left uncommitted, and the group
+// is redelivered after restart
+func (c *Consumer) flush(batch []*pendingMessage) {
+	if len(batch) == 0 {
+		return
+	}
+	defer c.release(len(batch))
+
+	var messages []kafka.Message
+	for _, group := range groupByTopic(batch) {
+		if !c.storeGroup(group) {
+			break
+		}
+		for _, p := range group {
+			messages = append(messages, p.message)
+		}
+	}
+	c.offsets.done(messages...)
+}
+
+// storeGroup processes one topic's messages, retrying the whole group with
+// backoff for as long as it fails, since committing past it would lose them,
+// or once storage is available again if its circuit breaker is open.
+// Records are deduplicated by message ID, so reprocessing is safe
+// It returns false if the consumer is stopped before the group is stored
+func (c *Consumer) storeGroup(group []*pendingMessage) bool {
+	topic := group[0].message.Topic
+	for attempt := 1; ; attempt++ {
+		err := c.handlers[topic].process(group)
+		if err == nil {
+			for _, p := range group {
+				p.span.End()
+			}
+			return true
+		}
+
+		var wait error
+		if errors.Is(err, store.ErrUnavailable) {
+			attempt--
+			if !c.WaitAvailable(context.Background(), err) {
+				wait = fmt.Errorf("consumer stopped while storage was unavailable: %w", err)
+			}
+		} else {
+			metrics.KafkaProcessingFailed(topic)
+			slog.Error("Error processing batch", "topic", topic, "messages", len(group), "attempt", attempt, "error", err)
+			wait = c.waitRetry(context.Background(), attempt, err)
+		}
+		if wait != nil {
+			for _, p := range group {
+				p.span.RecordError(wait)
+				p.span.SetStatus(codes.Error, "processing failed")
+				p.span.End()
+			}
+			return false
+		}
+	}
+}
+
+// processEach runs handle on each message in turn, dead-lettering unprocessable ones
+func (c *Consumer) processEach(batch []*pendingMessage, handle func(ctx context.Context, message kafka.Message) error) error {
+	for _, p := range batch {
+		err := handle(p.ctx, p.message)
+		var bad *ingest.RejectedError
+		if errors.As(err, &bad) {
+			// Retrying can never succeed, so dead-letter the message and commit past it
+			err = c.deadLetter(p.ctx, p.message, bad)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPipeline passes batch through pipeline, dead-lettering the messages it rejects
+func (c *Consumer) runPipeline(pipeline *ingest.Pipeline, batch []*pendingMessage) error {
+	events := make([]*ingest.Event, len(batch))
+	messages := make(map[*ingest.Event]kafka.Message, len(batch))
+	for i, p := range batch {
+		slog.DebugContext(p.ctx, "Processing message", "partition", p.message.Partition, "offset", p.message.Offset)
+		events[i] = ingest.NewEvent(p.ctx, p.message.Topic, p.message.Value)
+		messages[events[i]] = p.message
+	}
+	return pipeline.Run(context.Background(), events, func(e *ingest.Event) error {
+		return c.deadLetter(e.Context(), messages[e], e.Rejection())
+	})
+}
+
+// persistEvents stores the records of events with a single unordered bulk
+// write. Records that fail transiently are retried with backoff; records
+// MongoDB rejects, or that exhaust their attempts, are rejected so they are
+// dead-lettered and the rest of the batch can still be committed
+func (c *Consumer) persistEvents(ctx context.Context, events []*ingest.Event) ([]*ingest.Event, error) {
+	records := make([]*models.SMSRecord, len(events))
+	for i, e := range events {
+		records[i] = e.Record
+	}
+	sources := events
+
+	topic := events[0].Source
+	spanContexts := make([]context.Context, len(sources))
+	for i, e := range sources {
+		spanContexts[i] = e.Context()
+	}
+	ctx, span := tracing.StartBatchSpan(ctx, topic, spanContexts)
+	defer span.End()
+
+	total := len(records)
+	stored := 0
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+		err := c.smsService.SaveMessages(attemptCtx, records)
+		cancel()
+		if err == nil {
+			stored += len(records)
+			break
+		}
+
+		// Work out which records still need storing
+		var retry []int
+		var bulkErr *services.BulkSaveError
+		switch {
+		case errors.As(err, &bulkErr):
+			stored += len(records) - len(bulkErr.Failed)
+			for _, i := range slices.Sorted(maps.Keys(bulkErr.Failed)) {
+				recordErr := bulkErr.Failed[i]
+				if db.IsTransient(recordErr) {
+					retry = append(retry, i)
+					continue
+				}
+				rejected := unprocessable(ReasonRejected, recordErr)
+				if details, ok := db.ValidationFailure(recordErr); ok {
+					rejected = unprocessable(ReasonSchemaViolation, fmt.Errorf("%w: %s", recordErr, details))
+				} else if errors.Is(recordErr, services.ErrQuotaExceeded) {
+					rejected = unprocessable(ReasonQuotaExceeded, recordErr)
+				}
+				sources[i].Reject(rejected)
+			}
+		case db.IsTransient(err):
+			// The whole write failed, and any record may or may not have been stored
+			for i := range records {
+				retry = append(retry, i)
+			}
+		default:
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "bulk write failed")
+			return nil, fmt.Errorf("failed to save batch to database: %w", err)
+		}
+		if len(retry) == 0 {
+			break
+		}
+
+		if attempt >= c.cfg.WriteMaxAttempts {
+			for _, i := range retry {
+				sources[i].Reject(unprocessable(ReasonRetriesExhausted, fmt.Errorf("giving up after %d attempts: %w", attempt, err)))
+			}
+			break
+		}
+
+		records, sources = pick(records, retry), pick(sources, retry)
+		if err := c.waitRetry(ctx, attempt, err); err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Info("Stored batch", "topic", topic, "messages", total, "stored", stored)
+	return events, nil
+}
+
+// pick returns the elements of items at the given indexes
+func pick[T any](items []T, indexes []int) []T {
+	picked := make([]T, len(indexes))
+	for i, index := range indexes {
+		picked[i] = items[index]
+	}
+	return picked
+}
@@ -2,153 +2,343 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
 	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/requestid"
+	"github.com/ramG-reddy/sms-store/schemaregistry"
 	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/tracing"
 	"github.com/segmentio/kafka-go"
 )
 
+// Config identifies the topics and consumer group to read, and how writes are retried
+type Config struct {
+	Brokers  []string
+	Topics   map[string]string // topic to the name of its handler, e.g. HandlerOutbound
+	GroupID  string
+	Security Security
+
+	// Group tunes membership of the consumer group; the zero value keeps kafka-go's defaults
+	Group GroupSettings
+
+	// DefaultTenantID is assigned to events that don't carry a tenantId
+	// The status consumer doesn't use it
+	DefaultTenantID string
+
+	// Validation configures strict validation of events; off by default
+	Validation ingest.Validation
+
+	// Quarantine additionally keeps malformed and invalid messages in MongoDB,
+	// where they can be reprocessed; nil disables it
+	Quarantine *Quarantine
+
+	// WriteMaxAttempts bounds attempts at a MongoDB write that fails transiently
+	// before the message is dead-lettered. Backoff starts at RetryBase, capped at RetryMax
+	WriteMaxAttempts int
+	RetryBase        time.Duration
+	RetryMax         time.Duration
+
+	// Messages are processed in batches of up to BatchSize, flushed early once the
+	// oldest buffered message has waited BatchTimeout
+	BatchSize    int
+	BatchTimeout time.Duration
+
+	// Workers process messages concurrently, each with its own batch. Messages with
+	// the same ordering key (user for SMS events) always go to the same worker,
+	// whose queue holds up to QueueSize messages before fetching blocks
+	Workers   int
+	QueueSize int
+
+	// MaxInFlight bounds the messages fetched but not yet stored, across every
+	// worker's queue and batch; once reached, fetching pauses until batches are
+	// stored. FetchQueueCapacity bounds the messages the reader prefetches from
+	// the brokers ahead of that, so a slow store can't grow memory without limit
+	MaxInFlight        int
+	FetchQueueCapacity int
+
+	// Format of SMS event payloads, FormatJSON or FormatProtobuf
+	// With FormatJSON, SchemaRegistry resolves the writer schemas of Avro events;
+	// without one only JSON payloads are accepted
+	Format         string
+	SchemaRegistry *schemaregistry.Client
+
+	// The group's committed lag on every partition is checked each LagInterval
+	// (zero disables it), logging a warning for partitions above LagAlertThreshold
+	// (zero disables the warning)
+	LagInterval       time.Duration
+	LagAlertThreshold int64
+
+	// Stages are custom processors added to the ingest pipeline of SMS events,
+	// see ingest.Stage. The status consumer doesn't use them
+	Stages []ingest.Stage
+}
+
+const (
+	// fetchTimeout bounds each wait for a message
+	fetchTimeout = 10 * time.Second
+
+	// stopTimeout bounds how long Stop waits for the workers' batches to be stored
+	stopTimeout = 10 * time.Second
+)
+
 // Consumer handles Kafka message consumption
 type Consumer struct {
+	cfg        Config
 	reader     *kafka.Reader
 	smsService *services.SMSService
-	stopChan   chan struct{}
-}
+	dlq        *DeadLetterQueue         // nil skips unprocessable messages instead
+	avro       *avroDecoder             // nil without a schema registry
+	handlers   map[string]*topicHandler // by topic
+	offsets    *offsetTracker
+	inFlight   chan struct{} // a slot per message fetched and not yet stored
+	// pauseLoggedAt is when acquire last warned that intake paused; only the
+	// consume loop touches it
+	pauseLoggedAt time.Time
+	stopChan      chan struct{}
+	done          chan struct{}
 
-// NewConsumer creates a new Kafka consumer instance
-func NewConsumer(brokers []string, topic, groupID string, smsService *services.SMSService) *Consumer {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        brokers,
-		Topic:          topic,
-		GroupID:        groupID,
-		MinBytes:       1,    // 1 byte
-		MaxBytes:       10e6, // 10MB
-		CommitInterval: time.Second,
-		StartOffset:    kafka.LastOffset, // Start from latest for new consumer groups
-		MaxWait:        500 * time.Millisecond,
-		Logger:         kafka.LoggerFunc(log.Printf),
-		ErrorLogger:    kafka.LoggerFunc(log.Printf),
-	})
+	// Pausing stops fetching new messages; those already fetched are still
+	// stored and committed. The reader stays in its consumer group, so the group
+	// keeps its partitions and doesn't rebalance. A fetch in progress completes
+	// first, so at most one more message is consumed after Pause returns
+	*ingest.Gate
+
+	// running and lag are reported by Check for readiness probes, and groupLag
+	// by GroupLag once monitorLag has checked it
+	running  atomic.Bool
+	lagMu    sync.Mutex
+	lag      map[topicPartition]int64
+	groupLag []PartitionLag
+}
 
-	return &Consumer{
+// NewConsumer creates a consumer for cfg.Topics within one consumer group,
+// running the configured handler for each topic
+func NewConsumer(cfg Config, smsService *services.SMSService, dlq *DeadLetterQueue) (*Consumer, error) {
+	reader := newReader(cfg.Brokers, slices.Sorted(maps.Keys(cfg.Topics)), cfg.GroupID, cfg.Group, cfg.Security, cfg.FetchQueueCapacity)
+	consumer := &Consumer{
+		cfg:        cfg,
 		reader:     reader,
 		smsService: smsService,
+		dlq:        dlq,
+		handlers:   make(map[string]*topicHandler, len(cfg.Topics)),
+		offsets:    newOffsetTracker(reader),
+		inFlight:   make(chan struct{}, cfg.MaxInFlight),
 		stopChan:   make(chan struct{}),
+		done:       make(chan struct{}),
+		lag:        make(map[topicPartition]int64),
+	}
+	consumer.Gate = ingest.NewGate("Kafka consumer", consumer.stopChan, cfg.RetryMax, "group_id", cfg.GroupID)
+	if cfg.SchemaRegistry != nil {
+		consumer.avro = newAvroDecoder(cfg.SchemaRegistry)
+	}
+
+	for topic, name := range cfg.Topics {
+		handler, err := consumer.newHandler(name)
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("topic %s: %w", topic, err)
+		}
+		consumer.handlers[topic] = handler
+	}
+	return consumer, nil
+}
+
+// newReader creates a Kafka reader for the given topics and consumer group,
+// prefetching up to queueCapacity messages
+func newReader(brokers []string, topics []string, groupID string, group GroupSettings, security Security, queueCapacity int) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:           brokers,
+		GroupTopics:       topics,
+		GroupID:           groupID,
+		GroupBalancers:    group.balancers(),
+		SessionTimeout:    group.SessionTimeout, // zero values keep kafka-go's defaults
+		HeartbeatInterval: group.HeartbeatInterval,
+		RebalanceTimeout:  group.RebalanceTimeout,
+		JoinGroupBackoff:  group.JoinGroupBackoff,
+		Dialer:            security.dialer(),
+		QueueCapacity:     queueCapacity,
+		MinBytes:          1,                // 1 byte
+		MaxBytes:          10e6,             // 10MB
+		CommitInterval:    0,                // commit synchronously, once the messages are stored
+		StartOffset:       kafka.LastOffset, // Start from latest for new consumer groups
+		MaxWait:           500 * time.Millisecond,
+		Logger:            kafka.LoggerFunc(kafkaLogger(slog.LevelDebug)),
+		ErrorLogger:       kafka.LoggerFunc(kafkaLogger(slog.LevelError)),
+	})
+}
+
+// kafkaLogger adapts kafka-go's printf-style logging to slog at the given level
+func kafkaLogger(level slog.Level) func(string, ...interface{}) {
+	return func(format string, args ...interface{}) {
+		slog.Log(context.Background(), level, fmt.Sprintf(format, args...), "component", "kafka-go")
 	}
 }
 
 // StartConsumer begins consuming messages from Kafka in a background goroutine
-func StartConsumer(brokers []string, topic, groupID string, smsService *services.SMSService) (*Consumer, error) {
-	log.Printf("Starting Kafka consumer for topic: %s, group: %s", topic, groupID)
+func StartConsumer(cfg Config, smsService *services.SMSService, dlq *DeadLetterQueue) (*Consumer, error) {
+	slog.Info("Starting Kafka consumer", "topics", cfg.Topics, "group_id", cfg.GroupID)
 
-	consumer := NewConsumer(brokers, topic, groupID, smsService)
+	consumer, err := NewConsumer(cfg, smsService, dlq)
+	if err != nil {
+		return nil, err
+	}
 
 	// Start consumption in a goroutine
 	go consumer.consume()
+	if cfg.LagInterval > 0 {
+		go consumer.monitorLag()
+	}
 
-	log.Println("Kafka consumer started successfully")
+	slog.Info("Kafka consumer started successfully")
 	return consumer, nil
 }
 
-// consume is the main consumption loop that processes messages
+// consume is the main consumption loop: fetched messages are handed to the worker
+// for their ordering key, which processes them in batches
 func (c *Consumer) consume() {
+	c.running.Store(true)
+	defer close(c.done)
+
+	queues := make([]chan *pendingMessage, c.cfg.Workers)
+	var workers sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan *pendingMessage, c.cfg.QueueSize)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			c.work(queues[i])
+		}()
+	}
 	defer func() {
+		// Let the workers store what they already have before the reader closes
+		for _, queue := range queues {
+			close(queue)
+		}
+		workers.Wait()
+	}()
+
+	defer func() {
+		// A dead loop must fail readiness rather than silently stop consuming
+		c.running.Store(false)
 		if r := recover(); r != nil {
-			log.Printf("Consumer panic recovered: %v", r)
+			slog.Error("Consumer panic recovered", "panic", r)
 		}
 	}()
 
-	log.Println("Starting message consumption loop...")
+	slog.Info("Starting message consumption loop", "workers", c.cfg.Workers, "batch_size", c.cfg.BatchSize, "batch_timeout", c.cfg.BatchTimeout)
 
 	for {
 		select {
 		case <-c.stopChan:
-			log.Println("Consumer stop signal received, exiting...")
+			slog.Info("Consumer stop signal received, exiting")
 			return
 		default:
-			// Read message with timeout
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			message, err := c.reader.FetchMessage(ctx)
-			cancel()
-
-			if err != nil {
-				if err == context.DeadlineExceeded {
-					// Timeout is normal, continue
-					continue
-				}
-				log.Printf("Error fetching message: %v", err)
-				time.Sleep(1 * time.Second)
-				continue
-			}
+		}
+
+		if !c.WaitResumed() {
+			continue
+		}
+
+		// Blocks while MaxInFlight messages await storage
+		if !c.acquire() {
+			slog.Info("Consumer stop signal received, exiting")
+			return
+		}
 
-			// Process the message
-			if err := c.processMessage(message); err != nil {
-				log.Printf("Error processing message: %v", err)
-				// Don't commit on error - message will be reprocessed
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		message, err := c.reader.FetchMessage(ctx)
+		cancel()
+
+		if err != nil {
+			c.release(1)
+			if errors.Is(err, context.DeadlineExceeded) {
+				// Timeout is normal, continue
 				continue
 			}
+			slog.Error("Error fetching message", "error", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
 
-			// Commit the message after successful processing
-			commitCtx, commitCancel := context.WithTimeout(context.Background(), 5*time.Second)
-			if err := c.reader.CommitMessages(commitCtx, message); err != nil {
-				log.Printf("Error committing message: %v", err)
-			}
-			commitCancel()
+		metrics.KafkaMessageConsumed(message.Topic)
+		c.recordLag(message)
+
+		// Each message is processed within a span continuing the producer's trace
+		spanCtx, span := tracing.StartConsumeSpan(messageContext(&message), &message)
+		c.offsets.track(message)
+
+		// Blocks while the worker's queue is full, so fetching slows to the pace of storage
+		select {
+		case queues[workerFor(c.orderKey(message), len(queues))] <- &pendingMessage{ctx: spanCtx, span: span, message: message}:
+		case <-c.stopChan:
+			// Left uncommitted, so it is redelivered after restart
+			span.End()
+			slog.Info("Consumer stop signal received, exiting")
+			return
 		}
 	}
 }
 
-// processMessage deserializes and persists a Kafka message
-func (c *Consumer) processMessage(message kafka.Message) error {
-	log.Printf("Processing message from partition %d, offset %d", message.Partition, message.Offset)
-
-	// Deserialize Kafka event from JSON
-	var event models.KafkaEvent
-	if err := json.Unmarshal(message.Value, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal Kafka event: %w", err)
+// messageContext scopes processing of message to the producer's X-Request-ID
+// header, or to a new request ID when the producer didn't set one
+func messageContext(message *kafka.Message) context.Context {
+	id := ""
+	for _, h := range message.Headers {
+		if h.Key == requestid.Header {
+			id = string(h.Value)
+			break
+		}
 	}
-
-	log.Printf("Received event: EventID=%s, UserID=%s, Status=%s", event.EventID, event.UserID, event.Status)
-
-	// Convert Kafka event to SMS record (handles timestamp conversion)
-	record, err := event.ToSMSRecord()
-	if err != nil {
-		log.Printf("Warning: Failed to parse timestamp, using current time: %v", err)
-		// Continue processing even if timestamp parsing fails
+	if !requestid.IsValid(id) {
+		id = requestid.New()
 	}
+	return requestid.WithID(context.Background(), id)
+}
 
-	// Persist to MongoDB
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := c.smsService.SaveMessage(ctx, record); err != nil {
-		return fmt.Errorf("failed to save message to database: %w", err)
+// decodeEvent deserializes the SMS event of a Kafka message, as the validate
+// stage of the pipeline. In the JSON format, payloads in the schema registry
+// wire format are decoded as Avro
+func (c *Consumer) decodeEvent(ctx context.Context, e *ingest.Event) (*models.KafkaEvent, error) {
+	switch {
+	case c.cfg.Format == FormatProtobuf:
+		return decodeProtoEvent(e.Payload)
+	case schemaregistry.IsWireFormat(e.Payload):
+		return c.decodeAvroEvent(ctx, e.Source, e.Payload)
+	default:
+		return ingest.DecodeJSON(e.Payload)
 	}
-
-	log.Printf("Successfully processed and stored message for user: %s", event.UserID)
-	return nil
 }
 
 // Stop gracefully shuts down the consumer
 func (c *Consumer) Stop() error {
-	log.Println("Stopping Kafka consumer...")
+	slog.Info("Stopping Kafka consumer")
 
 	// Signal the consumer to stop
 	close(c.stopChan)
 
-	// Give it a moment to finish current message
-	time.Sleep(1 * time.Second)
+	// Give it a moment to flush the batch in progress
+	select {
+	case <-c.done:
+	case <-time.After(stopTimeout):
+		slog.Warn("Kafka consumer did not finish its batch before shutdown", "group_id", c.cfg.GroupID)
+	}
 
 	// Close the reader
 	if err := c.reader.Close(); err != nil {
 		return fmt.Errorf("failed to close Kafka reader: %w", err)
 	}
 
-	log.Println("Kafka consumer stopped successfully")
+	slog.Info("Kafka consumer stopped successfully")
 	return nil
 }
 
@@ -0,0 +1,130 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers added to dead-lettered messages, alongside the original message's headers
+const (
+	HeaderDLQTopic     = "dlq.original.topic"
+	HeaderDLQPartition = "dlq.original.partition"
+	HeaderDLQOffset    = "dlq.original.offset"
+	HeaderDLQGroup     = "dlq.consumer.group"
+	HeaderDLQReason    = "dlq.error.reason"
+	HeaderDLQError     = "dlq.error.message"
+	HeaderDLQFailedAt  = "dlq.failed.at"
+
+	// Violations of strict validation as field:rule pairs, e.g. phoneNumber:e164;
+	// only on messages dead-lettered for them
+	HeaderDLQViolations = "dlq.error.violations"
+)
+
+// Reasons a message is unprocessable, used as the DLQ reason header and metric label
+const (
+	ReasonMalformed        = ingest.ReasonMalformed
+	ReasonInvalid          = ingest.ReasonInvalid
+	ReasonRetriesExhausted = ingest.ReasonRetriesExhausted
+	ReasonRejected         = ingest.ReasonRejected
+	ReasonSchemaViolation  = ingest.ReasonSchemaViolation
+	ReasonQuotaExceeded    = ingest.ReasonQuotaExceeded
+)
+
+// unprocessable wraps err so the consumer dead-letters the message instead of retrying it
+func unprocessable(reason string, err error) error {
+	return ingest.Rejected(reason, err)
+}
+
+// DeadLetterQueue publishes unprocessable messages to a topic for later inspection
+type DeadLetterQueue struct {
+	writer *kafka.Writer
+}
+
+// NewDeadLetterQueue creates a producer for the given DLQ topic
+func NewDeadLetterQueue(brokers []string, topic string, security Security) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Transport:              security.transport(),
+			Topic:                  topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+			WriteTimeout:           5 * time.Second,
+			Logger:                 kafka.LoggerFunc(kafkaLogger(slog.LevelDebug)),
+			ErrorLogger:            kafka.LoggerFunc(kafkaLogger(slog.LevelError)),
+		},
+	}
+}
+
+// Publish writes the raw message to the DLQ with the failure described in headers
+// The original key is kept so dead letters for one key stay on one partition
+func (q *DeadLetterQueue) Publish(ctx context.Context, message kafka.Message, groupID, reason string, cause error) error {
+	headers := make([]kafka.Header, 0, len(message.Headers)+8)
+	headers = append(headers, message.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDLQTopic, Value: []byte(message.Topic)},
+		kafka.Header{Key: HeaderDLQPartition, Value: []byte(strconv.Itoa(message.Partition))},
+		kafka.Header{Key: HeaderDLQOffset, Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		kafka.Header{Key: HeaderDLQGroup, Value: []byte(groupID)},
+		kafka.Header{Key: HeaderDLQReason, Value: []byte(reason)},
+		kafka.Header{Key: HeaderDLQError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDLQFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+	var invalid *ingest.ValidationError
+	if errors.As(cause, &invalid) {
+		headers = append(headers, kafka.Header{Key: HeaderDLQViolations, Value: []byte(invalid.Summary())})
+	}
+
+	err := q.writer.WriteMessages(ctx, kafka.Message{
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to dead-letter topic %s: %w", q.writer.Topic, err)
+	}
+	return nil
+}
+
+// Close flushes pending writes and closes the DLQ producer
+func (q *DeadLetterQueue) Close() error {
+	if err := q.writer.Close(); err != nil {
+		return fmt.Errorf("failed to close dead-letter writer: %w", err)
+	}
+	return nil
+}
+
+// deadLetter moves an unprocessable message out of the way so its offset can be committed,
+// quarantining it first if its payload is at fault. Without a DLQ the message is logged and skipped
+func (c *Consumer) deadLetter(ctx context.Context, message kafka.Message, bad *ingest.RejectedError) error {
+	if c.cfg.Quarantine != nil && quarantinedReasons[bad.Reason] {
+		if err := c.cfg.Quarantine.Add(ctx, message, c.cfg.GroupID, bad); err != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "Quarantined unprocessable message", "topic", message.Topic, "partition", message.Partition, "offset", message.Offset, "reason", bad.Reason)
+	}
+
+	if c.dlq == nil {
+		slog.WarnContext(ctx, "Skipping unprocessable message", "topic", message.Topic, "partition", message.Partition, "offset", message.Offset, "reason", bad.Reason, "error", bad.Err)
+		return nil
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := c.dlq.Publish(publishCtx, message, c.cfg.GroupID, bad.Reason, bad.Err); err != nil {
+		return err
+	}
+
+	metrics.KafkaDeadLettered(message.Topic, bad.Reason)
+	slog.WarnContext(ctx, "Dead-lettered unprocessable message", "topic", message.Topic, "partition", message.Partition, "offset", message.Offset, "reason", bad.Reason, "error", bad.Err)
+	return nil
+}
@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Partition assignment strategies accepted by GroupSettings.Balancer
+const (
+	BalancerRange      = "range"
+	BalancerRoundRobin = "round_robin"
+)
+
+// GroupSettings tunes how a consumer takes part in its consumer group. The
+// zero value keeps kafka-go's defaults
+//
+// kafka-go joins groups with the eager protocol of JoinGroup v1: every member
+// gives up its partitions on each rebalance, and it supports neither static
+// membership (group.instance.id) nor cooperative-sticky assignment. Rolling
+// deploys are kept from churning the group by a session timeout longer than a
+// restart, so a stopped member's partitions wait for its replacement, and by
+// handing consumers over on in-place restarts (see the handoff package)
+type GroupSettings struct {
+	// Balancer is the preferred assignment strategy, BalancerRange or BalancerRoundRobin
+	Balancer string
+
+	// SessionTimeout is how long the coordinator waits for a heartbeat before
+	// evicting a member, and HeartbeatInterval how often members send one
+	SessionTimeout    time.Duration
+	HeartbeatInterval time.Duration
+
+	// RebalanceTimeout is how long members get to rejoin the group once a
+	// rebalance starts, the counterpart of Java's max.poll.interval.ms; batches
+	// still being stored by then are committed by the next generation
+	RebalanceTimeout time.Duration
+
+	// JoinGroupBackoff is the wait before rejoining after a failed attempt
+	JoinGroupBackoff time.Duration
+}
+
+// balancers lists the assignment strategies offered when joining, the
+// preferred one first. Both are always offered, so members configured with
+// different strategies during a rolling deploy still agree on one
+func (g GroupSettings) balancers() []kafka.GroupBalancer {
+	if g.Balancer == BalancerRoundRobin {
+		return []kafka.GroupBalancer{kafka.RoundRobinGroupBalancer{}, kafka.RangeGroupBalancer{}}
+	}
+	return []kafka.GroupBalancer{kafka.RangeGroupBalancer{}, kafka.RoundRobinGroupBalancer{}}
+}
@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"fmt"
+
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// Handlers that can be registered for a topic
+const (
+	HandlerOutbound = "outbound" // SMS events sent to users
+	HandlerInbound  = "inbound"  // SMS events received from users
+	HandlerStatus   = "status"   // delivery status updates for stored messages
+)
+
+// topicHandler processes the messages of one topic
+type topicHandler struct {
+	process  func(batch []*pendingMessage) error
+	orderKey func(message kafka.Message) string // messages with equal keys are processed in order
+}
+
+// newHandler returns the handler registered under name
+func (c *Consumer) newHandler(name string) (*topicHandler, error) {
+	userKey := userOrderKey
+	if c.cfg.Format == FormatProtobuf {
+		userKey = protoUserOrderKey
+	}
+
+	switch name {
+	case HandlerOutbound, HandlerInbound:
+		direction := models.DirectionOutbound
+		if name == HandlerInbound {
+			direction = models.DirectionInbound
+		}
+		pipeline, err := ingest.NewPipeline(ingest.PipelineConfig{
+			Decode:          c.decodeEvent,
+			DefaultTenantID: c.cfg.DefaultTenantID,
+			Validation:      c.cfg.Validation,
+			Direction:       direction,
+			Persist:         c.persistEvents,
+			Stages:          c.cfg.Stages,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &topicHandler{
+			process:  func(batch []*pendingMessage) error { return c.runPipeline(pipeline, batch) },
+			orderKey: userKey,
+		}, nil
+	case HandlerStatus:
+		// Status updates are applied one at a time
+		return &topicHandler{
+			process:  func(batch []*pendingMessage) error { return c.processEach(batch, c.processStatusMessage) },
+			orderKey: statusOrderKey,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown handler %q", name)
+	}
+}
+
+// orderKey returns the ordering key of message under its topic's handler
+func (c *Consumer) orderKey(message kafka.Message) string {
+	return c.handlers[message.Topic].orderKey(message)
+}
+
+// groupByTopic splits batch by topic, keeping fetch order within each topic
+func groupByTopic(batch []*pendingMessage) [][]*pendingMessage {
+	var groups [][]*pendingMessage
+	index := make(map[string]int)
+	for _, p := range batch {
+		i, ok := index[p.message.Topic]
+		if !ok {
+			i = len(groups)
+			index[p.message.Topic] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], p)
+	}
+	return groups
+}
@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// recordLag stores the partition lag after message for metrics and readiness
+func (c *Consumer) recordLag(message kafka.Message) {
+	lag := message.HighWaterMark - message.Offset - 1
+	metrics.SetKafkaLag(message.Topic, message.Partition, lag)
+
+	c.lagMu.Lock()
+	c.lag[partitionOf(message)] = lag
+	c.lagMu.Unlock()
+}
+
+// MaxLag returns the highest lag seen across the consumer's partitions
+func (c *Consumer) MaxLag() int64 {
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+
+	var highest int64
+	for _, lag := range c.lag {
+		highest = max(highest, lag)
+	}
+	return highest
+}
+
+// Check reports whether the consumer loop is running, at least one broker is
+// reachable, and no partition lags by more than maxLag (zero disables the lag check)
+func (c *Consumer) Check(ctx context.Context, maxLag int64) error {
+	if !c.running.Load() {
+		return errors.New("consumer loop is not running")
+	}
+
+	var dialErr error
+	for _, broker := range c.cfg.Brokers {
+		conn, err := c.cfg.Security.dialer().DialContext(ctx, "tcp", broker)
+		if err == nil {
+			conn.Close()
+			dialErr = nil
+			break
+		}
+		dialErr = err
+	}
+	if dialErr != nil {
+		return fmt.Errorf("no Kafka broker reachable: %w", dialErr)
+	}
+
+	if lag := c.MaxLag(); maxLag > 0 && lag > maxLag {
+		return fmt.Errorf("consumer lag %d exceeds threshold %d", lag, maxLag)
+	}
+	return nil
+}
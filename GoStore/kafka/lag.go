@@ -0,0 +1,151 @@
+package kafka
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// PartitionLag compares a partition's end offset with the group's committed offset
+type PartitionLag struct {
+	Topic           string    `json:"topic"`
+	Partition       int       `json:"partition"`
+	CommittedOffset int64     `json:"committed_offset"` // -1 before the group's first commit
+	EndOffset       int64     `json:"end_offset"`
+	Lag             int64     `json:"lag"`
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+// monitorLag refreshes the group lag of every partition each LagInterval until the consumer stops
+func (c *Consumer) monitorLag() {
+	client := &kafka.Client{
+		Addr:      kafka.TCP(c.cfg.Brokers...),
+		Timeout:   10 * time.Second,
+		Transport: c.cfg.Security.transport(),
+	}
+
+	ticker := time.NewTicker(c.cfg.LagInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		lags, err := c.queryLag(ctx, client)
+		cancel()
+		if err != nil {
+			slog.Warn("Error checking Kafka consumer group lag", "group_id", c.cfg.GroupID, "error", err)
+			continue
+		}
+
+		for _, lag := range lags {
+			metrics.SetKafkaGroupLag(c.cfg.GroupID, lag.Topic, lag.Partition, lag.Lag)
+			if c.cfg.LagAlertThreshold > 0 && lag.Lag > c.cfg.LagAlertThreshold {
+				slog.Warn("Kafka consumer group lag exceeds threshold", "group_id", c.cfg.GroupID, "topic", lag.Topic, "partition", lag.Partition, "lag", lag.Lag, "threshold", c.cfg.LagAlertThreshold)
+			}
+		}
+
+		c.lagMu.Lock()
+		c.groupLag = lags
+		c.lagMu.Unlock()
+	}
+}
+
+// queryLag asks the brokers for the end offset of every partition of the
+// consumer's topics and the group's committed offset on each
+func (c *Consumer) queryLag(ctx context.Context, client *kafka.Client) ([]PartitionLag, error) {
+	topics := slices.Sorted(maps.Keys(c.cfg.Topics))
+	partitions, err := partitionsOf(ctx, client, topics)
+	if err != nil {
+		return nil, err
+	}
+
+	offsetRequests := make(map[string][]kafka.OffsetRequest)
+	for topic, ids := range partitions {
+		for _, id := range ids {
+			offsetRequests[topic] = append(offsetRequests[topic], kafka.FirstOffsetOf(id), kafka.LastOffsetOf(id))
+		}
+	}
+
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: offsetRequests})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partition offsets: %w", err)
+	}
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: c.cfg.GroupID, Topics: partitions})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch committed offsets: %w", err)
+	}
+	if committed.Error != nil {
+		return nil, fmt.Errorf("failed to fetch committed offsets: %w", committed.Error)
+	}
+
+	commits := make(map[topicPartition]int64)
+	for topic, ps := range committed.Topics {
+		for _, p := range ps {
+			if p.Error == nil {
+				commits[topicPartition{topic: topic, partition: p.Partition}] = p.CommittedOffset
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	var lags []PartitionLag
+	for _, topic := range topics {
+		for _, p := range offsets.Topics[topic] {
+			if p.Error != nil {
+				return nil, fmt.Errorf("failed to list offsets of %s/%d: %w", topic, p.Partition, p.Error)
+			}
+			lag := PartitionLag{Topic: topic, Partition: p.Partition, CommittedOffset: -1, EndOffset: p.LastOffset, CheckedAt: now}
+			if offset, ok := commits[topicPartition{topic: topic, partition: p.Partition}]; ok && offset >= 0 {
+				lag.CommittedOffset = offset
+				lag.Lag = max(p.LastOffset-offset, 0)
+			} else {
+				// Without a commit, everything still retained counts as lag
+				lag.Lag = p.LastOffset - p.FirstOffset
+			}
+			lags = append(lags, lag)
+		}
+	}
+	slices.SortFunc(lags, func(a, b PartitionLag) int {
+		return cmp.Or(strings.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+	return lags, nil
+}
+
+// partitionsOf returns the partition IDs of each of topics
+func partitionsOf(ctx context.Context, client *kafka.Client, topics []string) (map[string][]int, error) {
+	metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch topic metadata: %w", err)
+	}
+
+	partitions := make(map[string][]int)
+	for _, topic := range metadata.Topics {
+		if topic.Error != nil {
+			return nil, fmt.Errorf("failed to fetch metadata of topic %s: %w", topic.Name, topic.Error)
+		}
+		for _, p := range topic.Partitions {
+			partitions[topic.Name] = append(partitions[topic.Name], p.ID)
+		}
+	}
+	return partitions, nil
+}
+
+// GroupLag returns the lag of each partition from the last check, empty before
+// the first check completes or when lag monitoring is disabled
+func (c *Consumer) GroupLag() []PartitionLag {
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+	return slices.Clone(c.groupLag)
+}
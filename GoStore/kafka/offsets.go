@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// offsetTracker commits offsets in order even though workers finish out of order
+// An offset is committed only once it and every earlier fetched offset of its
+// partition are done, so a crash never skips a message still being processed
+type offsetTracker struct {
+	reader *kafka.Reader
+
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+}
+
+// topicPartition identifies a partition across the consumer's topics
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+func partitionOf(message kafka.Message) topicPartition {
+	return topicPartition{topic: message.Topic, partition: message.Partition}
+}
+
+// partitionOffsets holds the fetched, not yet committed messages of one partition
+type partitionOffsets struct {
+	pending []kafka.Message // in fetch order
+	done    map[int64]bool
+}
+
+func newOffsetTracker(reader *kafka.Reader) *offsetTracker {
+	return &offsetTracker{
+		reader:     reader,
+		partitions: make(map[topicPartition]*partitionOffsets),
+	}
+}
+
+// track records a fetched message; it must be called in fetch order
+func (t *offsetTracker) track(message kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partitions[partitionOf(message)]
+	// An offset at or before the last one tracked means the partition was
+	// reassigned and is being read again from its committed offset
+	if p == nil || (len(p.pending) > 0 && message.Offset <= p.pending[len(p.pending)-1].Offset) {
+		p = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[partitionOf(message)] = p
+	}
+	p.pending = append(p.pending, message)
+}
+
+// done marks messages processed and commits each partition's newly completed prefix
+func (t *offsetTracker) done(messages ...kafka.Message) {
+	var commits []kafka.Message
+
+	t.mu.Lock()
+	for _, message := range messages {
+		if p := t.partitions[partitionOf(message)]; p != nil {
+			p.done[message.Offset] = true
+		}
+	}
+	for _, p := range t.partitions {
+		n := 0
+		for n < len(p.pending) && p.done[p.pending[n].Offset] {
+			delete(p.done, p.pending[n].Offset)
+			n++
+		}
+		if n > 0 {
+			commits = append(commits, p.pending[n-1])
+			p.pending = p.pending[n:]
+		}
+	}
+	t.mu.Unlock()
+
+	if len(commits) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := t.reader.CommitMessages(ctx, commits...); err != nil {
+		slog.Error("Error committing offsets", "partitions", len(commits), "error", err)
+	}
+}
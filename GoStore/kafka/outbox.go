@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// outboxBatchSize bounds the pending records published per round trip
+const outboxBatchSize = 100
+
+// StoredEvent is published to the stored-events topic for every newly stored message,
+// keyed by user ID so each user's events stay in order
+type StoredEvent struct {
+	MessageID string    `json:"message_id"`
+	TenantID  string    `json:"tenant_id"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxRelay publishes the stored event of every record the SMS service marked
+// pending, then clears the mark. A record stays pending until the topic has
+// acknowledged its event, so events survive produce failures and restarts and
+// are published at least once
+type OutboxRelay struct {
+	writer     *kafka.Writer
+	smsService *services.SMSService
+	interval   time.Duration
+	stopChan   chan struct{}
+	done       chan struct{}
+}
+
+// NewOutboxRelay creates a relay polling for pending records every interval and
+// publishing them to topic
+func NewOutboxRelay(brokers []string, topic string, security Security, interval time.Duration, smsService *services.SMSService) *OutboxRelay {
+	return &OutboxRelay{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Transport:              security.transport(),
+			Topic:                  topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+			WriteTimeout:           5 * time.Second,
+			Logger:                 kafka.LoggerFunc(kafkaLogger(slog.LevelDebug)),
+			ErrorLogger:            kafka.LoggerFunc(kafkaLogger(slog.LevelError)),
+		},
+		smsService: smsService,
+		interval:   interval,
+		stopChan:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start publishes pending records on the configured interval in a background goroutine
+func (r *OutboxRelay) Start() {
+	slog.Info("Starting stored event relay", "topic", r.writer.Topic, "interval", r.interval)
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stopChan:
+				return
+			case <-ticker.C:
+				if err := r.publishPending(context.Background()); err != nil {
+					slog.Error("Error publishing stored events", "topic", r.writer.Topic, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop waits for an in-progress round to finish and closes the producer
+// Records it has not published yet are published after restart
+func (r *OutboxRelay) Stop() {
+	slog.Info("Stopping stored event relay")
+	close(r.stopChan)
+	<-r.done
+	if err := r.writer.Close(); err != nil {
+		slog.Error("Error closing stored event writer", "error", err)
+	}
+}
+
+// publishPending publishes batches of pending records until none remain
+func (r *OutboxRelay) publishPending(ctx context.Context) error {
+	for {
+		select {
+		case <-r.stopChan:
+			return nil
+		default:
+		}
+
+		records, err := r.smsService.PendingStoredEvents(ctx, outboxBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		messages := make([]kafka.Message, len(records))
+		ids := make([]primitive.ObjectID, len(records))
+		for i, record := range records {
+			value, err := json.Marshal(StoredEvent{
+				MessageID: record.MessageID,
+				TenantID:  record.TenantID,
+				UserID:    record.UserID,
+				CreatedAt: record.CreatedAt,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to encode stored event: %w", err)
+			}
+			messages[i] = kafka.Message{Key: []byte(record.UserID), Value: value}
+			ids[i] = record.ID
+		}
+
+		writeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = r.writer.WriteMessages(writeCtx, messages...)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to publish to stored events topic %s: %w", r.writer.Topic, err)
+		}
+
+		// A crash before this point republishes the batch, so consumers see duplicates at worst
+		if err := r.smsService.ClearStoredEventsPending(ctx, ids); err != nil {
+			return err
+		}
+		slog.Debug("Published stored events", "topic", r.writer.Topic, "count", len(records))
+
+		if len(records) < outboxBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// EventProducer publishes JSON SMS events to a topic the way the Java sender
+// does, keyed by phone number. The service only consumes these topics; it is
+// used to feed them synthetic load
+type EventProducer struct {
+	writer *kafka.Writer
+}
+
+// NewEventProducer creates a producer for the given SMS events topic
+func NewEventProducer(brokers []string, topic string, security Security) *EventProducer {
+	return &EventProducer{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Transport:              security.transport(),
+			Topic:                  topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+			BatchSize:              1000,
+			BatchTimeout:           10 * time.Millisecond,
+			WriteTimeout:           10 * time.Second,
+			Logger:                 kafka.LoggerFunc(kafkaLogger(slog.LevelDebug)),
+			ErrorLogger:            kafka.LoggerFunc(kafkaLogger(slog.LevelError)),
+		},
+	}
+}
+
+// Publish writes the events, returning once the topic has acknowledged all of them
+func (p *EventProducer) Publish(ctx context.Context, events []*models.KafkaEvent) error {
+	messages := make([]kafka.Message, len(events))
+	for i, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode event %s: %w", event.EventID, err)
+		}
+		messages[i] = kafka.Message{Key: []byte(event.PhoneNumber), Value: value}
+	}
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+// Close flushes pending writes and closes the producer
+func (p *EventProducer) Close() error {
+	if err := p.writer.Close(); err != nil {
+		return fmt.Errorf("failed to close event writer: %w", err)
+	}
+	return nil
+}
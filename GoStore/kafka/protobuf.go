@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	smsstorev1 "github.com/ramG-reddy/sms-store/proto/smsstore/v1"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+)
+
+// Payload formats of the SMS events topic
+const (
+	FormatJSON     = "json"     // JSON, or Avro when framed for the schema registry
+	FormatProtobuf = "protobuf" // smsstore.v1.SMSEvent
+)
+
+// protoUserOrderKey keeps each user's Protobuf SMS events in order
+func protoUserOrderKey(message kafka.Message) string {
+	var event smsstorev1.SMSEvent
+	if err := proto.Unmarshal(message.Value, &event); err == nil && event.GetUserId() != "" {
+		return event.GetUserId()
+	}
+	return strconv.Itoa(message.Partition)
+}
+
+// decodeProtoEvent decodes a Protobuf SMS event
+func decodeProtoEvent(value []byte) (*models.KafkaEvent, error) {
+	var decoded smsstorev1.SMSEvent
+	if err := proto.Unmarshal(value, &decoded); err != nil {
+		return nil, unprocessable(ReasonMalformed, fmt.Errorf("failed to unmarshal Protobuf event: %w", err))
+	}
+
+	event := &models.KafkaEvent{
+		EventID:           decoded.GetEventId(),
+		ProviderMessageID: decoded.GetProviderMessageId(),
+		TenantID:          decoded.GetTenantId(),
+		UserID:            decoded.GetUserId(),
+		PhoneNumber:       decoded.GetPhoneNumber(),
+		Message:           decoded.GetMessage(),
+		Status:            decoded.GetStatus(),
+	}
+	// Left empty when unset, so ToSMSRecord falls back to the current time as for JSON
+	if decoded.GetCreatedAt() != nil {
+		event.CreatedAt = decoded.GetCreatedAt().AsTime().Format(time.RFC3339Nano)
+	}
+	return event, nil
+}
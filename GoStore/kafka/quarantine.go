@@ -0,0 +1,223 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNotQuarantined is returned when no quarantined message has the given ID
+var ErrNotQuarantined = errors.New("quarantined message not found")
+
+// quarantinedReasons are the reasons of the dead-lettered messages that are
+// quarantined too: those whose payload is at fault rather than storage
+var quarantinedReasons = map[string]bool{
+	ReasonMalformed:       true,
+	ReasonInvalid:         true,
+	ReasonSchemaViolation: true,
+}
+
+// QuarantineFilter narrows a listing of quarantined messages; empty fields match all
+type QuarantineFilter struct {
+	Topic  string
+	Reason string
+}
+
+// Quarantine keeps the messages that could not be decoded or failed validation
+// in MongoDB, next to the dead-letter topic, where operators can list them and
+// reprocess them by republishing them to the topic they came from
+type Quarantine struct {
+	db     *db.Mongo
+	writer *kafka.Writer
+}
+
+// NewQuarantine creates a quarantine in m republishing reprocessed messages to brokers
+func NewQuarantine(m *db.Mongo, brokers []string, security Security) *Quarantine {
+	return &Quarantine{
+		db: m,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Transport:    security.transport(),
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			WriteTimeout: 5 * time.Second,
+			Logger:       kafka.LoggerFunc(kafkaLogger(slog.LevelDebug)),
+			ErrorLogger:  kafka.LoggerFunc(kafkaLogger(slog.LevelError)),
+		},
+	}
+}
+
+// Add stores a message dead-lettered by the consumer group groupID. A message
+// already quarantined, e.g. by an earlier attempt at its batch, is left as it is
+func (q *Quarantine) Add(ctx context.Context, message kafka.Message, groupID string, bad *ingest.RejectedError) error {
+	headers := make([]models.MessageHeader, len(message.Headers))
+	for i, h := range message.Headers {
+		headers[i] = models.MessageHeader{Key: h.Key, Value: h.Value}
+	}
+	quarantined := models.QuarantinedMessage{
+		Topic:         message.Topic,
+		Partition:     message.Partition,
+		Offset:        message.Offset,
+		GroupID:       groupID,
+		Key:           message.Key,
+		Payload:       message.Value,
+		Headers:       headers,
+		Reason:        bad.Reason,
+		Error:         bad.Err.Error(),
+		QuarantinedAt: time.Now().UTC(),
+	}
+
+	writeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"group_id": groupID, "topic": message.Topic, "partition": message.Partition, "offset": message.Offset}
+	_, err := q.db.GetQuarantineCollection().UpdateOne(writeCtx, filter, bson.M{"$setOnInsert": quarantined}, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to quarantine message: %w", err)
+	}
+	return nil
+}
+
+// List returns the quarantined messages matching filter, newest first
+func (q *Quarantine) List(ctx context.Context, filter QuarantineFilter, skip, limit int64) ([]*models.QuarantinedMessage, error) {
+	query := bson.M{}
+	if filter.Topic != "" {
+		query["topic"] = filter.Topic
+	}
+	if filter.Reason != "" {
+		query["reason"] = filter.Reason
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "quarantined_at", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := q.db.GetQuarantineCollection().Find(queryCtx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quarantine: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	messages := []*models.QuarantinedMessage{}
+	if err := cursor.All(queryCtx, &messages); err != nil {
+		return nil, fmt.Errorf("failed to decode quarantined messages: %w", err)
+	}
+	return messages, nil
+}
+
+// Get returns the quarantined message with the given ID
+func (q *Quarantine) Get(ctx context.Context, id string) (*models.QuarantinedMessage, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotQuarantined
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var message models.QuarantinedMessage
+	err = q.db.GetQuarantineCollection().FindOne(queryCtx, bson.M{"_id": objectID}).Decode(&message)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotQuarantined
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quarantined message: %w", err)
+	}
+	return &message, nil
+}
+
+// Reprocess republishes a quarantined message to the topic it came from, with
+// its key and headers, and removes it from quarantine. The consumer then
+// processes it as any other message, so one that still fails is quarantined again
+func (q *Quarantine) Reprocess(ctx context.Context, id string) (*models.QuarantinedMessage, error) {
+	message, err := q.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make([]kafka.Header, len(message.Headers))
+	for i, h := range message.Headers {
+		headers[i] = kafka.Header{Key: h.Key, Value: h.Value}
+	}
+	publishCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err = q.writer.WriteMessages(publishCtx, kafka.Message{
+		Topic:   message.Topic,
+		Key:     message.Key,
+		Value:   message.Payload,
+		Headers: headers,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to republish quarantined message to %s: %w", message.Topic, err)
+	}
+
+	if err := q.Delete(ctx, id); err != nil && !errors.Is(err, ErrNotQuarantined) {
+		// Republished but still listed; reprocessing it again would publish it twice
+		return nil, fmt.Errorf("republished quarantined message but failed to remove it: %w", err)
+	}
+	slog.InfoContext(ctx, "Reprocessing quarantined message", "id", id, "topic", message.Topic, "partition", message.Partition, "offset", message.Offset)
+	return message, nil
+}
+
+// Delete removes the quarantined message with the given ID
+func (q *Quarantine) Delete(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotQuarantined
+	}
+
+	deleteCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := q.db.GetQuarantineCollection().DeleteOne(deleteCtx, bson.M{"_id": objectID})
+	if err != nil {
+		return fmt.Errorf("failed to delete quarantined message: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotQuarantined
+	}
+	return nil
+}
+
+// Purge removes the quarantined messages matching filter that were quarantined
+// before the given time, or at any time when it is zero, and returns how many
+func (q *Quarantine) Purge(ctx context.Context, filter QuarantineFilter, before time.Time) (int64, error) {
+	query := bson.M{}
+	if filter.Topic != "" {
+		query["topic"] = filter.Topic
+	}
+	if filter.Reason != "" {
+		query["reason"] = filter.Reason
+	}
+	if !before.IsZero() {
+		query["quarantined_at"] = bson.M{"$lt": before}
+	}
+
+	deleteCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := q.db.GetQuarantineCollection().DeleteMany(deleteCtx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge quarantine: %w", err)
+	}
+	return result.DeletedCount, nil
+}
+
+// Close flushes pending writes and closes the quarantine's producer
+func (q *Quarantine) Close() error {
+	if err := q.writer.Close(); err != nil {
+		return fmt.Errorf("failed to close quarantine writer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers of requeued messages, so consumers of the requeue topic can tell a
+// re-delivery from a first send
+const (
+	HeaderRequeueAttempt    = "requeue.attempt"
+	HeaderRequeueRecordID   = "requeue.record.id"
+	HeaderRequeueRequeuedAt = "requeue.requeued.at"
+)
+
+// Requeuer republishes stored messages as SMS events to a topic the service
+// does not consume, e.g. for re-delivery by the sender service
+type Requeuer struct {
+	writer *kafka.Writer
+}
+
+// NewRequeuer creates a producer for the given requeue topic
+func NewRequeuer(brokers []string, topic string, security Security) *Requeuer {
+	return &Requeuer{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Transport:              security.transport(),
+			Topic:                  topic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+			WriteTimeout:           5 * time.Second,
+			Logger:                 kafka.LoggerFunc(kafkaLogger(slog.LevelDebug)),
+			ErrorLogger:            kafka.LoggerFunc(kafkaLogger(slog.LevelError)),
+		},
+	}
+}
+
+// Topic returns the topic messages are requeued to
+func (r *Requeuer) Topic() string {
+	return r.writer.Topic
+}
+
+// Requeue publishes the event of the stored record with the given document ID
+// in the JSON format of the Java sender, keyed by phone number, with attempt
+// counting this requeue of it from one
+func (r *Requeuer) Requeue(ctx context.Context, recordID string, event *models.KafkaEvent, attempt int) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.EventID, err)
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err = r.writer.WriteMessages(publishCtx, kafka.Message{
+		Key:   []byte(event.PhoneNumber),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: HeaderRequeueAttempt, Value: []byte(strconv.Itoa(attempt))},
+			{Key: HeaderRequeueRecordID, Value: []byte(recordID)},
+			{Key: HeaderRequeueRequeuedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to requeue message to %s: %w", r.writer.Topic, err)
+	}
+	return nil
+}
+
+// Close flushes pending writes and closes the producer
+func (r *Requeuer) Close() error {
+	if err := r.writer.Close(); err != nil {
+		return fmt.Errorf("failed to close requeue writer: %w", err)
+	}
+	return nil
+}
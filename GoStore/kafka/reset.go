@@ -0,0 +1,110 @@
+package kafka
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// OffsetReset is the offset a consumer group is moved to on one partition
+type OffsetReset struct {
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+// ResetOffsets moves groupID on every partition of topics to the earliest
+// retained offset, or with a non-zero at to the first message produced at or
+// after it (the end of the partition if there is none). Unless dryRun is set the
+// offsets are committed, which Kafka only accepts while the group has no active
+// members, so every instance consuming in the group must be stopped first
+// Replayed messages already stored are skipped by the unique message_id index
+func ResetOffsets(ctx context.Context, brokers []string, security Security, groupID string, topics []string, at time.Time, dryRun bool) ([]OffsetReset, error) {
+	client := &kafka.Client{
+		Addr:      kafka.TCP(brokers...),
+		Timeout:   10 * time.Second,
+		Transport: security.transport(),
+	}
+
+	groups, err := client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{groupID}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe consumer group %s: %w", groupID, err)
+	}
+	for _, group := range groups.Groups {
+		if group.Error != nil {
+			return nil, fmt.Errorf("failed to describe consumer group %s: %w", groupID, group.Error)
+		}
+		if len(group.Members) > 0 {
+			return nil, fmt.Errorf("consumer group %s has %d active members; stop every consuming instance first", groupID, len(group.Members))
+		}
+	}
+
+	partitions, err := partitionsOf(ctx, client, topics)
+	if err != nil {
+		return nil, err
+	}
+
+	offsetRequests := make(map[string][]kafka.OffsetRequest)
+	for topic, ids := range partitions {
+		for _, id := range ids {
+			if at.IsZero() {
+				offsetRequests[topic] = append(offsetRequests[topic], kafka.FirstOffsetOf(id))
+			} else {
+				offsetRequests[topic] = append(offsetRequests[topic], kafka.TimeOffsetOf(id, at), kafka.LastOffsetOf(id))
+			}
+		}
+	}
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: offsetRequests})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partition offsets: %w", err)
+	}
+
+	var resets []OffsetReset
+	for topic, ps := range offsets.Topics {
+		for _, p := range ps {
+			if p.Error != nil {
+				return nil, fmt.Errorf("failed to list offsets of %s/%d: %w", topic, p.Partition, p.Error)
+			}
+			reset := OffsetReset{Topic: topic, Partition: p.Partition, Offset: p.FirstOffset}
+			if !at.IsZero() {
+				// The broker answers -1 when no message is as new as at
+				reset.Offset = p.LastOffset
+				for offset := range p.Offsets {
+					if offset >= 0 {
+						reset.Offset = offset
+					}
+				}
+			}
+			resets = append(resets, reset)
+		}
+	}
+	slices.SortFunc(resets, func(a, b OffsetReset) int {
+		return cmp.Or(strings.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+	})
+	if dryRun {
+		return resets, nil
+	}
+
+	commits := make(map[string][]kafka.OffsetCommit)
+	for _, reset := range resets {
+		commits[reset.Topic] = append(commits[reset.Topic], kafka.OffsetCommit{Partition: reset.Partition, Offset: reset.Offset})
+	}
+	// Generation -1 with no member ID commits on behalf of an empty group
+	committed, err := client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{GroupID: groupID, GenerationID: -1, Topics: commits})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	for topic, ps := range committed.Topics {
+		for _, p := range ps {
+			if p.Error != nil {
+				return nil, fmt.Errorf("failed to commit offset of %s/%d: %w", topic, p.Partition, p.Error)
+			}
+		}
+	}
+	return resets, nil
+}
@@ -0,0 +1,77 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+// writeTimeout bounds a single MongoDB write attempt
+const writeTimeout = 5 * time.Second
+
+// writeWithRetry runs write, retrying transient MongoDB failures with jittered
+// exponential backoff. Once WriteMaxAttempts is reached the message is marked
+// unprocessable so it is dead-lettered rather than lost. While storage is
+// unavailable it waits without using up attempts
+func (c *Consumer) writeWithRetry(ctx context.Context, write func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+		err := write(attemptCtx)
+		cancel()
+
+		if errors.Is(err, store.ErrUnavailable) {
+			if !c.WaitAvailable(ctx, err) {
+				// Leave the offset uncommitted so the message is redelivered after restart
+				return fmt.Errorf("consumer stopped while storage was unavailable: %w", err)
+			}
+			attempt--
+			continue
+		}
+		if err == nil || !db.IsTransient(err) {
+			return err
+		}
+		if attempt >= c.cfg.WriteMaxAttempts {
+			return unprocessable(ReasonRetriesExhausted, fmt.Errorf("giving up after %d attempts: %w", attempt, err))
+		}
+
+		if err := c.waitRetry(ctx, attempt, err); err != nil {
+			return err
+		}
+	}
+}
+
+// waitRetry sleeps for the backoff after a transient failure of attempt, whether
+// of a MongoDB write or a schema registry lookup
+// It returns an error if the consumer is stopped while waiting
+func (c *Consumer) waitRetry(ctx context.Context, attempt int, cause error) error {
+	delay := backoff(c.cfg.RetryBase, c.cfg.RetryMax, attempt)
+	slog.WarnContext(ctx, "Transient failure, retrying", "attempt", attempt, "delay", delay, "error", cause)
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-c.stopChan:
+		// Leave the offset uncommitted so the message is redelivered after restart
+		return fmt.Errorf("consumer stopped while retrying: %w", cause)
+	}
+}
+
+// backoff returns base * 2^(attempt-1), capped at limit, plus up to 50% random jitter
+func backoff(base, limit time.Duration, attempt int) time.Duration {
+	delay := base << (attempt - 1)
+	if delay <= 0 || delay > limit {
+		delay = limit
+	}
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(delay/2)+1))
+	if err != nil {
+		return delay
+	}
+	return delay + time.Duration(jitter.Int64())
+}
@@ -0,0 +1,52 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/segmentio/kafka-go"
+)
+
+// processStatusMessage deserializes a status update and applies it to the stored record
+func (c *Consumer) processStatusMessage(ctx context.Context, message kafka.Message) error {
+	slog.DebugContext(ctx, "Processing status update", "partition", message.Partition, "offset", message.Offset)
+
+	var event models.StatusUpdateEvent
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		return unprocessable(ReasonMalformed, fmt.Errorf("failed to unmarshal status update: %w", err))
+	}
+
+	if err := event.Validate(); err != nil {
+		return unprocessable(ReasonInvalid, err)
+	}
+
+	change := models.StatusChange{
+		Status:    event.Status,
+		Reason:    event.Reason,
+		ChangedAt: event.ChangedAt(),
+	}
+
+	err := c.writeWithRetry(ctx, func(ctx context.Context) error {
+		return c.smsService.UpdateMessageStatus(ctx, event.MessageID, change)
+	})
+	if errors.Is(err, services.ErrMessageNotFound) {
+		slog.WarnContext(ctx, "No stored message for status update, skipping", "message_id", event.MessageID)
+		return nil
+	}
+	if details, ok := db.ValidationFailure(err); ok {
+		// Retrying would be rejected again, so dead-letter the update instead
+		return unprocessable(ReasonSchemaViolation, fmt.Errorf("%w: %s", err, details))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply status update: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Applied status update", "message_id", event.MessageID, "status", event.Status)
+	return nil
+}
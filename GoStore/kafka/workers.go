@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// work batches messages from queue and processes them until queue is closed,
+// then flushes what is left. Batches are processed one at a time, so messages
+// routed to the same worker are stored in the order they were fetched
+func (c *Consumer) work(queue <-chan *pendingMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			// The worker's queue fills up and stalls fetching, so fail readiness too
+			c.running.Store(false)
+			slog.Error("Consumer worker panic recovered", "panic", r)
+		}
+	}()
+
+	var batch []*pendingMessage
+	timer := time.NewTimer(c.cfg.BatchTimeout)
+	timer.Stop()
+
+	for {
+		select {
+		case p, ok := <-queue:
+			if !ok {
+				c.flush(batch)
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(c.cfg.BatchTimeout)
+			}
+			batch = append(batch, p)
+			if len(batch) >= c.cfg.BatchSize {
+				timer.Stop()
+				c.flush(batch)
+				batch = nil
+			}
+		case <-timer.C:
+			c.flush(batch)
+			batch = nil
+		}
+	}
+}
+
+// workerFor maps an ordering key to one of n workers
+func workerFor(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// userOrderKey keeps each user's SMS events in order
+// Messages that don't parse are only dead-lettered, so any worker will do
+func userOrderKey(message kafka.Message) string {
+	var event struct {
+		UserID string `json:"userId"`
+	}
+	if err := json.Unmarshal(message.Value, &event); err == nil && event.UserID != "" {
+		return event.UserID
+	}
+	return strconv.Itoa(message.Partition)
+}
+
+// statusOrderKey keeps the status updates of each message in order
+func statusOrderKey(message kafka.Message) string {
+	var event struct {
+		MessageID string `json:"messageId"`
+	}
+	if err := json.Unmarshal(message.Value, &event); err == nil && event.MessageID != "" {
+		return event.MessageID
+	}
+	return strconv.Itoa(message.Partition)
+}
@@ -0,0 +1,75 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+)
+
+// Election runs a component on one instance at a time. Every instance
+// campaigns for the named lease, and the one holding it leads until it stops
+// or loses the lease, after which another instance takes over. An instance
+// that dies is replaced once its lease expires
+type Election struct {
+	leases *Leases
+	name   string
+	lead   func(ctx context.Context)
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewElection creates an election for the named lease. lead is called on the
+// elected instance and must run the component until ctx is done
+func (l *Leases) NewElection(name string, lead func(ctx context.Context)) *Election {
+	return &Election{
+		leases: l,
+		name:   name,
+		lead:   lead,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start campaigns for the lease in a background goroutine
+func (e *Election) Start() {
+	slog.Info("Campaigning for leadership", "lease", e.name)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	go func() {
+		defer close(e.done)
+		for {
+			err := e.leases.Run(ctx, e.name, 0, func(ctx context.Context) error {
+				slog.Info("Elected leader", "lease", e.name)
+				metrics.SetLeader(e.name, true)
+				e.lead(ctx)
+				metrics.SetLeader(e.name, false)
+				return nil
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			switch {
+			case errors.Is(err, ErrLost):
+				slog.Error("Lost leadership", "lease", e.name)
+			case err != nil && !errors.Is(err, ErrHeld):
+				slog.Warn("Failed to campaign for leadership", "lease", e.name, "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(renewInterval):
+			}
+		}
+	}()
+}
+
+// Stop stops leading, waiting for the component to stop, and frees the lease
+// for another instance
+func (e *Election) Stop() {
+	slog.Info("Leaving leader election", "lease", e.name)
+	e.cancel()
+	<-e.done
+}
@@ -0,0 +1,181 @@
+// Package lease keeps background jobs scheduled on every replica to one
+// instance at a time, and elects the instance running singleton components,
+// with named leases recorded in MongoDB. A lease is
+// renewed while its job runs and expires soon after the instance holding it
+// dies. Lease times come from the database server's clock, so the clocks of
+// the instances need not agree
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// ttl is how long a lease outlives its last renewal
+	ttl = time.Minute
+
+	// renewInterval is how often a held lease is renewed
+	renewInterval = ttl / 3
+)
+
+var (
+	// ErrHeld is returned when another instance holds the lease
+	ErrHeld = errors.New("lease is held by another instance")
+
+	// ErrLost cancels a job whose lease could not be renewed in time, as
+	// another instance may take it over
+	ErrLost = errors.New("lease lost")
+)
+
+// Leases takes named leases in one database. Each Leases is an instance of
+// its own, so two in one process compete for a lease as two processes would
+type Leases struct {
+	db    *db.Mongo
+	owner string // identifies this instance as the holder of its leases
+}
+
+// New creates an instance taking leases recorded in m
+func New(m *db.Mongo) *Leases {
+	host, _ := os.Hostname()
+	return &Leases{
+		db:    m,
+		owner: fmt.Sprintf("%s/%d/%s", host, os.Getpid(), primitive.NewObjectID().Hex()),
+	}
+}
+
+// Run runs job unless another instance holds the named lease, returning
+// ErrHeld without running it. The lease is held while job runs, under a
+// context cancelled with ErrLost should the lease be lost. Once job returns
+// the lease is kept until hold has passed since it was taken, so the other
+// instances skip runs scheduled in the same period while this one may run
+// again; a job scheduled every interval passes the interval. A zero hold
+// ignores the holds of earlier runs and leaves none, for runs requested by hand
+func (l *Leases) Run(ctx context.Context, name string, hold time.Duration, job func(ctx context.Context) error) error {
+	if err := l.acquire(ctx, name, hold > 0); err != nil {
+		return err
+	}
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	var lost atomic.Bool
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		if !l.renew(jobCtx, name) {
+			lost.Store(true)
+			cancel(ErrLost)
+		}
+	}()
+
+	err := job(jobCtx)
+	cancel(nil)
+	<-renewed
+	if lost.Load() {
+		if err == nil {
+			return ErrLost
+		}
+		return fmt.Errorf("%w: %w", ErrLost, err)
+	}
+	l.release(name, hold)
+	return err
+}
+
+// acquire takes the named lease if it is free, or already held by this
+// process. With respectHold, a lease held on after its run completed is not free
+func (l *Leases) acquire(ctx context.Context, name string, respectHold bool) error {
+	free := bson.A{bson.M{"$lt": bson.A{"$expires_at", "$$NOW"}}}
+	if respectHold {
+		free = append(free, bson.M{"$lt": bson.A{"$held_until", "$$NOW"}})
+	}
+	filter := bson.M{"_id": name, "$or": bson.A{
+		bson.M{"owner": l.owner},
+		bson.M{"$expr": bson.M{"$and": free}},
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"owner":       l.owner,
+		"acquired_at": "$$NOW",
+		"expires_at":  bson.M{"$add": bson.A{"$$NOW", ttl.Milliseconds()}},
+	}}}}
+
+	// A lease held by another instance fails the filter, and the upsert then
+	// collides with its _id
+	_, err := l.db.GetLeasesCollection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrHeld
+	}
+	if err != nil {
+		return fmt.Errorf("failed to acquire lease %s: %w", name, err)
+	}
+	return nil
+}
+
+// renew extends the lease until ctx is done, and returns false once it can't,
+// either because another instance took it over or because it could not be
+// renewed before expiring
+func (l *Leases) renew(ctx context.Context, name string) bool {
+	ticker := time.NewTicker(renewInterval)
+	defer ticker.Stop()
+
+	filter := bson.M{"_id": name, "owner": l.owner}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"expires_at": bson.M{"$add": bson.A{"$$NOW", ttl.Milliseconds()}},
+	}}}}
+	lastRenewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case <-ticker.C:
+		}
+
+		renewCtx, cancel := context.WithTimeout(context.Background(), renewInterval)
+		result, err := l.db.GetLeasesCollection().UpdateOne(renewCtx, filter, update)
+		cancel()
+		switch {
+		case err == nil && result.MatchedCount == 0:
+			slog.Error("Lease taken over by another instance", "lease", name)
+			return false
+		case err == nil:
+			lastRenewed = time.Now()
+		case time.Since(lastRenewed) >= ttl-renewInterval:
+			slog.Error("Lease could not be renewed before expiring", "lease", name, "error", err)
+			return false
+		default:
+			slog.Warn("Failed to renew lease", "lease", name, "error", err)
+		}
+	}
+}
+
+// release ends the run holding the lease, keeping the lease until hold has
+// passed since it was taken, or freeing it at once with a zero hold
+func (l *Leases) release(name string, hold time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": name, "owner": l.owner}
+	var err error
+	if hold > 0 {
+		update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"expires_at": "$$NOW",
+			"held_until": bson.M{"$add": bson.A{"$acquired_at", hold.Milliseconds()}},
+		}}}}
+		_, err = l.db.GetLeasesCollection().UpdateOne(ctx, filter, update)
+	} else {
+		_, err = l.db.GetLeasesCollection().DeleteOne(ctx, filter)
+	}
+	// It expires on its own otherwise
+	if err != nil {
+		slog.Warn("Failed to release lease", "lease", name, "error", err)
+	}
+}
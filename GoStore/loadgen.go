@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/kafka"
+	"github.com/ramG-reddy/sms-store/loadgen"
+	"github.com/ramG-reddy/sms-store/logging"
+	"github.com/spf13/cobra"
+)
+
+// newLoadgenCommand returns the loadgen command, which produces synthetic SMS
+// events to the SMS events topic at a steady rate for capacity testing, and
+// reports how long they took to be stored. It runs against a running service
+func newLoadgenCommand(app *cli) *cobra.Command {
+	var cfg loadgen.Config
+	var topic, languages, bodySizes string
+	cmd := &cobra.Command{
+		Use:   "loadgen --rate n --duration d",
+		Short: "Produce synthetic SMS events and measure their end-to-end ingest latency",
+		Args:  cobra.NoArgs,
+		Run: func(*cobra.Command, []string) {
+			if cfg.Rate <= 0 {
+				logging.Fatal("Invalid --rate; it must be positive", "rate", cfg.Rate)
+			}
+			if cfg.Duration <= 0 {
+				logging.Fatal("Invalid --duration; it must be positive", "duration", cfg.Duration)
+			}
+			if cfg.Users <= 0 {
+				logging.Fatal("Invalid --users; it must be positive", "users", cfg.Users)
+			}
+			if cfg.PollInterval <= 0 || cfg.ReportInterval <= 0 {
+				logging.Fatal("Invalid --poll or --progress; they must be positive", "poll", cfg.PollInterval, "progress", cfg.ReportInterval)
+			}
+			var err error
+			if cfg.Languages, err = loadgen.ParseLanguages(languages); err != nil {
+				logging.Fatal("Invalid --languages", "error", err)
+			}
+			if cfg.BodySizes, err = loadgen.ParseBodySizes(bodySizes); err != nil {
+				logging.Fatal("Invalid --body-sizes", "error", err)
+			}
+			if cfg.TenantID == "" {
+				cfg.TenantID = app.cfg.DefaultTenantID
+			}
+			app.loadgen(cfg, topic)
+		},
+	}
+	cmd.Flags().Float64Var(&cfg.Rate, "rate", 100, "events produced per second")
+	cmd.Flags().DurationVar(&cfg.Duration, "duration", time.Minute, "how long to produce for")
+	cmd.Flags().IntVar(&cfg.Count, "count", 0, "stop after producing this many events; 0 stops after --duration only")
+	cmd.Flags().IntVar(&cfg.Users, "users", 1000, "distinct users the events are spread over, a few of them getting most")
+	cmd.Flags().IntVar(&cfg.TrackedUsers, "track-users", 100, "busiest users whose events are read back from the store to measure latency; 0 measures none")
+	cmd.Flags().StringVar(&languages, "languages", "en=70,es=10,fr=5,pt=5,ja=5,ar=5", "language mix as language=weight pairs; one of "+strings.Join(loadgen.Languages(), ", "))
+	cmd.Flags().StringVar(&bodySizes, "body-sizes", "40=45,160=40,480=15", "body-size distribution as characters=weight pairs, each size the upper bound of its bucket")
+	cmd.Flags().StringVar(&cfg.TenantID, "tenant", "", "tenant of the events; defaults to DEFAULT_TENANT_ID")
+	cmd.Flags().StringVar(&topic, "topic", "", "topic to produce to; defaults to the first configured outbound topic")
+	cmd.Flags().DurationVar(&cfg.PollInterval, "poll", 500*time.Millisecond, "interval between reads of the store, which bounds the precision of latencies")
+	cmd.Flags().DurationVar(&cfg.DrainTimeout, "drain-timeout", time.Minute, "how long to wait for events to be stored once producing stops")
+	cmd.Flags().DurationVar(&cfg.ReportInterval, "progress", 10*time.Second, "interval between progress reports")
+	return cmd
+}
+
+// loadgen runs a load run configured by runCfg against topic
+func (app *cli) loadgen(runCfg loadgen.Config, topic string) {
+	cfg := app.cfg
+	if cfg.IngestBackend != "kafka" {
+		logging.Fatal("loadgen produces to Kafka, but the service consumes from another backend", "ingest_backend", cfg.IngestBackend)
+	}
+	if cfg.KafkaMessageFormat != "json" {
+		logging.Fatal("loadgen produces JSON events, but the service consumes another format", "format", cfg.KafkaMessageFormat)
+	}
+	if topic == "" {
+		for _, t := range slices.Sorted(maps.Keys(cfg.KafkaTopics)) {
+			if cfg.KafkaTopics[t] == kafka.HandlerOutbound {
+				topic = t
+				break
+			}
+		}
+		if topic == "" {
+			logging.Fatal("No outbound topic is configured; set --topic")
+		}
+	}
+
+	database := app.connectMongo()
+	defer database.Close()
+	core, err := newApplication(cfg, database)
+	if err != nil {
+		logging.Fatal("Failed to initialize message storage", "error", err)
+	}
+	defer core.Close()
+
+	producer := kafka.NewEventProducer(cfg.KafkaBrokers, topic, kafkaSecurity(cfg))
+	defer producer.Close()
+
+	// An interrupt stops producing and reports on the events produced so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("Starting load generation", "topic", topic, "tenant_id", runCfg.TenantID, "rate", runCfg.Rate, "duration", runCfg.Duration, "users", runCfg.Users)
+	report, err := loadgen.NewRunner(runCfg, producer, core.smsService).Run(ctx)
+	if err != nil {
+		logging.Fatal("Load generation failed", "sent", report.Sent, "error", err)
+	}
+	slog.Info("Load generation finished",
+		"sent", report.Sent,
+		"elapsed", report.Elapsed.Round(time.Millisecond),
+		"events_per_second", int(report.Rate()),
+		"tracked", report.Tracked,
+		"stored", report.Stored,
+		"missing", report.Missing(),
+		"latency_p50", report.Latency(50).Round(time.Millisecond),
+		"latency_p95", report.Latency(95).Round(time.Millisecond),
+		"latency_p99", report.Latency(99).Round(time.Millisecond),
+		"latency_max", report.Latency(100).Round(time.Millisecond),
+	)
+}
@@ -0,0 +1,61 @@
+package loadgen
+
+// corpus holds the words synthetic messages of one language are made of, and
+// the country such messages are sent to. The words include frequent ones, so
+// that where the store can tell the language of a message, it tells the one the
+// message was generated in
+type corpus struct {
+	country   string
+	separator string
+	words     []string
+}
+
+// corpora are the languages messages can be generated in, by ISO 639-1 code
+var corpora = map[string]corpus{
+	"en": {country: "US", separator: " ", words: []string{
+		"your", "verification", "code", "is", "please", "do", "not", "share", "it", "with", "anyone",
+		"the", "order", "has", "shipped", "and", "will", "arrive", "tomorrow", "thanks", "for", "shopping",
+		"you", "have", "an", "appointment", "at", "this", "account", "balance", "payment", "received",
+	}},
+	"es": {country: "ES", separator: " ", words: []string{
+		"tu", "código", "de", "verificación", "es", "no", "lo", "compartas", "con", "nadie",
+		"el", "pedido", "ha", "sido", "enviado", "y", "llegará", "mañana", "gracias", "por", "su", "compra",
+		"tienes", "una", "cita", "para", "los", "pagos", "recibido", "hola", "las", "cuenta",
+	}},
+	"fr": {country: "FR", separator: " ", words: []string{
+		"votre", "code", "de", "vérification", "est", "ne", "le", "partagez", "pas", "merci",
+		"la", "commande", "a", "été", "expédiée", "et", "arrivera", "demain", "pour", "vos", "achats",
+		"vous", "avez", "une", "rendez-vous", "avec", "les", "paiement", "reçu", "bonjour", "du", "au",
+	}},
+	"de": {country: "DE", separator: " ", words: []string{
+		"ihr", "bestätigungscode", "ist", "bitte", "nicht", "weitergeben", "danke", "für", "ihre", "bestellung",
+		"die", "wurde", "versandt", "und", "kommt", "morgen", "an", "sie", "haben", "einen", "termin",
+		"mit", "der", "zahlung", "das", "konto", "eine", "ein", "erhalten",
+	}},
+	"pt": {country: "BR", separator: " ", words: []string{
+		"seu", "código", "de", "verificação", "é", "não", "compartilhe", "com", "ninguém", "obrigado",
+		"o", "pedido", "foi", "enviado", "e", "chega", "amanhã", "você", "tem", "uma", "consulta",
+		"para", "sua", "conta", "pagamento", "recebido", "olá", "os", "que", "um",
+	}},
+	"ru": {country: "RU", separator: " ", words: []string{
+		"ваш", "код", "подтверждения", "никому", "не", "сообщайте", "его", "спасибо", "за", "заказ",
+		"отправлен", "и", "будет", "доставлен", "завтра", "у", "вас", "запись", "на", "платёж",
+		"получен", "баланс", "счёта", "здравствуйте", "вы", "мы", "это", "был", "выполнен", "вашего",
+	}},
+	"ar": {country: "SA", separator: " ", words: []string{
+		"رمز", "التحقق", "الخاص", "بك", "هو", "لا", "تشاركه", "مع", "أحد", "شكرا",
+		"تم", "شحن", "طلبك", "وسيصل", "غدا", "لديك", "موعد", "في", "تم", "استلام", "الدفع", "رصيد", "حسابك",
+	}},
+	"hi": {country: "IN", separator: " ", words: []string{
+		"आपका", "सत्यापन", "कोड", "है", "कृपया", "इसे", "किसी", "के", "साथ", "साझा", "न", "करें",
+		"धन्यवाद", "ऑर्डर", "भेज", "दिया", "गया", "कल", "पहुंचेगा", "भुगतान", "प्राप्त", "हुआ", "खाते", "में",
+	}},
+	"ja": {country: "JP", separator: "", words: []string{
+		"お客様の", "認証コードは", "です", "他人に", "教えないで", "ください", "ご注文の", "商品を",
+		"発送しました", "明日", "お届け", "します", "ご予約は", "お支払いを", "確認しました", "ありがとう", "ございます",
+	}},
+	"zh": {country: "CN", separator: "", words: []string{
+		"您的", "验证码", "是", "请勿", "告诉", "他人", "您的订单", "已发货", "预计", "明天", "送达",
+		"您有", "一个", "预约", "付款", "已收到", "账户", "余额", "谢谢", "您",
+	}},
+}
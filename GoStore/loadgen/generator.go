@@ -0,0 +1,218 @@
+// Package loadgen produces synthetic SMS events to the service's Kafka topic for
+// capacity testing, and measures how long they take to be stored by reading
+// them back from the message store
+package loadgen
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// userPrefix begins the user IDs and phone numbers of synthetic messages: the
+// unassigned country code 999, so they can't reach or be mistaken for real users
+const userPrefix = "+999"
+
+// javaLocalDateTime formats createdAt as the Java sender does: UTC, without a zone
+const javaLocalDateTime = "2006-01-02T15:04:05.000000"
+
+// zipfSkew is how unevenly messages are spread over users: a few users get most
+// of them, as in real traffic
+const zipfSkew = 1.1
+
+// UserID returns the user ID, and phone number, of the nth synthetic user
+func UserID(n int) string {
+	return fmt.Sprintf("%s%011d", userPrefix, n)
+}
+
+// ParseLanguages parses a language mix such as "en=70,es=20,ja=10" into the
+// relative weight of each language
+func ParseLanguages(mix string) (map[string]int, error) {
+	weights, err := parseWeights(mix)
+	if err != nil {
+		return nil, err
+	}
+	languages := make(map[string]int, len(weights))
+	for value, weight := range weights {
+		if _, ok := corpora[value]; !ok {
+			return nil, fmt.Errorf("unsupported language %q; use one of %s", value, strings.Join(Languages(), ", "))
+		}
+		languages[value] = weight
+	}
+	return languages, nil
+}
+
+// Languages returns the languages messages can be generated in
+func Languages() []string {
+	languages := make([]string, 0, len(corpora))
+	for language := range corpora {
+		languages = append(languages, language)
+	}
+	slices.Sort(languages)
+	return languages
+}
+
+// ParseBodySizes parses a body-size distribution such as "40=50,160=35,480=15"
+// into the relative weight of each size bucket. A message falling in a bucket
+// has from one more character than the next smaller bucket up to its size
+func ParseBodySizes(distribution string) (map[int]int, error) {
+	weights, err := parseWeights(distribution)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[int]int, len(weights))
+	for value, weight := range weights {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid body size %q; sizes are positive numbers of characters", value)
+		}
+		sizes[size] = weight
+	}
+	return sizes, nil
+}
+
+// parseWeights parses comma-separated value=weight pairs
+func parseWeights(s string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		value, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid weight %q; use value=weight", pair)
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid weight %q; weights are non-negative integers", pair)
+		}
+		if _, ok := weights[value]; ok {
+			return nil, fmt.Errorf("%q is weighted more than once", value)
+		}
+		weights[value] = n
+	}
+	total := 0
+	for _, n := range weights {
+		total += n
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("at least one weight must be positive")
+	}
+	return weights, nil
+}
+
+// choice picks values at random in proportion to their weights
+type choice[T any] struct {
+	values     []T
+	cumulative []int
+}
+
+// newChoice creates a choice between the keys of weights, in the order of keys
+func newChoice[T comparable](weights map[T]int, keys []T) choice[T] {
+	var c choice[T]
+	total := 0
+	for _, key := range keys {
+		if weights[key] == 0 {
+			continue
+		}
+		total += weights[key]
+		c.values = append(c.values, key)
+		c.cumulative = append(c.cumulative, total)
+	}
+	return c
+}
+
+// pick returns a value at random
+func (c choice[T]) pick(rng *rand.Rand) T {
+	n := rng.IntN(c.cumulative[len(c.cumulative)-1])
+	i, _ := slices.BinarySearch(c.cumulative, n+1)
+	return c.values[i]
+}
+
+// Generator makes synthetic SMS events
+type Generator struct {
+	rng       *rand.Rand
+	users     *rand.Zipf
+	run       string
+	tenantID  string
+	seq       int
+	languages choice[string]
+	sizes     choice[int]
+	lowerSize map[int]int // the smallest size of each bucket
+}
+
+// NewGenerator creates a generator of events of tenantID to users synthetic
+// users, in the given language mix and body-size distribution
+func NewGenerator(tenantID string, users int, languages map[string]int, sizes map[int]int) *Generator {
+	rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+
+	buckets := make([]int, 0, len(sizes))
+	for size := range sizes {
+		buckets = append(buckets, size)
+	}
+	slices.Sort(buckets)
+	lowerSize := make(map[int]int, len(buckets))
+	for i, size := range buckets {
+		lowerSize[size] = 1
+		if i > 0 {
+			lowerSize[size] = buckets[i-1] + 1
+		}
+	}
+
+	return &Generator{
+		rng:       rng,
+		users:     rand.NewZipf(rng, zipfSkew, 1, uint64(users-1)),
+		run:       strconv.FormatUint(rng.Uint64()&0xffffffffff, 36),
+		tenantID:  tenantID,
+		languages: newChoice(languages, Languages()),
+		sizes:     newChoice(sizes, buckets),
+		lowerSize: lowerSize,
+	}
+}
+
+// Next returns a new event created at now for a user picked at random, and the
+// number of that user
+func (g *Generator) Next(now time.Time) (*models.KafkaEvent, int) {
+	g.seq++
+	user := int(g.users.Uint64())
+	language := g.languages.pick(g.rng)
+	size := g.sizes.pick(g.rng)
+	if lower := g.lowerSize[size]; lower < size {
+		size = lower + g.rng.IntN(size-lower+1)
+	}
+
+	return &models.KafkaEvent{
+		EventID:     fmt.Sprintf("loadgen-%s-%d", g.run, g.seq),
+		TenantID:    g.tenantID,
+		UserID:      UserID(user),
+		PhoneNumber: UserID(user),
+		Message:     g.body(corpora[language], size),
+		Status:      models.StatusSent,
+		CreatedAt:   now.UTC().Format(javaLocalDateTime),
+		CountryCode: corpora[language].country,
+	}, user
+}
+
+// body returns a message of about size characters made of words of c, cut
+// short at size characters when a word runs past it
+func (g *Generator) body(c corpus, size int) string {
+	var b strings.Builder
+	length := 0
+	for length < size {
+		word := c.words[g.rng.IntN(len(c.words))]
+		if length > 0 {
+			b.WriteString(c.separator)
+			length += utf8.RuneCountInString(c.separator)
+		}
+		b.WriteString(word)
+		length += utf8.RuneCountInString(word)
+	}
+	body := b.String()
+	if length > size {
+		body = string([]rune(body)[:size])
+	}
+	return strings.TrimSpace(body)
+}
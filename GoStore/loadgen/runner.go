@@ -0,0 +1,293 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/kafka"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+// produceInterval is how often the events due by the rate are published, as one batch
+const produceInterval = 10 * time.Millisecond
+
+// Config configures a load run
+type Config struct {
+	TenantID       string
+	Rate           float64       // events per second
+	Duration       time.Duration // how long to produce for
+	Count          int           // stop after this many events; 0 stops after Duration only
+	Users          int           // user cardinality: the distinct users events are spread over
+	TrackedUsers   int           // the users, counted from the busiest, whose events are read back; 0 reads none
+	Languages      map[string]int
+	BodySizes      map[int]int
+	PollInterval   time.Duration // between reads of the store, which bounds the precision of latencies
+	DrainTimeout   time.Duration // how long to wait for tracked events to be stored once producing stops
+	ReportInterval time.Duration
+}
+
+// Report summarizes a load run
+type Report struct {
+	Sent      int
+	Elapsed   time.Duration // spent producing
+	Tracked   int           // events read back to measure latency
+	Stored    int           // tracked events found in the store
+	latencies []time.Duration
+}
+
+// Rate returns the events produced per second
+func (r *Report) Rate() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Sent) / r.Elapsed.Seconds()
+}
+
+// Missing returns how many tracked events weren't found in the store in time
+func (r *Report) Missing() int {
+	return r.Tracked - r.Stored
+}
+
+// Latency returns the pth percentile, from 0 to 100, of the end-to-end latencies
+// of the stored events: from being produced until being read back from the store.
+// It is zero when none were stored
+func (r *Report) Latency(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	i := int(p / 100 * float64(len(r.latencies)-1))
+	return r.latencies[i]
+}
+
+// Runner produces synthetic events at a steady rate and reads back those of the
+// tracked users until each is stored
+type Runner struct {
+	cfg        Config
+	generator  *Generator
+	producer   *kafka.EventProducer
+	smsService *services.SMSService
+
+	mu      sync.Mutex
+	pending map[string]map[string]time.Time // user ID to event ID to when it was produced
+	report  Report
+}
+
+// NewRunner creates a runner producing with producer and reading back through smsService
+func NewRunner(cfg Config, producer *kafka.EventProducer, smsService *services.SMSService) *Runner {
+	return &Runner{
+		cfg:        cfg,
+		generator:  NewGenerator(cfg.TenantID, cfg.Users, cfg.Languages, cfg.BodySizes),
+		producer:   producer,
+		smsService: smsService,
+		pending:    make(map[string]map[string]time.Time),
+	}
+}
+
+// Run produces events until the configured duration or count is reached, then
+// waits up to the drain timeout for the tracked ones to be stored. Cancelling
+// ctx ends the run early; the report then covers what was produced so far
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	readCtx, stopReading := context.WithCancel(tenant.WithID(context.WithoutCancel(ctx), r.cfg.TenantID))
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		r.readBack(readCtx)
+	}()
+
+	err := r.produce(ctx)
+	if err == nil {
+		r.drain(ctx)
+	}
+	stopReading()
+	<-readDone
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := r.report
+	report.latencies = slices.Clone(r.report.latencies)
+	slices.Sort(report.latencies)
+	return &report, err
+}
+
+// produce publishes the events due by the rate every produce interval
+func (r *Runner) produce(ctx context.Context) error {
+	ticker := time.NewTicker(produceInterval)
+	defer ticker.Stop()
+
+	started := time.Now()
+	sent := 0
+	for ctx.Err() == nil {
+		elapsed := min(time.Since(started), r.cfg.Duration)
+		due := int(r.cfg.Rate*elapsed.Seconds()) - sent
+		if r.cfg.Count > 0 {
+			due = min(due, r.cfg.Count-sent)
+		}
+		if due > 0 {
+			if err := r.publish(ctx, due); err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				return err
+			}
+			sent += due
+		}
+		if elapsed >= r.cfg.Duration || (r.cfg.Count > 0 && sent >= r.cfg.Count) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	r.mu.Lock()
+	r.report.Elapsed = time.Since(started)
+	r.mu.Unlock()
+	return nil
+}
+
+// publish generates n events and publishes them. Those of tracked users are
+// tracked first, so none can be stored before they are looked for
+func (r *Runner) publish(ctx context.Context, n int) error {
+	now := time.Now()
+	events := make([]*models.KafkaEvent, n)
+	var tracked []*models.KafkaEvent
+	r.mu.Lock()
+	for i := range events {
+		event, user := r.generator.Next(now)
+		events[i] = event
+		if user < r.cfg.TrackedUsers {
+			if r.pending[event.UserID] == nil {
+				r.pending[event.UserID] = make(map[string]time.Time)
+			}
+			r.pending[event.UserID][event.EventID] = now
+			tracked = append(tracked, event)
+		}
+	}
+	r.mu.Unlock()
+
+	err := r.producer.Publish(ctx, events)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		for _, event := range tracked {
+			delete(r.pending[event.UserID], event.EventID)
+			if len(r.pending[event.UserID]) == 0 {
+				delete(r.pending, event.UserID)
+			}
+		}
+		return fmt.Errorf("failed to publish %d events: %w", n, err)
+	}
+	r.report.Sent += n
+	r.report.Tracked += len(tracked)
+	return nil
+}
+
+// drain waits until every tracked event is stored, the drain timeout passes or
+// ctx is cancelled
+func (r *Runner) drain(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.DrainTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		pending := len(r.pending)
+		r.mu.Unlock()
+		if pending == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readBack polls the store for the pending events, and logs progress, until ctx
+// is cancelled
+func (r *Runner) readBack(ctx context.Context) {
+	poll := time.NewTicker(r.cfg.PollInterval)
+	defer poll.Stop()
+	report := time.NewTicker(r.cfg.ReportInterval)
+	defer report.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			if err := r.poll(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("Failed to read back load generation events", "error", err)
+			}
+		case <-report.C:
+			r.mu.Lock()
+			slog.Info("Load generation progress",
+				"sent", r.report.Sent,
+				"stored", r.report.Stored,
+				"pending", r.report.Tracked-r.report.Stored,
+			)
+			r.mu.Unlock()
+		}
+	}
+}
+
+// poll looks up the messages of the users with pending events created since the
+// oldest of them, and records the latency of each pending event found
+func (r *Runner) poll(ctx context.Context) error {
+	r.mu.Lock()
+	users := make([]string, 0, len(r.pending))
+	var since time.Time
+	for user, events := range r.pending {
+		users = append(users, user)
+		for _, producedAt := range events {
+			if since.IsZero() || producedAt.Before(since) {
+				since = producedAt
+			}
+		}
+	}
+	r.mu.Unlock()
+	if len(users) == 0 {
+		return nil
+	}
+
+	// created_at is stored with millisecond precision at best
+	found, err := r.smsService.FindMessagesForUsers(ctx, users, models.MessageQuery{
+		Since:  since.Add(-time.Second),
+		Fields: []string{"message_id"},
+	})
+	if err != nil {
+		return err
+	}
+	seen := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for user, records := range found {
+		events := r.pending[user]
+		for _, record := range records {
+			producedAt, ok := events[record.MessageID]
+			if !ok {
+				continue
+			}
+			delete(events, record.MessageID)
+			r.report.Stored++
+			r.report.latencies = append(r.report.latencies, seen.Sub(producedAt))
+		}
+		if len(events) == 0 {
+			delete(r.pending, user)
+		}
+	}
+	return nil
+}
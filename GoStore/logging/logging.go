@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ramG-reddy/sms-store/redact"
+	"github.com/ramG-reddy/sms-store/requestid"
+)
+
+// level is shared by the installed handler so it can be changed after startup
+var level = new(slog.LevelVar)
+
+// Init installs a JSON slog handler on stdout as the default logger, masking
+// personal data with redactor while it is enabled
+// Output from the standard library log package is routed through it as well
+func Init(service string, redactor *redact.Redactor) {
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(contextHandler{handler, redactor}).With("service", service))
+}
+
+// contextHandler adds the request ID from the record's context to every log line
+// written with one of the slog *Context functions, and masks personal data in
+// every line while redaction is enabled
+type contextHandler struct {
+	slog.Handler
+	redactor *redact.Redactor
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if h.redactor.Enabled() {
+		record = h.redactRecord(record)
+	}
+	if id, ok := requestid.FromContext(ctx); ok {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs masks the attributes by the redaction setting at the time; the
+// service's loggers only add attributes holding no personal data this way
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if h.redactor.Enabled() {
+		redacted := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = h.redactAttr(attr)
+		}
+		attrs = redacted
+	}
+	return contextHandler{h.Handler.WithAttrs(attrs), h.redactor}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name), h.redactor}
+}
+
+// redactRecord returns a copy of record with personal data masked in its
+// message and attributes
+func (h contextHandler) redactRecord(record slog.Record) slog.Record {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.String(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return redacted
+}
+
+// redactAttr masks an attribute's value by its key, formatting errors and
+// other values as text first so nothing escapes unmasked
+func (h contextHandler) redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redactor.Field(attr.Key, value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, member := range group {
+			redacted[i] = h.redactAttr(member)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		return slog.String(attr.Key, h.redactor.Field(attr.Key, fmt.Sprint(value.Any())))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// SetLevel changes the minimum level logged: DEBUG, INFO, WARN, or ERROR
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Fatal logs msg at error level and exits the process
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -2,93 +2,730 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/ramG-reddy/sms-store/admin"
+	"github.com/ramG-reddy/sms-store/archive"
+	"github.com/ramG-reddy/sms-store/auth"
+	"github.com/ramG-reddy/sms-store/cache"
 	"github.com/ramG-reddy/sms-store/config"
 	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/export"
+	"github.com/ramG-reddy/sms-store/flagging"
+	"github.com/ramG-reddy/sms-store/grpcserver"
 	"github.com/ramG-reddy/sms-store/handlers"
+	"github.com/ramG-reddy/sms-store/handoff"
+	"github.com/ramG-reddy/sms-store/ingest"
 	"github.com/ramG-reddy/sms-store/kafka"
+	"github.com/ramG-reddy/sms-store/logging"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/opmode"
+	"github.com/ramG-reddy/sms-store/quota"
+	"github.com/ramG-reddy/sms-store/redact"
+	"github.com/ramG-reddy/sms-store/retention"
+	"github.com/ramG-reddy/sms-store/search"
+	"github.com/ramG-reddy/sms-store/servertls"
 	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+	"github.com/ramG-reddy/sms-store/tiering"
+	"github.com/ramG-reddy/sms-store/tracing"
+	"github.com/ramG-reddy/sms-store/webhooks"
 )
 
+// version identifies the build in /readyz; set with -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
-	log.Println("Starting SMS Store Service...")
+	// Enabled once the configuration is loaded, if it says so
+	redactor := redact.New(false)
+	logging.Init("sms-store", redactor)
+
+	if err := newRootCommand(redactor).Execute(); err != nil {
+		os.Exit(2)
+	}
+}
+
+// serve runs the service until it receives SIGINT or SIGTERM
+func serve(app *cli) {
+	cfg := app.cfg
+	slog.Info("Starting SMS Store Service", "version", version)
 
-	// Load configuration
-	cfg, err := config.Load()
+	// Keep the Vault token alive and watch referenced secrets for rotation
+	if cfg.Secrets != nil {
+		cfg.Secrets.Start()
+		defer cfg.Secrets.Stop()
+	}
+
+	// Take over the listeners of the previous process when restarted in place
+	handoffs, err := handoff.New(time.Duration(cfg.RestartHandoffTimeoutSeconds)*time.Second, cfg.PIDFile)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		logging.Fatal("Failed to take over from the previous process", "error", err)
 	}
+	defer handoffs.Close()
+
+	// Initialize tracing before any instrumented component starts
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Enabled:     cfg.TracingEnabled,
+		ServiceName: cfg.TracingServiceName,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Error flushing traces", "error", err)
+		}
+	}()
 
 	// Initialize MongoDB connection
-	if err := db.InitMongoDB(cfg.MongoURI, cfg.MongoDatabase); err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	database := app.connectMongo()
+	defer database.Close()
+
+	// Replace the client if MongoDB stays unreachable; failovers are followed by the driver
+	if cfg.MongoReconnectAfterSeconds > 0 {
+		supervisor := db.NewSupervisor(database, time.Duration(cfg.MongoReconnectAfterSeconds)*time.Second)
+		supervisor.Start()
+		defer supervisor.Stop()
+	}
+
+	// Reject malformed records at the database layer too; on a fresh database this
+	// creates sms_records, so it must come before the indexes
+	if err := database.EnsureRecordSchema(); err != nil {
+		slog.Warn("Failed to apply record schema validation", "error", err)
+	}
+
+	// Create any missing indexes; the service still works without them, just slower
+	if err := database.EnsureIndexes(); err != nil {
+		slog.Warn("Failed to ensure indexes", "error", err)
 	}
-	defer db.Close()
 
-	// Verify indexes (created by MongoDB initialization script)
-	if err := db.ValidateIndexes(); err != nil {
-		log.Printf("Warning: Index validation failed: %v", err)
-		// Continue anyway - indexes should exist from MongoDB init
+	// Bring the database up to date, or point at the migrate subcommand when
+	// migrations are run as a separate deployment step
+	migrateCtx, cancelMigrate := context.WithTimeout(context.Background(), 10*time.Minute)
+	if cfg.MongoMigrateOnStart {
+		if _, err := database.MigrateUp(migrateCtx, db.MigrationEnv{DefaultTenantID: cfg.DefaultTenantID}, 0); err != nil {
+			logging.Fatal("Failed to apply MongoDB migrations", "error", err)
+		}
+	} else if pending, err := database.PendingMigrations(migrateCtx); err != nil {
+		slog.Warn("Failed to check MongoDB migrations", "error", err)
+	} else if pending > 0 {
+		slog.Warn("MongoDB migrations are pending; run sms-store migrate up", "pending", pending)
+	}
+	cancelMigrate()
+
+	if cfg.StorageBackend == store.BackendMongo {
+		// Apply the TTL retention policy; re-run on every start so RETENTION_DAYS changes take effect.
+		// Retention policies replace the TTL index with the sweeper, so it is removed
+		ttlDays := cfg.RetentionDays
+		if cfg.RetentionPoliciesEnabled {
+			ttlDays = 0
+		}
+		if err := database.EnsureRetentionPolicy(ttlDays); err != nil {
+			slog.Warn("Failed to apply retention policy", "error", err)
+		}
+	}
+
+	// Open the message storage and the SMS service on it; everything below is
+	// given the parts of core it uses
+	core, err := newApplication(cfg, database)
+	if err != nil {
+		logging.Fatal("Failed to initialize message storage", "error", err)
 	}
+	defer core.Close()
+	messageStorage, broker, smsService := core.storage, core.broker, core.smsService
 
 	// Initialize services
-	smsService := services.NewSMSService()
+	var userCache *cache.UserMessages
+	if cfg.RedisURL != "" {
+		// Caching is optional, so the service runs uncached when Redis is unreachable
+		userCache, err = cache.NewUserMessages(cfg.RedisURL, time.Duration(cfg.UserCacheTTLSeconds)*time.Second)
+		if err != nil {
+			userCache = nil
+			slog.Warn("User message cache disabled", "error", err)
+		} else {
+			defer userCache.Close()
+			smsService.EnableCache(userCache)
+		}
+	}
+	if cfg.UserStatsCacheTTLSeconds > 0 {
+		smsService.EnableStatsCache(cache.NewUserStats(time.Duration(cfg.UserStatsCacheTTLSeconds) * time.Second))
+	}
+	responseCache := newResponseCache(cfg)
+	if responseCache != nil {
+		smsService.EnableResponseCache(responseCache)
+	}
+	var searchIndex *search.Index
+	if cfg.SearchURL != "" {
+		// Search is optional, so the service runs without it when the cluster is unreachable
+		index := search.NewIndex(search.Config{
+			URL:      cfg.SearchURL,
+			Index:    cfg.SearchIndex,
+			Username: cfg.SearchUsername,
+			Password: cfg.SearchPassword,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := index.EnsureIndex(ctx)
+		cancel()
+		if err != nil {
+			slog.Warn("Message search disabled", "error", err)
+		} else {
+			smsService.EnableSearch(index)
+			searchIndex = index
+			// Pruning follows RETENTION_DAYS, and does nothing while it is zero. With
+			// retention policies the sweeper removes expired messages from the index instead
+			if !cfg.RetentionPoliciesEnabled {
+				searchIndex.SetRetention(time.Duration(cfg.RetentionDays) * 24 * time.Hour)
+			}
+			searchIndex.StartPruning(core.leases, time.Hour)
+			defer searchIndex.Stop()
+		}
+	}
+	var flagger *flagging.Flagger
+	if cfg.FlagRulesFile != "" {
+		rules, err := flagging.LoadFile(cfg.FlagRulesFile)
+		if err == nil {
+			flagger, err = flagging.New(rules)
+		}
+		if err != nil {
+			logging.Fatal("Failed to load flag rules", "error", err)
+		}
+		smsService.EnableFlagging(flagger)
+		slog.Info("Message flagging enabled", "rules", len(rules))
+	}
+	var senderService *services.SenderService
+	if cfg.SenderRegistryEnabled {
+		senderService = services.NewSenderService(database, time.Duration(cfg.SenderCacheSeconds)*time.Second)
+		smsService.EnableSenders(senderService)
+	}
+	var blocklistService *services.BlocklistService
+	if cfg.BlocklistEnabled {
+		blocklistService = services.NewBlocklistService(database, cfg.BlocklistAction, time.Duration(cfg.BlocklistCacheSeconds)*time.Second)
+		smsService.EnableBlocklist(blocklistService)
+	}
+	var quotaService *services.QuotaService
+	if cfg.QuotaEnabled {
+		quotaService = services.NewQuotaService(database, models.UserQuota{
+			MaxMessages: int64(cfg.QuotaMaxMessagesPerUser),
+			MaxBytes:    int64(cfg.QuotaMaxBytesPerUser),
+		}, cfg.QuotaAction, cfg.QuotaWarningPercent, broker)
+		smsService.EnableQuotas(quotaService)
+	}
+	webhookService := services.NewWebhookService(database)
+	webhookService.EnablePseudonyms(core.pseudonyms)
+	webhookService.EnablePhoneNormalization(core.numbers)
+	auditService := services.NewAuditService(messageStorage.store)
+	auditService.EnablePseudonyms(core.pseudonyms)
+	apiKeyService := services.NewAPIKeyService(database)
+	// Usage is only metered for API keys, so it needs them enabled
+	var usageService *services.UsageService
+	if cfg.APIKeyAuthEnabled && cfg.APIKeyUsageTracking {
+		usageService = services.NewUsageService(database)
+	}
+
+	if cfg.BootstrapAPIKey != "" {
+		if err := apiKeyService.EnsureBootstrapKey(context.Background(), cfg.DefaultTenantID, cfg.BootstrapAPIKey); err != nil {
+			logging.Fatal("Failed to register bootstrap API key", "error", err)
+		}
+	}
+
+	// API keys and bearer tokens can be enabled independently; with neither,
+	// routes only require X-Tenant-ID
+	var authenticators []auth.Authenticator
+	if cfg.APIKeyAuthEnabled {
+		authenticators = append(authenticators, auth.NewAPIKeyAuthenticator(apiKeyService))
+	}
+	if cfg.JWTIssuer != "" {
+		jwtAuthenticator, err := auth.NewJWTAuthenticator(context.Background(), auth.JWTConfig{
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+			JWKSURL:     cfg.JWTJWKSURL,
+			RolesClaim:  cfg.JWTRolesClaim,
+			TenantClaim: cfg.JWTTenantClaim,
+		})
+		if err != nil {
+			logging.Fatal("Failed to initialize JWT authentication", "error", err)
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+	if len(authenticators) == 0 {
+		slog.Warn("Authentication is disabled; only X-Tenant-ID is required")
+	}
+
+	// Start webhook dispatcher before ingestion so no events are missed
+	dispatcher := webhooks.NewDispatcher(webhooks.Config{
+		Workers:     cfg.WebhookWorkers,
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Timeout:     time.Duration(cfg.WebhookTimeoutSeconds) * time.Second,
+		RetryBase:   time.Second,
+	}, webhookService)
+	if cfg.WebhookLeaderElection {
+		// Every instance sees every change on the change stream, so only the
+		// elected one delivers, from a stream of its own whose resume token is
+		// shared so a new leader carries on where the last one stopped
+		webhookEvents := events.NewBroker()
+		dispatcher.Start(webhookEvents)
+		defer dispatcher.Stop()
+		if quotaService != nil {
+			// Quota events are not on the change stream, and each is only
+			// published on the instance storing the message that raised it
+			broker.AddListener(func(event events.Event) {
+				if event.Usage != nil {
+					webhookEvents.PublishUsage(event.Type, event.Record, event.Usage)
+				}
+			})
+		}
+
+		election := core.leases.NewElection("webhooks", func(ctx context.Context) {
+			watcher := smsService.WatchChangesTo(database, "webhooks", webhookEvents)
+			watcher.Start()
+			<-ctx.Done()
+			watcher.Stop()
+		})
+		election.Start()
+		defer election.Stop()
+	} else {
+		dispatcher.Start(broker)
+		defer dispatcher.Stop()
+	}
+
+	// Publish stored records and status changes from a change stream, so SSE and
+	// webhook subscribers see changes made by every instance and none are missed across restarts
+	if cfg.ChangeStreamsEnabled {
+		// Change streams have the same deployment requirement as transactions
+		if !database.SupportsTransactions() {
+			logging.Fatal("Change streams require a MongoDB replica set or sharded cluster")
+		}
+		watcher := smsService.WatchChanges(database, cfg.ChangeStreamName)
+		watcher.Start()
+		defer watcher.Stop()
+	}
+
+	// Ingestion is paused in the read_only mode and reads refused in write_only,
+	// as configured and then switched on the admin server
+	serviceMode, _ := opmode.Parse(cfg.ServiceMode) // validated by Load
+	modes := opmode.NewSwitch(serviceMode)
+
+	// Readiness requires MongoDB, the message storage backend, and every running
+	// Kafka consumer unless ingestion is paused; the cache and search index only
+	// degrade the service
+	healthHandler := handlers.NewHealthHandler(version)
+	healthHandler.SetModes(modes)
+	healthHandler.AddCheck("mongodb", database.HealthCheck)
+	healthHandler.AddDetails("mongodb", database.HealthDetails)
+	if messageStorage.postgres != nil {
+		healthHandler.AddCheck("postgres", messageStorage.postgres.Ping)
+	}
+	if messageStorage.cassandra != nil {
+		healthHandler.AddCheck("cassandra", messageStorage.cassandra.Ping)
+	}
+	if userCache != nil {
+		healthHandler.AddOptionalCheck("redis", userCache.Ping)
+	}
+	if searchIndex != nil {
+		healthHandler.AddOptionalCheck("search", searchIndex.Ping)
+	}
+
+	// Every Kafka connection shares the broker TLS and SASL settings
+	security := kafkaSecurity(cfg)
+
+	// Unprocessable messages from either consumer go to a shared dead-letter topic
+	// Deferred first so it closes after the consumers have stopped
+	var dlq *kafka.DeadLetterQueue
+	if cfg.KafkaDLQTopic != "" {
+		dlq = kafka.NewDeadLetterQueue(cfg.KafkaBrokers, cfg.KafkaDLQTopic, security)
+		defer dlq.Close()
+	}
+
+	// Malformed and invalid messages are also kept in MongoDB for reprocessing
+	var quarantine *kafka.Quarantine
+	if cfg.KafkaQuarantineEnabled {
+		quarantine = kafka.NewQuarantine(database, cfg.KafkaBrokers, security)
+		defer quarantine.Close()
+	}
+
+	// Operators can republish stored messages to the requeue topic through the admin server
+	var requeueService *services.RequeueService
+	if cfg.KafkaRequeueTopic != "" {
+		requeuer := kafka.NewRequeuer(cfg.KafkaBrokers, cfg.KafkaRequeueTopic, security)
+		defer requeuer.Close()
+		requeueService = services.NewRequeueService(smsService, auditService, requeuer, cfg.KafkaRequeueMaxAttempts)
+	}
 
-	// Start Kafka consumer
-	consumer, err := kafka.StartConsumer(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroupID, smsService)
+	// Publish a compact event to the stored-events topic for every stored message,
+	// via an outbox on the records. Deferred before the consumers so it stops after them
+	if cfg.KafkaStoredEventsTopic != "" {
+		smsService.EnableStoredEvents()
+		relay := kafka.NewOutboxRelay(cfg.KafkaBrokers, cfg.KafkaStoredEventsTopic, security, time.Duration(cfg.KafkaOutboxPollMs)*time.Millisecond, smsService)
+		relay.Start()
+		defer relay.Stop()
+	}
+
+	// After an in-place restart the previous process stops its consumers first,
+	// so each group sees it leave before this process joins
+	if err := handoffs.AwaitConsumers(); err != nil {
+		logging.Fatal("Failed to take over consumers from the previous process", "error", err)
+	}
+	// Stopped once, whether on shutdown or when handing over to a new process
+	ingestors, err := core.startIngestion(security, dlq, quarantine)
 	if err != nil {
-		log.Fatalf("Failed to start Kafka consumer: %v", err)
+		logging.Fatal("Failed to start ingestion", "error", err)
+	}
+	stopIngestion := sync.OnceFunc(func() {
+		for _, ingestor := range slices.Backward(ingestors) {
+			ingestor.Stop()
+		}
+	})
+	defer stopIngestion()
+	adminConsumers := make(map[string]admin.Consumer, len(ingestors))
+	for _, ingestor := range ingestors {
+		healthHandler.AddIngestionCheck(ingestor.name, func(ctx context.Context) error { return ingestor.Check(ctx, ingestor.maxLag) })
+		healthHandler.AddDetails(ingestor.name, consumerDetails(ingestor))
+		adminConsumers[ingestor.name] = ingestor
+		modes.AddConsumer(ingestor.name, ingestor)
 	}
-	defer consumer.Stop()
 
-	// Setup HTTP handlers
-	smsHandler := handlers.NewSMSHandler(smsService)
+	// Start scheduled archival of old messages to S3 if enabled. Retention policies
+	// and quotas archiving messages use the archiver too, without its schedule
+	var archiver *archive.Archiver
+	quotaArchives := cfg.QuotaEnabled && cfg.QuotaAction == models.QuotaActionArchive
+	if cfg.ArchiveEnabled || (cfg.RetentionPoliciesEnabled && cfg.ArchiveS3Bucket != "") || quotaArchives {
+		archiver, err = archive.NewArchiver(context.Background(), archive.Config{
+			Interval:  time.Duration(cfg.ArchiveIntervalMinutes) * time.Minute,
+			BatchSize: int64(cfg.ArchiveBatchSize),
+			MaxAge:    time.Duration(cfg.ArchiveMaxAgeDays) * 24 * time.Hour,
+			Bucket:    cfg.ArchiveS3Bucket,
+			Prefix:    cfg.ArchiveS3Prefix,
+			Endpoint:  cfg.ArchiveS3Endpoint,
+		}, core.leases, smsService)
+		if err != nil {
+			logging.Fatal("Failed to initialize archiver", "error", err)
+		}
+		if cfg.ArchiveEnabled {
+			archiver.Start()
+			defer archiver.Stop()
+		}
+	}
+
+	// Start the sweeper applying per-tenant retention policies if enabled
+	var retentionService *services.RetentionPolicyService
+	var sweeper *retention.Sweeper
+	if cfg.RetentionPoliciesEnabled {
+		retentionService = services.NewRetentionPolicyService(database)
+		sweeper = retention.NewSweeper(retention.Config{
+			Interval:         time.Duration(cfg.RetentionSweepMinutes) * time.Minute,
+			BatchSize:        int64(cfg.RetentionSweepBatchSize),
+			DefaultRetention: time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+			DefaultAction:    cfg.RetentionAction,
+		}, core.leases, smsService, retentionService, archiver)
+		sweeper.Start()
+		defer sweeper.Stop()
+	}
+
+	// Start moving old messages to the cold tier if enabled
+	if cfg.TieringEnabled {
+		mover := tiering.NewMover(tiering.Config{
+			Interval:  time.Duration(cfg.TieringIntervalMinutes) * time.Minute,
+			ColdAfter: time.Duration(cfg.TieringColdAfterDays) * 24 * time.Hour,
+			BatchSize: int64(cfg.TieringBatchSize),
+		}, database, core.leases)
+		mover.Start()
+		defer mover.Stop()
+	}
+
+	// Start reconciling users' storage usage, and archiving the messages of users
+	// over their quota with the archive action, if quotas are enabled
+	if cfg.QuotaEnabled {
+		var quotaArchiver *archive.Archiver
+		if quotaArchives {
+			quotaArchiver = archiver
+		}
+		enforcer := quota.NewEnforcer(quota.Config{
+			Interval:          time.Duration(cfg.QuotaEnforceMinutes) * time.Minute,
+			ReconcileInterval: time.Duration(cfg.QuotaReconcileHours) * time.Hour,
+			BatchSize:         int64(cfg.QuotaBatchSize),
+		}, core.leases, quotaService, smsService, quotaArchiver)
+		enforcer.Start()
+		defer enforcer.Stop()
+	}
 
-	http.HandleFunc("/v0/user/", smsHandler.GetUserMessages)
-	http.HandleFunc("/health", smsHandler.HealthCheck)
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "SMS Store Service - Use /v0/user/{user_id}/messages to retrieve messages")
+	// Start scheduled exports of new messages to the data warehouse bucket if enabled
+	if cfg.ExportEnabled {
+		exporter, err := export.NewExporter(context.Background(), export.Config{
+			Job:         cfg.ExportJob,
+			Schedule:    cfg.ExportSchedule,
+			Format:      cfg.ExportFormat,
+			Destination: cfg.ExportDestination,
+			S3Endpoint:  cfg.ExportS3Endpoint,
+			RowsPerFile: cfg.ExportRowsPerFile,
+		}, core.leases, smsService, services.NewExportRunService(database))
+		if err != nil {
+			logging.Fatal("Failed to initialize exporter", "error", err)
+		}
+		exporter.Start()
+		defer exporter.Stop()
+	}
+
+	// Setup HTTP handlers
+	api, err := core.newAPI(apiServices{
+		audit:     auditService,
+		webhooks:  webhookService,
+		apiKeys:   apiKeyService,
+		usage:     usageService,
+		retention: retentionService,
+		senders:   senderService,
+		blocklist: blocklistService,
+		responses: responseCache,
+		archiving: archiver != nil,
+		search:    searchIndex != nil,
 	})
+	if err != nil {
+		logging.Fatal("Failed to initialize HTTP handlers", "error", err)
+	}
+	authMiddleware := handlers.NewAuth(authenticators...)
+	if usageService != nil {
+		authMiddleware.EnableUsage(usageService, int64(cfg.APIKeyDailyQuota))
+	}
+	routes := api.routes(authMiddleware, modes, healthHandler, app.redactor)
+
+	// Rate limit per client in front of every route: by client IP, or by principal
+	// once authenticated for requests with credentials. The limiter is installed
+	// even while disabled so a reload can enable it
+	rateLimiter := handlers.NewRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst, cfg.RateLimitTrustForwarded)
+	defer rateLimiter.Stop()
+	httpHandler := rateLimiter.Middleware(routes)
+	// Outside the limiter so rate-limited responses are counted and traced too
+	httpHandler = tracing.Middleware(metrics.Middleware(httpHandler))
+	// Preflights are answered before authentication, and every response a browser
+	// may need to read, rate-limited ones included, carries the CORS headers
+	httpHandler = handlers.CORS(handlers.CORSConfig{
+		AllowedOrigins: cfg.CORSAllowedOrigins,
+		AllowedMethods: cfg.CORSAllowedMethods,
+		AllowedHeaders: cfg.CORSAllowedHeaders,
+		MaxAge:         cfg.CORSMaxAgeSeconds,
+	}, httpHandler)
+	if cfg.CompressionEnabled {
+		httpHandler = handlers.Compress(cfg.CompressionMinBytes, httpHandler)
+	}
+	// Outermost so every response, log line, and database call carries the request ID
+	httpHandler = handlers.RequestID(httpHandler)
 
 	// Start HTTP server
 	serverAddr := ":" + cfg.ServerPort
 	server := &http.Server{
-		Addr:         serverAddr,
-		Handler:      nil,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Handler:        httpHandler,
+		ReadTimeout:    time.Duration(cfg.HTTPReadTimeoutSeconds) * time.Second,
+		WriteTimeout:   time.Duration(cfg.HTTPWriteTimeoutSeconds) * time.Second,
+		IdleTimeout:    time.Duration(cfg.HTTPIdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes: cfg.HTTPMaxHeaderBytes,
+	}
+
+	// Serve HTTPS when a certificate is configured, picking up rotated files
+	var certificates *servertls.Certificates
+	if cfg.TLSCertFile != "" {
+		minVersion, _ := servertls.ParseVersion(cfg.TLSMinVersion)
+		certificates, err = servertls.Load(servertls.Config{
+			CertFile:           cfg.TLSCertFile,
+			KeyFile:            cfg.TLSKeyFile,
+			ClientCAFile:       cfg.TLSClientCAFile,
+			ClientAuthOptional: cfg.TLSClientAuth == "optional",
+			MinVersion:         minVersion,
+		})
+		if err != nil {
+			logging.Fatal("Failed to load TLS certificates", "error", err)
+		}
+		certificates.Start(30 * time.Second)
+		defer certificates.Stop()
+		server.TLSConfig = certificates.TLSConfig()
 	}
 
 	// Start server in a goroutine
+	listener, err := handoffs.Listen("http", serverAddr)
+	if err != nil {
+		logging.Fatal("Failed to listen", "port", cfg.ServerPort, "error", err)
+	}
 	go func() {
-		log.Printf("HTTP server listening on port %s", cfg.ServerPort)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+		slog.Info("HTTP server listening", "port", cfg.ServerPort, "tls", certificates != nil,
+			"client_certificates", cfg.TLSClientCAFile != "")
+		var err error
+		if certificates != nil {
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Failed to start server", "error", err)
 		}
 	}()
 
-	// Wait for interrupt signal to gracefully shutdown
+	// Start gRPC server on its own port if enabled
+	var grpcServer *grpcserver.Server
+	if cfg.GRPCPort != "" {
+		grpcServer = grpcserver.NewServer(smsService, auditService, modes, authenticators, cfg.GRPCReflection)
+		listener, err := handoffs.Listen("grpc", ":"+cfg.GRPCPort)
+		if err != nil {
+			logging.Fatal("Failed to start gRPC server", "port", cfg.GRPCPort, "error", err)
+		}
+		grpcServer.Serve(listener)
+	}
+
+	// Reloadable settings are re-read on SIGHUP and POST /admin/reload
+	reload := &reloader{
+		configPath:     app.configPath,
+		current:        cfg,
+		mongo:          database,
+		smsHandler:     api.sms,
+		graphqlHandler: api.graphql,
+		redactor:       app.redactor,
+		rateLimiter:    rateLimiter,
+		cassandraStore: messageStorage.cassandra,
+		searchIndex:    searchIndex,
+		flagger:        flagger,
+		archiver:       archiver,
+		sweeper:        sweeper,
+		certificates:   certificates,
+		modes:          modes,
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			slog.Info("Reloading configuration on SIGHUP")
+			if err := reload.Reload(); err != nil {
+				slog.Error("Configuration reload failed", "error", err)
+			}
+		}
+	}()
+
+	// Start the loopback-only admin server for profiling and consumer control if enabled
+	var adminServer *admin.Server
+	if cfg.AdminPort != "" {
+		// Service-wide stats read the sms_records collection directly
+		var stats admin.StatsFunc
+		if cfg.StorageBackend == store.BackendMongo {
+			stats = database.GetServiceStats
+		}
+		adminServer = admin.NewServer(adminConsumers, stats, quarantine, quotaService, requeueService, modes, reload.Reload)
+		listener, err := handoffs.Listen("admin", admin.Address(cfg.AdminPort))
+		if err != nil {
+			logging.Fatal("Failed to start admin server", "port", cfg.AdminPort, "error", err)
+		}
+		adminServer.Serve(listener)
+	}
+
+	// Serving; after an in-place restart this lets the previous process go
+	if err := handoffs.Ready(); err != nil {
+		slog.Warn("Failed to complete the handoff from the previous process", "error", err)
+	}
+
+	// Wait for interrupt signal to gracefully shutdown, or with handoffs enabled
+	// for SIGUSR2 to restart in place, e.g. after replacing the executable
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	restart := make(chan os.Signal, 1)
+	if cfg.RestartHandoffEnabled {
+		signal.Notify(restart, syscall.SIGUSR2)
+	}
+wait:
+	for {
+		select {
+		case <-quit:
+			break wait
+		case <-restart:
+			slog.Info("Restarting in place on SIGUSR2")
+			released := false
+			err := handoffs.Restart(func() {
+				released = true
+				stopIngestion()
+			})
+			if err == nil {
+				slog.Info("New process is serving; handing over")
+				break wait
+			}
+			slog.Error("In-place restart failed", "error", err)
+			// Without its consumers this process can only shut down
+			if released {
+				break wait
+			}
+		}
+	}
 
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server")
 
-	// Graceful shutdown with 10 second timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Requests in progress get the grace period to finish
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownGraceSeconds)*time.Second)
 	defer cancel()
 
+	if grpcServer != nil {
+		grpcServer.Stop(ctx)
+	}
+	if adminServer != nil {
+		adminServer.Stop(ctx)
+	}
+
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		logging.Fatal("Server forced to shutdown", "error", err)
+	}
+
+	slog.Info("Server exited gracefully")
+}
+
+// consumerDetails reports a consumer's lag, intake and pause state in /readyz
+func consumerDetails(consumer ingest.Ingestor) handlers.ComponentDetails {
+	return func() map[string]any {
+		return map[string]any{
+			"max_partition_lag": consumer.MaxLag(),
+			"in_flight":         consumer.InFlight(),
+			"paused":            consumer.Paused(),
+		}
+	}
+}
+
+// newResponseCache builds the response cache of the routes cfg gives a TTL,
+// or returns nil if it gives none
+func newResponseCache(cfg *config.Config) *cache.Responses {
+	stale := time.Duration(cfg.ResponseCacheStaleSeconds) * time.Second
+	policies := make(map[string]cache.ResponsePolicy)
+	for route, ttl := range map[string]int{
+		handlers.CacheRouteMessages: cfg.ResponseCacheMessagesTTLSeconds,
+		handlers.CacheRouteCount:    cfg.ResponseCacheCountTTLSeconds,
+		handlers.CacheRouteStats:    cfg.ResponseCacheStatsTTLSeconds,
+		handlers.CacheRouteDelivery: cfg.ResponseCacheDeliveryTTLSeconds,
+	} {
+		if ttl > 0 {
+			policies[route] = cache.ResponsePolicy{TTL: time.Duration(ttl) * time.Second, StaleWhileRevalidate: stale}
+		}
 	}
+	if len(policies) == 0 {
+		return nil
+	}
+	return cache.NewResponses(policies)
+}
 
-	log.Println("Server exited gracefully")
+// kafkaSecurity builds the broker TLS and SASL settings from cfg, exiting if they are invalid
+func kafkaSecurity(cfg *config.Config) kafka.Security {
+	var security kafka.Security
+	var err error
+	if cfg.KafkaTLSEnabled {
+		if security.TLS, err = kafka.NewTLSConfig(cfg.KafkaTLSCAFile, cfg.KafkaTLSCertFile, cfg.KafkaTLSKeyFile); err != nil {
+			logging.Fatal("Failed to configure Kafka TLS", "error", err)
+		}
+	}
+	if cfg.KafkaSASLMechanism != "" {
+		if security.SASL, err = kafka.NewSASLMechanism(cfg.KafkaSASLMechanism, cfg.KafkaSASLUsername, cfg.KafkaSASLPassword); err != nil {
+			logging.Fatal("Failed to configure Kafka SASL", "error", err)
+		}
+	}
+	return security
 }
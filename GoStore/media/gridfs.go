@@ -0,0 +1,128 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridFSStore keeps attachments in a GridFS bucket, with their keys as file IDs
+type GridFSStore struct {
+	database func() *mongo.Database
+	name     string
+
+	// bucket is opened on bucketDB, and reopened once database returns another,
+	// i.e. after MongoDB was reconnected
+	mu       sync.Mutex
+	bucketDB *mongo.Database
+	bucket   *gridfs.Bucket
+}
+
+// NewGridFSStore creates a store on the named bucket of the database returned by database
+func NewGridFSStore(database func() *mongo.Database, name string) (*GridFSStore, error) {
+	g := &GridFSStore{database: database, name: name}
+	if _, err := g.currentBucket(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// currentBucket returns the bucket on the current database
+func (g *GridFSStore) currentBucket() (*gridfs.Bucket, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	database := g.database()
+	if g.bucket != nil && g.bucketDB == database {
+		return g.bucket, nil
+	}
+	bucket, err := gridfs.NewBucket(database, options.GridFSBucket().SetName(g.name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GridFS bucket %s: %w", g.name, err)
+	}
+	g.bucketDB, g.bucket = database, bucket
+	return bucket, nil
+}
+
+// Put deletes any file stored under key, or chunks left by an interrupted
+// upload, then uploads data. The bucket's own upload helpers share one buffer,
+// so the upload stream is written directly to keep Put safe for concurrent use
+func (g *GridFSStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	bucket, err := g.currentBucket()
+	if err != nil {
+		return err
+	}
+	if err := bucket.DeleteContext(ctx, key); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return fmt.Errorf("failed to replace media %s: %w", key, err)
+	}
+
+	opts := options.GridFSUpload().SetMetadata(bson.M{"content_type": contentType})
+	stream, err := bucket.OpenUploadStreamWithID(key, key, opts)
+	if err != nil {
+		return fmt.Errorf("failed to upload media %s: %w", key, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetWriteDeadline(deadline)
+	}
+	if _, err := stream.Write(data); err != nil {
+		_ = stream.Abort()
+		return fmt.Errorf("failed to upload media %s: %w", key, err)
+	}
+	if err := stream.Close(); err != nil {
+		return fmt.Errorf("failed to upload media %s: %w", key, err)
+	}
+	return nil
+}
+
+// Open opens a download stream of the file stored under key
+func (g *GridFSStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	bucket, err := g.currentBucket()
+	if err != nil {
+		return nil, err
+	}
+	stream, err := bucket.OpenDownloadStream(key)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media %s: %w", key, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetReadDeadline(deadline)
+	}
+	return stream, nil
+}
+
+// DeletePrefix deletes the files whose IDs start with prefix one by one
+func (g *GridFSStore) DeletePrefix(ctx context.Context, prefix string) error {
+	bucket, err := g.currentBucket()
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}}
+	cursor, err := bucket.FindContext(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find media under %s: %w", prefix, err)
+	}
+	var files []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &files); err != nil {
+		return fmt.Errorf("failed to find media under %s: %w", prefix, err)
+	}
+
+	for _, file := range files {
+		if err := bucket.DeleteContext(ctx, file.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return fmt.Errorf("failed to delete media %s: %w", file.ID, err)
+		}
+	}
+	return nil
+}
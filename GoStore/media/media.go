@@ -0,0 +1,50 @@
+// Package media stores the binary attachments of MMS messages outside the
+// message store, in MongoDB GridFS or S3
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Media backends selectable with MEDIA_BACKEND
+const (
+	BackendGridFS = "gridfs"
+	BackendS3     = "s3"
+)
+
+// ErrNotFound is returned when no attachment is stored under a key
+var ErrNotFound = errors.New("media not found")
+
+// Store keeps attachments by key
+type Store interface {
+	// Put stores data under key, replacing any attachment stored under it
+	Put(ctx context.Context, key, contentType string, data []byte) error
+
+	// Open returns the attachment stored under key, or ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// DeletePrefix removes every attachment whose key starts with prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// Key returns the key of the nth attachment of record: its tenant, user and
+// message_id, or ID when it has none, so every delivery of a message writes the
+// same keys and UserPrefix finds them all
+func Key(record *models.SMSRecord, n int) string {
+	message := record.MessageID
+	if message == "" {
+		message = record.ID.Hex()
+	}
+	return UserPrefix(record.TenantID, record.UserID) + url.PathEscape(message) + "/" + strconv.Itoa(n)
+}
+
+// UserPrefix returns the prefix of the keys of a user's attachments
+func UserPrefix(tenantID, userID string) string {
+	return url.PathEscape(tenantID) + "/" + url.PathEscape(userID) + "/"
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+)
+
+// statusRecorder captures the response status code for instrumentation
+// Unwrap lets http.ResponseController reach the underlying writer for flushing
+// and deadline control on streaming endpoints
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware records request count and latency for every request handled by next
+// Routes are labelled by their ServeMux pattern to keep label cardinality bounded
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		// ServeMux sets the matched pattern on the request it was given
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		ObserveHTTPRequest(r.Method, route, recorder.status, time.Since(start))
+	})
+}
@@ -0,0 +1,400 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric exported by the service
+const namespace = "sms_store"
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route pattern, and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	kafkaMessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_messages_consumed_total",
+		Help:      "Kafka messages fetched, by topic.",
+	}, []string{"topic"})
+
+	kafkaProcessingFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_processing_failures_total",
+		Help:      "Kafka messages whose processing failed and will be redelivered, by topic.",
+	}, []string{"topic"})
+
+	kafkaDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_dead_lettered_total",
+		Help:      "Unprocessable Kafka messages published to the dead-letter topic, by source topic and reason.",
+	}, []string{"topic", "reason"})
+
+	ingestMessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_messages_consumed_total",
+		Help:      "Messages received by an ingestion backend other than Kafka, by backend and source.",
+	}, []string{"backend", "source"})
+
+	ingestProcessingFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_processing_failures_total",
+		Help:      "Messages of an ingestion backend other than Kafka whose processing failed and will be redelivered, by backend and source.",
+	}, []string{"backend", "source"})
+
+	ingestRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_rejected_total",
+		Help:      "Unprocessable messages of an ingestion backend other than Kafka, by backend, source and reason.",
+	}, []string{"backend", "source", "reason"})
+
+	duplicateMessagesSkipped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "duplicate_messages_skipped_total",
+		Help:      "Redelivered SMS events not stored again because their message_id was already stored.",
+	})
+
+	messagesFlagged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_flagged_total",
+		Help:      "Stored SMS messages matching a flag rule, by flag.",
+	}, []string{"flag"})
+
+	messagesBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_blocked_total",
+		Help:      "SMS messages matching their tenant's blocklist, by the kind of entry matched and the action taken.",
+	}, []string{"kind", "action"})
+
+	kafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumer_lag",
+		Help:      "Messages between the last consumed offset and the partition high watermark.",
+	}, []string{"topic", "partition"})
+
+	kafkaConsumerGroupLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumer_group_lag",
+		Help:      "Messages between the consumer group's committed offset and the partition end offset, checked periodically.",
+	}, []string{"group", "topic", "partition"})
+
+	kafkaInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_in_flight_messages",
+		Help:      "Messages fetched by a consumer group and not yet stored.",
+	}, []string{"group"})
+
+	kafkaIntakePauses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_intake_pauses_total",
+		Help:      "Times a consumer group stopped fetching because its in-flight limit was reached.",
+	}, []string{"group"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "User message cache lookups, by result: hit, miss or error.",
+	}, []string{"result"})
+
+	responseCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "response_cache_lookups_total",
+		Help:      "Response cache lookups, by route and result: hit, stale or miss.",
+	}, []string{"route", "result"})
+
+	searchIndexFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "search_index_failures_total",
+		Help:      "Writes of stored messages to the search index that failed; those messages are missing from search results.",
+	})
+
+	mongoCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "MongoDB command latency, by command name, collection and outcome.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"command", "collection", "outcome"})
+
+	mongoPrimaryChanges = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mongo_primary_changes_total",
+		Help:      "Times the MongoDB replica set primary moved to another member, e.g. after a failover.",
+	})
+
+	mongoReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mongo_reconnects_total",
+		Help:      "Times the MongoDB client was replaced after the deployment stayed unreachable, by outcome: succeeded or failed.",
+	}, []string{"outcome"})
+
+	exportRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_runs_total",
+		Help:      "Scheduled export runs, by job and outcome: succeeded or failed.",
+	}, []string{"job", "status"})
+
+	exportRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_records_total",
+		Help:      "Messages written to object storage by scheduled exports, by job.",
+	}, []string{"job"})
+
+	exportLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "export_last_success_timestamp_seconds",
+		Help:      "Unix time the last successful run of a scheduled export finished, by job.",
+	}, []string{"job"})
+
+	retentionRemoved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retention_messages_removed_total",
+		Help:      "Messages removed by the retention sweeper, by action: delete or archive.",
+	}, []string{"action"})
+
+	tieringMoved = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tiering_messages_moved_total",
+		Help:      "Messages moved from sms_records to the sms_records_cold tier.",
+	})
+
+	quotaAlerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "user_quota_alerts_total",
+		Help:      "Users whose stored messages crossed a level of their storage quota, by level: warning or exceeded.",
+	}, []string{"level"})
+
+	quotaRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "user_quota_rejections_total",
+		Help:      "Messages rejected because their user's storage quota was reached.",
+	})
+
+	quotaArchived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "user_quota_messages_archived_total",
+		Help:      "Messages archived to bring users back under their storage quota.",
+	})
+
+	circuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state by dependency: 0 closed, 1 open, 2 half-open.",
+	}, []string{"dependency"})
+
+	circuitBreakerRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_rejections_total",
+		Help:      "Calls failed fast by an open circuit breaker, by dependency.",
+	}, []string{"dependency"})
+
+	leader = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "1 while this instance leads the component elected under the lease, by lease.",
+	}, []string{"lease"})
+
+	serviceMode = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "service_mode",
+		Help:      "1 for the operational mode the instance is in: read_write, read_only or write_only.",
+	}, []string{"mode"})
+)
+
+// Handler returns the /metrics HTTP handler
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveHTTPRequest records a completed HTTP request
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequestsTotal.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// KafkaMessageConsumed counts a message fetched from topic
+func KafkaMessageConsumed(topic string) {
+	kafkaMessagesConsumed.WithLabelValues(topic).Inc()
+}
+
+// KafkaProcessingFailed counts a message from topic that failed processing
+func KafkaProcessingFailed(topic string) {
+	kafkaProcessingFailures.WithLabelValues(topic).Inc()
+}
+
+// KafkaDeadLettered counts a message from topic that was moved to the dead-letter topic
+func KafkaDeadLettered(topic, reason string) {
+	kafkaDeadLettered.WithLabelValues(topic, reason).Inc()
+}
+
+// IngestMessageConsumed counts a message received by backend from source, e.g. a NATS subject
+func IngestMessageConsumed(backend, source string) {
+	ingestMessagesConsumed.WithLabelValues(backend, source).Inc()
+}
+
+// IngestProcessingFailed counts a message of backend from source that failed processing
+func IngestProcessingFailed(backend, source string) {
+	ingestProcessingFailures.WithLabelValues(backend, source).Inc()
+}
+
+// IngestRejected counts an unprocessable message of backend from source
+func IngestRejected(backend, source, reason string) {
+	ingestRejected.WithLabelValues(backend, source, reason).Inc()
+}
+
+// DuplicateMessageSkipped counts a redelivered message that was already stored
+func DuplicateMessageSkipped() {
+	duplicateMessagesSkipped.Inc()
+}
+
+// MessageFlagged counts a stored message flagged by the rule named flag
+func MessageFlagged(flag string) {
+	messagesFlagged.WithLabelValues(flag).Inc()
+}
+
+// MessageBlocked counts a message matching a blocklist entry of the given kind,
+// skipped or flagged as action says
+func MessageBlocked(kind, action string) {
+	messagesBlocked.WithLabelValues(kind, action).Inc()
+}
+
+// SetKafkaLag records the remaining lag of a partition
+func SetKafkaLag(topic string, partition int, lag int64) {
+	if lag < 0 {
+		lag = 0
+	}
+	kafkaConsumerLag.WithLabelValues(topic, strconv.Itoa(partition)).Set(float64(lag))
+}
+
+// SetKafkaGroupLag records the committed lag of a consumer group on a partition
+func SetKafkaGroupLag(group, topic string, partition int, lag int64) {
+	kafkaConsumerGroupLag.WithLabelValues(group, topic, strconv.Itoa(partition)).Set(float64(lag))
+}
+
+// SetKafkaInFlight records the messages a consumer group has fetched and not yet stored
+func SetKafkaInFlight(group string, count int) {
+	kafkaInFlight.WithLabelValues(group).Set(float64(count))
+}
+
+// KafkaIntakePaused counts a consumer group pausing fetches at its in-flight limit
+func KafkaIntakePaused(group string) {
+	kafkaIntakePauses.WithLabelValues(group).Inc()
+}
+
+// CacheLookup counts a user message cache lookup with the given result
+func CacheLookup(result string) {
+	cacheLookups.WithLabelValues(result).Inc()
+}
+
+// ResponseCacheLookup counts a response cache lookup of route with the given result
+func ResponseCacheLookup(route, result string) {
+	responseCacheLookups.WithLabelValues(route, result).Inc()
+}
+
+// SearchIndexFailed counts a failed write to the search index
+func SearchIndexFailed() {
+	searchIndexFailures.Inc()
+}
+
+// ObserveMongoCommand records the duration of a MongoDB command on collection,
+// empty for commands not run on one
+func ObserveMongoCommand(command, collection string, succeeded bool, duration time.Duration) {
+	outcome := "success"
+	if !succeeded {
+		outcome = "failure"
+	}
+	mongoCommandDuration.WithLabelValues(command, collection, outcome).Observe(duration.Seconds())
+}
+
+// MongoPrimaryChanged counts a move of the replica set primary to another member
+func MongoPrimaryChanged() {
+	mongoPrimaryChanges.Inc()
+}
+
+// MongoReconnected counts an attempt at replacing the MongoDB client
+func MongoReconnected(succeeded bool) {
+	outcome := "succeeded"
+	if !succeeded {
+		outcome = "failed"
+	}
+	mongoReconnects.WithLabelValues(outcome).Inc()
+}
+
+// ExportRunFinished records a finished run of the export job and the messages
+// it exported
+func ExportRunFinished(job string, succeeded bool, records int64, finishedAt time.Time) {
+	exportRecords.WithLabelValues(job).Add(float64(records))
+	if !succeeded {
+		exportRuns.WithLabelValues(job, "failed").Inc()
+		return
+	}
+	exportRuns.WithLabelValues(job, "succeeded").Inc()
+	exportLastSuccess.WithLabelValues(job).Set(float64(finishedAt.Unix()))
+}
+
+// RetentionMessagesRemoved counts messages the retention sweeper deleted or archived
+func RetentionMessagesRemoved(action string, count int64) {
+	retentionRemoved.WithLabelValues(action).Add(float64(count))
+}
+
+// TieringMessagesMoved counts messages the tiering job moved to the cold tier
+func TieringMessagesMoved(count int64) {
+	tieringMoved.Add(float64(count))
+}
+
+// UserQuotaAlert counts a user crossing a level of their storage quota
+func UserQuotaAlert(level string) {
+	quotaAlerts.WithLabelValues(level).Inc()
+}
+
+// UserQuotaRejected counts a message rejected over its user's storage quota
+func UserQuotaRejected() {
+	quotaRejections.Inc()
+}
+
+// UserQuotaArchived counts messages archived to bring a user under their storage quota
+func UserQuotaArchived(count int64) {
+	quotaArchived.Add(float64(count))
+}
+
+// SetCircuitBreakerState records the state of the circuit breaker of dependency
+func SetCircuitBreakerState(dependency string, state int) {
+	circuitBreakerState.WithLabelValues(dependency).Set(float64(state))
+}
+
+// CircuitBreakerRejected counts a call failed fast by the circuit breaker of dependency
+func CircuitBreakerRejected(dependency string) {
+	circuitBreakerRejections.WithLabelValues(dependency).Inc()
+}
+
+// SetLeader records whether this instance leads the component elected under lease
+func SetLeader(lease string, leading bool) {
+	value := 0.0
+	if leading {
+		value = 1
+	}
+	leader.WithLabelValues(lease).Set(value)
+}
+
+// SetServiceMode records whether the instance is in the operational mode
+func SetServiceMode(mode string, current bool) {
+	value := 0.0
+	if current {
+		value = 1
+	}
+	serviceMode.WithLabelValues(mode).Set(value)
+}
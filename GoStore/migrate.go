@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/logging"
+	"github.com/spf13/cobra"
+)
+
+// newMigrateCommand returns the migrate command, which applies, reverts or
+// lists the MongoDB migrations, for deployments that run them as a separate
+// step with MONGO_MIGRATE_ON_START=false. It can run while the service is up
+func newMigrateCommand(app *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply, revert or list the MongoDB migrations",
+	}
+
+	var to int
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Apply the pending migrations in version order",
+		Args:  cobra.NoArgs,
+		Run: func(*cobra.Command, []string) {
+			app.migrate(func(ctx context.Context, m *db.Mongo, env db.MigrationEnv) {
+				applied, err := m.MigrateUp(ctx, env, to)
+				if err != nil {
+					logging.Fatal("Migration failed", "applied", applied, "error", err)
+				}
+				slog.Info("MongoDB migrations applied", "applied", applied)
+			})
+		},
+	}
+	up.Flags().IntVar(&to, "to", 0, "apply the pending migrations up to this version only")
+
+	var steps int
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Revert the last applied migrations, newest first",
+		Args:  cobra.NoArgs,
+		Run: func(*cobra.Command, []string) {
+			if steps < 1 {
+				logging.Fatal("--steps must be at least 1")
+			}
+			app.migrate(func(ctx context.Context, m *db.Mongo, env db.MigrationEnv) {
+				reverted, err := m.MigrateDown(ctx, env, steps)
+				if err != nil {
+					logging.Fatal("Migration revert failed", "reverted", reverted, "error", err)
+				}
+				slog.Info("MongoDB migrations reverted", "reverted", reverted)
+			})
+		},
+	}
+	down.Flags().IntVar(&steps, "steps", 1, "number of applied migrations to revert")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "List each migration with the time it was applied",
+		Args:  cobra.NoArgs,
+		Run: func(*cobra.Command, []string) {
+			app.migrate(func(ctx context.Context, m *db.Mongo, _ db.MigrationEnv) {
+				states, err := m.MigrationStatus(ctx)
+				if err != nil {
+					logging.Fatal("Failed to read migration status", "error", err)
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "VERSION\tAPPLIED\tDESCRIPTION")
+				for _, state := range states {
+					applied := "pending"
+					if !state.AppliedAt.IsZero() {
+						applied = state.AppliedAt.Format(time.RFC3339)
+					}
+					fmt.Fprintf(w, "%d\t%s\t%s\n", state.Version, applied, state.Description)
+				}
+				w.Flush()
+			})
+		},
+	}
+
+	cmd.AddCommand(up, down, status)
+	return cmd
+}
+
+// migrate connects to MongoDB and runs action, allowing it an hour
+func (app *cli) migrate(action func(ctx context.Context, m *db.Mongo, env db.MigrationEnv)) {
+	m := app.connectMongo()
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	action(ctx, m, db.MigrationEnv{DefaultTenantID: app.cfg.DefaultTenantID})
+}
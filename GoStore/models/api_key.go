@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// API key scopes; admin implies every other scope
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+	ScopeAdmin  = "admin"
+)
+
+// APIKey is a hashed API key bound to a tenant
+// The raw key is only ever returned once, when the key is created
+type APIKey struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name     string             `bson:"name" json:"name"`
+	TenantID string             `bson:"tenant_id" json:"tenant_id"`
+	Prefix   string             `bson:"prefix" json:"prefix"` // First characters of the raw key, for identification
+	KeyHash  string             `bson:"key_hash" json:"-"`
+	Scopes   []string           `bson:"scopes" json:"scopes"`
+	// DailyQuota caps the key's requests per UTC day; zero applies the service default
+	DailyQuota int64     `bson:"daily_quota,omitempty" json:"daily_quota,omitempty"`
+	CreatedAt  time.Time `bson:"created_at" json:"created_at"`
+	RevokedAt  time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitzero"`
+}
+
+// HasScope reports whether the key grants scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the key has a name and only known scopes
+func (k *APIKey) Validate() error {
+	if k.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if k.DailyQuota < 0 {
+		return fmt.Errorf("daily_quota must not be negative")
+	}
+	if len(k.Scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, s := range k.Scopes {
+		switch s {
+		case ScopeRead, ScopeWrite, ScopeDelete, ScopeAdmin:
+		default:
+			return fmt.Errorf("unknown scope: %q", s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// UsageDayFormat is the layout of APIKeyUsage.Day
+const UsageDayFormat = "2006-01-02"
+
+// APIKeyUsage counts what an API key was used for during one UTC day
+type APIKeyUsage struct {
+	ID        string    `bson:"_id" json:"-"` // key ID and day, so each day has one document
+	KeyID     string    `bson:"key_id" json:"key_id"`
+	TenantID  string    `bson:"tenant_id" json:"tenant_id"`
+	Day       string    `bson:"day" json:"day"`
+	Requests  int64     `bson:"requests" json:"requests"` // authenticated requests, those rejected over quota included
+	Bytes     int64     `bson:"bytes" json:"bytes"`       // response body bytes written, before compression
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions recorded in the audit log
+const (
+	AuditActionEraseUserMessages = "ERASE_USER_MESSAGES"
+	AuditActionReadMessages      = "READ_MESSAGES"
+	AuditActionQueryMessages     = "QUERY_MESSAGES"
+	AuditActionSearchMessages    = "SEARCH_MESSAGES"
+	AuditActionExportMessages    = "EXPORT_MESSAGES"
+	AuditActionStreamMessages    = "STREAM_MESSAGES"
+	AuditActionReadConversations = "READ_CONVERSATIONS"
+	AuditActionReadStats         = "READ_STATS"
+	AuditActionCountMessages     = "COUNT_MESSAGES"
+	AuditActionReadMedia         = "READ_MEDIA"
+	AuditActionReadDuplicates    = "READ_DUPLICATES"
+	AuditActionReadAnalytics     = "READ_ANALYTICS"
+	AuditActionRegisterWebhook   = "REGISTER_WEBHOOK"
+	AuditActionUpdateWebhook     = "UPDATE_WEBHOOK"
+	AuditActionRotateWebhook     = "ROTATE_WEBHOOK_SECRET"
+	AuditActionDeleteWebhook     = "DELETE_WEBHOOK"
+	AuditActionCreateAPIKey      = "CREATE_API_KEY"
+	AuditActionRevokeAPIKey      = "REVOKE_API_KEY"
+	AuditActionReadAPIKeyUsage   = "READ_API_KEY_USAGE"
+	AuditActionResetAPIKeyUsage  = "RESET_API_KEY_USAGE"
+	AuditActionReadAuditLog      = "READ_AUDIT_LOG"
+	AuditActionSetRetention      = "SET_RETENTION_POLICY"
+	AuditActionDeleteRetention   = "DELETE_RETENTION_POLICY"
+	AuditActionRegisterSender    = "REGISTER_SENDER"
+	AuditActionUpdateSender      = "UPDATE_SENDER"
+	AuditActionDeleteSender      = "DELETE_SENDER"
+	AuditActionAddBlockEntry     = "ADD_BLOCKLIST_ENTRY"
+	AuditActionUpdateBlockEntry  = "UPDATE_BLOCKLIST_ENTRY"
+	AuditActionDeleteBlockEntry  = "DELETE_BLOCKLIST_ENTRY"
+	AuditActionRequeueMessage    = "REQUEUE_MESSAGE"
+)
+
+// AuditRecord represents an entry in the audit_log collection
+type AuditRecord struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Action      string             `bson:"action" json:"action"`
+	TenantID    string             `bson:"tenant_id" json:"tenant_id"`
+	Actor       string             `bson:"actor,omitempty" json:"actor,omitempty"`             // subject of the API key or JWT; empty without authentication
+	AuthMethod  string             `bson:"auth_method,omitempty" json:"auth_method,omitempty"` // e.g. "api_key" or "jwt"
+	Endpoint    string             `bson:"endpoint,omitempty" json:"endpoint,omitempty"`       // route pattern, e.g. "GET /v1/user/{user_id}/messages"
+	Filters     map[string]string  `bson:"filters,omitempty" json:"filters,omitempty"`         // query parameters of the request
+	UserID      string             `bson:"user_id" json:"user_id"`                             // whose messages were accessed, if one user's
+	PhoneNumber string             `bson:"phone_number,omitempty" json:"phone_number,omitempty"`
+	TargetID    string             `bson:"target_id,omitempty" json:"target_id,omitempty"` // webhook or API key managed, or message read
+	ResultCount int64              `bson:"result_count" json:"result_count"`
+	RemoteAddr  string             `bson:"remote_addr,omitempty" json:"remote_addr,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// AuditQuery selects a page of a tenant's audit records, newest first
+// Empty fields and zero times do not filter
+type AuditQuery struct {
+	Actor    string
+	UserID   string
+	Action   string
+	TargetID string
+	Since    time.Time // inclusive
+	Until    time.Time // exclusive
+	Skip     int64
+	Limit    int64 // zero is unlimited
+}
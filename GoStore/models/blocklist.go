@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of blocklist entries, by what they match of a message
+const (
+	BlockKindPhoneNumber = "phone_number" // the user ID or phone number of the message
+	BlockKindPrefix      = "prefix"       // the leading digits of either, e.g. +4470
+	BlockKindCountryCode = "country_code" // the ISO 3166-1 alpha-2 country of the message
+)
+
+// What happens to a message matching the blocklist of its tenant
+const (
+	BlocklistActionSkip = "skip" // acknowledged without being stored
+	BlocklistActionFlag = "flag" // stored with FlagBlocked
+)
+
+// FlagBlocked is the flag of messages stored despite matching the blocklist
+const FlagBlocked = "blocked"
+
+// prefixPattern matches the leading digits of a phone number, with an optional +
+var prefixPattern = regexp.MustCompile(`^\+?[0-9]{1,14}$`)
+
+// IsValidBlockKind reports whether kind is a known blocklist entry kind
+func IsValidBlockKind(kind string) bool {
+	return kind == BlockKindPhoneNumber || kind == BlockKindPrefix || kind == BlockKindCountryCode
+}
+
+// IsValidBlocklistAction reports whether action is a known blocklist action
+func IsValidBlocklistAction(action string) bool {
+	return action == BlocklistActionSkip || action == BlocklistActionFlag
+}
+
+// BlockEntry blocks the messages of its tenant from a phone number, a range
+// of numbers or a country
+type BlockEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID  string             `bson:"tenant_id" json:"tenant_id"`
+	Kind      string             `bson:"kind" json:"kind"`
+	Value     string             `bson:"value" json:"value"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Validate checks that the value of the entry has the form of its kind
+func (e *BlockEntry) Validate() error {
+	if len(e.Reason) > 256 {
+		return fmt.Errorf("reason must be at most 256 characters")
+	}
+	switch e.Kind {
+	case BlockKindPhoneNumber:
+		if !IsValidPhoneNumber(e.Value) {
+			return fmt.Errorf("invalid value format, expected phone number")
+		}
+	case BlockKindPrefix:
+		if !prefixPattern.MatchString(e.Value) {
+			return fmt.Errorf("invalid value format, expected 1-14 leading digits of a phone number, e.g. +4470")
+		}
+	case BlockKindCountryCode:
+		if !IsValidCountryCode(e.Value) {
+			return fmt.Errorf("invalid value format, expected ISO 3166-1 alpha-2 code, e.g. US")
+		}
+	default:
+		return fmt.Errorf("kind must be %s, %s or %s", BlockKindPhoneNumber, BlockKindPrefix, BlockKindCountryCode)
+	}
+	return nil
+}
+
+// Matches reports whether the entry blocks record. Numbers are compared
+// without their leading +
+func (e *BlockEntry) Matches(record *SMSRecord) bool {
+	if e.Kind == BlockKindCountryCode {
+		return record.CountryCode == e.Value
+	}
+	value := strings.TrimPrefix(e.Value, "+")
+	for _, number := range []string{record.UserID, record.PhoneNumber} {
+		number = strings.TrimPrefix(number, "+")
+		if number == "" {
+			continue
+		}
+		if number == value || (e.Kind == BlockKindPrefix && strings.HasPrefix(number, value)) {
+			return true
+		}
+	}
+	return false
+}
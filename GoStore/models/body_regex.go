@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+)
+
+// MaxBodyRegexLength is the longest pattern accepted by the body_regex filter
+const MaxBodyRegexLength = 64
+
+// maxBodyRegexUnbounded is the most unbounded repetitions, such as .*, in a
+// body_regex pattern; each multiplies the work of a failing match by the
+// length of the body
+const maxBodyRegexUnbounded = 2
+
+// ValidateBodyRegex checks that a body_regex pattern is cheap to run on the
+// database: short, anchored to the start of the body with ^ (after any flags,
+// e.g. (?i)^.*refund), with at most two unbounded repetitions, and without
+// repeated repetitions or alternations such as (a+)+ or (a|ab)+, which
+// backtracking engines take exponential time to fail on. Patterns use the
+// syntax shared by Go and PCRE, so stores filtering in memory match what
+// MongoDB matches
+func ValidateBodyRegex(pattern string) error {
+	if len(pattern) > MaxBodyRegexLength {
+		return fmt.Errorf("pattern is longer than %d characters", MaxBodyRegexLength)
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	first := re
+	if re.Op == syntax.OpConcat {
+		first = re.Sub[0]
+	}
+	if first.Op != syntax.OpBeginText {
+		return errors.New("pattern must start with ^")
+	}
+	if repeatsAmbiguously(re, false) {
+		return errors.New("pattern must not repeat a repetition or an alternation")
+	}
+	if unboundedRepetitions(re) > maxBodyRegexUnbounded {
+		return fmt.Errorf("pattern must have at most %d unbounded repetitions such as .* or +", maxBodyRegexUnbounded)
+	}
+	return nil
+}
+
+// repeatsAmbiguously reports whether re has a repetition or alternation inside
+// a repetition, or inside one itself when repeated is set
+func repeatsAmbiguously(re *syntax.Regexp, repeated bool) bool {
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		if repeated {
+			return true
+		}
+		repeated = true
+	case syntax.OpAlternate:
+		if repeated {
+			return true
+		}
+	}
+	for _, sub := range re.Sub {
+		if repeatsAmbiguously(sub, repeated) {
+			return true
+		}
+	}
+	return false
+}
+
+// unboundedRepetitions counts the repetitions in re without an upper bound
+func unboundedRepetitions(re *syntax.Regexp) int {
+	count := 0
+	if re.Op == syntax.OpStar || re.Op == syntax.OpPlus || (re.Op == syntax.OpRepeat && re.Max == -1) {
+		count++
+	}
+	for _, sub := range re.Sub {
+		count += unboundedRepetitions(sub)
+	}
+	return count
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Conversation summarizes a user's messages with one counterpart phone number
+// Its BSON names are those of the MongoDB aggregation that groups them
+type Conversation struct {
+	Peer          string    `bson:"_id" json:"peer"` // the phone_number of its messages
+	MessageCount  int64     `bson:"message_count" json:"message_count"`
+	LastMessageAt time.Time `bson:"last_message_at" json:"last_message_at"`
+	LastMessage   string    `bson:"last_message" json:"last_message"`
+	LastStatus    string    `bson:"last_status" json:"last_status"`
+	LastDirection string    `bson:"last_direction" json:"last_direction"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeliveryReceipt represents a carrier delivery receipt (DLR) posted over HTTP
+type DeliveryReceipt struct {
+	ProviderMessageID string    `json:"provider_message_id"`
+	Status            string    `json:"status"`
+	ErrorCode         string    `json:"error_code,omitempty"`
+	DeliveredAt       time.Time `json:"delivered_at,omitzero"` // RFC3339; defaults to receipt time
+}
+
+// Validate checks that the receipt identifies a message and carries a known status
+func (r *DeliveryReceipt) Validate() error {
+	if r.ProviderMessageID == "" {
+		return fmt.Errorf("provider_message_id is required")
+	}
+	switch r.Status {
+	case StatusSent, StatusDelivered, StatusFailed:
+		return nil
+	default:
+		return fmt.Errorf("unknown status: %q", r.Status)
+	}
+}
+
+// ToStatusChange converts the receipt into a status history entry
+func (r *DeliveryReceipt) ToStatusChange() StatusChange {
+	changedAt := r.DeliveredAt
+	if changedAt.IsZero() {
+		changedAt = time.Now()
+	}
+	return StatusChange{
+		Status:    r.Status,
+		Reason:    r.ErrorCode,
+		ChangedAt: changedAt.UTC(),
+	}
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// Widths of the time buckets delivery stats are counted in
+const (
+	DeliveryIntervalHour = "hour"
+	DeliveryIntervalDay  = "day"
+)
+
+// IsValidDeliveryInterval reports whether interval is a known bucket width
+func IsValidDeliveryInterval(interval string) bool {
+	return interval == DeliveryIntervalHour || interval == DeliveryIntervalDay
+}
+
+// DeliveryQuery selects the outbound messages whose delivery outcomes are counted
+type DeliveryQuery struct {
+	Since       time.Time // inclusive
+	Until       time.Time // exclusive
+	Interval    string    // width of the time buckets, in UTC
+	SenderID    string    // empty matches all
+	Carrier     string    // empty matches all
+	CountryCode string    // empty matches all
+	DialCode    string    // empty matches all
+}
+
+// DeliveryStats counts the delivery outcomes of a tenant's outbound messages by
+// sender ID, by carrier, by country, and by time bucket. Messages without a
+// sender ID, carrier or country are counted under an empty one
+type DeliveryStats struct {
+	DeliveryCounts
+	BySenderID []SenderDelivery  `json:"by_sender_id"` // most messages first
+	ByCarrier  []CarrierDelivery `json:"by_carrier"`   // most messages first
+	ByCountry  []CountryDelivery `json:"by_country"`   // most messages first
+	ByPeriod   []PeriodDelivery  `json:"by_period"`    // oldest first; buckets without messages are left out
+}
+
+// DeliveryCounts is how many messages were sent, and how many of them have been
+// delivered, have failed, or are still waiting for a receipt
+type DeliveryCounts struct {
+	Total        int64   `json:"total"`
+	Delivered    int64   `json:"delivered"`
+	Failed       int64   `json:"failed"`
+	Pending      int64   `json:"pending"`
+	DeliveryRate float64 `json:"delivery_rate"` // delivered share of the total, 0 to 1
+	FailureRate  float64 `json:"failure_rate"`  // failed share of the total, 0 to 1
+}
+
+// Add counts count messages with status
+func (c *DeliveryCounts) Add(status string, count int64) {
+	c.Total += count
+	switch status {
+	case StatusDelivered:
+		c.Delivered += count
+	case StatusFailed:
+		c.Failed += count
+	default:
+		c.Pending += count
+	}
+	c.DeliveryRate = float64(c.Delivered) / float64(c.Total)
+	c.FailureRate = float64(c.Failed) / float64(c.Total)
+}
+
+// SenderDelivery counts the delivery outcomes of the messages of a sender ID
+type SenderDelivery struct {
+	SenderID string `json:"sender_id"`
+	DeliveryCounts
+}
+
+// CarrierDelivery counts the delivery outcomes of the messages to a carrier
+type CarrierDelivery struct {
+	Carrier string `json:"carrier"`
+	DeliveryCounts
+}
+
+// CountryDelivery counts the delivery outcomes of the messages to a country
+type CountryDelivery struct {
+	CountryCode string `json:"country_code"`
+	DeliveryCounts
+}
+
+// PeriodDelivery counts the delivery outcomes of the messages created in a
+// time bucket, in total and by carrier
+type PeriodDelivery struct {
+	Start time.Time `json:"start"`
+	DeliveryCounts
+	ByCarrier []CarrierDelivery `json:"by_carrier"` // most messages first
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Kinds of DuplicateGroup
+const (
+	DuplicateByMessageID = "message_id" // records sharing a message_id
+	DuplicateByContent   = "content"    // records sharing a user_id, body and created_at
+)
+
+// DuplicateGroup is a set of stored records that look like copies of one
+// message, as a producer retrying a send would leave behind
+type DuplicateGroup struct {
+	Kind      string    `json:"kind"`
+	MessageID string    `json:"message_id,omitempty"` // of a DuplicateByMessageID group
+	UserID    string    `json:"user_id"`              // of the first record stored
+	CreatedAt time.Time `json:"created_at"`           // of the earliest created record
+	Count     int64     `json:"count"`
+	IDs       []string  `json:"ids"` // of up to MaxDuplicateIDs of the records, in the order stored
+}
+
+// MaxDuplicateIDs caps the record IDs listed per DuplicateGroup
+const MaxDuplicateIDs = 20
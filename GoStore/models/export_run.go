@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Outcomes of an export run
+const (
+	ExportRunning   = "running"
+	ExportSucceeded = "succeeded"
+	ExportFailed    = "failed"
+)
+
+// ExportRun records one run of a scheduled export job. A run exports the
+// messages whose IDs are after After and before Before; Before of the last
+// successful run is the watermark the next run starts from
+type ExportRun struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Job        string             `bson:"job" json:"job"`
+	Status     string             `bson:"status" json:"status"`
+	After      primitive.ObjectID `bson:"after" json:"after"`
+	Before     primitive.ObjectID `bson:"before" json:"before"`
+	Records    int64              `bson:"records" json:"records"`
+	Bytes      int64              `bson:"bytes" json:"bytes"`
+	Files      []string           `bson:"files,omitempty" json:"files,omitempty"` // object URLs written
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt  time.Time          `bson:"started_at" json:"started_at"`
+	FinishedAt time.Time          `bson:"finished_at,omitempty" json:"finished_at,omitzero"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"mime"
+)
+
+// MaxMediaAttachments caps the attachments of one message
+const MaxMediaAttachments = 10
+
+// MediaAttachment describes a binary attachment of an MMS message. The data is
+// kept in the media store, not on the record
+type MediaAttachment struct {
+	ContentType string `bson:"content_type" json:"content_type"`
+	FileName    string `bson:"file_name,omitempty" json:"file_name,omitempty"`
+	Size        int64  `bson:"size" json:"size"`
+
+	// Set from ingestion until the attachment is written to the media store
+	Data []byte `bson:"-" json:"-"`
+}
+
+// KafkaMedia is an attachment of an MMS event; in JSON its data is base64-encoded
+type KafkaMedia struct {
+	ContentType string `json:"contentType"`
+	FileName    string `json:"fileName,omitempty"`
+	Data        []byte `json:"data"`
+}
+
+// validateMedia checks that every attachment has data and a valid content type
+func validateMedia(media []KafkaMedia) error {
+	if len(media) > MaxMediaAttachments {
+		return fmt.Errorf("at most %d media attachments are allowed, got %d", MaxMediaAttachments, len(media))
+	}
+	for n, attachment := range media {
+		if _, _, err := mime.ParseMediaType(attachment.ContentType); err != nil {
+			return fmt.Errorf("media %d: invalid contentType %q", n, attachment.ContentType)
+		}
+		if len(attachment.Data) == 0 {
+			return fmt.Errorf("media %d: data is required", n)
+		}
+	}
+	return nil
+}
+
+// toAttachments converts the attachments of an event to those of its record
+func toAttachments(media []KafkaMedia) []MediaAttachment {
+	if len(media) == 0 {
+		return nil
+	}
+	attachments := make([]MediaAttachment, len(media))
+	for n, m := range media {
+		attachments[n] = MediaAttachment{
+			ContentType: m.ContentType,
+			FileName:    m.FileName,
+			Size:        int64(len(m.Data)),
+			Data:        m.Data,
+		}
+	}
+	return attachments
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"regexp"
+	"slices"
+	"time"
+)
+
+// MessageQuery describes a filtered, paginated lookup of a user's messages
+type MessageQuery struct {
+	UserID      string
+	Statuses    []string  // Match any of these statuses; empty matches all
+	PhoneNumber string    // Match messages to or from this number only; empty matches all
+	Since       time.Time // Inclusive lower bound on created_at; zero means unbounded
+	Until       time.Time // Exclusive upper bound on created_at; zero means unbounded
+	Skip        int64
+	Limit       int64 // 0 means no limit
+	Sort        MessageSort
+	MessageFilter
+
+	// JSON names of the record fields to read; empty reads whole records. Backends
+	// that cannot project read whole records anyway
+	Fields []string
+}
+
+// MessageFilter narrows a lookup to messages with the given metadata; empty
+// fields match all
+type MessageFilter struct {
+	Carrier      string
+	CountryCode  string
+	DialCode     string // country calling code, without the +
+	SenderID     string
+	SenderType   string // matches messages from registered senders of this type
+	Registered   bool   // matches messages from any registered sender
+	Direction    string // outbound also matches records stored without a direction
+	SegmentCount int
+	Language     string
+	Flag         string // matches messages flagged by this rule
+	Flagged      bool   // matches messages flagged by any rule
+	BodyRegex    string // matches message bodies; checked by ValidateBodyRegex
+}
+
+// IsZero reports whether the filter matches every message
+func (f MessageFilter) IsZero() bool {
+	return f == MessageFilter{}
+}
+
+// Matches reports whether record has the metadata the filter asks for
+func (f MessageFilter) Matches(record *SMSRecord) bool {
+	if f.Carrier != "" && record.Carrier != f.Carrier {
+		return false
+	}
+	if f.CountryCode != "" && record.CountryCode != f.CountryCode {
+		return false
+	}
+	if f.DialCode != "" && record.DialCode != f.DialCode {
+		return false
+	}
+	if f.SenderID != "" && record.SenderID != f.SenderID {
+		return false
+	}
+	if f.SenderType != "" && record.SenderType != f.SenderType {
+		return false
+	}
+	if f.Registered && record.SenderType == "" {
+		return false
+	}
+	if f.Direction != "" {
+		direction := record.Direction
+		if direction == "" {
+			direction = DirectionOutbound
+		}
+		if direction != f.Direction {
+			return false
+		}
+	}
+	if f.SegmentCount > 0 && record.SegmentCount != f.SegmentCount {
+		return false
+	}
+	if f.Language != "" && record.Language != f.Language {
+		return false
+	}
+	if f.Flag != "" && !slices.Contains(record.Flags, f.Flag) {
+		return false
+	}
+	if f.Flagged && len(record.Flags) == 0 {
+		return false
+	}
+	if f.BodyRegex != "" {
+		if matched, _ := regexp.MatchString(f.BodyRegex, record.Message); !matched {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Fields message results can be sorted by, each backed by an index
+const (
+	SortCreatedAt = "created_at"
+	SortStatus    = "status"
+	SortSender    = "sender" // the phone_number of the message
+)
+
+// MessageSort orders message results by a field, then newest first. The zero
+// value sorts newest first
+type MessageSort struct {
+	Field     string
+	Ascending bool
+}
+
+// IsDefault reports whether s is the newest-first order
+func (s MessageSort) IsDefault() bool {
+	return (s.Field == "" || s.Field == SortCreatedAt) && !s.Ascending
+}
+
+// Column is the stored field, and the SQL column, s sorts by
+func (s MessageSort) Column() string {
+	switch s.Field {
+	case SortStatus:
+		return "status"
+	case SortSender:
+		return "phone_number"
+	}
+	return "created_at"
+}
+
+// String formats s as field:asc or field:desc
+func (s MessageSort) String() string {
+	field := s.Field
+	if field == "" {
+		field = SortCreatedAt
+	}
+	if s.Ascending {
+		return field + ":asc"
+	}
+	return field + ":desc"
+}
+
+// ParseMessageSort parses field or field:asc|desc; the direction defaults to
+// ascending for every field but created_at
+func ParseMessageSort(value string) (MessageSort, error) {
+	field, direction, hasDirection := strings.Cut(value, ":")
+	if field != SortCreatedAt && field != SortStatus && field != SortSender {
+		return MessageSort{}, fmt.Errorf("invalid sort field %q, expected %s, %s or %s", field, SortCreatedAt, SortStatus, SortSender)
+	}
+
+	sort := MessageSort{Field: field, Ascending: field != SortCreatedAt}
+	if hasDirection {
+		switch direction {
+		case "asc":
+			sort.Ascending = true
+		case "desc":
+			sort.Ascending = false
+		default:
+			return MessageSort{}, fmt.Errorf("invalid sort direction %q, expected asc or desc", direction)
+		}
+	}
+	return sort, nil
+}
+
+// SortRecords sorts records in place, for backends that cannot sort in the query
+func SortRecords(records []*SMSRecord, sort MessageSort) {
+	slices.SortStableFunc(records, func(a, b *SMSRecord) int { return CompareRecords(a, b, sort) })
+}
+
+// CompareRecords orders a before b if it comes first in sort
+func CompareRecords(a, b *SMSRecord, sort MessageSort) int {
+	var order int
+	switch sort.Field {
+	case SortStatus:
+		order = cmp.Compare(a.Status, b.Status)
+	case SortSender:
+		order = cmp.Compare(a.PhoneNumber, b.PhoneNumber)
+	default:
+		order = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if !sort.Ascending {
+		order = -order
+	}
+	if order == 0 && sort.Field != "" && sort.Field != SortCreatedAt {
+		// Newest first within a status or sender
+		order = b.CreatedAt.Compare(a.CreatedAt)
+	}
+	return order
+}
@@ -0,0 +1,19 @@
+package models
+
+// MessageStats counts a user's messages along several dimensions
+type MessageStats struct {
+	Total        int64            `json:"total"`
+	BillingUnits int64            `json:"billing_units"` // SMS segments; messages stored without a segment count are one each
+	ByStatus     map[string]int64 `json:"by_status"`
+	ByDirection  map[string]int64 `json:"by_direction"` // outbound messages are sent by the service, inbound by the user
+	ByDay        []PeriodCount    `json:"by_day"`       // oldest first
+	ByWeek       []PeriodCount    `json:"by_week"`      // oldest first; weeks start on Monday
+}
+
+// PeriodCount is the number of messages created in a UTC day or week, and the
+// billing units they took
+type PeriodCount struct {
+	Start        string `json:"start"` // YYYY-MM-DD
+	Count        int64  `json:"count"`
+	BillingUnits int64  `json:"billing_units"`
+}
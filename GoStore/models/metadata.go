@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Directions of an SMS event as producers name them: mobile originated messages
+// are received from the user, mobile terminated ones are sent to the user
+const (
+	EventDirectionMO = "MO"
+	EventDirectionMT = "MT"
+)
+
+// maxMetadataLength caps the carrier and sender ID of an event
+const maxMetadataLength = 64
+
+// countryCodePattern matches ISO 3166-1 alpha-2 country codes
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// dialCodePattern matches country calling codes without the +
+var dialCodePattern = regexp.MustCompile(`^[1-9][0-9]{0,2}$`)
+
+// languagePattern matches ISO 639 language codes
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// IsValidLanguage reports whether code is an ISO 639 language code, e.g. en
+func IsValidLanguage(code string) bool {
+	return languagePattern.MatchString(code)
+}
+
+// IsValidCountryCode reports whether code is an ISO 3166-1 alpha-2 country code, e.g. US
+func IsValidCountryCode(code string) bool {
+	return countryCodePattern.MatchString(code)
+}
+
+// IsValidDialCode reports whether code is a country calling code without the +, e.g. 91
+func IsValidDialCode(code string) bool {
+	return dialCodePattern.MatchString(code)
+}
+
+// IsValidDirection reports whether direction is one a record is stored with
+func IsValidDirection(direction string) bool {
+	return direction == DirectionOutbound || direction == DirectionInbound
+}
+
+// validateMetadata checks the optional carrier, country, sender, segment count
+// and direction of an event
+func (k *KafkaEvent) validateMetadata() error {
+	if len(k.Carrier) > maxMetadataLength {
+		return fmt.Errorf("carrier must be at most %d characters", maxMetadataLength)
+	}
+	if len(k.SenderID) > maxMetadataLength {
+		return fmt.Errorf("senderId must be at most %d characters", maxMetadataLength)
+	}
+	if k.CountryCode != "" && !IsValidCountryCode(k.CountryCode) {
+		return fmt.Errorf("invalid countryCode: %q", k.CountryCode)
+	}
+	if k.SegmentCount < 0 {
+		return fmt.Errorf("segmentCount must not be negative")
+	}
+	switch k.Direction {
+	case "", EventDirectionMO, EventDirectionMT:
+		return nil
+	default:
+		return fmt.Errorf("unknown direction: %q", k.Direction)
+	}
+}
+
+// recordDirection returns the stored direction of the event, or "" when the
+// event does not set one and the topic it came from decides
+func (k *KafkaEvent) recordDirection() string {
+	switch k.Direction {
+	case EventDirectionMO:
+		return DirectionInbound
+	case EventDirectionMT:
+		return DirectionOutbound
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QuarantinedMessage is a consumed message that could not be decoded or failed
+// validation, kept with its raw bytes so operators can inspect it, and reprocess
+// it once its producer or the consumer is fixed. Key, Payload and header values
+// are served base64-encoded
+type QuarantinedMessage struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Topic         string             `bson:"topic" json:"topic"`
+	Partition     int                `bson:"partition" json:"partition"`
+	Offset        int64              `bson:"offset" json:"offset"`
+	GroupID       string             `bson:"group_id" json:"group_id"`
+	Key           []byte             `bson:"key,omitempty" json:"key,omitempty"`
+	Payload       []byte             `bson:"payload" json:"payload"`
+	Headers       []MessageHeader    `bson:"headers,omitempty" json:"headers,omitempty"`
+	Reason        string             `bson:"reason" json:"reason"` // as dead-lettered, e.g. malformed
+	Error         string             `bson:"error" json:"error"`
+	QuarantinedAt time.Time          `bson:"quarantined_at" json:"quarantined_at"`
+}
+
+// MessageHeader is a header of a quarantined message
+type MessageHeader struct {
+	Key   string `bson:"key" json:"key"`
+	Value []byte `bson:"value" json:"value"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "slices"
+
+// RecordFields are the JSON names of the SMSRecord fields that can be selected
+// with a field projection
+var RecordFields = []string{
+	"id", "message_id", "provider_message_id", "tenant_id", "user_id", "phone_number",
+	"raw_user_id", "raw_phone_number", "message", "status", "direction", "carrier", "country_code", "dial_code", "sender_id", "sender_type", "segment_count",
+	"encoding", "script", "language", "flags",
+	"status_history", "media", "created_at", "updated_at",
+}
+
+// IsRecordField reports whether name is the JSON name of a selectable SMSRecord field
+func IsRecordField(name string) bool {
+	return slices.Contains(RecordFields, name)
+}
+
+// Project returns the record's fields named in fields, by JSON name, for encoding
+// as a partial record. Unknown names are skipped
+func (r *SMSRecord) Project(fields []string) map[string]any {
+	projected := make(map[string]any, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "id":
+			projected[field] = r.ID
+		case "message_id":
+			projected[field] = r.MessageID
+		case "provider_message_id":
+			projected[field] = r.ProviderMessageID
+		case "tenant_id":
+			projected[field] = r.TenantID
+		case "user_id":
+			projected[field] = r.UserID
+		case "phone_number":
+			projected[field] = r.PhoneNumber
+		case "raw_user_id":
+			projected[field] = r.RawUserID
+		case "raw_phone_number":
+			projected[field] = r.RawPhoneNumber
+		case "message":
+			projected[field] = r.Message
+		case "status":
+			projected[field] = r.Status
+		case "direction":
+			projected[field] = r.Direction
+		case "carrier":
+			projected[field] = r.Carrier
+		case "country_code":
+			projected[field] = r.CountryCode
+		case "dial_code":
+			projected[field] = r.DialCode
+		case "sender_id":
+			projected[field] = r.SenderID
+		case "sender_type":
+			projected[field] = r.SenderType
+		case "segment_count":
+			projected[field] = r.SegmentCount
+		case "encoding":
+			projected[field] = r.Encoding
+		case "script":
+			projected[field] = r.Script
+		case "language":
+			projected[field] = r.Language
+		case "flags":
+			projected[field] = r.Flags
+		case "status_history":
+			projected[field] = r.StatusHistory
+		case "media":
+			projected[field] = r.Media
+		case "created_at":
+			projected[field] = r.CreatedAt
+		case "updated_at":
+			projected[field] = r.UpdatedAt
+		}
+	}
+	return projected
+}
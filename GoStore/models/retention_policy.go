@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// What happens to a tenant's messages once they are older than its retention
+const (
+	RetentionActionDelete  = "delete"
+	RetentionActionArchive = "archive" // uploaded to the archive bucket, then deleted
+)
+
+// MaxRetentionDays bounds the retention of a policy at about 100 years
+const MaxRetentionDays = 36500
+
+// RetentionPolicy overrides the default retention for one tenant's messages
+// A RetentionDays of zero keeps the tenant's messages indefinitely
+type RetentionPolicy struct {
+	TenantID      string    `bson:"tenant_id" json:"tenant_id"`
+	RetentionDays int       `bson:"retention_days" json:"retention_days"`
+	Action        string    `bson:"action" json:"action"`
+	UpdatedAt     time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// IsValidRetentionAction reports whether action is a known retention action
+func IsValidRetentionAction(action string) bool {
+	return action == RetentionActionDelete || action == RetentionActionArchive
+}
+
+// Validate checks the retention and action of the policy
+func (p *RetentionPolicy) Validate() error {
+	if p.RetentionDays < 0 || p.RetentionDays > MaxRetentionDays {
+		return fmt.Errorf("retention_days must be between 0 and %d", MaxRetentionDays)
+	}
+	if !IsValidRetentionAction(p.Action) {
+		return fmt.Errorf("action must be %s or %s", RetentionActionDelete, RetentionActionArchive)
+	}
+	return nil
+}
+
+// TenantFilter restricts a maintenance query spanning tenants to some of them
+// The zero value selects every tenant
+type TenantFilter struct {
+	Only   []string // when set, only these tenants
+	Except []string // never these tenants
+}
+
+// Matches reports whether the filter selects tenantID
+func (f TenantFilter) Matches(tenantID string) bool {
+	if len(f.Only) > 0 && !slices.Contains(f.Only, tenantID) {
+		return false
+	}
+	return !slices.Contains(f.Except, tenantID)
+}
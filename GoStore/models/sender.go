@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Types of registered sender IDs
+const (
+	SenderTypeShortCode    = "short_code"   // 3-8 digits, e.g. 72345
+	SenderTypeAlphanumeric = "alphanumeric" // up to 11 letters, digits and spaces, at least one a letter
+	SenderTypeLongCode     = "long_code"    // a phone number of 10-15 digits
+)
+
+var (
+	shortCodePattern    = regexp.MustCompile(`^[0-9]{3,8}$`)
+	alphanumericPattern = regexp.MustCompile(`^[A-Za-z0-9 ]{1,11}$`)
+	letterPattern       = regexp.MustCompile(`[A-Za-z]`)
+)
+
+// IsValidSenderType reports whether senderType is a known sender ID type
+func IsValidSenderType(senderType string) bool {
+	return senderType == SenderTypeShortCode || senderType == SenderTypeAlphanumeric || senderType == SenderTypeLongCode
+}
+
+// SenderTypeOf returns the type a sender ID has the form of, or "" for none
+func SenderTypeOf(senderID string) string {
+	switch {
+	case shortCodePattern.MatchString(senderID):
+		return SenderTypeShortCode
+	case IsValidPhoneNumber(senderID):
+		return SenderTypeLongCode
+	case alphanumericPattern.MatchString(senderID) && letterPattern.MatchString(senderID):
+		return SenderTypeAlphanumeric
+	}
+	return ""
+}
+
+// Sender is a sender ID registered by its owning tenant. Messages the tenant
+// stores from it are marked with its type
+type Sender struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID    string             `bson:"tenant_id" json:"tenant_id"`
+	SenderID    string             `bson:"sender_id" json:"sender_id"`
+	Type        string             `bson:"type" json:"type"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Validate checks that the sender ID has the form of its type, taking the type
+// from the form when none is given
+func (s *Sender) Validate() error {
+	if s.SenderID == "" {
+		return fmt.Errorf("sender_id is required")
+	}
+	if len(s.Description) > 256 {
+		return fmt.Errorf("description must be at most 256 characters")
+	}
+	form := SenderTypeOf(s.SenderID)
+	if form == "" {
+		return fmt.Errorf("sender_id %q is not a short code, alphanumeric sender or long code", s.SenderID)
+	}
+	if s.Type == "" {
+		s.Type = form
+	}
+	if !IsValidSenderType(s.Type) {
+		return fmt.Errorf("type must be %s, %s or %s", SenderTypeShortCode, SenderTypeAlphanumeric, SenderTypeLongCode)
+	}
+	if form != s.Type {
+		return fmt.Errorf("sender_id %q is not a valid %s", s.SenderID, s.Type)
+	}
+	return nil
+}
@@ -1,30 +1,99 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Delivery status values published on the status topic
+const (
+	StatusSent      = "SENT"
+	StatusDelivered = "DELIVERED"
+	StatusFailed    = "FAILED"
+)
+
+// Directions of a stored message, relative to the user
+const (
+	DirectionOutbound = "outbound" // sent to the user
+	DirectionInbound  = "inbound"  // received from the user
+)
+
 // SMSRecord represents a stored SMS message record in MongoDB
 type SMSRecord struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID      string             `bson:"user_id" json:"user_id"`
-	PhoneNumber string             `bson:"phone_number" json:"phone_number"`
-	Message     string             `bson:"message" json:"message"`
-	Status      string             `bson:"status" json:"status"`
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MessageID         string             `bson:"message_id,omitempty" json:"message_id,omitempty"`
+	ProviderMessageID string             `bson:"provider_message_id,omitempty" json:"provider_message_id,omitempty"`
+	TenantID          string             `bson:"tenant_id" json:"tenant_id"`
+	UserID            string             `bson:"user_id" json:"user_id"`
+	PhoneNumber       string             `bson:"phone_number" json:"phone_number"`
+	RawUserID         string             `bson:"raw_user_id,omitempty" json:"raw_user_id,omitempty"`           // User ID as received, when normalizing changed it
+	RawPhoneNumber    string             `bson:"raw_phone_number,omitempty" json:"raw_phone_number,omitempty"` // Phone number as received, when normalizing changed it
+	Message           string             `bson:"message" json:"message"`
+	Status            string             `bson:"status" json:"status"`
+	Direction         string             `bson:"direction,omitempty" json:"direction,omitempty"` // Unset on records stored before inbound ingestion; those are outbound
+	Carrier           string             `bson:"carrier,omitempty" json:"carrier,omitempty"`
+	CountryCode       string             `bson:"country_code,omitempty" json:"country_code,omitempty"` // ISO 3166-1 alpha-2
+	DialCode          string             `bson:"dial_code,omitempty" json:"dial_code,omitempty"`       // Country calling code of the phone number, without the +
+	SenderID          string             `bson:"sender_id,omitempty" json:"sender_id,omitempty"`       // Alphanumeric sender, short code or long number
+	SenderType        string             `bson:"sender_type,omitempty" json:"sender_type,omitempty"`   // Type of the sender ID when the tenant registered it
+	SegmentCount      int                `bson:"segment_count,omitempty" json:"segment_count,omitempty"`
+	Encoding          string             `bson:"encoding,omitempty" json:"encoding,omitempty"` // GSM-7 or UCS-2, detected from the message
+	Script            string             `bson:"script,omitempty" json:"script,omitempty"`     // ISO 15924, detected from the message
+	Language          string             `bson:"language,omitempty" json:"language,omitempty"` // ISO 639-1, detected from the message when it is plain
+	Flags             []string           `bson:"flags,omitempty" json:"flags,omitempty"`       // Flag rules the message matched when it was stored
+	StatusHistory     []StatusChange     `bson:"status_history,omitempty" json:"status_history,omitempty"`
+	Media             []MediaAttachment  `bson:"media,omitempty" json:"media,omitempty"` // MMS attachments, downloaded by position
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitzero"`
+
+	// Set while the record's stored event waits in the outbox for the relay to publish it
+	StoredEventPending bool `bson:"stored_event_pending,omitempty" json:"-"`
+}
+
+// StatusChange is a single entry in a record's status history
+type StatusChange struct {
+	Status    string    `bson:"status" json:"status"`
+	Reason    string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	ChangedAt time.Time `bson:"changed_at" json:"changed_at"`
 }
 
 // KafkaEvent represents the event consumed from Kafka topic
 // This matches the Java KafkaEvent structure but uses Go types
 type KafkaEvent struct {
-	EventID     string `json:"eventId"`
-	UserID      string `json:"userId"`
-	PhoneNumber string `json:"phoneNumber"`
-	Message     string `json:"message"`
-	Status      string `json:"status"`
-	CreatedAt   string `json:"createdAt"` // ISO-8601 format from Java (no timezone)
+	EventID           string       `json:"eventId"`
+	ProviderMessageID string       `json:"providerMessageId,omitempty"` // Vendor-assigned ID used to match delivery receipts
+	TenantID          string       `json:"tenantId,omitempty"`          // Brand the message belongs to; defaulted by the consumer when absent
+	UserID            string       `json:"userId"`
+	PhoneNumber       string       `json:"phoneNumber"`
+	Message           string       `json:"message"`
+	Status            string       `json:"status"`
+	CreatedAt         string       `json:"createdAt"`       // ISO-8601 format from Java (no timezone)
+	Media             []KafkaMedia `json:"media,omitempty"` // MMS attachments
+	Carrier           string       `json:"carrier,omitempty"`
+	CountryCode       string       `json:"countryCode,omitempty"`
+	SenderID          string       `json:"senderId,omitempty"`
+	SegmentCount      int          `json:"segmentCount,omitempty"`
+	Direction         string       `json:"direction,omitempty"` // MO or MT; the topic decides when absent
+}
+
+// Validate checks that the event identifies the message, the user, and its status,
+// and that its metadata and any media attachments are well-formed
+func (k *KafkaEvent) Validate() error {
+	if k.EventID == "" {
+		return fmt.Errorf("eventId is required")
+	}
+	if k.UserID == "" {
+		return fmt.Errorf("userId is required")
+	}
+	if k.Status == "" {
+		return fmt.Errorf("status is required")
+	}
+	if err := k.validateMetadata(); err != nil {
+		return err
+	}
+	return validateMedia(k.Media)
 }
 
 // ToSMSRecord converts a KafkaEvent to an SMSRecord for MongoDB storage
@@ -40,14 +109,87 @@ func (k *KafkaEvent) ToSMSRecord() (*SMSRecord, error) {
 	}
 
 	return &SMSRecord{
-		UserID:      k.UserID,
-		PhoneNumber: k.PhoneNumber,
-		Message:     k.Message,
-		Status:      k.Status,
-		CreatedAt:   createdAt,
+		MessageID:         k.EventID,
+		ProviderMessageID: k.ProviderMessageID,
+		TenantID:          k.TenantID,
+		UserID:            k.UserID,
+		PhoneNumber:       k.PhoneNumber,
+		Message:           k.Message,
+		Status:            k.Status,
+		Direction:         k.recordDirection(),
+		Carrier:           k.Carrier,
+		CountryCode:       k.CountryCode,
+		SenderID:          k.SenderID,
+		SegmentCount:      k.SegmentCount,
+		Media:             toAttachments(k.Media),
+		StatusHistory: []StatusChange{
+			{Status: k.Status, ChangedAt: createdAt},
+		},
+		CreatedAt: createdAt,
 	}, nil
 }
 
+// ToKafkaEvent converts a stored record back to the event it was stored from,
+// with the status it arrived with and its numbers as normalized. Media data is
+// not on the record, so attachments are left for the caller to read
+func (r *SMSRecord) ToKafkaEvent() *KafkaEvent {
+	status := r.Status
+	if len(r.StatusHistory) > 0 {
+		status = r.StatusHistory[0].Status
+	}
+	direction := EventDirectionMT
+	if r.Direction == DirectionInbound {
+		direction = EventDirectionMO
+	}
+
+	return &KafkaEvent{
+		EventID:           r.MessageID,
+		ProviderMessageID: r.ProviderMessageID,
+		TenantID:          r.TenantID,
+		UserID:            r.UserID,
+		PhoneNumber:       r.PhoneNumber,
+		Message:           r.Message,
+		Status:            status,
+		CreatedAt:         r.CreatedAt.UTC().Format("2006-01-02T15:04:05.999999"),
+		Carrier:           r.Carrier,
+		CountryCode:       r.CountryCode,
+		SenderID:          r.SenderID,
+		SegmentCount:      r.SegmentCount,
+		Direction:         direction,
+	}
+}
+
+// StatusUpdateEvent represents a delivery status update consumed from the status topic
+// MessageID references the eventId of the original SMS event
+type StatusUpdateEvent struct {
+	MessageID string `json:"messageId"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+	Timestamp string `json:"timestamp"` // ISO-8601 format from Java (no timezone)
+}
+
+// Validate checks that the status update references a message and carries a known status
+func (e *StatusUpdateEvent) Validate() error {
+	if e.MessageID == "" {
+		return fmt.Errorf("messageId is required")
+	}
+	switch e.Status {
+	case StatusSent, StatusDelivered, StatusFailed:
+		return nil
+	default:
+		return fmt.Errorf("unknown status: %q", e.Status)
+	}
+}
+
+// ChangedAt returns the update timestamp as UTC, falling back to the current time
+func (e *StatusUpdateEvent) ChangedAt() time.Time {
+	t, err := parseJavaLocalDateTime(e.Timestamp)
+	if err != nil {
+		return time.Now().UTC()
+	}
+	return t
+}
+
 // parseJavaLocalDateTime parses Java LocalDateTime (ISO-8601 without timezone)
 // and returns a Go time.Time in UTC
 func parseJavaLocalDateTime(timestamp string) (time.Time, error) {
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Rules of strict event validation, reported in Violation.Rule
+const (
+	RuleRequired   = "required"     // the field is missing
+	RuleE164       = "e164"         // not a + followed by 10-15 digits
+	RuleTimestamp  = "timestamp"    // not an ISO-8601 timestamp
+	RuleOutOfRange = "out_of_range" // a timestamp no producer could have meant
+	RuleFuture     = "future"       // a timestamp ahead of the clock by more than the allowed skew
+	RuleMaxLength  = "max_length"   // longer than allowed
+)
+
+// maxFutureWindow is how far ahead a createdAt may be before it is out of range
+// rather than possibly a matter of clock skew
+const maxFutureWindow = time.Hour
+
+// earliestTimestamp is the oldest createdAt strict validation accepts
+var earliestTimestamp = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Violation is a rule of strict validation an event breaks
+type Violation struct {
+	Field string // as named in the event's JSON, e.g. phoneNumber
+	Rule  string
+
+	// Retryable violations may pass when the event is processed again later,
+	// such as a createdAt slightly ahead of a clock that is behind
+	Retryable bool
+}
+
+func (v Violation) String() string {
+	return v.Field + ":" + v.Rule
+}
+
+// StrictLimits are the limits strict validation checks events against
+type StrictLimits struct {
+	MaxMessageLength int           // in characters
+	MaxClockSkew     time.Duration // how far createdAt may be ahead of now
+}
+
+// IsE164PhoneNumber reports whether phoneNumber is in E.164 format: as
+// IsValidPhoneNumber accepts it, with the leading +
+func IsE164PhoneNumber(phoneNumber string) bool {
+	return strings.HasPrefix(phoneNumber, "+") && IsValidPhoneNumber(phoneNumber)
+}
+
+// StrictViolations checks the event more closely than Validate, returning every
+// rule it breaks: the message ID, user, phone number, status, timestamp and a
+// body (or media) are required, the user and phone number must be E.164, the
+// timestamp must be plausible as of now, and the body within the length limit
+func (k *KafkaEvent) StrictViolations(limits StrictLimits, now time.Time) []Violation {
+	var violations []Violation
+	add := func(field, rule string) {
+		violations = append(violations, Violation{Field: field, Rule: rule})
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"eventId", k.EventID},
+		{"status", k.Status},
+	} {
+		if field.value == "" {
+			add(field.name, RuleRequired)
+		}
+	}
+	for _, field := range []struct{ name, value string }{
+		{"userId", k.UserID},
+		{"phoneNumber", k.PhoneNumber},
+	} {
+		if field.value == "" {
+			add(field.name, RuleRequired)
+		} else if !IsE164PhoneNumber(field.value) {
+			add(field.name, RuleE164)
+		}
+	}
+
+	if k.Message == "" && len(k.Media) == 0 {
+		add("message", RuleRequired)
+	} else if limits.MaxMessageLength > 0 && utf8.RuneCountInString(k.Message) > limits.MaxMessageLength {
+		add("message", RuleMaxLength)
+	}
+
+	if k.CreatedAt == "" {
+		add("createdAt", RuleRequired)
+	} else if createdAt, err := parseJavaLocalDateTime(k.CreatedAt); err != nil {
+		add("createdAt", RuleTimestamp)
+	} else if ahead := createdAt.Sub(now); createdAt.Before(earliestTimestamp) || ahead > maxFutureWindow {
+		add("createdAt", RuleOutOfRange)
+	} else if ahead > limits.MaxClockSkew {
+		violations = append(violations, Violation{Field: "createdAt", Rule: RuleFuture, Retryable: true})
+	}
+	return violations
+}
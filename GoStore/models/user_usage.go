@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// What happens once a user's stored messages reach their quota
+const (
+	QuotaActionReject  = "reject"  // further messages of the user are rejected
+	QuotaActionArchive = "archive" // the user's oldest messages are archived to make room
+)
+
+// Levels of usage at which a user's quota alerts
+const (
+	QuotaLevelWarning  = "warning"
+	QuotaLevelExceeded = "exceeded"
+)
+
+// UserQuota caps the messages stored for each user; a zero cap is unlimited
+type UserQuota struct {
+	MaxMessages int64 `json:"max_messages,omitzero"`
+	MaxBytes    int64 `json:"max_bytes,omitzero"`
+}
+
+// IsValidQuotaAction reports whether action is a known quota action
+func IsValidQuotaAction(action string) bool {
+	return action == QuotaActionReject || action == QuotaActionArchive
+}
+
+// UserUsage is how many messages of a user are stored, and how large they are
+// It is kept up to date as messages are stored, and recounted from the stored
+// messages periodically to account for those removed since
+type UserUsage struct {
+	ID           string    `bson:"_id" json:"-"` // tenant and user ID, so each user has one document
+	TenantID     string    `bson:"tenant_id" json:"tenant_id"`
+	UserID       string    `bson:"user_id" json:"user_id"`
+	Messages     int64     `bson:"messages" json:"messages"`
+	Bytes        int64     `bson:"bytes" json:"bytes"` // BSON size of the stored records
+	UpdatedAt    time.Time `bson:"updated_at" json:"updated_at"`
+	ReconciledAt time.Time `bson:"reconciled_at,omitempty" json:"reconciled_at,omitzero"`
+}
+
+// Used returns the share of quota the usage takes, in the dimension closest
+// to its cap; 1 means the quota is reached. It is zero when quota is unlimited
+func (u *UserUsage) Used(quota UserQuota) float64 {
+	used := 0.0
+	if quota.MaxMessages > 0 {
+		used = max(used, float64(u.Messages)/float64(quota.MaxMessages))
+	}
+	if quota.MaxBytes > 0 {
+		used = max(used, float64(u.Bytes)/float64(quota.MaxBytes))
+	}
+	return used
+}
@@ -0,0 +1,12 @@
+package models
+
+import "regexp"
+
+// phoneNumberPattern accepts +1234567890 or 1234567890 (10-15 digits)
+// Pattern: optional +, digit 1-9, followed by 9-14 more digits
+var phoneNumberPattern = regexp.MustCompile(`^\+?[1-9]\d{9,14}$`)
+
+// IsValidPhoneNumber validates phone number format
+func IsValidPhoneNumber(phoneNumber string) bool {
+	return phoneNumberPattern.MatchString(phoneNumber)
+}
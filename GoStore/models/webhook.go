@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/netguard"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookSubscription is a registered URL notified about message events
+// An empty UserID subscribes to events for every user of the tenant, and empty
+// Events to every event type
+type WebhookSubscription struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TenantID  string             `bson:"tenant_id" json:"tenant_id"`
+	URL       string             `bson:"url" json:"url"`
+	UserID    string             `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Events    []string           `bson:"events,omitempty" json:"events,omitempty"`
+	Disabled  bool               `bson:"disabled,omitempty" json:"-"` // nothing is delivered while set
+	Secret    string             `bson:"secret" json:"-"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitzero"`
+}
+
+// Validate checks that the subscription targets an absolute http(s) URL whose
+// host is not a loopback, private, link-local or unspecified address
+func (w *WebhookSubscription) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if err := netguard.CheckHost(u.Hostname()); err != nil {
+		return fmt.Errorf("url host must be publicly routable")
+	}
+	if w.UserID != "" && !IsValidPhoneNumber(w.UserID) {
+		return fmt.Errorf("invalid user_id format, expected phone number")
+	}
+	seen := make(map[string]bool, len(w.Events))
+	for _, event := range w.Events {
+		if event == "" || seen[event] {
+			return fmt.Errorf("events must not contain empty or repeated event types")
+		}
+		seen[event] = true
+	}
+	return nil
+}
+
+// Subscribes reports whether events of eventType are delivered to the subscription
+func (w *WebhookSubscription) Subscribes(eventType string) bool {
+	if w.Disabled {
+		return false
+	}
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+// WebhookDelivery records a single attempt to deliver an event to a subscription
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SubscriptionID primitive.ObjectID `bson:"subscription_id" json:"subscription_id"`
+	DeliveryID     string             `bson:"delivery_id" json:"delivery_id"`
+	Event          string             `bson:"event" json:"event"`
+	URL            string             `bson:"url" json:"url"`
+	Attempt        int                `bson:"attempt" json:"attempt"`
+	StatusCode     int                `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+	Success        bool               `bson:"success" json:"success"`
+	DurationMs     int64              `bson:"duration_ms" json:"duration_ms"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+}
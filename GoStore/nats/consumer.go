@@ -0,0 +1,385 @@
+// Package nats consumes SMS events from a NATS JetStream stream, for deployments
+// that standardized on NATS instead of Kafka
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/requestid"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+// backend labels the metrics of the consumer
+const backend = "nats"
+
+const (
+	// connectTimeout bounds connecting and setting up the durable consumer
+	connectTimeout = 10 * time.Second
+
+	// stopTimeout bounds how long Stop waits for the batch in progress to be stored
+	stopTimeout = 10 * time.Second
+
+	// retryBase is the redelivery delay after a message's first failed delivery,
+	// doubling with each further one up to AckWait
+	retryBase = time.Second
+)
+
+// Config identifies the stream and subjects to consume, and the durable consumer to read them with
+type Config struct {
+	URL       string
+	CredsFile string // empty connects without credentials
+	Stream    string
+	Subjects  map[string]string // subject to the direction of its records, e.g. models.DirectionInbound
+	Durable   string
+
+	// DefaultTenantID is assigned to events that don't carry a tenantId
+	DefaultTenantID string
+
+	// Validation configures strict validation of events; off by default
+	Validation ingest.Validation
+
+	// Messages are fetched in batches of up to BatchSize, waiting up to FetchWait
+	// for a batch to fill
+	BatchSize int
+	FetchWait time.Duration
+
+	// AckWait is how long JetStream waits for a message to be acknowledged before
+	// redelivering it. A message failing MaxDeliver deliveries is terminated
+	AckWait    time.Duration
+	MaxDeliver int
+
+	// Stages are custom processors added to the ingest pipeline, see ingest.Stage
+	Stages []ingest.Stage
+}
+
+// Consumer stores the SMS events of a JetStream stream, read by a durable pull
+// consumer and acknowledged once stored
+type Consumer struct {
+	cfg       Config
+	conn      *nats.Conn
+	consumer  jetstream.Consumer
+	pipelines map[string]*ingest.Pipeline // by subject
+	stopChan  chan struct{}
+	done      chan struct{}
+
+	// Pausing stops fetching new messages; a batch already fetched is still
+	// stored and acknowledged. The durable consumer keeps its place in the
+	// stream, so consumption resumes where it stopped
+	*ingest.Gate
+
+	// running, backlog and inFlight are reported by Check, MaxLag and InFlight
+	running  atomic.Bool
+	backlog  atomic.Int64
+	inFlight atomic.Int64
+}
+
+// StartConsumer connects to NATS, creates or updates the durable consumer of
+// cfg.Subjects on cfg.Stream, and begins consuming in a background goroutine.
+// The stream itself must already exist
+func StartConsumer(cfg Config, smsService *services.SMSService) (*Consumer, error) {
+	slog.Info("Starting NATS consumer", "stream", cfg.Stream, "subjects", cfg.Subjects, "durable", cfg.Durable)
+
+	c := &Consumer{
+		cfg:       cfg,
+		pipelines: make(map[string]*ingest.Pipeline, len(cfg.Subjects)),
+		stopChan:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	c.Gate = ingest.NewGate("NATS consumer", c.stopChan, retryBase, "durable", cfg.Durable)
+	for subject, direction := range cfg.Subjects {
+		pipeline, err := ingest.NewPipeline(ingest.PipelineConfig{
+			DefaultTenantID: cfg.DefaultTenantID,
+			Validation:      cfg.Validation,
+			Direction:       direction,
+			Persist:         ingest.Persist(smsService),
+			Stages:          cfg.Stages,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("subject %s: %w", subject, err)
+		}
+		c.pipelines[subject] = pipeline
+	}
+
+	options := []nats.Option{
+		nats.Name("sms-store"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				slog.Warn("Disconnected from NATS", "error", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			slog.Info("Reconnected to NATS", "url", conn.ConnectedUrlRedacted())
+		}),
+	}
+	if cfg.CredsFile != "" {
+		options = append(options, nats.UserCredentials(cfg.CredsFile))
+	}
+	conn, err := nats.Connect(cfg.URL, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	c.conn = conn
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	consumerConfig := jetstream.ConsumerConfig{
+		Durable:       cfg.Durable,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxDeliver:    cfg.MaxDeliver,
+	}
+	// A single subject is filtered without FilterSubjects, which needs NATS 2.10
+	subjects := slices.Sorted(maps.Keys(cfg.Subjects))
+	if len(subjects) == 1 {
+		consumerConfig.FilterSubject = subjects[0]
+	} else {
+		consumerConfig.FilterSubjects = subjects
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	c.consumer, err = js.CreateOrUpdateConsumer(ctx, cfg.Stream, consumerConfig)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream consumer %s on stream %s: %w", cfg.Durable, cfg.Stream, err)
+	}
+
+	go c.consume()
+	slog.Info("NATS consumer started successfully")
+	return c, nil
+}
+
+// consume is the main consumption loop, fetching and storing a batch at a time
+func (c *Consumer) consume() {
+	c.running.Store(true)
+	defer close(c.done)
+	defer func() {
+		// A dead loop must fail readiness rather than silently stop consuming
+		c.running.Store(false)
+		if r := recover(); r != nil {
+			slog.Error("NATS consumer panic recovered", "panic", r)
+		}
+	}()
+
+	slog.Info("Starting NATS consumption loop", "batch_size", c.cfg.BatchSize, "fetch_wait", c.cfg.FetchWait)
+
+	for {
+		select {
+		case <-c.stopChan:
+			slog.Info("NATS consumer stop signal received, exiting")
+			return
+		default:
+		}
+
+		if !c.WaitResumed() {
+			continue
+		}
+
+		batch, err := c.consumer.Fetch(c.cfg.BatchSize, jetstream.FetchMaxWait(c.cfg.FetchWait))
+		if err != nil {
+			slog.Error("Error fetching NATS messages", "error", err)
+			c.sleep(time.Second)
+			continue
+		}
+		var messages []jetstream.Msg
+		for message := range batch.Messages() {
+			messages = append(messages, message)
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+			slog.Warn("NATS fetch ended early", "received", len(messages), "error", err)
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		for _, message := range messages {
+			metrics.IngestMessageConsumed(backend, message.Subject())
+		}
+		if metadata, err := messages[len(messages)-1].Metadata(); err == nil {
+			c.backlog.Store(int64(metadata.NumPending))
+		}
+
+		c.inFlight.Store(int64(len(messages)))
+		for _, group := range groupBySubject(messages) {
+			c.storeGroup(group)
+		}
+		c.inFlight.Store(0)
+	}
+}
+
+// storeGroup passes the messages of one subject through its pipeline,
+// acknowledging them once stored. Messages the pipeline rejects are terminated.
+// If processing fails the rest are redelivered after a delay, or terminated once
+// they have been delivered MaxDeliver times; while storage is unavailable they
+// are held, without using up deliveries, until it is back
+func (c *Consumer) storeGroup(messages []jetstream.Msg) {
+	subject := messages[0].Subject()
+	pipeline := c.pipelineFor(subject)
+	if pipeline == nil {
+		// Only configured subjects are delivered, unless the consumer's filter was changed elsewhere
+		for _, message := range messages {
+			c.terminate(context.Background(), message, ingest.ReasonInvalid, fmt.Errorf("no handler for subject %s", subject))
+		}
+		return
+	}
+
+	terminated := make(map[jetstream.Msg]bool)
+	for {
+		events := make([]*ingest.Event, 0, len(messages))
+		sources := make(map[*ingest.Event]jetstream.Msg, len(messages))
+		for _, message := range messages {
+			if terminated[message] {
+				continue
+			}
+			e := ingest.NewEvent(messageContext(message), subject, message.Data())
+			events = append(events, e)
+			sources[e] = message
+		}
+
+		err := pipeline.Run(context.Background(), events, func(e *ingest.Event) error {
+			terminated[sources[e]] = true
+			c.terminate(e.Context(), sources[e], e.Rejection().Reason, e.Rejection().Err)
+			return nil
+		})
+		if err == nil {
+			for _, e := range events {
+				if !terminated[sources[e]] {
+					if err := sources[e].Ack(); err != nil {
+						slog.WarnContext(e.Context(), "Failed to acknowledge NATS message", "subject", subject, "error", err)
+					}
+				}
+			}
+			return
+		}
+
+		if errors.Is(err, store.ErrUnavailable) {
+			for _, e := range events {
+				sources[e].InProgress()
+			}
+			if c.WaitAvailable(context.Background(), err) {
+				continue
+			}
+		} else {
+			slog.Error("Error processing NATS batch", "subject", subject, "messages", len(events), "error", err)
+		}
+		for _, e := range events {
+			if !terminated[sources[e]] {
+				metrics.IngestProcessingFailed(backend, subject)
+				c.redeliver(e.Context(), sources[e], err)
+			}
+		}
+		return
+	}
+}
+
+// pipelineFor returns the pipeline of the configured subject matching subject,
+// which may contain the wildcards * and >, or nil if none does
+func (c *Consumer) pipelineFor(subject string) *ingest.Pipeline {
+	if pipeline, ok := c.pipelines[subject]; ok {
+		return pipeline
+	}
+	for _, pattern := range slices.Sorted(maps.Keys(c.pipelines)) {
+		if subjectMatches(pattern, subject) {
+			return c.pipelines[pattern]
+		}
+	}
+	return nil
+}
+
+// subjectMatches reports whether subject matches pattern, in which * matches any
+// one token and a final > matches one or more
+func subjectMatches(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	tokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" && i == len(patternTokens)-1 {
+			return len(tokens) > i
+		}
+		if i >= len(tokens) || (token != "*" && token != tokens[i]) {
+			return false
+		}
+	}
+	return len(tokens) == len(patternTokens)
+}
+
+// redeliver negatively acknowledges a message that failed processing, so it is
+// delivered again after a delay, or terminates it after its last delivery
+func (c *Consumer) redeliver(ctx context.Context, message jetstream.Msg, cause error) {
+	deliveries := uint64(1)
+	if metadata, err := message.Metadata(); err == nil {
+		deliveries = metadata.NumDelivered
+	}
+	if c.cfg.MaxDeliver > 0 && deliveries >= uint64(c.cfg.MaxDeliver) {
+		c.terminate(ctx, message, ingest.ReasonRetriesExhausted, fmt.Errorf("giving up after %d deliveries: %w", deliveries, cause))
+		return
+	}
+
+	delay := retryBase << min(deliveries-1, 16)
+	if delay > c.cfg.AckWait {
+		delay = c.cfg.AckWait
+	}
+	if err := message.NakWithDelay(delay); err != nil {
+		slog.WarnContext(ctx, "Failed to negatively acknowledge NATS message", "subject", message.Subject(), "error", err)
+	}
+}
+
+// terminate stops JetStream from redelivering an unprocessable message
+func (c *Consumer) terminate(ctx context.Context, message jetstream.Msg, reason string, cause error) {
+	metrics.IngestRejected(backend, message.Subject(), reason)
+	slog.WarnContext(ctx, "Terminated unprocessable NATS message", "subject", message.Subject(), "reason", reason, "error", cause)
+	if err := message.TermWithReason(reason); err != nil {
+		slog.WarnContext(ctx, "Failed to terminate NATS message", "subject", message.Subject(), "error", err)
+	}
+}
+
+// sleep waits for delay, reporting false if the consumer is stopped first
+func (c *Consumer) sleep(delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return true
+	case <-c.stopChan:
+		return false
+	}
+}
+
+// messageContext scopes processing of message to the producer's X-Request-ID
+// header, or to a new request ID when the producer didn't set one
+func messageContext(message jetstream.Msg) context.Context {
+	id := message.Headers().Get(requestid.Header)
+	if !requestid.IsValid(id) {
+		id = requestid.New()
+	}
+	return requestid.WithID(context.Background(), id)
+}
+
+// groupBySubject splits messages by subject, keeping fetch order within each subject
+func groupBySubject(messages []jetstream.Msg) [][]jetstream.Msg {
+	var groups [][]jetstream.Msg
+	index := make(map[string]int)
+	for _, message := range messages {
+		i, ok := index[message.Subject()]
+		if !ok {
+			i = len(groups)
+			index[message.Subject()] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], message)
+	}
+	return groups
+}
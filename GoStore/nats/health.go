@@ -0,0 +1,60 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// MaxLag returns the messages of the consumer's subjects last seen waiting in
+// the stream to be delivered
+func (c *Consumer) MaxLag() int64 {
+	return c.backlog.Load()
+}
+
+// InFlight returns the number of messages fetched and not yet stored
+func (c *Consumer) InFlight() int {
+	return int(c.inFlight.Load())
+}
+
+// Check reports whether the consumer loop is running, the connection to NATS is
+// up, and no more than maxLag messages wait to be delivered (zero disables the
+// backlog check)
+func (c *Consumer) Check(ctx context.Context, maxLag int64) error {
+	if !c.running.Load() {
+		return errors.New("consumer loop is not running")
+	}
+	if !c.conn.IsConnected() {
+		return fmt.Errorf("not connected to NATS: %s", c.conn.Status())
+	}
+
+	info, err := c.consumer.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream consumer info: %w", err)
+	}
+	c.backlog.Store(int64(info.NumPending))
+	if maxLag > 0 && int64(info.NumPending) > maxLag {
+		return fmt.Errorf("consumer backlog %d exceeds threshold %d", info.NumPending, maxLag)
+	}
+	return nil
+}
+
+// Stop gracefully shuts down the consumer, letting the batch in progress be
+// stored before closing the connection
+func (c *Consumer) Stop() error {
+	slog.Info("Stopping NATS consumer")
+	close(c.stopChan)
+
+	select {
+	case <-c.done:
+	case <-time.After(stopTimeout):
+		slog.Warn("NATS consumer did not finish its batch before shutdown", "durable", c.cfg.Durable)
+	}
+
+	// Unacknowledged messages are redelivered once their AckWait expires
+	c.conn.Close()
+	slog.Info("NATS consumer stopped successfully")
+	return nil
+}
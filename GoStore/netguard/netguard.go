@@ -0,0 +1,83 @@
+// Package netguard keeps requests to URLs given by API callers, such as webhook
+// targets, off the service's own network: loopback, private, link-local and
+// unspecified addresses are refused, so a caller cannot reach the admin server,
+// internal services or cloud metadata endpoints through the service
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned for hosts that resolve to an address on the service's own network
+var ErrForbiddenAddress = errors.New("address is not publicly routable")
+
+// Allowed reports whether ip may be connected to
+func Allowed(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsUnspecified() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, private in all but name
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// CheckHost rejects host when it is a forbidden IP address or a name of the
+// local host, without resolving it
+func CheckHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%s: %w", host, ErrForbiddenAddress)
+	}
+	if ip, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil && !Allowed(ip) {
+		return fmt.Errorf("%s: %w", host, ErrForbiddenAddress)
+	}
+	return nil
+}
+
+// Resolve rejects host when it, or any address it resolves to, is forbidden
+func Resolve(ctx context.Context, host string) error {
+	if err := CheckHost(host); err != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", strings.Trim(host, "[]"))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !Allowed(addr) {
+			return fmt.Errorf("%s resolves to %s: %w", host, addr, ErrForbiddenAddress)
+		}
+	}
+	return nil
+}
+
+// Dialer returns a dialer refusing to connect to forbidden addresses. The check
+// runs on the address actually dialed, after resolution, so a name resolving to
+// a forbidden address at delivery time is refused too
+func Dialer(dialer *net.Dialer) *net.Dialer {
+	guarded := *dialer
+	guarded.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip, err := netip.ParseAddr(host)
+		if err != nil || !Allowed(ip) {
+			return fmt.Errorf("%s: %w", host, ErrForbiddenAddress)
+		}
+		return nil
+	}
+	return &guarded
+}
@@ -0,0 +1,59 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"100.64.0.1":           false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+	}
+	for addr, want := range tests {
+		if got := Allowed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Allowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"localhost", "admin.localhost", "LOCALHOST.", "127.0.0.1", "[::1]", "169.254.169.254"} {
+		if err := CheckHost(host); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%q) = %v, want ErrForbiddenAddress", host, err)
+		}
+	}
+	for _, host := range []string{"example.com", "93.184.216.34"} {
+		if err := CheckHost(host); err != nil {
+			t.Errorf("CheckHost(%q) = %v", host, err)
+		}
+	}
+}
+
+func TestDialerRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := Dialer(&net.Dialer{}).DialContext(context.Background(), "tcp", server.Listener.Addr().String())
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("dialing %s = %v, want ErrForbiddenAddress", server.Listener.Addr(), err)
+	}
+}
@@ -0,0 +1,286 @@
+// Package openapi builds an OpenAPI 3 document of the REST API, with the schemas
+// of request and response bodies reflected from the Go types the handlers encode,
+// and serves it along with a Swagger UI page
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Param is a query parameter of an operation
+type Param struct {
+	Name        string
+	Description string
+	Type        string // JSON schema type; defaults to string
+	Required    bool
+	Enum        []string
+}
+
+// Operation describes one route of the API
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	Scope       string // API key scope required; empty for unauthenticated routes
+	Query       []Param
+	Request     any               // value of the request body type; nil when there is no body
+	Response    any               // value of the response body type; nil when there is no JSON body
+	Status      int               // success status; defaults to 200
+	ContentType string            // of a response that is not JSON, e.g. text/event-stream
+	Errors      []int             // error statuses
+	Headers     map[string]string // response headers by name, with descriptions
+}
+
+// Document is an OpenAPI 3 document built up one operation at a time
+// Every operation must be added before it is served
+type Document struct {
+	title          string
+	version        string
+	errorType      any
+	errorMediaType string
+	paths          map[string]map[string]any
+	schemas        map[string]any
+}
+
+// pathParams matches the wildcards of a ServeMux pattern
+var pathParams = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// New creates an empty document, with errorType as the body of error
+// responses, served as errorMediaType
+func New(title, version string, errorType any, errorMediaType string) *Document {
+	return &Document{
+		title:          title,
+		version:        version,
+		errorType:      errorType,
+		errorMediaType: errorMediaType,
+		paths:          make(map[string]map[string]any),
+		schemas:        make(map[string]any),
+	}
+}
+
+// Add describes the route registered with a ServeMux pattern of the form "METHOD /path"
+func (d *Document) Add(pattern string, op Operation) {
+	method, path, _ := strings.Cut(pattern, " ")
+
+	var parameters []any
+	for _, match := range pathParams.FindAllStringSubmatch(path, -1) {
+		parameters = append(parameters, map[string]any{
+			"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, param := range op.Query {
+		schema := map[string]any{"type": "string"}
+		if param.Type != "" {
+			schema["type"] = param.Type
+		}
+		if len(param.Enum) > 0 {
+			schema["enum"] = param.Enum
+		}
+		parameters = append(parameters, map[string]any{
+			"name": param.Name, "in": "query", "required": param.Required,
+			"description": param.Description, "schema": schema,
+		})
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if op.Response != nil || op.ContentType != "" {
+		contentType := op.ContentType
+		schema := map[string]any{"type": "string"}
+		if contentType == "" {
+			contentType = "application/json"
+			schema = d.schema(reflect.TypeOf(op.Response))
+		}
+		success["content"] = map[string]any{contentType: map[string]any{"schema": schema}}
+	}
+	if len(op.Headers) > 0 {
+		headers := make(map[string]any)
+		for name, description := range op.Headers {
+			headers[name] = map[string]any{"description": description, "schema": map[string]any{"type": "string"}}
+		}
+		success["headers"] = headers
+	}
+	responses := map[string]any{strconv.Itoa(status): success}
+
+	errors := op.Errors
+	if op.Scope != "" {
+		errors = append(slices.Clone(errors), http.StatusUnauthorized, http.StatusForbidden)
+	}
+	for _, code := range errors {
+		responses[strconv.Itoa(code)] = map[string]any{
+			"description": http.StatusText(code),
+			"content": map[string]any{d.errorMediaType: map[string]any{
+				"schema": d.schema(reflect.TypeOf(d.errorType)),
+			}},
+		}
+	}
+
+	operation := map[string]any{
+		"summary":     op.Summary,
+		"description": op.Description,
+		"responses":   responses,
+	}
+	if op.Tag != "" {
+		operation["tags"] = []string{op.Tag}
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	if op.Request != nil {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{"application/json": map[string]any{
+				"schema": d.schema(reflect.TypeOf(op.Request)),
+			}},
+		}
+	}
+	if op.Scope != "" {
+		operation["security"] = []any{
+			map[string]any{"apiKey": []string{}},
+			map[string]any{"bearer": []string{}},
+		}
+		operation["description"] = strings.TrimSpace(op.Description + "\n\nRequires the `" + op.Scope + "` scope.")
+	}
+
+	// OpenAPI paths name wildcards without the ServeMux remainder marker
+	path = pathParams.ReplaceAllString(path, "{$1}")
+	if d.paths[path] == nil {
+		d.paths[path] = make(map[string]any)
+	}
+	d.paths[path][strings.ToLower(method)] = operation
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// schema returns the JSON schema of t as encoding/json marshals it. Named structs
+// are added to the components and referenced
+func (d *Document) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == objectIDType:
+		return map[string]any{"type": "string", "description": "Hex-encoded ObjectID"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": d.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": d.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := d.schemas[name]; !ok {
+			d.schemas[name] = nil // placeholder for recursive types
+			d.schemas[name] = d.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema returns the object schema of a struct, with the fields of embedded
+// structs promoted as encoding/json does. Fields without omitempty or omitzero are required
+func (d *Document) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			if field.Anonymous && name == "" {
+				embedded := field.Type
+				if embedded.Kind() == reflect.Pointer {
+					embedded = embedded.Elem()
+				}
+				if embedded.Kind() == reflect.Struct {
+					addFields(embedded)
+					continue
+				}
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = d.schema(field.Type)
+			if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// componentName names the schema of a named type, capitalized for unexported types
+func componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+// ServeHTTP serves the document as JSON
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	document := map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": d.title, "version": d.version},
+		"paths":   d.paths,
+		"components": map[string]any{
+			"schemas": d.schemas,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(document)
+}
@@ -0,0 +1,17 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// swaggerPage loads Swagger UI from the unpkg CDN and points it at /openapi.json
+//
+//go:embed swagger.html
+var swaggerPage []byte
+
+// SwaggerUI serves a Swagger UI page for browsing and trying out the API
+func SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(swaggerPage)
+}
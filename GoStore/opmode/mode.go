@@ -0,0 +1,141 @@
+// Package opmode holds the operational mode of the service, which lets operators
+// pause ingestion while the read API stays up, e.g. during a storage incident,
+// or stop serving reads while ingestion carries on during a read-path incident
+package opmode
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/metrics"
+)
+
+// Mode is an operational mode of the service
+type Mode string
+
+const (
+	// ReadWrite ingests messages and serves reads, as the service normally does
+	ReadWrite Mode = "read_write"
+	// ReadOnly pauses ingestion and serves reads
+	ReadOnly Mode = "read_only"
+	// WriteOnly ingests messages and refuses reads
+	WriteOnly Mode = "write_only"
+)
+
+// Modes lists every mode
+var Modes = []Mode{ReadWrite, ReadOnly, WriteOnly}
+
+// Parse returns the mode named s
+func Parse(s string) (Mode, error) {
+	for _, m := range Modes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown service mode %q; must be read_write, read_only or write_only", s)
+}
+
+// Reads reports whether the read API is served in the mode
+func (m Mode) Reads() bool {
+	return m != WriteOnly
+}
+
+// Writes reports whether messages are ingested in the mode
+func (m Mode) Writes() bool {
+	return m != ReadOnly
+}
+
+// Consumer is an ingestion consumer the mode pauses while it refuses writes
+type Consumer interface {
+	Pause() bool
+	Resume() bool
+}
+
+// Switch holds the current mode of the service, pausing its consumers while the
+// mode refuses writes. It only resumes the consumers it paused, so those an
+// operator paused before stay paused
+type Switch struct {
+	mu        sync.RWMutex
+	mode      Mode
+	since     time.Time
+	consumers map[string]Consumer
+	paused    map[string]bool // consumers paused by the switch, by name
+}
+
+// NewSwitch creates a switch in the given mode
+func NewSwitch(mode Mode) *Switch {
+	s := &Switch{
+		mode:      mode,
+		since:     time.Now().UTC(),
+		consumers: make(map[string]Consumer),
+		paused:    make(map[string]bool),
+	}
+	setMetric(mode)
+	return s
+}
+
+// AddConsumer registers a consumer by name, pausing it at once if the current
+// mode refuses writes
+func (s *Switch) AddConsumer(name string, consumer Consumer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.consumers[name] = consumer
+	if !s.mode.Writes() && consumer.Pause() {
+		s.paused[name] = true
+	}
+}
+
+// Mode returns the current mode
+func (s *Switch) Mode() Mode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mode
+}
+
+// Since returns when the current mode was entered
+func (s *Switch) Since() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.since
+}
+
+// Set changes the mode, pausing or resuming the consumers as it requires, and
+// reports whether it changed
+func (s *Switch) Set(mode Mode) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if mode == s.mode {
+		return false
+	}
+	previous := s.mode
+	s.mode, s.since = mode, time.Now().UTC()
+
+	switch {
+	case previous.Writes() && !mode.Writes():
+		for name, consumer := range s.consumers {
+			if consumer.Pause() {
+				s.paused[name] = true
+			}
+		}
+	case !previous.Writes() && mode.Writes():
+		for name := range s.paused {
+			s.consumers[name].Resume()
+		}
+		clear(s.paused)
+	}
+
+	setMetric(mode)
+	slog.Warn("Service mode changed", "mode", mode, "previous", previous)
+	return true
+}
+
+// setMetric records mode as the current one in the service mode gauge
+func setMetric(mode Mode) {
+	for _, m := range Modes {
+		metrics.SetServiceMode(string(m), m == mode)
+	}
+}
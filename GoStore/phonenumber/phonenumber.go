@@ -0,0 +1,101 @@
+// Package phonenumber normalizes phone numbers to E.164, so the same number
+// written in international or national form identifies the same user
+package phonenumber
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// E.164 numbers have at most 15 digits after the +; shorter ones than this are
+// not subscriber numbers
+const (
+	minDigits = 8
+	maxDigits = 15
+)
+
+// ErrInvalid is returned for numbers that can't be normalized
+var ErrInvalid = errors.New("invalid phone number")
+
+// formatting are the characters numbers are commonly written with, ignored when normalizing
+const formatting = " -.()/\t"
+
+// Normalizer converts phone numbers to E.164, reading numbers without an
+// international prefix as national numbers of a default region. A nil
+// Normalizer leaves numbers as they are
+type Normalizer struct {
+	region *region // nil reads every number without a prefix as international
+}
+
+// NewNormalizer creates a normalizer for numbers written in defaultRegion, an
+// ISO 3166-1 alpha-2 code; empty reads numbers without a + as international
+// numbers missing it
+func NewNormalizer(defaultRegion string) (*Normalizer, error) {
+	if defaultRegion == "" {
+		return &Normalizer{}, nil
+	}
+	r, ok := regions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported default region %q", defaultRegion)
+	}
+	return &Normalizer{region: &r}, nil
+}
+
+// IsSupportedRegion reports whether code is a region NewNormalizer accepts
+func IsSupportedRegion(code string) bool {
+	_, ok := regions[strings.ToUpper(code)]
+	return ok
+}
+
+// Normalize returns number in E.164 form, + followed by the country code and
+// subscriber number. Spaces, dashes, dots, slashes and parentheses are
+// ignored. Numbers starting with + or the default region's international
+// prefix are international; others are national numbers of the default region,
+// with or without its trunk prefix, unless they are too long to be, when they
+// are read as international numbers without the +. Normalized numbers are
+// returned as they are, so normalizing is idempotent
+func (n *Normalizer) Normalize(number string) (string, error) {
+	if n == nil {
+		return number, nil
+	}
+	digits := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(formatting, r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(number))
+
+	international := strings.HasPrefix(digits, "+")
+	digits = strings.TrimPrefix(digits, "+")
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return "", ErrInvalid
+	}
+
+	switch {
+	case international:
+	case n.region == nil:
+		// Without a region, a leading 0 can only start a national number or a prefix
+		if strings.HasPrefix(digits, "0") {
+			return "", ErrInvalid
+		}
+	case strings.HasPrefix(digits, n.region.internationalPrefix):
+		digits = strings.TrimPrefix(digits, n.region.internationalPrefix)
+	default:
+		national := digits
+		if n.region.trunkPrefix != "" {
+			national = strings.TrimPrefix(national, n.region.trunkPrefix)
+		}
+		if len(national) <= n.region.maxNationalDigits {
+			digits = n.region.countryCode + national
+		} else {
+			// Dialled with the country code but without the +, perhaps after the trunk prefix
+			digits = national
+		}
+	}
+
+	if len(digits) < minDigits || len(digits) > maxDigits || digits[0] == '0' {
+		return "", ErrInvalid
+	}
+	return "+" + digits, nil
+}
@@ -0,0 +1,68 @@
+package phonenumber
+
+// region holds how numbers are dialled within a country
+type region struct {
+	countryCode         string
+	trunkPrefix         string // dialled before national numbers; empty where there is none
+	internationalPrefix string // dialled before international numbers
+	maxNationalDigits   int    // longest national significant number
+}
+
+// regions are the default regions numbers can be normalized for, by ISO 3166-1 alpha-2 code
+var regions = map[string]region{
+	"AE": {"971", "0", "00", 9},
+	"AR": {"54", "0", "00", 11},
+	"AT": {"43", "0", "00", 13},
+	"AU": {"61", "0", "0011", 9},
+	"BD": {"880", "0", "00", 10},
+	"BE": {"32", "0", "00", 9},
+	"BR": {"55", "0", "00", 11},
+	"CA": {"1", "1", "011", 10},
+	"CH": {"41", "0", "00", 9},
+	"CL": {"56", "", "00", 9},
+	"CN": {"86", "0", "00", 11},
+	"CO": {"57", "", "00", 10},
+	"CZ": {"420", "", "00", 9},
+	"DE": {"49", "0", "00", 13},
+	"DK": {"45", "", "00", 8},
+	"EG": {"20", "0", "00", 10},
+	"ES": {"34", "", "00", 9},
+	"FI": {"358", "0", "00", 12},
+	"FR": {"33", "0", "00", 9},
+	"GB": {"44", "0", "00", 10},
+	"GH": {"233", "0", "00", 9},
+	"GR": {"30", "", "00", 10},
+	"HK": {"852", "", "001", 8},
+	"HU": {"36", "06", "00", 9},
+	"ID": {"62", "0", "001", 12},
+	"IE": {"353", "0", "00", 9},
+	"IL": {"972", "0", "00", 9},
+	"IN": {"91", "0", "00", 10},
+	"IT": {"39", "", "00", 11}, // the leading 0 of landlines is part of the number
+	"JP": {"81", "0", "010", 10},
+	"KE": {"254", "0", "000", 9},
+	"KR": {"82", "0", "001", 10},
+	"MA": {"212", "0", "00", 9},
+	"MX": {"52", "", "00", 10},
+	"MY": {"60", "0", "00", 10},
+	"NG": {"234", "0", "009", 10},
+	"NL": {"31", "0", "00", 9},
+	"NO": {"47", "", "00", 8},
+	"NZ": {"64", "0", "00", 10},
+	"PH": {"63", "0", "00", 10},
+	"PK": {"92", "0", "00", 10},
+	"PL": {"48", "", "00", 9},
+	"PT": {"351", "", "00", 9},
+	"RO": {"40", "0", "00", 9},
+	"RU": {"7", "8", "810", 10},
+	"SA": {"966", "0", "00", 9},
+	"SE": {"46", "0", "00", 9},
+	"SG": {"65", "", "000", 8},
+	"TH": {"66", "0", "001", 9},
+	"TR": {"90", "0", "00", 10},
+	"TW": {"886", "0", "002", 9},
+	"UA": {"380", "0", "00", 9},
+	"US": {"1", "1", "011", 10},
+	"VN": {"84", "0", "00", 10},
+	"ZA": {"27", "0", "00", 9},
+}
@@ -0,0 +1,82 @@
+// Package problem writes HTTP error responses as RFC 7807 problem details, the
+// one error body of every REST endpoint, so clients can tell failures apart by
+// status and type without parsing messages
+package problem
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/ramG-reddy/sms-store/redact"
+	"github.com/ramG-reddy/sms-store/requestid"
+)
+
+// MediaType is the content type of problem details
+const MediaType = "application/problem+json"
+
+// DefaultType is the type of problems with no more meaning than their status,
+// whose title is the status text (RFC 7807 section 4.2)
+const DefaultType = "about:blank"
+
+// Problem is the body of an error response
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Write sends a problem of the default type for status, explaining it with
+// detail, masked when written within Redact while redaction is on. The request
+// ID is read back from the response header set by the request ID middleware
+func Write(w http.ResponseWriter, status int, detail string) {
+	p := Problem{
+		Type:      DefaultType,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    redactorOf(w).String(detail),
+		RequestID: w.Header().Get(requestid.Header),
+	}
+	w.Header().Set("Content-Type", MediaType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		slog.Error("Error encoding problem response", "error", err)
+	}
+}
+
+// Redact masks personal data in the details of the problems next writes, with
+// redactor while it is enabled
+func Redact(redactor *redact.Redactor, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&redactingWriter{ResponseWriter: w, redactor: redactor}, r)
+	})
+}
+
+// redactingWriter carries the redactor of Redact to Write
+type redactingWriter struct {
+	http.ResponseWriter
+	redactor *redact.Redactor
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer for flushing
+func (w *redactingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// redactorOf returns the redactor of the Redact handler w was written through,
+// looking past the writers of middleware inside it, or nil outside Redact
+func redactorOf(w http.ResponseWriter) *redact.Redactor {
+	for {
+		switch rw := w.(type) {
+		case *redactingWriter:
+			return rw.redactor
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
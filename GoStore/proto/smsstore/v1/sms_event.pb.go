@@ -0,0 +1,194 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: smsstore/v1/sms_event.proto
+
+package smsstorev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SMSEvent is the Protobuf encoding of the SMS event published to sms.events,
+// consumed when KAFKA_MESSAGE_FORMAT=protobuf. It mirrors models.KafkaEvent
+// Add new fields with new numbers; never reuse or renumber existing ones
+type SMSEvent struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	EventId string                 `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// Vendor-assigned ID used to match delivery receipts
+	ProviderMessageId string `protobuf:"bytes,2,opt,name=provider_message_id,json=providerMessageId,proto3" json:"provider_message_id,omitempty"`
+	// Brand the message belongs to; defaulted by the consumer when empty
+	TenantId      string                 `protobuf:"bytes,3,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	UserId        string                 `protobuf:"bytes,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	PhoneNumber   string                 `protobuf:"bytes,5,opt,name=phone_number,json=phoneNumber,proto3" json:"phone_number,omitempty"`
+	Message       string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	Status        string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SMSEvent) Reset() {
+	*x = SMSEvent{}
+	mi := &file_smsstore_v1_sms_event_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SMSEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SMSEvent) ProtoMessage() {}
+
+func (x *SMSEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_smsstore_v1_sms_event_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SMSEvent.ProtoReflect.Descriptor instead.
+func (*SMSEvent) Descriptor() ([]byte, []int) {
+	return file_smsstore_v1_sms_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SMSEvent) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *SMSEvent) GetProviderMessageId() string {
+	if x != nil {
+		return x.ProviderMessageId
+	}
+	return ""
+}
+
+func (x *SMSEvent) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *SMSEvent) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SMSEvent) GetPhoneNumber() string {
+	if x != nil {
+		return x.PhoneNumber
+	}
+	return ""
+}
+
+func (x *SMSEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *SMSEvent) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SMSEvent) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+var File_smsstore_v1_sms_event_proto protoreflect.FileDescriptor
+
+const file_smsstore_v1_sms_event_proto_rawDesc = "" +
+	"\n" +
+	"\x1bsmsstore/v1/sms_event.proto\x12\vsmsstore.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x9b\x02\n" +
+	"\bSMSEvent\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\tR\aeventId\x12.\n" +
+	"\x13provider_message_id\x18\x02 \x01(\tR\x11providerMessageId\x12\x1b\n" +
+	"\ttenant_id\x18\x03 \x01(\tR\btenantId\x12\x17\n" +
+	"\auser_id\x18\x04 \x01(\tR\x06userId\x12!\n" +
+	"\fphone_number\x18\x05 \x01(\tR\vphoneNumber\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAtB>Z<github.com/ramG-reddy/sms-store/proto/smsstore/v1;smsstorev1b\x06proto3"
+
+var (
+	file_smsstore_v1_sms_event_proto_rawDescOnce sync.Once
+	file_smsstore_v1_sms_event_proto_rawDescData []byte
+)
+
+func file_smsstore_v1_sms_event_proto_rawDescGZIP() []byte {
+	file_smsstore_v1_sms_event_proto_rawDescOnce.Do(func() {
+		file_smsstore_v1_sms_event_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_smsstore_v1_sms_event_proto_rawDesc), len(file_smsstore_v1_sms_event_proto_rawDesc)))
+	})
+	return file_smsstore_v1_sms_event_proto_rawDescData
+}
+
+var file_smsstore_v1_sms_event_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_smsstore_v1_sms_event_proto_goTypes = []any{
+	(*SMSEvent)(nil),              // 0: smsstore.v1.SMSEvent
+	(*timestamppb.Timestamp)(nil), // 1: google.protobuf.Timestamp
+}
+var file_smsstore_v1_sms_event_proto_depIdxs = []int32{
+	1, // 0: smsstore.v1.SMSEvent.created_at:type_name -> google.protobuf.Timestamp
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_smsstore_v1_sms_event_proto_init() }
+func file_smsstore_v1_sms_event_proto_init() {
+	if File_smsstore_v1_sms_event_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_smsstore_v1_sms_event_proto_rawDesc), len(file_smsstore_v1_sms_event_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_smsstore_v1_sms_event_proto_goTypes,
+		DependencyIndexes: file_smsstore_v1_sms_event_proto_depIdxs,
+		MessageInfos:      file_smsstore_v1_sms_event_proto_msgTypes,
+	}.Build()
+	File_smsstore_v1_sms_event_proto = out.File
+	file_smsstore_v1_sms_event_proto_goTypes = nil
+	file_smsstore_v1_sms_event_proto_depIdxs = nil
+}
@@ -0,0 +1,444 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: smsstore/v1/sms_store.proto
+
+package smsstorev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SMSRecord mirrors models.SMSRecord
+type SMSRecord struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Id                string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	MessageId         string                 `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	ProviderMessageId string                 `protobuf:"bytes,3,opt,name=provider_message_id,json=providerMessageId,proto3" json:"provider_message_id,omitempty"`
+	UserId            string                 `protobuf:"bytes,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	PhoneNumber       string                 `protobuf:"bytes,5,opt,name=phone_number,json=phoneNumber,proto3" json:"phone_number,omitempty"`
+	Message           string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	Status            string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	StatusHistory     []*StatusChange        `protobuf:"bytes,8,rep,name=status_history,json=statusHistory,proto3" json:"status_history,omitempty"`
+	CreatedAt         *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt         *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *SMSRecord) Reset() {
+	*x = SMSRecord{}
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SMSRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SMSRecord) ProtoMessage() {}
+
+func (x *SMSRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SMSRecord.ProtoReflect.Descriptor instead.
+func (*SMSRecord) Descriptor() ([]byte, []int) {
+	return file_smsstore_v1_sms_store_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SMSRecord) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SMSRecord) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *SMSRecord) GetProviderMessageId() string {
+	if x != nil {
+		return x.ProviderMessageId
+	}
+	return ""
+}
+
+func (x *SMSRecord) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SMSRecord) GetPhoneNumber() string {
+	if x != nil {
+		return x.PhoneNumber
+	}
+	return ""
+}
+
+func (x *SMSRecord) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *SMSRecord) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SMSRecord) GetStatusHistory() []*StatusChange {
+	if x != nil {
+		return x.StatusHistory
+	}
+	return nil
+}
+
+func (x *SMSRecord) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *SMSRecord) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+// StatusChange is a single entry in a record's status history
+type StatusChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	ChangedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusChange) Reset() {
+	*x = StatusChange{}
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusChange) ProtoMessage() {}
+
+func (x *StatusChange) ProtoReflect() protoreflect.Message {
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusChange.ProtoReflect.Descriptor instead.
+func (*StatusChange) Descriptor() ([]byte, []int) {
+	return file_smsstore_v1_sms_store_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StatusChange) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *StatusChange) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *StatusChange) GetChangedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return nil
+}
+
+type GetUserMessagesRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Maximum number of messages to return; 0 returns all messages
+	Limit         int64 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserMessagesRequest) Reset() {
+	*x = GetUserMessagesRequest{}
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserMessagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserMessagesRequest) ProtoMessage() {}
+
+func (x *GetUserMessagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserMessagesRequest.ProtoReflect.Descriptor instead.
+func (*GetUserMessagesRequest) Descriptor() ([]byte, []int) {
+	return file_smsstore_v1_sms_store_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetUserMessagesRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *GetUserMessagesRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetUserMessagesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Messages      []*SMSRecord           `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserMessagesResponse) Reset() {
+	*x = GetUserMessagesResponse{}
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserMessagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserMessagesResponse) ProtoMessage() {}
+
+func (x *GetUserMessagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserMessagesResponse.ProtoReflect.Descriptor instead.
+func (*GetUserMessagesResponse) Descriptor() ([]byte, []int) {
+	return file_smsstore_v1_sms_store_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetUserMessagesResponse) GetMessages() []*SMSRecord {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+type GetMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMessageRequest) Reset() {
+	*x = GetMessageRequest{}
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMessageRequest) ProtoMessage() {}
+
+func (x *GetMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smsstore_v1_sms_store_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMessageRequest.ProtoReflect.Descriptor instead.
+func (*GetMessageRequest) Descriptor() ([]byte, []int) {
+	return file_smsstore_v1_sms_store_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetMessageRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+var File_smsstore_v1_sms_store_proto protoreflect.FileDescriptor
+
+const file_smsstore_v1_sms_store_proto_rawDesc = "" +
+	"\n" +
+	"\x1bsmsstore/v1/sms_store.proto\x12\vsmsstore.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x90\x03\n" +
+	"\tSMSRecord\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x02 \x01(\tR\tmessageId\x12.\n" +
+	"\x13provider_message_id\x18\x03 \x01(\tR\x11providerMessageId\x12\x17\n" +
+	"\auser_id\x18\x04 \x01(\tR\x06userId\x12!\n" +
+	"\fphone_number\x18\x05 \x01(\tR\vphoneNumber\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x12@\n" +
+	"\x0estatus_history\x18\b \x03(\v2\x19.smsstore.v1.StatusChangeR\rstatusHistory\x129\n" +
+	"\n" +
+	"created_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"y\n" +
+	"\fStatusChange\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x129\n" +
+	"\n" +
+	"changed_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tchangedAt\"G\n" +
+	"\x16GetUserMessagesRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x03R\x05limit\"M\n" +
+	"\x17GetUserMessagesResponse\x122\n" +
+	"\bmessages\x18\x01 \x03(\v2\x16.smsstore.v1.SMSRecordR\bmessages\"#\n" +
+	"\x11GetMessageRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id2\x8a\x02\n" +
+	"\x0fSMSStoreService\x12\\\n" +
+	"\x0fGetUserMessages\x12#.smsstore.v1.GetUserMessagesRequest\x1a$.smsstore.v1.GetUserMessagesResponse\x12D\n" +
+	"\n" +
+	"GetMessage\x12\x1e.smsstore.v1.GetMessageRequest\x1a\x16.smsstore.v1.SMSRecord\x12S\n" +
+	"\x12StreamUserMessages\x12#.smsstore.v1.GetUserMessagesRequest\x1a\x16.smsstore.v1.SMSRecord0\x01B>Z<github.com/ramG-reddy/sms-store/proto/smsstore/v1;smsstorev1b\x06proto3"
+
+var (
+	file_smsstore_v1_sms_store_proto_rawDescOnce sync.Once
+	file_smsstore_v1_sms_store_proto_rawDescData []byte
+)
+
+func file_smsstore_v1_sms_store_proto_rawDescGZIP() []byte {
+	file_smsstore_v1_sms_store_proto_rawDescOnce.Do(func() {
+		file_smsstore_v1_sms_store_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_smsstore_v1_sms_store_proto_rawDesc), len(file_smsstore_v1_sms_store_proto_rawDesc)))
+	})
+	return file_smsstore_v1_sms_store_proto_rawDescData
+}
+
+var file_smsstore_v1_sms_store_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_smsstore_v1_sms_store_proto_goTypes = []any{
+	(*SMSRecord)(nil),               // 0: smsstore.v1.SMSRecord
+	(*StatusChange)(nil),            // 1: smsstore.v1.StatusChange
+	(*GetUserMessagesRequest)(nil),  // 2: smsstore.v1.GetUserMessagesRequest
+	(*GetUserMessagesResponse)(nil), // 3: smsstore.v1.GetUserMessagesResponse
+	(*GetMessageRequest)(nil),       // 4: smsstore.v1.GetMessageRequest
+	(*timestamppb.Timestamp)(nil),   // 5: google.protobuf.Timestamp
+}
+var file_smsstore_v1_sms_store_proto_depIdxs = []int32{
+	1, // 0: smsstore.v1.SMSRecord.status_history:type_name -> smsstore.v1.StatusChange
+	5, // 1: smsstore.v1.SMSRecord.created_at:type_name -> google.protobuf.Timestamp
+	5, // 2: smsstore.v1.SMSRecord.updated_at:type_name -> google.protobuf.Timestamp
+	5, // 3: smsstore.v1.StatusChange.changed_at:type_name -> google.protobuf.Timestamp
+	0, // 4: smsstore.v1.GetUserMessagesResponse.messages:type_name -> smsstore.v1.SMSRecord
+	2, // 5: smsstore.v1.SMSStoreService.GetUserMessages:input_type -> smsstore.v1.GetUserMessagesRequest
+	4, // 6: smsstore.v1.SMSStoreService.GetMessage:input_type -> smsstore.v1.GetMessageRequest
+	2, // 7: smsstore.v1.SMSStoreService.StreamUserMessages:input_type -> smsstore.v1.GetUserMessagesRequest
+	3, // 8: smsstore.v1.SMSStoreService.GetUserMessages:output_type -> smsstore.v1.GetUserMessagesResponse
+	0, // 9: smsstore.v1.SMSStoreService.GetMessage:output_type -> smsstore.v1.SMSRecord
+	0, // 10: smsstore.v1.SMSStoreService.StreamUserMessages:output_type -> smsstore.v1.SMSRecord
+	8, // [8:11] is the sub-list for method output_type
+	5, // [5:8] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_smsstore_v1_sms_store_proto_init() }
+func file_smsstore_v1_sms_store_proto_init() {
+	if File_smsstore_v1_sms_store_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_smsstore_v1_sms_store_proto_rawDesc), len(file_smsstore_v1_sms_store_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_smsstore_v1_sms_store_proto_goTypes,
+		DependencyIndexes: file_smsstore_v1_sms_store_proto_depIdxs,
+		MessageInfos:      file_smsstore_v1_sms_store_proto_msgTypes,
+	}.Build()
+	File_smsstore_v1_sms_store_proto = out.File
+	file_smsstore_v1_sms_store_proto_goTypes = nil
+	file_smsstore_v1_sms_store_proto_depIdxs = nil
+}
@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: smsstore/v1/sms_store.proto
+
+package smsstorev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SMSStoreService_GetUserMessages_FullMethodName    = "/smsstore.v1.SMSStoreService/GetUserMessages"
+	SMSStoreService_GetMessage_FullMethodName         = "/smsstore.v1.SMSStoreService/GetMessage"
+	SMSStoreService_StreamUserMessages_FullMethodName = "/smsstore.v1.SMSStoreService/StreamUserMessages"
+)
+
+// SMSStoreServiceClient is the client API for SMSStoreService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SMSStoreService exposes read access to stored SMS records for internal services
+type SMSStoreServiceClient interface {
+	// GetUserMessages returns a user's messages, newest first
+	GetUserMessages(ctx context.Context, in *GetUserMessagesRequest, opts ...grpc.CallOption) (*GetUserMessagesResponse, error)
+	// GetMessage returns a single message by its document ID
+	GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*SMSRecord, error)
+	// StreamUserMessages streams a user's messages, newest first, without buffering the result set
+	StreamUserMessages(ctx context.Context, in *GetUserMessagesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SMSRecord], error)
+}
+
+type sMSStoreServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSMSStoreServiceClient(cc grpc.ClientConnInterface) SMSStoreServiceClient {
+	return &sMSStoreServiceClient{cc}
+}
+
+func (c *sMSStoreServiceClient) GetUserMessages(ctx context.Context, in *GetUserMessagesRequest, opts ...grpc.CallOption) (*GetUserMessagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUserMessagesResponse)
+	err := c.cc.Invoke(ctx, SMSStoreService_GetUserMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sMSStoreServiceClient) GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*SMSRecord, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SMSRecord)
+	err := c.cc.Invoke(ctx, SMSStoreService_GetMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sMSStoreServiceClient) StreamUserMessages(ctx context.Context, in *GetUserMessagesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SMSRecord], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SMSStoreService_ServiceDesc.Streams[0], SMSStoreService_StreamUserMessages_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetUserMessagesRequest, SMSRecord]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SMSStoreService_StreamUserMessagesClient = grpc.ServerStreamingClient[SMSRecord]
+
+// SMSStoreServiceServer is the server API for SMSStoreService service.
+// All implementations must embed UnimplementedSMSStoreServiceServer
+// for forward compatibility.
+//
+// SMSStoreService exposes read access to stored SMS records for internal services
+type SMSStoreServiceServer interface {
+	// GetUserMessages returns a user's messages, newest first
+	GetUserMessages(context.Context, *GetUserMessagesRequest) (*GetUserMessagesResponse, error)
+	// GetMessage returns a single message by its document ID
+	GetMessage(context.Context, *GetMessageRequest) (*SMSRecord, error)
+	// StreamUserMessages streams a user's messages, newest first, without buffering the result set
+	StreamUserMessages(*GetUserMessagesRequest, grpc.ServerStreamingServer[SMSRecord]) error
+	mustEmbedUnimplementedSMSStoreServiceServer()
+}
+
+// UnimplementedSMSStoreServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSMSStoreServiceServer struct{}
+
+func (UnimplementedSMSStoreServiceServer) GetUserMessages(context.Context, *GetUserMessagesRequest) (*GetUserMessagesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetUserMessages not implemented")
+}
+func (UnimplementedSMSStoreServiceServer) GetMessage(context.Context, *GetMessageRequest) (*SMSRecord, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetMessage not implemented")
+}
+func (UnimplementedSMSStoreServiceServer) StreamUserMessages(*GetUserMessagesRequest, grpc.ServerStreamingServer[SMSRecord]) error {
+	return status.Error(codes.Unimplemented, "method StreamUserMessages not implemented")
+}
+func (UnimplementedSMSStoreServiceServer) mustEmbedUnimplementedSMSStoreServiceServer() {}
+func (UnimplementedSMSStoreServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeSMSStoreServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SMSStoreServiceServer will
+// result in compilation errors.
+type UnsafeSMSStoreServiceServer interface {
+	mustEmbedUnimplementedSMSStoreServiceServer()
+}
+
+func RegisterSMSStoreServiceServer(s grpc.ServiceRegistrar, srv SMSStoreServiceServer) {
+	// If the following call panics, it indicates UnimplementedSMSStoreServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SMSStoreService_ServiceDesc, srv)
+}
+
+func _SMSStoreService_GetUserMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SMSStoreServiceServer).GetUserMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SMSStoreService_GetUserMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SMSStoreServiceServer).GetUserMessages(ctx, req.(*GetUserMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SMSStoreService_GetMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SMSStoreServiceServer).GetMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SMSStoreService_GetMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SMSStoreServiceServer).GetMessage(ctx, req.(*GetMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SMSStoreService_StreamUserMessages_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetUserMessagesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SMSStoreServiceServer).StreamUserMessages(m, &grpc.GenericServerStream[GetUserMessagesRequest, SMSRecord]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SMSStoreService_StreamUserMessagesServer = grpc.ServerStreamingServer[SMSRecord]
+
+// SMSStoreService_ServiceDesc is the grpc.ServiceDesc for SMSStoreService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SMSStoreService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "smsstore.v1.SMSStoreService",
+	HandlerType: (*SMSStoreServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetUserMessages",
+			Handler:    _SMSStoreService_GetUserMessages_Handler,
+		},
+		{
+			MethodName: "GetMessage",
+			Handler:    _SMSStoreService_GetMessage_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUserMessages",
+			Handler:       _SMSStoreService_StreamUserMessages_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "smsstore/v1/sms_store.proto",
+}
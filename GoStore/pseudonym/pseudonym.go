@@ -0,0 +1,41 @@
+// Package pseudonym replaces phone numbers with keyed hashes, for deployments
+// that may not store raw MSISDNs. The same number always maps to the same
+// pseudonym, so records can still be looked up by number
+package pseudonym
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// prefix marks pseudonyms, which read hmac:<base64 HMAC-SHA256 of the number>
+const prefix = "hmac:"
+
+// Hasher maps phone numbers to pseudonyms with HMAC-SHA256 keyed by a secret
+// pepper. A nil Hasher leaves numbers as they are
+type Hasher struct {
+	pepper []byte
+}
+
+// NewHasher creates a hasher keyed by pepper
+func NewHasher(pepper string) *Hasher {
+	return &Hasher{pepper: []byte(pepper)}
+}
+
+// Hash returns the pseudonym of a phone number. Empty values and pseudonyms are
+// returned as they are, so hashing is idempotent
+func (h *Hasher) Hash(phoneNumber string) string {
+	if h == nil || phoneNumber == "" || IsPseudonym(phoneNumber) {
+		return phoneNumber
+	}
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(phoneNumber))
+	return prefix + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IsPseudonym reports whether value was produced by a Hasher
+func IsPseudonym(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
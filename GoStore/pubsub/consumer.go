@@ -0,0 +1,326 @@
+// Package pubsub consumes SMS events from Google Cloud Pub/Sub subscriptions,
+// for deployments on GCP without Kafka
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/requestid"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+// backend labels the metrics of the consumer
+const backend = "pubsub"
+
+const (
+	// connectTimeout bounds creating the client and looking up the subscriptions
+	connectTimeout = 10 * time.Second
+
+	// stopTimeout bounds how long Stop waits for the batches in progress to be stored
+	stopTimeout = 10 * time.Second
+
+	// retryBase is the delay before receiving again after Receive failed, and
+	// before retrying storage after it was unavailable
+	retryBase = time.Second
+)
+
+// Config identifies the subscriptions to consume, and the flow control of each
+type Config struct {
+	ProjectID     string
+	Subscriptions map[string]string // subscription ID to the direction of its records, e.g. models.DirectionInbound
+
+	// DefaultTenantID is assigned to events that don't carry a tenantId
+	DefaultTenantID string
+
+	// Validation configures strict validation of events; off by default
+	Validation ingest.Validation
+
+	// Flow control: each subscription pulls over Streams streaming pulls, and
+	// holds no more than MaxOutstandingMessages messages, or MaxOutstandingBytes
+	// of them, that are received but not yet acknowledged
+	Streams                int
+	MaxOutstandingMessages int
+	MaxOutstandingBytes    int
+
+	// Messages are stored in batches of up to BatchSize, waiting up to BatchWait
+	// for a batch to fill
+	BatchSize int
+	BatchWait time.Duration
+
+	// Stages are custom processors added to the ingest pipeline, see ingest.Stage
+	Stages []ingest.Stage
+}
+
+// Consumer stores the SMS events of Pub/Sub subscriptions, acknowledging each
+// message once it is stored
+type Consumer struct {
+	cfg         Config
+	client      *pubsub.Client
+	subscribers map[string]*pubsub.Subscriber   // by subscription ID
+	pipelines   map[string]*ingest.Pipeline     // by subscription ID
+	messages    map[string]chan *pubsub.Message // by subscription ID, from Receive to the batching loop
+
+	// stopChan stops the batching loops, and cancelling receiveCtx then ends Receive
+	stopChan      chan struct{}
+	receiveCtx    context.Context
+	cancelReceive context.CancelFunc
+	batchers      sync.WaitGroup
+	receivers     sync.WaitGroup
+	done          chan struct{}
+
+	// Pausing stops taking new messages; batches already collected are still
+	// stored and acknowledged. Once the flow control limits are reached no more
+	// are pulled, and the leases of those received are extended meanwhile
+	*ingest.Gate
+
+	// running, receiving and inFlight are reported by Check and InFlight
+	running   atomic.Int32
+	receiving atomic.Int32
+	inFlight  atomic.Int64
+}
+
+// StartConsumer creates a Pub/Sub client with Application Default Credentials,
+// or for the emulator named by PUBSUB_EMULATOR_HOST, and begins receiving from
+// cfg.Subscriptions in background goroutines. The subscriptions themselves
+// must already exist
+func StartConsumer(cfg Config, smsService *services.SMSService) (*Consumer, error) {
+	slog.Info("Starting Pub/Sub consumer", "project", cfg.ProjectID, "subscriptions", cfg.Subscriptions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	client, err := pubsub.NewClient(ctx, cfg.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Pub/Sub client: %w", err)
+	}
+
+	receiveCtx, cancelReceive := context.WithCancel(context.Background())
+	c := &Consumer{
+		cfg:           cfg,
+		client:        client,
+		subscribers:   make(map[string]*pubsub.Subscriber, len(cfg.Subscriptions)),
+		pipelines:     make(map[string]*ingest.Pipeline, len(cfg.Subscriptions)),
+		messages:      make(map[string]chan *pubsub.Message, len(cfg.Subscriptions)),
+		stopChan:      make(chan struct{}),
+		receiveCtx:    receiveCtx,
+		cancelReceive: cancelReceive,
+		done:          make(chan struct{}),
+	}
+	c.Gate = ingest.NewGate("Pub/Sub consumer", c.stopChan, retryBase)
+	for subscription, direction := range cfg.Subscriptions {
+		pipeline, err := ingest.NewPipeline(ingest.PipelineConfig{
+			DefaultTenantID: cfg.DefaultTenantID,
+			Validation:      cfg.Validation,
+			Direction:       direction,
+			Persist:         ingest.Persist(smsService),
+			Stages:          cfg.Stages,
+		})
+		subscriber := client.Subscriber(subscription)
+		if err == nil {
+			err = c.lookup(ctx, subscriber)
+		}
+		if err != nil {
+			cancelReceive()
+			client.Close()
+			return nil, fmt.Errorf("subscription %s: %w", subscription, err)
+		}
+
+		subscriber.ReceiveSettings.NumGoroutines = cfg.Streams
+		subscriber.ReceiveSettings.MaxOutstandingMessages = cfg.MaxOutstandingMessages
+		subscriber.ReceiveSettings.MaxOutstandingBytes = cfg.MaxOutstandingBytes
+		c.subscribers[subscription] = subscriber
+		c.pipelines[subscription] = pipeline
+		c.messages[subscription] = make(chan *pubsub.Message)
+	}
+
+	for subscription := range cfg.Subscriptions {
+		c.receivers.Add(1)
+		go func() {
+			defer c.receivers.Done()
+			c.receive(subscription)
+		}()
+		c.batchers.Add(1)
+		go func() {
+			defer c.batchers.Done()
+			c.consume(subscription)
+		}()
+	}
+	go func() {
+		c.batchers.Wait()
+		c.cancelReceive()
+		c.receivers.Wait()
+		close(c.done)
+	}()
+
+	slog.Info("Pub/Sub consumer started successfully")
+	return c, nil
+}
+
+// lookup checks that the subscription exists and can be read
+func (c *Consumer) lookup(ctx context.Context, subscriber *pubsub.Subscriber) error {
+	_, err := c.client.SubscriptionAdminClient.GetSubscription(ctx, &pubsubpb.GetSubscriptionRequest{Subscription: subscriber.String()})
+	if err != nil {
+		return fmt.Errorf("failed to look up Pub/Sub subscription: %w", err)
+	}
+	return nil
+}
+
+// receive pulls the messages of one subscription and hands them to its
+// batching loop, until the consumer is stopped. Receive manages the lease of
+// every message until it is acknowledged, and is restarted if it fails
+func (c *Consumer) receive(subscription string) {
+	messages := c.messages[subscription]
+	for c.receiveCtx.Err() == nil {
+		c.receiving.Add(1)
+		err := c.subscribers[subscription].Receive(c.receiveCtx, func(ctx context.Context, m *pubsub.Message) {
+			select {
+			case messages <- m:
+			case <-ctx.Done():
+				m.Nack()
+			}
+		})
+		c.receiving.Add(-1)
+		if err != nil {
+			slog.Error("Error receiving Pub/Sub messages", "subscription", subscription, "error", err)
+			select {
+			case <-time.After(retryBase):
+			case <-c.receiveCtx.Done():
+			}
+		}
+	}
+}
+
+// consume stores the messages of one subscription a batch at a time, until
+// the consumer is stopped
+func (c *Consumer) consume(subscription string) {
+	c.running.Add(1)
+	defer func() {
+		// A dead loop must fail readiness rather than silently stop consuming
+		c.running.Add(-1)
+		if r := recover(); r != nil {
+			slog.Error("Pub/Sub consumer panic recovered", "subscription", subscription, "panic", r)
+		}
+	}()
+
+	slog.Info("Starting Pub/Sub consumption loop", "subscription", subscription, "batch_size", c.cfg.BatchSize, "batch_wait", c.cfg.BatchWait)
+
+	for {
+		if !c.WaitResumed() {
+			return
+		}
+
+		batch, open := c.collect(c.messages[subscription])
+		if len(batch) > 0 {
+			for range batch {
+				metrics.IngestMessageConsumed(backend, subscription)
+			}
+			c.inFlight.Add(int64(len(batch)))
+			c.storeBatch(subscription, batch)
+			c.inFlight.Add(-int64(len(batch)))
+		}
+		if !open {
+			return
+		}
+	}
+}
+
+// collect waits for a message, then gathers more until the batch is full or
+// BatchWait has passed. It reports false once the consumer is stopped, along
+// with the messages gathered until then
+func (c *Consumer) collect(messages <-chan *pubsub.Message) ([]*pubsub.Message, bool) {
+	var batch []*pubsub.Message
+	select {
+	case m := <-messages:
+		batch = append(batch, m)
+	case <-c.stopChan:
+		return nil, false
+	}
+
+	timer := time.NewTimer(c.cfg.BatchWait)
+	defer timer.Stop()
+	for len(batch) < c.cfg.BatchSize {
+		select {
+		case m := <-messages:
+			batch = append(batch, m)
+		case <-timer.C:
+			return batch, true
+		case <-c.stopChan:
+			return batch, false
+		}
+	}
+	return batch, true
+}
+
+// storeBatch passes a subscription's messages through its pipeline,
+// acknowledging them once stored. Messages the pipeline rejects are
+// acknowledged too, since they can never be stored. If processing fails the
+// rest are negatively acknowledged, so they are redelivered according to the
+// subscription's retry policy, and moved to its dead-letter topic after its
+// maximum delivery attempts; while storage is unavailable they are held,
+// without using up delivery attempts, until it is back
+func (c *Consumer) storeBatch(subscription string, messages []*pubsub.Message) {
+	pipeline := c.pipelines[subscription]
+	rejected := make(map[*pubsub.Message]bool)
+	for {
+		events := make([]*ingest.Event, 0, len(messages))
+		sources := make(map[*ingest.Event]*pubsub.Message, len(messages))
+		for _, m := range messages {
+			if rejected[m] {
+				continue
+			}
+			e := ingest.NewEvent(messageContext(m), subscription, m.Data)
+			events = append(events, e)
+			sources[e] = m
+		}
+
+		err := pipeline.Run(context.Background(), events, func(e *ingest.Event) error {
+			rejected[sources[e]] = true
+			metrics.IngestRejected(backend, subscription, e.Rejection().Reason)
+			slog.WarnContext(e.Context(), "Dropping unprocessable Pub/Sub message", "subscription", subscription, "reason", e.Rejection().Reason, "error", e.Rejection().Err)
+			sources[e].Ack()
+			return nil
+		})
+		if err == nil {
+			for _, e := range events {
+				if !rejected[sources[e]] {
+					sources[e].Ack()
+				}
+			}
+			return
+		}
+
+		if errors.Is(err, store.ErrUnavailable) && c.WaitAvailable(context.Background(), err) {
+			continue
+		}
+		if !errors.Is(err, store.ErrUnavailable) {
+			slog.Error("Error processing Pub/Sub batch", "subscription", subscription, "messages", len(events), "error", err)
+			for range events {
+				metrics.IngestProcessingFailed(backend, subscription)
+			}
+		}
+		for _, e := range events {
+			sources[e].Nack()
+		}
+		return
+	}
+}
+
+// messageContext scopes processing of a message to the publisher's X-Request-ID
+// attribute, or to a new request ID when the publisher didn't set one
+func messageContext(m *pubsub.Message) context.Context {
+	id := m.Attributes[requestid.Header]
+	if !requestid.IsValid(id) {
+		id = requestid.New()
+	}
+	return requestid.WithID(context.Background(), id)
+}
@@ -0,0 +1,55 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// MaxLag always returns zero: the backlog of a subscription is only reported
+// by Cloud Monitoring, as num_undelivered_messages
+func (c *Consumer) MaxLag() int64 {
+	return 0
+}
+
+// InFlight returns the number of messages received and not yet stored
+func (c *Consumer) InFlight() int {
+	return int(c.inFlight.Load())
+}
+
+// Check reports whether every subscription is being received from and stored,
+// and can be looked up. The backlog isn't checked, so maxLag is ignored
+func (c *Consumer) Check(ctx context.Context, maxLag int64) error {
+	if int(c.running.Load()) < len(c.subscribers) || int(c.receiving.Load()) < len(c.subscribers) {
+		return errors.New("consumer loop is not running")
+	}
+	for _, subscription := range slices.Sorted(maps.Keys(c.subscribers)) {
+		if err := c.lookup(ctx, c.subscribers[subscription]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop gracefully shuts down the consumer, letting the batches in progress be
+// stored before ending Receive and closing the client
+func (c *Consumer) Stop() error {
+	slog.Info("Stopping Pub/Sub consumer")
+	close(c.stopChan)
+
+	select {
+	case <-c.done:
+	case <-time.After(stopTimeout):
+		// Messages not yet acknowledged are redelivered once their lease expires
+		slog.Warn("Pub/Sub consumer did not finish its batches before shutdown")
+		c.cancelReceive()
+	}
+	if err := c.client.Close(); err != nil {
+		slog.Warn("Failed to close Pub/Sub client", "error", err)
+	}
+	slog.Info("Pub/Sub consumer stopped successfully")
+	return nil
+}
@@ -0,0 +1,201 @@
+// Package quota keeps users' storage usage accurate and, when the quota action
+// is archive, archives the oldest messages of users over their quota
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/archive"
+	"github.com/ramG-reddy/sms-store/lease"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// Config controls how often the enforcer runs
+type Config struct {
+	Interval          time.Duration
+	ReconcileInterval time.Duration // how often usage is recounted from the stored messages
+	BatchSize         int64
+}
+
+// Enforcer periodically reconciles users' usage and archives the messages of
+// users over their quota
+type Enforcer struct {
+	cfg        Config
+	leases     *lease.Leases
+	quotas     *services.QuotaService
+	smsService *services.SMSService
+	archiver   *archive.Archiver // nil leaves users over their quota as they are
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewEnforcer creates an enforcer of the quota of quotas. With archiver, users
+// over their quota have their oldest messages archived until they are back
+// below the warning level
+func NewEnforcer(cfg Config, leases *lease.Leases, quotas *services.QuotaService, smsService *services.SMSService, archiver *archive.Archiver) *Enforcer {
+	return &Enforcer{
+		cfg:        cfg,
+		leases:     leases,
+		quotas:     quotas,
+		smsService: smsService,
+		archiver:   archiver,
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Start reconciles usage, unless another instance did so within the reconcile
+// interval, then runs the enforcer and reconciliation on their intervals in a
+// background goroutine
+func (e *Enforcer) Start() {
+	slog.Info("Starting quota enforcer",
+		"interval", e.cfg.Interval, "reconcile_interval", e.cfg.ReconcileInterval, "archive", e.archiver != nil)
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+
+		e.logReconcile(e.Reconcile(context.Background()))
+
+		ticker := time.NewTicker(e.cfg.Interval)
+		defer ticker.Stop()
+		reconcileTicker := time.NewTicker(e.cfg.ReconcileInterval)
+		defer reconcileTicker.Stop()
+
+		for {
+			select {
+			case <-e.stopChan:
+				return
+			case <-reconcileTicker.C:
+				e.logReconcile(e.Reconcile(context.Background()))
+			case <-ticker.C:
+				if e.archiver == nil {
+					continue
+				}
+				err := e.RunOnce(context.Background())
+				if errors.Is(err, lease.ErrHeld) {
+					slog.Debug("Quota enforcement skipped; another instance ran it this period")
+				} else if err != nil {
+					slog.Error("Quota enforcement failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// logReconcile logs the outcome of a scheduled reconciliation
+func (e *Enforcer) logReconcile(err error) {
+	if errors.Is(err, lease.ErrHeld) {
+		slog.Debug("Usage reconciliation skipped; another instance ran it this period")
+	} else if err != nil {
+		slog.Error("Usage reconciliation failed", "error", err)
+	}
+}
+
+// Stop waits for an in-progress run to finish its batch and stops the schedule
+func (e *Enforcer) Stop() {
+	slog.Info("Stopping quota enforcer")
+	close(e.stopChan)
+	e.wg.Wait()
+	slog.Info("Quota enforcer stopped")
+}
+
+// RunOnce archives the messages of the users over their quota, if the enforcer
+// has an archiver. A user whose archival fails does not stop the others. Only
+// one instance enforces each interval; the others get lease.ErrHeld
+func (e *Enforcer) RunOnce(ctx context.Context) error {
+	return e.leases.Run(ctx, "quota", e.cfg.Interval, e.run)
+}
+
+// Reconcile recounts every user's usage from the stored messages. Only one
+// instance reconciles each reconcile interval; the others get lease.ErrHeld
+func (e *Enforcer) Reconcile(ctx context.Context) error {
+	return e.leases.Run(ctx, "quota-reconcile", e.cfg.ReconcileInterval, e.reconcile)
+}
+
+// run enforces the quota while holding the lease
+func (e *Enforcer) run(ctx context.Context) error {
+	if e.archiver == nil {
+		return nil
+	}
+
+	users, err := e.quotas.OverQuota(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, usage := range users {
+		select {
+		case <-e.stopChan:
+			return errors.Join(errs...)
+		default:
+		}
+		if err := e.archive(ctx, usage); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s user %s: %w", usage.TenantID, usage.UserID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reconcile recounts every user's usage from the stored messages
+func (e *Enforcer) reconcile(ctx context.Context) error {
+	started := time.Now()
+	users, err := e.quotas.Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("Reconciled user storage usage", "users", users, "duration", time.Since(started))
+	return nil
+}
+
+// archive archives the user's oldest messages, a batch at a time, until their
+// usage is back below the warning level
+func (e *Enforcer) archive(ctx context.Context, usage *models.UserUsage) error {
+	total := int64(0)
+	defer func() {
+		if total > 0 {
+			metrics.UserQuotaArchived(total)
+			slog.Info("Archived messages over the user's storage quota", "tenant_id", usage.TenantID, "user_id", usage.UserID, "count", total)
+		}
+	}()
+
+	remaining := *usage
+	for {
+		select {
+		case <-e.stopChan:
+			return nil
+		default:
+		}
+
+		records, err := e.smsService.OldestUserMessages(ctx, usage.TenantID, usage.UserID, e.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		n, bytes := e.quotas.Excess(&remaining, records)
+		if n == 0 {
+			return nil
+		}
+
+		archived, err := e.archiver.Archive(ctx, records[:n])
+		if err != nil {
+			return err
+		}
+		if err := e.quotas.Release(ctx, usage.TenantID, usage.UserID, archived, bytes); err != nil {
+			// Corrected by the next reconciliation
+			slog.WarnContext(ctx, "Failed to release archived messages from user storage usage", "tenant_id", usage.TenantID, "user_id", usage.UserID, "error", err)
+		}
+		total += archived
+		remaining.Messages -= int64(n)
+		remaining.Bytes -= bytes
+
+		if n < len(records) || int64(len(records)) < e.cfg.BatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Package rabbitmq consumes SMS events from RabbitMQ queues, for deployments
+// that standardized on AMQP instead of Kafka
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/requestid"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+// backend labels the metrics of the consumer
+const backend = "rabbitmq"
+
+const (
+	// stopTimeout bounds how long Stop waits for the batches in progress to be stored
+	stopTimeout = 10 * time.Second
+
+	// Reconnection is attempted after reconnectBase, doubling after each failed
+	// attempt up to reconnectMax
+	reconnectBase = time.Second
+	reconnectMax  = 30 * time.Second
+
+	// A batch failing to be stored is retried after retryBase, doubling with
+	// each further attempt up to retryMax
+	retryBase = time.Second
+	retryMax  = 30 * time.Second
+)
+
+// Config identifies the queues to consume, and how deliveries are batched
+type Config struct {
+	URL    string            // amqp:// or amqps://, with credentials and virtual host
+	Queues map[string]string // queue to the direction of its records, e.g. models.DirectionInbound
+
+	// DefaultTenantID is assigned to events that don't carry a tenantId
+	DefaultTenantID string
+
+	// Validation configures strict validation of events; off by default
+	Validation ingest.Validation
+
+	// Prefetch is how many unacknowledged deliveries the broker sends each queue's
+	// consumer. They are stored in batches of up to BatchSize, waiting up to
+	// BatchWait for a batch to fill
+	Prefetch  int
+	BatchSize int
+	BatchWait time.Duration
+
+	// MaxAttempts is how often storing a batch is tried before its messages are
+	// rejected as retries_exhausted
+	MaxAttempts int
+
+	// Stages are custom processors added to the ingest pipeline, see ingest.Stage
+	Stages []ingest.Stage
+}
+
+// Consumer stores the SMS events of RabbitMQ queues, acknowledging each message
+// once it is stored
+type Consumer struct {
+	cfg       Config
+	pipelines map[string]*ingest.Pipeline // by queue
+	stopChan  chan struct{}
+	done      chan struct{}
+
+	// conn is the current connection, replaced on reconnect
+	connMu sync.Mutex
+	conn   *amqp.Connection
+
+	// Pausing stops taking new deliveries; batches already collected are still
+	// stored and acknowledged. Once the prefetch limit is reached the broker
+	// holds further messages in the queues
+	*ingest.Gate
+
+	// running, backlog and inFlight are reported by Check, MaxLag and InFlight
+	running  atomic.Bool
+	backlog  atomic.Int64
+	inFlight atomic.Int64
+}
+
+// StartConsumer connects to RabbitMQ, subscribes to cfg.Queues and begins
+// consuming in a background goroutine, reconnecting whenever the connection is
+// lost. The queues themselves must already exist
+func StartConsumer(cfg Config, smsService *services.SMSService) (*Consumer, error) {
+	slog.Info("Starting RabbitMQ consumer", "queues", cfg.Queues, "prefetch", cfg.Prefetch)
+
+	c := &Consumer{
+		cfg:       cfg,
+		pipelines: make(map[string]*ingest.Pipeline, len(cfg.Queues)),
+		stopChan:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	c.Gate = ingest.NewGate("RabbitMQ consumer", c.stopChan, retryBase)
+	for queue, direction := range cfg.Queues {
+		pipeline, err := ingest.NewPipeline(ingest.PipelineConfig{
+			DefaultTenantID: cfg.DefaultTenantID,
+			Validation:      cfg.Validation,
+			Direction:       direction,
+			Persist:         ingest.Persist(smsService),
+			Stages:          cfg.Stages,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queue %s: %w", queue, err)
+		}
+		c.pipelines[queue] = pipeline
+	}
+
+	deliveries, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	go c.run(deliveries)
+	slog.Info("RabbitMQ consumer started successfully")
+	return c, nil
+}
+
+// connect opens a connection and a channel, and subscribes to every queue with
+// manual acknowledgement, returning the deliveries of each
+func (c *Consumer) connect() (map[string]<-chan amqp.Delivery, error) {
+	conn, err := amqp.DialConfig(c.cfg.URL, amqp.Config{
+		Heartbeat:  10 * time.Second,
+		Properties: amqp.Table{"connection_name": "sms-store"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open RabbitMQ channel: %w", err)
+	}
+	// Without global, the limit applies to each queue's consumer separately
+	if err := ch.Qos(c.cfg.Prefetch, 0, false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set RabbitMQ prefetch: %w", err)
+	}
+
+	deliveries := make(map[string]<-chan amqp.Delivery, len(c.cfg.Queues))
+	for _, queue := range slices.Sorted(maps.Keys(c.cfg.Queues)) {
+		deliveries[queue], err = ch.Consume(queue, "", false, false, false, false, nil)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to consume RabbitMQ queue %s: %w", queue, err)
+		}
+	}
+
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		if err := <-closed; err != nil {
+			slog.Warn("Lost connection to RabbitMQ", "error", err)
+		}
+	}()
+
+	c.connMu.Lock()
+	c.conn = conn
+	c.connMu.Unlock()
+	return deliveries, nil
+}
+
+// run consumes the deliveries of the current connection until it is lost,
+// then reconnects, until the consumer is stopped
+func (c *Consumer) run(deliveries map[string]<-chan amqp.Delivery) {
+	c.running.Store(true)
+	defer close(c.done)
+	defer func() {
+		// A dead loop must fail readiness rather than silently stop consuming
+		c.running.Store(false)
+		if r := recover(); r != nil {
+			slog.Error("RabbitMQ consumer panic recovered", "panic", r)
+		}
+	}()
+
+	for {
+		var wg sync.WaitGroup
+		for queue, queueDeliveries := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.consume(queue, queueDeliveries)
+			}()
+		}
+		wg.Wait()
+
+		// Messages not yet acknowledged are requeued by the broker when the
+		// connection closes
+		c.connMu.Lock()
+		c.conn.Close()
+		c.connMu.Unlock()
+
+		select {
+		case <-c.stopChan:
+			return
+		default:
+		}
+		if deliveries = c.reconnect(); deliveries == nil {
+			return
+		}
+	}
+}
+
+// reconnect retries connecting with backoff. It returns nil if the consumer is
+// stopped first
+func (c *Consumer) reconnect() map[string]<-chan amqp.Delivery {
+	delay := reconnectBase
+	for {
+		if !c.sleep(delay) {
+			return nil
+		}
+		deliveries, err := c.connect()
+		if err == nil {
+			slog.Info("Reconnected to RabbitMQ")
+			return deliveries
+		}
+		slog.Error("Failed to reconnect to RabbitMQ", "retry_in", delay, "error", err)
+		delay = min(delay*2, reconnectMax)
+	}
+}
+
+// consume stores the deliveries of one queue a batch at a time, until the
+// channel is closed or the consumer is stopped
+func (c *Consumer) consume(queue string, deliveries <-chan amqp.Delivery) {
+	slog.Info("Starting RabbitMQ consumption loop", "queue", queue, "batch_size", c.cfg.BatchSize, "batch_wait", c.cfg.BatchWait)
+
+	for {
+		if !c.WaitResumed() {
+			return
+		}
+
+		batch, open := c.collect(deliveries)
+		if len(batch) > 0 {
+			for range batch {
+				metrics.IngestMessageConsumed(backend, queue)
+			}
+			c.inFlight.Add(int64(len(batch)))
+			c.storeBatch(queue, batch)
+			c.inFlight.Add(-int64(len(batch)))
+		}
+		if !open {
+			return
+		}
+	}
+}
+
+// collect waits for a delivery, then gathers more until the batch is full or
+// BatchWait has passed. It reports false once the channel is closed or the
+// consumer is stopped, along with the deliveries gathered until then
+func (c *Consumer) collect(deliveries <-chan amqp.Delivery) ([]amqp.Delivery, bool) {
+	var batch []amqp.Delivery
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			return nil, false
+		}
+		batch = append(batch, d)
+	case <-c.stopChan:
+		return nil, false
+	}
+
+	timer := time.NewTimer(c.cfg.BatchWait)
+	defer timer.Stop()
+	for len(batch) < c.cfg.BatchSize {
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, d)
+		case <-timer.C:
+			return batch, true
+		case <-c.stopChan:
+			return batch, false
+		}
+	}
+	return batch, true
+}
+
+// storeBatch passes a queue's deliveries through its pipeline, acknowledging
+// them once stored. Messages the pipeline rejects are rejected without requeue,
+// so they go to the queue's dead letter exchange if it has one. If processing
+// fails the batch is retried with backoff, and its messages rejected after
+// MaxAttempts; while storage is unavailable it is held, without using up
+// attempts, until storage is back
+func (c *Consumer) storeBatch(queue string, deliveries []amqp.Delivery) {
+	pipeline := c.pipelines[queue]
+	rejected := make(map[uint64]bool)
+	for attempt := 1; ; {
+		events := make([]*ingest.Event, 0, len(deliveries))
+		sources := make(map[*ingest.Event]amqp.Delivery, len(deliveries))
+		for _, d := range deliveries {
+			if rejected[d.DeliveryTag] {
+				continue
+			}
+			e := ingest.NewEvent(deliveryContext(d), queue, d.Body)
+			events = append(events, e)
+			sources[e] = d
+		}
+
+		err := pipeline.Run(context.Background(), events, func(e *ingest.Event) error {
+			rejected[sources[e].DeliveryTag] = true
+			c.reject(e.Context(), queue, sources[e], e.Rejection().Reason, e.Rejection().Err)
+			return nil
+		})
+		if err == nil {
+			for _, e := range events {
+				if rejected[sources[e].DeliveryTag] {
+					continue
+				}
+				if err := sources[e].Ack(false); err != nil {
+					slog.WarnContext(e.Context(), "Failed to acknowledge RabbitMQ message", "queue", queue, "error", err)
+				}
+			}
+			return
+		}
+
+		if errors.Is(err, store.ErrUnavailable) {
+			if c.WaitAvailable(context.Background(), err) {
+				continue
+			}
+			// Stopped; the broker requeues the messages
+			return
+		}
+
+		slog.Error("Error processing RabbitMQ batch", "queue", queue, "messages", len(events), "attempt", attempt, "error", err)
+		for range events {
+			metrics.IngestProcessingFailed(backend, queue)
+		}
+		if attempt >= c.cfg.MaxAttempts {
+			for _, e := range events {
+				c.reject(e.Context(), queue, sources[e], ingest.ReasonRetriesExhausted, fmt.Errorf("giving up after %d attempts: %w", attempt, err))
+			}
+			return
+		}
+		if !c.sleep(min(retryBase<<(attempt-1), retryMax)) {
+			return
+		}
+		attempt++
+	}
+}
+
+// reject stops RabbitMQ from redelivering an unprocessable message, which is
+// dead-lettered if the queue has a dead letter exchange and dropped otherwise
+func (c *Consumer) reject(ctx context.Context, queue string, d amqp.Delivery, reason string, cause error) {
+	metrics.IngestRejected(backend, queue, reason)
+	slog.WarnContext(ctx, "Rejected unprocessable RabbitMQ message", "queue", queue, "reason", reason, "error", cause)
+	if err := d.Reject(false); err != nil {
+		slog.WarnContext(ctx, "Failed to reject RabbitMQ message", "queue", queue, "error", err)
+	}
+}
+
+// sleep waits for delay, reporting false if the consumer is stopped first
+func (c *Consumer) sleep(delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return true
+	case <-c.stopChan:
+		return false
+	}
+}
+
+// deliveryContext scopes processing of a delivery to the producer's X-Request-ID
+// header, or to a new request ID when the producer didn't set one
+func deliveryContext(d amqp.Delivery) context.Context {
+	id, _ := d.Headers[requestid.Header].(string)
+	if !requestid.IsValid(id) {
+		id = requestid.New()
+	}
+	return requestid.WithID(context.Background(), id)
+}
@@ -0,0 +1,79 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// MaxLag returns the most ready messages last seen waiting in any one queue
+func (c *Consumer) MaxLag() int64 {
+	return c.backlog.Load()
+}
+
+// InFlight returns the number of messages delivered and not yet stored
+func (c *Consumer) InFlight() int {
+	return int(c.inFlight.Load())
+}
+
+// Check reports whether the consumer loop is running, the connection to
+// RabbitMQ is up, and no queue holds more than maxLag ready messages (zero
+// disables the backlog check)
+func (c *Consumer) Check(ctx context.Context, maxLag int64) error {
+	if !c.running.Load() {
+		return errors.New("consumer loop is not running")
+	}
+	c.connMu.Lock()
+	conn := c.conn
+	c.connMu.Unlock()
+	if conn.IsClosed() {
+		return errors.New("not connected to RabbitMQ")
+	}
+
+	// A separate channel, since a failed passive declare closes the channel it is made on
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open RabbitMQ channel: %w", err)
+	}
+	defer ch.Close()
+
+	var backlog int64
+	for _, queue := range slices.Sorted(maps.Keys(c.cfg.Queues)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := ch.QueueDeclarePassive(queue, false, false, false, false, nil)
+		if err != nil {
+			return fmt.Errorf("failed to inspect RabbitMQ queue %s: %w", queue, err)
+		}
+		backlog = max(backlog, int64(info.Messages))
+	}
+	c.backlog.Store(backlog)
+	if maxLag > 0 && backlog > maxLag {
+		return fmt.Errorf("queue backlog %d exceeds threshold %d", backlog, maxLag)
+	}
+	return nil
+}
+
+// Stop gracefully shuts down the consumer, letting the batches in progress be
+// stored before closing the connection
+func (c *Consumer) Stop() error {
+	slog.Info("Stopping RabbitMQ consumer")
+	close(c.stopChan)
+
+	select {
+	case <-c.done:
+	case <-time.After(stopTimeout):
+		slog.Warn("RabbitMQ consumer did not finish its batches before shutdown")
+		// Unacknowledged messages are requeued once the connection closes
+		c.connMu.Lock()
+		c.conn.Close()
+		c.connMu.Unlock()
+	}
+	slog.Info("RabbitMQ consumer stopped successfully")
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package redact masks personal data, phone numbers and message bodies, in
+// log lines and error responses while enabled
+package redact
+
+import (
+	"regexp"
+	"sync/atomic"
+)
+
+// Redactor masks personal data while enabled. It is given to the logger and the
+// HTTP server at startup, and set from the configuration then and on reload.
+// A nil Redactor masks nothing
+type Redactor struct {
+	enabled atomic.Bool
+}
+
+// New creates a Redactor, masking personal data if enabled
+func New(enabled bool) *Redactor {
+	r := &Redactor{}
+	r.enabled.Store(enabled)
+	return r
+}
+
+// SetEnabled turns redaction on or off
+func (r *Redactor) SetEnabled(on bool) {
+	r.enabled.Store(on)
+}
+
+// Enabled reports whether redaction is on
+func (r *Redactor) Enabled() bool {
+	return r != nil && r.enabled.Load()
+}
+
+// Keys of log attributes holding phone numbers, and message bodies; user IDs are phone numbers
+var (
+	phoneKeys = map[string]bool{"user_id": true, "phone_number": true, "peer": true, "userId": true, "phoneNumber": true}
+	bodyKeys  = map[string]bool{"message": true, "body": true, "text": true}
+)
+
+var (
+	// phoneNumberPattern finds phone numbers in free text, as models.IsValidPhoneNumber accepts them
+	phoneNumberPattern = regexp.MustCompile(`\+?[1-9]\d{9,14}`)
+
+	// bodyPattern finds message bodies in JSON embedded in free text, such as the
+	// documents quoted by MongoDB schema validation errors
+	bodyPattern = regexp.MustCompile(`"(message|consideredValue)"\s*:\s*"(?:[^"\\]|\\.)*"`)
+)
+
+// Placeholder replacing message bodies
+const Placeholder = "[REDACTED]"
+
+// PhoneNumber masks every digit of a phone number but the last four
+func (r *Redactor) PhoneNumber(phoneNumber string) string {
+	if !r.Enabled() {
+		return phoneNumber
+	}
+	return maskPhoneNumber(phoneNumber)
+}
+
+// Message replaces a message body with Placeholder
+func (r *Redactor) Message(message string) string {
+	if !r.Enabled() || message == "" {
+		return message
+	}
+	return Placeholder
+}
+
+// String masks the phone numbers and JSON message bodies found in free text,
+// such as log messages and errors
+func (r *Redactor) String(s string) string {
+	if !r.Enabled() {
+		return s
+	}
+	s = phoneNumberPattern.ReplaceAllStringFunc(s, maskPhoneNumber)
+	return bodyPattern.ReplaceAllString(s, `"$1":"`+Placeholder+`"`)
+}
+
+// Field masks a value by the log attribute key it is written under: phone
+// numbers by PhoneNumber, message bodies by Message, and anything else by String
+func (r *Redactor) Field(key, value string) string {
+	switch {
+	case phoneKeys[key]:
+		return r.PhoneNumber(value)
+	case bodyKeys[key]:
+		return r.Message(value)
+	}
+	return r.String(value)
+}
+
+// maskPhoneNumber replaces each digit but the last four with an asterisk
+func maskPhoneNumber(phoneNumber string) string {
+	b := []byte(phoneNumber)
+	keep := 4
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < '0' || b[i] > '9' {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		b[i] = '*'
+	}
+	return string(b)
+}
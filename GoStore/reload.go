@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/archive"
+	"github.com/ramG-reddy/sms-store/config"
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/flagging"
+	"github.com/ramG-reddy/sms-store/gql"
+	"github.com/ramG-reddy/sms-store/handlers"
+	"github.com/ramG-reddy/sms-store/logging"
+	"github.com/ramG-reddy/sms-store/opmode"
+	"github.com/ramG-reddy/sms-store/redact"
+	"github.com/ramG-reddy/sms-store/retention"
+	"github.com/ramG-reddy/sms-store/search"
+	"github.com/ramG-reddy/sms-store/servertls"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+// reloader re-reads the configuration on SIGHUP or /admin/reload and applies the
+// settings that can change without restarting the Kafka consumers or the servers:
+// the log level, rate limits, retention and archive ages, pagination caps, the
+// slow query threshold and the service mode.
+// The TLS certificates and flag rules are read again too, in case they changed.
+// Any other changed setting is logged and only takes effect after a restart
+type reloader struct {
+	configPath string
+
+	mu      sync.Mutex
+	current *config.Config
+
+	// Components holding reloadable settings; nil when not running
+	redactor       *redact.Redactor
+	mongo          *db.Mongo
+	smsHandler     *handlers.SMSHandler
+	graphqlHandler *gql.Handler
+	rateLimiter    *handlers.RateLimiter
+	cassandraStore *store.CassandraStore
+	searchIndex    *search.Index
+	flagger        *flagging.Flagger
+	archiver       *archive.Archiver
+	sweeper        *retention.Sweeper
+	certificates   *servertls.Certificates
+	modes          *opmode.Switch
+}
+
+// Reload loads the configuration again and applies its reloadable settings
+// Nothing is applied if the configuration is invalid
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Load(r.configPath)
+	if err != nil {
+		return err
+	}
+	if err := logging.SetLevel(cfg.LogLevel); err != nil {
+		return err
+	}
+	r.redactor.SetEnabled(cfg.LogRedactPII)
+	previous := r.current
+
+	r.applyPageCaps(cfg)
+	r.mongo.SetSlowQueryThreshold(time.Duration(cfg.MongoSlowQueryMs) * time.Millisecond)
+	if r.rateLimiter != nil {
+		r.rateLimiter.SetLimits(cfg.RateLimitRPS, cfg.RateLimitBurst)
+	}
+	if r.archiver != nil {
+		r.archiver.SetMaxAge(time.Duration(cfg.ArchiveMaxAgeDays) * 24 * time.Hour)
+	}
+
+	var retentionErr error
+	if cfg.RetentionDays != previous.RetentionDays && r.sweeper != nil {
+		// With retention policies the sweeper applies RETENTION_DAYS, to the search index too
+		r.sweeper.SetDefaultRetention(time.Duration(cfg.RetentionDays) * 24 * time.Hour)
+	} else if cfg.RetentionDays != previous.RetentionDays {
+		retention := time.Duration(cfg.RetentionDays) * 24 * time.Hour
+		if r.cassandraStore != nil {
+			r.cassandraStore.SetRetention(retention)
+		}
+		if r.searchIndex != nil {
+			r.searchIndex.SetRetention(retention)
+		}
+		if cfg.StorageBackend == store.BackendMongo {
+			if err := r.mongo.EnsureRetentionPolicy(cfg.RetentionDays); err != nil {
+				// Keep the previous retention as current so the next reload tries again
+				retentionErr = fmt.Errorf("failed to apply retention policy: %w", err)
+				cfg.RetentionDays = previous.RetentionDays
+			}
+		}
+	}
+
+	// Only a changed setting is applied, so a mode set on the admin server is
+	// kept across reloads that don't touch it
+	if cfg.ServiceMode != previous.ServiceMode {
+		mode, _ := opmode.Parse(cfg.ServiceMode) // validated by Load
+		r.modes.Set(mode)
+	}
+
+	var certificatesErr error
+	if r.certificates != nil {
+		certificatesErr = r.certificates.Reload()
+	}
+
+	// The file flagging started with is read again; invalid rules leave the current ones in place
+	var flagRulesErr error
+	if r.flagger != nil {
+		rules, err := flagging.LoadFile(previous.FlagRulesFile)
+		if err == nil {
+			err = r.flagger.SetRules(rules)
+		}
+		if err != nil {
+			flagRulesErr = fmt.Errorf("failed to reload flag rules: %w", err)
+		}
+	}
+
+	if restartRequired(previous, cfg) {
+		slog.Warn("Configuration changes other than the log level and redaction, rate limits, retention, archive age, page sizes, slow query threshold and service mode take effect after a restart")
+	}
+	r.current = cfg
+
+	slog.Info("Configuration reloaded",
+		"log_level", cfg.LogLevel,
+		"log_redact_pii", cfg.LogRedactPII,
+		"rate_limit_rps", cfg.RateLimitRPS,
+		"rate_limit_burst", cfg.RateLimitBurst,
+		"retention_days", cfg.RetentionDays,
+		"archive_max_age_days", cfg.ArchiveMaxAgeDays,
+		"graphql_max_page_size", cfg.GraphQLMaxPageSize,
+		"search_max_limit", cfg.SearchMaxLimit,
+		"mongo_slow_query_ms", cfg.MongoSlowQueryMs,
+		"service_mode", cfg.ServiceMode)
+	return errors.Join(retentionErr, certificatesErr, flagRulesErr)
+}
+
+// applyPageCaps sets the largest pages served by the GraphQL and search endpoints
+func (r *reloader) applyPageCaps(cfg *config.Config) {
+	r.graphqlHandler.SetMaxPageSize(cfg.GraphQLMaxPageSize)
+	r.smsHandler.SetMaxSearchLimit(cfg.SearchMaxLimit)
+}
+
+// restartRequired reports whether a and b differ in any setting Reload does not apply
+func restartRequired(a, b *config.Config) bool {
+	x, y := *a, *b
+	for _, c := range []*config.Config{&x, &y} {
+		c.LogLevel, c.LogRedactPII = "", false
+		c.RateLimitRPS, c.RateLimitBurst = 0, 0
+		c.RetentionDays, c.ArchiveMaxAgeDays = 0, 0
+		c.GraphQLMaxPageSize, c.SearchMaxLimit = 0, 0
+		c.MongoSlowQueryMs = 0
+		c.ServiceMode = ""
+		c.Secrets = nil
+	}
+	return !reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/kafka"
+	"github.com/ramG-reddy/sms-store/logging"
+	"github.com/spf13/cobra"
+)
+
+// newReplayCommand returns the replay command, which rewinds a consumer group
+// so a time window can be re-ingested, e.g. after a data-corruption incident.
+// The service must be stopped while it runs
+func newReplayCommand(app *cli) *cobra.Command {
+	var to string
+	var status, dryRun bool
+	cmd := &cobra.Command{
+		Use:     "replay --to earliest|<RFC 3339 time>",
+		Aliases: []string{"reset-offsets"},
+		Short:   "Rewind the Kafka consumer group so messages are ingested again",
+		Args:    cobra.NoArgs,
+		Run: func(*cobra.Command, []string) {
+			var at time.Time
+			if to != "earliest" {
+				parsed, err := time.Parse(time.RFC3339, to)
+				if err != nil {
+					logging.Fatal("Invalid --to time; use earliest or RFC 3339", "to", to, "error", err)
+				}
+				at = parsed
+			}
+			app.replay(at, status, dryRun)
+		},
+	}
+	cmd.Flags().StringVar(&to, "to", "", `"earliest", or an RFC 3339 time to replay from`)
+	cmd.Flags().BoolVar(&status, "status", false, "reset the status consumer's group and topic instead of the SMS events group")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the new offsets without committing them")
+	cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+// replay resets the offsets of the SMS events group, or of the status group,
+// to the first messages at or after at; the zero time resets them to the earliest
+func (app *cli) replay(at time.Time, status, dryRun bool) {
+	cfg := app.cfg
+	groupID, topics := cfg.KafkaGroupID, slices.Sorted(maps.Keys(cfg.KafkaTopics))
+	if status {
+		if cfg.KafkaStatusTopic == "" {
+			logging.Fatal("No status topic is configured")
+		}
+		groupID, topics = cfg.KafkaStatusGroupID, []string{cfg.KafkaStatusTopic}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	resets, err := kafka.ResetOffsets(ctx, cfg.KafkaBrokers, kafkaSecurity(cfg), groupID, topics, at, dryRun)
+	if err != nil {
+		logging.Fatal("Failed to reset consumer group offsets", "group_id", groupID, "error", err)
+	}
+
+	for _, reset := range resets {
+		slog.Info("Consumer group offset reset", "group_id", groupID, "topic", reset.Topic, "partition", reset.Partition, "offset", reset.Offset, "dry_run", dryRun)
+	}
+}
@@ -0,0 +1,36 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"regexp"
+)
+
+// Header is the HTTP header (and gRPC metadata key, lowercased) carrying the request ID
+const Header = "X-Request-ID"
+
+// validID accepts caller-supplied IDs that are safe to echo back and log
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type contextKey struct{}
+
+// New returns a random request ID
+func New() string {
+	return rand.Text()
+}
+
+// IsValid reports whether a caller-supplied request ID can be honored
+func IsValid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// WithID returns a copy of ctx carrying the given request ID
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by the context, if any
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,183 @@
+// Package retention removes messages once they outlive the retention of their
+// tenant: the tenant's own policy, or the default for tenants without one
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/archive"
+	"github.com/ramG-reddy/sms-store/lease"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Config controls how often the sweeper runs and the policy of tenants without one
+type Config struct {
+	Interval         time.Duration
+	BatchSize        int64
+	DefaultRetention time.Duration // zero keeps the messages of tenants without a policy
+	DefaultAction    string
+}
+
+// Sweeper periodically deletes or archives the messages past their tenant's retention
+type Sweeper struct {
+	cfg              Config
+	defaultRetention atomic.Int64 // time.Duration; cfg.DefaultRetention until changed by SetDefaultRetention
+	leases           *lease.Leases
+	smsService       *services.SMSService
+	policies         *services.RetentionPolicyService
+	archiver         *archive.Archiver // nil when no archive bucket is configured
+	stopChan         chan struct{}
+	wg               sync.WaitGroup
+}
+
+// NewSweeper creates a sweeper applying the policies stored in policies. The
+// archive action needs archiver; without one, archiving tenants are skipped
+func NewSweeper(cfg Config, leases *lease.Leases, smsService *services.SMSService, policies *services.RetentionPolicyService, archiver *archive.Archiver) *Sweeper {
+	s := &Sweeper{
+		cfg:        cfg,
+		leases:     leases,
+		smsService: smsService,
+		policies:   policies,
+		archiver:   archiver,
+		stopChan:   make(chan struct{}),
+	}
+	s.defaultRetention.Store(int64(cfg.DefaultRetention))
+	return s
+}
+
+// SetDefaultRetention changes the retention of tenants without a policy, from the next run on
+func (s *Sweeper) SetDefaultRetention(retention time.Duration) {
+	s.defaultRetention.Store(int64(retention))
+}
+
+// Start runs the sweeper on the configured interval in a background goroutine
+func (s *Sweeper) Start() {
+	slog.Info("Starting retention sweeper",
+		"interval", s.cfg.Interval, "default_retention", time.Duration(s.defaultRetention.Load()), "default_action", s.cfg.DefaultAction)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				err := s.RunOnce(context.Background())
+				if errors.Is(err, lease.ErrHeld) {
+					slog.Debug("Retention sweep skipped; another instance ran it this period")
+				} else if err != nil {
+					slog.Error("Retention sweep failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop waits for an in-progress run to finish its batch and stops the schedule
+func (s *Sweeper) Stop() {
+	slog.Info("Stopping retention sweeper")
+	close(s.stopChan)
+	s.wg.Wait()
+	slog.Info("Retention sweeper stopped")
+}
+
+// RunOnce applies every tenant's policy, then the default to the tenants without
+// one. A tenant whose sweep fails does not stop the others. Only one instance
+// sweeps each interval; the others get lease.ErrHeld
+func (s *Sweeper) RunOnce(ctx context.Context) error {
+	return s.leases.Run(ctx, "retention", s.cfg.Interval, s.run)
+}
+
+// run sweeps the tenants while holding the lease
+func (s *Sweeper) run(ctx context.Context) error {
+	policies, err := s.policies.All(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var errs []error
+	withPolicy := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		withPolicy = append(withPolicy, policy.TenantID)
+		if policy.RetentionDays <= 0 {
+			continue
+		}
+		cutoff := now.AddDate(0, 0, -policy.RetentionDays)
+		if err := s.sweep(ctx, policy.Action, cutoff, models.TenantFilter{Only: []string{policy.TenantID}}); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", policy.TenantID, err))
+		}
+	}
+
+	if retention := time.Duration(s.defaultRetention.Load()); retention > 0 {
+		if err := s.sweep(ctx, s.cfg.DefaultAction, now.Add(-retention), models.TenantFilter{Except: withPolicy}); err != nil {
+			errs = append(errs, fmt.Errorf("default policy: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sweep removes the selected tenants' messages created before cutoff, a batch
+// at a time, until none remain
+func (s *Sweeper) sweep(ctx context.Context, action string, cutoff time.Time, tenants models.TenantFilter) error {
+	if action == models.RetentionActionArchive && s.archiver == nil {
+		return errors.New("the archive action needs ARCHIVE_S3_BUCKET to be set")
+	}
+
+	total := int64(0)
+	defer func() {
+		if total > 0 {
+			metrics.RetentionMessagesRemoved(action, total)
+			slog.Info("Removed expired messages", "action", action, "count", total, "cutoff", cutoff, "tenants", tenants.Only)
+		}
+	}()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return nil
+		default:
+		}
+
+		records, err := s.smsService.FindMessagesOlderThan(ctx, cutoff, tenants, s.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		var removed int64
+		if action == models.RetentionActionArchive {
+			removed, err = s.archiver.Archive(ctx, records)
+		} else {
+			ids := make([]primitive.ObjectID, len(records))
+			for i, record := range records {
+				ids[i] = record.ID
+			}
+			removed, err = s.smsService.DeleteMessagesByIDs(ctx, ids)
+		}
+		if err != nil {
+			return err
+		}
+		total += removed
+
+		if int64(len(records)) < s.cfg.BatchSize {
+			return nil
+		}
+	}
+}
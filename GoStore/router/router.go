@@ -0,0 +1,107 @@
+// Package router registers HTTP routes in groups that share a path prefix and a
+// chain of middleware, on top of the pattern matching and path parameters of
+// http.ServeMux
+package router
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ramG-reddy/sms-store/problem"
+)
+
+// Middleware wraps a handler with behaviour that runs around it
+type Middleware func(http.Handler) http.Handler
+
+// Router registers routes on a ServeMux. Groups made from it share its mux, so
+// every route is served by the root router
+type Router struct {
+	mux        *http.ServeMux
+	prefix     string
+	middleware []Middleware
+}
+
+// New creates a router with an empty mux of its own, so nothing registered on
+// http.DefaultServeMux is exposed
+func New() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// Group returns a router whose routes are registered under prefix, behind this
+// router's middleware followed by mw
+func (r *Router) Group(prefix string, mw ...Middleware) *Router {
+	return &Router{
+		mux:        r.mux,
+		prefix:     r.prefix + strings.TrimRight(prefix, "/"),
+		middleware: append(slices.Clone(r.middleware), mw...),
+	}
+}
+
+// Handle registers h for pattern, an optional method followed by a path relative
+// to the group's prefix as in http.ServeMux. The group's middleware runs first,
+// then mw, outermost first
+func (r *Router) Handle(pattern string, h http.Handler, mw ...Middleware) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		method, path = "", pattern
+	}
+	pattern = r.prefix + path
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+	r.mux.Handle(pattern, Chain(h, append(slices.Clone(r.middleware), mw...)...))
+}
+
+// HandleFunc registers the handler function h for pattern, as Handle does
+func (r *Router) HandleFunc(pattern string, h http.HandlerFunc, mw ...Middleware) {
+	r.Handle(pattern, h, mw...)
+}
+
+// ServeHTTP dispatches the request to the handler of the best matching route.
+// Requests matching no route, or none for their method, are answered with
+// problem details instead of the mux's plain text
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h, pattern := r.mux.Handler(req); pattern == "" {
+		h.ServeHTTP(&unmatchedWriter{ResponseWriter: w, req: req}, req)
+		return
+	}
+	r.mux.ServeHTTP(w, req)
+}
+
+// unmatchedWriter replaces the plain-text error the mux writes for a request
+// matching no route with problem details. The mux sets Allow on 405 responses
+// before writing, so it is kept
+type unmatchedWriter struct {
+	http.ResponseWriter
+	req         *http.Request
+	wroteHeader bool
+}
+
+func (u *unmatchedWriter) WriteHeader(status int) {
+	if u.wroteHeader {
+		return
+	}
+	u.wroteHeader = true
+	detail := "No endpoint at " + u.req.URL.Path
+	if status == http.StatusMethodNotAllowed {
+		detail = "Method " + u.req.Method + " not allowed at " + u.req.URL.Path + "; allowed: " + u.Header().Get("Allow")
+	}
+	problem.Write(u.ResponseWriter, status, detail)
+}
+
+// Write discards the mux's plain-text body
+func (u *unmatchedWriter) Write(p []byte) (int, error) {
+	if !u.wroteHeader {
+		u.WriteHeader(http.StatusOK)
+	}
+	return len(p), nil
+}
+
+// Chain wraps h in mw, the first middleware outermost
+func Chain(h http.Handler, mw ...Middleware) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
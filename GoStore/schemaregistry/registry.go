@@ -0,0 +1,167 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hamba/avro/v2"
+)
+
+// magicByte starts every payload in the Confluent wire format, followed by the
+// 4-byte big-endian schema ID and the Avro binary encoding
+const magicByte = 0
+
+// Subject naming strategies, matching the Confluent serializer settings
+const (
+	TopicNameStrategy       = "topic"        // <topic>-value
+	RecordNameStrategy      = "record"       // <record full name>
+	TopicRecordNameStrategy = "topic_record" // <topic>-<record full name>
+)
+
+var (
+	// ErrUnknownSchema is returned when the registry has no schema with the requested ID
+	ErrUnknownSchema = errors.New("schema not found in registry")
+
+	// ErrSubjectMismatch is returned when a schema isn't registered under the subject
+	// the naming strategy expects for the topic
+	ErrSubjectMismatch = errors.New("schema is not registered under the expected subject")
+)
+
+// subjectVersion is one entry of the registry's /schemas/ids/{id}/versions response
+type subjectVersion struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Client looks up writer schemas by ID, caching them since registered schemas never change
+type Client struct {
+	baseURL  string
+	strategy string
+	client   *http.Client
+
+	mu      sync.Mutex
+	schemas map[string]avro.Schema // by topic and schema ID
+}
+
+// NewClient creates a registry client for the given base URL and subject naming strategy
+func NewClient(baseURL, strategy string) *Client {
+	return &Client{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		strategy: strategy,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		schemas:  make(map[string]avro.Schema),
+	}
+}
+
+// IsValidStrategy reports whether strategy names a supported subject naming strategy
+func IsValidStrategy(strategy string) bool {
+	switch strategy {
+	case TopicNameStrategy, RecordNameStrategy, TopicRecordNameStrategy:
+		return true
+	}
+	return false
+}
+
+// IsWireFormat reports whether value looks like a Confluent-framed payload
+// JSON payloads never start with a zero byte, so the two can share a topic
+func IsWireFormat(value []byte) bool {
+	return len(value) > 5 && value[0] == magicByte
+}
+
+// Split returns the schema ID and Avro payload of a Confluent-framed value
+func Split(value []byte) (int, []byte, error) {
+	if !IsWireFormat(value) {
+		return 0, nil, fmt.Errorf("not a schema registry framed payload")
+	}
+	return int(binary.BigEndian.Uint32(value[1:5])), value[5:], nil
+}
+
+// Subject returns the subject schema must be registered under for topic
+func (c *Client) Subject(topic string, schema avro.Schema) string {
+	name := ""
+	if named, ok := schema.(avro.NamedSchema); ok {
+		name = named.FullName()
+	}
+	switch c.strategy {
+	case RecordNameStrategy:
+		return name
+	case TopicRecordNameStrategy:
+		return topic + "-" + name
+	default:
+		return topic + "-value"
+	}
+}
+
+// Schema returns the writer schema with the given ID, verifying that it is
+// registered under the subject expected for topic
+func (c *Client) Schema(ctx context.Context, topic string, id int) (avro.Schema, error) {
+	key := topic + "/" + strconv.Itoa(id)
+
+	c.mu.Lock()
+	schema, ok := c.schemas[key]
+	c.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	var body struct {
+		Schema string `json:"schema"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/schemas/ids/%d", id), &body); err != nil {
+		return nil, err
+	}
+	// A fresh cache keeps named types from different schema versions apart
+	schema, err := avro.ParseBytesWithCache([]byte(body.Schema), "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema %d: %w", id, err)
+	}
+
+	var versions []subjectVersion
+	if err := c.get(ctx, fmt.Sprintf("/schemas/ids/%d/versions", id), &versions); err != nil {
+		return nil, err
+	}
+	subject := c.Subject(topic, schema)
+	if !slices.ContainsFunc(versions, func(v subjectVersion) bool { return v.Subject == subject }) {
+		return nil, fmt.Errorf("%w: schema %d, subject %s", ErrSubjectMismatch, id, subject)
+	}
+
+	c.mu.Lock()
+	c.schemas[key] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// get fetches path from the registry and decodes the JSON response into v
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build schema registry request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("schema registry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrUnknownSchema, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("schema registry returned status %d for %s", resp.StatusCode, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode schema registry response: %w", err)
+	}
+	return nil
+}
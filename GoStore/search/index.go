@@ -0,0 +1,292 @@
+// Package search keeps a secondary Elasticsearch or OpenSearch index of stored
+// messages for full-text search. It talks to the REST API directly, since the
+// official clients of each refuse to connect to the other
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/lease"
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// indexMapping types the searched and aggregated fields; the rest of each
+// record is only kept in _source
+var indexMapping = map[string]any{
+	"mappings": map[string]any{
+		"dynamic": false,
+		"properties": map[string]any{
+			"tenant_id":    map[string]any{"type": "keyword"},
+			"user_id":      map[string]any{"type": "keyword"},
+			"message_id":   map[string]any{"type": "keyword"},
+			"phone_number": map[string]any{"type": "keyword"},
+			"message":      map[string]any{"type": "text"},
+			"status":       map[string]any{"type": "keyword"},
+			"direction":    map[string]any{"type": "keyword"},
+			"created_at":   map[string]any{"type": "date"},
+			"updated_at":   map[string]any{"type": "date"},
+		},
+	},
+}
+
+// Config configures the search index
+type Config struct {
+	URL      string
+	Index    string
+	Username string // empty disables basic authentication
+	Password string
+}
+
+// Index writes stored messages to the search index and queries it
+type Index struct {
+	baseURL  string
+	index    string
+	username string
+	password string
+	client   *http.Client
+
+	retention atomic.Int64 // time.Duration; zero disables pruning
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewIndex creates a client for the index named in cfg
+func NewIndex(cfg Config) *Index {
+	return &Index{
+		baseURL:  strings.TrimRight(cfg.URL, "/"),
+		index:    cfg.Index,
+		username: cfg.Username,
+		password: cfg.Password,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		stopChan: make(chan struct{}),
+	}
+}
+
+// EnsureIndex creates the index with its mapping unless it already exists
+func (i *Index) EnsureIndex(ctx context.Context) error {
+	status, _, err := i.do(ctx, http.MethodHead, "/"+i.index, "", nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+
+	body, err := json.Marshal(indexMapping)
+	if err != nil {
+		return err
+	}
+	status, resp, err := i.do(ctx, http.MethodPut, "/"+i.index, "application/json", body)
+	if err != nil {
+		return err
+	}
+	// Another instance may have created it first
+	if status != http.StatusOK && !bytes.Contains(resp, []byte("resource_already_exists_exception")) {
+		return fmt.Errorf("failed to create search index %s: status %d: %s", i.index, status, resp)
+	}
+	slog.Info("Created search index", "index", i.index)
+	return nil
+}
+
+// Ping checks that the cluster answers requests
+func (i *Index) Ping(ctx context.Context) error {
+	status, _, err := i.do(ctx, http.MethodGet, "/", "", nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("search cluster returned status %d", status)
+	}
+	return nil
+}
+
+// IndexRecords writes records to the index, replacing any earlier version of each
+func (i *Index) IndexRecords(ctx context.Context, records []*models.SMSRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, record := range records {
+		action := map[string]any{"index": map[string]any{"_index": i.index, "_id": record.ID.Hex()}}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	status, resp, err := i.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("search bulk request returned status %d: %s", status, resp)
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return fmt.Errorf("failed to decode search bulk response: %w", err)
+	}
+	if result.Errors {
+		failed := 0
+		var first json.RawMessage
+		for _, item := range result.Items {
+			for _, op := range item {
+				if op.Error != nil {
+					if failed == 0 {
+						first = op.Error
+					}
+					failed++
+				}
+			}
+		}
+		return fmt.Errorf("failed to index %d of %d records, e.g.: %s", failed, len(records), first)
+	}
+	return nil
+}
+
+// DeleteRecords removes the records with the given document IDs
+func (i *Index) DeleteRecords(ctx context.Context, ids []primitive.ObjectID) error {
+	hexIDs := make([]string, len(ids))
+	for n, id := range ids {
+		hexIDs[n] = id.Hex()
+	}
+	_, err := i.deleteByQuery(ctx, map[string]any{"ids": map[string]any{"values": hexIDs}})
+	return err
+}
+
+// DeleteUser removes every record of the user
+func (i *Index) DeleteUser(ctx context.Context, tenantID, userID string) error {
+	_, err := i.deleteByQuery(ctx, map[string]any{"bool": map[string]any{"filter": []any{
+		map[string]any{"term": map[string]any{"tenant_id": tenantID}},
+		map[string]any{"term": map[string]any{"user_id": userID}},
+	}}})
+	return err
+}
+
+// DeleteOlderThan removes the records created before cutoff and returns how many
+func (i *Index) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	return i.deleteByQuery(ctx, map[string]any{"range": map[string]any{"created_at": map[string]any{"lt": cutoff}}})
+}
+
+// deleteByQuery removes the records matching query, refreshing the index so
+// they stop appearing in search results at once
+func (i *Index) deleteByQuery(ctx context.Context, query map[string]any) (int64, error) {
+	body, err := json.Marshal(map[string]any{"query": query})
+	if err != nil {
+		return 0, err
+	}
+	status, resp, err := i.do(ctx, http.MethodPost, "/"+i.index+"/_delete_by_query?refresh=true&conflicts=proceed", "application/json", body)
+	if err != nil {
+		return 0, err
+	}
+	if status != http.StatusOK {
+		return 0, fmt.Errorf("search delete by query returned status %d: %s", status, resp)
+	}
+	var result struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return 0, fmt.Errorf("failed to decode search delete response: %w", err)
+	}
+	return result.Deleted, nil
+}
+
+// SetRetention changes how long records are kept in the index by StartPruning;
+// zero keeps them indefinitely
+func (i *Index) SetRetention(retention time.Duration) {
+	i.retention.Store(int64(retention))
+}
+
+// StartPruning deletes records older than the retention from the index every
+// interval, mirroring the retention policy of the message store. Only one
+// instance prunes each interval, the one taking the lease in leases
+func (i *Index) StartPruning(leases *lease.Leases, interval time.Duration) {
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-i.stopChan:
+				return
+			case <-ticker.C:
+				retention := time.Duration(i.retention.Load())
+				if retention <= 0 {
+					continue
+				}
+				var deleted int64
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				err := leases.Run(ctx, "search-prune", interval, func(ctx context.Context) error {
+					var err error
+					deleted, err = i.DeleteOlderThan(ctx, time.Now().Add(-retention))
+					return err
+				})
+				cancel()
+				if errors.Is(err, lease.ErrHeld) {
+					continue
+				}
+				if err != nil {
+					slog.Error("Failed to prune search index", "error", err)
+				} else if deleted > 0 {
+					slog.Info("Pruned expired messages from search index", "count", deleted)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops pruning, waiting for an in-progress run to finish
+func (i *Index) Stop() {
+	close(i.stopChan)
+	i.wg.Wait()
+}
+
+// do sends a request and returns the response status and body
+func (i *Index) do(ctx context.Context, method, path, contentType string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, i.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to build search request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if i.username != "" {
+		req.SetBasicAuth(i.username, i.password)
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("search request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read search response: %w", err)
+	}
+	return resp.StatusCode, data, nil
+}
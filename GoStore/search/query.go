@@ -0,0 +1,174 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Ways the query text is matched against message bodies
+const (
+	ModeMatch  = "match"  // every term, in any order
+	ModePhrase = "phrase" // the terms in order, next to each other
+	ModeFuzzy  = "fuzzy"  // every term, allowing typos
+)
+
+// IsValidMode reports whether mode names a supported match mode
+func IsValidMode(mode string) bool {
+	switch mode {
+	case ModeMatch, ModePhrase, ModeFuzzy:
+		return true
+	}
+	return false
+}
+
+// Query describes a full-text search of a user's messages
+type Query struct {
+	UserID   string
+	Text     string
+	Mode     string
+	Statuses []string  // Match any of these statuses; empty matches all
+	Since    time.Time // Inclusive lower bound on created_at; zero means unbounded
+	Until    time.Time // Exclusive upper bound on created_at; zero means unbounded
+	Skip     int
+	Limit    int
+}
+
+// DayCount is the number of matching messages created on one UTC day
+type DayCount struct {
+	Day   string `json:"day"` // YYYY-MM-DD
+	Count int64  `json:"count"`
+}
+
+// Result holds one page of matching messages, best match first, and
+// aggregations over every match
+type Result struct {
+	Total       int64               `json:"total"`
+	Messages    []*models.SMSRecord `json:"messages"`
+	ByStatus    map[string]int64    `json:"by_status"`
+	ByDirection map[string]int64    `json:"by_direction"`
+	ByDay       []DayCount          `json:"by_day"`
+}
+
+// textQuery returns the clause matching the query text in the configured mode
+func textQuery(q *Query) map[string]any {
+	switch q.Mode {
+	case ModePhrase:
+		return map[string]any{"match_phrase": map[string]any{"message": q.Text}}
+	case ModeFuzzy:
+		return map[string]any{"match": map[string]any{"message": map[string]any{
+			"query": q.Text, "operator": "and", "fuzziness": "AUTO",
+		}}}
+	default:
+		return map[string]any{"match": map[string]any{"message": map[string]any{
+			"query": q.Text, "operator": "and",
+		}}}
+	}
+}
+
+// Search runs q against the tenant's messages
+func (i *Index) Search(ctx context.Context, tenantID string, q *Query) (*Result, error) {
+	filters := []any{
+		map[string]any{"term": map[string]any{"tenant_id": tenantID}},
+		map[string]any{"term": map[string]any{"user_id": q.UserID}},
+	}
+	if len(q.Statuses) > 0 {
+		filters = append(filters, map[string]any{"terms": map[string]any{"status": q.Statuses}})
+	}
+	createdAt := map[string]any{}
+	if !q.Since.IsZero() {
+		createdAt["gte"] = q.Since
+	}
+	if !q.Until.IsZero() {
+		createdAt["lt"] = q.Until
+	}
+	if len(createdAt) > 0 {
+		filters = append(filters, map[string]any{"range": map[string]any{"created_at": createdAt}})
+	}
+
+	request := map[string]any{
+		"from":             q.Skip,
+		"size":             q.Limit,
+		"track_total_hits": true,
+		"query": map[string]any{"bool": map[string]any{
+			"must":   []any{textQuery(q)},
+			"filter": filters,
+		}},
+		"sort": []any{"_score", map[string]any{"created_at": "desc"}},
+		"aggs": map[string]any{
+			"by_status":    map[string]any{"terms": map[string]any{"field": "status"}},
+			"by_direction": map[string]any{"terms": map[string]any{"field": "direction"}},
+			"by_day": map[string]any{"date_histogram": map[string]any{
+				"field": "created_at", "calendar_interval": "day", "format": "yyyy-MM-dd", "min_doc_count": 1,
+			}},
+		},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	status, resp, err := i.do(ctx, http.MethodPost, "/"+i.index+"/_search", "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("search returned status %d: %s", status, resp)
+	}
+
+	type termBuckets struct {
+		Buckets []struct {
+			Key      string `json:"key"`
+			DocCount int64  `json:"doc_count"`
+		} `json:"buckets"`
+	}
+	type dayBuckets struct {
+		Buckets []struct {
+			KeyAsString string `json:"key_as_string"`
+			DocCount    int64  `json:"doc_count"`
+		} `json:"buckets"`
+	}
+	var response struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source models.SMSRecord `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations struct {
+			ByStatus    termBuckets `json:"by_status"`
+			ByDirection termBuckets `json:"by_direction"`
+			ByDay       dayBuckets  `json:"by_day"`
+		} `json:"aggregations"`
+	}
+	if err := json.Unmarshal(resp, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	result := &Result{
+		Total:       response.Hits.Total.Value,
+		Messages:    make([]*models.SMSRecord, len(response.Hits.Hits)),
+		ByStatus:    make(map[string]int64),
+		ByDirection: make(map[string]int64),
+		ByDay:       make([]DayCount, len(response.Aggregations.ByDay.Buckets)),
+	}
+	for n := range response.Hits.Hits {
+		result.Messages[n] = &response.Hits.Hits[n].Source
+	}
+	for _, b := range response.Aggregations.ByStatus.Buckets {
+		result.ByStatus[b.Key] = b.DocCount
+	}
+	for _, b := range response.Aggregations.ByDirection.Buckets {
+		result.ByDirection[b.Key] = b.DocCount
+	}
+	for n, b := range response.Aggregations.ByDay.Buckets {
+		result.ByDay[n] = DayCount{Day: b.KeyAsString, Count: b.DocCount}
+	}
+	return result, nil
+}
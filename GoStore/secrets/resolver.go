@@ -0,0 +1,306 @@
+// Package secrets resolves credentials kept in HashiCorp Vault or AWS Secrets
+// Manager, so configuration can reference them instead of holding them in plaintext
+//
+// A reference names the store, the secret and, optionally, one key of it:
+//
+//	vault:secret/data/sms-store#mongo_password  (Vault API path; KV v1 or v2)
+//	awssm:prod/sms-store#kafka_sasl_password    (secret ID; the key picks a field of a JSON secret)
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Reference prefixes of each secret store
+const (
+	vaultPrefix = "vault:"
+	awsPrefix   = "awssm:"
+)
+
+// IsReference reports whether value refers to a secret rather than being one
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, vaultPrefix) || strings.HasPrefix(value, awsPrefix)
+}
+
+// Config configures access to the secret stores
+// AWS credentials and region come from the default credential chain
+type Config struct {
+	VaultAddr       string
+	VaultToken      string
+	VaultNamespace  string        // Vault Enterprise namespace; empty for the root namespace
+	RefreshInterval time.Duration // how often resolved secrets are checked for rotation; zero disables
+}
+
+// Resolver fetches referenced secrets, keeps its Vault token from expiring, and
+// reports secrets rotated since they were resolved
+type Resolver struct {
+	cfg    Config
+	client *http.Client
+
+	mu       sync.Mutex
+	sm       *secretsmanager.Client // created on first use
+	resolved map[string]string      // value by reference
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewResolver creates a resolver for the stores in cfg
+func NewResolver(cfg Config) *Resolver {
+	return &Resolver{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		resolved: make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Resolve returns the secret that ref refers to
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	value, err := r.fetch(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s: %w", ref, err)
+	}
+	r.mu.Lock()
+	r.resolved[ref] = value
+	r.mu.Unlock()
+	return value, nil
+}
+
+// fetch reads ref from its store
+func (r *Resolver) fetch(ctx context.Context, ref string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, vaultPrefix); ok {
+		path, key, _ := strings.Cut(path, "#")
+		if key == "" {
+			return "", fmt.Errorf("Vault references must name a key after #")
+		}
+		return r.fetchVault(ctx, path, key)
+	}
+	if id, ok := strings.CutPrefix(ref, awsPrefix); ok {
+		id, key, _ := strings.Cut(id, "#")
+		return r.fetchSecretsManager(ctx, id, key)
+	}
+	return "", fmt.Errorf("unknown secret store")
+}
+
+// fetchVault reads one key of the secret at the Vault API path
+func (r *Resolver) fetchVault(ctx context.Context, path, key string) (string, error) {
+	if r.cfg.VaultAddr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+
+	var response struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := r.vaultRequest(ctx, http.MethodGet, path, &response); err != nil {
+		return "", err
+	}
+
+	// KV v2 nests the secret under data.data, next to its metadata
+	data := response.Data
+	if nested, ok := data["data"]; ok {
+		if _, versioned := data["metadata"]; versioned {
+			if err := json.Unmarshal(nested, &data); err != nil {
+				return "", fmt.Errorf("failed to decode Vault secret: %w", err)
+			}
+		}
+	}
+
+	raw, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("key %s is not a string", key)
+	}
+	return value, nil
+}
+
+// vaultRequest calls the Vault API at /v1/path and decodes the response into out
+func (r *Resolver) vaultRequest(ctx context.Context, method, path string, out any) error {
+	url := strings.TrimRight(r.cfg.VaultAddr, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build Vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", r.cfg.VaultToken)
+	if r.cfg.VaultNamespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.cfg.VaultNamespace)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Vault returned status %d for %s", resp.StatusCode, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Vault response: %w", err)
+	}
+	return nil
+}
+
+// fetchSecretsManager reads the secret string with the given ID, or one field of
+// it when key is set
+func (r *Resolver) fetchSecretsManager(ctx context.Context, id, key string) (string, error) {
+	client, err := r.secretsManager(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: &id})
+	if err != nil {
+		return "", err
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("secret has no string value")
+	}
+	if key == "" {
+		return *out.SecretString, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(*out.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("key %s not found or not a string", key)
+	}
+	return value, nil
+}
+
+// secretsManager returns the Secrets Manager client, creating it on first use
+func (r *Resolver) secretsManager(ctx context.Context) (*secretsmanager.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sm == nil {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+		}
+		r.sm = secretsmanager.NewFromConfig(awsCfg)
+	}
+	return r.sm, nil
+}
+
+// Start renews the Vault token before it expires, if it is renewable, and checks
+// the resolved secrets for rotation every refresh interval, in background goroutines.
+// Rotated secrets are logged; services pick them up when restarted
+func (r *Resolver) Start() {
+	if r.cfg.VaultAddr != "" && r.cfg.VaultToken != "" {
+		r.wg.Add(1)
+		go r.renewVaultToken()
+	}
+	if r.cfg.RefreshInterval > 0 {
+		r.wg.Add(1)
+		go r.refresh()
+	}
+}
+
+// Stop stops renewal and refreshing
+func (r *Resolver) Stop() {
+	close(r.stopChan)
+	r.wg.Wait()
+}
+
+// renewVaultToken renews the token halfway through each lease, retrying failures every minute
+func (r *Resolver) renewVaultToken() {
+	defer r.wg.Done()
+
+	var lookup struct {
+		Data struct {
+			TTL       int64 `json:"ttl"`
+			Renewable bool  `json:"renewable"`
+		} `json:"data"`
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	err := r.vaultRequest(ctx, http.MethodGet, "auth/token/lookup-self", &lookup)
+	cancel()
+	if err != nil {
+		slog.Error("Failed to look up Vault token; it will not be renewed", "error", err)
+		return
+	}
+	if !lookup.Data.Renewable || lookup.Data.TTL <= 0 {
+		slog.Info("Vault token does not expire or is not renewable")
+		return
+	}
+
+	wait := time.Duration(lookup.Data.TTL) * time.Second / 2
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		case <-time.After(wait):
+		}
+
+		var renewal struct {
+			Auth struct {
+				LeaseDuration int64 `json:"lease_duration"`
+			} `json:"auth"`
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := r.vaultRequest(ctx, http.MethodPost, "auth/token/renew-self", &renewal)
+		cancel()
+		if err != nil || renewal.Auth.LeaseDuration <= 0 {
+			slog.Error("Failed to renew Vault token", "error", err)
+			wait = time.Minute
+			continue
+		}
+		slog.Debug("Renewed Vault token", "lease_seconds", renewal.Auth.LeaseDuration)
+		wait = time.Duration(renewal.Auth.LeaseDuration) * time.Second / 2
+	}
+}
+
+// refresh periodically fetches every resolved secret again and logs those that changed
+func (r *Resolver) refresh() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopChan:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			resolved := make(map[string]string, len(r.resolved))
+			for ref, value := range r.resolved {
+				resolved[ref] = value
+			}
+			r.mu.Unlock()
+
+			for ref, previous := range resolved {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				value, err := r.fetch(ctx, ref)
+				cancel()
+				if err != nil {
+					slog.Error("Failed to refresh secret", "reference", ref, "error", err)
+					continue
+				}
+				if value != previous {
+					slog.Warn("Secret was rotated; restart the service to use the new value", "reference", ref)
+					r.mu.Lock()
+					r.resolved[ref] = value
+					r.mu.Unlock()
+				}
+			}
+		}
+	}
+}
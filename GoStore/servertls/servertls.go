@@ -0,0 +1,169 @@
+// Package servertls serves the HTTP API over TLS, optionally verifying client
+// certificates, and picks up rotated certificates without a restart
+package servertls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config configures the server certificate and client verification
+type Config struct {
+	CertFile           string
+	KeyFile            string
+	ClientCAFile       string // empty disables client certificate verification
+	ClientAuthOptional bool   // verify client certificates only when presented
+	MinVersion         uint16
+}
+
+// ParseVersion returns the TLS version named "1.2" or "1.3"
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q (use 1.2 or 1.3)", version)
+	}
+}
+
+// Certificates holds the TLS settings of the server, reloading the certificate,
+// key and client CA when their files change
+type Certificates struct {
+	cfg     Config
+	current atomic.Pointer[tls.Config]
+
+	mu       sync.Mutex
+	modTimes [3]time.Time // of the cert, key and client CA files when last loaded
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Load reads the files named in cfg
+func Load(cfg Config) (*Certificates, error) {
+	c := &Certificates{cfg: cfg, stopChan: make(chan struct{})}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// TLSConfig returns the config for the HTTP server. Each handshake uses the
+// most recently loaded certificates
+func (c *Certificates) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: c.cfg.MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return c.current.Load(), nil
+		},
+	}
+}
+
+// Reload reads the files again, keeping the loaded certificates if they are invalid
+func (c *Certificates) Reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modTimes, err := c.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.cfg.CertFile, c.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   c.cfg.MinVersion,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if c.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in TLS client CA file %s", c.cfg.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if c.cfg.ClientAuthOptional {
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	c.current.Store(config)
+	c.modTimes = modTimes
+	return nil
+}
+
+// stat returns the modification times of the files
+func (c *Certificates) stat() ([3]time.Time, error) {
+	var modTimes [3]time.Time
+	for n, path := range []string{c.cfg.CertFile, c.cfg.KeyFile, c.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return modTimes, fmt.Errorf("failed to stat TLS file: %w", err)
+		}
+		modTimes[n] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// Start checks the files for changes every interval and reloads them when any
+// changed, so rotated certificates are served without a restart
+func (c *Certificates) Start(interval time.Duration) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stopChan:
+				return
+			case <-ticker.C:
+				modTimes, err := c.stat()
+				if err != nil {
+					slog.Error("Failed to check TLS certificates for changes", "error", err)
+					continue
+				}
+				c.mu.Lock()
+				changed := modTimes != c.modTimes
+				c.mu.Unlock()
+				if !changed {
+					continue
+				}
+				// A rotation may replace the files one at a time; a mismatched
+				// pair fails to load and is retried on the next tick
+				if err := c.Reload(); err != nil {
+					slog.Error("Failed to reload TLS certificates", "error", err)
+					continue
+				}
+				slog.Info("Reloaded rotated TLS certificates")
+			}
+		}
+	}()
+}
+
+// Stop stops checking for changes
+func (c *Certificates) Stop() {
+	close(c.stopChan)
+	c.wg.Wait()
+}
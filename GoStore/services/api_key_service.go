@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// apiKeyPrefix marks raw keys issued by this service
+const apiKeyPrefix = "sk_"
+
+var (
+	// ErrInvalidAPIKey is returned when a key is unknown or revoked
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyNotFound is returned when no key matches the given ID
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKeyService issues, verifies and revokes API keys
+type APIKeyService struct {
+	db *db.Mongo
+}
+
+// NewAPIKeyService creates a new API key service instance on m
+func NewAPIKeyService(m *db.Mongo) *APIKeyService {
+	return &APIKeyService{db: m}
+}
+
+// Authenticate returns the active key matching rawKey
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"key_hash":   hashAPIKey(rawKey),
+		"revoked_at": bson.M{"$exists": false},
+	}
+
+	var key models.APIKey
+	err := s.db.GetAPIKeysCollection().FindOne(queryCtx, filter).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	return &key, nil
+}
+
+// Create issues a new key for the context's tenant and returns the raw key
+// The raw key is not stored and cannot be recovered later
+func (s *APIKeyService) Create(ctx context.Context, key *models.APIKey) (string, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", tenant.ErrMissing
+	}
+
+	rawKey, err := generateAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	key.TenantID = tenantID
+	key.Prefix = rawKey[:len(apiKeyPrefix)+8]
+	key.KeyHash = hashAPIKey(rawKey)
+	key.CreatedAt = time.Now().UTC()
+
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := s.db.GetAPIKeysCollection().InsertOne(insertCtx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert API key: %w", err)
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		key.ID = id
+	}
+
+	slog.InfoContext(ctx, "Created API key", "api_key_id", key.ID.Hex(), "prefix", key.Prefix, "tenant_id", key.TenantID, "scopes", key.Scopes)
+	return rawKey, nil
+}
+
+// Revoke disables a key of the context's tenant
+func (s *APIKeyService) Revoke(ctx context.Context, id string) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrAPIKeyNotFound
+	}
+
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": objectID, "tenant_id": tenantID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}}
+
+	result, err := s.db.GetAPIKeysCollection().UpdateOne(updateCtx, filter, update)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	slog.InfoContext(ctx, "Revoked API key", "api_key_id", id, "tenant_id", tenantID)
+	return nil
+}
+
+// EnsureBootstrapKey stores rawKey as an admin key for tenantID if it isn't known yet,
+// so a fresh deployment has a key that can issue further keys
+func (s *APIKeyService) EnsureBootstrapKey(ctx context.Context, tenantID, rawKey string) error {
+	upsertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	hash := hashAPIKey(rawKey)
+	prefix := rawKey
+	if len(prefix) > len(apiKeyPrefix)+8 {
+		prefix = prefix[:len(apiKeyPrefix)+8]
+	}
+
+	update := bson.M{"$setOnInsert": models.APIKey{
+		Name:      "bootstrap",
+		TenantID:  tenantID,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    []string{models.ScopeAdmin},
+		CreatedAt: time.Now().UTC(),
+	}}
+	opts := options.Update().SetUpsert(true)
+
+	if _, err := s.db.GetAPIKeysCollection().UpdateOne(upsertCtx, bson.M{"key_hash": hash}, update, opts); err != nil {
+		return fmt.Errorf("failed to store bootstrap API key: %w", err)
+	}
+	return nil
+}
+
+// hashAPIKey returns the hex SHA-256 of a raw key
+// Keys are 256-bit random values, so a fast unsalted hash is sufficient
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPIKey returns a new random raw API key
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
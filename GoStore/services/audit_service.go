@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/pseudonym"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+// AuditService records data access and admin operations in the audit log of the
+// message store, and reads the trail back for each tenant
+type AuditService struct {
+	store      store.Store
+	pseudonyms *pseudonym.Hasher // nil records phone numbers as they are
+}
+
+// NewAuditService creates an audit service writing to messageStore
+func NewAuditService(messageStore store.Store) *AuditService {
+	return &AuditService{store: messageStore}
+}
+
+// EnablePseudonyms records the pseudonyms of user IDs and phone numbers, and
+// looks records up by them, matching SMSService.EnablePseudonyms
+func (s *AuditService) EnablePseudonyms(h *pseudonym.Hasher) {
+	s.pseudonyms = h
+}
+
+// Record writes record to the audit log of the context's tenant, stamping it
+// with the time if unset
+func (s *AuditService) Record(ctx context.Context, record *models.AuditRecord) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	record.TenantID = tenantID
+	record.UserID = s.pseudonyms.Hash(record.UserID)
+	record.PhoneNumber = s.pseudonyms.Hash(record.PhoneNumber)
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	if err := s.store.InsertAuditRecord(ctx, record); err != nil {
+		return err
+	}
+
+	slog.DebugContext(ctx, "Audit record written", "audit_action", record.Action, "actor", record.Actor, "user_id", record.UserID, "count", record.ResultCount)
+	return nil
+}
+
+// Find returns the context tenant's audit records matching query, newest first
+func (s *AuditService) Find(ctx context.Context, query *models.AuditQuery) ([]*models.AuditRecord, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.pseudonyms != nil {
+		q := *query
+		q.UserID = s.pseudonyms.Hash(query.UserID)
+		query = &q
+	}
+	return s.store.FindAuditRecords(ctx, tenantID, query)
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrBlockEntryNotFound is returned when no blocklist entry matches the given ID
+	ErrBlockEntryNotFound = errors.New("blocklist entry not found")
+	// ErrBlockEntryExists is returned when the tenant already blocks the same value
+	ErrBlockEntryExists = errors.New("blocklist entry already exists")
+)
+
+// cachedBlocklist is the blocklist of a tenant as last read
+type cachedBlocklist struct {
+	entries []*models.BlockEntry
+	expires time.Time
+}
+
+// BlocklistService manages the blocklists of tenants and checks the messages
+// about to be stored against them
+type BlocklistService struct {
+	db       *db.Mongo
+	action   string
+	cacheTTL time.Duration
+
+	mu     sync.Mutex
+	cached map[string]cachedBlocklist // by tenant
+}
+
+// NewBlocklistService creates a new blocklist service instance applying action
+// to blocked messages, and remembering each tenant's blocklist for cacheTTL.
+// Changes made through another instance are only seen by this one once its
+// copy expires
+func NewBlocklistService(m *db.Mongo, action string, cacheTTL time.Duration) *BlocklistService {
+	return &BlocklistService{
+		db:       m,
+		action:   action,
+		cacheTTL: cacheTTL,
+		cached:   make(map[string]cachedBlocklist),
+	}
+}
+
+// Action returns what happens to blocked messages
+func (s *BlocklistService) Action() string {
+	return s.action
+}
+
+// forget drops the cached blocklist of the tenant
+func (s *BlocklistService) forget(tenantID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cached, tenantID)
+}
+
+// Add stores a new entry in the blocklist of the context's tenant
+func (s *BlocklistService) Add(ctx context.Context, entry *models.BlockEntry) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	entry.TenantID = tenantID
+	entry.CreatedAt = time.Now().UTC()
+	entry.UpdatedAt = entry.CreatedAt
+
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := s.db.GetBlocklistCollection().InsertOne(insertCtx, entry)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrBlockEntryExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert blocklist entry: %w", err)
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		entry.ID = id
+	}
+	s.forget(tenantID)
+
+	slog.InfoContext(ctx, "Added blocklist entry", "id", entry.ID.Hex(), "tenant_id", tenantID, "kind", entry.Kind)
+	return nil
+}
+
+// Get returns an entry of the blocklist of the context's tenant by ID
+func (s *BlocklistService) Get(ctx context.Context, id string) (*models.BlockEntry, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrBlockEntryNotFound
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var entry models.BlockEntry
+	err = s.db.GetBlocklistCollection().FindOne(queryCtx, bson.M{"_id": objectID, "tenant_id": tenantID}).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrBlockEntryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklist entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// List returns the blocklist of the context's tenant by kind and value, only
+// the entries of the given kind when set
+func (s *BlocklistService) List(ctx context.Context, kind string) ([]*models.BlockEntry, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+	filter := bson.M{"tenant_id": tenantID}
+	if kind != "" {
+		filter["kind"] = kind
+	}
+	entries, err := s.find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []*models.BlockEntry{}
+	}
+	return entries, nil
+}
+
+// find returns the entries matching filter by kind and value
+func (s *BlocklistService) find(ctx context.Context, filter bson.M) ([]*models.BlockEntry, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "kind", Value: 1}, {Key: "value", Value: 1}})
+	cursor, err := s.db.GetBlocklistCollection().Find(queryCtx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklist: %w", err)
+	}
+	var entries []*models.BlockEntry
+	if err := cursor.All(queryCtx, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode blocklist: %w", err)
+	}
+	return entries, nil
+}
+
+// Update replaces the kind, value and reason of an entry of the blocklist of
+// the context's tenant
+func (s *BlocklistService) Update(ctx context.Context, id string, entry *models.BlockEntry) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrBlockEntryNotFound
+	}
+
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	update := bson.M{"$set": bson.M{
+		"kind":       entry.Kind,
+		"value":      entry.Value,
+		"reason":     entry.Reason,
+		"updated_at": now,
+	}}
+	var previous models.BlockEntry
+	err = s.db.GetBlocklistCollection().FindOneAndUpdate(updateCtx, bson.M{"_id": objectID, "tenant_id": tenantID}, update).Decode(&previous)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrBlockEntryExists
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrBlockEntryNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update blocklist entry: %w", err)
+	}
+	s.forget(tenantID)
+
+	entry.ID = objectID
+	entry.TenantID = tenantID
+	entry.CreatedAt = previous.CreatedAt
+	entry.UpdatedAt = now
+
+	slog.InfoContext(ctx, "Updated blocklist entry", "id", id, "tenant_id", tenantID, "kind", entry.Kind)
+	return nil
+}
+
+// Delete removes an entry of the blocklist of the context's tenant by ID
+func (s *BlocklistService) Delete(ctx context.Context, id string) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrBlockEntryNotFound
+	}
+
+	deleteCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := s.db.GetBlocklistCollection().DeleteOne(deleteCtx, bson.M{"_id": objectID, "tenant_id": tenantID})
+	if err != nil {
+		return fmt.Errorf("failed to delete blocklist entry: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrBlockEntryNotFound
+	}
+	s.forget(tenantID)
+
+	slog.InfoContext(ctx, "Deleted blocklist entry", "id", id, "tenant_id", tenantID)
+	return nil
+}
+
+// Check returns the entry of its tenant's blocklist blocking each record, by
+// index into records, reading the blocklists not cached
+func (s *BlocklistService) Check(ctx context.Context, records []*models.SMSRecord) (map[int]*models.BlockEntry, error) {
+	blocked := make(map[int]*models.BlockEntry)
+	lists := make(map[string][]*models.BlockEntry)
+	for i, record := range records {
+		if record.TenantID == "" {
+			continue
+		}
+		entries, ok := lists[record.TenantID]
+		if !ok {
+			var err error
+			if entries, err = s.blocklist(ctx, record.TenantID); err != nil {
+				return nil, err
+			}
+			lists[record.TenantID] = entries
+		}
+		for _, entry := range entries {
+			if entry.Matches(record) {
+				blocked[i] = entry
+				break
+			}
+		}
+	}
+	return blocked, nil
+}
+
+// blocklist returns the blocklist of the tenant, from the cache while it is fresh
+func (s *BlocklistService) blocklist(ctx context.Context, tenantID string) ([]*models.BlockEntry, error) {
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.cached[tenantID]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.entries, nil
+	}
+
+	entries, err := s.find(ctx, bson.M{"tenant_id": tenantID})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.cached[tenantID] = cachedBlocklist{entries: entries, expires: now.Add(s.cacheTTL)}
+	s.mu.Unlock()
+	return entries, nil
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/fieldcrypt"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Record fields as authenticated with their ciphertexts
+const (
+	fieldMessage     = "message"
+	fieldPhoneNumber = "phone_number"
+	fieldUserID      = "user_id"
+)
+
+// errBodyRegexEncrypted is returned for queries filtering by body_regex, which
+// the store can't match against ciphertexts
+var errBodyRegexEncrypted = fmt.Errorf("body_regex on encrypted message bodies: %w", errors.ErrUnsupported)
+
+// encryptedStore encrypts the message bodies, and optionally the phone numbers
+// and user IDs, of the records written to a store and decrypts those read back.
+// Phone numbers and user IDs are encrypted deterministically so lookups, erasure
+// and streams by them still match; a lookup only finds the records written
+// under the active key
+type encryptedStore struct {
+	store.Store
+	keys         *fieldcrypt.Keyring
+	phoneNumbers bool
+}
+
+// seal returns an encrypted copy of record. Values that are encrypted already,
+// as in archived records, are kept
+func (e *encryptedStore) seal(record *models.SMSRecord) (*models.SMSRecord, error) {
+	sealed := *record
+	if !fieldcrypt.IsEncrypted(record.Message) {
+		var err error
+		if sealed.Message, err = e.keys.Encrypt(fieldMessage, record.Message); err != nil {
+			return nil, err
+		}
+	}
+	sealed.PhoneNumber = e.sealPhoneNumber(record.PhoneNumber)
+	if record.RawPhoneNumber != "" {
+		sealed.RawPhoneNumber = e.sealPhoneNumber(record.RawPhoneNumber)
+	}
+	sealed.UserID = e.sealUserID(record.UserID)
+	if record.RawUserID != "" {
+		sealed.RawUserID = e.sealUserID(record.RawUserID)
+	}
+	return &sealed, nil
+}
+
+// sealPhoneNumber encrypts a phone number as seal does, to match stored records by it
+func (e *encryptedStore) sealPhoneNumber(phoneNumber string) string {
+	if !e.phoneNumbers || fieldcrypt.IsEncrypted(phoneNumber) {
+		return phoneNumber
+	}
+	return e.keys.EncryptDeterministic(fieldPhoneNumber, phoneNumber)
+}
+
+// sealUserID encrypts a user ID as seal does, to match stored records by it.
+// Without encryption, on a nil store, it is returned as it is
+func (e *encryptedStore) sealUserID(userID string) string {
+	if e == nil || !e.phoneNumbers || fieldcrypt.IsEncrypted(userID) {
+		return userID
+	}
+	return e.keys.EncryptDeterministic(fieldUserID, userID)
+}
+
+// openUserID decrypts a user ID sealed by sealUserID
+func (e *encryptedStore) openUserID(userID string) (string, error) {
+	if e == nil {
+		return userID, nil
+	}
+	return e.keys.Decrypt(fieldUserID, userID)
+}
+
+// sealQuery returns query with its user ID and phone number filters encrypted
+func (e *encryptedStore) sealQuery(query *models.MessageQuery) *models.MessageQuery {
+	if !e.phoneNumbers {
+		return query
+	}
+	sealed := *query
+	sealed.UserID = e.sealUserID(query.UserID)
+	sealed.PhoneNumber = e.sealPhoneNumber(query.PhoneNumber)
+	return &sealed
+}
+
+// open decrypts a record read from the store in place. Values stored before
+// encryption was enabled are read as they are
+func (e *encryptedStore) open(record *models.SMSRecord) error {
+	var err error
+	if record.Message, err = e.keys.Decrypt(fieldMessage, record.Message); err != nil {
+		return err
+	}
+	if record.PhoneNumber, err = e.keys.Decrypt(fieldPhoneNumber, record.PhoneNumber); err != nil {
+		return err
+	}
+	if record.RawPhoneNumber, err = e.keys.Decrypt(fieldPhoneNumber, record.RawPhoneNumber); err != nil {
+		return err
+	}
+	if record.UserID, err = e.keys.Decrypt(fieldUserID, record.UserID); err != nil {
+		return err
+	}
+	if record.RawUserID, err = e.keys.Decrypt(fieldUserID, record.RawUserID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// openAll decrypts records in place
+func (e *encryptedStore) openAll(records []*models.SMSRecord) ([]*models.SMSRecord, error) {
+	for _, record := range records {
+		if err := e.open(record); err != nil {
+			return nil, fmt.Errorf("failed to decrypt message %s: %w", record.ID.Hex(), err)
+		}
+	}
+	return records, nil
+}
+
+func (e *encryptedStore) InsertMessage(ctx context.Context, record *models.SMSRecord) error {
+	sealed, err := e.seal(record)
+	if err != nil {
+		return err
+	}
+	err = e.Store.InsertMessage(ctx, sealed)
+	record.ID = sealed.ID
+	return err
+}
+
+func (e *encryptedStore) InsertMessages(ctx context.Context, records []*models.SMSRecord) (store.InsertResult, error) {
+	sealed := make([]*models.SMSRecord, len(records))
+	for i, record := range records {
+		var err error
+		if sealed[i], err = e.seal(record); err != nil {
+			return store.InsertResult{}, err
+		}
+	}
+	result, err := e.Store.InsertMessages(ctx, sealed)
+	for i, record := range records {
+		record.ID = sealed[i].ID
+	}
+	return result, err
+}
+
+func (e *encryptedStore) FindMessages(ctx context.Context, tenantID string, query *models.MessageQuery) ([]*models.SMSRecord, error) {
+	if query.BodyRegex != "" {
+		return nil, errBodyRegexEncrypted
+	}
+	records, err := e.Store.FindMessages(ctx, tenantID, e.sealQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	return e.openAll(records)
+}
+
+func (e *encryptedStore) StreamMessages(ctx context.Context, tenantID string, query *models.MessageQuery, fn func(*models.SMSRecord) error) error {
+	if query.BodyRegex != "" {
+		return errBodyRegexEncrypted
+	}
+	return e.Store.StreamMessages(ctx, tenantID, e.sealQuery(query), func(record *models.SMSRecord) error {
+		if err := e.open(record); err != nil {
+			return fmt.Errorf("failed to decrypt message %s: %w", record.ID.Hex(), err)
+		}
+		return fn(record)
+	})
+}
+
+func (e *encryptedStore) FindMessagesByPhoneNumber(ctx context.Context, tenantID, phoneNumber string, skip, limit int64) ([]*models.SMSRecord, error) {
+	records, err := e.Store.FindMessagesByPhoneNumber(ctx, tenantID, e.sealPhoneNumber(phoneNumber), skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	return e.openAll(records)
+}
+
+func (e *encryptedStore) Conversations(ctx context.Context, tenantID, userID string, skip, limit int64) ([]*models.Conversation, error) {
+	conversations, err := e.Store.Conversations(ctx, tenantID, e.sealUserID(userID), skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, conversation := range conversations {
+		if conversation.Peer, err = e.keys.Decrypt(fieldPhoneNumber, conversation.Peer); err != nil {
+			return nil, fmt.Errorf("failed to decrypt conversation: %w", err)
+		}
+		if conversation.LastMessage, err = e.keys.Decrypt(fieldMessage, conversation.LastMessage); err != nil {
+			return nil, fmt.Errorf("failed to decrypt conversation: %w", err)
+		}
+	}
+	return conversations, nil
+}
+
+func (e *encryptedStore) CountMessages(ctx context.Context, tenantID string, query *models.MessageQuery) (int64, error) {
+	return e.Store.CountMessages(ctx, tenantID, e.sealQuery(query))
+}
+
+func (e *encryptedStore) MessageStats(ctx context.Context, tenantID string, query *models.MessageQuery) (*models.MessageStats, error) {
+	return e.Store.MessageStats(ctx, tenantID, e.sealQuery(query))
+}
+
+func (e *encryptedStore) FindDuplicates(ctx context.Context, tenantID string, since, until time.Time, limit int64) ([]*models.DuplicateGroup, error) {
+	groups, err := e.Store.FindDuplicates(ctx, tenantID, since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if group.UserID, err = e.openUserID(group.UserID); err != nil {
+			return nil, fmt.Errorf("failed to decrypt duplicate group: %w", err)
+		}
+	}
+	return groups, nil
+}
+
+func (e *encryptedStore) GetMessage(ctx context.Context, tenantID string, id primitive.ObjectID) (*models.SMSRecord, error) {
+	return e.openOne(e.Store.GetMessage(ctx, tenantID, id))
+}
+
+func (e *encryptedStore) UpdateStatus(ctx context.Context, messageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return e.openOne(e.Store.UpdateStatus(ctx, messageID, change))
+}
+
+func (e *encryptedStore) UpdateStatusByProviderID(ctx context.Context, tenantID, providerMessageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return e.openOne(e.Store.UpdateStatusByProviderID(ctx, tenantID, providerMessageID, change))
+}
+
+func (e *encryptedStore) DeleteUserMessages(ctx context.Context, tenantID string, userIDs []string, audit *models.AuditRecord) (int64, error) {
+	sealed := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		sealed[i] = e.sealUserID(userID)
+	}
+	return e.Store.DeleteUserMessages(ctx, tenantID, sealed, audit)
+}
+
+// FindMessagesOlderThan reads records as stored, so archives stay encrypted
+func (e *encryptedStore) FindMessagesOlderThan(ctx context.Context, cutoff time.Time, tenants models.TenantFilter, limit int64) ([]*models.SMSRecord, error) {
+	return e.Store.FindMessagesOlderThan(ctx, cutoff, tenants, limit)
+}
+
+// FindMessagesInIDRange reads records as stored, so exports stay encrypted
+func (e *encryptedStore) FindMessagesInIDRange(ctx context.Context, after, before primitive.ObjectID, limit int64) ([]*models.SMSRecord, error) {
+	return e.Store.FindMessagesInIDRange(ctx, after, before, limit)
+}
+
+// PendingStoredEvents decrypts the records, as published stored events always carried plaintext
+func (e *encryptedStore) PendingStoredEvents(ctx context.Context, limit int64) ([]*models.SMSRecord, error) {
+	records, err := e.Store.PendingStoredEvents(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	return e.openAll(records)
+}
+
+// openOne decrypts the record returned by a lookup
+func (e *encryptedStore) openOne(record *models.SMSRecord, err error) (*models.SMSRecord, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := e.open(record); err != nil {
+		return nil, fmt.Errorf("failed to decrypt message %s: %w", record.ID.Hex(), err)
+	}
+	return record, nil
+}
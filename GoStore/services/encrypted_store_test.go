@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/fieldcrypt"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEncryptedUserIDs(t *testing.T) {
+	svc, st := newTestService()
+	keys, err := fieldcrypt.NewKeyring(map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")}, "k1")
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	svc.EnableEncryption(keys, true)
+	ctx := tenant.WithID(context.Background(), testTenant)
+
+	record := testRecord("m1", "+14155550123", time.Now().UTC())
+	record.RawUserID = "(415) 555-0123"
+	if err := svc.SaveMessage(ctx, record); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	stored, err := st.FindMessagesInIDRange(ctx, primitive.NilObjectID, primitive.NewObjectID(), 10)
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("FindMessagesInIDRange: %d records, %v", len(stored), err)
+	}
+	for field, value := range map[string]string{"user_id": stored[0].UserID, "raw_user_id": stored[0].RawUserID} {
+		if !fieldcrypt.IsEncrypted(value) || strings.Contains(value, "555") {
+			t.Errorf("%s stored as %q", field, value)
+		}
+	}
+
+	records, err := svc.GetMessagesByUserID(ctx, "+14155550123", models.MessageFilter{}, nil, models.MessageSort{})
+	if err != nil {
+		t.Fatalf("GetMessagesByUserID: %v", err)
+	}
+	if len(records) != 1 || records[0].UserID != "+14155550123" || records[0].RawUserID != "(415) 555-0123" {
+		t.Fatalf("GetMessagesByUserID = %+v", records)
+	}
+
+	streamed := 0
+	err = svc.StreamMessagesByUserID(ctx, "+14155550123", models.MessageFilter{}, nil, models.MessageSort{}, 0, func(*models.SMSRecord) error {
+		streamed++
+		return nil
+	})
+	if err != nil || streamed != 1 {
+		t.Fatalf("StreamMessagesByUserID streamed %d, %v", streamed, err)
+	}
+
+	deleted, err := svc.DeleteMessagesByUserID(ctx, "+14155550123", &models.AuditRecord{Actor: "admin"})
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteMessagesByUserID deleted %d, %v", deleted, err)
+	}
+}
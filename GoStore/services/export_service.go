@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExportRunService keeps the history of scheduled export runs, one document per run
+type ExportRunService struct {
+	db *db.Mongo
+}
+
+// NewExportRunService creates a new export run service instance on m
+func NewExportRunService(m *db.Mongo) *ExportRunService {
+	return &ExportRunService{db: m}
+}
+
+// Start records run as running, assigning its ID and start time
+func (s *ExportRunService) Start(ctx context.Context, run *models.ExportRun) error {
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	run.Status = models.ExportRunning
+	run.StartedAt = time.Now().UTC()
+	result, err := s.db.GetExportRunsCollection().InsertOne(insertCtx, run)
+	if err != nil {
+		return fmt.Errorf("failed to record export run: %w", err)
+	}
+	run.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Finish records the outcome of run, setting its finish time
+func (s *ExportRunService) Finish(ctx context.Context, run *models.ExportRun) error {
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	run.FinishedAt = time.Now().UTC()
+	if _, err := s.db.GetExportRunsCollection().ReplaceOne(updateCtx, bson.M{"_id": run.ID}, run); err != nil {
+		return fmt.Errorf("failed to record export run outcome: %w", err)
+	}
+	return nil
+}
+
+// LastSucceeded returns the most recent successful run of job, or nil if it
+// never succeeded
+func (s *ExportRunService) LastSucceeded(ctx context.Context, job string) (*models.ExportRun, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"job": job, "status": models.ExportSucceeded}
+	opts := options.FindOne().SetSort(bson.D{{Key: "started_at", Value: -1}})
+	var run models.ExportRun
+	err := s.db.GetExportRunsCollection().FindOne(queryCtx, filter, opts).Decode(&run)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last export run: %w", err)
+	}
+	return &run, nil
+}
+
+// List returns the latest limit runs of job, newest first
+func (s *ExportRunService) List(ctx context.Context, job string, limit int64) ([]*models.ExportRun, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(limit)
+	cursor, err := s.db.GetExportRunsCollection().Find(queryCtx, bson.M{"job": job}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query export runs: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	runs := []*models.ExportRun{}
+	if err := cursor.All(queryCtx, &runs); err != nil {
+		return nil, fmt.Errorf("failed to decode export runs: %w", err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrQuotaExceeded is returned for a message whose user has reached their storage quota
+var ErrQuotaExceeded = errors.New("user storage quota exceeded")
+
+// reconcileBatch is how many users' usage a reconciliation writes at a time
+const reconcileBatch = 1000
+
+// QuotaService tracks how many messages each user has stored, one document per
+// user, and enforces the storage quota every user shares
+type QuotaService struct {
+	db      *db.Mongo
+	quota   models.UserQuota
+	action  string
+	warning float64 // share of the quota at which users are warned about
+	broker  *events.Broker
+	users   *encryptedStore // seals user IDs as stored; nil keeps them as they are
+}
+
+// NewQuotaService creates a service enforcing quota with action, publishing
+// quota events to broker once a user's usage reaches warningPercent of it
+func NewQuotaService(m *db.Mongo, quota models.UserQuota, action string, warningPercent int, broker *events.Broker) *QuotaService {
+	return &QuotaService{
+		db:      m,
+		quota:   quota,
+		action:  action,
+		warning: float64(warningPercent) / 100,
+		broker:  broker,
+	}
+}
+
+// Quota returns the quota of every user
+func (s *QuotaService) Quota() models.UserQuota {
+	return s.quota
+}
+
+// WarningLevel returns the share of the quota at which users are warned about
+func (s *QuotaService) WarningLevel() float64 {
+	return s.warning
+}
+
+// userUsageID returns the ID of a user's usage document
+func userUsageID(tenantID, userID string) string {
+	return tenantID + "/" + userID
+}
+
+// recordUsageID returns the ID of the usage document of record's user, whose
+// user ID is kept as stored, matching the records Reconcile counts
+func (s *QuotaService) recordUsageID(record *models.SMSRecord) string {
+	return userUsageID(record.TenantID, s.users.sealUserID(record.UserID))
+}
+
+// recordSize estimates the stored size of a record from its BSON encoding
+func recordSize(record *models.SMSRecord) int64 {
+	data, err := bson.Marshal(record)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// Admit returns ErrQuotaExceeded, by index, for the records that would take
+// their user past the quota, when its action is to reject them. The others may
+// be stored
+func (s *QuotaService) Admit(ctx context.Context, records []*models.SMSRecord) (map[int]error, error) {
+	if s.action != models.QuotaActionReject || len(records) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, s.recordUsageID(record))
+	}
+	usage, err := s.find(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rejected := make(map[int]error)
+	for i, record := range records {
+		id := s.recordUsageID(record)
+		u := usage[id]
+		if u == nil {
+			u = &models.UserUsage{}
+			usage[id] = u
+		}
+		size := recordSize(record)
+		if (s.quota.MaxMessages > 0 && u.Messages+1 > s.quota.MaxMessages) || (s.quota.MaxBytes > 0 && u.Bytes+size > s.quota.MaxBytes) {
+			rejected[i] = ErrQuotaExceeded
+			metrics.UserQuotaRejected()
+			slog.WarnContext(ctx, "Rejected message over the user's storage quota", "tenant_id", record.TenantID, "user_id", record.UserID, "message_id", record.MessageID)
+			continue
+		}
+		// Later records of the batch count the earlier ones
+		u.Messages++
+		u.Bytes += size
+	}
+	return rejected, nil
+}
+
+// find returns the usage documents with the given IDs, by ID
+func (s *QuotaService) find(ctx context.Context, ids []string) (map[string]*models.UserUsage, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.GetUserUsageCollection().Find(queryCtx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user usage: %w", err)
+	}
+	var found []*models.UserUsage
+	if err := cursor.All(queryCtx, &found); err != nil {
+		return nil, fmt.Errorf("failed to decode user usage: %w", err)
+	}
+
+	usage := make(map[string]*models.UserUsage, len(found))
+	for _, u := range found {
+		usage[u.ID] = u
+	}
+	return usage, nil
+}
+
+// Record adds stored records to their users' usage, alerting about the users
+// whose usage crossed the warning level or reached the quota
+func (s *QuotaService) Record(ctx context.Context, records []*models.SMSRecord) error {
+	type delta struct {
+		messages, bytes int64
+		last            *models.SMSRecord
+	}
+	deltas := make(map[string]*delta)
+	var order []string
+	for _, record := range records {
+		id := s.recordUsageID(record)
+		d := deltas[id]
+		if d == nil {
+			d = &delta{}
+			deltas[id] = d
+			order = append(order, id)
+		}
+		d.messages++
+		d.bytes += recordSize(record)
+		d.last = record
+	}
+
+	var errs []error
+	for _, id := range order {
+		d := deltas[id]
+		after, err := s.add(ctx, d.last.TenantID, s.users.sealUserID(d.last.UserID), d.messages, d.bytes)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		before := *after
+		before.Messages -= d.messages
+		before.Bytes -= d.bytes
+		s.alert(ctx, &before, after, d.last)
+	}
+	return errors.Join(errs...)
+}
+
+// Release subtracts messages removed to make room from their user's usage
+func (s *QuotaService) Release(ctx context.Context, tenantID, userID string, messages, bytes int64) error {
+	_, err := s.add(ctx, tenantID, userID, -messages, -bytes)
+	return err
+}
+
+// add changes a user's usage and returns it as changed
+func (s *QuotaService) add(ctx context.Context, tenantID, userID string, messages, bytes int64) (*models.UserUsage, error) {
+	updateCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$inc":         bson.M{"messages": messages, "bytes": bytes},
+		"$set":         bson.M{"updated_at": time.Now().UTC()},
+		"$setOnInsert": bson.M{"tenant_id": tenantID, "user_id": userID},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var usage models.UserUsage
+	err := s.db.GetUserUsageCollection().FindOneAndUpdate(updateCtx, bson.M{"_id": userUsageID(tenantID, userID)}, update, opts).Decode(&usage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user usage: %w", err)
+	}
+	return &usage, nil
+}
+
+// alert reports a user whose usage crossed the warning level or reached the
+// quota, in logs, metrics and a quota event about record
+func (s *QuotaService) alert(ctx context.Context, before, after *models.UserUsage, record *models.SMSRecord) {
+	used, was := after.Used(s.quota), before.Used(s.quota)
+	var level, eventType string
+	switch {
+	case was < 1 && used >= 1:
+		level, eventType = models.QuotaLevelExceeded, events.QuotaExceeded
+	case was < s.warning && used >= s.warning:
+		level, eventType = models.QuotaLevelWarning, events.QuotaWarning
+	default:
+		return
+	}
+
+	// Events carry the user ID as the record does, not as stored
+	usage := *after
+	usage.UserID = record.UserID
+	metrics.UserQuotaAlert(level)
+	slog.WarnContext(ctx, "User storage quota level reached", "level", level, "tenant_id", usage.TenantID, "user_id", usage.UserID,
+		"messages", usage.Messages, "bytes", usage.Bytes, "max_messages", s.quota.MaxMessages, "max_bytes", s.quota.MaxBytes)
+	s.broker.PublishUsage(eventType, record, &usage)
+}
+
+// Excess returns how many of records, a user's oldest messages oldest first,
+// must be removed to bring usage back below the warning level, and their size
+func (s *QuotaService) Excess(usage *models.UserUsage, records []*models.SMSRecord) (int, int64) {
+	remaining := *usage
+	var bytes int64
+	for n, record := range records {
+		if remaining.Used(s.quota) < s.warning {
+			return n, bytes
+		}
+		size := recordSize(record)
+		remaining.Messages--
+		remaining.Bytes -= size
+		bytes += size
+	}
+	return len(records), bytes
+}
+
+// List returns up to limit users by size, largest first, of tenantID or of
+// every tenant if empty. With minUsed above zero only users using at least
+// that share of the quota are listed
+// This is a maintenance operation and may span tenants
+func (s *QuotaService) List(ctx context.Context, tenantID string, minUsed float64, limit int64) ([]*models.UserUsage, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if tenantID != "" {
+		filter["tenant_id"] = tenantID
+	}
+	if minUsed > 0 {
+		if over := s.atLeast(minUsed); over != nil {
+			filter["$or"] = over
+		}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "bytes", Value: -1}}).SetLimit(limit)
+	cursor, err := s.db.GetUserUsageCollection().Find(queryCtx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user usage: %w", err)
+	}
+	usage := []*models.UserUsage{}
+	if err := cursor.All(queryCtx, &usage); err != nil {
+		return nil, fmt.Errorf("failed to decode user usage: %w", err)
+	}
+	for _, u := range usage {
+		if u.UserID, err = s.users.openUserID(u.UserID); err != nil {
+			return nil, fmt.Errorf("failed to decrypt user usage: %w", err)
+		}
+	}
+	return usage, nil
+}
+
+// OverQuota returns the users whose usage exceeds the quota
+// This is a maintenance operation and spans all tenants
+func (s *QuotaService) OverQuota(ctx context.Context) ([]*models.UserUsage, error) {
+	over := s.above()
+	if over == nil {
+		return nil, nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.GetUserUsageCollection().Find(queryCtx, bson.M{"$or": over})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users over quota: %w", err)
+	}
+	var usage []*models.UserUsage
+	if err := cursor.All(queryCtx, &usage); err != nil {
+		return nil, fmt.Errorf("failed to decode users over quota: %w", err)
+	}
+	return usage, nil
+}
+
+// atLeast returns the conditions of a usage taking at least share of the quota,
+// or nil when it is unlimited
+func (s *QuotaService) atLeast(share float64) bson.A {
+	var conditions bson.A
+	if s.quota.MaxMessages > 0 {
+		conditions = append(conditions, bson.M{"messages": bson.M{"$gte": share * float64(s.quota.MaxMessages)}})
+	}
+	if s.quota.MaxBytes > 0 {
+		conditions = append(conditions, bson.M{"bytes": bson.M{"$gte": share * float64(s.quota.MaxBytes)}})
+	}
+	return conditions
+}
+
+// above returns the conditions of a usage exceeding the quota, or nil when it is unlimited
+func (s *QuotaService) above() bson.A {
+	var conditions bson.A
+	if s.quota.MaxMessages > 0 {
+		conditions = append(conditions, bson.M{"messages": bson.M{"$gt": s.quota.MaxMessages}})
+	}
+	if s.quota.MaxBytes > 0 {
+		conditions = append(conditions, bson.M{"bytes": bson.M{"$gt": s.quota.MaxBytes}})
+	}
+	return conditions
+}
+
+// Reconcile recounts every user's usage from the stored messages of both
+// tiers, correcting for messages removed by retention, erasure or archival
+// since, and removes the usage of users without messages. It returns how many
+// users have messages. Messages stored while it runs may be left out until the
+// next reconciliation
+// This is a maintenance operation and spans all tenants
+func (s *QuotaService) Reconcile(ctx context.Context) (int, error) {
+	started := time.Now().UTC()
+
+	var pipeline mongo.Pipeline
+	cold, err := s.db.ColdTierExists(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up cold tier: %w", err)
+	}
+	if cold {
+		pipeline = append(pipeline, bson.D{{Key: "$unionWith", Value: bson.M{"coll": db.ColdRecordsCollection}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$group", Value: bson.M{
+		"_id":      bson.M{"tenant_id": "$tenant_id", "user_id": "$user_id"},
+		"messages": bson.M{"$sum": 1},
+		"bytes":    bson.M{"$sum": bson.M{"$bsonSize": "$$ROOT"}},
+	}}})
+	cursor, err := s.db.GetCollection().Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count user usage: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	users := 0
+	var writes []mongo.WriteModel
+	flush := func() error {
+		if len(writes) == 0 {
+			return nil
+		}
+		_, err := s.db.GetUserUsageCollection().BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		writes = writes[:0]
+		if err != nil {
+			return fmt.Errorf("failed to write user usage: %w", err)
+		}
+		return nil
+	}
+	for cursor.Next(ctx) {
+		var group struct {
+			ID struct {
+				TenantID string `bson:"tenant_id"`
+				UserID   string `bson:"user_id"`
+			} `bson:"_id"`
+			Messages int64 `bson:"messages"`
+			Bytes    int64 `bson:"bytes"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return users, fmt.Errorf("failed to decode user usage: %w", err)
+		}
+		users++
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": userUsageID(group.ID.TenantID, group.ID.UserID)}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"tenant_id":     group.ID.TenantID,
+				"user_id":       group.ID.UserID,
+				"messages":      group.Messages,
+				"bytes":         group.Bytes,
+				"updated_at":    time.Now().UTC(),
+				"reconciled_at": started,
+			}}).
+			SetUpsert(true))
+		if len(writes) == reconcileBatch {
+			if err := flush(); err != nil {
+				return users, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return users, fmt.Errorf("cursor error while counting user usage: %w", err)
+	}
+	if err := flush(); err != nil {
+		return users, err
+	}
+
+	// Users not counted and not written to since have no messages left
+	_, err = s.db.GetUserUsageCollection().DeleteMany(ctx, bson.M{"updated_at": bson.M{"$lt": started}})
+	if err != nil {
+		return users, fmt.Errorf("failed to remove usage of users without messages: %w", err)
+	}
+	return users, nil
+}
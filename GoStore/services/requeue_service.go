@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/media"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+var (
+	// ErrRequeueLimit is returned when a message has been requeued as many times as allowed
+	ErrRequeueLimit = store.ErrRequeueLimit
+	// ErrNotRequeueable is returned for inbound messages, which have no sender to re-deliver them
+	ErrNotRequeueable = errors.New("only outbound messages can be requeued")
+)
+
+// Requeuer publishes the event of a stored message, e.g. to Kafka
+type Requeuer interface {
+	Topic() string
+	Requeue(ctx context.Context, recordID string, event *models.KafkaEvent, attempt int) error
+}
+
+// RequeueResult describes a message that was requeued
+type RequeueResult struct {
+	ID          string `json:"id"`
+	MessageID   string `json:"message_id"`
+	TenantID    string `json:"tenant_id"`
+	Topic       string `json:"topic"`
+	Attempt     int    `json:"attempt"`
+	MaxAttempts int    `json:"max_attempts"`
+}
+
+// RequeueService republishes stored messages for re-delivery. Each requeue is
+// counted on the stored message, which limits how often one message can be
+// requeued across instances and restarts, and recorded in the audit log before
+// it is published
+type RequeueService struct {
+	sms         *SMSService
+	audit       *AuditService
+	requeuer    Requeuer
+	maxAttempts int
+}
+
+// NewRequeueService creates a new requeue service instance publishing through
+// requeuer, allowing each message to be requeued at most maxAttempts times
+func NewRequeueService(sms *SMSService, audit *AuditService, requeuer Requeuer, maxAttempts int) *RequeueService {
+	return &RequeueService{sms: sms, audit: audit, requeuer: requeuer, maxAttempts: maxAttempts}
+}
+
+// Requeue republishes the context tenant's message with the given document ID,
+// attachments included, and records audit, describing who asked for it, first.
+// A requeue that fails to publish still counts towards the limit
+func (s *RequeueService) Requeue(ctx context.Context, id string, audit *models.AuditRecord) (*RequeueResult, error) {
+	record, err := s.sms.GetMessageByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.Direction == models.DirectionInbound {
+		return nil, ErrNotRequeueable
+	}
+
+	event := record.ToKafkaEvent()
+	if event.Media, err = s.readMedia(ctx, record); err != nil {
+		return nil, err
+	}
+
+	attempt, err := s.sms.store.ClaimRequeue(ctx, record.TenantID, record.ID, s.maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Action = models.AuditActionRequeueMessage
+	audit.UserID = record.UserID
+	audit.TargetID = id
+	audit.ResultCount = int64(attempt)
+	if err := s.audit.Record(ctx, audit); err != nil {
+		return nil, fmt.Errorf("failed to write audit record: %w", err)
+	}
+
+	if err := s.requeuer.Requeue(ctx, id, event, attempt); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Requeued message", "id", id, "message_id", record.MessageID, "topic", s.requeuer.Topic(), "attempt", attempt)
+	return &RequeueResult{
+		ID:          id,
+		MessageID:   record.MessageID,
+		TenantID:    record.TenantID,
+		Topic:       s.requeuer.Topic(),
+		Attempt:     attempt,
+		MaxAttempts: s.maxAttempts,
+	}, nil
+}
+
+// readMedia reads the data of the record's attachments back from the media store
+func (s *RequeueService) readMedia(ctx context.Context, record *models.SMSRecord) ([]models.KafkaMedia, error) {
+	if len(record.Media) == 0 {
+		return nil, nil
+	}
+	if s.sms.media == nil {
+		return nil, fmt.Errorf("message has %d attachments but media storage is not enabled", len(record.Media))
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	attachments := make([]models.KafkaMedia, len(record.Media))
+	for n, attachment := range record.Media {
+		data, err := s.sms.media.Open(readCtx, media.Key(record, n))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open attachment %d: %w", n, err)
+		}
+		body, err := io.ReadAll(data)
+		data.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment %d: %w", n, err)
+		}
+		attachments[n] = models.KafkaMedia{ContentType: attachment.ContentType, FileName: attachment.FileName, Data: body}
+	}
+	return attachments, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+// countingRequeuer counts the events it is asked to publish
+type countingRequeuer struct{ published atomic.Int64 }
+
+func (r *countingRequeuer) Topic() string { return "sms-requeue" }
+
+func (r *countingRequeuer) Requeue(ctx context.Context, recordID string, event *models.KafkaEvent, attempt int) error {
+	r.published.Add(1)
+	return nil
+}
+
+func TestRequeueLimitAcrossInstances(t *testing.T) {
+	svc, st := newTestService()
+	ctx := tenant.WithID(context.Background(), testTenant)
+	record := testRecord("m1", "u1", time.Now().UTC())
+	if err := svc.SaveMessage(ctx, record); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	// Two instances over one store, as replicas share one database
+	const maxAttempts = 2
+	requeuer := &countingRequeuer{}
+	instances := []*RequeueService{
+		NewRequeueService(svc, NewAuditService(st), requeuer, maxAttempts),
+		NewRequeueService(svc, NewAuditService(st), requeuer, maxAttempts),
+	}
+
+	var wg sync.WaitGroup
+	var requeued, limited atomic.Int64
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := instances[i%len(instances)].Requeue(ctx, record.ID.Hex(), &models.AuditRecord{Actor: "admin"})
+			switch {
+			case err == nil:
+				requeued.Add(1)
+			case errors.Is(err, ErrRequeueLimit):
+				limited.Add(1)
+			default:
+				t.Errorf("Requeue: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if requeued.Load() != maxAttempts || limited.Load() != 10-maxAttempts {
+		t.Errorf("requeued %d and limited %d, want %d and %d", requeued.Load(), limited.Load(), maxAttempts, 10-maxAttempts)
+	}
+	if requeuer.published.Load() != maxAttempts {
+		t.Errorf("published %d events, want %d", requeuer.published.Load(), maxAttempts)
+	}
+	audits, err := st.FindAuditRecords(context.Background(), testTenant, &models.AuditQuery{Action: models.AuditActionRequeueMessage})
+	if err != nil {
+		t.Fatalf("FindAuditRecords: %v", err)
+	}
+	if len(audits) != maxAttempts {
+		t.Errorf("%d requeues audited, want %d", len(audits), maxAttempts)
+	}
+}
+
+func TestRequeueRefusesInboundMessages(t *testing.T) {
+	svc, st := newTestService()
+	ctx := tenant.WithID(context.Background(), testTenant)
+	record := testRecord("m1", "u1", time.Now().UTC())
+	record.Direction = models.DirectionInbound
+	if err := svc.SaveMessage(ctx, record); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	requeue := NewRequeueService(svc, NewAuditService(st), &countingRequeuer{}, 1)
+	if _, err := requeue.Requeue(ctx, record.ID.Hex(), &models.AuditRecord{}); !errors.Is(err, ErrNotRequeueable) {
+		t.Errorf("Requeue of an inbound message = %v, want ErrNotRequeueable", err)
+	}
+}
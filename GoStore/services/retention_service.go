@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrRetentionPolicyNotFound is returned when the tenant has no retention policy of its own
+var ErrRetentionPolicyNotFound = errors.New("retention policy not found")
+
+// RetentionPolicyService manages the retention policies of tenants, one per tenant
+type RetentionPolicyService struct {
+	db *db.Mongo
+}
+
+// NewRetentionPolicyService creates a new retention policy service instance on m
+func NewRetentionPolicyService(m *db.Mongo) *RetentionPolicyService {
+	return &RetentionPolicyService{db: m}
+}
+
+// Get returns the retention policy of the context's tenant
+func (s *RetentionPolicyService) Get(ctx context.Context) (*models.RetentionPolicy, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var policy models.RetentionPolicy
+	err := s.db.GetRetentionPoliciesCollection().FindOne(queryCtx, bson.M{"tenant_id": tenantID}).Decode(&policy)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrRetentionPolicyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Set stores policy as the retention policy of the context's tenant, replacing
+// any earlier one
+func (s *RetentionPolicyService) Set(ctx context.Context, policy *models.RetentionPolicy) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	policy.TenantID = tenantID
+	policy.UpdatedAt = time.Now().UTC()
+
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Replace().SetUpsert(true)
+	if _, err := s.db.GetRetentionPoliciesCollection().ReplaceOne(updateCtx, bson.M{"tenant_id": tenantID}, policy, opts); err != nil {
+		return fmt.Errorf("failed to store retention policy: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Set retention policy", "tenant_id", tenantID, "retention_days", policy.RetentionDays, "action", policy.Action)
+	return nil
+}
+
+// Delete removes the retention policy of the context's tenant, so the default applies again
+func (s *RetentionPolicyService) Delete(ctx context.Context) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+
+	deleteCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := s.db.GetRetentionPoliciesCollection().DeleteOne(deleteCtx, bson.M{"tenant_id": tenantID})
+	if err != nil {
+		return fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrRetentionPolicyNotFound
+	}
+
+	slog.InfoContext(ctx, "Deleted retention policy", "tenant_id", tenantID)
+	return nil
+}
+
+// All returns the retention policies of every tenant that has one
+// This is a maintenance operation and spans all tenants
+func (s *RetentionPolicyService) All(ctx context.Context) ([]*models.RetentionPolicy, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.GetRetentionPoliciesCollection().Find(queryCtx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention policies: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	var policies []*models.RetentionPolicy
+	if err := cursor.All(queryCtx, &policies); err != nil {
+		return nil, fmt.Errorf("failed to decode retention policies: %w", err)
+	}
+	return policies, nil
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrSenderNotFound is returned when no registered sender matches the given ID
+	ErrSenderNotFound = errors.New("sender not found")
+	// ErrSenderExists is returned when the tenant has already registered the sender ID
+	ErrSenderExists = errors.New("sender ID already registered")
+)
+
+// maxCachedSenders bounds the sender IDs the service remembers between lookups;
+// the cache is emptied when it grows past it
+const maxCachedSenders = 10000
+
+// cachedSender is the resolved type of a sender ID, empty when it is not registered
+type cachedSender struct {
+	senderType string
+	expires    time.Time
+}
+
+// SenderService manages the sender IDs registered by tenants and resolves the
+// senders of stored messages against them
+type SenderService struct {
+	db       *db.Mongo
+	cacheTTL time.Duration
+
+	mu     sync.Mutex
+	cached map[string]cachedSender // by tenant and sender ID
+}
+
+// NewSenderService creates a new sender service instance, remembering how each
+// sender ID resolved for cacheTTL. Changes made through another instance are
+// only seen by this one once its entries expire
+func NewSenderService(m *db.Mongo, cacheTTL time.Duration) *SenderService {
+	return &SenderService{
+		db:       m,
+		cacheTTL: cacheTTL,
+		cached:   make(map[string]cachedSender),
+	}
+}
+
+// senderKey is the cache key of a tenant's sender ID
+func senderKey(tenantID, senderID string) string {
+	return tenantID + "\x00" + senderID
+}
+
+// forget drops the cached resolution of the tenant's sender IDs
+func (s *SenderService) forget(tenantID string, senderIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, senderID := range senderIDs {
+		delete(s.cached, senderKey(tenantID, senderID))
+	}
+}
+
+// Register stores a new sender for the context's tenant
+func (s *SenderService) Register(ctx context.Context, sender *models.Sender) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	sender.TenantID = tenantID
+	sender.CreatedAt = time.Now().UTC()
+	sender.UpdatedAt = sender.CreatedAt
+
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := s.db.GetSendersCollection().InsertOne(insertCtx, sender)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSenderExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert sender: %w", err)
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		sender.ID = id
+	}
+	s.forget(tenantID, sender.SenderID)
+
+	slog.InfoContext(ctx, "Registered sender", "id", sender.ID.Hex(), "tenant_id", tenantID, "sender_id", sender.SenderID, "type", sender.Type)
+	return nil
+}
+
+// Get returns a sender of the context's tenant by ID
+func (s *SenderService) Get(ctx context.Context, id string) (*models.Sender, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSenderNotFound
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var sender models.Sender
+	err = s.db.GetSendersCollection().FindOne(queryCtx, bson.M{"_id": objectID, "tenant_id": tenantID}).Decode(&sender)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSenderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sender: %w", err)
+	}
+	return &sender, nil
+}
+
+// List returns the senders of the context's tenant by sender ID, only those
+// with the given sender ID and type when set
+func (s *SenderService) List(ctx context.Context, senderID, senderType string) ([]*models.Sender, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+
+	filter := bson.M{"tenant_id": tenantID}
+	if senderID != "" {
+		filter["sender_id"] = senderID
+	}
+	if senderType != "" {
+		filter["type"] = senderType
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.GetSendersCollection().Find(queryCtx, filter, options.Find().SetSort(bson.D{{Key: "sender_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query senders: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	senders := []*models.Sender{}
+	if err := cursor.All(queryCtx, &senders); err != nil {
+		return nil, fmt.Errorf("failed to decode senders: %w", err)
+	}
+	return senders, nil
+}
+
+// Update replaces the sender ID, type and description of a sender of the
+// context's tenant. Messages stored before keep the type they were stored with
+func (s *SenderService) Update(ctx context.Context, id string, sender *models.Sender) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrSenderNotFound
+	}
+
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	update := bson.M{"$set": bson.M{
+		"sender_id":   sender.SenderID,
+		"type":        sender.Type,
+		"description": sender.Description,
+		"updated_at":  now,
+	}}
+	// The previous sender ID is returned, so its cached resolution can be dropped too
+	var previous models.Sender
+	err = s.db.GetSendersCollection().FindOneAndUpdate(updateCtx, bson.M{"_id": objectID, "tenant_id": tenantID}, update).Decode(&previous)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrSenderExists
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrSenderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update sender: %w", err)
+	}
+	s.forget(tenantID, previous.SenderID, sender.SenderID)
+
+	sender.ID = objectID
+	sender.TenantID = tenantID
+	sender.CreatedAt = previous.CreatedAt
+	sender.UpdatedAt = now
+
+	slog.InfoContext(ctx, "Updated sender", "id", id, "tenant_id", tenantID, "sender_id", sender.SenderID, "type", sender.Type)
+	return nil
+}
+
+// Delete removes a sender of the context's tenant by ID. Messages stored
+// before keep the type they were stored with
+func (s *SenderService) Delete(ctx context.Context, id string) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrSenderNotFound
+	}
+
+	deleteCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var deleted models.Sender
+	err = s.db.GetSendersCollection().FindOneAndDelete(deleteCtx, bson.M{"_id": objectID, "tenant_id": tenantID}).Decode(&deleted)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrSenderNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete sender: %w", err)
+	}
+	s.forget(tenantID, deleted.SenderID)
+
+	slog.InfoContext(ctx, "Deleted sender", "id", id, "tenant_id", tenantID, "sender_id", deleted.SenderID)
+	return nil
+}
+
+// Resolve sets the sender type of each record whose sender ID its tenant has
+// registered, looking up the sender IDs not cached in one query. Records that
+// already carry a sender type, such as restored ones, keep it
+func (s *SenderService) Resolve(ctx context.Context, records []*models.SMSRecord) error {
+	now := time.Now()
+	var missing bson.A
+	pending := make(map[string]bool)
+
+	s.mu.Lock()
+	var unresolved []*models.SMSRecord
+	for _, record := range records {
+		if record.SenderID == "" || record.SenderType != "" {
+			continue
+		}
+		key := senderKey(record.TenantID, record.SenderID)
+		if cached, ok := s.cached[key]; ok && now.Before(cached.expires) {
+			record.SenderType = cached.senderType
+			continue
+		}
+		unresolved = append(unresolved, record)
+		if !pending[key] {
+			pending[key] = true
+			missing = append(missing, bson.M{"tenant_id": record.TenantID, "sender_id": record.SenderID})
+		}
+	}
+	s.mu.Unlock()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.GetSendersCollection().Find(queryCtx, bson.M{"$or": missing},
+		options.Find().SetProjection(bson.M{"tenant_id": 1, "sender_id": 1, "type": 1}))
+	if err != nil {
+		return fmt.Errorf("failed to look up senders: %w", err)
+	}
+	var senders []*models.Sender
+	if err := cursor.All(queryCtx, &senders); err != nil {
+		return fmt.Errorf("failed to decode senders: %w", err)
+	}
+
+	resolved := make(map[string]string, len(pending))
+	for key := range pending {
+		resolved[key] = "" // not registered
+	}
+	for _, sender := range senders {
+		resolved[senderKey(sender.TenantID, sender.SenderID)] = sender.Type
+	}
+
+	s.mu.Lock()
+	if len(s.cached)+len(resolved) > maxCachedSenders {
+		s.cached = make(map[string]cachedSender)
+	}
+	expires := now.Add(s.cacheTTL)
+	for key, senderType := range resolved {
+		s.cached[key] = cachedSender{senderType: senderType, expires: expires}
+	}
+	s.mu.Unlock()
+
+	for _, record := range unresolved {
+		record.SenderType = resolved[senderKey(record.TenantID, record.SenderID)]
+	}
+	return nil
+}
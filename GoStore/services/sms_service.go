@@ -2,123 +2,1145 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/ramG-reddy/sms-store/cache"
 	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/fieldcrypt"
+	"github.com/ramG-reddy/sms-store/flagging"
+	"github.com/ramG-reddy/sms-store/media"
+	"github.com/ramG-reddy/sms-store/metrics"
 	"github.com/ramG-reddy/sms-store/models"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/ramG-reddy/sms-store/phonenumber"
+	"github.com/ramG-reddy/sms-store/pseudonym"
+	"github.com/ramG-reddy/sms-store/search"
+	"github.com/ramG-reddy/sms-store/smstext"
+	"github.com/ramG-reddy/sms-store/store"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrMessageNotFound is returned when no stored record matches the given message ID
+var ErrMessageNotFound = store.ErrNotFound
+
+// ErrSearchDisabled is returned by SearchMessages when no search index is configured
+var ErrSearchDisabled = errors.New("search is not enabled")
+
+// ErrMediaNotFound is returned when a message has no attachment at the given position
+var ErrMediaNotFound = errors.New("media not found")
+
 // SMSService handles business logic for SMS record operations
 type SMSService struct {
-	collection string
+	store        store.Store
+	broker       *events.Broker
+	storedEvents bool
+	watched      bool // changes reach the broker from the change stream rather than after each write
+	cache        *cache.UserMessages
+	stats        *cache.UserStats
+	responses    *cache.Responses
+	search       *search.Index
+	media        media.Store
+	flagger      *flagging.Flagger // nil flags nothing
+	encryption   *encryptedStore
+	pseudonyms   *pseudonym.Hasher       // nil stores phone numbers as they are
+	numbers      *phonenumber.Normalizer // nil stores phone numbers as received
+	quotas       *QuotaService           // nil leaves users' storage unlimited
+	senders      *SenderService          // nil resolves no senders
+	blocklist    *BlocklistService       // nil blocks nothing
 }
 
-// NewSMSService creates a new SMS service instance
-func NewSMSService() *SMSService {
+// NewSMSService creates a new SMS service instance storing records in st
+// Newly stored messages are published to broker for real-time subscribers
+func NewSMSService(st store.Store, broker *events.Broker) *SMSService {
 	return &SMSService{
-		collection: db.SMSRecordsCollection,
+		store:  st,
+		broker: broker,
+	}
+}
+
+// EnableStoredEvents marks every record stored from now on as pending in the
+// outbox, for a relay to publish its stored event and clear the mark
+// It must be called before any message is saved
+func (s *SMSService) EnableStoredEvents() {
+	s.storedEvents = true
+}
+
+// EnableCache serves GetMessagesByUserID from c, invalidating a user's entry
+// whenever one of their messages is stored, changed or erased.
+// It must be called before the service is used
+func (s *SMSService) EnableCache(c *cache.UserMessages) {
+	s.cache = c
+}
+
+// EnableStatsCache serves GetUserStats from c.
+// It must be called before the service is used
+func (s *SMSService) EnableStatsCache(c *cache.UserStats) {
+	s.stats = c
+}
+
+// EnableResponseCache drops the responses about a user cached in c whenever one
+// of their messages is stored, changed or erased.
+// It must be called before the service is used
+func (s *SMSService) EnableResponseCache(c *cache.Responses) {
+	s.responses = c
+}
+
+// invalidate drops the cached messages and responses of the tenant's users, if caching is enabled
+func (s *SMSService) invalidate(ctx context.Context, tenantID string, userIDs ...string) {
+	if s.cache != nil {
+		s.cache.Invalidate(ctx, tenantID, userIDs...)
+	}
+	if s.responses != nil {
+		s.responses.Invalidate(tenantID, userIDs...)
+	}
+}
+
+// EnableEncryption encrypts the message body of every record stored from now on
+// with keys, and its phone numbers and user IDs too if phoneNumbers is set,
+// decrypting them again whenever records are read. It must be called before the
+// service is used
+func (s *SMSService) EnableEncryption(keys *fieldcrypt.Keyring, phoneNumbers bool) {
+	s.encryption = &encryptedStore{Store: s.store, keys: keys, phoneNumbers: phoneNumbers}
+	s.store = s.encryption
+}
+
+// EnablePseudonyms replaces the user ID and phone number of every record stored
+// from now on with their pseudonyms, and looks records up by the pseudonyms of
+// the given numbers. It must be called before the service is used
+func (s *SMSService) EnablePseudonyms(h *pseudonym.Hasher) {
+	s.pseudonyms = h
+}
+
+// pseudonymize replaces the phone numbers of a record about to be stored with
+// their pseudonyms, if enabled
+func (s *SMSService) pseudonymize(record *models.SMSRecord) {
+	record.UserID = s.pseudonyms.Hash(record.UserID)
+	record.PhoneNumber = s.pseudonyms.Hash(record.PhoneNumber)
+	record.RawUserID = s.pseudonyms.Hash(record.RawUserID)
+	record.RawPhoneNumber = s.pseudonyms.Hash(record.RawPhoneNumber)
+}
+
+// EnablePhoneNormalization stores the user ID and phone number of every record
+// stored from now on in E.164 form as normalized by n, keeping the forms
+// received when they differ, and looks records up by the normalized forms of
+// the given numbers. It must be called before the service is used
+func (s *SMSService) EnablePhoneNormalization(n *phonenumber.Normalizer) {
+	s.numbers = n
+}
+
+// normalize replaces the phone numbers of a record about to be stored with
+// their E.164 forms, if enabled. Numbers that can't be normalized are stored as
+// received. It must run before blocklists are checked and numbers pseudonymized
+func (s *SMSService) normalize(record *models.SMSRecord) {
+	if s.numbers == nil {
+		return
+	}
+	if normalized, err := s.numbers.Normalize(record.UserID); err == nil && normalized != record.UserID {
+		record.RawUserID, record.UserID = record.UserID, normalized
+	}
+	if normalized, err := s.numbers.Normalize(record.PhoneNumber); err == nil && normalized != record.PhoneNumber {
+		record.RawPhoneNumber, record.PhoneNumber = record.PhoneNumber, normalized
+	}
+}
+
+// locate records the dial code of the phone number of a record about to be
+// stored, or of its user ID when the phone number is not in E.164 form, and
+// the country of the number unless the producer reported one. It must run after
+// numbers are normalized and before they are pseudonymized
+func locate(record *models.SMSRecord) {
+	number := record.PhoneNumber
+	if !models.IsE164PhoneNumber(number) {
+		number = record.UserID
+	}
+	if !models.IsE164PhoneNumber(number) {
+		return
+	}
+	country, dialCode := phonenumber.Locate(number)
+	record.DialCode = dialCode
+	if record.CountryCode == "" {
+		record.CountryCode = country
+	}
+}
+
+// lookupNumber returns the form a phone number given in a lookup is stored
+// under: normalized, then replaced with its pseudonym, as enabled
+func (s *SMSService) lookupNumber(number string) string {
+	if normalized, err := s.numbers.Normalize(number); err == nil {
+		number = normalized
+	}
+	return s.pseudonyms.Hash(number)
+}
+
+// erasureUserIDs returns the forms the messages of a user ID given in an erasure
+// may be stored under: the lookup form first, then the ID as given, which
+// messages stored before normalization was enabled still have, and both again
+// as stored before pseudonyms were enabled. Messages stored since normalization
+// keep the form given as raw_user_id, but under the lookup form as user_id
+func (s *SMSService) erasureUserIDs(userID string) []string {
+	normalized := userID
+	if n, err := s.numbers.Normalize(userID); err == nil {
+		normalized = n
+	}
+	userIDs := []string{s.lookupNumber(userID)}
+	for _, form := range []string{s.pseudonyms.Hash(userID), normalized, userID} {
+		if !slices.Contains(userIDs, form) {
+			userIDs = append(userIDs, form)
+		}
+	}
+	return userIDs
+}
+
+// LookupKey returns the form records of a user ID or phone number given in a
+// lookup are stored under, to key caches of lookups by the number they match
+func (s *SMSService) LookupKey(number string) string {
+	return s.lookupNumber(number)
+}
+
+// IsValidPhoneNumber reports whether number can identify a user or phone number
+// in a lookup: a number of 10-15 digits, with or without a leading +, or any
+// number that normalizes to E.164 when normalization is enabled
+func (s *SMSService) IsValidPhoneNumber(number string) bool {
+	if models.IsValidPhoneNumber(number) {
+		return true
+	}
+	if s.numbers == nil {
+		return false
+	}
+	_, err := s.numbers.Normalize(number)
+	return err == nil
+}
+
+// describeText records the encoding, script and language of the message of a
+// record about to be stored, and the segments it is billed as unless the
+// producer reported them
+func describeText(record *models.SMSRecord) {
+	if record.Message == "" {
+		return
+	}
+	info := smstext.Detect(record.Message)
+	record.Encoding, record.Script, record.Language = info.Encoding, info.Script, info.Language
+	if record.SegmentCount == 0 {
+		record.SegmentCount = smstext.Segments(record.Message)
+	}
+}
+
+// lookupQuery returns query with its phone numbers replaced by the forms they
+// are stored under, if normalization or pseudonyms are enabled
+func (s *SMSService) lookupQuery(query *models.MessageQuery) *models.MessageQuery {
+	if s.numbers == nil && s.pseudonyms == nil {
+		return query
+	}
+	q := *query
+	q.UserID = s.lookupNumber(query.UserID)
+	q.PhoneNumber = s.lookupNumber(query.PhoneNumber)
+	return &q
+}
+
+// EnableFlagging flags every message stored from now on with the rules of f
+// it matches. It must be called before the service is used
+func (s *SMSService) EnableFlagging(f *flagging.Flagger) {
+	s.flagger = f
+}
+
+// flag records the flag rules the message of a record about to be stored
+// matches, and models.FlagBlocked if its tenant's blocklist blocks it
+func (s *SMSService) flag(record *models.SMSRecord, blocked bool) {
+	if s.flagger != nil {
+		record.Flags = s.flagger.Flags(record.Message)
+	}
+	if blocked && !slices.Contains(record.Flags, models.FlagBlocked) {
+		record.Flags = append(record.Flags, models.FlagBlocked)
+	}
+}
+
+// EnableBlocklist checks every message about to be stored against the
+// blocklist of its tenant in b, skipping or flagging those it blocks as its
+// action says. It must be called before any message is saved
+func (s *SMSService) EnableBlocklist(b *BlocklistService) {
+	s.blocklist = b
+}
+
+// screen returns the blocklist entries blocking records, by index into records,
+// counting each blocked record. It must run before their phone numbers are
+// replaced with their pseudonyms
+func (s *SMSService) screen(ctx context.Context, records []*models.SMSRecord) (map[int]*models.BlockEntry, error) {
+	if s.blocklist == nil {
+		return nil, nil
+	}
+	blocked, err := s.blocklist.Check(ctx, records)
+	if err != nil {
+		return nil, err
+	}
+	action := s.blocklist.Action()
+	for i, entry := range blocked {
+		metrics.MessageBlocked(entry.Kind, action)
+		slog.InfoContext(ctx, "Blocked SMS record", "message_id", records[i].MessageID, "tenant_id", records[i].TenantID,
+			"entry_id", entry.ID.Hex(), "kind", entry.Kind, "action", action)
+	}
+	return blocked, nil
+}
+
+// skipsBlocked reports whether blocked messages are left unstored rather than flagged
+func (s *SMSService) skipsBlocked() bool {
+	return s.blocklist != nil && s.blocklist.Action() == models.BlocklistActionSkip
+}
+
+// countFlags counts the flags of a newly stored record
+func countFlags(record *models.SMSRecord) {
+	for _, flag := range record.Flags {
+		metrics.MessageFlagged(flag)
+	}
+}
+
+// EnableSearch writes every stored, updated and erased message through to idx,
+// and serves SearchMessages from it. Failed writes are logged and counted but
+// do not fail the write to the store, which remains the source of truth.
+// It must be called before the service is used
+func (s *SMSService) EnableSearch(idx *search.Index) {
+	s.search = idx
+}
+
+// EnableMedia keeps the attachments of MMS messages in st, and erases them with
+// their user's messages. Without it records with attachments cannot be saved.
+// It must be called before the service is used
+func (s *SMSService) EnableMedia(st media.Store) {
+	s.media = st
+}
+
+// EnableQuotas counts every message stored from now on towards its user's
+// storage quota in q, rejecting those over it when that is its action. Usage is
+// kept under user IDs as stored, so it must be called after EnableEncryption,
+// and before any message is saved
+func (s *SMSService) EnableQuotas(q *QuotaService) {
+	q.users = s.encryption
+	s.quotas = q
+}
+
+// EnableSenders marks every message stored from now on whose sender ID its
+// tenant registered in senders with the sender's type.
+// It must be called before any message is saved
+func (s *SMSService) EnableSenders(senders *SenderService) {
+	s.senders = senders
+}
+
+// resolveSenders marks records about to be stored with the type of their
+// registered senders. A failed lookup leaves them unmarked rather than failing the write
+func (s *SMSService) resolveSenders(ctx context.Context, records []*models.SMSRecord) {
+	if s.senders == nil {
+		return
+	}
+	if err := s.senders.Resolve(ctx, records); err != nil {
+		slog.WarnContext(ctx, "Failed to resolve registered senders", "count", len(records), "error", err)
+	}
+}
+
+// recordUsage counts stored records towards their users' storage quotas
+func (s *SMSService) recordUsage(ctx context.Context, records []*models.SMSRecord) {
+	if s.quotas == nil || len(records) == 0 {
+		return
+	}
+	if err := s.quotas.Record(ctx, records); err != nil {
+		// Corrected by the next reconciliation
+		slog.WarnContext(ctx, "Failed to record user storage usage", "count", len(records), "error", err)
+	}
+}
+
+// storeMedia writes the attachments of records that still carry their data to
+// the media store, then drops the data from the records. Keys are derived from
+// message IDs, so writes are idempotent and a failed batch can be retried
+func (s *SMSService) storeMedia(ctx context.Context, records []*models.SMSRecord) error {
+	for _, record := range records {
+		for n := range record.Media {
+			attachment := &record.Media[n]
+			if attachment.Data == nil {
+				continue
+			}
+			if s.media == nil {
+				return fmt.Errorf("message %s has media attachments but media storage is not enabled", record.MessageID)
+			}
+			if record.MessageID == "" && record.ID.IsZero() {
+				// Keyed by the record ID instead
+				record.ID = primitive.NewObjectID()
+			}
+			if err := s.media.Put(ctx, media.Key(record, n), attachment.ContentType, attachment.Data); err != nil {
+				return err
+			}
+			attachment.Data = nil
+		}
+	}
+	return nil
+}
+
+// indexRecords writes records to the search index, if search is enabled
+func (s *SMSService) indexRecords(ctx context.Context, records []*models.SMSRecord) {
+	if s.search == nil || len(records) == 0 {
+		return
 	}
+	if err := s.search.IndexRecords(ctx, records); err != nil {
+		metrics.SearchIndexFailed()
+		slog.WarnContext(ctx, "Failed to index messages for search", "count", len(records), "error", err)
+	}
+}
+
+// WatchChanges returns a watcher of m's change stream publishing stored records
+// and status changes to the broker, replacing publishing after each write, so changes
+// made by every instance reach subscribers and none are lost across restarts.
+// Only the mongo storage backend has a change stream, so m must be the database
+// the service stores messages in. It must be called before any message is saved
+func (s *SMSService) WatchChanges(m *db.Mongo, name string) *db.RecordWatcher {
+	s.watched = true
+	return s.WatchChangesTo(m, name, s.broker)
+}
+
+// WatchChangesTo returns a change stream watcher publishing stored records and
+// status changes to broker instead of the service's own, leaving publishing
+// after each write as it is
+func (s *SMSService) WatchChangesTo(m *db.Mongo, name string, broker *events.Broker) *db.RecordWatcher {
+	return m.NewRecordWatcher(name, func(ctx context.Context, change db.ChangeEvent) {
+		s.publishChange(ctx, broker, change)
+	})
 }
 
-// SaveMessage persists an SMS record to MongoDB
+// publishChange publishes a change seen on the change stream to broker
+func (s *SMSService) publishChange(ctx context.Context, broker *events.Broker, change db.ChangeEvent) {
+	// The change stream reads records as stored
+	if s.encryption != nil {
+		if err := s.encryption.open(change.Record); err != nil {
+			slog.ErrorContext(ctx, "Failed to decrypt changed message", "id", change.Record.ID, "error", err)
+			return
+		}
+	}
+	switch change.OperationType {
+	case db.OperationInsert:
+		broker.Publish(events.MessageStored, change.Record)
+	case db.OperationUpdate:
+		broker.Publish(events.MessageStatusChanged, change.Record)
+	}
+}
+
+// publish hands a written record to the broker unless the change stream does
+func (s *SMSService) publish(eventType string, record *models.SMSRecord) {
+	if !s.watched {
+		s.broker.Publish(eventType, record)
+	}
+}
+
+// SaveMessage persists an SMS record
+// The record must already carry its tenant ID
 func (s *SMSService) SaveMessage(ctx context.Context, record *models.SMSRecord) error {
-	log.Printf("Saving SMS record for user: %s", record.UserID)
+	if record.TenantID == "" {
+		return tenant.ErrMissing
+	}
+	s.normalize(record)
+	locate(record)
+	blocked, err := s.screen(ctx, []*models.SMSRecord{record})
+	if err != nil {
+		return err
+	}
+	if len(blocked) > 0 && s.skipsBlocked() {
+		return nil
+	}
+	s.pseudonymize(record)
+	describeText(record)
+	s.flag(record, len(blocked) > 0)
+	s.resolveSenders(ctx, []*models.SMSRecord{record})
 
-	collection := db.GetCollection()
+	slog.DebugContext(ctx, "Saving SMS record", "tenant_id", record.TenantID, "user_id", record.UserID)
 
-	// Set timeout for insert operation
-	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	if s.quotas != nil {
+		rejected, err := s.quotas.Admit(ctx, []*models.SMSRecord{record})
+		if err != nil {
+			return err
+		}
+		if err := rejected[0]; err != nil {
+			return err
+		}
+	}
+
+	// Before the record, so a stored record's attachments can always be downloaded
+	if err := s.storeMedia(ctx, []*models.SMSRecord{record}); err != nil {
+		return err
+	}
+	record.StoredEventPending = s.storedEvents
 
-	// Insert the record
-	result, err := collection.InsertOne(insertCtx, record)
+	err = s.store.InsertMessage(ctx, record)
+	if errors.Is(err, store.ErrDuplicate) {
+		// Already stored by an earlier delivery of the same message
+		metrics.DuplicateMessageSkipped()
+		slog.InfoContext(ctx, "Skipped duplicate SMS record", "message_id", record.MessageID, "tenant_id", record.TenantID, "user_id", record.UserID)
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to insert SMS record: %w", err)
+		return err
 	}
 
-	log.Printf("Successfully saved SMS record with ID: %v for user: %s", result.InsertedID, record.UserID)
+	slog.InfoContext(ctx, "Saved SMS record", "id", record.ID, "message_id", record.MessageID, "tenant_id", record.TenantID, "user_id", record.UserID)
+	countFlags(record)
+
+	s.invalidate(ctx, record.TenantID, record.UserID)
+	s.recordUsage(ctx, []*models.SMSRecord{record})
+	s.indexRecords(ctx, []*models.SMSRecord{record})
+	s.publish(events.MessageStored, record)
 	return nil
 }
 
+// BulkSaveError reports the records of a SaveMessages call that were not stored
+// Every other record was stored
+type BulkSaveError struct {
+	Failed map[int]error // by index into the records passed to SaveMessages
+}
+
+func (e *BulkSaveError) Error() string {
+	for _, err := range e.Failed {
+		return fmt.Sprintf("failed to insert %d SMS records, e.g.: %v", len(e.Failed), err)
+	}
+	return "failed to insert SMS records"
+}
+
+// SaveMessages stores records with as few writes as the backend allows and publishes
+// each newly stored record to the broker. Records whose message_id (or ID, when
+// an earlier attempt did succeed) is already stored are skipped as duplicates.
+// If only some records fail the error is a *BulkSaveError; any other error
+// means the outcome of every record is unknown
+func (s *SMSService) SaveMessages(ctx context.Context, records []*models.SMSRecord) error {
+	_, err := s.SaveMessagesCounting(ctx, records)
+	return err
+}
+
+// SaveMessagesCounting is SaveMessages, also returning how many records were
+// skipped as duplicates. Records skipped as blocked are neither stored nor failed
+func (s *SMSService) SaveMessagesCounting(ctx context.Context, records []*models.SMSRecord) (int, error) {
+	for _, record := range records {
+		if record.TenantID == "" {
+			return 0, tenant.ErrMissing
+		}
+	}
+	for _, record := range records {
+		s.normalize(record)
+		locate(record)
+	}
+	blocked, err := s.screen(ctx, records)
+	if err != nil {
+		return 0, err
+	}
+	if len(blocked) > 0 && s.skipsBlocked() {
+		return s.saveUnblocked(ctx, records, blocked)
+	}
+	return s.saveMessages(ctx, records, blocked)
+}
+
+// saveUnblocked stores the records not blocked, reporting the failed ones by
+// index into records
+func (s *SMSService) saveUnblocked(ctx context.Context, records []*models.SMSRecord, blocked map[int]*models.BlockEntry) (int, error) {
+	kept := make([]*models.SMSRecord, 0, len(records)-len(blocked))
+	positions := make([]int, 0, len(records)-len(blocked))
+	for i, record := range records {
+		if _, ok := blocked[i]; !ok {
+			kept = append(kept, record)
+			positions = append(positions, i)
+		}
+	}
+	if len(kept) == 0 {
+		return 0, nil
+	}
+
+	duplicates, err := s.saveMessages(ctx, kept, nil)
+	var bulkErr *BulkSaveError
+	if errors.As(err, &bulkErr) {
+		failed := make(map[int]error, len(bulkErr.Failed))
+		for i, recordErr := range bulkErr.Failed {
+			failed[positions[i]] = recordErr
+		}
+		return duplicates, &BulkSaveError{Failed: failed}
+	}
+	return duplicates, err
+}
+
+// saveMessages stores records, flagging those blocked, by index into records
+func (s *SMSService) saveMessages(ctx context.Context, records []*models.SMSRecord, blocked map[int]*models.BlockEntry) (int, error) {
+	for i, record := range records {
+		_, isBlocked := blocked[i]
+		s.pseudonymize(record)
+		describeText(record)
+		s.flag(record, isBlocked)
+		// Written with the record itself, so its stored event cannot be lost
+		record.StoredEventPending = s.storedEvents
+	}
+	s.resolveSenders(ctx, records)
+
+	slog.DebugContext(ctx, "Saving SMS records", "count", len(records))
+
+	var rejected map[int]error
+	if s.quotas != nil {
+		var err error
+		if rejected, err = s.quotas.Admit(ctx, records); err != nil {
+			return 0, err
+		}
+	}
+	result, err := s.insertAdmitted(ctx, records, rejected)
+	if err != nil {
+		return 0, err
+	}
+
+	stored := make(map[string]map[string]bool) // user IDs by tenant
+	var indexed []*models.SMSRecord
+	for i, record := range records {
+		if result.Duplicates[i] {
+			metrics.DuplicateMessageSkipped()
+			slog.InfoContext(ctx, "Skipped duplicate SMS record", "message_id", record.MessageID, "tenant_id", record.TenantID, "user_id", record.UserID)
+			continue
+		}
+		if _, ok := result.Failed[i]; ok {
+			continue
+		}
+		slog.DebugContext(ctx, "Saved SMS record", "id", record.ID, "message_id", record.MessageID, "tenant_id", record.TenantID, "user_id", record.UserID)
+		if stored[record.TenantID] == nil {
+			stored[record.TenantID] = make(map[string]bool)
+		}
+		stored[record.TenantID][record.UserID] = true
+		indexed = append(indexed, record)
+		countFlags(record)
+		s.publish(events.MessageStored, record)
+	}
+	s.recordUsage(ctx, indexed)
+	s.indexRecords(ctx, indexed)
+	for tenantID, users := range stored {
+		userIDs := make([]string, 0, len(users))
+		for userID := range users {
+			userIDs = append(userIDs, userID)
+		}
+		s.invalidate(ctx, tenantID, userIDs...)
+	}
+
+	if len(result.Failed) > 0 {
+		return len(result.Duplicates), &BulkSaveError{Failed: result.Failed}
+	}
+	return len(result.Duplicates), nil
+}
+
+// insertAdmitted stores the records not rejected, with their attachments, and
+// returns the result by index into records, counting the rejected as failed
+func (s *SMSService) insertAdmitted(ctx context.Context, records []*models.SMSRecord, rejected map[int]error) (store.InsertResult, error) {
+	if len(rejected) == 0 {
+		if err := s.storeMedia(ctx, records); err != nil {
+			return store.InsertResult{}, err
+		}
+		return s.store.InsertMessages(ctx, records)
+	}
+
+	admitted := make([]*models.SMSRecord, 0, len(records)-len(rejected))
+	positions := make([]int, 0, len(records)-len(rejected))
+	for i, record := range records {
+		if _, ok := rejected[i]; !ok {
+			admitted = append(admitted, record)
+			positions = append(positions, i)
+		}
+	}
+
+	result := store.InsertResult{Duplicates: make(map[int]bool), Failed: make(map[int]error)}
+	for i, err := range rejected {
+		result.Failed[i] = err
+	}
+	if len(admitted) == 0 {
+		return result, nil
+	}
+	if err := s.storeMedia(ctx, admitted); err != nil {
+		return store.InsertResult{}, err
+	}
+	inserted, err := s.store.InsertMessages(ctx, admitted)
+	if err != nil {
+		return store.InsertResult{}, err
+	}
+	for i := range inserted.Duplicates {
+		result.Duplicates[positions[i]] = true
+	}
+	for i, err := range inserted.Failed {
+		result.Failed[positions[i]] = err
+	}
+	return result, nil
+}
+
+// SubscribeUserMessages returns a channel of events for the user's messages from now on
+// within the context's tenant. The returned function releases the subscription
+func (s *SMSService) SubscribeUserMessages(ctx context.Context, userID string) (<-chan events.Event, func(), error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, nil, tenant.ErrMissing
+	}
+	ch, unsubscribe := s.broker.Subscribe(tenantID, s.lookupNumber(userID))
+	return ch, unsubscribe, nil
+}
+
+// tenantOf returns the tenant carried by ctx
+// Every user-facing query is scoped to it so no read can cross tenants
+func tenantOf(ctx context.Context) (string, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", tenant.ErrMissing
+	}
+	return tenantID, nil
+}
+
 // GetMessagesByUserID retrieves all SMS messages for a specific user
 // Results are sorted by created_at in descending order (newest first)
-func (s *SMSService) GetMessagesByUserID(ctx context.Context, userID string) ([]*models.SMSRecord, error) {
-	log.Printf("Retrieving messages for user: %s", userID)
+// Only messages with the metadata asked for by filter are returned
+// When fields names JSON fields, the store may read only those; such partial
+// records bypass the cache
+func (s *SMSService) GetMessagesByUserID(ctx context.Context, userID string, filter models.MessageFilter, fields []string, sort models.MessageSort) ([]*models.SMSRecord, error) {
+	userID = s.lookupNumber(userID)
+	slog.DebugContext(ctx, "Retrieving messages", "user_id", userID)
+
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	collection := db.GetCollection()
+	// The cache holds the whole newest-first list; filtered lists and other orders are read from the store
+	useCache := s.cache != nil && sort.IsDefault() && filter.IsZero()
+	if useCache {
+		if records, ok := s.cache.Get(ctx, tenantID, userID); ok {
+			slog.DebugContext(ctx, "Retrieved messages from cache", "user_id", userID, "count", len(records))
+			return records, nil
+		}
+	}
 
-	// Set timeout for query operation
-	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+	records, err := s.store.FindMessages(ctx, tenantID, &models.MessageQuery{UserID: userID, Fields: fields, Sort: sort, MessageFilter: filter})
+	if err != nil {
+		return nil, err
+	}
 
-	// Build query filter
-	filter := bson.M{"user_id": userID}
+	// Partial records must not be served to callers wanting whole ones
+	if useCache && len(fields) == 0 {
+		s.cache.Set(ctx, tenantID, userID, records)
+	}
 
-	// Set options: sort by created_at descending
-	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	slog.DebugContext(ctx, "Retrieved messages", "user_id", userID, "count", len(records))
+	return records, nil
+}
 
-	// Execute query
-	cursor, err := collection.Find(queryCtx, filter, opts)
+// GetConversations retrieves a page of the user's conversations, grouped by
+// counterpart phone number, most recently active first
+func (s *SMSService) GetConversations(ctx context.Context, userID string, skip, limit int64) ([]*models.Conversation, error) {
+	userID = s.lookupNumber(userID)
+	slog.DebugContext(ctx, "Retrieving conversations", "user_id", userID, "skip", skip, "limit", limit)
+
+	tenantID, err := tenantOf(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query messages: %w", err)
+		return nil, err
 	}
-	defer cursor.Close(queryCtx)
 
-	// Decode results
-	var records []*models.SMSRecord
-	if err := cursor.All(queryCtx, &records); err != nil {
-		return nil, fmt.Errorf("failed to decode messages: %w", err)
+	conversations, err := s.store.Conversations(ctx, tenantID, userID, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "Retrieved conversations", "user_id", userID, "count", len(conversations))
+	return conversations, nil
+}
+
+// GetMessagesByPhoneNumber retrieves a page of the tenant's messages to or from
+// a phone number, across users, newest first
+func (s *SMSService) GetMessagesByPhoneNumber(ctx context.Context, phoneNumber string, skip, limit int64) ([]*models.SMSRecord, error) {
+	phoneNumber = s.lookupNumber(phoneNumber)
+	slog.DebugContext(ctx, "Retrieving messages by phone number", "phone_number", phoneNumber, "skip", skip, "limit", limit)
+
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.store.FindMessagesByPhoneNumber(ctx, tenantID, phoneNumber, skip, limit)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("Retrieved %d messages for user: %s", len(records), userID)
+	slog.DebugContext(ctx, "Retrieved messages by phone number", "phone_number", phoneNumber, "count", len(records))
 	return records, nil
 }
 
 // GetRecentMessages retrieves the most recent N messages for a user
 func (s *SMSService) GetRecentMessages(ctx context.Context, userID string, limit int64) ([]*models.SMSRecord, error) {
-	log.Printf("Retrieving recent %d messages for user: %s", limit, userID)
+	userID = s.lookupNumber(userID)
+	slog.DebugContext(ctx, "Retrieving recent messages", "user_id", userID, "limit", limit)
 
-	collection := db.GetCollection()
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.store.FindMessages(ctx, tenantID, &models.MessageQuery{UserID: userID, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
 
-	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	slog.DebugContext(ctx, "Retrieved recent messages", "user_id", userID, "count", len(records))
+	return records, nil
+}
+
+// FindMessages retrieves a user's messages matching the query, in its sort order
+func (s *SMSService) FindMessages(ctx context.Context, query *models.MessageQuery) ([]*models.SMSRecord, error) {
+	query = s.lookupQuery(query)
+	slog.DebugContext(ctx, "Querying messages", "user_id", query.UserID, "skip", query.Skip, "limit", query.Limit)
+
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.FindMessages(ctx, tenantID, query)
+}
+
+// bulkQueryConcurrency bounds the users FindMessagesForUsers queries at once
+const bulkQueryConcurrency = 16
+
+// FindMessagesForUsers runs query for each of userIDs, a few users at a time,
+// and returns the messages of each by the user ID as given. The query's UserID
+// is ignored. It fails as a whole if any user's query fails
+func (s *SMSService) FindMessagesForUsers(ctx context.Context, userIDs []string, query models.MessageQuery) (map[string][]*models.SMSRecord, error) {
+	if _, err := tenantOf(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	filter := bson.M{"user_id": userID}
-	opts := options.Find().
-		SetSort(bson.D{{Key: "created_at", Value: -1}}).
-		SetLimit(limit)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		results  = make(map[string][]*models.SMSRecord, len(userIDs))
+		firstErr error
+	)
+	slots := make(chan struct{}, bulkQueryConcurrency)
+	for _, userID := range userIDs {
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			// A query failed, or the caller gave up; the rest are not started
+			<-slots
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			q := query
+			q.UserID = userID
+			records, err := s.FindMessages(ctx, &q)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to query messages of user %s: %w", userID, err)
+					cancel()
+				}
+				return
+			}
+			results[userID] = records
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		// The caller gave up before every user was queried
+		return nil, err
+	}
+	return results, nil
+}
+
+// CountMatchingMessages returns the number of a user's messages matching the query,
+// ignoring its pagination fields
+func (s *SMSService) CountMatchingMessages(ctx context.Context, query *models.MessageQuery) (int64, error) {
+	query = s.lookupQuery(query)
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return s.store.CountMessages(ctx, tenantID, query)
+}
 
-	cursor, err := collection.Find(queryCtx, filter, opts)
+// FindDuplicates returns up to limit groups of the tenant's messages created from
+// since until until that look like copies of one message, largest first. With
+// encryption enabled, copies stored as separate ciphertexts aren't matched by content
+func (s *SMSService) FindDuplicates(ctx context.Context, since, until time.Time, limit int64) ([]*models.DuplicateGroup, error) {
+	tenantID, err := tenantOf(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query recent messages: %w", err)
+		return nil, err
 	}
-	defer cursor.Close(queryCtx)
+	return s.store.FindDuplicates(ctx, tenantID, since, until, limit)
+}
 
-	var records []*models.SMSRecord
-	if err := cursor.All(queryCtx, &records); err != nil {
-		return nil, fmt.Errorf("failed to decode recent messages: %w", err)
+// GetDeliveryStats counts the delivery outcomes of the tenant's outbound
+// messages matching query, across all users, by sender ID, carrier and time bucket
+func (s *SMSService) GetDeliveryStats(ctx context.Context, query *models.DeliveryQuery) (*models.DeliveryStats, error) {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return s.store.DeliveryStats(ctx, tenantID, query)
+}
 
-	log.Printf("Retrieved %d recent messages for user: %s", len(records), userID)
-	return records, nil
+// FindMessagesOlderThan returns up to limit messages created before cutoff, oldest first
+// This is a maintenance operation and spans the tenants selected by tenants
+func (s *SMSService) FindMessagesOlderThan(ctx context.Context, cutoff time.Time, tenants models.TenantFilter, limit int64) ([]*models.SMSRecord, error) {
+	return s.store.FindMessagesOlderThan(ctx, cutoff, tenants, limit)
+}
+
+// OldestUserMessages returns up to limit of the user's messages, oldest first,
+// as stored, so archives stay encrypted. The user ID is taken as stored too.
+// This is a maintenance operation and may span tenants
+func (s *SMSService) OldestUserMessages(ctx context.Context, tenantID, userID string, limit int64) ([]*models.SMSRecord, error) {
+	st := s.store
+	if s.encryption != nil {
+		st = s.encryption.Store
+	}
+	return st.FindMessages(ctx, tenantID, &models.MessageQuery{
+		UserID: userID,
+		Limit:  limit,
+		Sort:   models.MessageSort{Field: models.SortCreatedAt, Ascending: true},
+	})
+}
+
+// FindMessagesInIDRange returns up to limit messages whose IDs are after after and
+// before before, in ID order. This is a maintenance operation and spans all tenants
+func (s *SMSService) FindMessagesInIDRange(ctx context.Context, after, before primitive.ObjectID, limit int64) ([]*models.SMSRecord, error) {
+	return s.store.FindMessagesInIDRange(ctx, after, before, limit)
+}
+
+// DeleteMessagesByIDs removes the messages with the given document IDs across all tenants
+func (s *SMSService) DeleteMessagesByIDs(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	deleted, err := s.store.DeleteMessages(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	if s.search != nil {
+		if err := s.search.DeleteRecords(ctx, ids); err != nil {
+			slog.WarnContext(ctx, "Failed to delete messages from search index", "count", len(ids), "error", err)
+		}
+	}
+	return deleted, nil
+}
+
+// PendingStoredEvents returns up to limit records whose stored event has not
+// been published yet, oldest first
+// This is an internal operation and spans all tenants
+func (s *SMSService) PendingStoredEvents(ctx context.Context, limit int64) ([]*models.SMSRecord, error) {
+	return s.store.PendingStoredEvents(ctx, limit)
+}
+
+// ClearStoredEventsPending marks the stored events of the records with the given
+// document IDs as published, across all tenants
+func (s *SMSService) ClearStoredEventsPending(ctx context.Context, ids []primitive.ObjectID) error {
+	return s.store.ClearStoredEventsPending(ctx, ids)
+}
+
+// GetMessageByID retrieves a single SMS message by its document ID
+func (s *SMSService) GetMessageByID(ctx context.Context, id string) (*models.SMSRecord, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.GetMessage(ctx, tenantID, objectID)
+}
+
+// GetMessageMedia returns the message with the given document ID and a reader of
+// the data of its nth attachment, counted from zero
+func (s *SMSService) GetMessageMedia(ctx context.Context, id string, n int) (*models.SMSRecord, io.ReadCloser, error) {
+	record, err := s.GetMessageByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n < 0 || n >= len(record.Media) || s.media == nil {
+		return nil, nil, ErrMediaNotFound
+	}
+
+	data, err := s.media.Open(ctx, media.Key(record, n))
+	if errors.Is(err, media.ErrNotFound) {
+		return nil, nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return record, data, nil
+}
+
+// StreamMessagesByUserID iterates a user's messages matching filter in sort order, newest first by
+// default, invoking fn for each record as it is read from the store so large result sets are never buffered.
+// When fields names JSON fields, the store may read only those.
+// Iteration stops at the first error returned by fn.
+func (s *SMSService) StreamMessagesByUserID(ctx context.Context, userID string, filter models.MessageFilter, fields []string, sort models.MessageSort, limit int64, fn func(*models.SMSRecord) error) error {
+	userID = s.lookupNumber(userID)
+	slog.DebugContext(ctx, "Streaming messages", "user_id", userID)
+
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	err = s.store.StreamMessages(ctx, tenantID, &models.MessageQuery{UserID: userID, Limit: limit, Fields: fields, Sort: sort, MessageFilter: filter}, func(record *models.SMSRecord) error {
+		count++
+		return fn(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.DebugContext(ctx, "Streamed messages", "user_id", userID, "count", count)
+	return nil
 }
 
 // GetMessageCount returns the total number of messages for a user
 func (s *SMSService) GetMessageCount(ctx context.Context, userID string) (int64, error) {
-	collection := db.GetCollection()
+	userID = s.lookupNumber(userID)
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return s.store.CountMessages(ctx, tenantID, &models.MessageQuery{UserID: userID})
+}
 
-	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+// GetUserStats counts a user's messages created in the last days UTC days, today
+// included, by day, week, status and direction
+func (s *SMSService) GetUserStats(ctx context.Context, userID string, days int) (*models.MessageStats, error) {
+	userID = s.lookupNumber(userID)
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	filter := bson.M{"user_id": userID}
-	count, err := collection.CountDocuments(queryCtx, filter)
+	if s.stats != nil {
+		if stats, ok := s.stats.Get(tenantID, userID, days); ok {
+			return stats, nil
+		}
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	stats, err := s.store.MessageStats(ctx, tenantID, &models.MessageQuery{
+		UserID: userID,
+		Since:  today.AddDate(0, 0, 1-days),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.stats != nil {
+		s.stats.Set(tenantID, userID, days, stats)
+	}
+	return stats, nil
+}
+
+// DeleteMessagesByUserID hard-deletes all SMS messages for a user (GDPR right to erasure)
+// and records audit, describing who asked for it, atomically where the storage
+// backend supports it. Returns the number of documents removed.
+func (s *SMSService) DeleteMessagesByUserID(ctx context.Context, userID string, audit *models.AuditRecord) (int64, error) {
+	userIDs := s.erasureUserIDs(userID)
+	userID = userIDs[0]
+	slog.InfoContext(ctx, "Erasing all messages for user", "user_id", userID)
+
+	tenantID, err := tenantOf(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to count messages: %w", err)
+		return 0, err
 	}
 
-	return count, nil
+	audit.Action = models.AuditActionEraseUserMessages
+	audit.TenantID = tenantID
+	audit.UserID = userID
+	audit.CreatedAt = time.Now().UTC()
+	deleted, err := s.store.DeleteUserMessages(ctx, tenantID, userIDs, audit)
+	if err != nil {
+		return 0, err
+	}
+	s.invalidate(ctx, tenantID, userIDs...)
+	for _, userID := range userIDs {
+		if s.stats != nil {
+			s.stats.Invalidate(tenantID, userID)
+		}
+		if s.search != nil {
+			// Failing lets the erasure be retried until the index forgets the user too
+			if err := s.search.DeleteUser(ctx, tenantID, userID); err != nil {
+				return 0, fmt.Errorf("failed to erase messages from search index: %w", err)
+			}
+		}
+		if s.media != nil {
+			if err := s.media.DeletePrefix(ctx, media.UserPrefix(tenantID, userID)); err != nil {
+				return 0, fmt.Errorf("failed to erase media: %w", err)
+			}
+		}
+	}
+
+	slog.InfoContext(ctx, "Audit record written", "audit_action", audit.Action, "tenant_id", audit.TenantID, "actor", audit.Actor, "user_id", audit.UserID, "count", audit.ResultCount)
+	slog.InfoContext(ctx, "Erased messages for user", "user_id", userID, "count", deleted)
+	return deleted, nil
+}
+
+// UpdateMessageStatus sets the current status of a stored message and appends the
+// change to its status history. Status events carry no tenant, so this is not
+// tenant-scoped; if tenants share a message_id, one of their records is updated
+func (s *SMSService) UpdateMessageStatus(ctx context.Context, messageID string, change models.StatusChange) error {
+	slog.DebugContext(ctx, "Updating message status", "message_id", messageID, "status", change.Status)
+
+	record, err := s.store.UpdateStatus(ctx, messageID, change)
+	if err != nil {
+		return err
+	}
+	s.statusChanged(ctx, record)
+
+	slog.InfoContext(ctx, "Updated message status", "message_id", messageID, "status", change.Status)
+	return nil
+}
+
+// UpdateStatusByProviderMessageID applies a delivery receipt status to the message
+// of the context's tenant carrying the given vendor-assigned ID
+func (s *SMSService) UpdateStatusByProviderMessageID(ctx context.Context, providerMessageID string, change models.StatusChange) error {
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "Updating provider message status", "provider_message_id", providerMessageID, "status", change.Status)
+
+	record, err := s.store.UpdateStatusByProviderID(ctx, tenantID, providerMessageID, change)
+	if err != nil {
+		return err
+	}
+	s.statusChanged(ctx, record)
+
+	slog.InfoContext(ctx, "Updated provider message status", "provider_message_id", providerMessageID, "status", change.Status)
+	return nil
+}
+
+// statusChanged invalidates the cached messages of the updated record's user and publishes it
+func (s *SMSService) statusChanged(ctx context.Context, record *models.SMSRecord) {
+	s.invalidate(ctx, record.TenantID, record.UserID)
+	s.indexRecords(ctx, []*models.SMSRecord{record})
+	s.publish(events.MessageStatusChanged, record)
+}
+
+// SearchMessages runs a full-text search of a user's messages in the context's tenant
+func (s *SMSService) SearchMessages(ctx context.Context, query *search.Query) (*search.Result, error) {
+	if s.search == nil {
+		return nil, ErrSearchDisabled
+	}
+
+	tenantID, err := tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.numbers != nil || s.pseudonyms != nil {
+		q := *query
+		q.UserID = s.lookupNumber(query.UserID)
+		query = &q
+	}
+	return s.search.Search(ctx, tenantID, query)
 }
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/phonenumber"
+	"github.com/ramG-reddy/sms-store/store"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+const testTenant = "t1"
+
+// newTestService returns an SMS service over an empty memory store
+func newTestService() (*SMSService, *store.MemoryStore) {
+	st := store.NewMemoryStore()
+	return NewSMSService(st, events.NewBroker()), st
+}
+
+func testRecord(messageID, userID string, createdAt time.Time) *models.SMSRecord {
+	return &models.SMSRecord{
+		MessageID:   messageID,
+		TenantID:    testTenant,
+		UserID:      userID,
+		PhoneNumber: "+14155550100",
+		Message:     "hello " + messageID,
+		Status:      models.StatusSent,
+		CreatedAt:   createdAt,
+	}
+}
+
+func TestSaveMessage(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := tenant.WithID(context.Background(), testTenant)
+
+	record := testRecord("m1", "u1", time.Now().UTC())
+	if err := svc.SaveMessage(ctx, record); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	if record.ID.IsZero() {
+		t.Fatal("SaveMessage did not assign an ID")
+	}
+
+	got, err := svc.GetMessageByID(ctx, record.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetMessageByID: %v", err)
+	}
+	if got.MessageID != "m1" || got.UserID != "u1" || got.Message != "hello m1" {
+		t.Errorf("stored record = %+v", got)
+	}
+}
+
+func TestSaveMessageRequiresTenant(t *testing.T) {
+	svc, _ := newTestService()
+
+	record := testRecord("m1", "u1", time.Now().UTC())
+	record.TenantID = ""
+	if err := svc.SaveMessage(context.Background(), record); err != tenant.ErrMissing {
+		t.Fatalf("SaveMessage without tenant = %v, want %v", err, tenant.ErrMissing)
+	}
+}
+
+func TestSaveMessageSkipsDuplicates(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := tenant.WithID(context.Background(), testTenant)
+	now := time.Now().UTC()
+
+	if err := svc.SaveMessage(ctx, testRecord("m1", "u1", now)); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	// A redelivery of the same message is not an error, and is not stored twice
+	if err := svc.SaveMessage(ctx, testRecord("m1", "u1", now)); err != nil {
+		t.Fatalf("SaveMessage of a duplicate: %v", err)
+	}
+
+	duplicates, err := svc.SaveMessagesCounting(ctx, []*models.SMSRecord{
+		testRecord("m1", "u1", now),
+		testRecord("m2", "u1", now),
+	})
+	if err != nil {
+		t.Fatalf("SaveMessagesCounting: %v", err)
+	}
+	if duplicates != 1 {
+		t.Errorf("SaveMessagesCounting duplicates = %d, want 1", duplicates)
+	}
+
+	records, err := svc.GetMessagesByUserID(ctx, "u1", models.MessageFilter{}, nil, models.MessageSort{})
+	if err != nil {
+		t.Fatalf("GetMessagesByUserID: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("stored %d records, want 2", len(records))
+	}
+}
+
+func TestSaveMessageDeduplicatesPerTenant(t *testing.T) {
+	svc, _ := newTestService()
+	now := time.Now().UTC()
+
+	other := testRecord("m1", "u1", now)
+	other.TenantID = "t2"
+	if err := svc.SaveMessage(tenant.WithID(context.Background(), testTenant), testRecord("m1", "u1", now)); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	// Another tenant's message with the same message_id is not a redelivery
+	duplicates, err := svc.SaveMessagesCounting(tenant.WithID(context.Background(), "t2"), []*models.SMSRecord{other})
+	if err != nil {
+		t.Fatalf("SaveMessagesCounting: %v", err)
+	}
+	if duplicates != 0 {
+		t.Errorf("SaveMessagesCounting duplicates = %d, want 0", duplicates)
+	}
+
+	for _, tenantID := range []string{testTenant, "t2"} {
+		records, err := svc.GetMessagesByUserID(tenant.WithID(context.Background(), tenantID), "u1", models.MessageFilter{}, nil, models.MessageSort{})
+		if err != nil {
+			t.Fatalf("GetMessagesByUserID: %v", err)
+		}
+		if len(records) != 1 {
+			t.Errorf("tenant %s stored %d records, want 1", tenantID, len(records))
+		}
+	}
+}
+
+func TestGetMessagesByUserID(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := tenant.WithID(context.Background(), testTenant)
+	now := time.Now().UTC()
+
+	err := svc.SaveMessages(ctx, []*models.SMSRecord{
+		testRecord("m1", "u1", now.Add(-2*time.Hour)),
+		testRecord("m2", "u1", now),
+		testRecord("m3", "u1", now.Add(-time.Hour)),
+		testRecord("m4", "u2", now),
+	})
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	records, err := svc.GetMessagesByUserID(ctx, "u1", models.MessageFilter{}, nil, models.MessageSort{})
+	if err != nil {
+		t.Fatalf("GetMessagesByUserID: %v", err)
+	}
+	var got []string
+	for _, record := range records {
+		got = append(got, record.MessageID)
+	}
+	// Newest first, and only the user's own
+	want := []string{"m2", "m3", "m1"}
+	if len(got) != len(want) {
+		t.Fatalf("GetMessagesByUserID = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("GetMessagesByUserID = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestGetMessagesByUserIDIsTenantScoped(t *testing.T) {
+	svc, _ := newTestService()
+	now := time.Now().UTC()
+
+	if err := svc.SaveMessage(tenant.WithID(context.Background(), testTenant), testRecord("m1", "u1", now)); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	records, err := svc.GetMessagesByUserID(tenant.WithID(context.Background(), "t2"), "u1", models.MessageFilter{}, nil, models.MessageSort{})
+	if err != nil {
+		t.Fatalf("GetMessagesByUserID: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("another tenant read %d records", len(records))
+	}
+
+	if _, err := svc.GetMessagesByUserID(context.Background(), "u1", models.MessageFilter{}, nil, models.MessageSort{}); err != tenant.ErrMissing {
+		t.Errorf("GetMessagesByUserID without tenant = %v, want %v", err, tenant.ErrMissing)
+	}
+}
+
+func TestDeleteMessagesByUserID(t *testing.T) {
+	svc, st := newTestService()
+	ctx := tenant.WithID(context.Background(), testTenant)
+	now := time.Now().UTC()
+
+	err := svc.SaveMessages(ctx, []*models.SMSRecord{
+		testRecord("m1", "u1", now),
+		testRecord("m2", "u1", now),
+		testRecord("m3", "u2", now),
+	})
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	deleted, err := svc.DeleteMessagesByUserID(ctx, "u1", &models.AuditRecord{Actor: "admin"})
+	if err != nil {
+		t.Fatalf("DeleteMessagesByUserID: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteMessagesByUserID deleted %d, want 2", deleted)
+	}
+
+	count, err := svc.GetMessageCount(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetMessageCount: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d messages left after erasure", count)
+	}
+	if count, _ := svc.GetMessageCount(ctx, "u2"); count != 1 {
+		t.Errorf("erasure left %d of another user's messages, want 1", count)
+	}
+
+	audit, err := st.FindAuditRecords(ctx, testTenant, &models.AuditQuery{Action: models.AuditActionEraseUserMessages})
+	if err != nil {
+		t.Fatalf("FindAuditRecords: %v", err)
+	}
+	if len(audit) != 1 {
+		t.Fatalf("found %d audit records, want 1", len(audit))
+	}
+	if audit[0].UserID != "u1" || audit[0].Actor != "admin" || audit[0].ResultCount != 2 {
+		t.Errorf("audit record = %+v", audit[0])
+	}
+}
+
+func TestDeleteMessagesStoredBeforeNormalization(t *testing.T) {
+	svc, st := newTestService()
+	ctx := tenant.WithID(context.Background(), testTenant)
+	now := time.Now().UTC()
+
+	if err := svc.SaveMessage(ctx, testRecord("m1", "4155550100", now)); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	normalizer, err := phonenumber.NewNormalizer("US")
+	if err != nil {
+		t.Fatalf("NewNormalizer: %v", err)
+	}
+	svc.EnablePhoneNormalization(normalizer)
+	if err := svc.SaveMessage(ctx, testRecord("m2", "4155550100", now)); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	// The first is stored under the number as received, the second normalized
+	deleted, err := svc.DeleteMessagesByUserID(ctx, "4155550100", &models.AuditRecord{Actor: "admin"})
+	if err != nil {
+		t.Fatalf("DeleteMessagesByUserID: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteMessagesByUserID deleted %d, want 2", deleted)
+	}
+	for _, userID := range []string{"4155550100", "+14155550100"} {
+		records, err := st.FindMessages(ctx, testTenant, &models.MessageQuery{UserID: userID})
+		if err != nil {
+			t.Fatalf("FindMessages: %v", err)
+		}
+		if len(records) != 0 {
+			t.Errorf("%d messages of %s left after erasure", len(records), userID)
+		}
+	}
+}
+
+func TestUpdateStatusByProviderMessageIDIsTenantScoped(t *testing.T) {
+	svc, _ := newTestService()
+	ctx := tenant.WithID(context.Background(), testTenant)
+
+	record := testRecord("m1", "u1", time.Now().UTC())
+	record.ProviderMessageID = "p1"
+	if err := svc.SaveMessage(ctx, record); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	change := models.StatusChange{Status: models.StatusDelivered, ChangedAt: time.Now().UTC()}
+	if err := svc.UpdateStatusByProviderMessageID(tenant.WithID(context.Background(), "t2"), "p1", change); err != ErrMessageNotFound {
+		t.Fatalf("receipt of another tenant = %v, want %v", err, ErrMessageNotFound)
+	}
+	if err := svc.UpdateStatusByProviderMessageID(ctx, "p1", change); err != nil {
+		t.Fatalf("UpdateStatusByProviderMessageID: %v", err)
+	}
+
+	got, err := svc.GetMessageByID(ctx, record.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetMessageByID: %v", err)
+	}
+	if got.Status != models.StatusDelivered {
+		t.Errorf("status = %s, want %s", got.Status, models.StatusDelivered)
+	}
+}
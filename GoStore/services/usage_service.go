@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UsageService meters API key requests per UTC day, one document per key and day
+type UsageService struct {
+	db *db.Mongo
+}
+
+// NewUsageService creates a new usage service instance on m
+func NewUsageService(m *db.Mongo) *UsageService {
+	return &UsageService{db: m}
+}
+
+// usageID returns the ID of a key's usage document for day
+func usageID(keyID, day string) string {
+	return keyID + ":" + day
+}
+
+// today returns the current UTC day as stored in usage documents
+func today() string {
+	return time.Now().UTC().Format(models.UsageDayFormat)
+}
+
+// CountRequest adds a request to the key's usage today and returns today's
+// request count, this one included
+func (s *UsageService) CountRequest(ctx context.Context, tenantID, keyID string) (int64, error) {
+	updateCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	day := today()
+	update := bson.M{
+		"$inc":         bson.M{"requests": 1},
+		"$set":         bson.M{"updated_at": time.Now().UTC()},
+		"$setOnInsert": bson.M{"key_id": keyID, "tenant_id": tenantID, "day": day, "bytes": 0},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var usage models.APIKeyUsage
+	err := s.db.GetAPIKeyUsageCollection().FindOneAndUpdate(updateCtx, bson.M{"_id": usageID(keyID, day)}, update, opts).Decode(&usage)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count API key request: %w", err)
+	}
+	return usage.Requests, nil
+}
+
+// AddBytes adds response bytes served to the key's usage today
+func (s *UsageService) AddBytes(ctx context.Context, tenantID, keyID string, bytes int64) error {
+	updateCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	day := today()
+	update := bson.M{
+		"$inc":         bson.M{"bytes": bytes},
+		"$set":         bson.M{"updated_at": time.Now().UTC()},
+		"$setOnInsert": bson.M{"key_id": keyID, "tenant_id": tenantID, "day": day, "requests": 0},
+	}
+	_, err := s.db.GetAPIKeyUsageCollection().UpdateOne(updateCtx, bson.M{"_id": usageID(keyID, day)}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to record API key bytes: %w", err)
+	}
+	return nil
+}
+
+// Usage returns the daily usage of a key of the context's tenant over the last
+// days UTC days, today included, most recent first. Days without requests are omitted
+func (s *UsageService) Usage(ctx context.Context, keyID string, days int) ([]*models.APIKeyUsage, error) {
+	tenantID, err := s.checkKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	since := time.Now().UTC().AddDate(0, 0, 1-days).Format(models.UsageDayFormat)
+	filter := bson.M{"tenant_id": tenantID, "key_id": keyID, "day": bson.M{"$gte": since}}
+	cursor, err := s.db.GetAPIKeyUsageCollection().Find(queryCtx, filter, options.Find().SetSort(bson.D{{Key: "day", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API key usage: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	usage := []*models.APIKeyUsage{}
+	if err := cursor.All(queryCtx, &usage); err != nil {
+		return nil, fmt.Errorf("failed to decode API key usage: %w", err)
+	}
+	return usage, nil
+}
+
+// Reset clears today's usage of a key of the context's tenant, lifting its
+// quota until it is reached again
+func (s *UsageService) Reset(ctx context.Context, keyID string) error {
+	tenantID, err := s.checkKey(ctx, keyID)
+	if err != nil {
+		return err
+	}
+
+	deleteCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": usageID(keyID, today()), "tenant_id": tenantID}
+	if _, err := s.db.GetAPIKeyUsageCollection().DeleteOne(deleteCtx, filter); err != nil {
+		return fmt.Errorf("failed to reset API key usage: %w", err)
+	}
+	return nil
+}
+
+// checkKey returns the context's tenant, or ErrAPIKeyNotFound unless it owns
+// the key with the given ID, revoked or not
+func (s *UsageService) checkKey(ctx context.Context, keyID string) (string, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(keyID)
+	if err != nil {
+		return "", ErrAPIKeyNotFound
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	err = s.db.GetAPIKeysCollection().FindOne(queryCtx, bson.M{"_id": objectID, "tenant_id": tenantID}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up API key: %w", err)
+	}
+	return tenantID, nil
+}
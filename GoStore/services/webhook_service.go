@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/netguard"
+	"github.com/ramG-reddy/sms-store/phonenumber"
+	"github.com/ramG-reddy/sms-store/pseudonym"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrWebhookNotFound is returned when no subscription matches the given ID
+var ErrWebhookNotFound = errors.New("webhook subscription not found")
+
+// ErrForbiddenWebhookURL is returned when a subscription's URL host doesn't
+// resolve, or resolves to a loopback, private, link-local or unspecified address
+var ErrForbiddenWebhookURL = errors.New("webhook url must resolve to publicly routable addresses")
+
+// WebhookService manages webhook subscriptions and their delivery log
+type WebhookService struct {
+	db         *db.Mongo
+	pseudonyms *pseudonym.Hasher       // nil stores user IDs as they are
+	numbers    *phonenumber.Normalizer // nil stores user IDs as given
+}
+
+// NewWebhookService creates a new webhook service instance on m
+func NewWebhookService(m *db.Mongo) *WebhookService {
+	return &WebhookService{db: m}
+}
+
+// EnablePseudonyms stores the user ID of every subscription registered from now
+// on as its pseudonym, matching the records of SMSService.EnablePseudonyms
+func (s *WebhookService) EnablePseudonyms(h *pseudonym.Hasher) {
+	s.pseudonyms = h
+}
+
+// EnablePhoneNormalization stores the user ID of every subscription registered
+// from now on in E.164 form, matching the records of
+// SMSService.EnablePhoneNormalization
+func (s *WebhookService) EnablePhoneNormalization(n *phonenumber.Normalizer) {
+	s.numbers = n
+}
+
+// userKey returns the form a subscription's user ID is stored under, matching
+// the user IDs of the records it is notified of
+func (s *WebhookService) userKey(userID string) string {
+	if normalized, err := s.numbers.Normalize(userID); err == nil {
+		userID = normalized
+	}
+	return s.pseudonyms.Hash(userID)
+}
+
+// checkURL resolves the host of a subscription's URL, rejecting it unless every
+// address is publicly routable. Deliveries check the address dialed again, as
+// the name may resolve differently by then
+func checkURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForbiddenWebhookURL, err)
+	}
+	resolveCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := netguard.Resolve(resolveCtx, u.Hostname()); err != nil {
+		return fmt.Errorf("%w: %v", ErrForbiddenWebhookURL, err)
+	}
+	return nil
+}
+
+// Register stores a new subscription for the context's tenant, generating a signing
+// secret when none is given
+func (s *WebhookService) Register(ctx context.Context, sub *models.WebhookSubscription) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	if err := checkURL(ctx, sub.URL); err != nil {
+		return err
+	}
+	sub.TenantID = tenantID
+	sub.UserID = s.userKey(sub.UserID)
+
+	if sub.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			return err
+		}
+		sub.Secret = secret
+	}
+	sub.CreatedAt = time.Now().UTC()
+
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := s.db.GetWebhooksCollection().InsertOne(insertCtx, sub)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook subscription: %w", err)
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		sub.ID = id
+	}
+
+	slog.InfoContext(ctx, "Registered webhook", "webhook_id", sub.ID.Hex(), "tenant_id", sub.TenantID, "user_id", sub.UserID, "url", sub.URL)
+	return nil
+}
+
+// Get returns a subscription of the context's tenant by ID
+func (s *WebhookService) Get(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var sub models.WebhookSubscription
+	err = s.db.GetWebhooksCollection().FindOne(queryCtx, bson.M{"_id": objectID, "tenant_id": tenantID}).Decode(&sub)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+// List returns the subscriptions of the context's tenant, oldest first, only
+// those scoped to the user when userID is set
+func (s *WebhookService) List(ctx context.Context, userID string) ([]*models.WebhookSubscription, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+	filter := bson.M{"tenant_id": tenantID}
+	if userID != "" {
+		filter["user_id"] = s.userKey(userID)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cursor, err := s.db.GetWebhooksCollection().Find(queryCtx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	subs := []*models.WebhookSubscription{}
+	if err := cursor.All(queryCtx, &subs); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// Update replaces the URL, user, event types and enabled state of a subscription
+// of the context's tenant, and its signing secret when sub has one. sub is filled
+// in with the stored subscription
+func (s *WebhookService) Update(ctx context.Context, id string, sub *models.WebhookSubscription) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrWebhookNotFound
+	}
+	if err := checkURL(ctx, sub.URL); err != nil {
+		return err
+	}
+
+	set := bson.M{"url": sub.URL, "updated_at": time.Now().UTC()}
+	unset := bson.M{}
+	if sub.UserID != "" {
+		set["user_id"] = s.userKey(sub.UserID)
+	} else {
+		unset["user_id"] = ""
+	}
+	if len(sub.Events) > 0 {
+		set["events"] = sub.Events
+	} else {
+		unset["events"] = ""
+	}
+	if sub.Disabled {
+		set["disabled"] = true
+	} else {
+		unset["disabled"] = ""
+	}
+	if sub.Secret != "" {
+		set["secret"] = sub.Secret
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	if err := s.update(ctx, bson.M{"_id": objectID, "tenant_id": tenantID}, update, sub); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Updated webhook", "webhook_id", id, "tenant_id", tenantID, "url", sub.URL, "disabled", sub.Disabled)
+	return nil
+}
+
+// RotateSecret replaces the signing secret of a subscription of the context's
+// tenant with a generated one, returning the subscription with its new secret.
+// Deliveries already being retried keep signing with the old one
+func (s *WebhookService) RotateSecret(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	var sub models.WebhookSubscription
+	update := bson.M{"$set": bson.M{"secret": secret, "updated_at": time.Now().UTC()}}
+	if err := s.update(ctx, bson.M{"_id": objectID, "tenant_id": tenantID}, update, &sub); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Rotated webhook secret", "webhook_id", id, "tenant_id", tenantID)
+	return &sub, nil
+}
+
+// update applies update to the subscription matching filter, decoding the
+// updated subscription into sub
+func (s *WebhookService) update(ctx context.Context, filter, update bson.M, sub *models.WebhookSubscription) error {
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var updated models.WebhookSubscription
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.db.GetWebhooksCollection().FindOneAndUpdate(updateCtx, filter, update, opts).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrWebhookNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+	*sub = updated
+	return nil
+}
+
+// Delete removes a subscription by ID within the context's tenant, along with
+// its delivery log
+func (s *WebhookService) Delete(ctx context.Context, id string) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrWebhookNotFound
+	}
+
+	deleteCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := s.db.GetWebhooksCollection().DeleteOne(deleteCtx, bson.M{"_id": objectID, "tenant_id": tenantID})
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+	// A retry still in flight may log one more attempt after this
+	if _, err := s.db.GetWebhookDeliveriesCollection().DeleteMany(deleteCtx, bson.M{"subscription_id": objectID}); err != nil {
+		slog.WarnContext(ctx, "Failed to delete webhook delivery log", "webhook_id", id, "error", err)
+	}
+
+	slog.InfoContext(ctx, "Deleted webhook", "webhook_id", id, "tenant_id", tenantID)
+	return nil
+}
+
+// SubscriptionsForUser returns the tenant's enabled subscriptions scoped to the
+// user plus its enabled subscriptions for all users
+func (s *WebhookService) SubscriptionsForUser(ctx context.Context, tenantID, userID string) ([]*models.WebhookSubscription, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"tenant_id": tenantID,
+		"disabled":  bson.M{"$ne": true},
+		"$or": bson.A{
+			bson.M{"user_id": userID},
+			bson.M{"user_id": bson.M{"$exists": false}},
+		},
+	}
+
+	cursor, err := s.db.GetWebhooksCollection().Find(queryCtx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	var subs []*models.WebhookSubscription
+	if err := cursor.All(queryCtx, &subs); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// RecordDelivery appends an attempt to the webhook_deliveries log
+func (s *WebhookService) RecordDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := s.db.GetWebhookDeliveriesCollection().InsertOne(insertCtx, delivery); err != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// Deliveries returns the logged delivery attempts of a subscription of the
+// context's tenant, newest first, only the successful or failed ones when
+// success is set
+func (s *WebhookService) Deliveries(ctx context.Context, id string, success *bool, skip, limit int64) ([]*models.WebhookDelivery, error) {
+	sub, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"subscription_id": sub.ID}
+	if success != nil {
+		filter["success"] = *success
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+	cursor, err := s.db.GetWebhookDeliveriesCollection().Find(queryCtx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	deliveries := []*models.WebhookDelivery{}
+	if err := cursor.All(queryCtx, &deliveries); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// generateSecret returns a random hex-encoded HMAC signing secret
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
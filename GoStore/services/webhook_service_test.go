@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWebhookURLGuard(t *testing.T) {
+	// The URL is checked before the database is reached, so none is needed
+	svc := NewWebhookService(nil)
+	ctx := tenant.WithID(context.Background(), testTenant)
+
+	for _, target := range []string{
+		"http://127.0.0.1:8091/admin/quarantine",
+		"http://[::1]/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.5/",
+		"http://localhost/",
+		"http://0.0.0.0/",
+	} {
+		t.Run(target, func(t *testing.T) {
+			sub := &models.WebhookSubscription{URL: target}
+			if err := svc.Register(ctx, sub); !errors.Is(err, ErrForbiddenWebhookURL) {
+				t.Errorf("Register: got %v, want ErrForbiddenWebhookURL", err)
+			}
+			if err := svc.Update(ctx, primitive.NewObjectID().Hex(), sub); !errors.Is(err, ErrForbiddenWebhookURL) {
+				t.Errorf("Update: got %v, want ErrForbiddenWebhookURL", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package smpp
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Data codings of deliver_sm messages that can be decoded to text
+const (
+	codingDefault = 0x00 // SMSC default alphabet, see Config.DefaultAlphabet
+	codingIA5     = 0x01 // ASCII
+	codingLatin1  = 0x03 // ISO-8859-1
+	codingUCS2    = 0x08 // UTF-16BE
+
+	// codingGSM stands for GSM 03.38, which has no data coding of its own
+	codingGSM = 0xFF
+)
+
+// Alphabets an SMSC may use for its default data coding
+const (
+	AlphabetGSM    = "gsm"
+	AlphabetASCII  = "ascii"
+	AlphabetLatin1 = "latin1"
+)
+
+// alphabetCodings maps each default alphabet to the data coding it stands for
+var alphabetCodings = map[string]byte{
+	AlphabetGSM:    codingGSM,
+	AlphabetASCII:  codingIA5,
+	AlphabetLatin1: codingLatin1,
+}
+
+// IsValidAlphabet reports whether name is a supported default alphabet
+func IsValidAlphabet(name string) bool {
+	_, ok := alphabetCodings[name]
+	return ok
+}
+
+// gsmBasic is the GSM 03.38 default alphabet, indexed by septet; 0x1B escapes
+// to gsmExtension
+var gsmBasic = []rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// gsmExtension is the GSM 03.38 extension table
+var gsmExtension = map[byte]rune{
+	0x0A: '\f', 0x14: '^', 0x28: '{', 0x29: '}', 0x2F: '\\',
+	0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|', 0x65: '€',
+}
+
+// decodeText converts a message in the given data coding to a string, reading
+// the default data coding as alphabet. SMSCs send GSM text unpacked, one
+// septet per octet
+func decodeText(message []byte, dataCoding byte, alphabet string) (string, error) {
+	if dataCoding == codingDefault {
+		dataCoding = alphabetCodings[alphabet]
+	}
+	switch dataCoding {
+	case codingGSM:
+		return decodeGSM(message), nil
+	case codingIA5:
+		return string(message), nil
+	case codingLatin1:
+		runes := make([]rune, len(message))
+		for i, b := range message {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	case codingUCS2:
+		if len(message)%2 != 0 {
+			return "", fmt.Errorf("odd length UCS2 message")
+		}
+		units := make([]uint16, len(message)/2)
+		for i := range units {
+			units[i] = uint16(message[2*i])<<8 | uint16(message[2*i+1])
+		}
+		return string(utf16.Decode(units)), nil
+	default:
+		return "", fmt.Errorf("unsupported data coding 0x%02X", dataCoding)
+	}
+}
+
+// decodeGSM decodes unpacked GSM 03.38 septets
+func decodeGSM(message []byte) string {
+	var text strings.Builder
+	for i := 0; i < len(message); i++ {
+		septet := message[i] & 0x7F
+		if septet == 0x1B && i+1 < len(message) {
+			i++
+			if r, ok := gsmExtension[message[i]&0x7F]; ok {
+				text.WriteRune(r)
+			} else {
+				// Unknown escapes fall back to the basic character
+				text.WriteRune(gsmBasic[message[i]&0x7F])
+			}
+			continue
+		}
+		text.WriteRune(gsmBasic[septet])
+	}
+	return text.String()
+}
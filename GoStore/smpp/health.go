@@ -0,0 +1,47 @@
+package smpp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// MaxLag returns zero, since an SMSC doesn't report how many messages it holds
+func (r *Receiver) MaxLag() int64 {
+	return 0
+}
+
+// InFlight returns the number of messages delivered and not yet answered
+func (r *Receiver) InFlight() int {
+	return int(r.inFlight.Load())
+}
+
+// Check reports whether the receiver loop is running and bound to the SMSC
+func (r *Receiver) Check(ctx context.Context, _ int64) error {
+	if !r.running.Load() {
+		return errors.New("receiver loop is not running")
+	}
+	if !r.bound.Load() {
+		return errors.New("not bound to SMSC")
+	}
+	return ctx.Err()
+}
+
+// Stop unbinds from the SMSC once the batch in progress is stored and answered
+func (r *Receiver) Stop() error {
+	slog.Info("Stopping SMPP receiver")
+	close(r.stopChan)
+
+	select {
+	case <-r.done:
+	case <-time.After(stopTimeout):
+		slog.Warn("SMPP receiver did not finish its batch before shutdown")
+		// Unanswered messages are delivered again after the next bind
+		r.sessionMu.Lock()
+		r.session.conn.Close()
+		r.sessionMu.Unlock()
+	}
+	slog.Info("SMPP receiver stopped successfully")
+	return nil
+}
@@ -0,0 +1,246 @@
+package smpp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Command IDs of the SMPP 3.4 operations the receiver sends or answers
+const (
+	cmdGenericNack         uint32 = 0x80000000
+	cmdBindReceiver        uint32 = 0x00000001
+	cmdBindReceiverResp    uint32 = 0x80000001
+	cmdBindTransceiver     uint32 = 0x00000009
+	cmdBindTransceiverResp uint32 = 0x80000009
+	cmdDeliverSM           uint32 = 0x00000005
+	cmdDeliverSMResp       uint32 = 0x80000005
+	cmdUnbind              uint32 = 0x00000006
+	cmdUnbindResp          uint32 = 0x80000006
+	cmdEnquireLink         uint32 = 0x00000015
+	cmdEnquireLinkResp     uint32 = 0x80000015
+
+	// respBit is set in the command ID of every response
+	respBit uint32 = 0x80000000
+)
+
+// Command statuses the receiver answers with
+const (
+	statusOK         uint32 = 0x00000000
+	statusInvalidCmd uint32 = 0x00000003 // ESME_RINVCMDID
+	statusTempAppErr uint32 = 0x00000064 // ESME_RX_T_APPN, the SMSC retries later
+	statusPermAppErr uint32 = 0x00000065 // ESME_RX_R_APPN, the SMSC gives up
+)
+
+// Optional parameter tags read from deliver_sm
+const (
+	tagReceiptedMessageID uint16 = 0x001E
+	tagNetworkErrorCode   uint16 = 0x0423
+	tagMessagePayload     uint16 = 0x0424
+	tagMessageState       uint16 = 0x0427
+)
+
+const (
+	headerLen = 16
+
+	// maxPDULen bounds the PDUs read from the SMSC; deliver_sm with the
+	// largest message_payload fits easily
+	maxPDULen = 64 << 10
+
+	// interfaceVersion announces SMPP 3.4 when binding
+	interfaceVersion = 0x34
+)
+
+// esm_class bits of deliver_sm
+const (
+	esmTypeMask    = 0x3C
+	esmTypeReceipt = 0x04 // SMSC delivery receipt
+	esmUDHI        = 0x40 // short_message starts with a user data header
+)
+
+// pdu is an SMPP protocol data unit
+type pdu struct {
+	commandID uint32
+	status    uint32
+	sequence  uint32
+	body      []byte
+}
+
+// readPDU reads the next PDU from r
+func readPDU(r io.Reader) (*pdu, error) {
+	var header [headerLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length < headerLen || length > maxPDULen {
+		return nil, fmt.Errorf("invalid SMPP command length %d", length)
+	}
+	p := &pdu{
+		commandID: binary.BigEndian.Uint32(header[4:8]),
+		status:    binary.BigEndian.Uint32(header[8:12]),
+		sequence:  binary.BigEndian.Uint32(header[12:16]),
+		body:      make([]byte, length-headerLen),
+	}
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// encode serializes the PDU with its header
+func (p *pdu) encode() []byte {
+	data := make([]byte, headerLen, headerLen+len(p.body))
+	binary.BigEndian.PutUint32(data[0:4], uint32(headerLen+len(p.body)))
+	binary.BigEndian.PutUint32(data[4:8], p.commandID)
+	binary.BigEndian.PutUint32(data[8:12], p.status)
+	binary.BigEndian.PutUint32(data[12:16], p.sequence)
+	return append(data, p.body...)
+}
+
+// bindBody is the body of bind_receiver and bind_transceiver
+func bindBody(systemID, password, systemType string) []byte {
+	var body bytes.Buffer
+	body.WriteString(systemID)
+	body.WriteByte(0)
+	body.WriteString(password)
+	body.WriteByte(0)
+	body.WriteString(systemType)
+	body.WriteByte(0)
+	body.WriteByte(interfaceVersion)
+	body.WriteByte(0) // addr_ton
+	body.WriteByte(0) // addr_npi
+	body.WriteByte(0) // empty address_range
+	return body.Bytes()
+}
+
+// deliverSM holds the fields of a deliver_sm the receiver uses
+type deliverSM struct {
+	sourceTON   byte
+	source      string
+	destination string
+	esmClass    byte
+	dataCoding  byte
+	message     []byte // short_message, or message_payload when that is set
+
+	// Delivery receipts identify the message they report on and its state
+	receiptedID  string
+	messageState byte // zero when absent
+	networkError []byte
+}
+
+// isReceipt reports whether the PDU is a delivery receipt rather than a message
+func (d *deliverSM) isReceipt() bool {
+	return d.esmClass&esmTypeMask == esmTypeReceipt
+}
+
+// parseDeliverSM decodes the body of a deliver_sm PDU
+func parseDeliverSM(body []byte) (*deliverSM, error) {
+	r := &reader{data: body}
+	d := &deliverSM{}
+	r.cstring() // service_type
+	d.sourceTON = r.byte()
+	r.byte() // source_addr_npi
+	d.source = r.cstring()
+	r.byte() // dest_addr_ton
+	r.byte() // dest_addr_npi
+	d.destination = r.cstring()
+	d.esmClass = r.byte()
+	r.byte()    // protocol_id
+	r.byte()    // priority_flag
+	r.cstring() // schedule_delivery_time
+	r.cstring() // validity_period
+	r.byte()    // registered_delivery
+	r.byte()    // replace_if_present_flag
+	d.dataCoding = r.byte()
+	r.byte() // sm_default_msg_id
+	d.message = r.bytes(int(r.byte()))
+
+	for r.err == nil && r.remaining() > 0 {
+		tag := r.uint16()
+		value := r.bytes(int(r.uint16()))
+		switch tag {
+		case tagMessagePayload:
+			d.message = value
+		case tagReceiptedMessageID:
+			d.receiptedID = string(bytes.TrimRight(value, "\x00"))
+		case tagMessageState:
+			if len(value) == 1 {
+				d.messageState = value[0]
+			}
+		case tagNetworkErrorCode:
+			d.networkError = value
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed deliver_sm: %w", r.err)
+	}
+
+	// The user data header, e.g. concatenation info, is not part of the text
+	if d.esmClass&esmUDHI != 0 && len(d.message) > 0 {
+		udhLen := int(d.message[0]) + 1
+		if udhLen > len(d.message) {
+			return nil, errors.New("malformed deliver_sm: user data header exceeds the message")
+		}
+		d.message = d.message[udhLen:]
+	}
+	return d, nil
+}
+
+// errTruncated reports a PDU body shorter than its fields
+var errTruncated = errors.New("truncated PDU body")
+
+// reader reads the fields of a PDU body, remembering the first error
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > r.remaining() {
+		r.err = errTruncated
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+// cstring reads a NUL-terminated string
+func (r *reader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		r.err = errTruncated
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
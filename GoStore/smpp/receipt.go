@@ -0,0 +1,110 @@
+package smpp
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// Delivery states of the message_state parameter (SMPP 3.4 section 5.2.28)
+const (
+	stateEnroute       = 1
+	stateDelivered     = 2
+	stateExpired       = 3
+	stateDeleted       = 4
+	stateUndeliverable = 5
+	stateAccepted      = 6
+	stateUnknown       = 7
+	stateRejected      = 8
+)
+
+// receiptStatuses maps the stat field of a receipt text to a message status
+var receiptStatuses = map[string]string{
+	"ENROUTE": models.StatusSent,
+	"ACCEPTD": models.StatusSent,
+	"DELIVRD": models.StatusDelivered,
+	"EXPIRED": models.StatusFailed,
+	"DELETED": models.StatusFailed,
+	"UNDELIV": models.StatusFailed,
+	"UNKNOWN": models.StatusFailed,
+	"REJECTD": models.StatusFailed,
+}
+
+// stateStatuses maps message_state to a message status
+var stateStatuses = map[byte]string{
+	stateEnroute:       models.StatusSent,
+	stateAccepted:      models.StatusSent,
+	stateDelivered:     models.StatusDelivered,
+	stateExpired:       models.StatusFailed,
+	stateDeleted:       models.StatusFailed,
+	stateUndeliverable: models.StatusFailed,
+	stateUnknown:       models.StatusFailed,
+	stateRejected:      models.StatusFailed,
+}
+
+// receiptDateLayouts are the layouts of the done date of a receipt text, in
+// the SMSC's time zone, which is taken to be UTC; some SMSCs add seconds
+var receiptDateLayouts = []string{"0601021504", "060102150405"}
+
+// parseReceipt reads a delivery receipt from the optional parameters of d when
+// the SMSC sets them, and from the de facto standard receipt text otherwise:
+//
+//	id:IIII sub:SSS dlvrd:DDD submit date:YYMMDDhhmm done date:YYMMDDhhmm stat:DDDDDDD err:EEE text:...
+func parseReceipt(d *deliverSM) (*models.DeliveryReceipt, error) {
+	fields := receiptFields(string(d.message))
+	receipt := &models.DeliveryReceipt{
+		ProviderMessageID: d.receiptedID,
+		Status:            stateStatuses[d.messageState],
+		ErrorCode:         fields["err"],
+	}
+	if receipt.ProviderMessageID == "" {
+		receipt.ProviderMessageID = fields["id"]
+	}
+	if receipt.Status == "" {
+		receipt.Status = receiptStatuses[strings.ToUpper(fields["stat"])]
+	}
+	if len(d.networkError) > 0 {
+		receipt.ErrorCode = hex.EncodeToString(d.networkError)
+	}
+	for _, layout := range receiptDateLayouts {
+		if doneAt, err := time.Parse(layout, fields["done date"]); err == nil {
+			receipt.DeliveredAt = doneAt
+			break
+		}
+	}
+	// err:000 means no error
+	if strings.Trim(receipt.ErrorCode, "0") == "" {
+		receipt.ErrorCode = ""
+	}
+
+	if err := receipt.Validate(); err != nil {
+		return nil, errors.New("unrecognized delivery receipt: " + err.Error())
+	}
+	return receipt, nil
+}
+
+// receiptFields splits a receipt text into its name:value fields. Names may
+// contain a space ("submit date"), so each name runs from the end of the
+// previous value to the next colon; the text field takes the rest
+func receiptFields(text string) map[string]string {
+	fields := make(map[string]string)
+	for text != "" {
+		colon := strings.IndexByte(text, ':')
+		if colon < 0 {
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(text[:colon]))
+		text = text[colon+1:]
+		if name == "text" {
+			fields[name] = text
+			break
+		}
+		value, rest, _ := strings.Cut(text, " ")
+		fields[name] = value
+		text = rest
+	}
+	return fields
+}
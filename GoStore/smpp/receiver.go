@@ -0,0 +1,558 @@
+// Package smpp binds to an SMSC over SMPP 3.4 as a receiver or transceiver and
+// stores the messages it delivers, for deployments connected directly to a
+// carrier rather than through an SMS provider's API
+package smpp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/requestid"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/tenant"
+)
+
+// backend labels the metrics of the receiver
+const backend = "smpp"
+
+// Bind types
+const (
+	BindReceiver    = "receiver"
+	BindTransceiver = "transceiver"
+)
+
+const (
+	// dialTimeout and bindTimeout bound connecting and waiting for bind_resp,
+	// writeTimeout sending a PDU, and unbindTimeout waiting for unbind_resp
+	dialTimeout   = 10 * time.Second
+	bindTimeout   = 10 * time.Second
+	writeTimeout  = 10 * time.Second
+	unbindTimeout = 5 * time.Second
+
+	// stopTimeout bounds how long Stop waits for the batch in progress to be stored
+	stopTimeout = 10 * time.Second
+
+	// Rebinding is attempted after reconnectBase, doubling after each failed
+	// attempt up to reconnectMax
+	reconnectBase = time.Second
+	reconnectMax  = 30 * time.Second
+
+	// tonInternational is the type of number of addresses in international format
+	tonInternational = 0x01
+)
+
+// Config identifies the SMSC and account to bind with, and how delivered
+// messages are batched
+type Config struct {
+	Addr       string      // host:port of the SMSC
+	TLS        *tls.Config // nil connects in plaintext
+	SystemID   string
+	Password   string
+	SystemType string
+	BindType   string // BindReceiver or BindTransceiver
+
+	// DefaultAlphabet is what the SMSC's default data coding means: AlphabetGSM,
+	// as SMPP specifies, or AlphabetASCII or AlphabetLatin1, as some SMSCs use
+	DefaultAlphabet string
+
+	// EnquireLink is how often the link is checked with enquire_link; the
+	// connection is considered lost once nothing arrives for three times as long
+	EnquireLink time.Duration
+
+	// TenantID is the tenant received messages are stored for
+	TenantID string
+
+	// Delivered messages are stored in batches of up to BatchSize, waiting up to
+	// BatchWait for a batch to fill. The SMSC's window limits how many it sends
+	// before they are answered
+	BatchSize int
+	BatchWait time.Duration
+
+	// Stages are custom processors added to the ingest pipeline, see ingest.Stage
+	Stages []ingest.Stage
+}
+
+// Receiver stores the messages an SMSC delivers with deliver_sm, answering each
+// once it is stored, and applies the delivery receipts it sends to the messages
+// they report on
+type Receiver struct {
+	cfg        Config
+	pipeline   *ingest.Pipeline
+	smsService *services.SMSService
+	stopChan   chan struct{}
+	done       chan struct{}
+
+	// session is the current bound connection, replaced on rebind
+	sessionMu sync.Mutex
+	session   *session
+
+	sequence atomic.Uint32
+
+	// running, bound and inFlight are reported by Check and InFlight
+	running  atomic.Bool
+	bound    atomic.Bool
+	inFlight atomic.Int64
+
+	// Pausing answers further deliveries with a temporary error, so the SMSC
+	// holds them and delivers them again later; messages already received are
+	// still stored and answered
+	*ingest.Gate
+}
+
+// session is one connection bound to the SMSC
+type session struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+}
+
+// write sends a PDU; the read loop and the batches answering deliveries share
+// the connection
+func (s *session) write(p *pdu) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := s.conn.Write(p.encode())
+	return err
+}
+
+// unbindError reports that the SMSC asked to unbind
+type unbindError struct {
+	sequence uint32
+}
+
+func (e *unbindError) Error() string {
+	return "SMSC requested unbind"
+}
+
+// StartReceiver binds to the SMSC and begins receiving in a background
+// goroutine, rebinding whenever the connection is lost
+func StartReceiver(cfg Config, smsService *services.SMSService) (*Receiver, error) {
+	slog.Info("Starting SMPP receiver", "smsc", cfg.Addr, "system_id", cfg.SystemID, "bind_type", cfg.BindType, "tls", cfg.TLS != nil)
+
+	r := &Receiver{
+		cfg:        cfg,
+		smsService: smsService,
+		stopChan:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	// Deliveries storage can't take are answered with a temporary error too, so
+	// the receiver never waits for storage and needs no retry delay
+	r.Gate = ingest.NewGate("SMPP receiver", r.stopChan, 0)
+	pipeline, err := ingest.NewPipeline(ingest.PipelineConfig{
+		Decode:          r.decode,
+		DefaultTenantID: cfg.TenantID,
+		Direction:       models.DirectionInbound,
+		Persist:         ingest.Persist(smsService),
+		Stages:          cfg.Stages,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.pipeline = pipeline
+
+	s, err := r.bind()
+	if err != nil {
+		return nil, err
+	}
+
+	go r.run(s)
+	slog.Info("SMPP receiver started successfully")
+	return r, nil
+}
+
+// bind connects to the SMSC and binds as the configured system ID
+func (r *Receiver) bind() (*session, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if r.cfg.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", r.cfg.Addr, r.cfg.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", r.cfg.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMSC: %w", err)
+	}
+
+	commandID, respID := cmdBindReceiver, cmdBindReceiverResp
+	if r.cfg.BindType == BindTransceiver {
+		commandID, respID = cmdBindTransceiver, cmdBindTransceiverResp
+	}
+	s := &session{conn: conn}
+	req := &pdu{commandID: commandID, sequence: r.nextSequence(), body: bindBody(r.cfg.SystemID, r.cfg.Password, r.cfg.SystemType)}
+	if err := s.write(req); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send SMPP bind: %w", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(bindTimeout))
+	for {
+		resp, err := readPDU(conn)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to read SMPP bind response: %w", err)
+		}
+		if resp.commandID == cmdGenericNack || (resp.commandID == respID && resp.sequence == req.sequence) {
+			if resp.commandID == cmdGenericNack || resp.status != statusOK {
+				conn.Close()
+				return nil, fmt.Errorf("SMSC refused to bind %s as %s: status 0x%08X", r.cfg.SystemID, r.cfg.BindType, resp.status)
+			}
+			break
+		}
+	}
+
+	r.sessionMu.Lock()
+	r.session = s
+	r.sessionMu.Unlock()
+	r.bound.Store(true)
+	slog.Info("Bound to SMSC", "smsc", r.cfg.Addr, "system_id", r.cfg.SystemID, "bind_type", r.cfg.BindType)
+	return s, nil
+}
+
+// nextSequence returns the sequence number of the next request, which runs
+// from 1 to 0x7FFFFFFF
+func (r *Receiver) nextSequence() uint32 {
+	return r.sequence.Add(1)%0x7FFFFFFF + 1
+}
+
+// run serves the current session until it is lost, then rebinds, until the
+// receiver is stopped
+func (r *Receiver) run(s *session) {
+	r.running.Store(true)
+	defer close(r.done)
+	defer func() {
+		// A dead loop must fail readiness rather than silently stop receiving
+		r.running.Store(false)
+		if rec := recover(); rec != nil {
+			slog.Error("SMPP receiver panic recovered", "panic", rec)
+		}
+	}()
+
+	for {
+		r.serve(s)
+		r.bound.Store(false)
+
+		select {
+		case <-r.stopChan:
+			return
+		default:
+		}
+		if s = r.rebind(); s == nil {
+			return
+		}
+	}
+}
+
+// rebind retries binding with backoff. It returns nil if the receiver is
+// stopped first
+func (r *Receiver) rebind() *session {
+	delay := reconnectBase
+	for {
+		if !r.sleep(delay) {
+			return nil
+		}
+		s, err := r.bind()
+		if err == nil {
+			return s
+		}
+		slog.Error("Failed to rebind to SMSC", "retry_in", delay, "error", err)
+		delay = min(delay*2, reconnectMax)
+	}
+}
+
+// serve answers the SMSC on a bound session and checks the link, until the
+// connection is lost, the SMSC unbinds or the receiver is stopped. Delivered
+// messages are handed to a batching goroutine, which answers them once stored
+func (r *Receiver) serve(s *session) {
+	deliveries := make(chan *pdu, r.cfg.BatchSize)
+	quit := make(chan struct{})
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		r.consume(s, deliveries, quit)
+	}()
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- r.read(s, deliveries)
+	}()
+
+	// finish lets the batch in progress be answered before the connection closes
+	finish := func() {
+		close(quit)
+		<-consumed
+		s.conn.Close()
+	}
+
+	ticker := time.NewTicker(r.cfg.EnquireLink)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-readErr:
+			var unbind *unbindError
+			if errors.As(err, &unbind) {
+				slog.Warn("SMSC unbound")
+				close(quit)
+				<-consumed
+				_ = s.write(&pdu{commandID: cmdUnbindResp, sequence: unbind.sequence})
+				s.conn.Close()
+				return
+			}
+			slog.Warn("Lost connection to SMSC", "error", err)
+			finish()
+			return
+		case <-ticker.C:
+			if err := s.write(&pdu{commandID: cmdEnquireLink, sequence: r.nextSequence()}); err != nil {
+				slog.Warn("Lost connection to SMSC", "error", err)
+				s.conn.Close()
+				<-readErr
+				finish()
+				return
+			}
+		case <-r.stopChan:
+			// The batching goroutine answers its batch and returns
+			<-consumed
+			if err := s.write(&pdu{commandID: cmdUnbind, sequence: r.nextSequence()}); err == nil {
+				s.conn.SetReadDeadline(time.Now().Add(unbindTimeout))
+				<-readErr
+			}
+			close(quit)
+			s.conn.Close()
+			return
+		}
+	}
+}
+
+// read handles the PDUs the SMSC sends until the connection fails, returning
+// once the SMSC unbinds or acknowledges an unbind
+func (r *Receiver) read(s *session, deliveries chan<- *pdu) error {
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(3 * r.cfg.EnquireLink))
+		p, err := readPDU(s.conn)
+		if err != nil {
+			return err
+		}
+
+		switch p.commandID {
+		case cmdDeliverSM:
+			if r.Paused() {
+				r.respond(s, p.sequence, statusTempAppErr)
+				continue
+			}
+			select {
+			case deliveries <- p:
+			case <-r.stopChan:
+				// Left unanswered, so the SMSC delivers it again after the next bind
+			}
+		case cmdEnquireLink:
+			if err := s.write(&pdu{commandID: cmdEnquireLinkResp, sequence: p.sequence}); err != nil {
+				return err
+			}
+		case cmdUnbind:
+			return &unbindError{sequence: p.sequence}
+		case cmdUnbindResp:
+			return nil
+		default:
+			// Responses, such as enquire_link_resp, need no answer
+			if p.commandID&respBit == 0 {
+				slog.Warn("Unsupported SMPP command from SMSC", "command_id", fmt.Sprintf("0x%08X", p.commandID))
+				if err := s.write(&pdu{commandID: cmdGenericNack, status: statusInvalidCmd, sequence: p.sequence}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// consume stores delivered messages a batch at a time, until quit is closed
+// or the receiver is stopped
+func (r *Receiver) consume(s *session, deliveries <-chan *pdu, quit <-chan struct{}) {
+	for {
+		batch, open := r.collect(deliveries, quit)
+		if len(batch) > 0 {
+			r.inFlight.Add(int64(len(batch)))
+			r.store(s, batch)
+			r.inFlight.Add(-int64(len(batch)))
+		}
+		if !open {
+			return
+		}
+	}
+}
+
+// collect waits for a delivery, then gathers more until the batch is full or
+// BatchWait has passed. It reports false once quit is closed or the receiver
+// is stopped, along with the deliveries gathered until then
+func (r *Receiver) collect(deliveries <-chan *pdu, quit <-chan struct{}) ([]*pdu, bool) {
+	var batch []*pdu
+	select {
+	case p := <-deliveries:
+		batch = append(batch, p)
+	case <-quit:
+		return nil, false
+	case <-r.stopChan:
+		return nil, false
+	}
+
+	timer := time.NewTimer(r.cfg.BatchWait)
+	defer timer.Stop()
+	for len(batch) < r.cfg.BatchSize {
+		select {
+		case p := <-deliveries:
+			batch = append(batch, p)
+		case <-timer.C:
+			return batch, true
+		case <-quit:
+			return batch, false
+		case <-r.stopChan:
+			return batch, false
+		}
+	}
+	return batch, true
+}
+
+// store handles a batch of deliver_sm PDUs and answers each: messages are
+// stored through the pipeline, and receipts applied to the messages they
+// report on. The SMSC delivers those answered with a temporary error again
+// later, and gives up on those answered with a permanent one
+func (r *Receiver) store(s *session, batch []*pdu) {
+	statuses := make([]uint32, len(batch))
+	answered := make([]bool, len(batch))
+	events := make([]*ingest.Event, 0, len(batch))
+	sources := make(map[*ingest.Event]int, len(batch))
+	for n, p := range batch {
+		metrics.IngestMessageConsumed(backend, r.cfg.Addr)
+		ctx := requestid.WithID(context.Background(), requestid.New())
+		d, err := parseDeliverSM(p.body)
+		switch {
+		case err != nil:
+			statuses[n], answered[n] = r.reject(ctx, ingest.ReasonMalformed, err), true
+		case d.isReceipt():
+			statuses[n], answered[n] = r.applyReceipt(ctx, d), true
+		default:
+			e := ingest.NewEvent(ctx, r.cfg.Addr, p.body)
+			events = append(events, e)
+			sources[e] = n
+		}
+	}
+
+	if len(events) > 0 {
+		err := r.pipeline.Run(context.Background(), events, func(e *ingest.Event) error {
+			n := sources[e]
+			statuses[n], answered[n] = r.reject(e.Context(), e.Rejection().Reason, e.Rejection().Err), true
+			return nil
+		})
+		if err != nil {
+			slog.Error("Error storing SMPP messages", "messages", len(events), "error", err)
+		}
+		for _, e := range events {
+			n := sources[e]
+			if answered[n] {
+				continue
+			}
+			statuses[n] = statusOK
+			if err != nil {
+				metrics.IngestProcessingFailed(backend, r.cfg.Addr)
+				statuses[n] = statusTempAppErr
+			}
+		}
+	}
+
+	for n, p := range batch {
+		r.respond(s, p.sequence, statuses[n])
+	}
+}
+
+// applyReceipt updates the status of the message a delivery receipt reports
+// on, returning the status to answer the receipt with
+func (r *Receiver) applyReceipt(ctx context.Context, d *deliverSM) uint32 {
+	receipt, err := parseReceipt(d)
+	if err != nil {
+		return r.reject(ctx, ingest.ReasonInvalid, err)
+	}
+
+	// Receipts only report on messages sent through this bind, which are stored for its tenant
+	err = r.smsService.UpdateStatusByProviderMessageID(tenant.WithID(ctx, r.cfg.TenantID), receipt.ProviderMessageID, receipt.ToStatusChange())
+	if errors.Is(err, services.ErrMessageNotFound) {
+		// Most likely sent by another system on the same account; an error
+		// would only have the SMSC deliver the receipt again
+		slog.InfoContext(ctx, "No message found for SMPP delivery receipt", "provider_message_id", receipt.ProviderMessageID, "status", receipt.Status)
+		return statusOK
+	}
+	if err != nil {
+		metrics.IngestProcessingFailed(backend, r.cfg.Addr)
+		slog.ErrorContext(ctx, "Error applying SMPP delivery receipt", "provider_message_id", receipt.ProviderMessageID, "error", err)
+		return statusTempAppErr
+	}
+	return statusOK
+}
+
+// reject counts and logs a deliver_sm that can never be handled, returning the
+// permanent error to answer it with
+func (r *Receiver) reject(ctx context.Context, reason string, cause error) uint32 {
+	metrics.IngestRejected(backend, r.cfg.Addr, reason)
+	slog.WarnContext(ctx, "Rejected unprocessable SMPP delivery", "reason", reason, "error", cause)
+	return statusPermAppErr
+}
+
+// respond answers a deliver_sm. A failed write loses the connection, so the
+// SMSC delivers the message again after the next bind
+func (r *Receiver) respond(s *session, sequence, status uint32) {
+	// deliver_sm_resp carries an empty message_id
+	if err := s.write(&pdu{commandID: cmdDeliverSMResp, status: status, sequence: sequence, body: []byte{0}}); err != nil {
+		slog.Warn("Failed to answer SMPP delivery", "sequence", sequence, "error", err)
+	}
+}
+
+// sleep waits for delay, reporting false if the receiver is stopped first
+func (r *Receiver) sleep(delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return true
+	case <-r.stopChan:
+		return false
+	}
+}
+
+// decode maps a deliver_sm carrying a mobile originated message to an
+// SMS event. The sender is the user, and the address it was sent to the sender
+// ID. deliver_sm carries no message ID, so every delivery gets a new event ID.
+// The parts of a concatenated message are stored separately
+func (r *Receiver) decode(_ context.Context, e *ingest.Event) (*models.KafkaEvent, error) {
+	d, err := parseDeliverSM(e.Payload)
+	if err != nil {
+		return nil, ingest.Rejected(ingest.ReasonMalformed, err)
+	}
+	if d.source == "" {
+		return nil, ingest.Rejected(ingest.ReasonInvalid, errors.New("source_addr is required"))
+	}
+	text, err := decodeText(d.message, d.dataCoding, r.cfg.DefaultAlphabet)
+	if err != nil {
+		return nil, ingest.Rejected(ingest.ReasonInvalid, err)
+	}
+
+	phoneNumber := d.source
+	if d.sourceTON == tonInternational && !strings.HasPrefix(phoneNumber, "+") {
+		phoneNumber = "+" + phoneNumber
+	}
+	return &models.KafkaEvent{
+		EventID:     rand.Text(),
+		UserID:      phoneNumber,
+		PhoneNumber: phoneNumber,
+		Message:     text,
+		Status:      models.StatusDelivered,
+		CreatedAt:   time.Now().UTC().Format("2006-01-02T15:04:05"),
+		SenderID:    d.destination,
+	}, nil
+}
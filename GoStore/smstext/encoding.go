@@ -0,0 +1,35 @@
+// Package smstext classifies the text of messages: the SMS encoding it needs,
+// the script it is written in and, where the text makes it plain, its language
+package smstext
+
+import "strings"
+
+// Encodings of SMS text
+const (
+	EncodingGSM7 = "GSM-7" // the GSM 03.38 default alphabet and its extension table
+	EncodingUCS2 = "UCS-2" // UTF-16, for text with any other character
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet; each character takes one septet
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension is the GSM 03.38 extension table; each character takes an
+// escape septet and its own
+const gsm7Extension = "\f^{}\\[~]|€"
+
+// IsGSM7Extension reports whether r is sent as two septets in GSM-7
+func IsGSM7Extension(r rune) bool {
+	return strings.ContainsRune(gsm7Extension, r)
+}
+
+// Encoding returns the encoding text is sent in: GSM-7 if every character is in
+// the GSM 03.38 alphabet or its extension table, UCS-2 otherwise
+func Encoding(text string) string {
+	for _, r := range text {
+		if !strings.ContainsRune(gsm7Basic, r) && !IsGSM7Extension(r) {
+			return EncodingUCS2
+		}
+	}
+	return EncodingGSM7
+}
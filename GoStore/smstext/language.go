@@ -0,0 +1,170 @@
+package smstext
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scripts are the scripts Detect tells apart, by ISO 15924 code
+var scripts = []struct {
+	code  string
+	table *unicode.RangeTable
+}{
+	{"Latn", unicode.Latin},
+	{"Cyrl", unicode.Cyrillic},
+	{"Grek", unicode.Greek},
+	{"Arab", unicode.Arabic},
+	{"Hebr", unicode.Hebrew},
+	{"Hani", unicode.Han},
+	{"Hira", unicode.Hiragana},
+	{"Kana", unicode.Katakana},
+	{"Hang", unicode.Hangul},
+	{"Thai", unicode.Thai},
+	{"Deva", unicode.Devanagari},
+	{"Beng", unicode.Bengali},
+	{"Guru", unicode.Gurmukhi},
+	{"Gujr", unicode.Gujarati},
+	{"Taml", unicode.Tamil},
+	{"Telu", unicode.Telugu},
+	{"Knda", unicode.Kannada},
+	{"Mlym", unicode.Malayalam},
+	{"Sinh", unicode.Sinhala},
+	{"Geor", unicode.Georgian},
+	{"Armn", unicode.Armenian},
+	{"Ethi", unicode.Ethiopic},
+	{"Khmr", unicode.Khmer},
+	{"Laoo", unicode.Lao},
+	{"Mymr", unicode.Myanmar},
+}
+
+// scriptLanguages are the languages implied by scripts used for one language only
+var scriptLanguages = map[string]string{
+	"Grek": "el", "Hebr": "he", "Hang": "ko", "Thai": "th", "Beng": "bn", "Guru": "pa",
+	"Gujr": "gu", "Taml": "ta", "Telu": "te", "Knda": "kn", "Mlym": "ml", "Sinh": "si",
+	"Geor": "ka", "Armn": "hy", "Ethi": "am", "Khmr": "km", "Laoo": "lo", "Mymr": "my",
+}
+
+// latinStopwords are frequent short words of the Latin-script languages Detect
+// recognizes. Words common to several of them are left out
+var latinStopwords = map[string][]string{
+	"en": {"the", "and", "you", "your", "is", "are", "to", "of", "for", "with", "this", "have", "will", "please", "thanks", "code"},
+	"es": {"el", "los", "las", "que", "y", "es", "por", "para", "con", "su", "tu", "gracias", "hola", "del", "una", "código"},
+	"fr": {"le", "les", "et", "est", "vous", "votre", "pour", "avec", "merci", "bonjour", "une", "des", "du", "au", "pas"},
+	"de": {"der", "die", "das", "und", "ist", "sie", "ihr", "ihre", "für", "mit", "nicht", "danke", "ein", "eine", "bitte"},
+	"pt": {"o", "os", "que", "e", "é", "você", "seu", "sua", "para", "com", "obrigado", "olá", "um", "uma", "não"},
+	"it": {"il", "gli", "che", "è", "sono", "per", "con", "tuo", "grazie", "ciao", "una", "non", "della", "di"},
+	"nl": {"de", "het", "een", "en", "is", "je", "jouw", "uw", "voor", "met", "niet", "dank", "bedankt", "van"},
+	"tr": {"ve", "bir", "bu", "için", "ile", "değil", "teşekkürler", "merhaba", "kodunuz", "lütfen"},
+	"id": {"dan", "yang", "untuk", "dengan", "anda", "ini", "tidak", "terima", "kasih", "kode", "dari"},
+}
+
+// latinWordLanguages maps each stopword to the languages it belongs to
+var latinWordLanguages = func() map[string][]string {
+	words := make(map[string][]string)
+	for language, list := range latinStopwords {
+		for _, word := range list {
+			words[word] = append(words[word], language)
+		}
+	}
+	return words
+}()
+
+// Info describes the text of a message
+type Info struct {
+	Encoding string // EncodingGSM7 or EncodingUCS2
+	Script   string // ISO 15924 code of the dominant script; empty without letters
+	Language string // ISO 639-1 code; empty when the text does not make it plain
+}
+
+// Detect classifies text. Languages are told from scripts used by a single
+// language, letters peculiar to one language of a shared script, and for Latin
+// script, from common words; short or mixed texts often have none
+func Detect(text string) Info {
+	info := Info{Encoding: Encoding(text)}
+
+	counts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.code]++
+				break
+			}
+		}
+	}
+	best := 0
+	for _, s := range scripts {
+		if counts[s.code] > best {
+			info.Script, best = s.code, counts[s.code]
+		}
+	}
+
+	switch info.Script {
+	case "":
+	case "Hani", "Hira", "Kana":
+		// Japanese mixes kana into Han text; Chinese has none
+		if counts["Hira"]+counts["Kana"] > 0 {
+			info.Script, info.Language = "Jpan", "ja"
+		} else {
+			info.Language = "zh"
+		}
+	case "Cyrl":
+		info.Language = cyrillicLanguage(text)
+	case "Arab":
+		info.Language = arabicLanguage(text)
+	case "Latn":
+		info.Language = latinLanguage(text)
+	default:
+		info.Language = scriptLanguages[info.Script]
+	}
+	return info
+}
+
+// cyrillicLanguage tells Ukrainian and Russian apart by their own letters
+func cyrillicLanguage(text string) string {
+	lower := strings.ToLower(text)
+	switch {
+	case strings.ContainsAny(lower, "єїґі"):
+		return "uk"
+	case strings.ContainsAny(lower, "ыэё"):
+		return "ru"
+	}
+	return ""
+}
+
+// arabicLanguage tells Urdu and Persian from Arabic by the letters they add
+func arabicLanguage(text string) string {
+	switch {
+	case strings.ContainsAny(text, "ٹڈڑںے"):
+		return "ur"
+	case strings.ContainsAny(text, "پچژگ"):
+		return "fa"
+	}
+	return "ar"
+}
+
+// latinLanguage returns the language with the most stopwords in text, if it
+// has at least two and no other language has as many
+func latinLanguage(text string) string {
+	scores := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for _, language := range latinWordLanguages[word] {
+			scores[language]++
+		}
+	}
+	language, best, tied := "", 1, false
+	for candidate, score := range scores {
+		switch {
+		case score > best:
+			language, best, tied = candidate, score, false
+		case score == best:
+			tied = true
+		}
+	}
+	if tied {
+		return ""
+	}
+	return language
+}
@@ -0,0 +1,59 @@
+package smstext
+
+import "unicode/utf16"
+
+// Capacity of a segment in each encoding. A message too long for one segment is
+// sent in several whose user data headers, needed to reassemble them, take up
+// the space of 7 septets or 3 UTF-16 units
+const (
+	gsm7Single    = 160 // septets
+	gsm7Multipart = 153
+	ucs2Single    = 70 // UTF-16 units
+	ucs2Multipart = 67
+)
+
+// Segments returns the number of SMS segments text is sent in, which is what
+// carriers bill. Extension table characters take two septets and characters
+// outside the Basic Multilingual Plane two UTF-16 units; neither is split across
+// segments. Empty text takes none
+func Segments(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	// The size of each character in the encoding's units
+	var sizes []int
+	single, multipart := gsm7Single, gsm7Multipart
+	if Encoding(text) == EncodingGSM7 {
+		for _, r := range text {
+			if IsGSM7Extension(r) {
+				sizes = append(sizes, 2)
+			} else {
+				sizes = append(sizes, 1)
+			}
+		}
+	} else {
+		single, multipart = ucs2Single, ucs2Multipart
+		for _, r := range text {
+			sizes = append(sizes, utf16.RuneLen(r))
+		}
+	}
+
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
+	if total <= single {
+		return 1
+	}
+
+	segments, used := 1, 0
+	for _, size := range sizes {
+		if used+size > multipart {
+			segments++
+			used = 0
+		}
+		used += size
+	}
+	return segments
+}
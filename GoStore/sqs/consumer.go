@@ -0,0 +1,375 @@
+// Package sqs consumes SMS events from Amazon SQS queues, including events
+// published to an SNS topic the queues are subscribed to, so the service can
+// run in AWS without Kafka
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ramG-reddy/sms-store/ingest"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+// backend labels the metrics of the consumer
+const backend = "sqs"
+
+const (
+	// requestTimeout bounds SQS calls other than receiving, which long-polls
+	requestTimeout = 10 * time.Second
+
+	// stopTimeout bounds how long Stop waits for the batches in progress to be stored
+	stopTimeout = 10 * time.Second
+
+	// retryBase is how long a message that failed processing stays invisible
+	// after its first receive, doubling with each further one up to the
+	// visibility timeout
+	retryBase = time.Second
+)
+
+// Config identifies the queues to consume, and how messages are received
+type Config struct {
+	Queues   map[string]string // queue name to the direction of its records, e.g. models.DirectionInbound
+	Endpoint string            // Optional SQS-compatible endpoint (e.g. LocalStack)
+
+	// DefaultTenantID is assigned to events that don't carry a tenantId
+	DefaultTenantID string
+
+	// Validation configures strict validation of events; off by default
+	Validation ingest.Validation
+
+	// Each receive long-polls for up to WaitTime for a batch of up to BatchSize
+	// messages, at most 10
+	BatchSize int
+	WaitTime  time.Duration
+
+	// VisibilityTimeout is how long received messages are hidden from other
+	// consumers. It is extended while a batch is still being stored
+	VisibilityTimeout time.Duration
+
+	// Stages are custom processors added to the ingest pipeline, see ingest.Stage
+	Stages []ingest.Stage
+}
+
+// Consumer stores the SMS events of SQS queues, deleting messages once stored
+type Consumer struct {
+	cfg       Config
+	client    *sqs.Client
+	queueURLs map[string]string           // by queue name
+	pipelines map[string]*ingest.Pipeline // by queue name
+
+	// stopChan stops the polling loops, and cancelling receiveCtx ends the
+	// receives they are waiting on
+	stopChan      chan struct{}
+	receiveCtx    context.Context
+	cancelReceive context.CancelFunc
+	done          chan struct{}
+
+	// Pausing stops receiving new messages; batches already received are still
+	// stored and deleted. Unreceived messages stay in the queues
+	*ingest.Gate
+
+	// running, backlog and inFlight are reported by Check, MaxLag and InFlight
+	running  atomic.Int32
+	backlog  atomic.Int64
+	inFlight atomic.Int64
+}
+
+// StartConsumer looks up the URLs of cfg.Queues with the default AWS credential
+// chain and begins polling each in a background goroutine. The queues
+// themselves must already exist
+func StartConsumer(cfg Config, smsService *services.SMSService) (*Consumer, error) {
+	slog.Info("Starting SQS consumer", "queues", cfg.Queues, "batch_size", cfg.BatchSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	client := sqs.NewFromConfig(awsCfg, func(o *sqs.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	receiveCtx, cancelReceive := context.WithCancel(context.Background())
+	c := &Consumer{
+		cfg:           cfg,
+		client:        client,
+		queueURLs:     make(map[string]string, len(cfg.Queues)),
+		pipelines:     make(map[string]*ingest.Pipeline, len(cfg.Queues)),
+		stopChan:      make(chan struct{}),
+		receiveCtx:    receiveCtx,
+		cancelReceive: cancelReceive,
+		done:          make(chan struct{}),
+	}
+	c.Gate = ingest.NewGate("SQS consumer", c.stopChan, retryBase)
+	for queue, direction := range cfg.Queues {
+		pipeline, err := ingest.NewPipeline(ingest.PipelineConfig{
+			DefaultTenantID: cfg.DefaultTenantID,
+			Validation:      cfg.Validation,
+			Direction:       direction,
+			Persist:         ingest.Persist(smsService),
+			Stages:          cfg.Stages,
+		})
+		if err != nil {
+			cancelReceive()
+			return nil, fmt.Errorf("queue %s: %w", queue, err)
+		}
+		c.pipelines[queue] = pipeline
+
+		out, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queue)})
+		if err != nil {
+			cancelReceive()
+			return nil, fmt.Errorf("failed to look up SQS queue %s: %w", queue, err)
+		}
+		c.queueURLs[queue] = aws.ToString(out.QueueUrl)
+	}
+
+	var wg sync.WaitGroup
+	for queue := range cfg.Queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.poll(queue)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(c.done)
+	}()
+
+	slog.Info("SQS consumer started successfully")
+	return c, nil
+}
+
+// poll is the consumption loop of one queue, receiving and storing a batch at a time
+func (c *Consumer) poll(queue string) {
+	c.running.Add(1)
+	defer func() {
+		// A dead loop must fail readiness rather than silently stop consuming
+		c.running.Add(-1)
+		if r := recover(); r != nil {
+			slog.Error("SQS consumer panic recovered", "queue", queue, "panic", r)
+		}
+	}()
+
+	slog.Info("Starting SQS consumption loop", "queue", queue, "wait_time", c.cfg.WaitTime)
+
+	queueURL := c.queueURLs[queue]
+	for {
+		select {
+		case <-c.stopChan:
+			slog.Info("SQS consumer stop signal received, exiting", "queue", queue)
+			return
+		default:
+		}
+
+		if !c.WaitResumed() {
+			continue
+		}
+
+		out, err := c.client.ReceiveMessage(c.receiveCtx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(queueURL),
+			MaxNumberOfMessages:         int32(c.cfg.BatchSize),
+			WaitTimeSeconds:             int32(c.cfg.WaitTime / time.Second),
+			VisibilityTimeout:           int32(c.cfg.VisibilityTimeout / time.Second),
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameApproximateReceiveCount},
+			MessageAttributeNames:       []string{"All"},
+		})
+		if err != nil {
+			if c.receiveCtx.Err() != nil {
+				continue
+			}
+			slog.Error("Error receiving SQS messages", "queue", queue, "error", err)
+			c.sleep(time.Second)
+			continue
+		}
+		if len(out.Messages) == 0 {
+			continue
+		}
+		for range out.Messages {
+			metrics.IngestMessageConsumed(backend, queue)
+		}
+
+		c.inFlight.Add(int64(len(out.Messages)))
+		c.storeBatch(queue, out.Messages)
+		c.inFlight.Add(-int64(len(out.Messages)))
+	}
+}
+
+// storeBatch passes a queue's messages through its pipeline, deleting them once
+// stored. Messages the pipeline rejects are deleted too, since they can never
+// be stored. If processing fails the rest are left to reappear after a delay,
+// so the queue's redrive policy moves those that keep failing to its dead
+// letter queue; while storage is unavailable they are held, without using up
+// receives, until it is back. Their visibility timeout is extended meanwhile
+func (c *Consumer) storeBatch(queue string, messages []types.Message) {
+	queueURL := c.queueURLs[queue]
+	stopExtending := c.extendVisibility(queue, messages)
+	defer stopExtending()
+
+	rejected := make(map[string]bool)
+	for {
+		events := make([]*ingest.Event, 0, len(messages))
+		sources := make(map[*ingest.Event]types.Message, len(messages))
+		for _, message := range messages {
+			if rejected[aws.ToString(message.MessageId)] {
+				continue
+			}
+			payload, requestID := unwrap(message)
+			e := ingest.NewEvent(messageContext(requestID), queue, payload)
+			events = append(events, e)
+			sources[e] = message
+		}
+
+		var done []types.Message
+		err := c.pipelines[queue].Run(context.Background(), events, func(e *ingest.Event) error {
+			rejected[aws.ToString(sources[e].MessageId)] = true
+			metrics.IngestRejected(backend, queue, e.Rejection().Reason)
+			slog.WarnContext(e.Context(), "Deleting unprocessable SQS message", "queue", queue, "reason", e.Rejection().Reason, "error", e.Rejection().Err)
+			done = append(done, sources[e])
+			return nil
+		})
+		if err == nil {
+			for _, e := range events {
+				if !rejected[aws.ToString(sources[e].MessageId)] {
+					done = append(done, sources[e])
+				}
+			}
+			c.delete(queue, queueURL, done)
+			return
+		}
+		c.delete(queue, queueURL, done)
+
+		if errors.Is(err, store.ErrUnavailable) {
+			if c.WaitAvailable(context.Background(), err) {
+				continue
+			}
+			// Stopped; hand the messages straight back to the queue
+			c.changeVisibility(queue, queueURL, messagesOf(events, sources), func(types.Message) time.Duration { return 0 })
+			return
+		}
+
+		slog.Error("Error processing SQS batch", "queue", queue, "messages", len(events), "error", err)
+		for range events {
+			metrics.IngestProcessingFailed(backend, queue)
+		}
+		c.changeVisibility(queue, queueURL, messagesOf(events, sources), c.retryDelay)
+		return
+	}
+}
+
+// extendVisibility keeps messages hidden from other consumers until the
+// returned function is called, renewing their visibility timeout halfway through
+func (c *Consumer) extendVisibility(queue string, messages []types.Message) func() {
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(c.cfg.VisibilityTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.changeVisibility(queue, c.queueURLs[queue], messages, func(types.Message) time.Duration { return c.cfg.VisibilityTimeout })
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-finished
+	}
+}
+
+// retryDelay is how long a message that failed processing stays invisible
+func (c *Consumer) retryDelay(message types.Message) time.Duration {
+	receives, err := strconv.Atoi(message.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	if err != nil || receives < 1 {
+		receives = 1
+	}
+	return min(retryBase<<min(receives-1, 16), c.cfg.VisibilityTimeout)
+}
+
+// delete removes stored or unprocessable messages from the queue
+func (c *Consumer) delete(queue, queueURL string, messages []types.Message) {
+	if len(messages) == 0 {
+		return
+	}
+	entries := make([]types.DeleteMessageBatchRequestEntry, len(messages))
+	for i, message := range messages {
+		entries[i] = types.DeleteMessageBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), ReceiptHandle: message.ReceiptHandle}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	out, err := c.client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{QueueUrl: aws.String(queueURL), Entries: entries})
+	if err != nil {
+		// They reappear once their visibility timeout expires, and are then skipped as duplicates
+		slog.Warn("Failed to delete SQS messages", "queue", queue, "messages", len(messages), "error", err)
+		return
+	}
+	for _, failed := range out.Failed {
+		slog.Warn("Failed to delete SQS message", "queue", queue, "code", aws.ToString(failed.Code), "error", aws.ToString(failed.Message))
+	}
+}
+
+// changeVisibility sets how much longer each message stays hidden from consumers
+func (c *Consumer) changeVisibility(queue, queueURL string, messages []types.Message, timeout func(types.Message) time.Duration) {
+	if len(messages) == 0 {
+		return
+	}
+	entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, len(messages))
+	for i, message := range messages {
+		entries[i] = types.ChangeMessageVisibilityBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			ReceiptHandle:     message.ReceiptHandle,
+			VisibilityTimeout: int32(timeout(message) / time.Second),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	out, err := c.client.ChangeMessageVisibilityBatch(ctx, &sqs.ChangeMessageVisibilityBatchInput{QueueUrl: aws.String(queueURL), Entries: entries})
+	if err != nil {
+		slog.Warn("Failed to change SQS message visibility", "queue", queue, "messages", len(messages), "error", err)
+		return
+	}
+	for _, failed := range out.Failed {
+		slog.Warn("Failed to change SQS message visibility", "queue", queue, "code", aws.ToString(failed.Code), "error", aws.ToString(failed.Message))
+	}
+}
+
+// sleep waits for delay, reporting false if the consumer is stopped first
+func (c *Consumer) sleep(delay time.Duration) bool {
+	select {
+	case <-time.After(delay):
+		return true
+	case <-c.stopChan:
+		return false
+	}
+}
+
+// messagesOf returns the messages events were made from
+func messagesOf(events []*ingest.Event, sources map[*ingest.Event]types.Message) []types.Message {
+	messages := make([]types.Message, len(events))
+	for i, e := range events {
+		messages[i] = sources[e]
+	}
+	return messages
+}
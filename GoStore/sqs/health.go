@@ -0,0 +1,70 @@
+package sqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// MaxLag returns the most visible messages last seen waiting in any one queue
+func (c *Consumer) MaxLag() int64 {
+	return c.backlog.Load()
+}
+
+// InFlight returns the number of messages received and not yet stored
+func (c *Consumer) InFlight() int {
+	return int(c.inFlight.Load())
+}
+
+// Check reports whether the polling loop of every queue is running, SQS is
+// reachable, and no queue holds more than maxLag visible messages (zero
+// disables the backlog check)
+func (c *Consumer) Check(ctx context.Context, maxLag int64) error {
+	if int(c.running.Load()) < len(c.queueURLs) {
+		return errors.New("consumer loop is not running")
+	}
+
+	var backlog int64
+	for _, queue := range slices.Sorted(maps.Keys(c.queueURLs)) {
+		out, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+			QueueUrl:       aws.String(c.queueURLs[queue]),
+			AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get attributes of SQS queue %s: %w", queue, err)
+		}
+		visible, _ := strconv.ParseInt(out.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)], 10, 64)
+		backlog = max(backlog, visible)
+	}
+	c.backlog.Store(backlog)
+	if maxLag > 0 && backlog > maxLag {
+		return fmt.Errorf("queue backlog %d exceeds threshold %d", backlog, maxLag)
+	}
+	return nil
+}
+
+// Stop gracefully shuts down the consumer, letting the batches in progress be
+// stored and deleted first
+func (c *Consumer) Stop() error {
+	slog.Info("Stopping SQS consumer")
+	close(c.stopChan)
+	c.cancelReceive()
+
+	select {
+	case <-c.done:
+	case <-time.After(stopTimeout):
+		// Messages not yet deleted reappear once their visibility timeout expires
+		slog.Warn("SQS consumer did not finish its batches before shutdown")
+	}
+	slog.Info("SQS consumer stopped successfully")
+	return nil
+}
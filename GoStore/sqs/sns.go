@@ -0,0 +1,50 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/ramG-reddy/sms-store/requestid"
+)
+
+// snsNotification is the envelope SNS wraps messages in when delivering them
+// to a queue without raw message delivery
+type snsNotification struct {
+	Type              string `json:"Type"`
+	TopicArn          string `json:"TopicArn"`
+	Message           string `json:"Message"`
+	MessageAttributes map[string]struct {
+		Value string `json:"Value"`
+	} `json:"MessageAttributes"`
+}
+
+// unwrap returns the SMS event of a message, taken out of its SNS envelope if
+// it has one, along with the X-Request-ID the publisher set as a message
+// attribute, if any
+func unwrap(message types.Message) ([]byte, string) {
+	body := []byte(aws.ToString(message.Body))
+	requestID := ""
+	if attr, ok := message.MessageAttributes[requestid.Header]; ok && attr.StringValue != nil {
+		requestID = *attr.StringValue
+	}
+
+	var notification snsNotification
+	if json.Unmarshal(body, &notification) != nil || notification.Type != "Notification" || notification.TopicArn == "" {
+		return body, requestID
+	}
+	if attr, ok := notification.MessageAttributes[requestid.Header]; ok {
+		requestID = attr.Value
+	}
+	return []byte(notification.Message), requestID
+}
+
+// messageContext scopes processing of a message to the publisher's request ID,
+// or to a new request ID when the publisher didn't set one
+func messageContext(id string) context.Context {
+	if !requestid.IsValid(id) {
+		id = requestid.New()
+	}
+	return requestid.WithID(context.Background(), id)
+}
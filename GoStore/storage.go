@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/config"
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/fieldcrypt"
+	"github.com/ramG-reddy/sms-store/media"
+	"github.com/ramG-reddy/sms-store/phonenumber"
+	"github.com/ramG-reddy/sms-store/pseudonym"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+// storage is the message store of the configured backend, with the concrete
+// backends whose health is checked or settings reloaded; nil when not in use
+type storage struct {
+	store     store.Store
+	postgres  *store.PostgresStore
+	cassandra *store.CassandraStore
+}
+
+// openStorage connects to the storage backend selected by cfg, behind the
+// circuit breaker when one is configured. The mongo backend stores messages in m
+func openStorage(cfg *config.Config, m *db.Mongo) (*storage, error) {
+	s := &storage{}
+	var err error
+	switch cfg.StorageBackend {
+	case store.BackendPostgres:
+		// Applies any pending schema migrations before serving
+		s.postgres, err = store.NewPostgresStore(context.Background(), cfg.PostgresURL, cfg.PostgresMaxConns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize PostgreSQL storage: %w", err)
+		}
+		s.store = s.postgres
+	case store.BackendCassandra:
+		// RETENTION_DAYS becomes a TTL on every row
+		s.cassandra, err = store.NewCassandraStore(store.CassandraConfig{
+			Hosts:       cfg.CassandraHosts,
+			Keyspace:    cfg.CassandraKeyspace,
+			Consistency: cfg.CassandraConsistency,
+			Username:    cfg.CassandraUsername,
+			Password:    cfg.CassandraPassword,
+			Retention:   time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Cassandra storage: %w", err)
+		}
+		s.store = s.cassandra
+	case store.BackendMemory:
+		s.store = store.NewMemoryStore()
+	default:
+		s.store = store.NewMongoStore(m)
+	}
+	if cfg.StorageBreakerThreshold > 0 {
+		// Fails requests fast with 503, and stalls Kafka intake, while the backend is down
+		s.store = store.WithBreaker(s.store, cfg.StorageBreakerThreshold, time.Duration(cfg.StorageBreakerOpenSeconds)*time.Second)
+	}
+	slog.Info("Message storage initialized", "backend", cfg.StorageBackend)
+	return s, nil
+}
+
+// Close disconnects from the backend
+func (s *storage) Close() {
+	if s.postgres != nil {
+		s.postgres.Close()
+	}
+	if s.cassandra != nil {
+		s.cassandra.Close()
+	}
+}
+
+// configureRecords applies the settings that decide how records are written, so
+// every writer stores them alike: field encryption, phone number normalization
+// and pseudonymization, and attachment storage, in GridFS on m unless kept in
+// S3. It returns the pseudonym hasher and phone number normalizer, nil when
+// disabled
+func configureRecords(cfg *config.Config, m *db.Mongo, smsService *services.SMSService) (*pseudonym.Hasher, *phonenumber.Normalizer, error) {
+	if cfg.EncryptionKeys != "" {
+		ids, keys, err := fieldcrypt.ParseKeys(cfg.EncryptionKeys)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid encryption keys: %w", err)
+		}
+		activeKeyID := cfg.EncryptionActiveKeyID
+		if activeKeyID == "" {
+			activeKeyID = ids[0]
+		}
+		keyring, err := fieldcrypt.NewKeyring(keys, activeKeyID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize encryption: %w", err)
+		}
+		smsService.EnableEncryption(keyring, cfg.EncryptPhoneNumbers)
+		slog.Info("Message encryption enabled", "active_key_id", activeKeyID, "keys", len(ids), "phone_numbers", cfg.EncryptPhoneNumbers)
+	}
+
+	var numbers *phonenumber.Normalizer
+	if cfg.NormalizePhoneNumbers {
+		var err error
+		if numbers, err = phonenumber.NewNormalizer(cfg.PhoneDefaultRegion); err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize phone number normalization: %w", err)
+		}
+		smsService.EnablePhoneNormalization(numbers)
+		slog.Info("Phone number normalization enabled", "default_region", cfg.PhoneDefaultRegion)
+	}
+
+	var pseudonyms *pseudonym.Hasher
+	if cfg.PseudonymizePhoneNumbers {
+		pseudonyms = pseudonym.NewHasher(cfg.PseudonymPepper)
+		smsService.EnablePseudonyms(pseudonyms)
+		slog.Info("Phone number pseudonymization enabled")
+	}
+
+	var mediaStore media.Store
+	var err error
+	if cfg.MediaBackend == media.BackendS3 {
+		mediaStore, err = media.NewS3Store(context.Background(), media.S3Config{
+			Bucket:   cfg.MediaS3Bucket,
+			Prefix:   cfg.MediaS3Prefix,
+			Endpoint: cfg.MediaS3Endpoint,
+		})
+	} else {
+		mediaStore, err = media.NewGridFSStore(m.Database, cfg.MediaGridFSBucket)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize media storage: %w", err)
+	}
+	smsService.EnableMedia(mediaStore)
+	slog.Info("Media storage initialized", "backend", cfg.MediaBackend)
+	return pseudonyms, numbers, nil
+}
@@ -0,0 +1,39 @@
+package store
+
+import "github.com/ramG-reddy/sms-store/models"
+
+// matchesAudit reports whether audit passes the filters of query, for the
+// backends that filter audit records in memory
+func matchesAudit(audit *models.AuditRecord, query *models.AuditQuery) bool {
+	if query.Actor != "" && audit.Actor != query.Actor {
+		return false
+	}
+	if query.UserID != "" && audit.UserID != query.UserID {
+		return false
+	}
+	if query.Action != "" && audit.Action != query.Action {
+		return false
+	}
+	if query.TargetID != "" && audit.TargetID != query.TargetID {
+		return false
+	}
+	if !query.Since.IsZero() && audit.CreatedAt.Before(query.Since) {
+		return false
+	}
+	if !query.Until.IsZero() && !audit.CreatedAt.Before(query.Until) {
+		return false
+	}
+	return true
+}
+
+// pageAudit returns the page of the matched records selected by query
+func pageAudit(records []*models.AuditRecord, query *models.AuditQuery) []*models.AuditRecord {
+	if query.Skip >= int64(len(records)) {
+		return nil
+	}
+	records = records[query.Skip:]
+	if query.Limit > 0 && query.Limit < int64(len(records)) {
+		records = records[:query.Limit]
+	}
+	return records
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+	"github.com/ramG-reddy/sms-store/breaker"
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/metrics"
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrUnavailable is returned without calling the backend while its circuit
+// breaker is open. The error also wraps the *breaker.OpenError saying when to retry
+var ErrUnavailable = errors.New("storage unavailable")
+
+// breakerDependency names the storage breaker in logs and metrics
+const breakerDependency = "storage"
+
+// breakerStore fails calls fast while the backend is down, instead of letting
+// each one wait out its timeout
+type breakerStore struct {
+	next    Store
+	breaker *breaker.Breaker
+}
+
+// WithBreaker wraps next in a circuit breaker that opens after threshold
+// consecutive backend failures and lets a probe through every openFor
+func WithBreaker(next Store, threshold int, openFor time.Duration) Store {
+	b := breaker.New(breakerDependency, threshold, openFor, isBackendFailure)
+	b.OnStateChange(func(state breaker.State) {
+		metrics.SetCircuitBreakerState(breakerDependency, int(state))
+	})
+	metrics.SetCircuitBreakerState(breakerDependency, int(breaker.Closed))
+	return &breakerStore{next: next, breaker: b}
+}
+
+// isBackendFailure reports whether err means the backend is unreachable or
+// overwhelmed, as opposed to rejecting the call itself
+func isBackendFailure(err error) bool {
+	var netErr net.Error
+	var unavailable *gocql.RequestErrUnavailable
+	var readTimeout *gocql.RequestErrReadTimeout
+	var writeTimeout *gocql.RequestErrWriteTimeout
+	// A client that went away says nothing about the backend
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	return db.IsTransient(err) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, gocql.ErrNoConnections) ||
+		errors.As(err, &netErr) ||
+		errors.As(err, &unavailable) ||
+		errors.As(err, &readTimeout) ||
+		errors.As(err, &writeTimeout)
+}
+
+// allow asks the breaker to let a call through
+func (s *breakerStore) allow() error {
+	if err := s.breaker.Allow(); err != nil {
+		metrics.CircuitBreakerRejected(breakerDependency)
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return nil
+}
+
+// guard runs call through the breaker
+func guard(s *breakerStore, call func() error) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	err := call()
+	s.breaker.Done(err)
+	return err
+}
+
+// guardValue runs call through the breaker, returning its result
+func guardValue[T any](s *breakerStore, call func() (T, error)) (T, error) {
+	if err := s.allow(); err != nil {
+		var zero T
+		return zero, err
+	}
+	result, err := call()
+	s.breaker.Done(err)
+	return result, err
+}
+
+func (s *breakerStore) InsertMessage(ctx context.Context, record *models.SMSRecord) error {
+	return guard(s, func() error { return s.next.InsertMessage(ctx, record) })
+}
+
+func (s *breakerStore) InsertMessages(ctx context.Context, records []*models.SMSRecord) (InsertResult, error) {
+	return guardValue(s, func() (InsertResult, error) { return s.next.InsertMessages(ctx, records) })
+}
+
+func (s *breakerStore) FindMessages(ctx context.Context, tenantID string, query *models.MessageQuery) ([]*models.SMSRecord, error) {
+	return guardValue(s, func() ([]*models.SMSRecord, error) { return s.next.FindMessages(ctx, tenantID, query) })
+}
+
+// StreamMessages does not count errors returned by fn, such as a client going
+// away mid-stream, against the backend
+func (s *breakerStore) StreamMessages(ctx context.Context, tenantID string, query *models.MessageQuery, fn func(*models.SMSRecord) error) error {
+	var fnErr error
+	err := guard(s, func() error {
+		err := s.next.StreamMessages(ctx, tenantID, query, func(record *models.SMSRecord) error {
+			fnErr = fn(record)
+			return fnErr
+		})
+		if fnErr != nil && errors.Is(err, fnErr) {
+			return nil
+		}
+		return err
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+func (s *breakerStore) FindMessagesByPhoneNumber(ctx context.Context, tenantID, phoneNumber string, skip, limit int64) ([]*models.SMSRecord, error) {
+	return guardValue(s, func() ([]*models.SMSRecord, error) {
+		return s.next.FindMessagesByPhoneNumber(ctx, tenantID, phoneNumber, skip, limit)
+	})
+}
+
+func (s *breakerStore) Conversations(ctx context.Context, tenantID, userID string, skip, limit int64) ([]*models.Conversation, error) {
+	return guardValue(s, func() ([]*models.Conversation, error) {
+		return s.next.Conversations(ctx, tenantID, userID, skip, limit)
+	})
+}
+
+func (s *breakerStore) CountMessages(ctx context.Context, tenantID string, query *models.MessageQuery) (int64, error) {
+	return guardValue(s, func() (int64, error) { return s.next.CountMessages(ctx, tenantID, query) })
+}
+
+func (s *breakerStore) MessageStats(ctx context.Context, tenantID string, query *models.MessageQuery) (*models.MessageStats, error) {
+	return guardValue(s, func() (*models.MessageStats, error) { return s.next.MessageStats(ctx, tenantID, query) })
+}
+
+func (s *breakerStore) FindDuplicates(ctx context.Context, tenantID string, since, until time.Time, limit int64) ([]*models.DuplicateGroup, error) {
+	return guardValue(s, func() ([]*models.DuplicateGroup, error) {
+		return s.next.FindDuplicates(ctx, tenantID, since, until, limit)
+	})
+}
+
+func (s *breakerStore) DeliveryStats(ctx context.Context, tenantID string, query *models.DeliveryQuery) (*models.DeliveryStats, error) {
+	return guardValue(s, func() (*models.DeliveryStats, error) { return s.next.DeliveryStats(ctx, tenantID, query) })
+}
+
+func (s *breakerStore) GetMessage(ctx context.Context, tenantID string, id primitive.ObjectID) (*models.SMSRecord, error) {
+	return guardValue(s, func() (*models.SMSRecord, error) { return s.next.GetMessage(ctx, tenantID, id) })
+}
+
+func (s *breakerStore) ClaimRequeue(ctx context.Context, tenantID string, id primitive.ObjectID, max int) (int, error) {
+	return guardValue(s, func() (int, error) { return s.next.ClaimRequeue(ctx, tenantID, id, max) })
+}
+
+func (s *breakerStore) UpdateStatus(ctx context.Context, messageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return guardValue(s, func() (*models.SMSRecord, error) { return s.next.UpdateStatus(ctx, messageID, change) })
+}
+
+func (s *breakerStore) UpdateStatusByProviderID(ctx context.Context, tenantID, providerMessageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return guardValue(s, func() (*models.SMSRecord, error) {
+		return s.next.UpdateStatusByProviderID(ctx, tenantID, providerMessageID, change)
+	})
+}
+
+func (s *breakerStore) DeleteUserMessages(ctx context.Context, tenantID string, userIDs []string, audit *models.AuditRecord) (int64, error) {
+	return guardValue(s, func() (int64, error) { return s.next.DeleteUserMessages(ctx, tenantID, userIDs, audit) })
+}
+
+func (s *breakerStore) InsertAuditRecord(ctx context.Context, audit *models.AuditRecord) error {
+	return guard(s, func() error { return s.next.InsertAuditRecord(ctx, audit) })
+}
+
+func (s *breakerStore) FindAuditRecords(ctx context.Context, tenantID string, query *models.AuditQuery) ([]*models.AuditRecord, error) {
+	return guardValue(s, func() ([]*models.AuditRecord, error) { return s.next.FindAuditRecords(ctx, tenantID, query) })
+}
+
+func (s *breakerStore) FindMessagesOlderThan(ctx context.Context, cutoff time.Time, tenants models.TenantFilter, limit int64) ([]*models.SMSRecord, error) {
+	return guardValue(s, func() ([]*models.SMSRecord, error) { return s.next.FindMessagesOlderThan(ctx, cutoff, tenants, limit) })
+}
+
+func (s *breakerStore) FindMessagesInIDRange(ctx context.Context, after, before primitive.ObjectID, limit int64) ([]*models.SMSRecord, error) {
+	return guardValue(s, func() ([]*models.SMSRecord, error) { return s.next.FindMessagesInIDRange(ctx, after, before, limit) })
+}
+
+func (s *breakerStore) DeleteMessages(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	return guardValue(s, func() (int64, error) { return s.next.DeleteMessages(ctx, ids) })
+}
+
+func (s *breakerStore) PendingStoredEvents(ctx context.Context, limit int64) ([]*models.SMSRecord, error) {
+	return guardValue(s, func() ([]*models.SMSRecord, error) { return s.next.PendingStoredEvents(ctx, limit) })
+}
+
+func (s *breakerStore) ClearStoredEventsPending(ctx context.Context, ids []primitive.ObjectID) error {
+	return guard(s, func() error { return s.next.ClearStoredEventsPending(ctx, ids) })
+}
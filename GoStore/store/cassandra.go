@@ -0,0 +1,876 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gocql "github.com/apache/cassandra-gocql-driver/v2"
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// cassandraSchema creates the store's tables in the configured keyspace
+// messages_by_user holds the records, partitioned by user and clustered newest
+// first; the other tables look records up by their IDs, and messages_by_phone
+// by phone number, newest first. audit_by_tenant holds each tenant's audit trail,
+// newest first; audit_log only holds erasures recorded by earlier versions
+var cassandraSchema = []string{
+	`CREATE TABLE IF NOT EXISTS messages_by_user (
+		tenant_id text, user_id text, created_at timestamp, id text,
+		message_id text, provider_message_id text, phone_number text, message text,
+		status text, direction text, status_history list<text>, updated_at timestamp, media list<text>,
+		carrier text, country_code text, sender_id text, segment_count int,
+		encoding text, script text, language text, flags list<text>, sender_type text,
+		raw_user_id text, raw_phone_number text, dial_code text,
+		PRIMARY KEY ((tenant_id, user_id), created_at, id)
+	) WITH CLUSTERING ORDER BY (created_at DESC, id DESC)`,
+	`CREATE TABLE IF NOT EXISTS messages_by_id (
+		id text PRIMARY KEY, tenant_id text, user_id text, created_at timestamp, requeue_count int
+	)`,
+	`CREATE TABLE IF NOT EXISTS messages_by_message_id (
+		message_id text PRIMARY KEY, id text, tenant_id text, user_id text, created_at timestamp
+	)`,
+	`CREATE TABLE IF NOT EXISTS messages_by_provider_id (
+		provider_message_id text PRIMARY KEY, id text, tenant_id text, user_id text, created_at timestamp
+	)`,
+	`CREATE TABLE IF NOT EXISTS messages_by_phone (
+		tenant_id text, phone_number text, created_at timestamp, id text, user_id text,
+		PRIMARY KEY ((tenant_id, phone_number), created_at, id)
+	) WITH CLUSTERING ORDER BY (created_at DESC, id DESC)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		tenant_id text, user_id text, created_at timestamp, action text, result_count bigint, remote_addr text,
+		PRIMARY KEY ((tenant_id, user_id), created_at)
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_by_tenant (
+		tenant_id text, created_at timestamp, id text, action text, actor text, auth_method text,
+		endpoint text, filters map<text, text>, user_id text, phone_number text, target_id text,
+		result_count bigint, remote_addr text,
+		PRIMARY KEY ((tenant_id), created_at, id)
+	) WITH CLUSTERING ORDER BY (created_at DESC, id DESC)`,
+}
+
+// cassandraAddedColumns are the columns added to cassandraSchema's tables after
+// their creation, which NewCassandraStore adds to tables created without them
+var cassandraAddedColumns = []struct{ table, column, kind string }{
+	{"messages_by_user", "media", "list<text>"},
+	{"messages_by_user", "carrier", "text"},
+	{"messages_by_user", "country_code", "text"},
+	{"messages_by_user", "sender_id", "text"},
+	{"messages_by_user", "segment_count", "int"},
+	{"messages_by_user", "encoding", "text"},
+	{"messages_by_user", "script", "text"},
+	{"messages_by_user", "language", "text"},
+	{"messages_by_user", "flags", "list<text>"},
+	{"messages_by_user", "sender_type", "text"},
+	{"messages_by_user", "raw_user_id", "text"},
+	{"messages_by_user", "raw_phone_number", "text"},
+	{"messages_by_user", "dial_code", "text"},
+	{"messages_by_id", "requeue_count", "int"},
+}
+
+// cassandraRecordColumns are the messages_by_user columns in the order scanCassandraRecord reads them
+const cassandraRecordColumns = `tenant_id, user_id, created_at, id, message_id, provider_message_id,
+	phone_number, message, status, direction, status_history, updated_at, media,
+	carrier, country_code, sender_id, segment_count, encoding, script, language, flags, sender_type,
+	raw_user_id, raw_phone_number, dial_code`
+
+// cassandraWriteConcurrency bounds the records InsertMessages writes at once
+const cassandraWriteConcurrency = 64
+
+// errCassandraUnsupported is returned by the operations that would need a scan of every partition
+var errCassandraUnsupported = fmt.Errorf("cassandra storage: %w", errors.ErrUnsupported)
+
+// CassandraConfig configures the Cassandra store
+type CassandraConfig struct {
+	Hosts       []string
+	Keyspace    string // must already exist, so operators choose its replication
+	Consistency string // e.g. LOCAL_QUORUM
+	Username    string // empty disables authentication
+	Password    string
+	Retention   time.Duration // records expire this long after created_at; zero keeps them
+}
+
+// CassandraStore keeps records in Cassandra or ScyllaDB for write-heavy deployments
+//
+// Every write is an idempotent upsert: record IDs are derived from message_id and
+// inserts are timestamped with created_at, so a redelivered message rewrites the same
+// row without undoing later status updates, and cannot resurrect an erased one. In
+// exchange redeliveries are not detected as duplicates. Listing old messages and the
+// stored events outbox would need full table scans and are not supported, so
+// archival and the stored events topic cannot be used with this backend
+type CassandraStore struct {
+	session   *gocql.Session
+	retention atomic.Int64 // time.Duration
+}
+
+// NewCassandraStore connects to the cluster and creates any missing tables
+func NewCassandraStore(cfg CassandraConfig) (*CassandraStore, error) {
+	consistency, err := gocql.ParseConsistencyWrapper(cfg.Consistency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Cassandra consistency: %w", err)
+	}
+
+	cluster := gocql.NewCluster(cfg.Hosts...)
+	cluster.Keyspace = cfg.Keyspace
+	cluster.Consistency = consistency
+	cluster.Timeout = 5 * time.Second
+	if cfg.Username != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: cfg.Username, Password: cfg.Password}
+	}
+
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Cassandra: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, stmt := range cassandraSchema {
+		if err := session.Query(stmt).ExecContext(ctx); err != nil {
+			session.Close()
+			return nil, fmt.Errorf("failed to create Cassandra tables: %w", err)
+		}
+	}
+	for _, added := range cassandraAddedColumns {
+		if err := addCassandraColumn(ctx, session, cfg.Keyspace, added.table, added.column, added.kind); err != nil {
+			session.Close()
+			return nil, err
+		}
+	}
+
+	c := &CassandraStore{session: session}
+	c.SetRetention(cfg.Retention)
+	return c, nil
+}
+
+// addCassandraColumn adds a column to a table unless it already has it
+func addCassandraColumn(ctx context.Context, session *gocql.Session, keyspace, table, column, kind string) error {
+	var name string
+	err := session.Query(`SELECT column_name FROM system_schema.columns
+		WHERE keyspace_name = ? AND table_name = ? AND column_name = ?`, keyspace, table, column).ScanContext(ctx, &name)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gocql.ErrNotFound) {
+		return fmt.Errorf("failed to look up Cassandra column %s.%s: %w", table, column, err)
+	}
+	if err := session.Query(fmt.Sprintf(`ALTER TABLE %s ADD %s %s`, table, column, kind)).ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to add Cassandra column %s.%s: %w", table, column, err)
+	}
+	slog.Info("Added Cassandra column", "table", table, "column", column)
+	return nil
+}
+
+// Ping checks that a coordinator answers queries
+func (c *CassandraStore) Ping(ctx context.Context) error {
+	return c.session.Query(`SELECT release_version FROM system.local`).ExecContext(ctx)
+}
+
+// Close closes the session
+func (c *CassandraStore) Close() {
+	c.session.Close()
+}
+
+// recordID derives the ID of a record from its message_id, so every delivery of a
+// message writes the same row. The ObjectID layout is kept: created_at seconds, then
+// eight bytes of the message_id's hash
+func recordID(record *models.SMSRecord) primitive.ObjectID {
+	if record.MessageID == "" {
+		return primitive.NewObjectID()
+	}
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[:4], uint32(record.CreatedAt.Unix()))
+	hash := sha256.Sum256([]byte(record.MessageID))
+	copy(id[4:], hash[:8])
+	return id
+}
+
+// SetRetention changes the retention of records written from now on; records
+// already written keep the TTL they were written with
+func (c *CassandraStore) SetRetention(retention time.Duration) {
+	c.retention.Store(int64(retention))
+}
+
+// ttl returns the seconds a record created at createdAt has left under the retention
+// policy, zero for no expiry, and false if it has already expired
+func (c *CassandraStore) ttl(createdAt time.Time) (int, bool) {
+	retention := time.Duration(c.retention.Load())
+	if retention <= 0 {
+		return 0, true
+	}
+	remaining := int(time.Until(createdAt.Add(retention)).Seconds())
+	return remaining, remaining > 0
+}
+
+// InsertMessage upserts record and its lookup rows
+func (c *CassandraStore) InsertMessage(ctx context.Context, record *models.SMSRecord) error {
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := c.insert(insertCtx, record); err != nil {
+		return fmt.Errorf("failed to insert SMS record: %w", err)
+	}
+	return nil
+}
+
+// insert writes the record row before its lookup rows, so a lookup always finds a row
+func (c *CassandraStore) insert(ctx context.Context, record *models.SMSRecord) error {
+	if record.ID.IsZero() {
+		record.ID = recordID(record)
+	}
+	ttl, ok := c.ttl(record.CreatedAt)
+	if !ok {
+		// Older than the retention period: stored and expired at once
+		return nil
+	}
+
+	history := make([]string, len(record.StatusHistory))
+	for i, change := range record.StatusHistory {
+		data, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		history[i] = string(data)
+	}
+	var media []string
+	for _, attachment := range record.Media {
+		data, err := json.Marshal(attachment)
+		if err != nil {
+			return err
+		}
+		media = append(media, string(data))
+	}
+	var updatedAt *time.Time
+	if !record.UpdatedAt.IsZero() {
+		updatedAt = &record.UpdatedAt
+	}
+
+	// Timestamped with created_at, so a later status update or erasure always wins
+	timestamp := record.CreatedAt.UnixMicro()
+	id := record.ID.Hex()
+
+	err := c.session.Query(`INSERT INTO messages_by_user (`+cassandraRecordColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) USING TTL ?`,
+		record.TenantID, record.UserID, record.CreatedAt, id, record.MessageID, record.ProviderMessageID,
+		record.PhoneNumber, record.Message, record.Status, record.Direction, history, updatedAt, media,
+		record.Carrier, record.CountryCode, record.SenderID, record.SegmentCount,
+		record.Encoding, record.Script, record.Language, record.Flags, record.SenderType,
+		record.RawUserID, record.RawPhoneNumber, record.DialCode, ttl,
+	).WithTimestamp(timestamp).Idempotent(true).ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	lookups := []struct {
+		stmt string
+		key  string
+	}{
+		{`INSERT INTO messages_by_id (id, tenant_id, user_id, created_at) VALUES (?, ?, ?, ?) USING TTL ?`, id},
+		{`INSERT INTO messages_by_message_id (message_id, tenant_id, user_id, created_at, id) VALUES (?, ?, ?, ?, ?) USING TTL ?`, record.MessageID},
+		{`INSERT INTO messages_by_provider_id (provider_message_id, tenant_id, user_id, created_at, id) VALUES (?, ?, ?, ?, ?) USING TTL ?`, record.ProviderMessageID},
+	}
+	for i, lookup := range lookups {
+		if lookup.key == "" {
+			continue
+		}
+		args := []any{lookup.key, record.TenantID, record.UserID, record.CreatedAt}
+		if i > 0 {
+			args = append(args, id)
+		}
+		args = append(args, ttl)
+		if err := c.session.Query(lookup.stmt, args...).WithTimestamp(timestamp).Idempotent(true).ExecContext(ctx); err != nil {
+			return err
+		}
+	}
+	return c.session.Query(`INSERT INTO messages_by_phone (tenant_id, phone_number, created_at, id, user_id) VALUES (?, ?, ?, ?, ?) USING TTL ?`,
+		record.TenantID, record.PhoneNumber, record.CreatedAt, id, record.UserID, ttl,
+	).WithTimestamp(timestamp).Idempotent(true).ExecContext(ctx)
+}
+
+// InsertMessages upserts the records concurrently
+// Writes are idempotent, so on error the caller can retry every record
+func (c *CassandraStore) InsertMessages(ctx context.Context, records []*models.SMSRecord) (InsertResult, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	slots := make(chan struct{}, cassandraWriteConcurrency)
+	for _, record := range records {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			if err := c.insert(ctx, record); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return InsertResult{}, fmt.Errorf("failed to insert SMS records: %w", firstErr)
+	}
+	return InsertResult{}, nil
+}
+
+// scanCassandraRecord reads a row of cassandraRecordColumns
+func scanCassandraRecord(scan func(dest ...any) bool) (*models.SMSRecord, bool, error) {
+	var (
+		record    models.SMSRecord
+		id        string
+		history   []string
+		updatedAt time.Time
+		media     []string
+	)
+	if !scan(&record.TenantID, &record.UserID, &record.CreatedAt, &id, &record.MessageID, &record.ProviderMessageID,
+		&record.PhoneNumber, &record.Message, &record.Status, &record.Direction, &history, &updatedAt, &media,
+		&record.Carrier, &record.CountryCode, &record.SenderID, &record.SegmentCount,
+		&record.Encoding, &record.Script, &record.Language, &record.Flags, &record.SenderType,
+		&record.RawUserID, &record.RawPhoneNumber, &record.DialCode) {
+		return nil, false, nil
+	}
+
+	var err error
+	if record.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, false, fmt.Errorf("invalid record ID %q: %w", id, err)
+	}
+	for _, entry := range history {
+		var change models.StatusChange
+		if err := json.Unmarshal([]byte(entry), &change); err != nil {
+			return nil, false, fmt.Errorf("invalid status history of %s: %w", id, err)
+		}
+		record.StatusHistory = append(record.StatusHistory, change)
+	}
+	for _, entry := range media {
+		var attachment models.MediaAttachment
+		if err := json.Unmarshal([]byte(entry), &attachment); err != nil {
+			return nil, false, fmt.Errorf("invalid media of %s: %w", id, err)
+		}
+		record.Media = append(record.Media, attachment)
+	}
+	record.CreatedAt = record.CreatedAt.UTC()
+	if !updatedAt.IsZero() {
+		record.UpdatedAt = updatedAt.UTC()
+	}
+	return &record, true, nil
+}
+
+// eachMessage calls fn for each of the user's messages matching query, newest first,
+// applying the query's pagination. The status and phone number filters are applied
+// here rather than with ALLOW FILTERING; it only ever reads the user's own partition
+func (c *CassandraStore) eachMessage(ctx context.Context, tenantID string, query *models.MessageQuery, paginate bool, fn func(*models.SMSRecord) error) error {
+	stmt := `SELECT ` + cassandraRecordColumns + ` FROM messages_by_user WHERE tenant_id = ? AND user_id = ?`
+	args := []any{tenantID, query.UserID}
+	if !query.Since.IsZero() {
+		stmt += ` AND created_at >= ?`
+		args = append(args, query.Since)
+	}
+	if !query.Until.IsZero() {
+		stmt += ` AND created_at < ?`
+		args = append(args, query.Until)
+	}
+
+	iter := c.session.Query(stmt, args...).PageSize(500).IterContext(ctx)
+	var skipped, matched int64
+	for {
+		record, ok, err := scanCassandraRecord(iter.Scan)
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		if !ok {
+			break
+		}
+		if len(query.Statuses) > 0 && !slices.Contains(query.Statuses, record.Status) {
+			continue
+		}
+		if query.PhoneNumber != "" && record.PhoneNumber != query.PhoneNumber {
+			continue
+		}
+		if !query.MessageFilter.Matches(record) {
+			continue
+		}
+		if paginate && skipped < query.Skip {
+			skipped++
+			continue
+		}
+		if err := fn(record); err != nil {
+			iter.Close()
+			return err
+		}
+		matched++
+		if paginate && query.Limit > 0 && matched >= query.Limit {
+			break
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return fmt.Errorf("failed to query messages: %w", err)
+	}
+	return nil
+}
+
+// FindMessages reads one page of the user's partition
+func (c *CassandraStore) FindMessages(ctx context.Context, tenantID string, query *models.MessageQuery) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var records []*models.SMSRecord
+	err := c.eachSorted(queryCtx, tenantID, query, func(record *models.SMSRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// StreamMessages pages through the user's partition
+func (c *CassandraStore) StreamMessages(ctx context.Context, tenantID string, query *models.MessageQuery, fn func(*models.SMSRecord) error) error {
+	// No fixed timeout: the stream lives as long as the caller's context
+	return c.eachSorted(ctx, tenantID, query, fn)
+}
+
+// eachSorted is eachMessage in the query's sort order. The partition is
+// clustered newest first, so other sorts read every matching row and sort
+// them before paginating
+func (c *CassandraStore) eachSorted(ctx context.Context, tenantID string, query *models.MessageQuery, fn func(*models.SMSRecord) error) error {
+	if query.Sort.IsDefault() {
+		return c.eachMessage(ctx, tenantID, query, true, fn)
+	}
+
+	var records []*models.SMSRecord
+	err := c.eachMessage(ctx, tenantID, query, false, func(record *models.SMSRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	models.SortRecords(records, query.Sort)
+
+	if query.Skip >= int64(len(records)) {
+		return nil
+	}
+	records = records[query.Skip:]
+	if query.Limit > 0 && query.Limit < int64(len(records)) {
+		records = records[:query.Limit]
+	}
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountMessages counts the matching rows of the user's partition
+func (c *CassandraStore) CountMessages(ctx context.Context, tenantID string, query *models.MessageQuery) (int64, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var count int64
+	err := c.eachMessage(queryCtx, tenantID, query, false, func(*models.SMSRecord) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+	return count, nil
+}
+
+// Conversations groups the rows of the user's partition by phone number
+func (c *CassandraStore) Conversations(ctx context.Context, tenantID, userID string, skip, limit int64) ([]*models.Conversation, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	conversations := newConversationBuilder()
+	err := c.eachMessage(queryCtx, tenantID, &models.MessageQuery{UserID: userID}, false, func(record *models.SMSRecord) error {
+		conversations.add(record)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to group conversations: %w", err)
+	}
+	return conversations.build(skip, limit), nil
+}
+
+// MessageStats tallies the matching rows of the user's partition
+func (c *CassandraStore) MessageStats(ctx context.Context, tenantID string, query *models.MessageQuery) (*models.MessageStats, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	stats := newStatsBuilder()
+	err := c.eachMessage(queryCtx, tenantID, query, false, func(record *models.SMSRecord) error {
+		stats.add(record.CreatedAt, record.Status, record.Direction, 1, billingUnits(record))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to tally message stats: %w", err)
+	}
+	return stats.build(), nil
+}
+
+// FindMessagesByPhoneNumber pages through the phone number's partition of
+// messages_by_phone, reading each record from its user's partition
+func (c *CassandraStore) FindMessagesByPhoneNumber(ctx context.Context, tenantID, phoneNumber string, skip, limit int64) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	iter := c.session.Query(`SELECT user_id, created_at, id FROM messages_by_phone WHERE tenant_id = ? AND phone_number = ?`,
+		tenantID, phoneNumber).PageSize(500).IterContext(queryCtx)
+	var (
+		records []*models.SMSRecord
+		skipped int64
+	)
+	key := recordKey{tenantID: tenantID}
+	for int64(len(records)) < limit && iter.Scan(&key.userID, &key.createdAt, &key.id) {
+		if skipped < skip {
+			skipped++
+			continue
+		}
+		record, err := c.getRecord(queryCtx, key)
+		if errors.Is(err, ErrNotFound) {
+			// Erased, or expired a moment before its lookup row
+			continue
+		}
+		if err != nil {
+			iter.Close()
+			return nil, fmt.Errorf("failed to query messages: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	return records, nil
+}
+
+// recordKey locates a record's row in messages_by_user
+type recordKey struct {
+	tenantID  string
+	userID    string
+	createdAt time.Time
+	id        string
+}
+
+// getRecord reads the row at key
+func (c *CassandraStore) getRecord(ctx context.Context, key recordKey) (*models.SMSRecord, error) {
+	iter := c.session.Query(`SELECT `+cassandraRecordColumns+` FROM messages_by_user
+		WHERE tenant_id = ? AND user_id = ? AND created_at = ? AND id = ?`,
+		key.tenantID, key.userID, key.createdAt, key.id).IterContext(ctx)
+	record, ok, err := scanCassandraRecord(iter.Scan)
+	if closeErr := iter.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return record, nil
+}
+
+// GetMessage finds the record's row through messages_by_id
+func (c *CassandraStore) GetMessage(ctx context.Context, tenantID string, id primitive.ObjectID) (*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	key := recordKey{id: id.Hex()}
+	err := c.session.Query(`SELECT tenant_id, user_id, created_at FROM messages_by_id WHERE id = ?`, key.id).
+		ScanContext(queryCtx, &key.tenantID, &key.userID, &key.createdAt)
+	if errors.Is(err, gocql.ErrNotFound) || (err == nil && key.tenantID != tenantID) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message: %w", err)
+	}
+
+	record, err := c.getRecord(queryCtx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to query message: %w", err)
+	}
+	return record, err
+}
+
+// ClaimRequeue increments the requeue_count of the record's messages_by_id row
+// with a lightweight transaction conditioned on the count it read. Losing the
+// transaction to a concurrent claim counts as reaching the limit, rather than
+// retrying it
+func (c *CassandraStore) ClaimRequeue(ctx context.Context, tenantID string, id primitive.ObjectID, max int) (int, error) {
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var (
+		owner     string
+		createdAt time.Time
+		requeues  *int // nil until the first requeue
+	)
+	err := c.session.Query(`SELECT tenant_id, created_at, requeue_count FROM messages_by_id WHERE id = ?`, id.Hex()).
+		ScanContext(updateCtx, &owner, &createdAt, &requeues)
+	if errors.Is(err, gocql.ErrNotFound) || (err == nil && owner != tenantID) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count message requeue: %w", err)
+	}
+	ttl, ok := c.ttl(createdAt)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	claimed := 1
+	if requeues != nil {
+		claimed = *requeues + 1
+	}
+	if claimed > max {
+		return 0, ErrRequeueLimit
+	}
+
+	// Conditioned on the tenant too, so a row erased meanwhile is not recreated
+	applied, err := c.session.Query(`UPDATE messages_by_id USING TTL ? SET requeue_count = ?
+		WHERE id = ? IF tenant_id = ? AND requeue_count = ?`,
+		ttl, claimed, id.Hex(), tenantID, requeues).ScanCASContext(updateCtx, new(string), new(int))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count message requeue: %w", err)
+	}
+	if !applied {
+		return 0, ErrRequeueLimit
+	}
+	return claimed, nil
+}
+
+// UpdateStatus finds the record through messages_by_message_id and updates it
+func (c *CassandraStore) UpdateStatus(ctx context.Context, messageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return c.updateStatus(ctx, "", `SELECT tenant_id, user_id, created_at, id FROM messages_by_message_id WHERE message_id = ?`, messageID, change)
+}
+
+// UpdateStatusByProviderID finds the tenant's record through messages_by_provider_id and updates it
+func (c *CassandraStore) UpdateStatusByProviderID(ctx context.Context, tenantID, providerMessageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return c.updateStatus(ctx, tenantID, `SELECT tenant_id, user_id, created_at, id FROM messages_by_provider_id WHERE provider_message_id = ?`, providerMessageID, change)
+}
+
+// updateStatus sets the current status and appends to the status history in a
+// single statement, expiring the new cells with the rest of the row. Records
+// found of another tenant than tenantID, when set, are not found
+func (c *CassandraStore) updateStatus(ctx context.Context, tenantID, lookup, value string, change models.StatusChange) (*models.SMSRecord, error) {
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var key recordKey
+	err := c.session.Query(lookup, value).ScanContext(updateCtx, &key.tenantID, &key.userID, &key.createdAt, &key.id)
+	if errors.Is(err, gocql.ErrNotFound) || (err == nil && tenantID != "" && key.tenantID != tenantID) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message status: %w", err)
+	}
+	ttl, ok := c.ttl(key.createdAt)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry, err := json.Marshal(change)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message status: %w", err)
+	}
+	err = c.session.Query(`UPDATE messages_by_user USING TTL ?
+		SET status = ?, updated_at = ?, status_history = status_history + ?
+		WHERE tenant_id = ? AND user_id = ? AND created_at = ? AND id = ?`,
+		ttl, change.Status, change.ChangedAt, []string{string(entry)},
+		key.tenantID, key.userID, key.createdAt, key.id,
+	).ExecContext(updateCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message status: %w", err)
+	}
+
+	record, err := c.getRecord(updateCtx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to read updated message: %w", err)
+	}
+	return record, err
+}
+
+// DeleteUserMessages deletes the lookup rows, then the partition, of each of the
+// user's IDs, then writes the audit record. Cassandra has no multi-partition
+// transactions: a failed audit write leaves the records deleted, and erasing
+// again is harmless
+func (c *CassandraStore) DeleteUserMessages(ctx context.Context, tenantID string, userIDs []string, audit *models.AuditRecord) (int64, error) {
+	deleteCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var deleted int64
+	for _, userID := range userIDs {
+		count, err := c.deleteUserPartition(deleteCtx, tenantID, userID)
+		if err != nil {
+			return 0, err
+		}
+		deleted += count
+	}
+
+	audit.ResultCount = deleted
+	if err := c.InsertAuditRecord(deleteCtx, audit); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// deleteUserPartition deletes the lookup rows of the messages stored under userID,
+// then their partition, and returns how many there were
+func (c *CassandraStore) deleteUserPartition(ctx context.Context, tenantID, userID string) (int64, error) {
+	iter := c.session.Query(`SELECT id, message_id, provider_message_id, phone_number, created_at FROM messages_by_user
+		WHERE tenant_id = ? AND user_id = ?`, tenantID, userID).PageSize(500).IterContext(ctx)
+	var (
+		deleted                                       int64
+		id, messageID, providerMessageID, phoneNumber string
+		createdAt                                     time.Time
+	)
+	for iter.Scan(&id, &messageID, &providerMessageID, &phoneNumber, &createdAt) {
+		deleted++
+		if err := c.session.Query(`DELETE FROM messages_by_id WHERE id = ?`, id).ExecContext(ctx); err != nil {
+			iter.Close()
+			return 0, fmt.Errorf("failed to delete messages: %w", err)
+		}
+		err := c.session.Query(`DELETE FROM messages_by_phone WHERE tenant_id = ? AND phone_number = ? AND created_at = ? AND id = ?`,
+			tenantID, phoneNumber, createdAt, id).ExecContext(ctx)
+		if err != nil {
+			iter.Close()
+			return 0, fmt.Errorf("failed to delete messages: %w", err)
+		}
+		if messageID != "" {
+			if err := c.session.Query(`DELETE FROM messages_by_message_id WHERE message_id = ?`, messageID).ExecContext(ctx); err != nil {
+				iter.Close()
+				return 0, fmt.Errorf("failed to delete messages: %w", err)
+			}
+		}
+		if providerMessageID != "" {
+			if err := c.session.Query(`DELETE FROM messages_by_provider_id WHERE provider_message_id = ?`, providerMessageID).ExecContext(ctx); err != nil {
+				iter.Close()
+				return 0, fmt.Errorf("failed to delete messages: %w", err)
+			}
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return 0, fmt.Errorf("failed to delete messages: %w", err)
+	}
+
+	err := c.session.Query(`DELETE FROM messages_by_user WHERE tenant_id = ? AND user_id = ?`, tenantID, userID).ExecContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete messages: %w", err)
+	}
+	return deleted, nil
+}
+
+// InsertAuditRecord writes audit to its tenant's partition of audit_by_tenant,
+// without a TTL: the audit trail outlives the retention of messages
+func (c *CassandraStore) InsertAuditRecord(ctx context.Context, audit *models.AuditRecord) error {
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err := c.session.Query(`INSERT INTO audit_by_tenant (tenant_id, created_at, id, action, actor, auth_method,
+		endpoint, filters, user_id, phone_number, target_id, result_count, remote_addr)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		audit.TenantID, audit.CreatedAt, primitive.NewObjectID().Hex(), audit.Action, audit.Actor, audit.AuthMethod,
+		audit.Endpoint, audit.Filters, audit.UserID, audit.PhoneNumber, audit.TargetID, audit.ResultCount,
+		audit.RemoteAddr).ExecContext(insertCtx)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit record: %w", err)
+	}
+	return nil
+}
+
+// FindAuditRecords pages through the tenant's partition of audit_by_tenant,
+// applying the actor, user, action and target filters as it reads
+func (c *CassandraStore) FindAuditRecords(ctx context.Context, tenantID string, query *models.AuditQuery) ([]*models.AuditRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	stmt := `SELECT action, actor, auth_method, endpoint, filters, user_id, phone_number, target_id,
+		result_count, remote_addr, created_at FROM audit_by_tenant WHERE tenant_id = ?`
+	args := []any{tenantID}
+	if !query.Since.IsZero() {
+		stmt += ` AND created_at >= ?`
+		args = append(args, query.Since)
+	}
+	if !query.Until.IsZero() {
+		stmt += ` AND created_at < ?`
+		args = append(args, query.Until)
+	}
+
+	iter := c.session.Query(stmt, args...).PageSize(500).IterContext(queryCtx)
+	var (
+		records []*models.AuditRecord
+		skipped int64
+	)
+	for query.Limit == 0 || int64(len(records)) < query.Limit {
+		audit := models.AuditRecord{TenantID: tenantID}
+		if !iter.Scan(&audit.Action, &audit.Actor, &audit.AuthMethod, &audit.Endpoint, &audit.Filters, &audit.UserID,
+			&audit.PhoneNumber, &audit.TargetID, &audit.ResultCount, &audit.RemoteAddr, &audit.CreatedAt) {
+			break
+		}
+		if !matchesAudit(&audit, query) {
+			continue
+		}
+		if skipped < query.Skip {
+			skipped++
+			continue
+		}
+		audit.CreatedAt = audit.CreatedAt.UTC()
+		records = append(records, &audit)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to query audit records: %w", err)
+	}
+	return records, nil
+}
+
+// FindDuplicates is not supported; it would scan every partition of the tenant.
+// Record IDs are derived from message_id, so no two records can share one
+func (c *CassandraStore) FindDuplicates(ctx context.Context, tenantID string, since, until time.Time, limit int64) ([]*models.DuplicateGroup, error) {
+	return nil, errCassandraUnsupported
+}
+
+// DeliveryStats is not supported; it would scan every partition of the tenant
+func (c *CassandraStore) DeliveryStats(ctx context.Context, tenantID string, query *models.DeliveryQuery) (*models.DeliveryStats, error) {
+	return nil, errCassandraUnsupported
+}
+
+// FindMessagesOlderThan is not supported; records expire with their TTL instead
+func (c *CassandraStore) FindMessagesOlderThan(ctx context.Context, cutoff time.Time, tenants models.TenantFilter, limit int64) ([]*models.SMSRecord, error) {
+	return nil, errCassandraUnsupported
+}
+
+// FindMessagesInIDRange is not supported; it would scan every partition
+func (c *CassandraStore) FindMessagesInIDRange(ctx context.Context, after, before primitive.ObjectID, limit int64) ([]*models.SMSRecord, error) {
+	return nil, errCassandraUnsupported
+}
+
+// DeleteMessages is not supported; it is only used after FindMessagesOlderThan
+func (c *CassandraStore) DeleteMessages(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	return 0, errCassandraUnsupported
+}
+
+// PendingStoredEvents is not supported; there is no outbox
+func (c *CassandraStore) PendingStoredEvents(ctx context.Context, limit int64) ([]*models.SMSRecord, error) {
+	return nil, errCassandraUnsupported
+}
+
+// ClearStoredEventsPending is not supported; there is no outbox
+func (c *CassandraStore) ClearStoredEventsPending(ctx context.Context, ids []primitive.ObjectID) error {
+	return errCassandraUnsupported
+}
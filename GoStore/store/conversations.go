@@ -0,0 +1,62 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// conversationBuilder groups messages by counterpart phone number for the
+// backends that cannot aggregate in the query, in the order they are added
+type conversationBuilder struct {
+	byPeer map[string]*models.Conversation
+}
+
+func newConversationBuilder() *conversationBuilder {
+	return &conversationBuilder{byPeer: make(map[string]*models.Conversation)}
+}
+
+// add counts record in its conversation, taking it as the last message if it
+// is the newest yet
+func (b *conversationBuilder) add(record *models.SMSRecord) {
+	conversation, ok := b.byPeer[record.PhoneNumber]
+	if !ok {
+		conversation = &models.Conversation{Peer: record.PhoneNumber}
+		b.byPeer[record.PhoneNumber] = conversation
+	}
+	conversation.MessageCount++
+	if conversation.MessageCount == 1 || record.CreatedAt.After(conversation.LastMessageAt) {
+		conversation.LastMessageAt = record.CreatedAt
+		conversation.LastMessage = record.Message
+		conversation.LastStatus = record.Status
+		conversation.LastDirection = record.Direction
+		if conversation.LastDirection == "" {
+			conversation.LastDirection = models.DirectionOutbound
+		}
+	}
+}
+
+// build returns one page of the conversations, most recently active first and
+// by peer within the same instant
+func (b *conversationBuilder) build(skip, limit int64) []*models.Conversation {
+	conversations := make([]*models.Conversation, 0, len(b.byPeer))
+	for _, conversation := range b.byPeer {
+		conversations = append(conversations, conversation)
+	}
+	slices.SortFunc(conversations, func(a, b *models.Conversation) int {
+		if order := b.LastMessageAt.Compare(a.LastMessageAt); order != 0 {
+			return order
+		}
+		return cmp.Compare(a.Peer, b.Peer)
+	})
+
+	if skip >= int64(len(conversations)) {
+		return nil
+	}
+	conversations = conversations[skip:]
+	if limit < int64(len(conversations)) {
+		conversations = conversations[:limit]
+	}
+	return conversations
+}
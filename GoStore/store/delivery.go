@@ -0,0 +1,130 @@
+package store
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// deliveryBuilder rolls up message counts grouped by time bucket, sender ID,
+// carrier, country and status into DeliveryStats, so every backend reports the
+// same shape
+type deliveryBuilder struct {
+	interval  string
+	stats     models.DeliveryStats
+	bySender  map[string]*models.SenderDelivery
+	byCarrier map[string]*models.CarrierDelivery
+	byCountry map[string]*models.CountryDelivery
+	byPeriod  map[time.Time]*periodDelivery
+}
+
+// periodDelivery is a time bucket being counted
+type periodDelivery struct {
+	models.PeriodDelivery
+	byCarrier map[string]*models.CarrierDelivery
+}
+
+func newDeliveryBuilder(interval string) *deliveryBuilder {
+	return &deliveryBuilder{
+		interval:  interval,
+		bySender:  make(map[string]*models.SenderDelivery),
+		byCarrier: make(map[string]*models.CarrierDelivery),
+		byCountry: make(map[string]*models.CountryDelivery),
+		byPeriod:  make(map[time.Time]*periodDelivery),
+	}
+}
+
+// bucket returns the start of the UTC bucket of createdAt
+func (b *deliveryBuilder) bucket(createdAt time.Time) time.Time {
+	if b.interval == models.DeliveryIntervalDay {
+		return createdAt.UTC().Truncate(24 * time.Hour)
+	}
+	return createdAt.UTC().Truncate(time.Hour)
+}
+
+// add counts count messages created in the bucket of createdAt
+func (b *deliveryBuilder) add(createdAt time.Time, senderID, carrier, countryCode, status string, count int64) {
+	b.stats.Add(status, count)
+
+	sender, ok := b.bySender[senderID]
+	if !ok {
+		sender = &models.SenderDelivery{SenderID: senderID}
+		b.bySender[senderID] = sender
+	}
+	sender.Add(status, count)
+
+	addCarrier(b.byCarrier, carrier, status, count)
+
+	country, ok := b.byCountry[countryCode]
+	if !ok {
+		country = &models.CountryDelivery{CountryCode: countryCode}
+		b.byCountry[countryCode] = country
+	}
+	country.Add(status, count)
+
+	start := b.bucket(createdAt)
+	period, ok := b.byPeriod[start]
+	if !ok {
+		period = &periodDelivery{
+			PeriodDelivery: models.PeriodDelivery{Start: start},
+			byCarrier:      make(map[string]*models.CarrierDelivery),
+		}
+		b.byPeriod[start] = period
+	}
+	period.Add(status, count)
+	addCarrier(period.byCarrier, carrier, status, count)
+}
+
+// addCarrier counts count messages of carrier in carriers
+func addCarrier(carriers map[string]*models.CarrierDelivery, carrier, status string, count int64) {
+	c, ok := carriers[carrier]
+	if !ok {
+		c = &models.CarrierDelivery{Carrier: carrier}
+		carriers[carrier] = c
+	}
+	c.Add(status, count)
+}
+
+// build returns the stats added so far
+func (b *deliveryBuilder) build() *models.DeliveryStats {
+	b.stats.BySenderID = make([]models.SenderDelivery, 0, len(b.bySender))
+	for _, sender := range b.bySender {
+		b.stats.BySenderID = append(b.stats.BySenderID, *sender)
+	}
+	slices.SortFunc(b.stats.BySenderID, func(a, b models.SenderDelivery) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.SenderID, b.SenderID))
+	})
+
+	b.stats.ByCarrier = carrierDeliveries(b.byCarrier)
+
+	b.stats.ByCountry = make([]models.CountryDelivery, 0, len(b.byCountry))
+	for _, country := range b.byCountry {
+		b.stats.ByCountry = append(b.stats.ByCountry, *country)
+	}
+	slices.SortFunc(b.stats.ByCountry, func(a, b models.CountryDelivery) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.CountryCode, b.CountryCode))
+	})
+
+	b.stats.ByPeriod = make([]models.PeriodDelivery, 0, len(b.byPeriod))
+	for _, start := range slices.SortedFunc(maps.Keys(b.byPeriod), time.Time.Compare) {
+		period := b.byPeriod[start]
+		period.ByCarrier = carrierDeliveries(period.byCarrier)
+		b.stats.ByPeriod = append(b.stats.ByPeriod, period.PeriodDelivery)
+	}
+	return &b.stats
+}
+
+// carrierDeliveries lists carriers by messages, most first
+func carrierDeliveries(carriers map[string]*models.CarrierDelivery) []models.CarrierDelivery {
+	list := make([]models.CarrierDelivery, 0, len(carriers))
+	for _, carrier := range carriers {
+		list = append(list, *carrier)
+	}
+	slices.SortFunc(list, func(a, b models.CarrierDelivery) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Carrier, b.Carrier))
+	})
+	return list
+}
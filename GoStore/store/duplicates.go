@@ -0,0 +1,105 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// duplicateKey identifies the records that are copies of one message by content
+type duplicateKey struct {
+	userID  string
+	message string
+	unix    int64 // created_at in nanoseconds, since times with a location don't compare with ==
+}
+
+// duplicateBuilder groups records sharing a message_id or content for the
+// backends that cannot aggregate in the query
+type duplicateBuilder struct {
+	byMessageID map[string]*models.DuplicateGroup
+	byContent   map[duplicateKey]*models.DuplicateGroup
+}
+
+func newDuplicateBuilder() *duplicateBuilder {
+	return &duplicateBuilder{
+		byMessageID: make(map[string]*models.DuplicateGroup),
+		byContent:   make(map[duplicateKey]*models.DuplicateGroup),
+	}
+}
+
+// add counts record in the groups of its message_id and content
+func (b *duplicateBuilder) add(record *models.SMSRecord) {
+	if record.MessageID != "" {
+		group, ok := b.byMessageID[record.MessageID]
+		if !ok {
+			group = &models.DuplicateGroup{Kind: models.DuplicateByMessageID, MessageID: record.MessageID}
+			b.byMessageID[record.MessageID] = group
+		}
+		addDuplicate(group, record)
+	}
+
+	key := duplicateKey{userID: record.UserID, message: record.Message, unix: record.CreatedAt.UnixNano()}
+	group, ok := b.byContent[key]
+	if !ok {
+		group = &models.DuplicateGroup{Kind: models.DuplicateByContent}
+		b.byContent[key] = group
+	}
+	addDuplicate(group, record)
+}
+
+// addDuplicate counts record in group. ObjectIDs start with the time they were
+// assigned, at insertion, so the lowest is of the first record stored
+func addDuplicate(group *models.DuplicateGroup, record *models.SMSRecord) {
+	group.IDs = append(group.IDs, record.ID.Hex())
+	if group.Count == 0 || record.ID.Hex() < group.IDs[0] {
+		group.UserID = record.UserID
+		// Keep the lowest first; build sorts the rest
+		last := len(group.IDs) - 1
+		group.IDs[0], group.IDs[last] = group.IDs[last], group.IDs[0]
+	}
+	if group.Count == 0 || record.CreatedAt.Before(group.CreatedAt) {
+		group.CreatedAt = record.CreatedAt
+	}
+	group.Count++
+}
+
+// build returns up to limit of the groups of more than one record
+func (b *duplicateBuilder) build(limit int64) []*models.DuplicateGroup {
+	var groups []*models.DuplicateGroup
+	for _, group := range b.byMessageID {
+		if group.Count > 1 {
+			groups = append(groups, group)
+		}
+	}
+	for _, group := range b.byContent {
+		if group.Count > 1 {
+			groups = append(groups, group)
+		}
+	}
+	for _, group := range groups {
+		slices.Sort(group.IDs)
+		if len(group.IDs) > models.MaxDuplicateIDs {
+			group.IDs = group.IDs[:models.MaxDuplicateIDs]
+		}
+	}
+	return topDuplicates(groups, limit)
+}
+
+// topDuplicates returns up to limit of groups, largest first, then most
+// recent first and by kind, so every backend reports them in the same order
+func topDuplicates(groups []*models.DuplicateGroup, limit int64) []*models.DuplicateGroup {
+	slices.SortFunc(groups, func(a, b *models.DuplicateGroup) int {
+		if order := cmp.Compare(b.Count, a.Count); order != 0 {
+			return order
+		}
+		if order := b.CreatedAt.Compare(a.CreatedAt); order != 0 {
+			return order
+		}
+		return cmp.Compare(a.Kind, b.Kind)
+	})
+	if limit < int64(len(groups)) {
+		groups = groups[:limit]
+	}
+	return groups
+}
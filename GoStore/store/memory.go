@@ -0,0 +1,454 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStore keeps records in process memory, for development and tests
+// Nothing survives a restart and nothing is shared between instances
+type MemoryStore struct {
+	mu         sync.RWMutex
+	records    map[primitive.ObjectID]*models.SMSRecord
+	messageIDs map[messageKey]primitive.ObjectID // enforces unique message_ids per tenant like the Mongo index
+	requeues   map[primitive.ObjectID]int
+	audit      []*models.AuditRecord
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:    make(map[primitive.ObjectID]*models.SMSRecord),
+		messageIDs: make(map[messageKey]primitive.ObjectID),
+		requeues:   make(map[primitive.ObjectID]int),
+	}
+}
+
+// messageKey identifies a tenant's message by its message_id
+type messageKey struct {
+	tenantID, messageID string
+}
+
+// clone copies record so callers can never modify stored state
+func clone(record *models.SMSRecord) *models.SMSRecord {
+	c := *record
+	c.StatusHistory = slices.Clone(record.StatusHistory)
+	c.Media = slices.Clone(record.Media)
+	c.Flags = slices.Clone(record.Flags)
+	return &c
+}
+
+// insert stores record; the caller holds the write lock
+func (m *MemoryStore) insert(record *models.SMSRecord) error {
+	if record.ID.IsZero() {
+		record.ID = primitive.NewObjectID()
+	}
+	if _, ok := m.records[record.ID]; ok {
+		return ErrDuplicate
+	}
+	if record.MessageID != "" {
+		key := messageKey{record.TenantID, record.MessageID}
+		if _, ok := m.messageIDs[key]; ok {
+			return ErrDuplicate
+		}
+		m.messageIDs[key] = record.ID
+	}
+	m.records[record.ID] = clone(record)
+	return nil
+}
+
+// remove deletes the record with id; the caller holds the write lock
+func (m *MemoryStore) remove(id primitive.ObjectID) bool {
+	record, ok := m.records[id]
+	if !ok {
+		return false
+	}
+	delete(m.messageIDs, messageKey{record.TenantID, record.MessageID})
+	delete(m.records, id)
+	delete(m.requeues, id)
+	return true
+}
+
+// InsertMessage stores a copy of record
+func (m *MemoryStore) InsertMessage(ctx context.Context, record *models.SMSRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.insert(record)
+}
+
+// InsertMessages stores a copy of each record
+func (m *MemoryStore) InsertMessages(ctx context.Context, records []*models.SMSRecord) (InsertResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := InsertResult{Duplicates: make(map[int]bool), Failed: make(map[int]error)}
+	for i, record := range records {
+		if err := m.insert(record); err != nil {
+			result.Duplicates[i] = true
+		}
+	}
+	return result, nil
+}
+
+// matches reports whether record is one of the user's messages matching query
+func matches(record *models.SMSRecord, tenantID string, query *models.MessageQuery) bool {
+	if record.TenantID != tenantID || record.UserID != query.UserID {
+		return false
+	}
+	if len(query.Statuses) > 0 && !slices.Contains(query.Statuses, record.Status) {
+		return false
+	}
+	if query.PhoneNumber != "" && record.PhoneNumber != query.PhoneNumber {
+		return false
+	}
+	if !query.MessageFilter.Matches(record) {
+		return false
+	}
+	if !query.Since.IsZero() && record.CreatedAt.Before(query.Since) {
+		return false
+	}
+	if !query.Until.IsZero() && !record.CreatedAt.Before(query.Until) {
+		return false
+	}
+	return true
+}
+
+// FindMessages scans for the user's messages
+func (m *MemoryStore) FindMessages(ctx context.Context, tenantID string, query *models.MessageQuery) ([]*models.SMSRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found []*models.SMSRecord
+	for _, record := range m.records {
+		if matches(record, tenantID, query) {
+			found = append(found, clone(record))
+		}
+	}
+	models.SortRecords(found, query.Sort)
+
+	if query.Skip >= int64(len(found)) {
+		return nil, nil
+	}
+	found = found[query.Skip:]
+	if query.Limit > 0 && query.Limit < int64(len(found)) {
+		found = found[:query.Limit]
+	}
+	return found, nil
+}
+
+// Conversations groups the user's messages by phone number
+func (m *MemoryStore) Conversations(ctx context.Context, tenantID, userID string, skip, limit int64) ([]*models.Conversation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	conversations := newConversationBuilder()
+	for _, record := range m.records {
+		if record.TenantID == tenantID && record.UserID == userID {
+			conversations.add(record)
+		}
+	}
+	return conversations.build(skip, limit), nil
+}
+
+// FindMessagesByPhoneNumber scans for the tenant's messages by phone number
+func (m *MemoryStore) FindMessagesByPhoneNumber(ctx context.Context, tenantID, phoneNumber string, skip, limit int64) ([]*models.SMSRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found []*models.SMSRecord
+	for _, record := range m.records {
+		if record.TenantID == tenantID && record.PhoneNumber == phoneNumber {
+			found = append(found, clone(record))
+		}
+	}
+	models.SortRecords(found, models.MessageSort{})
+
+	if skip >= int64(len(found)) {
+		return nil, nil
+	}
+	found = found[skip:]
+	if limit < int64(len(found)) {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// StreamMessages finds the user's messages, then calls fn for each without holding the lock
+func (m *MemoryStore) StreamMessages(ctx context.Context, tenantID string, query *models.MessageQuery, fn func(*models.SMSRecord) error) error {
+	records, err := m.FindMessages(ctx, tenantID, query)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountMessages counts the user's messages
+func (m *MemoryStore) CountMessages(ctx context.Context, tenantID string, query *models.MessageQuery) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, record := range m.records {
+		if matches(record, tenantID, query) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// MessageStats scans for the user's messages and tallies them
+func (m *MemoryStore) MessageStats(ctx context.Context, tenantID string, query *models.MessageQuery) (*models.MessageStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := newStatsBuilder()
+	for _, record := range m.records {
+		if matches(record, tenantID, query) {
+			stats.add(record.CreatedAt, record.Status, record.Direction, 1, billingUnits(record))
+		}
+	}
+	return stats.build(), nil
+}
+
+// FindDuplicates scans for the tenant's messages of the window and groups them
+func (m *MemoryStore) FindDuplicates(ctx context.Context, tenantID string, since, until time.Time, limit int64) ([]*models.DuplicateGroup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	duplicates := newDuplicateBuilder()
+	for _, record := range m.records {
+		if record.TenantID == tenantID && !record.CreatedAt.Before(since) && record.CreatedAt.Before(until) {
+			duplicates.add(record)
+		}
+	}
+	return duplicates.build(limit), nil
+}
+
+// DeliveryStats scans for the tenant's outbound messages of the window and tallies them
+func (m *MemoryStore) DeliveryStats(ctx context.Context, tenantID string, query *models.DeliveryQuery) (*models.DeliveryStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	delivery := newDeliveryBuilder(query.Interval)
+	for _, record := range m.records {
+		if record.TenantID != tenantID || record.Direction == models.DirectionInbound ||
+			record.CreatedAt.Before(query.Since) || !record.CreatedAt.Before(query.Until) ||
+			(query.SenderID != "" && record.SenderID != query.SenderID) ||
+			(query.Carrier != "" && record.Carrier != query.Carrier) ||
+			(query.CountryCode != "" && record.CountryCode != query.CountryCode) ||
+			(query.DialCode != "" && record.DialCode != query.DialCode) {
+			continue
+		}
+		delivery.add(record.CreatedAt, record.SenderID, record.Carrier, record.CountryCode, record.Status, 1)
+	}
+	return delivery.build(), nil
+}
+
+// GetMessage looks a record up by ID
+func (m *MemoryStore) GetMessage(ctx context.Context, tenantID string, id primitive.ObjectID) (*models.SMSRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, ok := m.records[id]
+	if !ok || record.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	return clone(record), nil
+}
+
+// ClaimRequeue counts the requeue under the write lock
+func (m *MemoryStore) ClaimRequeue(ctx context.Context, tenantID string, id primitive.ObjectID, max int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.records[id]
+	if !ok || record.TenantID != tenantID {
+		return 0, ErrNotFound
+	}
+	if m.requeues[id] >= max {
+		return 0, ErrRequeueLimit
+	}
+	m.requeues[id]++
+	return m.requeues[id], nil
+}
+
+// UpdateStatus scans for a record with the given message_id, of any tenant
+func (m *MemoryStore) UpdateStatus(ctx context.Context, messageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, id := range m.messageIDs {
+		if key.messageID == messageID {
+			return m.applyStatus(m.records[id], change), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// UpdateStatusByProviderID scans for the tenant's record with the given provider_message_id
+func (m *MemoryStore) UpdateStatusByProviderID(ctx context.Context, tenantID, providerMessageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, record := range m.records {
+		if record.TenantID == tenantID && record.ProviderMessageID == providerMessageID {
+			return m.applyStatus(record, change), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// applyStatus updates record in place; the caller holds the write lock
+func (m *MemoryStore) applyStatus(record *models.SMSRecord, change models.StatusChange) *models.SMSRecord {
+	record.Status = change.Status
+	record.UpdatedAt = change.ChangedAt
+	record.StatusHistory = append(record.StatusHistory, change)
+	return clone(record)
+}
+
+// DeleteUserMessages deletes the messages and appends the audit record under one lock
+func (m *MemoryStore) DeleteUserMessages(ctx context.Context, tenantID string, userIDs []string, audit *models.AuditRecord) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for id, record := range m.records {
+		if record.TenantID == tenantID && slices.Contains(userIDs, record.UserID) && m.remove(id) {
+			deleted++
+		}
+	}
+
+	audit.ResultCount = deleted
+	m.audit = append(m.audit, cloneAudit(audit))
+	return deleted, nil
+}
+
+// InsertAuditRecord appends a copy of audit to the audit log
+func (m *MemoryStore) InsertAuditRecord(ctx context.Context, audit *models.AuditRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.audit = append(m.audit, cloneAudit(audit))
+	return nil
+}
+
+// FindAuditRecords scans the audit log, which is kept in insertion order
+func (m *MemoryStore) FindAuditRecords(ctx context.Context, tenantID string, query *models.AuditQuery) ([]*models.AuditRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matched []*models.AuditRecord
+	for i := len(m.audit) - 1; i >= 0; i-- {
+		if audit := m.audit[i]; audit.TenantID == tenantID && matchesAudit(audit, query) {
+			matched = append(matched, audit)
+		}
+	}
+	slices.SortStableFunc(matched, func(a, b *models.AuditRecord) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	page := pageAudit(matched, query)
+	records := make([]*models.AuditRecord, len(page))
+	for i, audit := range page {
+		records[i] = cloneAudit(audit)
+	}
+	return records, nil
+}
+
+// cloneAudit copies audit so callers can never modify stored state
+func cloneAudit(audit *models.AuditRecord) *models.AuditRecord {
+	c := *audit
+	c.Filters = maps.Clone(audit.Filters)
+	return &c
+}
+
+// FindMessagesOlderThan scans for old messages of the selected tenants
+func (m *MemoryStore) FindMessagesOlderThan(ctx context.Context, cutoff time.Time, tenants models.TenantFilter, limit int64) ([]*models.SMSRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found []*models.SMSRecord
+	for _, record := range m.records {
+		if record.CreatedAt.Before(cutoff) && tenants.Matches(record.TenantID) {
+			found = append(found, clone(record))
+		}
+	}
+	slices.SortFunc(found, func(a, b *models.SMSRecord) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	if limit > 0 && limit < int64(len(found)) {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// FindMessagesInIDRange scans for messages by ID across all tenants
+func (m *MemoryStore) FindMessagesInIDRange(ctx context.Context, after, before primitive.ObjectID, limit int64) ([]*models.SMSRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found []*models.SMSRecord
+	for _, record := range m.records {
+		if bytes.Compare(record.ID[:], after[:]) > 0 && bytes.Compare(record.ID[:], before[:]) < 0 {
+			found = append(found, clone(record))
+		}
+	}
+	slices.SortFunc(found, func(a, b *models.SMSRecord) int { return bytes.Compare(a.ID[:], b.ID[:]) })
+	if limit > 0 && limit < int64(len(found)) {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// DeleteMessages deletes records by ID across all tenants
+func (m *MemoryStore) DeleteMessages(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for _, id := range ids {
+		if m.remove(id) {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// PendingStoredEvents scans the outbox across all tenants, in ID order
+func (m *MemoryStore) PendingStoredEvents(ctx context.Context, limit int64) ([]*models.SMSRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found []*models.SMSRecord
+	for _, record := range m.records {
+		if record.StoredEventPending {
+			found = append(found, clone(record))
+		}
+	}
+	slices.SortFunc(found, func(a, b *models.SMSRecord) int { return bytes.Compare(a.ID[:], b.ID[:]) })
+	if limit > 0 && limit < int64(len(found)) {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// ClearStoredEventsPending clears the outbox flag across all tenants
+func (m *MemoryStore) ClearStoredEventsPending(ctx context.Context, ids []primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		if record, ok := m.records[id]; ok {
+			record.StoredEventPending = false
+		}
+	}
+	return nil
+}
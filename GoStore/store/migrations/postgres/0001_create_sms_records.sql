@@ -0,0 +1,29 @@
+-- SMS records, one row per stored message
+-- id holds the record's ObjectID in hex so IDs look the same on every backend
+CREATE TABLE sms_records (
+    id                   TEXT PRIMARY KEY,
+    message_id           TEXT UNIQUE,
+    provider_message_id  TEXT,
+    tenant_id            TEXT NOT NULL,
+    user_id              TEXT NOT NULL,
+    phone_number         TEXT NOT NULL,
+    message              TEXT NOT NULL,
+    status               TEXT NOT NULL,
+    direction            TEXT,
+    status_history       JSONB NOT NULL DEFAULT '[]',
+    created_at           TIMESTAMPTZ NOT NULL,
+    updated_at           TIMESTAMPTZ,
+    stored_event_pending BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- User message queries, newest first
+CREATE INDEX idx_sms_records_tenant_user_created ON sms_records (tenant_id, user_id, created_at DESC);
+
+-- Retention and archival scans
+CREATE INDEX idx_sms_records_created_at ON sms_records (created_at);
+
+-- Delivery receipts
+CREATE INDEX idx_sms_records_provider_message_id ON sms_records (provider_message_id) WHERE provider_message_id IS NOT NULL;
+
+-- Stored events outbox
+CREATE INDEX idx_sms_records_stored_event_pending ON sms_records (id) WHERE stored_event_pending;
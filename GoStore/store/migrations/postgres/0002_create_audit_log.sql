@@ -0,0 +1,12 @@
+-- Audit entries for privileged operations such as erasing a user's messages
+CREATE TABLE audit_log (
+    id           BIGSERIAL PRIMARY KEY,
+    action       TEXT NOT NULL,
+    tenant_id    TEXT NOT NULL,
+    user_id      TEXT NOT NULL,
+    result_count BIGINT NOT NULL,
+    remote_addr  TEXT,
+    created_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_audit_log_tenant_user ON audit_log (tenant_id, user_id);
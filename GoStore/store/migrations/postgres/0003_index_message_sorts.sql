@@ -0,0 +1,3 @@
+-- User queries sorted by status or by sender, then newest first
+CREATE INDEX idx_sms_records_tenant_user_status ON sms_records (tenant_id, user_id, status, created_at DESC);
+CREATE INDEX idx_sms_records_tenant_user_phone_number ON sms_records (tenant_id, user_id, phone_number, created_at DESC);
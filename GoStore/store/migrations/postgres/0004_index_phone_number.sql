@@ -0,0 +1,2 @@
+-- Support lookups by the phone number messages were sent to or received from
+CREATE INDEX idx_sms_records_tenant_phone_number ON sms_records (tenant_id, phone_number, created_at DESC);
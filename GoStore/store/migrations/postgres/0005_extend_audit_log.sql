@@ -0,0 +1,12 @@
+-- Who accessed whose messages, and how, for the audit trail of data access and
+-- admin operations; rows written before this migration leave the new columns NULL
+ALTER TABLE audit_log
+    ADD COLUMN actor        TEXT,
+    ADD COLUMN auth_method  TEXT,
+    ADD COLUMN endpoint     TEXT,
+    ADD COLUMN filters      JSONB,
+    ADD COLUMN phone_number TEXT,
+    ADD COLUMN target_id    TEXT;
+
+-- A tenant's audit trail, newest first
+CREATE INDEX idx_audit_log_tenant_created_at ON audit_log (tenant_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Metadata of the media attachments of MMS messages; the data itself is kept in
+-- the media store (MEDIA_BACKEND). NULL for messages without attachments
+ALTER TABLE sms_records ADD COLUMN media JSONB;
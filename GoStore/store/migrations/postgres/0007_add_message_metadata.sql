@@ -0,0 +1,12 @@
+-- Message metadata set by producers, and user queries filtered by it. Carrier,
+-- country and sender are only set by some producers, so the indexes skip rows without them
+ALTER TABLE sms_records ADD COLUMN carrier TEXT, ADD COLUMN country_code TEXT, ADD COLUMN sender_id TEXT,
+	ADD COLUMN segment_count INTEGER;
+
+CREATE INDEX idx_sms_records_tenant_user_direction ON sms_records (tenant_id, user_id, direction, created_at DESC);
+CREATE INDEX idx_sms_records_tenant_user_carrier ON sms_records (tenant_id, user_id, carrier, created_at DESC)
+	WHERE carrier IS NOT NULL;
+CREATE INDEX idx_sms_records_tenant_user_country_code ON sms_records (tenant_id, user_id, country_code, created_at DESC)
+	WHERE country_code IS NOT NULL;
+CREATE INDEX idx_sms_records_tenant_user_sender_id ON sms_records (tenant_id, user_id, sender_id, created_at DESC)
+	WHERE sender_id IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Encoding, script and language detected from each message, and user queries
+-- filtered by language; messages whose language is not detected are not indexed
+ALTER TABLE sms_records ADD COLUMN encoding TEXT, ADD COLUMN script TEXT, ADD COLUMN language TEXT;
+
+CREATE INDEX idx_sms_records_tenant_user_language ON sms_records (tenant_id, user_id, language, created_at DESC)
+	WHERE language IS NOT NULL;
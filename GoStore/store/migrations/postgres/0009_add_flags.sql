@@ -0,0 +1,6 @@
+-- Flag rules each message matched when it was stored, NULL for none, and the
+-- flagged messages of a user; few messages are flagged, so only those are indexed
+ALTER TABLE sms_records ADD COLUMN flags TEXT[];
+
+CREATE INDEX idx_sms_records_tenant_user_flagged ON sms_records (tenant_id, user_id, created_at DESC)
+	WHERE flags IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Retention sweeps of one tenant by age
+CREATE INDEX idx_sms_records_tenant_created_at ON sms_records (tenant_id, created_at);
@@ -0,0 +1,6 @@
+-- Type of the sender ID when the tenant registered it, NULL for unregistered
+-- senders, and the messages of a user from registered senders
+ALTER TABLE sms_records ADD COLUMN sender_type TEXT;
+
+CREATE INDEX idx_sms_records_tenant_user_sender_type ON sms_records (tenant_id, user_id, sender_type, created_at DESC)
+	WHERE sender_type IS NOT NULL;
@@ -0,0 +1,4 @@
+-- User ID and phone number of a message as received, when normalizing them
+-- to E.164 changed them, and NULL otherwise
+ALTER TABLE sms_records ADD COLUMN raw_user_id TEXT;
+ALTER TABLE sms_records ADD COLUMN raw_phone_number TEXT;
@@ -0,0 +1,8 @@
+-- Country calling code of a message's phone number, derived at ingest, and
+-- tenant-wide queries of a country or dial code for regional reporting
+ALTER TABLE sms_records ADD COLUMN dial_code TEXT;
+
+CREATE INDEX idx_sms_records_tenant_country_code ON sms_records (tenant_id, country_code, created_at DESC)
+	WHERE country_code IS NOT NULL;
+CREATE INDEX idx_sms_records_tenant_dial_code ON sms_records (tenant_id, dial_code, created_at DESC)
+	WHERE dial_code IS NOT NULL;
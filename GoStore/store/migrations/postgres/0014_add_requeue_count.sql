@@ -0,0 +1,2 @@
+-- Times a message was requeued by the admin server, which caps it per message
+ALTER TABLE sms_records ADD COLUMN requeue_count INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- message_id is unique per tenant, so two tenants' messages with the same ID are
+-- both stored; it leads, so status updates by message_id alone still use the index
+ALTER TABLE sms_records DROP CONSTRAINT IF EXISTS sms_records_message_id_key;
+CREATE UNIQUE INDEX idx_sms_records_message_id_tenant ON sms_records (message_id, tenant_id);
@@ -0,0 +1,942 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps records in the sms_records collection, and older ones in
+// sms_records_cold once the tiering job has moved them. Queries reaching back
+// past the cold tier boundary read both collections and merge the results
+// Queries use the configured read preference and ingestion the configured write concern
+type MongoStore struct {
+	db *db.Mongo
+}
+
+// NewMongoStore creates a store on the database m is connected to
+func NewMongoStore(m *db.Mongo) *MongoStore {
+	return &MongoStore{db: m}
+}
+
+// InsertMessage stores record with a single insert, unless the tenant's message
+// with its message_id is already in either tier
+func (m *MongoStore) InsertMessage(ctx context.Context, record *models.SMSRecord) error {
+	if record.ID.IsZero() {
+		record.ID = primitive.NewObjectID()
+	}
+
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	duplicates, err := m.coldDuplicates(insertCtx, []*models.SMSRecord{record})
+	if err != nil {
+		return err
+	}
+	if duplicates[0] {
+		return ErrDuplicate
+	}
+
+	_, err = m.db.GetIngestCollection().InsertOne(insertCtx, record)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert SMS record: %w", err)
+	}
+	return nil
+}
+
+// InsertMessages stores records with a single unordered bulk write; records whose
+// tenant's message is already in the cold tier are reported as duplicates unwritten
+// Failed records keep the driver's error, wrapped as a WriteException so callers
+// can inspect codes and labels
+func (m *MongoStore) InsertMessages(ctx context.Context, records []*models.SMSRecord) (InsertResult, error) {
+	duplicates, err := m.coldDuplicates(ctx, records)
+	if err != nil {
+		return InsertResult{}, err
+	}
+
+	result := InsertResult{Duplicates: make(map[int]bool), Failed: make(map[int]error)}
+
+	var writes []mongo.WriteModel
+	var indexes []int // the index in records of each write
+	for i, record := range records {
+		if record.ID.IsZero() {
+			record.ID = primitive.NewObjectID()
+		}
+		if duplicates[i] {
+			result.Duplicates[i] = true
+			continue
+		}
+		writes = append(writes, mongo.NewInsertOneModel().SetDocument(record))
+		indexes = append(indexes, i)
+	}
+	if len(writes) == 0 {
+		return result, nil
+	}
+
+	_, err = m.db.GetIngestCollection().BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil && len(bulkErr.WriteErrors) > 0 {
+		for _, writeErr := range bulkErr.WriteErrors {
+			recordErr := mongo.WriteException{WriteErrors: mongo.WriteErrors{writeErr.WriteError}, Labels: bulkErr.Labels}
+			if mongo.IsDuplicateKeyError(recordErr) {
+				result.Duplicates[indexes[writeErr.Index]] = true
+				continue
+			}
+			result.Failed[indexes[writeErr.Index]] = recordErr
+		}
+	} else if err != nil {
+		return InsertResult{}, fmt.Errorf("failed to insert SMS records: %w", err)
+	}
+
+	return result, nil
+}
+
+// bodyRegexMaxTime bounds the server time of queries filtering by body_regex,
+// which MongoDB evaluates on every message of the user
+const bodyRegexMaxTime = 5 * time.Second
+
+// messageFilter translates a MessageQuery into a MongoDB filter document
+func messageFilter(tenantID string, query *models.MessageQuery) bson.M {
+	filter := bson.M{"tenant_id": tenantID, "user_id": query.UserID}
+
+	if len(query.Statuses) > 0 {
+		filter["status"] = bson.M{"$in": query.Statuses}
+	}
+	if query.PhoneNumber != "" {
+		filter["phone_number"] = query.PhoneNumber
+	}
+	if query.Carrier != "" {
+		filter["carrier"] = query.Carrier
+	}
+	if query.CountryCode != "" {
+		filter["country_code"] = query.CountryCode
+	}
+	if query.DialCode != "" {
+		filter["dial_code"] = query.DialCode
+	}
+	if query.SenderID != "" {
+		filter["sender_id"] = query.SenderID
+	}
+	// Records from unregistered senders have no sender_type field
+	if query.SenderType != "" {
+		filter["sender_type"] = query.SenderType
+	} else if query.Registered {
+		filter["sender_type"] = bson.M{"$exists": true}
+	}
+	if query.SegmentCount > 0 {
+		filter["segment_count"] = query.SegmentCount
+	}
+	if query.Language != "" {
+		filter["language"] = query.Language
+	}
+	// Records without flags have no flags field
+	if query.Flag != "" {
+		filter["flags"] = query.Flag
+	} else if query.Flagged {
+		filter["flags"] = bson.M{"$exists": true}
+	}
+	if query.BodyRegex != "" {
+		filter["message"] = bson.M{"$regex": query.BodyRegex}
+	}
+	switch query.Direction {
+	case models.DirectionOutbound:
+		// Records stored before inbound ingestion have no direction
+		filter["direction"] = bson.M{"$in": bson.A{models.DirectionOutbound, nil}}
+	case models.DirectionInbound:
+		filter["direction"] = models.DirectionInbound
+	}
+
+	createdAt := bson.M{}
+	if !query.Since.IsZero() {
+		createdAt["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		createdAt["$lt"] = query.Until
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	return filter
+}
+
+// findOptions applies the query's sort, pagination and projection, and limits
+// its server time to maxTime unless zero
+func findOptions(query *models.MessageQuery, maxTime time.Duration) *options.FindOptions {
+	opts := options.Find().SetSort(sortKeys(query.Sort))
+	if query.Skip > 0 {
+		opts.SetSkip(query.Skip)
+	}
+	if query.Limit > 0 {
+		opts.SetLimit(query.Limit)
+	}
+	if len(query.Fields) > 0 {
+		opts.SetProjection(projection(query.Fields))
+	}
+	// The body can't be indexed, so keep the planner on the user's messages
+	// rather than letting it scan others, and stop runaway patterns
+	if query.BodyRegex != "" {
+		opts.SetHint(db.UserMessagesIndexName)
+		if maxTime == 0 || maxTime > bodyRegexMaxTime {
+			maxTime = bodyRegexMaxTime
+		}
+	}
+	if maxTime > 0 {
+		opts.SetMaxTime(maxTime)
+	}
+	return opts
+}
+
+// sortKeys orders by the sorted field, then newest first, matching the
+// compound indexes on the user's messages
+func sortKeys(sort models.MessageSort) bson.D {
+	direction := -1
+	if sort.Ascending {
+		direction = 1
+	}
+	keys := bson.D{{Key: sort.Column(), Value: direction}}
+	if sort.Column() != "created_at" {
+		keys = append(keys, bson.E{Key: "created_at", Value: -1})
+	}
+	return keys
+}
+
+// projection includes the documents' fields with the given JSON names, which match
+// their BSON names apart from the ID. The timestamps are always read, since
+// they identify versions of a message list
+func projection(fields []string) bson.M {
+	include := bson.M{"created_at": 1, "updated_at": 1}
+	for _, field := range fields {
+		if field == "id" {
+			field = "_id"
+		}
+		include[field] = 1
+	}
+	return include
+}
+
+// FindMessages queries the user's messages
+func (m *MongoStore) FindMessages(ctx context.Context, tenantID string, query *models.MessageQuery) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := m.db.QueryContext(ctx)
+	defer cancel()
+
+	cold, err := m.coldTierReached(queryCtx, query.Since)
+	if err != nil {
+		return nil, err
+	}
+	filter := messageFilter(tenantID, query)
+	if !cold {
+		records, err := findRecords(queryCtx, m.db.GetQueryCollection(), filter, findOptions(query, m.db.QueryTimeout()))
+		if err != nil {
+			return nil, queryError(query, "failed to query messages", err)
+		}
+		return records, nil
+	}
+
+	// The hot tier is read first, so a message moved in between is read twice rather than missed
+	opts := findOptions(tierQuery(query), m.db.QueryTimeout())
+	hot, err := findRecords(queryCtx, m.db.GetQueryCollection(), filter, opts)
+	if err != nil {
+		return nil, queryError(query, "failed to query messages", err)
+	}
+	coldRecords, err := findRecords(queryCtx, m.db.GetColdQueryCollection(), filter, opts)
+	if err != nil {
+		return nil, queryError(query, "failed to query cold messages", err)
+	}
+	merged := mergeRecords(hot, coldRecords, func(a, b *models.SMSRecord) int { return models.CompareRecords(a, b, query.Sort) })
+	return page(merged, query.Skip, query.Limit), nil
+}
+
+// StreamMessages decodes the user's messages one at a time from a cursor, or
+// from a cursor on each tier merged in sort order
+func (m *MongoStore) StreamMessages(ctx context.Context, tenantID string, query *models.MessageQuery, fn func(*models.SMSRecord) error) error {
+	cold, err := m.coldTierReached(ctx, query.Since)
+	if err != nil {
+		return err
+	}
+	if cold {
+		return m.streamTiers(ctx, tenantID, query, fn)
+	}
+
+	// No fixed timeout: the stream lives as long as the caller's context
+	cursor, err := m.db.GetQueryCollection().Find(ctx, messageFilter(tenantID, query), findOptions(query, 0))
+	if err != nil {
+		return queryError(query, "failed to query messages", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var record models.SMSRecord
+		if err := cursor.Decode(&record); err != nil {
+			return fmt.Errorf("failed to decode message: %w", err)
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return queryError(query, "cursor error while streaming messages", err)
+	}
+	return nil
+}
+
+// streamTiers streams the user's messages of both tiers. A message moved by
+// the tiering job while the stream passes it may be missed or sent twice
+func (m *MongoStore) streamTiers(ctx context.Context, tenantID string, query *models.MessageQuery, fn func(*models.SMSRecord) error) error {
+	filter := messageFilter(tenantID, query)
+	opts := findOptions(tierQuery(query), 0)
+	hot, err := m.db.GetQueryCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return queryError(query, "failed to query messages", err)
+	}
+	defer hot.Close(ctx)
+	cold, err := m.db.GetColdQueryCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return queryError(query, "failed to query cold messages", err)
+	}
+	defer cold.Close(ctx)
+
+	compare := func(a, b *models.SMSRecord) int { return models.CompareRecords(a, b, query.Sort) }
+	var fnErr error
+	err = mergeCursors(ctx, hot, cold, compare, query.Skip, query.Limit, func(record *models.SMSRecord) error {
+		fnErr = fn(record)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return queryError(query, "cursor error while streaming messages", err)
+	}
+	return nil
+}
+
+// queryError describes a failed find of query. A body_regex query stopped by
+// its time limit is the pattern's fault rather than the database's, so it is
+// returned as ErrQueryTimeout alone, which the circuit breaker ignores
+func queryError(query *models.MessageQuery, message string, err error) error {
+	var cmdErr mongo.CommandError
+	if query.BodyRegex != "" && errors.As(err, &cmdErr) && cmdErr.IsMaxTimeMSExpiredError() {
+		return ErrQueryTimeout
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// CountMessages counts the user's messages
+func (m *MongoStore) CountMessages(ctx context.Context, tenantID string, query *models.MessageQuery) (int64, error) {
+	queryCtx, cancel := m.db.QueryContext(ctx)
+	defer cancel()
+
+	filter := messageFilter(tenantID, query)
+	opts := options.Count().SetMaxTime(m.db.QueryTimeout())
+	count, err := m.db.GetQueryCollection().CountDocuments(queryCtx, filter, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	cold, err := m.coldTierReached(queryCtx, query.Since)
+	if err != nil || !cold {
+		return count, err
+	}
+	coldCount, err := m.db.GetColdQueryCollection().CountDocuments(queryCtx, filter, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count cold messages: %w", err)
+	}
+	return count + coldCount, nil
+}
+
+// MessageStats groups the user's messages by day, status and direction in an
+// aggregation pipeline, and rolls the groups up into weeks and totals
+func (m *MongoStore) MessageStats(ctx context.Context, tenantID string, query *models.MessageQuery) (*models.MessageStats, error) {
+	queryCtx, cancel := m.db.QueryContext(ctx)
+	defer cancel()
+
+	cold, err := m.coldTierReached(queryCtx, query.Since)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := append(matchTiers(messageFilter(tenantID, query), cold),
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"day":       bson.M{"$dateTrunc": bson.M{"date": "$created_at", "unit": "day"}},
+				"status":    "$status",
+				"direction": "$direction",
+			},
+			"count": bson.M{"$sum": 1},
+			"units": bson.M{"$sum": bson.M{"$max": bson.A{"$segment_count", 1}}},
+		}}},
+	)
+	cursor, err := m.db.GetQueryCollection().Aggregate(queryCtx, pipeline, options.Aggregate().SetMaxTime(m.db.QueryTimeout()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate message stats: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	var groups []struct {
+		ID struct {
+			Day       time.Time `bson:"day"`
+			Status    string    `bson:"status"`
+			Direction string    `bson:"direction"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+		Units int64 `bson:"units"`
+	}
+	if err := cursor.All(queryCtx, &groups); err != nil {
+		return nil, fmt.Errorf("failed to decode message stats: %w", err)
+	}
+
+	stats := newStatsBuilder()
+	for _, group := range groups {
+		stats.add(group.ID.Day, group.ID.Status, group.ID.Direction, group.Count, group.Units)
+	}
+	return stats.build(), nil
+}
+
+// Conversations groups the user's messages by phone number in an aggregation,
+// walking the tenant_id, user_id, phone_number index
+func (m *MongoStore) Conversations(ctx context.Context, tenantID, userID string, skip, limit int64) ([]*models.Conversation, error) {
+	queryCtx, cancel := m.db.QueryContext(ctx)
+	defer cancel()
+
+	cold, err := m.coldTierReached(queryCtx, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	pipeline := append(matchTiers(bson.M{"tenant_id": tenantID, "user_id": userID}, cold),
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":             "$phone_number",
+			"message_count":   bson.M{"$sum": 1},
+			"last_message_at": bson.M{"$first": "$created_at"},
+			"last_message":    bson.M{"$first": "$message"},
+			"last_status":     bson.M{"$first": "$status"},
+			"last_direction":  bson.M{"$first": bson.M{"$ifNull": bson.A{"$direction", models.DirectionOutbound}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "last_message_at", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$skip", Value: skip}},
+		bson.D{{Key: "$limit", Value: limit}},
+	)
+	cursor, err := m.db.GetQueryCollection().Aggregate(queryCtx, pipeline, options.Aggregate().SetMaxTime(m.db.QueryTimeout()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate conversations: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	var conversations []*models.Conversation
+	if err := cursor.All(queryCtx, &conversations); err != nil {
+		return nil, fmt.Errorf("failed to decode conversations: %w", err)
+	}
+	return conversations, nil
+}
+
+// FindDuplicates groups the tenant's messages of the window by message_id and
+// by content in two aggregations
+func (m *MongoStore) FindDuplicates(ctx context.Context, tenantID string, since, until time.Time, limit int64) ([]*models.DuplicateGroup, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cold, err := m.coldTierReached(queryCtx, since)
+	if err != nil {
+		return nil, err
+	}
+	window := bson.M{"tenant_id": tenantID, "created_at": bson.M{"$gte": since, "$lt": until}}
+	withMessageID := bson.M{"tenant_id": tenantID, "created_at": window["created_at"], "message_id": bson.M{"$exists": true, "$ne": ""}}
+	byMessageID, err := m.aggregateDuplicates(queryCtx, withMessageID, cold, "$message_id", models.DuplicateByMessageID, limit)
+	if err != nil {
+		return nil, err
+	}
+	byContent, err := m.aggregateDuplicates(queryCtx, window, cold,
+		bson.M{"user_id": "$user_id", "message": "$message", "created_at": "$created_at"}, models.DuplicateByContent, limit)
+	if err != nil {
+		return nil, err
+	}
+	return topDuplicates(append(byMessageID, byContent...), limit), nil
+}
+
+// DeliveryStats counts the tenant's outbound messages of the window by bucket,
+// sender ID, carrier, country and status in an aggregation, walking the
+// tenant_id, created_at index, or that of the country or dial code filtered by
+func (m *MongoStore) DeliveryStats(ctx context.Context, tenantID string, query *models.DeliveryQuery) (*models.DeliveryStats, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cold, err := m.coldTierReached(queryCtx, query.Since)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{
+		"tenant_id":  tenantID,
+		"created_at": bson.M{"$gte": query.Since, "$lt": query.Until},
+		// Records stored before inbound ingestion have no direction
+		"direction": bson.M{"$in": bson.A{models.DirectionOutbound, nil}},
+	}
+	if query.SenderID != "" {
+		filter["sender_id"] = query.SenderID
+	}
+	if query.Carrier != "" {
+		filter["carrier"] = query.Carrier
+	}
+	if query.CountryCode != "" {
+		filter["country_code"] = query.CountryCode
+	}
+	if query.DialCode != "" {
+		filter["dial_code"] = query.DialCode
+	}
+	pipeline := append(matchTiers(filter, cold),
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"bucket":       bson.M{"$dateTrunc": bson.M{"date": "$created_at", "unit": query.Interval}},
+				"sender_id":    "$sender_id",
+				"carrier":      "$carrier",
+				"country_code": "$country_code",
+				"status":       "$status",
+			},
+			"count": bson.M{"$sum": 1},
+		}}},
+	)
+	cursor, err := m.db.GetQueryCollection().Aggregate(queryCtx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate delivery stats: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	var groups []struct {
+		ID struct {
+			Bucket      time.Time `bson:"bucket"`
+			SenderID    string    `bson:"sender_id"`
+			Carrier     string    `bson:"carrier"`
+			CountryCode string    `bson:"country_code"`
+			Status      string    `bson:"status"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(queryCtx, &groups); err != nil {
+		return nil, fmt.Errorf("failed to decode delivery stats: %w", err)
+	}
+
+	delivery := newDeliveryBuilder(query.Interval)
+	for _, group := range groups {
+		delivery.add(group.ID.Bucket, group.ID.SenderID, group.ID.Carrier, group.ID.CountryCode, group.ID.Status, group.Count)
+	}
+	return delivery.build(), nil
+}
+
+// aggregateDuplicates returns up to limit groups of more than one of the records
+// matching filter that share key, largest first, including the cold tier's if cold
+func (m *MongoStore) aggregateDuplicates(ctx context.Context, filter bson.M, cold bool, key any, kind string, limit int64) ([]*models.DuplicateGroup, error) {
+	pipeline := append(matchTiers(filter, cold),
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":        key,
+			"message_id": bson.M{"$first": "$message_id"},
+			"user_id":    bson.M{"$first": "$user_id"},
+			"created_at": bson.M{"$min": "$created_at"},
+			"count":      bson.M{"$sum": 1},
+			"ids":        bson.M{"$push": "$_id"},
+		}}},
+		bson.D{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "created_at", Value: -1}}}},
+		bson.D{{Key: "$limit", Value: limit}},
+		bson.D{{Key: "$project", Value: bson.M{
+			"message_id": 1, "user_id": 1, "created_at": 1, "count": 1,
+			"ids": bson.M{"$slice": bson.A{"$ids", models.MaxDuplicateIDs}},
+		}}},
+	)
+	cursor, err := m.db.GetQueryCollection().Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate duplicate messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var groups []*models.DuplicateGroup
+	for cursor.Next(ctx) {
+		var group struct {
+			MessageID string               `bson:"message_id"`
+			UserID    string               `bson:"user_id"`
+			CreatedAt time.Time            `bson:"created_at"`
+			Count     int64                `bson:"count"`
+			IDs       []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return nil, fmt.Errorf("failed to decode duplicate messages: %w", err)
+		}
+		duplicate := &models.DuplicateGroup{Kind: kind, UserID: group.UserID, CreatedAt: group.CreatedAt, Count: group.Count}
+		if kind == models.DuplicateByMessageID {
+			duplicate.MessageID = group.MessageID
+		}
+		for _, id := range group.IDs {
+			duplicate.IDs = append(duplicate.IDs, id.Hex())
+		}
+		groups = append(groups, duplicate)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error while aggregating duplicate messages: %w", err)
+	}
+	return groups, nil
+}
+
+// FindMessagesByPhoneNumber queries the tenant's messages by phone number
+func (m *MongoStore) FindMessagesByPhoneNumber(ctx context.Context, tenantID, phoneNumber string, skip, limit int64) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := m.db.QueryContext(ctx)
+	defer cancel()
+
+	cold, err := m.coldTierReached(queryCtx, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"tenant_id": tenantID, "phone_number": phoneNumber}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetMaxTime(m.db.QueryTimeout())
+	if !cold {
+		records, err := findRecords(queryCtx, m.db.GetQueryCollection(), filter, opts.SetSkip(skip).SetLimit(limit))
+		if err != nil {
+			return nil, fmt.Errorf("failed to query messages: %w", err)
+		}
+		return records, nil
+	}
+
+	if limit > 0 {
+		opts.SetLimit(skip + limit)
+	}
+	hot, err := findRecords(queryCtx, m.db.GetQueryCollection(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	coldRecords, err := findRecords(queryCtx, m.db.GetColdQueryCollection(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cold messages: %w", err)
+	}
+	return page(mergeRecords(hot, coldRecords, newestFirst), skip, limit), nil
+}
+
+// GetMessage looks a record up by its document ID
+func (m *MongoStore) GetMessage(ctx context.Context, tenantID string, id primitive.ObjectID) (*models.SMSRecord, error) {
+	queryCtx, cancel := m.db.QueryContext(ctx)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "tenant_id": tenantID}
+	opts := options.FindOne().SetMaxTime(m.db.QueryTimeout())
+	var record models.SMSRecord
+	err := m.db.GetQueryCollection().FindOne(queryCtx, filter, opts).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		cold, coldErr := m.coldTierReached(queryCtx, time.Time{})
+		if coldErr != nil {
+			return nil, coldErr
+		}
+		if cold {
+			err = m.db.GetColdQueryCollection().FindOne(queryCtx, filter, opts).Decode(&record)
+		}
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message: %w", err)
+	}
+	return &record, nil
+}
+
+// ClaimRequeue increments the record's requeue_count with a single update that
+// only matches while it is below max, in the cold tier if the record was moved
+func (m *MongoStore) ClaimRequeue(ctx context.Context, tenantID string, id primitive.ObjectID, max int) (int, error) {
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "tenant_id": tenantID, "requeue_count": bson.M{"$not": bson.M{"$gte": max}}}
+	update := bson.M{"$inc": bson.M{"requeue_count": 1}}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"requeue_count": 1})
+
+	var claimed struct {
+		RequeueCount int `bson:"requeue_count"`
+	}
+	err := m.db.GetIngestCollection().FindOneAndUpdate(updateCtx, filter, update, opts).Decode(&claimed)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		cold, coldErr := m.coldTierReached(updateCtx, time.Time{})
+		if coldErr != nil {
+			return 0, coldErr
+		}
+		if cold {
+			err = m.db.GetColdIngestCollection().FindOneAndUpdate(updateCtx, filter, update, opts).Decode(&claimed)
+		}
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Either the record is at its limit or there is no such record
+		if _, err := m.GetMessage(updateCtx, tenantID, id); err != nil {
+			return 0, err
+		}
+		return 0, ErrRequeueLimit
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count message requeue: %w", err)
+	}
+	return claimed.RequeueCount, nil
+}
+
+// UpdateStatus updates the record with the given message_id
+func (m *MongoStore) UpdateStatus(ctx context.Context, messageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return m.updateStatus(ctx, bson.M{"message_id": messageID}, change)
+}
+
+// UpdateStatusByProviderID updates the tenant's record with the given provider_message_id
+func (m *MongoStore) UpdateStatusByProviderID(ctx context.Context, tenantID, providerMessageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return m.updateStatus(ctx, bson.M{"tenant_id": tenantID, "provider_message_id": providerMessageID}, change)
+}
+
+// updateStatus sets the current status and appends to the status history in a single
+// update so the two fields can never disagree. Messages not in sms_records are
+// looked up in the cold tier, for receipts and corrections of old messages
+func (m *MongoStore) updateStatus(ctx context.Context, filter bson.M, change models.StatusChange) (*models.SMSRecord, error) {
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":     change.Status,
+			"updated_at": change.ChangedAt,
+		},
+		"$push": bson.M{"status_history": change},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var record models.SMSRecord
+	err := m.db.GetIngestCollection().FindOneAndUpdate(updateCtx, filter, update, opts).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		cold, coldErr := m.coldTierReached(updateCtx, time.Time{})
+		if coldErr != nil {
+			return nil, coldErr
+		}
+		if cold {
+			err = m.db.GetColdIngestCollection().FindOneAndUpdate(updateCtx, filter, update, opts).Decode(&record)
+		}
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message status: %w", err)
+	}
+	return &record, nil
+}
+
+// DeleteUserMessages deletes the messages of both tiers and writes the audit
+// record in one transaction where the deployment supports them
+func (m *MongoStore) DeleteUserMessages(ctx context.Context, tenantID string, userIDs []string, audit *models.AuditRecord) (int64, error) {
+	var deleted int64
+	err := m.db.WithTransaction(ctx, func(ctx context.Context) error {
+		deleteCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		filter := bson.M{"tenant_id": tenantID, "user_id": bson.M{"$in": userIDs}}
+		result, err := m.db.GetCollection().DeleteMany(deleteCtx, filter)
+		if err != nil {
+			return fmt.Errorf("failed to delete messages: %w", err)
+		}
+		deleted = result.DeletedCount
+		// Erase the cold tier even while no boundary is cached, so nothing can be left behind
+		result, err = m.db.GetColdCollection().DeleteMany(deleteCtx, filter)
+		if err != nil {
+			return fmt.Errorf("failed to delete cold messages: %w", err)
+		}
+		deleted += result.DeletedCount
+
+		insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		audit.ResultCount = deleted
+		if _, err := m.db.GetAuditCollection().InsertOne(insertCtx, audit); err != nil {
+			return fmt.Errorf("failed to insert audit record: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		// Without a transaction the erasure may already have happened; erasing again is harmless
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// InsertAuditRecord writes audit to the audit_log collection
+func (m *MongoStore) InsertAuditRecord(ctx context.Context, audit *models.AuditRecord) error {
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := m.db.GetAuditCollection().InsertOne(insertCtx, audit); err != nil {
+		return fmt.Errorf("failed to insert audit record: %w", err)
+	}
+	return nil
+}
+
+// FindAuditRecords queries one page of the tenant's audit records
+func (m *MongoStore) FindAuditRecords(ctx context.Context, tenantID string, query *models.AuditQuery) ([]*models.AuditRecord, error) {
+	queryCtx, cancel := m.db.QueryContext(ctx)
+	defer cancel()
+
+	filter := bson.M{"tenant_id": tenantID}
+	if query.Actor != "" {
+		filter["actor"] = query.Actor
+	}
+	if query.UserID != "" {
+		filter["user_id"] = query.UserID
+	}
+	if query.Action != "" {
+		filter["action"] = query.Action
+	}
+	if query.TargetID != "" {
+		filter["target_id"] = query.TargetID
+	}
+	createdAt := bson.M{}
+	if !query.Since.IsZero() {
+		createdAt["$gte"] = query.Since
+	}
+	if !query.Until.IsZero() {
+		createdAt["$lt"] = query.Until
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(query.Skip).SetMaxTime(m.db.QueryTimeout())
+	if query.Limit > 0 {
+		opts.SetLimit(query.Limit)
+	}
+	cursor, err := m.db.GetAuditCollection().Find(queryCtx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit records: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	var records []*models.AuditRecord
+	if err := cursor.All(queryCtx, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode audit records: %w", err)
+	}
+	return records, nil
+}
+
+// FindMessagesOlderThan queries old messages of the selected tenants
+func (m *MongoStore) FindMessagesOlderThan(ctx context.Context, cutoff time.Time, tenants models.TenantFilter, limit int64) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"created_at": bson.M{"$lt": cutoff}}
+	tenantFilter := bson.M{}
+	if len(tenants.Only) > 0 {
+		tenantFilter["$in"] = tenants.Only
+	}
+	if len(tenants.Except) > 0 {
+		tenantFilter["$nin"] = tenants.Except
+	}
+	if len(tenantFilter) > 0 {
+		filter["tenant_id"] = tenantFilter
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetLimit(limit)
+
+	records, err := findRecords(queryCtx, m.db.GetCollection(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query old messages: %w", err)
+	}
+	cold, err := m.coldTierReached(queryCtx, time.Time{})
+	if err != nil || !cold {
+		return records, err
+	}
+	coldRecords, err := findRecords(queryCtx, m.db.GetColdCollection(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query old cold messages: %w", err)
+	}
+	return page(mergeRecords(records, coldRecords, oldestFirst), 0, limit), nil
+}
+
+// FindMessagesInIDRange queries messages by document ID across all tenants
+func (m *MongoStore) FindMessagesInIDRange(ctx context.Context, after, before primitive.ObjectID, limit int64) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": bson.M{"$gt": after, "$lt": before}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(limit)
+
+	records, err := findRecords(queryCtx, m.db.GetCollection(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages by ID range: %w", err)
+	}
+	cold, err := m.coldTierReached(queryCtx, time.Time{})
+	if err != nil || !cold {
+		return records, err
+	}
+	coldRecords, err := findRecords(queryCtx, m.db.GetColdCollection(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cold messages by ID range: %w", err)
+	}
+	return page(mergeRecords(records, coldRecords, byID), 0, limit), nil
+}
+
+// DeleteMessages deletes records by document ID across all tenants, from both tiers
+func (m *MongoStore) DeleteMessages(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	deleteCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	result, err := m.db.GetCollection().DeleteMany(deleteCtx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete messages: %w", err)
+	}
+	cold, err := m.coldTierReached(deleteCtx, time.Time{})
+	if err != nil || !cold {
+		return result.DeletedCount, err
+	}
+	coldResult, err := m.db.GetColdCollection().DeleteMany(deleteCtx, filter)
+	if err != nil {
+		return result.DeletedCount, fmt.Errorf("failed to delete cold messages: %w", err)
+	}
+	return result.DeletedCount + coldResult.DeletedCount, nil
+}
+
+// PendingStoredEvents queries the outbox across all tenants, in insertion order
+func (m *MongoStore) PendingStoredEvents(ctx context.Context, limit int64) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := m.db.GetCollection().Find(queryCtx, bson.M{"stored_event_pending": true}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending stored events: %w", err)
+	}
+	defer cursor.Close(queryCtx)
+
+	var records []*models.SMSRecord
+	if err := cursor.All(queryCtx, &records); err != nil {
+		return nil, fmt.Errorf("failed to decode pending stored events: %w", err)
+	}
+	return records, nil
+}
+
+// ClearStoredEventsPending unsets the outbox flag across all tenants
+func (m *MongoStore) ClearStoredEventsPending(ctx context.Context, ids []primitive.ObjectID) error {
+	updateCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := m.db.GetCollection().UpdateMany(updateCtx, bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$unset": bson.M{"stored_event_pending": ""}})
+	if err != nil {
+		return fmt.Errorf("failed to clear pending stored events: %w", err)
+	}
+	return nil
+}
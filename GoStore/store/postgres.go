@@ -0,0 +1,879 @@
+package store
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// migrationLockID serializes migrations between instances starting together
+const migrationLockID = 7_310_511_003
+
+// recordColumns are the sms_records columns in the order scanRecord reads them
+const recordColumns = `id, message_id, provider_message_id, tenant_id, user_id, phone_number, message,
+	status, direction, status_history, created_at, updated_at, stored_event_pending, media,
+	carrier, country_code, sender_id, segment_count, encoding, script, language, flags, sender_type,
+	raw_user_id, raw_phone_number, dial_code`
+
+// matchUserMessages is the WHERE clause of a MessageQuery; unset filters are passed as NULL
+const matchUserMessages = `tenant_id = $1 AND user_id = $2
+	AND ($3::text[] IS NULL OR status = ANY($3))
+	AND ($4::timestamptz IS NULL OR created_at >= $4)
+	AND ($5::timestamptz IS NULL OR created_at < $5)
+	AND ($6::text IS NULL OR phone_number = $6)
+	AND ($7::text IS NULL OR carrier = $7)
+	AND ($8::text IS NULL OR country_code = $8)
+	AND ($9::text IS NULL OR sender_id = $9)
+	AND ($10::text IS NULL OR direction = $10 OR ($10 = 'outbound' AND direction IS NULL))
+	AND ($11::integer IS NULL OR segment_count = $11)
+	AND ($12::text IS NULL OR language = $12)
+	AND ($13::text IS NULL OR $13 = ANY(flags))
+	AND (NOT $14::boolean OR flags IS NOT NULL)
+	AND ($15::text IS NULL OR sender_type = $15)
+	AND (NOT $16::boolean OR sender_type IS NOT NULL)
+	AND ($17::text IS NULL OR dial_code = $17)`
+
+// errBodyRegexUnsupported is returned for queries filtering by body_regex, whose
+// patterns PostgreSQL's regular expressions would not match the same way
+var errBodyRegexUnsupported = fmt.Errorf("postgres storage: body_regex: %w", errors.ErrUnsupported)
+
+// findMessagesSorted is find_messages without its ORDER BY, for sorts other than
+// newest first; the ORDER BY is built from models.MessageSort.Column only
+const findMessagesSorted = `SELECT ` + recordColumns + ` FROM sms_records WHERE ` + matchUserMessages
+
+// auditColumns are the audit_log columns in the order insert_audit writes them
+const auditColumns = `action, tenant_id, actor, auth_method, endpoint, filters, user_id, phone_number,
+	target_id, result_count, remote_addr, created_at`
+
+// postgresStatements are prepared on every pooled connection, by name
+var postgresStatements = map[string]string{
+	"insert_message": `INSERT INTO sms_records (` + recordColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)
+		ON CONFLICT DO NOTHING`,
+	"find_messages": `SELECT ` + recordColumns + ` FROM sms_records WHERE ` + matchUserMessages + `
+		ORDER BY created_at DESC OFFSET $18 LIMIT $19`,
+	"find_phone_messages": `SELECT ` + recordColumns + ` FROM sms_records WHERE tenant_id = $1 AND phone_number = $2
+		ORDER BY created_at DESC OFFSET $3 LIMIT $4`,
+	"conversations": `SELECT phone_number, count(*), max(created_at),
+		(array_agg(message ORDER BY created_at DESC))[1],
+		(array_agg(status ORDER BY created_at DESC))[1],
+		(array_agg(coalesce(direction, 'outbound') ORDER BY created_at DESC))[1]
+		FROM sms_records WHERE tenant_id = $1 AND user_id = $2
+		GROUP BY phone_number ORDER BY 3 DESC, 1 OFFSET $3 LIMIT $4`,
+	"count_messages": `SELECT count(*) FROM sms_records WHERE ` + matchUserMessages,
+	"message_stats": `SELECT date_trunc('day', created_at, 'UTC'), status, coalesce(direction, ''), count(*),
+		sum(greatest(segment_count, 1)) FROM sms_records WHERE ` + matchUserMessages + ` GROUP BY 1, 2, 3`,
+	"duplicate_contents": `SELECT user_id, created_at, count(*), (array_agg(id ORDER BY id))[1:$4] FROM sms_records
+		WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY user_id, message, created_at HAVING count(*) > 1 ORDER BY 3 DESC, 2 DESC LIMIT $5`,
+	"delivery_stats": `SELECT date_trunc($4::text, created_at, 'UTC'), coalesce(sender_id, ''), coalesce(carrier, ''),
+		coalesce(country_code, ''), status, count(*) FROM sms_records
+		WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3
+		AND (direction IS NULL OR direction = 'outbound')
+		AND ($5::text IS NULL OR sender_id = $5)
+		AND ($6::text IS NULL OR carrier = $6)
+		AND ($7::text IS NULL OR country_code = $7)
+		AND ($8::text IS NULL OR dial_code = $8)
+		GROUP BY 1, 2, 3, 4, 5`,
+	"get_message": `SELECT ` + recordColumns + ` FROM sms_records WHERE id = $1 AND tenant_id = $2`,
+	"claim_requeue": `UPDATE sms_records SET requeue_count = requeue_count + 1
+		WHERE id = $1 AND tenant_id = $2 AND requeue_count < $3 RETURNING requeue_count`,
+	"update_status": `UPDATE sms_records
+		SET status = $1, updated_at = $2, status_history = status_history || jsonb_build_array($3::jsonb)
+		WHERE id = (SELECT id FROM sms_records WHERE message_id = $4 LIMIT 1) RETURNING ` + recordColumns,
+	"update_status_by_provider_id": `UPDATE sms_records
+		SET status = $1, updated_at = $2, status_history = status_history || jsonb_build_array($3::jsonb)
+		WHERE tenant_id = $4 AND provider_message_id = $5 RETURNING ` + recordColumns,
+	"delete_user_messages": `DELETE FROM sms_records WHERE tenant_id = $1 AND user_id = ANY($2)`,
+	"insert_audit": `INSERT INTO audit_log (` + auditColumns + `)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, $7, NULLIF($8, ''),
+		NULLIF($9, ''), $10, NULLIF($11, ''), $12)`,
+	"find_audit": `SELECT action, tenant_id, coalesce(actor, ''), coalesce(auth_method, ''), coalesce(endpoint, ''),
+		filters, user_id, coalesce(phone_number, ''), coalesce(target_id, ''), result_count,
+		coalesce(remote_addr, ''), created_at FROM audit_log WHERE tenant_id = $1
+		AND ($2::text IS NULL OR actor = $2)
+		AND ($3::text IS NULL OR user_id = $3)
+		AND ($4::text IS NULL OR action = $4)
+		AND ($5::text IS NULL OR target_id = $5)
+		AND ($6::timestamptz IS NULL OR created_at >= $6)
+		AND ($7::timestamptz IS NULL OR created_at < $7)
+		ORDER BY created_at DESC, id DESC OFFSET $8 LIMIT $9`,
+	"find_older_than": `SELECT ` + recordColumns + ` FROM sms_records WHERE created_at < $1
+		AND ($2::text[] IS NULL OR tenant_id = ANY($2)) AND tenant_id <> ALL(coalesce($3::text[], '{}'))
+		ORDER BY created_at LIMIT $4`,
+	"find_in_id_range":      `SELECT ` + recordColumns + ` FROM sms_records WHERE id > $1 AND id < $2 ORDER BY id LIMIT $3`,
+	"delete_messages":       `DELETE FROM sms_records WHERE id = ANY($1)`,
+	"pending_stored_events": `SELECT ` + recordColumns + ` FROM sms_records WHERE stored_event_pending ORDER BY id LIMIT $1`,
+	"clear_stored_events":   `UPDATE sms_records SET stored_event_pending = FALSE WHERE id = ANY($1)`,
+}
+
+// PostgresStore keeps records in the sms_records table of a PostgreSQL database
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore connects to the database at url, applies any pending
+// migrations, and prepares the store's statements on every connection
+func NewPostgresStore(ctx context.Context, url string, maxConns int) (*PostgresStore, error) {
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PostgreSQL URL: %w", err)
+	}
+	if maxConns > 0 {
+		cfg.MaxConns = int32(maxConns)
+	}
+
+	// Migrate on a single connection first; statements cannot be prepared before the tables exist
+	conn, err := pgx.ConnectConfig(ctx, cfg.ConnConfig.Copy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	err = migratePostgres(ctx, conn)
+	conn.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		for name, sql := range postgresStatements {
+			if _, err := conn.Prepare(ctx, name, sql); err != nil {
+				return fmt.Errorf("failed to prepare %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+
+	return &PostgresStore{pool: pool}, nil
+}
+
+// migratePostgres applies the embedded migrations not yet recorded in schema_migrations,
+// in version order, each in its own transaction
+func migratePostgres(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	_, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRow(ctx, `SELECT coalesce(max(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	files, err := fs.Glob(postgresMigrations, "migrations/postgres/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		name := file[strings.LastIndex(file, "/")+1:]
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("migration %s has no version prefix", name)
+		}
+		if version <= current {
+			continue
+		}
+
+		sql, err := postgresMigrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s failed: %w", name, err)
+		}
+		slog.Info("Applied PostgreSQL migration", "migration", name)
+	}
+	return nil
+}
+
+// Ping checks that the database is reachable
+func (p *PostgresStore) Ping(ctx context.Context) error {
+	return p.pool.Ping(ctx)
+}
+
+// Close closes every pooled connection
+func (p *PostgresStore) Close() {
+	p.pool.Close()
+}
+
+// nullable maps the empty string to NULL
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// recordArgs returns the insert_message arguments of record
+func recordArgs(record *models.SMSRecord) []any {
+	history := record.StatusHistory
+	if history == nil {
+		history = []models.StatusChange{}
+	}
+	var updatedAt *time.Time
+	if !record.UpdatedAt.IsZero() {
+		updatedAt = &record.UpdatedAt
+	}
+	var media any // NULL without attachments
+	if len(record.Media) > 0 {
+		media = record.Media
+	}
+	var segmentCount *int
+	if record.SegmentCount > 0 {
+		segmentCount = &record.SegmentCount
+	}
+	var flags []string // NULL without flags
+	if len(record.Flags) > 0 {
+		flags = record.Flags
+	}
+	return []any{
+		record.ID.Hex(), nullable(record.MessageID), nullable(record.ProviderMessageID),
+		record.TenantID, record.UserID, record.PhoneNumber, record.Message,
+		record.Status, nullable(record.Direction), history, record.CreatedAt, updatedAt,
+		record.StoredEventPending, media,
+		nullable(record.Carrier), nullable(record.CountryCode), nullable(record.SenderID), segmentCount,
+		nullable(record.Encoding), nullable(record.Script), nullable(record.Language), flags,
+		nullable(record.SenderType), nullable(record.RawUserID), nullable(record.RawPhoneNumber),
+		nullable(record.DialCode),
+	}
+}
+
+// scanRecord reads a row of recordColumns
+func scanRecord(row pgx.Row) (*models.SMSRecord, error) {
+	var (
+		record                                  models.SMSRecord
+		id                                      string
+		messageID, providerMessageID, direction *string
+		carrier, countryCode, senderID          *string
+		dialCode                                *string
+		senderType                              *string
+		rawUserID, rawPhoneNumber               *string
+		encoding, script, language              *string
+		segmentCount                            *int
+		updatedAt                               *time.Time
+	)
+	err := row.Scan(&id, &messageID, &providerMessageID, &record.TenantID, &record.UserID,
+		&record.PhoneNumber, &record.Message, &record.Status, &direction, &record.StatusHistory,
+		&record.CreatedAt, &updatedAt, &record.StoredEventPending, &record.Media,
+		&carrier, &countryCode, &senderID, &segmentCount, &encoding, &script, &language, &record.Flags,
+		&senderType, &rawUserID, &rawPhoneNumber, &dialCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, fmt.Errorf("invalid record ID %q: %w", id, err)
+	}
+	if messageID != nil {
+		record.MessageID = *messageID
+	}
+	if providerMessageID != nil {
+		record.ProviderMessageID = *providerMessageID
+	}
+	if direction != nil {
+		record.Direction = *direction
+	}
+	if carrier != nil {
+		record.Carrier = *carrier
+	}
+	if countryCode != nil {
+		record.CountryCode = *countryCode
+	}
+	if dialCode != nil {
+		record.DialCode = *dialCode
+	}
+	if senderID != nil {
+		record.SenderID = *senderID
+	}
+	if senderType != nil {
+		record.SenderType = *senderType
+	}
+	if rawUserID != nil {
+		record.RawUserID = *rawUserID
+	}
+	if rawPhoneNumber != nil {
+		record.RawPhoneNumber = *rawPhoneNumber
+	}
+	if segmentCount != nil {
+		record.SegmentCount = *segmentCount
+	}
+	if encoding != nil {
+		record.Encoding = *encoding
+	}
+	if script != nil {
+		record.Script = *script
+	}
+	if language != nil {
+		record.Language = *language
+	}
+	record.CreatedAt = record.CreatedAt.UTC()
+	if updatedAt != nil {
+		record.UpdatedAt = updatedAt.UTC()
+	}
+	return &record, nil
+}
+
+// collectRecords reads every row of rows
+func collectRecords(rows pgx.Rows) ([]*models.SMSRecord, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.SMSRecord, error) {
+		return scanRecord(row)
+	})
+}
+
+// hexIDs converts document IDs to the id column's representation
+func hexIDs(ids []primitive.ObjectID) []string {
+	hex := make([]string, len(ids))
+	for i, id := range ids {
+		hex[i] = id.Hex()
+	}
+	return hex
+}
+
+// queryArgs returns the matchUserMessages arguments of query
+func queryArgs(tenantID string, query *models.MessageQuery) []any {
+	var statuses []string
+	if len(query.Statuses) > 0 {
+		statuses = query.Statuses
+	}
+	var since, until *time.Time
+	if !query.Since.IsZero() {
+		since = &query.Since
+	}
+	if !query.Until.IsZero() {
+		until = &query.Until
+	}
+	var phoneNumber *string
+	if query.PhoneNumber != "" {
+		phoneNumber = &query.PhoneNumber
+	}
+	var segmentCount *int
+	if query.SegmentCount > 0 {
+		segmentCount = &query.SegmentCount
+	}
+	return []any{tenantID, query.UserID, statuses, since, until, phoneNumber,
+		nullable(query.Carrier), nullable(query.CountryCode), nullable(query.SenderID), nullable(query.Direction), segmentCount,
+		nullable(query.Language), nullable(query.Flag), query.Flagged,
+		nullable(query.SenderType), query.Registered, nullable(query.DialCode)}
+}
+
+// findStatement returns the prepared find_messages for the default sort, and SQL
+// ordered by the sorted field then newest first for the others
+func findStatement(sort models.MessageSort) string {
+	if sort.IsDefault() {
+		return "find_messages"
+	}
+	direction := "DESC"
+	if sort.Ascending {
+		direction = "ASC"
+	}
+	order := sort.Column() + " " + direction
+	if sort.Column() != "created_at" {
+		order += ", created_at DESC"
+	}
+	return findMessagesSorted + ` ORDER BY ` + order + ` OFFSET $18 LIMIT $19`
+}
+
+// pageArgs returns the OFFSET and LIMIT of query; a NULL limit returns every row
+func pageArgs(query *models.MessageQuery) []any {
+	var limit *int64
+	if query.Limit > 0 {
+		limit = &query.Limit
+	}
+	return []any{query.Skip, limit}
+}
+
+// InsertMessage stores record, ignoring it if its ID or message_id is already stored
+func (p *PostgresStore) InsertMessage(ctx context.Context, record *models.SMSRecord) error {
+	if record.ID.IsZero() {
+		record.ID = primitive.NewObjectID()
+	}
+
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tag, err := p.pool.Exec(insertCtx, "insert_message", recordArgs(record)...)
+	if err != nil {
+		return fmt.Errorf("failed to insert SMS record: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+// InsertMessages sends one insert per record in a single batch, which PostgreSQL
+// runs as one transaction: either every new record is stored or none is
+func (p *PostgresStore) InsertMessages(ctx context.Context, records []*models.SMSRecord) (InsertResult, error) {
+	batch := &pgx.Batch{}
+	for _, record := range records {
+		if record.ID.IsZero() {
+			record.ID = primitive.NewObjectID()
+		}
+		batch.Queue("insert_message", recordArgs(record)...)
+	}
+
+	result := InsertResult{Duplicates: make(map[int]bool), Failed: make(map[int]error)}
+
+	results := p.pool.SendBatch(ctx, batch)
+	defer results.Close()
+	for i := range records {
+		tag, err := results.Exec()
+		if err != nil {
+			return InsertResult{}, fmt.Errorf("failed to insert SMS records: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			result.Duplicates[i] = true
+		}
+	}
+	if err := results.Close(); err != nil {
+		return InsertResult{}, fmt.Errorf("failed to insert SMS records: %w", err)
+	}
+	return result, nil
+}
+
+// FindMessages queries one page of the user's messages
+func (p *PostgresStore) FindMessages(ctx context.Context, tenantID string, query *models.MessageQuery) ([]*models.SMSRecord, error) {
+	if query.BodyRegex != "" {
+		return nil, errBodyRegexUnsupported
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rows, err := p.pool.Query(queryCtx, findStatement(query.Sort), append(queryArgs(tenantID, query), pageArgs(query)...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	records, err := collectRecords(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode messages: %w", err)
+	}
+	return records, nil
+}
+
+// Conversations groups the user's rows by phone number
+func (p *PostgresStore) Conversations(ctx context.Context, tenantID, userID string, skip, limit int64) ([]*models.Conversation, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rows, err := p.pool.Query(queryCtx, "conversations", tenantID, userID, skip, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate conversations: %w", err)
+	}
+	conversations, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Conversation, error) {
+		var c models.Conversation
+		err := row.Scan(&c.Peer, &c.MessageCount, &c.LastMessageAt, &c.LastMessage, &c.LastStatus, &c.LastDirection)
+		c.LastMessageAt = c.LastMessageAt.UTC()
+		return &c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode conversations: %w", err)
+	}
+	return conversations, nil
+}
+
+// FindMessagesByPhoneNumber queries one page of the tenant's messages by phone number
+func (p *PostgresStore) FindMessagesByPhoneNumber(ctx context.Context, tenantID, phoneNumber string, skip, limit int64) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rows, err := p.pool.Query(queryCtx, "find_phone_messages", tenantID, phoneNumber, skip, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	records, err := collectRecords(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode messages: %w", err)
+	}
+	return records, nil
+}
+
+// StreamMessages reads the user's messages row by row
+func (p *PostgresStore) StreamMessages(ctx context.Context, tenantID string, query *models.MessageQuery, fn func(*models.SMSRecord) error) error {
+	if query.BodyRegex != "" {
+		return errBodyRegexUnsupported
+	}
+	// No fixed timeout: the stream lives as long as the caller's context
+	rows, err := p.pool.Query(ctx, findStatement(query.Sort), append(queryArgs(tenantID, query), pageArgs(query)...)...)
+	if err != nil {
+		return fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record, err := scanRecord(rows)
+		if err != nil {
+			return fmt.Errorf("failed to decode message: %w", err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error while streaming messages: %w", err)
+	}
+	return nil
+}
+
+// CountMessages counts the user's messages
+func (p *PostgresStore) CountMessages(ctx context.Context, tenantID string, query *models.MessageQuery) (int64, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int64
+	if err := p.pool.QueryRow(queryCtx, "count_messages", queryArgs(tenantID, query)...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+	return count, nil
+}
+
+// MessageStats groups the user's messages by day, status and direction, and rolls
+// the groups up into weeks and totals
+func (p *PostgresStore) MessageStats(ctx context.Context, tenantID string, query *models.MessageQuery) (*models.MessageStats, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rows, err := p.pool.Query(queryCtx, "message_stats", queryArgs(tenantID, query)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := newStatsBuilder()
+	for rows.Next() {
+		var day time.Time
+		var status, direction string
+		var count, units int64
+		if err := rows.Scan(&day, &status, &direction, &count, &units); err != nil {
+			return nil, fmt.Errorf("failed to decode message stats: %w", err)
+		}
+		stats.add(day, status, direction, count, units)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read message stats: %w", err)
+	}
+	return stats.build(), nil
+}
+
+// FindDuplicates groups the tenant's rows of the window by content. The
+// message_id column is unique per tenant, so no two of its rows can share one
+func (p *PostgresStore) FindDuplicates(ctx context.Context, tenantID string, since, until time.Time, limit int64) ([]*models.DuplicateGroup, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := p.pool.Query(queryCtx, "duplicate_contents", tenantID, since, until, models.MaxDuplicateIDs, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate messages: %w", err)
+	}
+	groups, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.DuplicateGroup, error) {
+		group := models.DuplicateGroup{Kind: models.DuplicateByContent}
+		err := row.Scan(&group.UserID, &group.CreatedAt, &group.Count, &group.IDs)
+		group.CreatedAt = group.CreatedAt.UTC()
+		return &group, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode duplicate messages: %w", err)
+	}
+	return groups, nil
+}
+
+// DeliveryStats counts the tenant's outbound rows of the window by bucket,
+// sender ID, carrier, country and status, walking the tenant_id, created_at
+// index, or that of the country or dial code filtered by
+func (p *PostgresStore) DeliveryStats(ctx context.Context, tenantID string, query *models.DeliveryQuery) (*models.DeliveryStats, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := p.pool.Query(queryCtx, "delivery_stats", tenantID, query.Since, query.Until, query.Interval,
+		nullable(query.SenderID), nullable(query.Carrier), nullable(query.CountryCode), nullable(query.DialCode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivery stats: %w", err)
+	}
+	defer rows.Close()
+
+	delivery := newDeliveryBuilder(query.Interval)
+	for rows.Next() {
+		var bucket time.Time
+		var senderID, carrier, countryCode, status string
+		var count int64
+		if err := rows.Scan(&bucket, &senderID, &carrier, &countryCode, &status, &count); err != nil {
+			return nil, fmt.Errorf("failed to decode delivery stats: %w", err)
+		}
+		delivery.add(bucket, senderID, carrier, countryCode, status, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read delivery stats: %w", err)
+	}
+	return delivery.build(), nil
+}
+
+// GetMessage looks a record up by ID
+func (p *PostgresStore) GetMessage(ctx context.Context, tenantID string, id primitive.ObjectID) (*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	record, err := scanRecord(p.pool.QueryRow(queryCtx, "get_message", id.Hex(), tenantID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message: %w", err)
+	}
+	return record, nil
+}
+
+// ClaimRequeue increments requeue_count with an update that only matches rows below max
+func (p *PostgresStore) ClaimRequeue(ctx context.Context, tenantID string, id primitive.ObjectID, max int) (int, error) {
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var requeues int
+	err := p.pool.QueryRow(updateCtx, "claim_requeue", id.Hex(), tenantID, max).Scan(&requeues)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Either the record is at its limit or there is no such record
+		if _, err := p.GetMessage(updateCtx, tenantID, id); err != nil {
+			return 0, err
+		}
+		return 0, ErrRequeueLimit
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count message requeue: %w", err)
+	}
+	return requeues, nil
+}
+
+// UpdateStatus updates the record with the given message_id
+func (p *PostgresStore) UpdateStatus(ctx context.Context, messageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return p.updateStatus(ctx, "update_status", change, messageID)
+}
+
+// UpdateStatusByProviderID updates the tenant's record with the given provider_message_id
+func (p *PostgresStore) UpdateStatusByProviderID(ctx context.Context, tenantID, providerMessageID string, change models.StatusChange) (*models.SMSRecord, error) {
+	return p.updateStatus(ctx, "update_status_by_provider_id", change, tenantID, providerMessageID)
+}
+
+// updateStatus sets the current status and appends to the status history in a single
+// statement so the two columns can never disagree. keys are the statement's
+// arguments following the change
+func (p *PostgresStore) updateStatus(ctx context.Context, statement string, change models.StatusChange, keys ...any) (*models.SMSRecord, error) {
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	args := append([]any{change.Status, change.ChangedAt, change}, keys...)
+	record, err := scanRecord(p.pool.QueryRow(updateCtx, statement, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message status: %w", err)
+	}
+	return record, nil
+}
+
+// DeleteUserMessages deletes the messages and writes the audit record in one transaction
+func (p *PostgresStore) DeleteUserMessages(ctx context.Context, tenantID string, userIDs []string, audit *models.AuditRecord) (int64, error) {
+	deleteCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var deleted int64
+	err := pgx.BeginFunc(deleteCtx, p.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(deleteCtx, "delete_user_messages", tenantID, userIDs)
+		if err != nil {
+			return fmt.Errorf("failed to delete messages: %w", err)
+		}
+		deleted = tag.RowsAffected()
+
+		audit.ResultCount = deleted
+		if _, err := tx.Exec(deleteCtx, "insert_audit", auditArgs(audit)...); err != nil {
+			return fmt.Errorf("failed to insert audit record: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// InsertAuditRecord appends audit to the audit_log table
+func (p *PostgresStore) InsertAuditRecord(ctx context.Context, audit *models.AuditRecord) error {
+	insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := p.pool.Exec(insertCtx, "insert_audit", auditArgs(audit)...); err != nil {
+		return fmt.Errorf("failed to insert audit record: %w", err)
+	}
+	return nil
+}
+
+// FindAuditRecords queries one page of the tenant's audit records
+func (p *PostgresStore) FindAuditRecords(ctx context.Context, tenantID string, query *models.AuditQuery) ([]*models.AuditRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var actor, userID, action, targetID *string
+	if query.Actor != "" {
+		actor = &query.Actor
+	}
+	if query.UserID != "" {
+		userID = &query.UserID
+	}
+	if query.Action != "" {
+		action = &query.Action
+	}
+	if query.TargetID != "" {
+		targetID = &query.TargetID
+	}
+	var since, until, limit any
+	if !query.Since.IsZero() {
+		since = query.Since
+	}
+	if !query.Until.IsZero() {
+		until = query.Until
+	}
+	if query.Limit > 0 {
+		limit = query.Limit
+	}
+
+	rows, err := p.pool.Query(queryCtx, "find_audit", tenantID, actor, userID, action, targetID, since, until, query.Skip, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit records: %w", err)
+	}
+	records, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.AuditRecord, error) {
+		var audit models.AuditRecord
+		err := row.Scan(&audit.Action, &audit.TenantID, &audit.Actor, &audit.AuthMethod, &audit.Endpoint,
+			&audit.Filters, &audit.UserID, &audit.PhoneNumber, &audit.TargetID, &audit.ResultCount,
+			&audit.RemoteAddr, &audit.CreatedAt)
+		audit.CreatedAt = audit.CreatedAt.UTC()
+		return &audit, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audit records: %w", err)
+	}
+	return records, nil
+}
+
+// auditArgs returns the insert_audit arguments of audit, in auditColumns order
+func auditArgs(audit *models.AuditRecord) []any {
+	return []any{audit.Action, audit.TenantID, audit.Actor, audit.AuthMethod, audit.Endpoint, audit.Filters,
+		audit.UserID, audit.PhoneNumber, audit.TargetID, audit.ResultCount, audit.RemoteAddr, audit.CreatedAt}
+}
+
+// FindMessagesOlderThan queries old messages of the selected tenants; an empty
+// list of tenants is passed as NULL so it selects every tenant
+func (p *PostgresStore) FindMessagesOlderThan(ctx context.Context, cutoff time.Time, tenants models.TenantFilter, limit int64) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var only, except []string
+	if len(tenants.Only) > 0 {
+		only = tenants.Only
+	}
+	if len(tenants.Except) > 0 {
+		except = tenants.Except
+	}
+	rows, err := p.pool.Query(queryCtx, "find_older_than", cutoff, only, except, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query old messages: %w", err)
+	}
+	records, err := collectRecords(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode old messages: %w", err)
+	}
+	return records, nil
+}
+
+// FindMessagesInIDRange queries messages by ID across all tenants; hex IDs sort
+// like the IDs themselves
+func (p *PostgresStore) FindMessagesInIDRange(ctx context.Context, after, before primitive.ObjectID, limit int64) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := p.pool.Query(queryCtx, "find_in_id_range", after.Hex(), before.Hex(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages by ID range: %w", err)
+	}
+	records, err := collectRecords(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode messages by ID range: %w", err)
+	}
+	return records, nil
+}
+
+// DeleteMessages deletes records by ID across all tenants
+func (p *PostgresStore) DeleteMessages(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	deleteCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := p.pool.Exec(deleteCtx, "delete_messages", hexIDs(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete messages: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PendingStoredEvents queries the outbox across all tenants, in ID order
+func (p *PostgresStore) PendingStoredEvents(ctx context.Context, limit int64) ([]*models.SMSRecord, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rows, err := p.pool.Query(queryCtx, "pending_stored_events", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending stored events: %w", err)
+	}
+	records, err := collectRecords(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pending stored events: %w", err)
+	}
+	return records, nil
+}
+
+// ClearStoredEventsPending clears the outbox flag across all tenants
+func (p *PostgresStore) ClearStoredEventsPending(ctx context.Context, ids []primitive.ObjectID) error {
+	updateCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := p.pool.Exec(updateCtx, "clear_stored_events", hexIDs(ids)); err != nil {
+		return fmt.Errorf("failed to clear pending stored events: %w", err)
+	}
+	return nil
+}
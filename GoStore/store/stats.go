@@ -0,0 +1,83 @@
+package store
+
+import (
+	"slices"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+)
+
+// statsBuilder rolls up message counts grouped by day, status and direction
+// into MessageStats, so every backend reports the same shape
+type statsBuilder struct {
+	stats  models.MessageStats
+	byDay  map[time.Time]*models.PeriodCount
+	byWeek map[time.Time]*models.PeriodCount
+}
+
+func newStatsBuilder() *statsBuilder {
+	return &statsBuilder{
+		stats: models.MessageStats{
+			ByStatus:    make(map[string]int64),
+			ByDirection: make(map[string]int64),
+		},
+		byDay:  make(map[time.Time]*models.PeriodCount),
+		byWeek: make(map[time.Time]*models.PeriodCount),
+	}
+}
+
+// billingUnits returns the segments record is billed as; records stored
+// without a segment count are billed as one
+func billingUnits(record *models.SMSRecord) int64 {
+	return int64(max(record.SegmentCount, 1))
+}
+
+// add counts count messages created on the UTC day of createdAt, billed as units
+func (b *statsBuilder) add(createdAt time.Time, status, direction string, count, units int64) {
+	if direction == "" {
+		direction = models.DirectionOutbound
+	}
+	day := createdAt.UTC().Truncate(24 * time.Hour)
+	// time.Weekday counts from Sunday; weeks start on Monday
+	week := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+
+	b.stats.Total += count
+	b.stats.BillingUnits += units
+	b.stats.ByStatus[status] += count
+	b.stats.ByDirection[direction] += count
+	addPeriod(b.byDay, day, count, units)
+	addPeriod(b.byWeek, week, count, units)
+}
+
+// addPeriod adds count messages and their units to the period starting at start
+func addPeriod(periods map[time.Time]*models.PeriodCount, start time.Time, count, units int64) {
+	period, ok := periods[start]
+	if !ok {
+		period = &models.PeriodCount{Start: start.Format(time.DateOnly)}
+		periods[start] = period
+	}
+	period.Count += count
+	period.BillingUnits += units
+}
+
+// build returns the stats added so far
+func (b *statsBuilder) build() *models.MessageStats {
+	b.stats.ByDay = periodCounts(b.byDay)
+	b.stats.ByWeek = periodCounts(b.byWeek)
+	return &b.stats
+}
+
+// periodCounts sorts counts by period start, oldest first
+func periodCounts(counts map[time.Time]*models.PeriodCount) []models.PeriodCount {
+	starts := make([]time.Time, 0, len(counts))
+	for start := range counts {
+		starts = append(starts, start)
+	}
+	slices.SortFunc(starts, time.Time.Compare)
+
+	periods := make([]models.PeriodCount, len(starts))
+	for n, start := range starts {
+		periods[n] = *counts[start]
+	}
+	return periods
+}
@@ -0,0 +1,129 @@
+// Package store persists SMS records behind a backend-neutral interface
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Storage backends selectable with STORAGE_BACKEND
+const (
+	BackendMongo     = "mongo"
+	BackendMemory    = "memory"
+	BackendPostgres  = "postgres"
+	BackendCassandra = "cassandra"
+)
+
+var (
+	// ErrNotFound is returned when no stored record matches
+	ErrNotFound = errors.New("message not found")
+
+	// ErrDuplicate is returned when a record with the same ID or message_id is already stored
+	ErrDuplicate = errors.New("message already stored")
+
+	// ErrQueryTimeout is returned when a body_regex query runs past its time limit
+	ErrQueryTimeout = errors.New("query exceeded its time limit")
+
+	// ErrRequeueLimit is returned when a message has been requeued as many times as allowed
+	ErrRequeueLimit = errors.New("message has reached its requeue limit")
+)
+
+// InsertResult reports the records of an InsertMessages call that were not stored,
+// by index into the records passed to it. Every other record was stored
+type InsertResult struct {
+	Duplicates map[int]bool  // already stored by an earlier delivery
+	Failed     map[int]error // rejected by the backend
+}
+
+// Store persists SMS records
+// Methods taking a tenant ID only see that tenant's records; the others span all tenants
+type Store interface {
+	// InsertMessage stores record, assigning its ID if unset
+	// Returns ErrDuplicate if the record is already stored
+	InsertMessage(ctx context.Context, record *models.SMSRecord) error
+
+	// InsertMessages stores records in as few writes as the backend allows, assigning
+	// IDs where unset. An error means the outcome of every record is unknown
+	InsertMessages(ctx context.Context, records []*models.SMSRecord) (InsertResult, error)
+
+	// FindMessages returns the user's messages matching query, newest first
+	FindMessages(ctx context.Context, tenantID string, query *models.MessageQuery) ([]*models.SMSRecord, error)
+
+	// StreamMessages calls fn for each of the user's messages matching query, newest first,
+	// without buffering the whole result. Iteration stops at the first error returned by fn
+	StreamMessages(ctx context.Context, tenantID string, query *models.MessageQuery, fn func(*models.SMSRecord) error) error
+
+	// FindMessagesByPhoneNumber returns the tenant's messages to or from phoneNumber,
+	// across all users, newest first, skipping skip of them and returning up to limit
+	FindMessagesByPhoneNumber(ctx context.Context, tenantID, phoneNumber string, skip, limit int64) ([]*models.SMSRecord, error)
+
+	// Conversations groups the user's messages by counterpart phone number and returns
+	// one page of the groups, most recently active first, skipping skip of them and
+	// returning up to limit
+	Conversations(ctx context.Context, tenantID, userID string, skip, limit int64) ([]*models.Conversation, error)
+
+	// CountMessages returns the number of the user's messages matching query, ignoring its pagination fields
+	CountMessages(ctx context.Context, tenantID string, query *models.MessageQuery) (int64, error)
+
+	// MessageStats counts the user's messages matching query by day, week, status and
+	// direction, ignoring its pagination fields
+	MessageStats(ctx context.Context, tenantID string, query *models.MessageQuery) (*models.MessageStats, error)
+
+	// FindDuplicates returns up to limit groups of the tenant's messages created from
+	// since until until that share a message_id, or a user_id, body and created_at,
+	// largest first
+	FindDuplicates(ctx context.Context, tenantID string, since, until time.Time, limit int64) ([]*models.DuplicateGroup, error)
+
+	// DeliveryStats counts the delivery outcomes of the tenant's outbound messages
+	// matching query, across all users, by sender ID, carrier and time bucket
+	DeliveryStats(ctx context.Context, tenantID string, query *models.DeliveryQuery) (*models.DeliveryStats, error)
+
+	// GetMessage returns the record with the given ID, or ErrNotFound
+	GetMessage(ctx context.Context, tenantID string, id primitive.ObjectID) (*models.SMSRecord, error)
+
+	// ClaimRequeue counts one more requeue of the record with the given ID and returns
+	// its requeues so far, this one included, or ErrRequeueLimit if it already had max,
+	// or ErrNotFound. The check and the count are a single conditional write, so
+	// concurrent claims from any number of instances never exceed max
+	ClaimRequeue(ctx context.Context, tenantID string, id primitive.ObjectID, max int) (int, error)
+
+	// UpdateStatus applies change to the record with the given message_id and returns
+	// the updated record, or ErrNotFound
+	UpdateStatus(ctx context.Context, messageID string, change models.StatusChange) (*models.SMSRecord, error)
+
+	// UpdateStatusByProviderID applies change to the tenant's record with the given
+	// vendor-assigned ID and returns the updated record, or ErrNotFound
+	UpdateStatusByProviderID(ctx context.Context, tenantID, providerMessageID string, change models.StatusChange) (*models.SMSRecord, error)
+
+	// DeleteUserMessages removes all of the user's messages, stored under any of
+	// userIDs, and stores audit with its ResultCount set, atomically where the
+	// backend supports it. Returns the number removed
+	DeleteUserMessages(ctx context.Context, tenantID string, userIDs []string, audit *models.AuditRecord) (int64, error)
+
+	// InsertAuditRecord appends audit to the audit log
+	InsertAuditRecord(ctx context.Context, audit *models.AuditRecord) error
+
+	// FindAuditRecords returns the tenant's audit records matching query, newest first
+	FindAuditRecords(ctx context.Context, tenantID string, query *models.AuditQuery) ([]*models.AuditRecord, error)
+
+	// FindMessagesOlderThan returns up to limit messages of the tenants selected by
+	// tenants created before cutoff, oldest first
+	FindMessagesOlderThan(ctx context.Context, cutoff time.Time, tenants models.TenantFilter, limit int64) ([]*models.SMSRecord, error)
+
+	// FindMessagesInIDRange returns up to limit messages whose IDs are after after and
+	// before before, across all tenants, in ID order
+	FindMessagesInIDRange(ctx context.Context, after, before primitive.ObjectID, limit int64) ([]*models.SMSRecord, error)
+
+	// DeleteMessages removes the records with the given IDs and returns the number removed
+	DeleteMessages(ctx context.Context, ids []primitive.ObjectID) (int64, error)
+
+	// PendingStoredEvents returns up to limit records whose stored event is pending, oldest first
+	PendingStoredEvents(ctx context.Context, limit int64) ([]*models.SMSRecord, error)
+
+	// ClearStoredEventsPending clears the pending stored event of the records with the given IDs
+	ClearStoredEventsPending(ctx context.Context, ids []primitive.ObjectID) error
+}
@@ -0,0 +1,230 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Orders of merged results that are not a user's sort
+var (
+	oldestFirst = func(a, b *models.SMSRecord) int {
+		return models.CompareRecords(a, b, models.MessageSort{Field: models.SortCreatedAt, Ascending: true})
+	}
+	newestFirst = func(a, b *models.SMSRecord) int {
+		return models.CompareRecords(a, b, models.MessageSort{Field: models.SortCreatedAt})
+	}
+	byID = func(a, b *models.SMSRecord) int { return bytes.Compare(a.ID[:], b.ID[:]) }
+)
+
+// coldTierReached reports whether messages created since since, or at any time
+// if since is zero, may have been moved to sms_records_cold
+func (m *MongoStore) coldTierReached(ctx context.Context, since time.Time) (bool, error) {
+	boundary, err := m.db.ColdTierBoundary(ctx)
+	if err != nil {
+		return false, err
+	}
+	return !boundary.IsZero() && (since.IsZero() || since.Before(boundary)), nil
+}
+
+// coldDuplicates returns the indexes of the records whose tenant already has a
+// message with their message_id in sms_records_cold, where the unique index of the
+// hot tier cannot see it. Only records created before the cold tier boundary are
+// looked up, and the cold tier is read from the primary like inserts are checked
+func (m *MongoStore) coldDuplicates(ctx context.Context, records []*models.SMSRecord) (map[int]bool, error) {
+	var pairs bson.A
+	for _, record := range records {
+		if record.MessageID == "" {
+			continue
+		}
+		cold, err := m.coldTierReached(ctx, record.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if cold {
+			pairs = append(pairs, bson.M{"tenant_id": record.TenantID, "message_id": record.MessageID})
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	opts := options.Find().SetProjection(bson.M{"_id": 0, "tenant_id": 1, "message_id": 1})
+	cursor, err := m.db.GetColdCollection().Find(ctx, bson.M{"$or": pairs}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up cold tier duplicates: %w", err)
+	}
+	var copies []struct {
+		TenantID  string `bson:"tenant_id"`
+		MessageID string `bson:"message_id"`
+	}
+	if err := cursor.All(ctx, &copies); err != nil {
+		return nil, fmt.Errorf("failed to decode cold tier duplicates: %w", err)
+	}
+
+	duplicates := make(map[int]bool, len(copies))
+	for _, c := range copies {
+		for i, record := range records {
+			if record.TenantID == c.TenantID && record.MessageID == c.MessageID {
+				duplicates[i] = true
+			}
+		}
+	}
+	return duplicates, nil
+}
+
+// tierQuery is query as run on each tier of a merged result: the page is
+// among the first skip+limit messages of each, and the sorted field is read
+// to merge them
+func tierQuery(query *models.MessageQuery) *models.MessageQuery {
+	tier := *query
+	tier.Skip = 0
+	if query.Limit > 0 {
+		tier.Limit = query.Skip + query.Limit
+	}
+	if len(query.Fields) > 0 {
+		tier.Fields = append(slices.Clone(query.Fields), query.Sort.Column())
+	}
+	return &tier
+}
+
+// findRecords runs a find and decodes every record it returns
+func findRecords(ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptions) ([]*models.SMSRecord, error) {
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []*models.SMSRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// mergeRecords merges the records of both tiers, each in compare order, into
+// one list in that order. A message moved between the two reads is in both;
+// its second copy is dropped
+func mergeRecords(hot, cold []*models.SMSRecord, compare func(a, b *models.SMSRecord) int) []*models.SMSRecord {
+	merged := make([]*models.SMSRecord, 0, len(hot)+len(cold))
+	seen := make(map[primitive.ObjectID]bool, len(hot)+len(cold))
+	add := func(record *models.SMSRecord) {
+		if !seen[record.ID] {
+			seen[record.ID] = true
+			merged = append(merged, record)
+		}
+	}
+	for len(hot) > 0 && len(cold) > 0 {
+		if compare(cold[0], hot[0]) < 0 {
+			add(cold[0])
+			cold = cold[1:]
+		} else {
+			add(hot[0])
+			hot = hot[1:]
+		}
+	}
+	for _, record := range hot {
+		add(record)
+	}
+	for _, record := range cold {
+		add(record)
+	}
+	return merged
+}
+
+// page returns the records after the first skip, at most limit of them unless zero
+func page(records []*models.SMSRecord, skip, limit int64) []*models.SMSRecord {
+	if skip >= int64(len(records)) {
+		return nil
+	}
+	records = records[skip:]
+	if limit > 0 && limit < int64(len(records)) {
+		records = records[:limit]
+	}
+	return records
+}
+
+// matchTiers starts a pipeline with the records matching filter, including
+// those of the cold tier if cold
+func matchTiers(filter bson.M, cold bool) mongo.Pipeline {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	if cold {
+		pipeline = append(pipeline, bson.D{{Key: "$unionWith", Value: bson.M{
+			"coll":     db.ColdRecordsCollection,
+			"pipeline": bson.A{bson.M{"$match": filter}},
+		}}})
+	}
+	return pipeline
+}
+
+// recordCursor is a cursor with its next record decoded, for merging streams
+type recordCursor struct {
+	cursor *mongo.Cursor
+	next   *models.SMSRecord
+}
+
+// advance decodes the cursor's next record, leaving next nil at its end
+func (c *recordCursor) advance(ctx context.Context) error {
+	c.next = nil
+	if c.cursor.Next(ctx) {
+		var record models.SMSRecord
+		if err := c.cursor.Decode(&record); err != nil {
+			return err
+		}
+		c.next = &record
+		return nil
+	}
+	return c.cursor.Err()
+}
+
+// mergeCursors calls fn with the records of both cursors, each in compare order,
+// in that order, skipping the first skip and stopping after limit unless zero.
+// A copy of a message in both tiers is dropped when the copies meet
+func mergeCursors(ctx context.Context, hot, cold *mongo.Cursor, compare func(a, b *models.SMSRecord) int, skip, limit int64, fn func(*models.SMSRecord) error) error {
+	tiers := [2]*recordCursor{{cursor: hot}, {cursor: cold}}
+	for _, tier := range tiers {
+		if err := tier.advance(ctx); err != nil {
+			return err
+		}
+	}
+
+	var sent int64
+	for tiers[0].next != nil || tiers[1].next != nil {
+		from, other := tiers[0], tiers[1]
+		if from.next == nil || (other.next != nil && compare(other.next, from.next) < 0) {
+			from, other = other, from
+		}
+		record := from.next
+		if err := from.advance(ctx); err != nil {
+			return err
+		}
+		if other.next != nil && other.next.ID == record.ID {
+			if err := other.advance(ctx); err != nil {
+				return err
+			}
+		}
+
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+		sent++
+		if limit > 0 && sent == limit {
+			return nil
+		}
+	}
+	return nil
+}
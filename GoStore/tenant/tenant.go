@@ -0,0 +1,34 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// Header is the HTTP header (and gRPC metadata key, lowercased) carrying the tenant ID
+const Header = "X-Tenant-ID"
+
+// ErrMissing is returned when a tenant-scoped operation runs without a tenant
+var ErrMissing = errors.New("tenant is required")
+
+// validID restricts tenant IDs to short URL- and key-safe identifiers
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type contextKey struct{}
+
+// IsValidID reports whether id is an acceptable tenant identifier
+func IsValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// WithID returns a copy of ctx scoped to the given tenant
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant the context is scoped to, if any
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,35 @@
+// Package testharness runs the service's dependencies for integration tests:
+// MongoDB and Kafka in throwaway containers, and an SMS service over the
+// in-memory store for tests that need neither. Containers are started with
+// testcontainers, so Docker (or another provider it supports) must be running
+//
+// Each Mongo has a connection of its own, handed to the components under test.
+// Containers are slow to start, so a test binary typically starts one from
+// TestMain and Resets it between tests, which then can't run in parallel
+package testharness
+
+import (
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+)
+
+// Memory is an SMS service over an in-memory store, publishing to its own broker
+type Memory struct {
+	Store   *store.MemoryStore
+	Broker  *events.Broker
+	Service *services.SMSService
+}
+
+// NewMemory creates an SMS service with an empty in-memory store. Features kept
+// in MongoDB, such as quotas or the sender registry, are left disabled; enable
+// them on Service once a Mongo is started
+func NewMemory() *Memory {
+	st := store.NewMemoryStore()
+	broker := events.NewBroker()
+	return &Memory{
+		Store:   st,
+		Broker:  broker,
+		Service: services.NewSMSService(st, broker),
+	}
+}
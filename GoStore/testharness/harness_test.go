@@ -0,0 +1,54 @@
+package testharness_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"github.com/ramG-reddy/sms-store/testharness"
+)
+
+func TestMemory(t *testing.T) {
+	h := testharness.NewMemory()
+	ctx := tenant.WithID(context.Background(), "t1")
+
+	stored, unsubscribe, err := h.Service.SubscribeUserMessages(ctx, "+14155550100")
+	if err != nil {
+		t.Fatalf("SubscribeUserMessages: %v", err)
+	}
+	defer unsubscribe()
+
+	record := &models.SMSRecord{
+		MessageID:   "m1",
+		TenantID:    "t1",
+		UserID:      "+14155550100",
+		PhoneNumber: "+14155550199",
+		Message:     "hello",
+		Status:      models.StatusSent,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := h.Service.SaveMessage(ctx, record); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+
+	select {
+	case event := <-stored:
+		if event.Type != events.MessageStored || event.Record.MessageID != "m1" {
+			t.Errorf("published %s for %q, want %s for m1", event.Type, event.Record.MessageID, events.MessageStored)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event published on the harness broker")
+	}
+
+	// The service writes to the harness's store
+	got, err := h.Store.GetMessage(ctx, "t1", record.ID)
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	if got.Message != "hello" {
+		t.Errorf("stored message = %q, want hello", got.Message)
+	}
+}
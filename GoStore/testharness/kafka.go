@@ -0,0 +1,102 @@
+package testharness
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/testcontainers/testcontainers-go"
+	tckafka "github.com/testcontainers/testcontainers-go/modules/kafka"
+)
+
+// DefaultKafkaImage is the broker image started when none is given
+const DefaultKafkaImage = "confluentinc/confluent-local:7.5.0"
+
+// Kafka is a single KRaft broker in a container
+type Kafka struct {
+	Brokers []string
+
+	container *tckafka.KafkaContainer
+}
+
+// StartKafka starts a Kafka container from image, DefaultKafkaImage when empty
+func StartKafka(ctx context.Context, image string) (*Kafka, error) {
+	if image == "" {
+		image = DefaultKafkaImage
+	}
+	container, err := tckafka.Run(ctx, image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start Kafka container: %w", err)
+	}
+	k := &Kafka{container: container}
+	if k.Brokers, err = container.Brokers(ctx); err != nil {
+		k.Stop()
+		return nil, fmt.Errorf("failed to read Kafka container address: %w", err)
+	}
+	return k, nil
+}
+
+// NewKafka starts a Kafka for the test and stops it when the test ends. The test
+// is skipped when no container provider is running
+func NewKafka(t *testing.T, image string) *Kafka {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	k, err := StartKafka(context.Background(), image)
+	if err != nil {
+		t.Fatalf("failed to start Kafka: %v", err)
+	}
+	t.Cleanup(k.Stop)
+	return k
+}
+
+// CreateTopic creates topic with the given number of partitions
+func (k *Kafka) CreateTopic(ctx context.Context, topic string, partitions int) error {
+	conn, err := kafka.DialContext(ctx, "tcp", k.Brokers[0])
+	if err != nil {
+		return fmt.Errorf("failed to connect to Kafka: %w", err)
+	}
+	defer conn.Close()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		return fmt.Errorf("failed to find Kafka controller: %w", err)
+	}
+	controllerConn, err := kafka.DialContext(ctx, "tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect to Kafka controller: %w", err)
+	}
+	defer controllerConn.Close()
+
+	err = controllerConn.CreateTopics(kafka.TopicConfig{Topic: topic, NumPartitions: partitions, ReplicationFactor: 1})
+	if err != nil {
+		return fmt.Errorf("failed to create topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Produce writes messages to topic, waiting until the broker has them
+func (k *Kafka) Produce(ctx context.Context, topic string, messages ...kafka.Message) error {
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(k.Brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}
+	defer writer.Close()
+
+	if err := writer.WriteMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("failed to produce to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Stop removes the container
+func (k *Kafka) Stop() {
+	_ = testcontainers.TerminateContainer(k.container)
+}
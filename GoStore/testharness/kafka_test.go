@@ -0,0 +1,45 @@
+package testharness_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/testharness"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKafka(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a Kafka container")
+	}
+	k := testharness.NewKafka(t, "")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const topic = "sms-events"
+	if err := k.CreateTopic(ctx, topic, 3); err != nil {
+		t.Fatalf("CreateTopic: %v", err)
+	}
+	err := k.Produce(ctx, topic,
+		kafka.Message{Key: []byte("+14155550100"), Value: []byte(`{"eventId":"m1"}`)},
+		kafka.Message{Key: []byte("+14155550100"), Value: []byte(`{"eventId":"m2"}`)},
+	)
+	if err != nil {
+		t.Fatalf("Produce: %v", err)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: k.Brokers, Topic: topic, GroupID: "testharness"})
+	defer reader.Close()
+
+	// Messages with the same key share a partition, so they are read in order
+	for _, want := range []string{`{"eventId":"m1"}`, `{"eventId":"m2"}`} {
+		message, err := reader.ReadMessage(ctx)
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		if string(message.Value) != want {
+			t.Errorf("read %s, want %s", message.Value, want)
+		}
+	}
+}
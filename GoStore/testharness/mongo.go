@@ -0,0 +1,122 @@
+package testharness
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Defaults of MongoConfig
+const (
+	DefaultMongoImage    = "mongo:6.0"
+	DefaultMongoDatabase = "sms_store_test"
+	DefaultTenantID      = "default"
+)
+
+// MongoConfig configures the MongoDB container
+type MongoConfig struct {
+	Image           string // default DefaultMongoImage
+	Database        string // default DefaultMongoDatabase
+	DefaultTenantID string // for migrations backfilling tenant IDs; default DefaultTenantID
+}
+
+// Mongo is a single-node MongoDB replica set in a container, and a connection
+// to it, so change streams and transactions work as in production
+type Mongo struct {
+	URI      string
+	Database string
+	DB       *db.Mongo // connected to Database; give it to the components under test
+
+	container *mongodb.MongoDBContainer
+}
+
+// StartMongo starts a MongoDB container, connects to it and prepares the database the way the service does at startup: schema
+// validation, indexes and every migration
+func StartMongo(ctx context.Context, cfg MongoConfig) (*Mongo, error) {
+	if cfg.Image == "" {
+		cfg.Image = DefaultMongoImage
+	}
+	if cfg.Database == "" {
+		cfg.Database = DefaultMongoDatabase
+	}
+	if cfg.DefaultTenantID == "" {
+		cfg.DefaultTenantID = DefaultTenantID
+	}
+
+	container, err := mongodb.Run(ctx, cfg.Image, mongodb.WithReplicaSet("rs0"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start MongoDB container: %w", err)
+	}
+	m := &Mongo{Database: cfg.Database, container: container}
+	if m.URI, err = container.ConnectionString(ctx); err != nil {
+		m.terminate()
+		return nil, fmt.Errorf("failed to read MongoDB container address: %w", err)
+	}
+
+	if m.DB, err = db.Connect(m.URI, m.Database); err != nil {
+		m.terminate()
+		return nil, err
+	}
+	if err := m.DB.EnsureRecordSchema(); err != nil {
+		m.Stop()
+		return nil, err
+	}
+	if err := m.DB.EnsureIndexes(); err != nil {
+		m.Stop()
+		return nil, err
+	}
+	if _, err := m.DB.MigrateUp(ctx, db.MigrationEnv{DefaultTenantID: cfg.DefaultTenantID}, 0); err != nil {
+		m.Stop()
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewMongo starts a Mongo for the test and stops it when the test ends. The test
+// is skipped when no container provider is running
+func NewMongo(t *testing.T, cfg MongoConfig) *Mongo {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	m, err := StartMongo(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("failed to start MongoDB: %v", err)
+	}
+	t.Cleanup(m.Stop)
+	return m
+}
+
+// Reset deletes every document of the database except the record of applied
+// migrations, keeping the collections with their indexes and validation
+func (m *Mongo) Reset(ctx context.Context) error {
+	names, err := m.DB.Database().ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+	for _, name := range names {
+		if name == db.MigrationsCollection || strings.HasPrefix(name, "system.") {
+			continue
+		}
+		if _, err := m.DB.Database().Collection(name).DeleteMany(ctx, bson.M{}); err != nil {
+			return fmt.Errorf("failed to empty %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Stop disconnects and removes the container
+func (m *Mongo) Stop() {
+	_ = m.DB.Close()
+	m.terminate()
+}
+
+// terminate removes the container
+func (m *Mongo) terminate() {
+	_ = testcontainers.TerminateContainer(m.container)
+}
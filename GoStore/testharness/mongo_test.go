@@ -0,0 +1,62 @@
+package testharness_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/services"
+	"github.com/ramG-reddy/sms-store/store"
+	"github.com/ramG-reddy/sms-store/tenant"
+	"github.com/ramG-reddy/sms-store/testharness"
+)
+
+func TestMongo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a MongoDB container")
+	}
+	m := testharness.NewMongo(t, testharness.MongoConfig{})
+	ctx := tenant.WithID(context.Background(), "t1")
+	svc := services.NewSMSService(store.NewMongoStore(m.DB), events.NewBroker())
+
+	record := func() *models.SMSRecord {
+		return &models.SMSRecord{
+			MessageID:   "m1",
+			TenantID:    "t1",
+			UserID:      "+14155550100",
+			PhoneNumber: "+14155550199",
+			Message:     "hello",
+			Status:      models.StatusSent,
+			CreatedAt:   time.Now().UTC(),
+		}
+	}
+	if err := svc.SaveMessage(ctx, record()); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	// The unique message_id index created at startup deduplicates redeliveries
+	if err := svc.SaveMessage(ctx, record()); err != nil {
+		t.Fatalf("SaveMessage of a duplicate: %v", err)
+	}
+	if count, err := svc.GetMessageCount(ctx, "+14155550100"); err != nil || count != 1 {
+		t.Fatalf("GetMessageCount = %d, %v; want 1", count, err)
+	}
+
+	if err := m.Reset(ctx); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if count, err := svc.GetMessageCount(ctx, "+14155550100"); err != nil || count != 0 {
+		t.Fatalf("GetMessageCount after Reset = %d, %v; want 0", count, err)
+	}
+	// Reset keeps the indexes
+	if err := svc.SaveMessage(ctx, record()); err != nil {
+		t.Fatalf("SaveMessage after Reset: %v", err)
+	}
+	if err := svc.SaveMessage(ctx, record()); err != nil {
+		t.Fatalf("SaveMessage of a duplicate after Reset: %v", err)
+	}
+	if count, err := svc.GetMessageCount(ctx, "+14155550100"); err != nil || count != 1 {
+		t.Fatalf("GetMessageCount after Reset = %d, %v; want 1", count, err)
+	}
+}
@@ -0,0 +1,130 @@
+// Package tiering keeps sms_records small by moving messages past an age to
+// sms_records_cold, a zstd-compressed collection with fewer indexes. The store
+// merges both tiers into the results of queries reaching back that far
+package tiering
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/db"
+	"github.com/ramG-reddy/sms-store/lease"
+	"github.com/ramG-reddy/sms-store/metrics"
+)
+
+// Config controls how often the mover runs and which messages it moves
+type Config struct {
+	Interval  time.Duration
+	ColdAfter time.Duration // age at which messages are moved
+	BatchSize int64
+}
+
+// Mover periodically moves messages older than Config.ColdAfter to the cold tier
+type Mover struct {
+	cfg      Config
+	db       *db.Mongo
+	leases   *lease.Leases
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewMover creates a mover of the messages in m; the cold tier collection is
+// created by migration 4
+func NewMover(cfg Config, m *db.Mongo, leases *lease.Leases) *Mover {
+	return &Mover{cfg: cfg, db: m, leases: leases, stopChan: make(chan struct{})}
+}
+
+// Start runs the mover on the configured interval in a background goroutine
+func (m *Mover) Start() {
+	slog.Info("Starting tiering job", "interval", m.cfg.Interval, "cold_after", m.cfg.ColdAfter)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stopChan:
+				return
+			case <-ticker.C:
+				err := m.RunOnce(context.Background())
+				if errors.Is(err, lease.ErrHeld) {
+					slog.Debug("Tiering skipped; another instance ran it this period")
+				} else if err != nil {
+					slog.Error("Tiering failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop waits for an in-progress run to finish its batch and stops the schedule
+func (m *Mover) Stop() {
+	slog.Info("Stopping tiering job")
+	close(m.stopChan)
+	m.wg.Wait()
+	slog.Info("Tiering job stopped")
+}
+
+// RunOnce moves the messages past the configured age, a batch at a time, until
+// none remain. Only one instance moves each interval; the others get lease.ErrHeld
+func (m *Mover) RunOnce(ctx context.Context) error {
+	return m.leases.Run(ctx, "tiering", m.cfg.Interval, m.run)
+}
+
+// run moves the messages while holding the lease
+func (m *Mover) run(ctx context.Context) error {
+	exists, err := m.db.ColdTierExists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("the cold tier collection does not exist yet; apply the pending migrations")
+	}
+
+	// Queries read the cold tier from the boundary down; raise it first, and
+	// wait until every instance has seen it before moving anything past it
+	cutoff := time.Now().UTC().Add(-m.cfg.ColdAfter)
+	if err := m.db.RaiseColdTierBoundary(ctx, cutoff); err != nil {
+		return err
+	}
+	select {
+	case <-m.stopChan:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(db.ColdBoundaryPropagation):
+	}
+
+	total := int64(0)
+	defer func() {
+		if total > 0 {
+			metrics.TieringMessagesMoved(total)
+			slog.Info("Moved messages to the cold tier", "count", total, "cutoff", cutoff)
+		}
+	}()
+
+	for {
+		select {
+		case <-m.stopChan:
+			return nil
+		default:
+		}
+
+		moved, err := m.db.MoveToColdTier(ctx, cutoff, m.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		total += moved
+
+		if moved < m.cfg.BatchSize {
+			return nil
+		}
+	}
+}
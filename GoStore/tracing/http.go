@@ -0,0 +1,29 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for every request, continuing any trace in the
+// incoming traceparent header. Spans are named by the matched ServeMux pattern
+func Middleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(routeNamer(next), "http.request")
+}
+
+// routeNamer renames the request span after the mux has matched a route
+// ServeMux sets the pattern on the request it was given, which otelhttp owns
+func routeNamer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if r.Pattern != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Pattern)
+			span.SetAttributes(semconv.HTTPRoute(r.Pattern))
+		}
+	})
+}
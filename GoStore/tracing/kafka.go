@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier adapts Kafka message headers to a propagation.TextMapCarrier
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// StartBatchSpan starts a span covering the bulk write of a batch of messages,
+// linked to each message's consume span
+func StartBatchSpan(ctx context.Context, topic string, messageContexts []context.Context) (context.Context, trace.Span) {
+	links := make([]trace.Link, 0, len(messageContexts))
+	for _, messageCtx := range messageContexts {
+		links = append(links, trace.LinkFromContext(messageCtx))
+	}
+
+	return Tracer().Start(ctx, "store batch "+topic,
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingBatchMessageCount(len(messageContexts)),
+		),
+	)
+}
+
+// StartConsumeSpan starts a consumer span for message, continuing the producer's
+// trace from the message's traceparent header when present
+func StartConsumeSpan(ctx context.Context, message *kafka.Message) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier{headers: &message.Headers})
+
+	return Tracer().Start(ctx, "process "+message.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(message.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(message.Partition)),
+			attribute.Int64("messaging.kafka.offset", message.Offset),
+		),
+	)
+}
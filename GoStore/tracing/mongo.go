@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MongoTracer creates client spans for MongoDB commands from driver monitor events
+type MongoTracer struct {
+	spans sync.Map // request ID -> trace.Span
+}
+
+// NewMongoTracer creates a MongoDB command tracer
+func NewMongoTracer() *MongoTracer {
+	return &MongoTracer{}
+}
+
+// Started begins a span for the command as a child of the caller's context
+func (t *MongoTracer) Started(ctx context.Context, e *event.CommandStartedEvent) {
+	_, span := Tracer().Start(ctx, e.CommandName+" "+e.DatabaseName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameMongoDB,
+			semconv.DBNamespace(e.DatabaseName),
+			semconv.DBOperationName(e.CommandName),
+		),
+	)
+	t.spans.Store(e.RequestID, span)
+}
+
+// Succeeded ends the command's span
+func (t *MongoTracer) Succeeded(_ context.Context, e *event.CommandSucceededEvent) {
+	if span, ok := t.spans.LoadAndDelete(e.RequestID); ok {
+		span.(trace.Span).End()
+	}
+}
+
+// Failed records the error and ends the command's span
+func (t *MongoTracer) Failed(_ context.Context, e *event.CommandFailedEvent) {
+	if span, ok := t.spans.LoadAndDelete(e.RequestID); ok {
+		s := span.(trace.Span)
+		s.SetStatus(codes.Error, e.Failure)
+		s.End()
+	}
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies spans created by this service's own instrumentation
+const instrumentationName = "github.com/ramG-reddy/sms-store"
+
+// Config controls span export
+type Config struct {
+	Enabled     bool
+	ServiceName string
+	SampleRatio float64
+}
+
+// Init installs the global tracer provider and W3C trace-context propagator
+// Spans are exported over OTLP/gRPC to OTEL_EXPORTER_OTLP_ENDPOINT. The returned
+// function flushes pending spans and must be called on shutdown
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	// Always propagate context so traces pass through even when export is disabled
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	slog.Info("Tracing enabled", "service_name", cfg.ServiceName, "sample_ratio", cfg.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer used by the service's own instrumentation
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
@@ -0,0 +1,261 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ramG-reddy/sms-store/events"
+	"github.com/ramG-reddy/sms-store/models"
+	"github.com/ramG-reddy/sms-store/netguard"
+	"github.com/ramG-reddy/sms-store/services"
+)
+
+// queueSize bounds the number of events waiting for delivery
+const queueSize = 1000
+
+// Request headers sent with every webhook delivery
+const (
+	HeaderEvent      = "X-SMS-Event"
+	HeaderDeliveryID = "X-SMS-Delivery"
+	HeaderTimestamp  = "X-SMS-Timestamp"
+	HeaderSignature  = "X-SMS-Signature"
+)
+
+// Config controls delivery concurrency, timeouts, and retries
+type Config struct {
+	Workers     int
+	MaxAttempts int
+	Timeout     time.Duration
+	RetryBase   time.Duration
+}
+
+// Dispatcher POSTs message events to registered webhook subscriptions
+type Dispatcher struct {
+	cfg            Config
+	webhookService *services.WebhookService
+	client         *http.Client
+	queue          chan events.Event
+	ctx            context.Context
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
+}
+
+// NewDispatcher creates a new webhook dispatcher instance
+func NewDispatcher(cfg Config, webhookService *services.WebhookService) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		cfg:            cfg,
+		webhookService: webhookService,
+		client:         newClient(cfg.Timeout),
+		queue:          make(chan events.Event, queueSize),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// newClient returns a client that only connects to publicly routable addresses
+// and doesn't follow redirects, so subscriptions cannot reach the service's own
+// network, whether directly, through DNS or through a redirect
+func newClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = netguard.Dialer(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Start subscribes to the broker and launches delivery workers
+func (d *Dispatcher) Start(broker *events.Broker) {
+	slog.Info("Starting webhook dispatcher", "workers", d.cfg.Workers)
+
+	broker.AddListener(d.enqueue)
+	for i := 0; i < d.cfg.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+}
+
+// Stop cancels pending retries and waits for workers to exit
+func (d *Dispatcher) Stop() {
+	slog.Info("Stopping webhook dispatcher")
+	d.cancel()
+	d.wg.Wait()
+	slog.Info("Webhook dispatcher stopped")
+}
+
+// enqueue hands an event to the workers without blocking the publisher
+func (d *Dispatcher) enqueue(event events.Event) {
+	select {
+	case d.queue <- event:
+	default:
+		slog.Warn("Webhook queue full, dropping event", "event", event.Type, "tenant_id", event.Record.TenantID, "user_id", event.Record.UserID, "message_id", event.Record.MessageID)
+	}
+}
+
+// work delivers queued events until the dispatcher is stopped
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case event := <-d.queue:
+			d.dispatch(event)
+		}
+	}
+}
+
+// dispatch delivers an event to every matching subscription that subscribes to
+// its type
+func (d *Dispatcher) dispatch(event events.Event) {
+	subs, err := d.webhookService.SubscriptionsForUser(d.ctx, event.Record.TenantID, event.Record.UserID)
+	if err != nil {
+		slog.Error("Error loading webhook subscriptions", "error", err)
+		return
+	}
+	subs = slices.DeleteFunc(subs, func(sub *models.WebhookSubscription) bool {
+		return !sub.Subscribes(event.Type)
+	})
+	if len(subs) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Error encoding webhook payload", "error", err)
+		return
+	}
+
+	for _, sub := range subs {
+		d.deliver(sub, event.Type, body)
+	}
+}
+
+// deliver POSTs the payload to a subscription, retrying transient failures with
+// jittered exponential backoff and logging every attempt
+func (d *Dispatcher) deliver(sub *models.WebhookSubscription, eventType string, body []byte) {
+	deliveryID, err := newDeliveryID()
+	if err != nil {
+		slog.Error("Error generating webhook delivery ID", "error", err)
+		return
+	}
+
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		statusCode, duration, err := d.post(sub, eventType, deliveryID, body)
+
+		delivery := &models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			DeliveryID:     deliveryID,
+			Event:          eventType,
+			URL:            sub.URL,
+			Attempt:        attempt,
+			StatusCode:     statusCode,
+			Success:        err == nil && statusCode >= 200 && statusCode < 300,
+			DurationMs:     duration.Milliseconds(),
+			CreatedAt:      time.Now().UTC(),
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if logErr := d.webhookService.RecordDelivery(d.ctx, delivery); logErr != nil {
+			slog.Error("Error recording webhook delivery", "delivery_id", deliveryID, "error", logErr)
+		}
+
+		if delivery.Success {
+			slog.Info("Delivered webhook", "event", eventType, "delivery_id", deliveryID, "url", sub.URL, "attempt", attempt)
+			return
+		}
+		if err == nil && !retryable(statusCode) {
+			slog.Warn("Webhook rejected, not retrying", "delivery_id", deliveryID, "url", sub.URL, "status_code", statusCode)
+			return
+		}
+		if attempt == d.cfg.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(backoff(d.cfg.RetryBase, attempt)):
+		}
+	}
+
+	slog.Error("Giving up on webhook", "delivery_id", deliveryID, "url", sub.URL, "attempts", d.cfg.MaxAttempts)
+}
+
+// post performs a single signed delivery attempt
+func (d *Dispatcher) post(sub *models.WebhookSubscription, eventType, deliveryID string, body []byte) (int, time.Duration, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDeliveryID, deliveryID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	duration := time.Since(start)
+	if err != nil {
+		return 0, duration, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, duration, nil
+}
+
+// Sign computes the signature header value: sha256=HMAC(secret, timestamp + "." + body)
+// Receivers should recompute it and compare in constant time
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable reports whether a response status warrants another attempt
+func retryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// backoff returns base * 2^(attempt-1) plus up to 50% random jitter
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base << (attempt - 1)
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(delay/2)+1))
+	if err != nil {
+		return delay
+	}
+	return delay + time.Duration(jitter.Int64())
+}
+
+// newDeliveryID returns a random identifier shared by all attempts of one delivery
+func newDeliveryID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
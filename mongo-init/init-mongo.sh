@@ -1,6 +1,7 @@
 #!/bin/bash
 # MongoDB initialization script
-# This script creates a non-root user, collection, and indexes for the sms_store database
+# This script creates a non-root user for the sms_store database
+# The sms_records collection and its indexes are created by the Go service on startup
 # Runs automatically on first container startup with environment variables
 # Security: All inputs are properly quoted and validated
 
@@ -32,7 +33,7 @@ until mongosh --quiet --eval "db.adminCommand('ping')" > /dev/null 2>&1; do
   sleep 2
 done
 
-echo "MongoDB is ready. Creating user, collection, and indexes..."
+echo "MongoDB is ready. Creating user..."
 
 # Execute MongoDB commands using mongosh with proper error handling
 mongosh --quiet <<EOF
@@ -61,53 +62,7 @@ try {
   }
 }
 
-// Create sms_records collection
-try {
-  db.createCollection('sms_records')
-  print('✓ Collection created successfully')
-} catch(e) {
-  if (e.code === 48) {
-    print('⚠ Collection already exists, skipping...')
-  } else {
-    print('✗ Error creating collection: ' + e.message)
-    throw e
-  }
-}
-
-// Create indexes with custom names (matching Go application expectations)
-try {
-  // Single field index on user_id
-  db.sms_records.createIndex(
-    { user_id: 1 },
-    { name: 'idx_user_id' }
-  )
-  print('✓ Index idx_user_id created')
-  
-  // Single field index on created_at (descending for recent queries)
-  db.sms_records.createIndex(
-    { created_at: -1 },
-    { name: 'idx_created_at' }
-  )
-  print('✓ Index idx_created_at created')
-  
-  // Compound index for efficient user queries sorted by time
-  db.sms_records.createIndex(
-    { user_id: 1, created_at: -1 },
-    { name: 'idx_user_id_created_at' }
-  )
-  print('✓ Index idx_user_id_created_at created')
-} catch(e) {
-  if (e.code === 85 || e.code === 86) {
-    print('⚠ Some indexes already exist, skipping...')
-  } else {
-    print('✗ Error creating indexes: ' + e.message)
-    throw e
-  }
-}
-
-// Verify setup
-var indexCount = db.sms_records.getIndexes().length
-print('✓ Total indexes: ' + indexCount)
+// sms_records, its schema validation, and its indexes are created by the Go service at startup
 
 EOF
 
@@ -117,8 +72,6 @@ if [ $EXIT_CODE -eq 0 ]; then
   echo "========================================="
   echo "MongoDB initialization completed successfully!"
   echo "✓ User: ${MONGO_APP_USER} (readWrite role)"
-  echo "✓ Collection: sms_records"
-  echo "✓ Indexes: idx_user_id, idx_created_at, idx_user_id_created_at"
   echo "========================================="
 else
   echo "========================================="
@@ -133,6 +86,4 @@ EOF
 echo "========================================="
 echo "MongoDB initialization completed!"
 echo "User '${MONGO_APP_USER}' created with readWrite permissions"
-echo "Collection 'sms_records' created with indexes"
-echo "Indexes: user_id, created_at, user_id+created_at"
 echo "========================================="